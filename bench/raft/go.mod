@@ -0,0 +1,13 @@
+module github.com/jabolina/go-mcast/bench/raft
+
+go 1.14
+
+require (
+	github.com/hashicorp/go-immutable-radix v1.3.1 // indirect
+	github.com/hashicorp/golang-lru v0.5.4 // indirect
+	github.com/hashicorp/raft v1.5.0
+	github.com/jabolina/go-mcast v0.0.0
+	golang.org/x/sys v0.7.0 // indirect
+)
+
+replace github.com/jabolina/go-mcast => ../..
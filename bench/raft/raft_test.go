@@ -0,0 +1,213 @@
+package raft
+
+import (
+	"fmt"
+	"github.com/hashicorp/raft"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// How many replicas each system runs, so both tolerate the
+// same number of failures.
+const replicas = 3
+
+// The value written by every request.
+var value = make([]byte, 128)
+
+// Writes a single value, returning once it is committed.
+type writer func(key []byte) error
+
+// Runs the workload against the writer, reporting the throughput
+// and the latency percentiles. The sequential workload measures the
+// latency of a single client, the concurrent one the throughput with
+// many clients issuing requests at the same time.
+func workload(b *testing.B, write writer) {
+	b.Run("Sequential", func(b *testing.B) {
+		measure(b, func(latencies []time.Duration) {
+			for i := 0; i < b.N; i++ {
+				latencies[i] = timed(b, write, i)
+			}
+		})
+	})
+	b.Run("Concurrent", func(b *testing.B) {
+		measure(b, func(latencies []time.Duration) {
+			next := make(chan int)
+			group := &sync.WaitGroup{}
+			for c := 0; c < 16; c++ {
+				group.Add(1)
+				go func() {
+					defer group.Done()
+					for i := range next {
+						latencies[i] = timed(b, write, i)
+					}
+				}()
+			}
+			for i := 0; i < b.N; i++ {
+				next <- i
+			}
+			close(next)
+			group.Wait()
+		})
+	})
+}
+
+// Execute the requests and report the numbers.
+func measure(b *testing.B, execute func(latencies []time.Duration)) {
+	latencies := make([]time.Duration, b.N)
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	execute(latencies)
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	b.ReportMetric(float64(b.N)/elapsed.Seconds(), "ops/s")
+	b.ReportMetric(float64(percentile(latencies, 0.5).Microseconds()), "p50-us")
+	b.ReportMetric(float64(percentile(latencies, 0.99).Microseconds()), "p99-us")
+}
+
+// Write the i-th key, returning how long it took.
+func timed(b *testing.B, write writer, i int) time.Duration {
+	start := time.Now()
+	if err := write([]byte(fmt.Sprintf("key-%d", i))); err != nil {
+		b.Errorf("failed writing %d. %v", i, err)
+	}
+	return time.Since(start)
+}
+
+// The latency at the given percentile of the sorted latencies.
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	return latencies[int(float64(len(latencies)-1)*p)]
+}
+
+// A single partition totally orders every request, the same
+// guarantee of the raft log.
+func BenchmarkMulticast(b *testing.B) {
+	partition := types.Partition("bench-mcast")
+	conf := mcast.DefaultConfiguration(partition)
+	conf.Replication = replicas
+	conf.LogLevels = map[string]types.LogLevel{"": types.LevelError}
+	unity, err := mcast.NewMulticastConfigured(conf)
+	if err != nil {
+		b.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	workload(b, func(key []byte) error {
+		res := <-unity.Write(types.Request{
+			Key:         key,
+			Value:       value,
+			Destination: []types.Partition{partition},
+			Consistency: types.ConsistencyTotalOrder,
+		})
+		if !res.Success {
+			return res.Failure
+		}
+		return nil
+	})
+}
+
+// A raft cluster on the in memory transport and stores, applying
+// every request on the leader.
+func BenchmarkRaft(b *testing.B) {
+	leader, shutdown, err := cluster(replicas)
+	if err != nil {
+		b.Fatalf("failed creating cluster. %v", err)
+	}
+	defer shutdown()
+
+	workload(b, func(key []byte) error {
+		command := append(append([]byte{}, key...), value...)
+		return leader.Apply(command, 5*time.Second).Error()
+	})
+}
+
+// Creates the raft cluster, returning the leader once elected.
+func cluster(size int) (*raft.Raft, func(), error) {
+	var nodes []*raft.Raft
+	var transports []*raft.InmemTransport
+	var servers []raft.Server
+	for i := 0; i < size; i++ {
+		id := raft.ServerID(fmt.Sprintf("bench-raft-%d", i))
+		address, transport := raft.NewInmemTransport(raft.ServerAddress(id))
+		transports = append(transports, transport)
+		servers = append(servers, raft.Server{ID: id, Address: address})
+	}
+	for _, t := range transports {
+		for _, other := range transports {
+			t.Connect(other.LocalAddr(), other)
+		}
+	}
+
+	shutdown := func() {
+		for _, node := range nodes {
+			node.Shutdown().Error()
+		}
+	}
+	for i, t := range transports {
+		conf := raft.DefaultConfig()
+		conf.LocalID = servers[i].ID
+		conf.LogOutput = ioutil.Discard
+		store := raft.NewInmemStore()
+		node, err := raft.NewRaft(conf, &machine{values: make(map[string][]byte)}, store, store, raft.NewDiscardSnapshotStore(), t)
+		if err != nil {
+			shutdown()
+			return nil, nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if err := nodes[0].BootstrapCluster(raft.Configuration{Servers: servers}).Error(); err != nil {
+		shutdown()
+		return nil, nil, err
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, node := range nodes {
+			if node.State() == raft.Leader {
+				return node, shutdown, nil
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	shutdown()
+	return nil, nil, fmt.Errorf("no leader elected")
+}
+
+// The state machine of the raft cluster, keeping the last value
+// written, as the storage of the multicast.
+type machine struct {
+	mutex  sync.Mutex
+	values map[string][]byte
+}
+
+// Implements the raft.FSM interface.
+func (m *machine) Apply(log *raft.Log) interface{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	split := len(log.Data) - len(value)
+	m.values[string(log.Data[:split])] = log.Data[split:]
+	return nil
+}
+
+// Implements the raft.FSM interface.
+func (m *machine) Snapshot() (raft.FSMSnapshot, error) {
+	return nil, fmt.Errorf("snapshots are not benchmarked")
+}
+
+// Implements the raft.FSM interface.
+func (m *machine) Restore(snapshot io.ReadCloser) error {
+	return snapshot.Close()
+}
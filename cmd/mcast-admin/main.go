@@ -0,0 +1,94 @@
+// Command mcast-admin inspects and unsticks a message through
+// the admin handler exposed by the unity.
+//
+//	mcast-admin -addr http://peer:8080/admin -uid <uid>
+//	mcast-admin -addr http://peer:8080/admin -uid <uid> -action resend
+//	mcast-admin -addr http://peer:8080/admin -uid <uid> -action expire
+//
+// The log levels are changed through the log levels handler, without
+// a level the current levels are printed:
+//
+//	mcast-admin -addr http://peer:8080/log -action levels
+//	mcast-admin -addr http://peer:8080/log -action levels -subsystem transport -level debug
+//
+// Expiring a message fails the request and the message is never
+// delivered by the unity, only expire messages known to be safe.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+func main() {
+	addr := flag.String("addr", "http://localhost:8080/admin", "address of the admin handler")
+	uid := flag.String("uid", "", "identifier of the message")
+	action := flag.String("action", "inspect", "inspect, resend, expire or levels")
+	subsystem := flag.String("subsystem", "", "subsystem to change the log level, empty for the root level")
+	level := flag.String("level", "", "log level to set on the subsystem")
+	flag.Parse()
+
+	var err error
+	if *action == "levels" {
+		err = levels(*addr, *subsystem, *level)
+	} else {
+		err = run(*addr, *uid, *action)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(addr, uid, action string) error {
+	if len(uid) == 0 {
+		return fmt.Errorf("missing uid")
+	}
+
+	query := url.Values{"uid": {uid}}
+	var res *http.Response
+	var err error
+	if action == "inspect" {
+		res, err = http.Get(addr + "?" + query.Encode())
+	} else {
+		query.Set("action", action)
+		res, err = http.Post(addr+"?"+query.Encode(), "application/json", nil)
+	}
+	if err != nil {
+		return err
+	}
+	return output(res)
+}
+
+func levels(addr, subsystem, level string) error {
+	var res *http.Response
+	var err error
+	if len(level) == 0 {
+		res, err = http.Get(addr)
+	} else {
+		query := url.Values{"subsystem": {subsystem}, "level": {level}}
+		res, err = http.Post(addr+"?"+query.Encode(), "application/json", nil)
+	}
+	if err != nil {
+		return err
+	}
+	return output(res)
+}
+
+func output(res *http.Response) error {
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", res.Status, body)
+	}
+	fmt.Print(string(body))
+	return nil
+}
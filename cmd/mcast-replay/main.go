@@ -0,0 +1,70 @@
+// Command mcast-replay replays the events dumped by the events
+// handler, reporting the protocol violations found.
+//
+//	curl http://peer:8080/events > events.json
+//	mcast-replay -file events.json -until 2020-07-20T10:00:00Z
+//
+// The until flag replays only the events recorded before the given
+// time, which can be used to bisect when a violation started.
+// The command exits with status 1 when a violation is found.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/replay"
+	"io"
+	"os"
+	"time"
+)
+
+func main() {
+	file := flag.String("file", "-", "file with the recorded events, - reads from stdin")
+	until := flag.String("until", "", "replay only the events until the given RFC3339 time")
+	flag.Parse()
+
+	if err := run(*file, *until); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(file, until string) error {
+	var reader io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	var limit time.Time
+	if len(until) > 0 {
+		parsed, err := time.Parse(time.RFC3339Nano, until)
+		if err != nil {
+			return err
+		}
+		limit = parsed
+	}
+
+	events, err := replay.Load(reader)
+	if err != nil {
+		return err
+	}
+
+	report := replay.Replay(events, limit)
+	for peer, delivered := range report.Delivered {
+		fmt.Printf("%s delivered %d messages\n", peer, len(delivered))
+	}
+	for _, violation := range report.Violations {
+		fmt.Println(violation)
+	}
+
+	if len(report.Violations) > 0 {
+		return fmt.Errorf("found %d violations on %d events", len(report.Violations), report.Events)
+	}
+	fmt.Printf("replayed %d events without violations\n", report.Events)
+	return nil
+}
@@ -0,0 +1,54 @@
+// Command mcast-timeline renders the events dumped by the events
+// handler as a timeline for each key, showing the states and the
+// deliveries of the messages across the partitions.
+//
+//	curl http://peer:8080/events > events.json
+//	mcast-timeline -file events.json -format mermaid > timeline.mmd
+//	mcast-timeline -file events.json -format dot -key orders | dot -Tsvg > orders.svg
+//
+// The json format writes the timelines to be consumed by a user
+// interface. When the key flag is set only the key is rendered.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/replay"
+	"github.com/jabolina/go-mcast/pkg/mcast/timeline"
+	"io"
+	"os"
+)
+
+func main() {
+	file := flag.String("file", "-", "file with the recorded events, - reads from stdin")
+	format := flag.String("format", string(timeline.Mermaid), "output format: mermaid, dot or json")
+	key := flag.String("key", "", "render only the timeline of the given key")
+	flag.Parse()
+
+	if err := run(*file, timeline.Format(*format), *key); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(file string, format timeline.Format, key string) error {
+	var reader io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	events, err := replay.Load(reader)
+	if err != nil {
+		return err
+	}
+	timelines := timeline.Build(events, key)
+	if len(timelines) == 0 {
+		return fmt.Errorf("no events recorded for the key %q", key)
+	}
+	return timeline.Render(os.Stdout, timelines, format)
+}
@@ -0,0 +1,10 @@
+module github.com/jabolina/go-mcast/compression/zstd
+
+go 1.22
+
+require (
+	github.com/jabolina/go-mcast v0.0.0
+	github.com/klauspost/compress v1.18.0
+)
+
+replace github.com/jabolina/go-mcast => ../..
@@ -0,0 +1,164 @@
+// Package zstd implements the dictionary compression of the message
+// contents with zstd. It is a separate module, so the library does
+// not require the compression dependency.
+//
+//	conf.Compression = types.Compression{
+//		Codec:        zstd.NewCodec(),
+//		Dictionaries: definition.NewInMemoryDictionaries(),
+//	}
+package zstd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"github.com/klauspost/compress/zstd"
+	"hash/fnv"
+	"sync"
+)
+
+// The largest history trained into a dictionary, in bytes.
+const HistorySize = 64 << 10
+
+var (
+	// Returned when there are no samples to train a dictionary.
+	ErrNoSamples = errors.New("no samples to train")
+
+	// Returned when the samples do not train a useful dictionary,
+	// such as when every sample is the same.
+	ErrUntrainable = errors.New("samples can not train a dictionary")
+)
+
+// Implements the types.DictionaryCodec interface with zstd. The
+// encoders and decoders of each dictionary are created once and
+// reused, so the codec must be shared by the peers.
+type Codec struct {
+	// The compression level, the default when zero.
+	Level zstd.EncoderLevel
+
+	// Synchronize access to the encoders and decoders.
+	mutex *sync.Mutex
+
+	// The encoders by dictionary identifier.
+	encoders map[uint32]*zstd.Encoder
+
+	// The decoders by dictionary identifier.
+	decoders map[uint32]*zstd.Decoder
+}
+
+var _ types.DictionaryCodec = (*Codec)(nil)
+
+// Creates a new codec with the default level.
+func NewCodec() *Codec {
+	return &Codec{
+		mutex:    &sync.Mutex{},
+		encoders: make(map[uint32]*zstd.Encoder),
+		decoders: make(map[uint32]*zstd.Decoder),
+	}
+}
+
+// Implements the types.DictionaryCodec interface.
+// The older half of the samples is the dictionary history, up to the
+// HistorySize, and the newer half trains the entropy tables against
+// the history.
+func (c *Codec) Train(samples [][]byte) (dictionary []byte, err error) {
+	half := len(samples) / 2
+	var history []byte
+	for i := half - 1; i >= 0 && len(history)+len(samples[i]) <= HistorySize; i-- {
+		history = append(append([]byte(nil), samples[i]...), history...)
+	}
+	if len(history) == 0 {
+		return nil, ErrNoSamples
+	}
+
+	// The builder panics when the contents are all on the history.
+	defer func() {
+		if r := recover(); r != nil {
+			dictionary, err = nil, fmt.Errorf("%w: %v", ErrUntrainable, r)
+		}
+	}()
+	h := fnv.New32a()
+	h.Write(history)
+	dictionary, err = zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       h.Sum32() | 1,
+		Contents: samples[half:],
+		History:  history,
+		Offsets:  [3]int{1, 4, 8},
+		Level:    c.Level,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUntrainable, err)
+	}
+	return dictionary, nil
+}
+
+// Implements the types.DictionaryCodec interface.
+func (c *Codec) Compress(dictionary, content []byte) ([]byte, error) {
+	encoder, err := c.encoder(dictionary)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.EncodeAll(content, nil), nil
+}
+
+// Implements the types.DictionaryCodec interface.
+func (c *Codec) Decompress(dictionary, content []byte) ([]byte, error) {
+	decoder, err := c.decoder(dictionary)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.DecodeAll(content, nil)
+}
+
+// The encoder of the dictionary, created once.
+func (c *Codec) encoder(dictionary []byte) (*zstd.Encoder, error) {
+	id, err := identify(dictionary)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if encoder, ok := c.encoders[id]; ok {
+		return encoder, nil
+	}
+
+	options := []zstd.EOption{zstd.WithEncoderDict(dictionary)}
+	if c.Level != 0 {
+		options = append(options, zstd.WithEncoderLevel(c.Level))
+	}
+	encoder, err := zstd.NewWriter(nil, options...)
+	if err != nil {
+		return nil, err
+	}
+	c.encoders[id] = encoder
+	return encoder, nil
+}
+
+// The decoder of the dictionary, created once.
+func (c *Codec) decoder(dictionary []byte) (*zstd.Decoder, error) {
+	id, err := identify(dictionary)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if decoder, ok := c.decoders[id]; ok {
+		return decoder, nil
+	}
+
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderDicts(dictionary))
+	if err != nil {
+		return nil, err
+	}
+	c.decoders[id] = decoder
+	return decoder, nil
+}
+
+// The identifier written on the dictionary.
+func identify(dictionary []byte) (uint32, error) {
+	info, err := zstd.InspectDictionary(dictionary)
+	if err != nil {
+		return 0, err
+	}
+	return info.ID(), nil
+}
@@ -0,0 +1,53 @@
+package zstd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"testing"
+)
+
+func samples(n int) [][]byte {
+	var contents [][]byte
+	for i := 0; i < n; i++ {
+		contents = append(contents, []byte(fmt.Sprintf(`{"user":"user-%d","status":"active","plan":"premium","region":"eu-west"}`, i)))
+	}
+	return contents
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	codec := NewCodec()
+	dictionary, err := codec.Train(samples(500))
+	if err != nil {
+		t.Fatalf("failed training. %v", err)
+	}
+
+	content := []byte(`{"user":"user-9999","status":"active","plan":"premium","region":"eu-west"}`)
+	compressed, err := codec.Compress(dictionary, content)
+	if err != nil {
+		t.Fatalf("failed compressing. %v", err)
+	}
+	plain, _ := zstd.NewWriter(nil)
+	if without := plain.EncodeAll(content, nil); len(compressed) >= len(without) {
+		t.Errorf("expected the dictionary to compress better, found %d and %d bytes", len(compressed), len(without))
+	}
+
+	decompressed, err := codec.Decompress(dictionary, compressed)
+	if err != nil || !bytes.Equal(decompressed, content) {
+		t.Errorf("expected the content back, found %q. %v", decompressed, err)
+	}
+	if _, err := NewCodec().Decompress(dictionary, compressed); err != nil {
+		t.Errorf("expected another codec to decompress, found %v", err)
+	}
+}
+
+func TestCodec_NoSamples(t *testing.T) {
+	if _, err := NewCodec().Train(samples(1)); !errors.Is(err, ErrNoSamples) {
+		t.Errorf("expected no samples, found %v", err)
+	}
+	same := [][]byte{[]byte("same content"), []byte("same content"), []byte("same content"), []byte("same content")}
+	if _, err := NewCodec().Train(same); !errors.Is(err, ErrUntrainable) {
+		t.Errorf("expected untrainable samples, found %v", err)
+	}
+}
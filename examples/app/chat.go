@@ -0,0 +1,151 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+)
+
+// The key holding the messages of a room.
+var roomKey = []byte("messages")
+
+// A message posted on a chat room.
+type ChatMessage struct {
+	// The message identifier.
+	Identifier types.UID
+
+	// Who posted the message.
+	From string
+
+	// The message text.
+	Text string
+}
+
+func (c ChatMessage) String() string {
+	return fmt.Sprintf("%s: %s", c.From, c.Text)
+}
+
+// A storage holding the messages of a room in the order they were
+// committed. Since every replica commits the same entries on the
+// shared storage, the messages already held are ignored. The other
+// keys, used by the peers, are kept in memory.
+type RoomStorage struct {
+	// Synchronize access to the messages.
+	mutex *sync.Mutex
+
+	// Holds the keys that are not messages.
+	types.Storage
+
+	// The committed messages, in order.
+	messages []ChatMessage
+
+	// The messages already held.
+	held map[types.UID]bool
+}
+
+// Creates a new storage for an empty room.
+func NewRoomStorage() *RoomStorage {
+	return &RoomStorage{
+		mutex:   &sync.Mutex{},
+		Storage: definition.NewInMemoryStorage(),
+		held:    make(map[types.UID]bool),
+	}
+}
+
+// Implements the Storage interface.
+func (r *RoomStorage) Set(key []byte, value []byte) error {
+	if !bytes.Equal(key, roomKey) {
+		return r.Storage.Set(key, value)
+	}
+
+	var entry types.Entry
+	if err := json.Unmarshal(value, &entry); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.held[entry.Identifier] {
+		return nil
+	}
+	r.held[entry.Identifier] = true
+	r.messages = append(r.messages, ChatMessage{
+		Identifier: entry.Identifier,
+		From:       string(entry.Extensions),
+		Text:       string(entry.Data),
+	})
+	return nil
+}
+
+// Implements the Storage interface.
+func (r *RoomStorage) Get(key []byte) ([]byte, error) {
+	if !bytes.Equal(key, roomKey) {
+		return r.Storage.Get(key)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	data, err := json.Marshal(r.messages)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(types.Entry{Operation: types.Command, Key: key, Data: data})
+}
+
+// A chat where each room is a partition. A message posted to many
+// rooms is multicast to all of them at once, so every room sees the
+// messages they have in common in the same order.
+type Chat struct {
+	// The partition of each room.
+	rooms map[string]mcast.Unity
+}
+
+// Creates a new chat with the given rooms, each room must commit
+// the values on a RoomStorage.
+func NewChat(rooms map[string]mcast.Unity) *Chat {
+	return &Chat{rooms: rooms}
+}
+
+// Post the message on the given rooms, waiting until committed.
+func (c *Chat) Post(from, text string, rooms ...string) error {
+	var destination []types.Partition
+	for _, room := range rooms {
+		if _, ok := c.rooms[room]; !ok {
+			return fmt.Errorf("unknown room %q", room)
+		}
+		destination = append(destination, types.Partition(room))
+	}
+	if len(destination) == 0 {
+		return fmt.Errorf("message without room")
+	}
+
+	_, err := write(c.rooms[rooms[0]], types.Request{
+		Key:         roomKey,
+		Value:       []byte(text),
+		Extra:       []byte(from),
+		Destination: destination,
+	})
+	return err
+}
+
+// The messages posted on the room, in order.
+func (c *Chat) Messages(room string) ([]ChatMessage, error) {
+	unity, ok := c.rooms[room]
+	if !ok {
+		return nil, fmt.Errorf("unknown room %q", room)
+	}
+
+	res, err := unity.Read(types.Request{Key: roomKey, Destination: []types.Partition{types.Partition(room)}})
+	if err != nil {
+		return nil, err
+	}
+	var messages []ChatMessage
+	if err := json.Unmarshal(res.Data, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
@@ -0,0 +1,121 @@
+// Package app holds the example applications built on top of
+// the multicast, used by the commands on the examples folder.
+//
+// Every example runs its partitions on a single process, using
+// either the in memory broker or RabbitMQ through relt.
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"time"
+)
+
+const (
+	// Every partition communicates through the process memory.
+	TransportMemory = "memory"
+
+	// The partitions communicate through RabbitMQ.
+	TransportRelt = "relt"
+)
+
+// How long the examples wait for a write to be committed.
+var WriteTimeout = 5 * time.Second
+
+var (
+	// Returned when the write is not committed in time.
+	ErrWriteTimeout = errors.New("timeout waiting for write")
+)
+
+// Creates the unity of a partition.
+type Factory func(configuration *types.Configuration) (mcast.Unity, error)
+
+// The partitions of an example, all running on this process
+// and connected through the same broker.
+type Cluster struct {
+	// Synchronize access to the partitions.
+	mutex *sync.Mutex
+
+	// The broker connecting the partitions, nil for relt.
+	broker types.Broker
+
+	// Creates the unity of each partition.
+	factory Factory
+
+	// The partitions started.
+	partitions []mcast.Unity
+}
+
+// Creates a new cluster using the given transport. Without a
+// factory the partitions are created with NewMulticastConfigured.
+func NewCluster(transport string, factory Factory) (*Cluster, error) {
+	var broker types.Broker
+	switch transport {
+	case TransportMemory:
+		broker = core.NewMemoryBroker()
+	case TransportRelt:
+	default:
+		return nil, fmt.Errorf("unknown transport %q", transport)
+	}
+
+	if factory == nil {
+		factory = mcast.NewMulticastConfigured
+	}
+	return &Cluster{
+		mutex:   &sync.Mutex{},
+		broker:  broker,
+		factory: factory,
+	}, nil
+}
+
+// Start a partition with the given name, committing the values on
+// the given storage. Every replica of the partition shares the storage.
+func (c *Cluster) Partition(name string, storage types.Storage) (mcast.Unity, error) {
+	conf := mcast.DefaultConfiguration(types.Partition(name))
+	conf.Broker = c.broker
+	conf.Storage = storage
+	conf.LogLevels = map[string]types.LogLevel{"": types.LevelWarn}
+	unity, err := c.factory(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.partitions = append(c.partitions, unity)
+	return unity, nil
+}
+
+// Shutdown every partition at once, since a partition waits
+// for the others to stop when shutting down.
+func (c *Cluster) Shutdown() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	group := &sync.WaitGroup{}
+	for _, unity := range c.partitions {
+		group.Add(1)
+		go func(unity mcast.Unity) {
+			defer group.Done()
+			unity.Shutdown()
+		}(unity)
+	}
+	group.Wait()
+	c.partitions = nil
+}
+
+// Write the request and wait until it is committed.
+func write(unity mcast.Unity, request types.Request) (types.Response, error) {
+	select {
+	case res := <-unity.Write(request):
+		if !res.Success {
+			return res, res.Failure
+		}
+		return res, nil
+	case <-time.After(WriteTimeout):
+		return types.Response{}, ErrWriteTimeout
+	}
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"strconv"
+	"sync"
+)
+
+// Prefix of the keys holding the counters.
+var counterPrefix = []byte("counter/")
+
+// A storage where each value committed on a counter is an increment
+// added to the counter total. Since every replica commits the same
+// entries on the shared storage, the entries already added are
+// ignored. The other keys, used by the peers, are kept in memory.
+type CounterStorage struct {
+	// Synchronize access to the totals.
+	mutex *sync.Mutex
+
+	// Holds the keys that are not counters.
+	types.Storage
+
+	// The total of each key.
+	totals map[string]int64
+
+	// The entries already added.
+	added map[types.UID]bool
+}
+
+// Creates a new storage with every counter on zero.
+func NewCounterStorage() *CounterStorage {
+	return &CounterStorage{
+		mutex:   &sync.Mutex{},
+		Storage: definition.NewInMemoryStorage(),
+		totals:  make(map[string]int64),
+		added:   make(map[types.UID]bool),
+	}
+}
+
+// Implements the Storage interface.
+func (c *CounterStorage) Set(key []byte, value []byte) error {
+	if !bytes.HasPrefix(key, counterPrefix) {
+		return c.Storage.Set(key, value)
+	}
+
+	var entry types.Entry
+	if err := json.Unmarshal(value, &entry); err != nil {
+		return err
+	}
+	increment, err := strconv.ParseInt(string(entry.Data), 10, 64)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.added[entry.Identifier] {
+		return nil
+	}
+	c.added[entry.Identifier] = true
+	c.totals[string(key)] += increment
+	return nil
+}
+
+// Implements the Storage interface.
+func (c *CounterStorage) Get(key []byte) ([]byte, error) {
+	if !bytes.HasPrefix(key, counterPrefix) {
+		return c.Storage.Get(key)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return json.Marshal(types.Entry{
+		Operation: types.Command,
+		Key:       key,
+		Data:      []byte(strconv.FormatInt(c.totals[string(key)], 10)),
+	})
+}
+
+// A distributed counter, where the increments are replicated
+// across the partition and applied in the same order.
+type Counter struct {
+	// The partition holding the counter.
+	unity mcast.Unity
+
+	// The partition name.
+	partition types.Partition
+
+	// The key holding the counter.
+	name []byte
+}
+
+// Creates a new counter with the given name, the partition must
+// commit the values on a CounterStorage.
+func NewCounter(unity mcast.Unity, partition, name string) *Counter {
+	return &Counter{
+		unity:     unity,
+		partition: types.Partition(partition),
+		name:      append(append([]byte{}, counterPrefix...), name...),
+	}
+}
+
+// Add the increment to the counter, waiting until committed.
+func (c *Counter) Add(increment int64) error {
+	_, err := write(c.unity, types.Request{
+		Key:         c.name,
+		Value:       []byte(strconv.FormatInt(increment, 10)),
+		Destination: []types.Partition{c.partition},
+	})
+	return err
+}
+
+// The current counter value on one of the replicas.
+func (c *Counter) Value() (int64, error) {
+	res, err := c.unity.Read(types.Request{Key: c.name, Destination: []types.Partition{c.partition}})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(res.Data), 10, 64)
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// The path prefix of the key-value server.
+const KeysPath = "/keys/"
+
+// A replicated key-value store served over HTTP.
+//
+//	PUT /keys/{key} replicates the request body as the key value;
+//	GET /keys/{key} reads the key value from one of the replicas.
+type KVServer struct {
+	// The partition holding the keys.
+	unity mcast.Unity
+
+	// The partition name.
+	partition types.Partition
+}
+
+// Creates a new server for the keys on the given partition.
+func NewKVServer(unity mcast.Unity, partition string) *KVServer {
+	return &KVServer{unity: unity, partition: types.Partition(partition)}
+}
+
+// Implements the http.Handler interface.
+func (k *KVServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, KeysPath)
+	if len(key) == 0 || key == r.URL.Path {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		res, err := k.unity.Read(types.Request{Key: []byte(key), Destination: []types.Partition{k.partition}})
+		if err != nil || !res.Success {
+			http.Error(w, "key not found", http.StatusNotFound)
+			return
+		}
+		w.Write(res.Data)
+	case http.MethodPut:
+		value, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		request := types.Request{Key: []byte(key), Value: value, Destination: []types.Partition{k.partition}}
+		if _, err := write(k.unity, request); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,77 @@
+// Command chat is a chat where each room is a partition. Each line
+// read is posted on the given rooms, and a message posted to many
+// rooms is ordered the same way on all of them.
+//
+//	chat -transport memory -rooms general,random -user alice
+//	general,random: hello everyone
+//	/show general
+//
+// The relt transport requires a RabbitMQ broker running.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/jabolina/go-mcast/examples/app"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	transport := flag.String("transport", app.TransportMemory, "memory or relt")
+	rooms := flag.String("rooms", "general,random", "comma separated rooms of the chat")
+	user := flag.String("user", "anonymous", "name posting the messages")
+	flag.Parse()
+
+	if err := run(*transport, strings.Split(*rooms, ","), *user, os.Stdin); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(transport string, rooms []string, user string, input io.Reader) error {
+	cluster, err := app.NewCluster(transport, nil)
+	if err != nil {
+		return err
+	}
+	defer cluster.Shutdown()
+
+	partitions := make(map[string]mcast.Unity)
+	for _, room := range rooms {
+		unity, err := cluster.Partition(room, app.NewRoomStorage())
+		if err != nil {
+			return err
+		}
+		partitions[room] = unity
+	}
+
+	chat := app.NewChat(partitions)
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if room := strings.TrimPrefix(line, "/show "); room != line {
+			messages, err := chat.Messages(room)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			for _, message := range messages {
+				fmt.Printf("[%s] %s\n", room, message)
+			}
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			fmt.Fprintln(os.Stderr, "expected rooms: text or /show room")
+			continue
+		}
+		if err := chat.Post(user, strings.TrimSpace(line[i+1:]), strings.Split(line[:i], ",")...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	return scanner.Err()
+}
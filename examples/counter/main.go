@@ -0,0 +1,70 @@
+// Command counter increments a replicated counter concurrently
+// and verifies no increment was lost.
+//
+//	counter -transport memory -workers 4 -increments 50
+//
+// The relt transport requires a RabbitMQ broker running.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jabolina/go-mcast/examples/app"
+	"os"
+	"sync"
+)
+
+func main() {
+	transport := flag.String("transport", app.TransportMemory, "memory or relt")
+	workers := flag.Int("workers", 4, "how many workers increment concurrently")
+	increments := flag.Int("increments", 50, "how many increments each worker applies")
+	flag.Parse()
+
+	if err := run(*transport, *workers, *increments); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(transport string, workers, increments int) error {
+	cluster, err := app.NewCluster(transport, nil)
+	if err != nil {
+		return err
+	}
+	defer cluster.Shutdown()
+	unity, err := cluster.Partition("counter", app.NewCounterStorage())
+	if err != nil {
+		return err
+	}
+
+	counter := app.NewCounter(unity, "counter", "visits")
+	group := &sync.WaitGroup{}
+	failures := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for j := 0; j < increments; j++ {
+				if err := counter.Add(1); err != nil {
+					failures <- err
+					return
+				}
+			}
+		}()
+	}
+	group.Wait()
+	close(failures)
+	if err, ok := <-failures; ok {
+		return err
+	}
+
+	value, err := counter.Value()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("counter at %d, expected %d\n", value, workers*increments)
+	if value != int64(workers*increments) {
+		return fmt.Errorf("lost %d increments", int64(workers*increments)-value)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Command kv serves a replicated key-value store over HTTP.
+//
+//	kv -transport memory -addr :8080
+//	curl -X PUT -d world http://localhost:8080/keys/hello
+//	curl http://localhost:8080/keys/hello
+//
+// The relt transport requires a RabbitMQ broker running.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jabolina/go-mcast/examples/app"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"net/http"
+	"os"
+)
+
+func main() {
+	transport := flag.String("transport", app.TransportMemory, "memory or relt")
+	partition := flag.String("partition", "kv", "name of the partition holding the keys")
+	addr := flag.String("addr", ":8080", "address to serve the keys")
+	flag.Parse()
+
+	if err := run(*transport, *partition, *addr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(transport, partition, addr string) error {
+	cluster, err := app.NewCluster(transport, nil)
+	if err != nil {
+		return err
+	}
+	defer cluster.Shutdown()
+	unity, err := cluster.Partition(partition, definition.NewInMemoryStorage())
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(app.KeysPath, app.NewKVServer(unity, partition))
+	fmt.Printf("serving %s on %s\n", partition, addr)
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,84 @@
+package fuzzy
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"github.com/jabolina/go-mcast/test"
+	"go.uber.org/goleak"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Crash and restart the peers of a partition one at a time, while
+// concurrent writes are in flight. The writes are issued through
+// the other partitions, so the restarted partition only takes part
+// as a destination. Every acknowledged write must be committed on
+// all partitions and the partitions must agree on the final value.
+//
+// A restarted peer comes back without the received messages, so it
+// may commit a message before its replicas commit the ones it never
+// received. Only the partitions that never crashed are verified to
+// commit in the same order.
+func Test_CrashRestartKeepsAcknowledgedWrites(t *testing.T) {
+	cluster, _, storages := createFaultyCluster(3, "crash", t)
+	defer func() {
+		if !test.WaitThisOrTimeout(cluster.Off, 30*time.Second) {
+			t.Error("failed shutdown cluster")
+			test.PrintStackTrace(t)
+		}
+		goleak.VerifyNone(t)
+	}()
+
+	crashed := cluster.Unities[2]
+	writers := cluster.Unities[:2]
+	key := []byte("alphabet")
+
+	mutex := &sync.Mutex{}
+	var acknowledged []types.UID
+	group := &sync.WaitGroup{}
+	for i, letter := range test.Alphabet {
+		group.Add(1)
+		go func(i int, letter string) {
+			defer group.Done()
+			time.Sleep(time.Duration(i) * 20 * time.Millisecond)
+			req := test.GenerateRequest(key, []byte(letter), cluster.Names)
+			res := <-writers[i%len(writers)].Write(req)
+			if res.Success {
+				mutex.Lock()
+				acknowledged = append(acknowledged, res.Identifier)
+				mutex.Unlock()
+			}
+		}(i, letter)
+	}
+
+	for i := 0; i < 2; i++ {
+		time.Sleep(100 * time.Millisecond)
+		test.CrashPeer(crashed, i)
+		time.Sleep(100 * time.Millisecond)
+		if err := test.RestartPeer(crashed, i); err != nil {
+			t.Fatalf("failed restarting peer %d. %v", i, err)
+		}
+	}
+
+	if !test.WaitThisOrTimeout(group.Wait, 30*time.Second) {
+		t.Fatalf("writes not finished after restarting")
+	}
+
+	time.Sleep(time.Second)
+	if len(acknowledged) == 0 {
+		t.Fatalf("no write acknowledged")
+	}
+	for i, storage := range storages {
+		committed := make(map[types.UID]bool)
+		for _, uid := range storage.committed() {
+			committed[uid] = true
+		}
+		for _, uid := range acknowledged {
+			if !committed[uid] {
+				t.Errorf("partition %d lost acknowledged write %s", i, uid)
+			}
+		}
+	}
+	cluster.DoesAllClusterMatch(key)
+	sameOrder(t, storages[:2], len(storages[0].committed()))
+}
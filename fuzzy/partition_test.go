@@ -0,0 +1,186 @@
+package fuzzy
+
+import (
+	"encoding/json"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"github.com/jabolina/go-mcast/test"
+	"go.uber.org/goleak"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A storage recording the order the entries were committed.
+// Every replica commits the same entries on the shared storage,
+// so the entries already recorded are ignored.
+type orderStorage struct {
+	mutex *sync.Mutex
+	types.Storage
+	order    []types.UID
+	recorded map[types.UID]bool
+}
+
+func newOrderStorage() *orderStorage {
+	return &orderStorage{
+		mutex:    &sync.Mutex{},
+		Storage:  definition.NewInMemoryStorage(),
+		recorded: make(map[types.UID]bool),
+	}
+}
+
+func (o *orderStorage) Set(key []byte, value []byte) error {
+	var entry types.Entry
+	if err := json.Unmarshal(value, &entry); err == nil && entry.Operation == types.Command && len(entry.Identifier) > 0 {
+		o.mutex.Lock()
+		if !o.recorded[entry.Identifier] {
+			o.recorded[entry.Identifier] = true
+			o.order = append(o.order, entry.Identifier)
+		}
+		o.mutex.Unlock()
+	}
+	return o.Storage.Set(key, value)
+}
+
+func (o *orderStorage) committed() []types.UID {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return append([]types.UID{}, o.order...)
+}
+
+// Creates a cluster communicating through a faulty broker, each
+// partition recording the order it committed the entries.
+func createFaultyCluster(size int, prefix string, t *testing.T) (*test.UnityCluster, *test.FaultyBroker, []*orderStorage) {
+	broker := test.NewFaultyBroker(core.NewMemoryBroker())
+	var storages []*orderStorage
+	cluster := test.CreateClusterWith(size, prefix, t, func(conf *types.Configuration) {
+		storage := newOrderStorage()
+		storages = append(storages, storage)
+		conf.Storage = storage
+		conf.Broker = broker
+	})
+	return cluster, broker, storages
+}
+
+// Every partition received all the writes, so all of them
+// must have committed the same entries in the same order.
+func sameOrder(t *testing.T, storages []*orderStorage, expected int) {
+	first := storages[0].committed()
+	if len(first) != expected {
+		t.Errorf("expected %d entries committed, found %d", expected, len(first))
+	}
+	for i, storage := range storages[1:] {
+		order := storage.committed()
+		if len(order) != len(first) {
+			t.Errorf("partition %d committed %d entries, expected %d", i+1, len(order), len(first))
+			continue
+		}
+		for j := range order {
+			if order[j] != first[j] {
+				t.Errorf("partition %d committed %s at %d, expected %s", i+1, order[j], j, first[j])
+				break
+			}
+		}
+	}
+}
+
+// Write the letters concurrently to every partition, returning a
+// channel closed once every write is acknowledged.
+func writeConcurrently(t *testing.T, cluster *test.UnityCluster, key []byte, letters []string) <-chan struct{} {
+	group := &sync.WaitGroup{}
+	for _, letter := range letters {
+		group.Add(1)
+		go func(letter string) {
+			defer group.Done()
+			req := test.GenerateRequest(key, []byte(letter), cluster.Names)
+			res := <-cluster.Next().Write(req)
+			if !res.Success {
+				t.Errorf("failed writting %s. %v", letter, res.Failure)
+			}
+		}(letter)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		group.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// Sever two of the partitions before issuing concurrent writes to
+// all of them. Since every write needs the timestamp of every
+// destination, no write completes while severed. After healing the
+// pending writes must complete and every partition must commit
+// them in the same order.
+func Test_SeveredPartitionsCompleteAfterHealing(t *testing.T) {
+	cluster, broker, storages := createFaultyCluster(3, "severed", t)
+	defer func() {
+		if !test.WaitThisOrTimeout(cluster.Off, 30*time.Second) {
+			t.Error("failed shutdown cluster")
+			test.PrintStackTrace(t)
+		}
+		goleak.VerifyNone(t)
+	}()
+
+	broker.Sever(cluster.Names[0], cluster.Names[1])
+	key := []byte("alphabet")
+	done := writeConcurrently(t, cluster, key, test.Alphabet)
+
+	select {
+	case <-done:
+		t.Fatalf("writes completed while partitions severed")
+	case <-time.After(2 * time.Second):
+	}
+
+	if broker.Held() == 0 {
+		t.Errorf("no data held by the severed partitions")
+	}
+	broker.Heal()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("pending writes not finished after healing")
+	}
+
+	time.Sleep(time.Second)
+	cluster.DoesAllClusterMatch(key)
+	sameOrder(t, storages, len(test.Alphabet))
+}
+
+// Issue concurrent writes while partitions are severed and healed
+// repeatedly mid-run, each time a different pair. At the end every
+// write must complete and every partition must commit the writes
+// in the same order.
+func Test_RepeatedPartitionsKeepOrder(t *testing.T) {
+	cluster, broker, storages := createFaultyCluster(3, "repeated", t)
+	defer func() {
+		if !test.WaitThisOrTimeout(cluster.Off, 30*time.Second) {
+			t.Error("failed shutdown cluster")
+			test.PrintStackTrace(t)
+		}
+		goleak.VerifyNone(t)
+	}()
+
+	key := []byte("alphabet")
+	done := writeConcurrently(t, cluster, key, test.Alphabet)
+
+	for i := 0; i < len(cluster.Names); i++ {
+		broker.Sever(cluster.Names[i], cluster.Names[(i+1)%len(cluster.Names)])
+		time.Sleep(300 * time.Millisecond)
+		broker.Heal()
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("pending writes not finished after healing")
+	}
+
+	time.Sleep(time.Second)
+	cluster.DoesAllClusterMatch(key)
+	sameOrder(t, storages, len(test.Alphabet))
+}
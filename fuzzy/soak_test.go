@@ -0,0 +1,164 @@
+//go:build soak
+// +build soak
+
+package fuzzy
+
+import (
+	"flag"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"github.com/jabolina/go-mcast/test"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	soakDuration = flag.Duration("soak.duration", time.Hour, "how long the soak test runs")
+	soakSample   = flag.Duration("soak.sample", time.Minute, "how often the process metrics are sampled")
+	soakWriters  = flag.Int("soak.writers", 8, "how many concurrent writers")
+)
+
+// The write latencies observed during the soak.
+type latencies struct {
+	mutex    *sync.Mutex
+	observed []time.Duration
+	failed   int
+}
+
+func (l *latencies) observe(latency time.Duration, success bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !success {
+		l.failed++
+		return
+	}
+	l.observed = append(l.observed, latency)
+}
+
+// The latency percentiles of the observed writes, resetting them.
+func (l *latencies) percentiles(ps ...float64) ([]time.Duration, int, int) {
+	l.mutex.Lock()
+	observed, failed := l.observed, l.failed
+	l.observed, l.failed = nil, 0
+	l.mutex.Unlock()
+
+	values := make([]time.Duration, len(ps))
+	if len(observed) == 0 {
+		return values, 0, failed
+	}
+	sort.Slice(observed, func(i, j int) bool { return observed[i] < observed[j] })
+	for i, p := range ps {
+		values[i] = observed[int(p*float64(len(observed)-1))]
+	}
+	return values, len(observed), failed
+}
+
+// The memory and goroutines on the process.
+func sample() (uint64, int) {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc, runtime.NumGoroutine()
+}
+
+// Issue a mix of writes to a single random partition, writes to
+// all partitions and reads, until stopped.
+func soakLoad(cluster *test.UnityCluster, observed *latencies, stop <-chan struct{}) {
+	keys := test.GenerateDataArray(16)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		key := []byte(keys[rand.Intn(len(keys))])
+		unity := cluster.Next()
+		switch n := rand.Intn(10); {
+		case n < 2:
+			unity.Read(test.GenerateRandomRequestValue(key, cluster.Names))
+		case n < 5:
+			started := time.Now()
+			res := <-unity.Write(test.GenerateRandomRequestValue(key, cluster.Names))
+			observed.observe(time.Since(started), res.Success)
+		default:
+			// A unity only answers the writes it is a destination.
+			i := rand.Intn(len(cluster.Names))
+			started := time.Now()
+			res := <-cluster.Unities[i].Write(test.GenerateRandomRequestValue(key, []types.Partition{cluster.Names[i]}))
+			observed.observe(time.Since(started), res.Success)
+		}
+	}
+}
+
+// Run the cluster under continuous mixed load with random latencies
+// on the broker, executed only with the soak tag:
+//
+//	go test -tags soak -timeout 0 -run Soak ./fuzzy -soak.duration 4h
+//
+// The heap, goroutines and write latency percentiles are logged on
+// each sample, the test fails if no write is answered between two
+// samples. After the load stops the goroutines must go back to the
+// count before the load, any goroutine left behind is a leak.
+func Test_Soak(t *testing.T) {
+	cluster, broker, _ := createFaultyCluster(3, "soak", t)
+	defer func() {
+		if !test.WaitThisOrTimeout(cluster.Off, 30*time.Second) {
+			t.Error("failed shutdown cluster")
+			test.PrintStackTrace(t)
+		}
+	}()
+	broker.Latency(time.Millisecond, 20*time.Millisecond)
+
+	baseHeap, baseGoroutines := sample()
+	t.Logf("soak for %v with %d writers, heap %d bytes and %d goroutines", *soakDuration, *soakWriters, baseHeap, baseGoroutines)
+
+	observed := &latencies{mutex: &sync.Mutex{}}
+	stop := make(chan struct{})
+	group := &sync.WaitGroup{}
+	for i := 0; i < *soakWriters; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			soakLoad(cluster, observed, stop)
+		}()
+	}
+
+	finished := time.After(*soakDuration)
+	ticker := time.NewTicker(*soakSample)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-finished:
+			running = false
+		case <-ticker.C:
+		}
+		heap, goroutines := sample()
+		p, writes, failed := observed.percentiles(0.5, 0.9, 0.99)
+		t.Logf("heap %d bytes, %d goroutines, %d writes and %d failed, latency p50 %v p90 %v p99 %v",
+			heap, goroutines, writes, failed, p[0], p[1], p[2])
+		if writes == 0 && failed == 0 {
+			test.PrintStackTrace(t)
+			t.Fatalf("no write answered since the last sample, the cluster stalled")
+		}
+	}
+
+	close(stop)
+	if !test.WaitThisOrTimeout(group.Wait, time.Minute) {
+		t.Fatalf("writers did not finish after stopping the load")
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	heap, goroutines := sample()
+	for goroutines > baseGoroutines && time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+		heap, goroutines = sample()
+	}
+	t.Logf("after the load, heap %d bytes and %d goroutines", heap, goroutines)
+	if goroutines > baseGoroutines {
+		t.Errorf("goroutines leaked, %d before the load and %d after", baseGoroutines, goroutines)
+	}
+}
@@ -3,11 +3,14 @@ module github.com/jabolina/go-mcast
 go 1.14
 
 require (
+	github.com/BurntSushi/toml v1.2.0
 	github.com/ReneKroon/ttlcache v1.6.0
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751 // indirect
 	github.com/alecthomas/units v0.0.0-20190924025748-f65c72e2690d // indirect
 	github.com/axw/gocov v1.0.0 // indirect
 	github.com/fatih/color v1.9.0 // indirect
+	github.com/fxamacker/cbor/v2 v2.4.0
+	github.com/gomodule/redigo v1.8.9
 	github.com/hashicorp/go-version v1.2.0 // indirect
 	github.com/jabolina/relt v0.0.9
 	github.com/matm/gocov-html v0.0.0-20200509184451-71874e2e203b // indirect
@@ -15,10 +18,12 @@ require (
 	github.com/mitchellh/gox v1.0.1 // indirect
 	github.com/prometheus/common v0.0.0-20181126121408-4724e9255275
 	github.com/sirupsen/logrus v1.6.0 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	github.com/wangjia184/sortedset v0.0.0-20200422044937-080872f546ba
 	go.uber.org/goleak v1.0.0
 	golang.org/x/lint v0.0.0-20200302205851-738671d3881b // indirect
 	golang.org/x/sys v0.0.0-20200523222454-059865788121 // indirect
 	golang.org/x/tools v0.0.0-20200717024301-6ddee64345a6 // indirect
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,74 @@
+// Command node runs the peers of a partition on its own process,
+// configured from the environment as a StatefulSet pod, and serves
+// the keys committed on the partition over HTTP:
+//
+//	/ready   the readiness probe;
+//	/keys/   reads and writes the keys on the partition;
+//	/admin   inspects and unsticks messages;
+//	/log     changes the log levels;
+//	/events  dumps the recorded protocol events.
+//
+// Used by the docker-compose environment of the integration tests.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jabolina/go-mcast/examples/app"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to serve the HTTP handlers")
+	levels := flag.String("log", "warn", "the log levels, e.g. info,transport=debug")
+	flag.Parse()
+
+	if err := run(*addr, *levels); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(addr, levels string) error {
+	configuration, err := mcast.KubernetesConfiguration()
+	if err != nil {
+		return err
+	}
+	if configuration.LogLevels, err = types.ParseLogLevels(levels); err != nil {
+		return err
+	}
+	configuration.Recorder = types.NewEventRecorder(32, 1024)
+
+	unity, err := mcast.NewMulticastConfigured(configuration)
+	if err != nil {
+		return err
+	}
+	defer unity.Shutdown()
+
+	mux := http.NewServeMux()
+	mux.Handle("/ready", mcast.ReadinessHandler(unity))
+	mux.Handle(app.KeysPath, app.NewKVServer(unity, string(configuration.Name)))
+	mux.Handle("/admin", mcast.AdminHandler(unity))
+	mux.Handle("/log", mcast.LogLevelsHandler(configuration.Logger))
+	mux.Handle("/events", mcast.EventsHandler(configuration.Recorder))
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	failed := make(chan error, 1)
+	go func() {
+		failed <- server.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-failed:
+		return err
+	case <-signals:
+		return server.Close()
+	}
+}
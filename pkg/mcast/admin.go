@@ -0,0 +1,123 @@
+package mcast
+
+import (
+	"encoding/json"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"net/http"
+)
+
+// Actions an operator can apply on a message.
+const (
+	// Discard the message, see Unity.Expire.
+	AdminExpire = "expire"
+
+	// Resend the message timestamp, see Unity.Resend.
+	AdminResend = "resend"
+)
+
+// The result of an admin action.
+type AdminResult struct {
+	// If any peer applied the action.
+	Applied bool `json:"applied"`
+
+	// The message state on the peers after the action.
+	Status []types.MessageStatus `json:"status"`
+}
+
+// Creates an HTTP handler to unstick messages without restarting
+// the unity. A GET returns the state of the message given by the uid
+// query parameter on every peer, and a POST applies the action given
+// by the action query parameter on the message.
+func AdminHandler(unity Unity) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid := types.UID(r.URL.Query().Get("uid"))
+		if len(uid) == 0 {
+			http.Error(w, "missing uid", http.StatusBadRequest)
+			return
+		}
+
+		var body interface{}
+		switch r.Method {
+		case http.MethodGet:
+			status := unity.Inspect(uid)
+			if len(status) == 0 {
+				http.Error(w, "message not pending", http.StatusNotFound)
+				return
+			}
+			body = status
+		case http.MethodPost:
+			var applied bool
+			switch action := r.URL.Query().Get("action"); action {
+			case AdminExpire:
+				applied = unity.Expire(uid)
+			case AdminResend:
+				applied = unity.Resend(uid)
+			default:
+				http.Error(w, "unknown action "+action, http.StatusBadRequest)
+				return
+			}
+			body = AdminResult{Applied: applied, Status: unity.Inspect(uid)}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	})
+}
+
+// Creates an HTTP handler dumping the received queue of every peer
+// as JSON, with the state and timestamp of each pending message. The
+// dump is read by replay.LoadQueues, so the ordering observed on the
+// peers can be investigated locally.
+func QueueHandler(unity Unity) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(unity.Dump())
+	})
+}
+
+// Creates an HTTP handler to change the log levels at runtime. A GET
+// returns the level of each subsystem, a POST sets the level given by
+// the level query parameter on the subsystem query parameter, where
+// an empty subsystem is the root level, and a DELETE removes the level
+// of the subsystem so it uses the level of its parent.
+func LogLevelsHandler(logger types.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leveled, ok := logger.(types.LevelLogger)
+		if !ok {
+			http.Error(w, "logger without levels", http.StatusNotImplemented)
+			return
+		}
+
+		subsystem := r.URL.Query().Get("subsystem")
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			level, err := types.ParseLogLevel(r.URL.Query().Get("level"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			leveled.SetLevel(subsystem, level)
+		case http.MethodDelete:
+			leveled.ResetLevel(subsystem)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		levels := make(map[string]string)
+		for name, level := range leveled.Levels() {
+			levels[name] = level.String()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(levels)
+	})
+}
@@ -3,6 +3,7 @@ package mcast
 import (
 	"github.com/jabolina/go-mcast/pkg/mcast/definition"
 	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"time"
 )
 
 // Creates a new multicast instance for the partition with the
@@ -27,6 +28,48 @@ func DefaultConfiguration(name types.Partition) *types.Configuration {
 		Conflict:    &definition.AlwaysConflict{},
 		Storage:     definition.NewInMemoryStorage(),
 		Logger:      definition.NewDefaultLogger(),
+		Topology:    types.StaticTopology{},
+		Codec:       definition.JSONCodec{},
+		Resolver:    definition.IdentityResolver{},
+		Retry: types.RetryPolicy{
+			Backoff: 2,
+		},
+		Breaker: types.BreakerPolicy{
+			Failures: 5,
+			Cooldown: time.Second,
+		},
+		ObserverTTL: 10 * time.Minute,
+		GCInterval:  time.Minute,
+	}
+}
+
+// Creates the default configuration for a client with the given
+// name. The client will wait up to 5 seconds for a reply.
+func DefaultClientConfiguration(name string) *types.ClientConfiguration {
+	return &types.ClientConfiguration{
+		Name:     types.Partition(name),
+		Version:  types.LatestProtocolVersion,
+		Timeout:  5 * time.Second,
+		Logger:   definition.NewDefaultLogger(),
+		Topology: types.StaticTopology{},
+		Codec:    definition.JSONCodec{},
+		Resolver: definition.IdentityResolver{},
+	}
+}
+
+// Creates the default configuration for a read replica with the
+// given name, receiving the log delivered by the given partition.
+// The replica subscribes again every second.
+func DefaultReplicaConfiguration(name string, partition types.Partition) *types.ReplicaConfiguration {
+	return &types.ReplicaConfiguration{
+		Name:      types.Partition(name),
+		Partition: partition,
+		Version:   types.LatestProtocolVersion,
+		Storage:   definition.NewInMemoryStorage(),
+		Refresh:   time.Second,
+		Logger:    definition.NewDefaultLogger(),
+		Codec:     definition.JSONCodec{},
+		Resolver:  definition.IdentityResolver{},
 	}
 }
 
@@ -63,3 +106,18 @@ func NewReadRequest(key []byte, destination []string) *types.Request {
 		Destination: partitions,
 	}
 }
+
+// Creates a request to read the history of the given key for one of
+// the given destinations. Only the entries with final timestamp on the
+// range are returned, a zero `to` means no upper bound. If `last` is
+// greater than zero, only the last entries are returned.
+func NewHistoryRequest(key []byte, from, to uint64, last int, destination []string) *types.Request {
+	request := NewReadRequest(key, destination)
+	request.History = &types.HistoryFilter{
+		Key:  key,
+		From: from,
+		To:   to,
+		Last: last,
+	}
+	return request
+}
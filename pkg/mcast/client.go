@@ -0,0 +1,291 @@
+package mcast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned when the client did not receive a reply
+	// for the issued request in time.
+	ErrClientTimeout = errors.New("timeout waiting for reply")
+
+	// Returned when the request has no destination.
+	ErrNoDestination = errors.New("request without destination")
+)
+
+// The client interface, used by applications that only
+// need to issue reads and writes.
+// Differently from the Unity, a client does not participate
+// on the protocol, it only connects to the partitions through
+// the transport, so there is no received queue nor clock.
+type Client interface {
+	// Apply a request to the protocol.
+	// The response is sent back through the channel once
+	// any peer on the destination commits the request, or
+	// fails with ErrClientTimeout if no reply arrives in time.
+	// With a write concern, the response also waits for the
+	// receipts of the destinations.
+	Write(request types.Request) <-chan types.Response
+
+	// Query a value from one of the destination partitions.
+	Read(request types.Request) (types.Response, error)
+
+	// Close the client.
+	Close()
+}
+
+// Concrete implementation of the Client interface.
+type TransportClient struct {
+	// Synchronize access to the waiting requests.
+	mutex *sync.Mutex
+
+	// The client configuration.
+	configuration *types.ClientConfiguration
+
+	// Transport used to send requests and receive replies.
+	transport core.Transport
+
+	// Requests waiting for a reply.
+	waiting map[types.UID]chan types.Response
+
+	// The receipts of the requests with a write concern.
+	receipts *core.ReceiptTracker
+
+	// Used to spawn and control go routines.
+	invoker core.Invoker
+
+	// The client cancellable context.
+	context context.Context
+
+	// Cancel function to finish the client.
+	finish context.CancelFunc
+}
+
+// Creates a new client using the given configuration.
+func NewClient(configuration *types.ClientConfiguration) (Client, error) {
+	pc := &types.PeerConfiguration{
+		Name:       string(configuration.Name),
+		Partition:  configuration.Name,
+		Version:    configuration.Version,
+		Codec:      configuration.Codec,
+		Codecs:     configuration.Codecs,
+		SigningKey: configuration.SigningKey,
+		Resolver:   configuration.Resolver,
+		Broker:     configuration.Broker,
+		Consumer:   configuration.Consumer,
+	}
+	types.ApplyLogLevels(configuration.Logger, configuration.LogLevels)
+	reliable, err := core.NewTransport(pc, core.NewRTTEstimator(), types.SubsystemLogger(configuration.Logger, types.SubsystemTransport))
+	if err != nil {
+		return nil, err
+	}
+	// Replies from stale peers are ignored, so the client
+	// is answered by a peer on the current epoch.
+	transport := core.NewEpochTransport(reliable, pc, types.SubsystemLogger(configuration.Logger, types.SubsystemEpoch))
+
+	ctx, done := context.WithCancel(context.Background())
+	c := &TransportClient{
+		mutex:         &sync.Mutex{},
+		configuration: configuration,
+		transport:     transport,
+		waiting:       make(map[types.UID]chan types.Response),
+		receipts:      core.NewReceiptTracker(),
+		invoker:       core.InvokerInstance(),
+		context:       ctx,
+		finish:        done,
+	}
+	c.invoker.Supervise(ctx, "client "+string(configuration.Name), c.poll)
+	return c, nil
+}
+
+// Implements the Client interface.
+func (c *TransportClient) Write(request types.Request) <-chan types.Response {
+	message := c.message(request, types.Initial)
+	message.Content.Operation = types.Command
+	message.Content.Content = request.Value
+	message.Content.Extensions = request.Extra
+	message.Content.Keys = request.Keys
+	if err := types.ValidateAcknowledgments(request); err != nil {
+		return failed(message.Identifier, err)
+	}
+	if err := types.ValidateRequest(request, c.configuration.Validators); err != nil {
+		return failed(message.Identifier, err)
+	}
+	if request.Acknowledgments > 0 {
+		message.Header.Flags |= types.FlagReceipt
+		c.receipts.Expect(message.Identifier, request.Acknowledgments)
+	}
+	if request.Urgent {
+		message.Header.Flags |= types.FlagUrgent
+	}
+	res := c.wait(message.Identifier)
+	c.invoker.Spawn(func() {
+		if err := c.transport.Broadcast(message); err != nil {
+			c.notify(message.Identifier, types.Response{
+				Success:    false,
+				Identifier: message.Identifier,
+				Failure:    err,
+			})
+		}
+	})
+
+	// The reply can be lost, so the request stops waiting after
+	// the timeout. Once answered, the timeout notification is ignored.
+	time.AfterFunc(c.configuration.Timeout, func() {
+		c.notify(message.Identifier, types.Response{
+			Success:    false,
+			Identifier: message.Identifier,
+			Failure:    ErrClientTimeout,
+		})
+	})
+	if request.Acknowledgments > 0 {
+		return c.receipts.Await(request.Context, message.Identifier, c.configuration.Timeout, res)
+	}
+	return res
+}
+
+// Implements the Client interface.
+// The read is sent to the closest destination partition.
+func (c *TransportClient) Read(request types.Request) (types.Response, error) {
+	if len(request.Destination) == 0 {
+		return types.Response{}, ErrNoDestination
+	}
+
+	message := c.message(request, types.Retrieve)
+	message.Content.Operation = types.Query
+	if request.History != nil {
+		filter, err := json.Marshal(request.History)
+		if err != nil {
+			return types.Response{}, err
+		}
+		message.Header.Flags |= types.FlagHistory
+		message.Content.Content = filter
+	}
+	destination := c.nearest(request.Destination)
+	res := c.wait(message.Identifier)
+	if err := c.transport.Unicast(message, destination); err != nil {
+		c.forget(message.Identifier)
+		return types.Response{Identifier: message.Identifier, Failure: err}, err
+	}
+
+	select {
+	case r := <-res:
+		return r, r.Failure
+	case <-time.After(c.configuration.Timeout):
+		c.forget(message.Identifier)
+		return types.Response{Identifier: message.Identifier, Failure: ErrClientTimeout}, ErrClientTimeout
+	}
+}
+
+// Implements the Client interface.
+func (c *TransportClient) Close() {
+	c.finish()
+	c.transport.Close()
+}
+
+// Choose the closest partition between the given ones, a
+// partition on the same zone is preferred.
+func (c *TransportClient) nearest(partitions []types.Partition) types.Partition {
+	if c.configuration.Topology == nil {
+		return partitions[0]
+	}
+	near, far := core.SplitByProximity(c.configuration.Location, c.configuration.Topology, partitions)
+	return append(near, far...)[0]
+}
+
+// Creates the message for the given request.
+func (c *TransportClient) message(request types.Request, t types.MessageType) types.Message {
+	return types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: c.configuration.Version,
+			Type:            t,
+			ReplyTo:         c.configuration.Name,
+			Consistency:     request.Consistency,
+		},
+		Identifier: types.UID(helper.GenerateUID()),
+		Content: types.DataHolder{
+			Key: request.Key,
+		},
+		State:       types.S0,
+		Timestamp:   0,
+		Destination: request.Destination,
+		From:        c.configuration.Name,
+	}
+}
+
+// Register a request that waits for a reply.
+func (c *TransportClient) wait(uid types.UID) chan types.Response {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	res := make(chan types.Response, 1)
+	c.waiting[uid] = res
+	return res
+}
+
+// Remove the request from the waiting list.
+func (c *TransportClient) forget(uid types.UID) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.waiting, uid)
+}
+
+// Notify the response to the waiting request. Only the first
+// response is notified, all following replies are ignored.
+func (c *TransportClient) notify(uid types.UID, res types.Response) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ch, ok := c.waiting[uid]
+	if !ok {
+		return
+	}
+	ch <- res
+	close(ch)
+	delete(c.waiting, uid)
+}
+
+// Keep polling for replies while the client is open.
+func (c *TransportClient) poll() {
+	for {
+		select {
+		case <-c.context.Done():
+			return
+		case m, ok := <-c.transport.Listen():
+			if !ok {
+				return
+			}
+
+			if m.Header.Type != types.Reply {
+				c.configuration.Logger.Warnf("client %s ignoring message %#v", c.configuration.Name, m)
+				continue
+			}
+			if m.Header.Flags.Has(types.FlagReceipt) {
+				c.receipts.Received(m.Identifier, m.From)
+				continue
+			}
+
+			res := types.Response{
+				Success:    len(m.Header.Failure) == 0,
+				Identifier: m.Identifier,
+				Data:       m.Content.Content,
+				Extra:      m.Content.Extensions,
+			}
+			if !res.Success {
+				res.Failure = errors.New(m.Header.Failure)
+			} else if m.Header.Flags.Has(types.FlagHistory) {
+				res.Data = nil
+				if err := json.Unmarshal(m.Content.Content, &res.History); err != nil {
+					res.Success = false
+					res.Failure = err
+				}
+			}
+			c.notify(m.Identifier, res)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package mcast
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Writes the request, as done by the Unity and the Client.
+type WriteFunc func(request types.Request) <-chan types.Response
+
+// Writes waiting for the coalescing window to finish.
+type coalesced struct {
+	// The last request written.
+	request types.Request
+
+	// All the writers waiting for the response.
+	waiting []chan types.Response
+}
+
+// Merges successive writes to the same key and destination into a
+// single protocol round. The first write opens a window, the writes
+// arriving while the window is open replace the previous ones and
+// only the last write is applied once the window finishes, every
+// merged write receives the response of the applied one.
+//
+// Since the merged values are never applied, they are not available
+// on the history. This is meant for hot keys where only the last value
+// matters, such as counters and configuration keys.
+type Coalescer struct {
+	// Synchronize access to the pending writes.
+	mutex *sync.Mutex
+
+	// How long the window stays open.
+	window time.Duration
+
+	// Applies the request.
+	write WriteFunc
+
+	// Writes waiting for the window to finish.
+	pending map[string]*coalesced
+
+	// How many writes were merged into another.
+	merged uint64
+
+	// Used to spawn the window go routines.
+	invoker core.Invoker
+}
+
+// Creates a new coalescer that applies the requests with the
+// given function after the window.
+func NewCoalescer(write WriteFunc, window time.Duration) *Coalescer {
+	return &Coalescer{
+		mutex:   &sync.Mutex{},
+		window:  window,
+		write:   write,
+		pending: make(map[string]*coalesced),
+		invoker: core.InvokerInstance(),
+	}
+}
+
+// Write the request, merging with the writes to the same key
+// and destination issued during the window. The requests waiting
+// for acknowledgments or urgent are applied right away, since the
+// merged writes would not honour them.
+func (c *Coalescer) Write(request types.Request) <-chan types.Response {
+	if request.Acknowledgments > 0 || request.Urgent {
+		return c.write(request)
+	}
+
+	res := make(chan types.Response, 1)
+	key := coalescingKey(request)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if pending, ok := c.pending[key]; ok {
+		pending.request = request
+		pending.waiting = append(pending.waiting, res)
+		atomic.AddUint64(&c.merged, 1)
+		return res
+	}
+
+	c.pending[key] = &coalesced{request: request, waiting: []chan types.Response{res}}
+	c.invoker.Spawn(func() {
+		<-time.After(c.window)
+		c.flush(key)
+	})
+	return res
+}
+
+// How many writes were merged into another.
+func (c *Coalescer) Merged() uint64 {
+	return atomic.LoadUint64(&c.merged)
+}
+
+// Apply the last request written for the key, notifying
+// all the writers waiting.
+func (c *Coalescer) flush(key string) {
+	c.mutex.Lock()
+	pending := c.pending[key]
+	delete(c.pending, key)
+	c.mutex.Unlock()
+
+	response, ok := <-c.write(pending.request)
+	for _, waiting := range pending.waiting {
+		if ok {
+			waiting <- response
+		}
+		close(waiting)
+	}
+}
+
+// Writes are merged only if they have the same key, other keys,
+// destination and consistency level.
+func coalescingKey(request types.Request) string {
+	var keys []string
+	for _, key := range request.Keys {
+		keys = append(keys, string(key))
+	}
+	sort.Strings(keys)
+	var destination []string
+	for _, partition := range request.Destination {
+		destination = append(destination, string(partition))
+	}
+	sort.Strings(destination)
+	parts := []string{string(request.Key), strconv.Itoa(int(request.Consistency)), strconv.Itoa(len(keys))}
+	parts = append(append(parts, keys...), destination...)
+	return strings.Join(parts, "\x00")
+}
+
+// A Unity that merges successive writes to the same key.
+type CoalescingUnity struct {
+	Unity
+
+	// Merges the writes.
+	coalescer *Coalescer
+}
+
+// Decorates the unity merging the writes to the same key
+// issued inside the window.
+func NewCoalescingUnity(unity Unity, window time.Duration) *CoalescingUnity {
+	return &CoalescingUnity{
+		Unity:     unity,
+		coalescer: NewCoalescer(unity.Write, window),
+	}
+}
+
+// Implements the Unity interface.
+func (c *CoalescingUnity) Write(request types.Request) <-chan types.Response {
+	return c.coalescer.Write(request)
+}
+
+// How many writes were merged into another.
+func (c *CoalescingUnity) Merged() uint64 {
+	return c.coalescer.Merged()
+}
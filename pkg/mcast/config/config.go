@@ -0,0 +1,397 @@
+// Loads the partition configuration from YAML or TOML files, so
+// the cluster is managed declaratively instead of in Go code.
+//
+// Every key of the file can be overridden through the environment,
+// the variable name is the key path prefixed by MCAST_, upper cased
+// and with the dots replaced by underscores, e.g., retry.attempts is
+// overridden by MCAST_RETRY_ATTEMPTS. Lists are comma separated and
+// mappings are comma separated `key=value` pairs, the topology is
+// only read from the file. The values not set keep the defaults of
+// mcast.DefaultConfiguration, reference.yaml and reference.toml list
+// every key with its default.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Prefix of the environment variables overriding the file.
+const EnvPrefix = "MCAST_"
+
+var (
+	// Returned when the configuration does not follow the schema.
+	ErrInvalidConfiguration = errors.New("invalid configuration")
+
+	// Returned when the file extension is not of a known format.
+	ErrUnknownFormat = errors.New("unknown configuration format")
+)
+
+// The format of the configuration file.
+type Format int
+
+const (
+	YAML Format = iota
+	TOML
+)
+
+// The codecs available by name.
+var codecs = map[string]types.IdentifiedCodec{
+	"json":    definition.JSONCodec{},
+	"gob":     definition.GobCodec{},
+	"msgpack": definition.MsgpackCodec{},
+	"cbor":    definition.CBORCodec{},
+}
+
+// Load the configuration from the file, with the format given by the
+// extension, overridden by the environment variables.
+func Load(path string) (*types.Configuration, error) {
+	return LoadFrom(path, os.Getenv)
+}
+
+// Load the configuration from the file, with the format given by the
+// extension, overridden using the given lookup function.
+func LoadFrom(path string, lookup func(string) string) (*types.Configuration, error) {
+	var format Format
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		format = YAML
+	case ".toml":
+		format = TOML
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data, format, lookup)
+}
+
+// Load the configuration of the peer with the given index on the
+// partition, from the file overridden by the environment variables.
+func LoadPeer(path string, index int) (*types.PeerConfiguration, error) {
+	configuration, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return mcast.NewPeerConfiguration(configuration, index, nil), nil
+}
+
+// Decode the configuration on the given format, overridden using the
+// given lookup function. A nil lookup function ignores the environment.
+// Every key that does not follow the schema is reported on the error.
+func Decode(data []byte, format Format, lookup func(string) string) (*types.Configuration, error) {
+	var tree map[string]interface{}
+	var err error
+	if format == TOML {
+		tree, err = parseTOML(data)
+	} else {
+		tree, err = parseYAML(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	if lookup != nil {
+		problems = override(tree, schema, "", lookup)
+	}
+	problems = append(problems, validate(tree, schema, "")...)
+	if _, ok := tree["name"]; !ok && !failed(problems, "name") {
+		problems = append(problems, "name: required")
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConfiguration, strings.Join(problems, "; "))
+	}
+
+	configuration, err := build(tree)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfiguration, err)
+	}
+	return configuration, nil
+}
+
+// Override the values of the tree with the environment variables.
+func override(tree map[string]interface{}, fields map[string]field, path string, lookup func(string) string) []string {
+	var problems []string
+	var keys []string
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f, name := fields[key], join(path, key)
+		switch f.kind {
+		case kindTables:
+			continue
+		case kindTable:
+			table, ok := tree[key].(map[string]interface{})
+			if !ok {
+				table = make(map[string]interface{})
+			}
+			problems = append(problems, override(table, f.fields, name, lookup)...)
+			if _, exists := tree[key]; ok || !exists && len(table) > 0 {
+				tree[key] = table
+			}
+			continue
+		}
+
+		variable := EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+		value := lookup(variable)
+		if len(value) == 0 {
+			continue
+		}
+		parsed, ok := parseEnvironment(value, f)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected %s, found %q", variable, f.kind, value))
+			continue
+		}
+		tree[key] = parsed
+	}
+	return problems
+}
+
+// Creates the configuration from the validated tree, starting from
+// the default configuration for the partition.
+func build(tree map[string]interface{}) (*types.Configuration, error) {
+	configuration := mcast.DefaultConfiguration(types.Partition(tree["name"].(string)))
+	if v, ok := tree["replication"]; ok {
+		configuration.Replication = v.(int)
+	}
+	if v, ok := tree["ordinal"]; ok {
+		configuration.Ordinal = v.(int)
+	}
+	if v, ok := tree["version"]; ok {
+		configuration.Version = uint(v.(int))
+	}
+	if v, ok := tree["conflict"]; ok && v == "key" {
+		configuration.Conflict = &definition.KeyConflict{}
+	}
+	if v, ok := tree["strictness"]; ok && v == "pending" {
+		configuration.Strictness = types.ConflictPending
+	}
+	if v, ok := tree["batch_size"]; ok {
+		configuration.BatchSize = v.(int)
+	}
+	if v, ok := tree["parallelism"]; ok {
+		configuration.Parallelism = v.(int)
+	}
+	if v, ok := tree["observer_ttl"]; ok {
+		configuration.ObserverTTL = v.(time.Duration)
+	}
+	if v, ok := tree["heartbeat"]; ok {
+		configuration.Heartbeat = v.(time.Duration)
+	}
+	if v, ok := tree["gc_interval"]; ok {
+		configuration.GCInterval = v.(time.Duration)
+	}
+	if v, ok := tree["credits"]; ok {
+		configuration.Credits = v.(int)
+	}
+	if v, ok := tree["clock_step"]; ok {
+		configuration.ClockStep = uint64(v.(int))
+	}
+	if v, ok := tree["log_levels"]; ok {
+		levels, err := types.ParseLogLevels(v.(string))
+		if err != nil {
+			return nil, fmt.Errorf("log_levels: %w", err)
+		}
+		configuration.LogLevels = levels
+	}
+	if v, ok := tree["features"]; ok {
+		features, err := types.ParseFeatures(v.(string))
+		if err != nil {
+			return nil, fmt.Errorf("features: %w", err)
+		}
+		configuration.Features = features
+	}
+	if v, ok := tree["codec"]; ok {
+		configuration.Codec = codecs[v.(string)]
+	}
+	if v, ok := tree["codecs"]; ok {
+		for _, name := range v.([]string) {
+			configuration.Codecs = append(configuration.Codecs, codecs[name])
+		}
+	}
+	if v, ok := tree["signing_key"]; ok && len(v.(string)) > 0 {
+		configuration.SigningKey = []byte(v.(string))
+	}
+	if v, ok := tree["addresses"]; ok {
+		resolver := make(definition.StaticResolver)
+		for partition, address := range v.(map[string]string) {
+			resolver[types.Partition(partition)] = types.Address(address)
+		}
+		configuration.Resolver = resolver
+	}
+	if v, ok := tree["location"]; ok {
+		configuration.Location = location(v.(map[string]interface{}))
+	}
+	if v, ok := tree["topology"]; ok {
+		topology := make(types.StaticTopology)
+		for partition, table := range v.(map[string]interface{}) {
+			topology[types.Partition(partition)] = location(table.(map[string]interface{}))
+		}
+		configuration.Topology = topology
+	}
+
+	if v, ok := tree["broker"]; ok {
+		broker := v.(map[string]interface{})
+		url, _ := broker["url"].(string)
+		switch {
+		case broker["type"] == "memory":
+			configuration.Broker = core.NewMemoryBroker()
+		case len(url) > 0:
+			configuration.Broker = core.ReltBroker{URL: url}
+		}
+	}
+	if v, ok := tree["durability"]; ok {
+		durability := v.(map[string]interface{})
+		switch durability["policy"] {
+		case "none":
+			configuration.Durability.Policy = types.NoSync
+		case "batched":
+			configuration.Durability.Policy = types.SyncBatched
+			if interval, _ := durability["interval"].(time.Duration); interval <= 0 {
+				return nil, errors.New("durability.interval: required by the batched policy")
+			}
+		}
+		if interval, ok := durability["interval"]; ok {
+			configuration.Durability.Interval = interval.(time.Duration)
+		}
+	}
+	if v, ok := tree["storage"]; ok {
+		storage := v.(map[string]interface{})
+		if storage["type"] == "wal" {
+			path, ok := storage["path"].(string)
+			if !ok || len(path) == 0 {
+				return nil, errors.New("storage.path: required by the wal storage")
+			}
+			wal, err := definition.NewWALStorage(path, definition.NewInMemoryStorage())
+			if err != nil {
+				return nil, err
+			}
+			configuration.Storage = wal
+		}
+	}
+	if v, ok := tree["dedup"]; ok {
+		dedup := v.(map[string]interface{})
+		if capacity, ok := dedup["capacity"]; ok {
+			configuration.Dedup.Capacity = capacity.(int)
+		}
+		if rate, ok := dedup["false_positive"]; ok {
+			configuration.Dedup.FalsePositive = rate.(float64)
+		}
+		if rotation, ok := dedup["rotation"]; ok {
+			configuration.Dedup.Rotation = rotation.(time.Duration)
+		}
+		if persist, ok := dedup["persist"]; ok {
+			configuration.Dedup.Persist = persist.(time.Duration)
+		}
+	}
+	if v, ok := tree["consumer"]; ok {
+		consumer := v.(map[string]interface{})
+		switch consumer["strategy"] {
+		case "spill":
+			configuration.Consumer.Strategy = types.ConsumerSpill
+		case "crash":
+			configuration.Consumer.Strategy = types.ConsumerCrash
+		}
+		if directory, ok := consumer["directory"]; ok {
+			configuration.Consumer.Directory = directory.(string)
+		}
+	}
+	if v, ok := tree["retry"]; ok {
+		retry := v.(map[string]interface{})
+		if attempts, ok := retry["attempts"]; ok {
+			configuration.Retry.Attempts = attempts.(int)
+		}
+		if backoff, ok := retry["backoff"]; ok {
+			configuration.Retry.Backoff = backoff.(float64)
+		}
+	}
+	if v, ok := tree["membership"]; ok {
+		membership := v.(map[string]interface{})
+		if members, ok := membership["members"]; ok && len(members.([]string)) > 0 {
+			var known types.StaticMembers
+			for _, member := range members.([]string) {
+				known = append(known, types.Partition(member))
+			}
+			configuration.Membership.Members = known
+		}
+		if membership["check"] == "receive" {
+			configuration.Membership.Check = types.CheckOnReceive
+		}
+	}
+	if v, ok := tree["breaker"]; ok {
+		breaker := v.(map[string]interface{})
+		if failures, ok := breaker["failures"]; ok {
+			configuration.Breaker.Failures = failures.(int)
+		}
+		if cooldown, ok := breaker["cooldown"]; ok {
+			configuration.Breaker.Cooldown = cooldown.(time.Duration)
+		}
+	}
+	if v, ok := tree["fanout"]; ok {
+		fanout := v.(map[string]interface{})
+		if workers, ok := fanout["workers"]; ok {
+			configuration.Fanout.Workers = workers.(int)
+		}
+		if fanout["partial"] == "all" {
+			configuration.Fanout.Partial = types.FailOnAll
+		}
+	}
+	if v, ok := tree["tree"]; ok {
+		exchange := v.(map[string]interface{})
+		if threshold, ok := exchange["threshold"]; ok {
+			configuration.Tree.Threshold = threshold.(int)
+		}
+		if fanout, ok := exchange["fanout"]; ok {
+			configuration.Tree.Fanout = fanout.(int)
+		}
+	}
+	return configuration, nil
+}
+
+// The location on the table.
+func location(table map[string]interface{}) types.Location {
+	var l types.Location
+	if datacenter, ok := table["datacenter"]; ok {
+		l.Datacenter = datacenter.(string)
+	}
+	if zone, ok := table["zone"]; ok {
+		l.Zone = types.Zone(zone.(string))
+	}
+	return l
+}
+
+// The names of the codecs, sorted.
+func codecNames() []string {
+	var names []string
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Verify if a problem was already reported for the key.
+func failed(problems []string, key string) bool {
+	for _, problem := range problems {
+		if strings.HasPrefix(problem, key+":") {
+			return true
+		}
+	}
+	return false
+}
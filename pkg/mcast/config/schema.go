@@ -0,0 +1,444 @@
+package config
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The kind of value a configuration key holds.
+type kind int
+
+const (
+	kindString kind = iota
+	kindInt
+	kindFloat
+	kindDuration
+
+	// A list of strings, comma separated on the environment.
+	kindList
+
+	// A mapping of strings, comma separated `key=value`
+	// pairs on the environment.
+	kindMap
+
+	// A nested table with its own keys.
+	kindTable
+
+	// A mapping where each value is a nested table, not
+	// available on the environment.
+	kindTables
+)
+
+// Describes a configuration key.
+type field struct {
+	// The kind of value.
+	kind kind
+
+	// The values accepted, any value when empty.
+	values []string
+
+	// The keys of a table, or of each table on the mapping.
+	fields map[string]field
+
+	// Verify the value after converted, returning why it is invalid.
+	check func(interface{}) string
+}
+
+// The keys accepted on the configuration file.
+var schema = map[string]field{
+	"name":         {kind: kindString},
+	"replication":  {kind: kindInt, check: atLeast(1)},
+	"ordinal":      {kind: kindInt, check: atLeast(0)},
+	"version":      {kind: kindInt, check: atLeast(0)},
+	"conflict":     {kind: kindString, values: []string{"always", "key"}},
+	"strictness":   {kind: kindString, values: []string{"previous", "pending"}},
+	"batch_size":   {kind: kindInt, check: atLeast(0)},
+	"parallelism":  {kind: kindInt, check: atLeast(0)},
+	"observer_ttl": {kind: kindDuration, check: notNegative},
+	"heartbeat":    {kind: kindDuration, check: notNegative},
+	"gc_interval":  {kind: kindDuration, check: notNegative},
+	"credits":      {kind: kindInt, check: atLeast(0)},
+	"clock_step":   {kind: kindInt, check: atLeast(0)},
+	"log_levels":   {kind: kindString, check: logLevels},
+	"features":     {kind: kindString, check: features},
+	"codec":        {kind: kindString, values: codecNames()},
+	"codecs":       {kind: kindList, check: knownCodecs},
+	"signing_key":  {kind: kindString},
+	"addresses":    {kind: kindMap},
+	"location": {kind: kindTable, fields: map[string]field{
+		"datacenter": {kind: kindString},
+		"zone":       {kind: kindString},
+	}},
+	"topology": {kind: kindTables, fields: map[string]field{
+		"datacenter": {kind: kindString},
+		"zone":       {kind: kindString},
+	}},
+	"broker": {kind: kindTable, fields: map[string]field{
+		"type": {kind: kindString, values: []string{"rabbitmq", "memory"}},
+		"url":  {kind: kindString},
+	}},
+	"storage": {kind: kindTable, fields: map[string]field{
+		"type": {kind: kindString, values: []string{"memory", "wal"}},
+		"path": {kind: kindString},
+	}},
+	"durability": {kind: kindTable, fields: map[string]field{
+		"policy":   {kind: kindString, values: []string{"commit", "none", "batched"}},
+		"interval": {kind: kindDuration, check: notNegative},
+	}},
+	"dedup": {kind: kindTable, fields: map[string]field{
+		"capacity":       {kind: kindInt, check: atLeast(0)},
+		"false_positive": {kind: kindFloat, check: probability},
+		"rotation":       {kind: kindDuration, check: positive},
+		"persist":        {kind: kindDuration, check: positive},
+	}},
+	"consumer": {kind: kindTable, fields: map[string]field{
+		"strategy":  {kind: kindString, values: []string{"block", "spill", "crash"}},
+		"directory": {kind: kindString},
+	}},
+	"retry": {kind: kindTable, fields: map[string]field{
+		"attempts": {kind: kindInt, check: atLeast(0)},
+		"backoff":  {kind: kindFloat, check: atLeastFloat(0)},
+	}},
+	"breaker": {kind: kindTable, fields: map[string]field{
+		"failures": {kind: kindInt, check: atLeast(0)},
+		"cooldown": {kind: kindDuration, check: notNegative},
+	}},
+	"fanout": {kind: kindTable, fields: map[string]field{
+		"workers": {kind: kindInt, check: atLeast(0)},
+		"partial": {kind: kindString, values: []string{"any", "all"}},
+	}},
+	"tree": {kind: kindTable, fields: map[string]field{
+		"threshold": {kind: kindInt, check: atLeast(0)},
+		"fanout":    {kind: kindInt, check: atLeast(0)},
+	}},
+	"membership": {kind: kindTable, fields: map[string]field{
+		"members": {kind: kindList},
+		"check":   {kind: kindString, values: []string{"send", "receive"}},
+	}},
+}
+
+// Verify the tree against the fields, converting every value to the
+// kind of its key. Returns a problem for each invalid key.
+func validate(tree map[string]interface{}, fields map[string]field, path string) []string {
+	var problems []string
+	for _, key := range sortedKeys(tree) {
+		name := join(path, key)
+		f, ok := fields[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown key", name))
+			continue
+		}
+
+		value, problem := convert(tree[key], f, name)
+		if len(problem) > 0 {
+			problems = append(problems, problem)
+			continue
+		}
+		tree[key] = value
+	}
+	return problems
+}
+
+// Convert the value to the kind of the field.
+func convert(value interface{}, f field, name string) (interface{}, string) {
+	if value == nil {
+		return nil, fmt.Sprintf("%s: missing value", name)
+	}
+
+	var converted interface{}
+	var ok bool
+	switch f.kind {
+	case kindString:
+		converted, ok = scalar(value)
+	case kindInt:
+		var v int64
+		v, ok = value.(int64)
+		converted = int(v)
+	case kindFloat:
+		switch v := value.(type) {
+		case float64:
+			converted, ok = v, true
+		case int64:
+			converted, ok = float64(v), true
+		}
+	case kindDuration:
+		if text, isText := value.(string); isText {
+			var err error
+			converted, err = time.ParseDuration(text)
+			ok = err == nil
+		}
+	case kindList:
+		converted, ok = list(value)
+	case kindMap:
+		converted, ok = mapping(value)
+	case kindTable:
+		table, isTable := value.(map[string]interface{})
+		if !isTable {
+			break
+		}
+		if problems := validate(table, f.fields, name); len(problems) > 0 {
+			return nil, strings.Join(problems, "; ")
+		}
+		converted, ok = table, true
+	case kindTables:
+		tables, isTable := value.(map[string]interface{})
+		if !isTable {
+			break
+		}
+		var problems []string
+		for _, key := range sortedKeys(tables) {
+			problem := ""
+			tables[key], problem = convert(tables[key], field{kind: kindTable, fields: f.fields}, join(name, key))
+			if len(problem) > 0 {
+				problems = append(problems, problem)
+			}
+		}
+		if len(problems) > 0 {
+			return nil, strings.Join(problems, "; ")
+		}
+		converted, ok = tables, true
+	}
+
+	if !ok {
+		return nil, fmt.Sprintf("%s: expected %s, found %v", name, f.kind, value)
+	}
+	if len(f.values) > 0 && !contains(f.values, converted.(string)) {
+		return nil, fmt.Sprintf("%s: expected one of %s, found %q", name, strings.Join(f.values, ", "), converted)
+	}
+	if f.check != nil {
+		if problem := f.check(converted); len(problem) > 0 {
+			return nil, fmt.Sprintf("%s: %s", name, problem)
+		}
+	}
+	return converted, ""
+}
+
+// Parse the environment value with the kind of the field, as
+// it was read from the configuration file.
+func parseEnvironment(value string, f field) (interface{}, bool) {
+	switch f.kind {
+	case kindString, kindDuration:
+		return value, true
+	case kindInt:
+		v, err := strconv.ParseInt(value, 10, 64)
+		return v, err == nil
+	case kindFloat:
+		v, err := strconv.ParseFloat(value, 64)
+		return v, err == nil
+	case kindList:
+		var items []interface{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				items = append(items, item)
+			}
+		}
+		return items, true
+	case kindMap:
+		pairs := make(map[string]interface{})
+		for _, pair := range strings.Split(value, ",") {
+			if len(strings.TrimSpace(pair)) == 0 {
+				continue
+			}
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+				return nil, false
+			}
+			pairs[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+		return pairs, true
+	}
+	return nil, false
+}
+
+func (k kind) String() string {
+	switch k {
+	case kindString:
+		return "a string"
+	case kindInt:
+		return "an integer"
+	case kindFloat:
+		return "a number"
+	case kindDuration:
+		return "a duration"
+	case kindList:
+		return "a list"
+	case kindMap:
+		return "a mapping"
+	default:
+		return "a table"
+	}
+}
+
+// Convert the values decoded from the file to the types the schema
+// expects: integers as int64, tables as string keyed mappings and
+// arrays, including arrays of tables, as lists.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		table := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			table[fmt.Sprint(key)] = normalize(item)
+		}
+		return table
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = normalize(item)
+		}
+		return items
+	case int:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	}
+	return value
+}
+
+// A string scalar, numbers and booleans are formatted, since
+// YAML does not quote the strings that look like numbers.
+func scalar(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+func list(value interface{}) ([]string, bool) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	converted := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := scalar(item)
+		if !ok {
+			return nil, false
+		}
+		converted = append(converted, s)
+	}
+	return converted, true
+}
+
+func mapping(value interface{}) (map[string]string, bool) {
+	pairs, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	converted := make(map[string]string, len(pairs))
+	for key, pair := range pairs {
+		s, ok := scalar(pair)
+		if !ok {
+			return nil, false
+		}
+		converted[key] = s
+	}
+	return converted, true
+}
+
+func atLeast(min int) func(interface{}) string {
+	return func(value interface{}) string {
+		if value.(int) < min {
+			return fmt.Sprintf("must be at least %d", min)
+		}
+		return ""
+	}
+}
+
+func atLeastFloat(min float64) func(interface{}) string {
+	return func(value interface{}) string {
+		if value.(float64) < min {
+			return fmt.Sprintf("must be at least %g", min)
+		}
+		return ""
+	}
+}
+
+func notNegative(value interface{}) string {
+	if value.(time.Duration) < 0 {
+		return "must not be negative"
+	}
+	return ""
+}
+
+func positive(value interface{}) string {
+	if value.(time.Duration) <= 0 {
+		return "must be positive"
+	}
+	return ""
+}
+
+func probability(value interface{}) string {
+	if v := value.(float64); v <= 0 || v >= 1 {
+		return "must be between 0 and 1"
+	}
+	return ""
+}
+
+func knownCodecs(value interface{}) string {
+	for _, name := range value.([]string) {
+		if _, ok := codecs[name]; !ok {
+			return fmt.Sprintf("unknown codec %q", name)
+		}
+	}
+	return ""
+}
+
+func logLevels(value interface{}) string {
+	if _, err := types.ParseLogLevels(value.(string)); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func features(value interface{}) string {
+	if _, err := types.ParseFeatures(value.(string)); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(tree map[string]interface{}) []string {
+	keys := make([]string, 0, len(tree))
+	for key := range tree {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func join(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
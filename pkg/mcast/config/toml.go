@@ -0,0 +1,21 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+)
+
+var (
+	// Returned when the TOML document is malformed.
+	ErrTOMLInvalid = errors.New("invalid toml")
+)
+
+// Parse the TOML document.
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+	if _, err := toml.Decode(string(data), &tree); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTOMLInvalid, err)
+	}
+	return normalize(tree).(map[string]interface{}), nil
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// Returned when the YAML document is malformed.
+	ErrYAMLInvalid = errors.New("invalid yaml")
+)
+
+// Parse the YAML document, which must be a mapping. An empty
+// document is an empty mapping.
+func parseYAML(data []byte) (map[string]interface{}, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrYAMLInvalid, err)
+	}
+	if document == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	tree, ok := normalize(document).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: the document must be a mapping", ErrYAMLInvalid)
+	}
+	return tree, nil
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+)
+
+var (
+	// Returned to the request when an operator expired the message.
+	ErrMessageExpired = errors.New("message expired by operator")
+)
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Inspect(uid types.UID) (types.MessageStatus, bool) {
+	for position, message := range p.rqueue.Pending() {
+		if message.Identifier != uid {
+			continue
+		}
+
+		status := types.MessageStatus{
+			Peer:        p.configuration.Name,
+			Identifier:  uid,
+			State:       message.State,
+			Timestamp:   message.Timestamp,
+			Destination: message.Destination,
+			Position:    position,
+		}
+		if message.State == types.S1 {
+			status.Missing = p.received.Missing(uid, p.retirement.Participants(message.Destination))
+		}
+		return status, true
+	}
+	return types.MessageStatus{}, false
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Dump() types.QueueDump {
+	return types.QueueDump{
+		Peer:      p.configuration.Name,
+		Partition: p.configuration.Partition,
+		Messages:  p.rqueue.Pending(),
+	}
+}
+
+// Implements the PartitionPeer interface.
+// The message is removed from the received queue and never delivered
+// by this peer, the request fails with ErrMessageExpired.
+func (p *Peer) Expire(uid types.UID) bool {
+	value := p.rqueue.GetIfExists(string(uid))
+	if value == nil {
+		return false
+	}
+
+	message := value.(types.Message)
+	p.rqueue.Dequeue(message)
+	p.received.Remove(uid)
+	p.tree.Forget(uid)
+	p.timeouts.Forget(uid)
+	if p.transitions != nil {
+		p.transitions.Forget(uid)
+	}
+
+	p.log.Warnf("message %s expired by operator on state %d", uid, message.State)
+	p.report(types.DroppedMessage, uid, ErrMessageExpired)
+	res := types.Response{Identifier: uid, Failure: ErrMessageExpired}
+	p.notify(uid, res)
+	if len(message.Header.ReplyTo) > 0 {
+		p.reply(message, res)
+	}
+	return true
+}
+
+// Implements the PartitionPeer interface.
+// Only messages still exchanging the timestamp are sent again,
+// to the partitions that did not answer yet.
+func (p *Peer) Resend(uid types.UID) bool {
+	value := p.rqueue.GetIfExists(string(uid))
+	if value == nil {
+		return false
+	}
+
+	message := value.(types.Message)
+	if message.State != types.S1 {
+		return false
+	}
+
+	missing := p.received.Missing(uid, p.retirement.Participants(message.Destination))
+	p.log.Infof("resending %s timestamp to %v by operator", uid, missing)
+	p.resend(message, missing)
+	return len(missing) > 0
+}
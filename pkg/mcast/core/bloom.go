@@ -0,0 +1,226 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// A bloom filter over strings, using double hashing.
+type BloomFilter struct {
+	// The filter bits.
+	Bits []uint64
+
+	// How many hashes are computed for each value.
+	Hashes uint64
+}
+
+// Creates a new filter sized for the capacity and the expected
+// false positive rate.
+func NewBloomFilter(capacity int, falsePositive float64) *BloomFilter {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if falsePositive <= 0 || falsePositive >= 1 {
+		falsePositive = types.DefaultDedupFalsePositive
+	}
+
+	bits := math.Ceil(-float64(capacity) * math.Log(falsePositive) / (math.Ln2 * math.Ln2))
+	hashes := math.Max(1, math.Round(bits/float64(capacity)*math.Ln2))
+	return &BloomFilter{
+		Bits:   make([]uint64, uint64(bits)/64+1),
+		Hashes: uint64(hashes),
+	}
+}
+
+// Add the value to the filter.
+func (b *BloomFilter) Add(value string) {
+	for _, position := range b.positions(value) {
+		b.Bits[position/64] |= 1 << (position % 64)
+	}
+}
+
+// Verify if the value was possibly added to the filter.
+func (b *BloomFilter) Contains(value string) bool {
+	for _, position := range b.positions(value) {
+		if b.Bits[position/64]&(1<<(position%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *BloomFilter) positions(value string) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	first := h.Sum64()
+	second := first>>33 | first<<31 | 1
+	size := uint64(len(b.Bits)) * 64
+	positions := make([]uint64, b.Hashes)
+	for i := range positions {
+		positions[i] = (first + uint64(i)*second) % size
+	}
+	return positions
+}
+
+// The persisted state of the rotating bloom filter.
+type persistedBloom struct {
+	Current  *BloomFilter
+	Previous *BloomFilter
+	Rotated  time.Time
+}
+
+// Implements the Cache interface using two bloom filters, the values
+// are added to the current one and both are verified. After each
+// rotation the previous filter is discarded and the current one takes
+// its place, so the memory is bounded while a value is remembered for
+// at least one rotation.
+//
+// The filters are periodically persisted on the storage, and loaded
+// when created, so the values are remembered across restarts.
+type RotatingBloom struct {
+	// Synchronize access to the filters.
+	mutex *sync.Mutex
+
+	// The window configuration.
+	window types.DedupWindow
+
+	// The filter receiving the values.
+	current *BloomFilter
+
+	// The filter before the last rotation.
+	previous *BloomFilter
+
+	// When the filters were rotated.
+	rotated time.Time
+
+	// Stable storage where the filters are persisted.
+	storage types.Storage
+
+	// Key used to store the filters, each peer has its own key.
+	key []byte
+
+	// Bloom logger.
+	log types.Logger
+}
+
+// Creates a new rotating bloom filter for the peer, loading the
+// filters persisted on the storage, if any.
+func NewRotatingBloom(ctx context.Context, window types.DedupWindow, storage types.Storage, peer string, log types.Logger) *RotatingBloom {
+	if window.Rotation <= 0 {
+		window.Rotation = types.DefaultDedupRotation
+	}
+	if window.Persist <= 0 {
+		window.Persist = types.DefaultDedupPersist
+	}
+
+	b := &RotatingBloom{
+		mutex:    &sync.Mutex{},
+		window:   window,
+		current:  NewBloomFilter(window.Capacity, window.FalsePositive),
+		previous: NewBloomFilter(window.Capacity, window.FalsePositive),
+		rotated:  time.Now(),
+		storage:  storage,
+		key:      []byte(fmt.Sprintf("dedup-%s", peer)),
+		log:      log,
+	}
+	b.load()
+	InvokerInstance().Spawn(func() {
+		b.poll(ctx)
+	})
+	return b
+}
+
+// Implements the Cache interface.
+func (b *RotatingBloom) Set(id string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.current.Add(id)
+}
+
+// Implements the Cache interface.
+func (b *RotatingBloom) Contains(id string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.current.Contains(id) || b.previous.Contains(id)
+}
+
+// Rotate and persist the filters while the context is open, the
+// filters are persisted a last time when the context is done.
+func (b *RotatingBloom) poll(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			b.Persist()
+			return
+		case <-time.After(b.window.Persist):
+			b.mutex.Lock()
+			if time.Since(b.rotated) >= b.window.Rotation {
+				b.rotate()
+			}
+			b.mutex.Unlock()
+			b.Persist()
+		}
+	}
+}
+
+// Discard the previous filter.
+// This method should be called while holding the mutex.
+func (b *RotatingBloom) rotate() {
+	b.previous = b.current
+	b.current = NewBloomFilter(b.window.Capacity, b.window.FalsePositive)
+	b.rotated = time.Now()
+}
+
+// Write the filters on the storage.
+func (b *RotatingBloom) Persist() {
+	b.mutex.Lock()
+	data, err := json.Marshal(persistedBloom{Current: b.current, Previous: b.previous, Rotated: b.rotated})
+	b.mutex.Unlock()
+	if err == nil {
+		err = b.storage.Set(b.key, data)
+	}
+	if err != nil {
+		b.log.Errorf("failed persisting dedup window %s. %v", string(b.key), err)
+	}
+}
+
+// Read the filters from the storage. If the filters persisted were
+// sized for another configuration they are discarded, and when the
+// rotation expired while stopped the filters are rotated.
+func (b *RotatingBloom) load() {
+	data, err := b.storage.Get(b.key)
+	if err != nil || data == nil {
+		return
+	}
+
+	var persisted persistedBloom
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		b.log.Errorf("failed reading dedup window %s. %v", string(b.key), err)
+		return
+	}
+
+	if !b.compatible(persisted.Current) || !b.compatible(persisted.Previous) {
+		b.log.Warnf("discarding dedup window %s with other configuration", string(b.key))
+		return
+	}
+
+	elapsed := time.Since(persisted.Rotated)
+	if elapsed >= 2*b.window.Rotation {
+		return
+	}
+
+	b.current, b.previous, b.rotated = persisted.Current, persisted.Previous, persisted.Rotated
+	if elapsed >= b.window.Rotation {
+		b.rotate()
+	}
+}
+
+func (b *RotatingBloom) compatible(filter *BloomFilter) bool {
+	return filter != nil && len(filter.Bits) == len(b.current.Bits) && filter.Hashes == b.current.Hashes
+}
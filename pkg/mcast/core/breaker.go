@@ -0,0 +1,229 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned when sending to a partition with the circuit open.
+	ErrPartitionUnavailable = errors.New("partition unavailable")
+)
+
+// The circuit of a single partition.
+type circuit struct {
+	// Consecutive failures sending to the partition.
+	failures int
+
+	// When the circuit opened.
+	opened time.Time
+
+	// If a probe is being sent while half-open.
+	probing bool
+
+	// The circuit counters and state.
+	metrics types.BreakerMetrics
+}
+
+// A transport decorator with a circuit breaker for each destination
+// partition. After consecutive failures sending to a partition its
+// circuit opens and the messages to it fail fast, instead of each
+// sender waiting on a route that is down. Once the cooldown passes
+// the circuit is half-open, a single message is sent as a probe and
+// the circuit closes if it succeeds, or opens again otherwise.
+//
+// Each state change is logged and handed to the listener, if any.
+type BreakerTransport struct {
+	// Synchronize access to the circuits.
+	mutex *sync.Mutex
+
+	// The underlying transport.
+	Transport
+
+	// The name of the peer using the transport.
+	name string
+
+	// When the circuits open.
+	policy types.BreakerPolicy
+
+	// How a broadcast fans out to the destinations.
+	fanout types.Fanout
+
+	// The circuit of each partition.
+	circuits map[types.Partition]*circuit
+
+	// Called on each state change.
+	listener types.BreakerListener
+
+	// Transport logger.
+	log types.Logger
+}
+
+// Creates a new breaker decorating the given transport.
+func NewBreakerTransport(transport Transport, peer *types.PeerConfiguration, log types.Logger) *BreakerTransport {
+	return &BreakerTransport{
+		mutex:     &sync.Mutex{},
+		Transport: transport,
+		name:      peer.Name,
+		policy:    peer.Breaker,
+		fanout:    peer.Fanout,
+		circuits:  make(map[types.Partition]*circuit),
+		listener:  peer.OnBreaker,
+		log:       log,
+	}
+}
+
+// Implements the Transport interface.
+// Fails fast if the circuit of any destination is open, otherwise
+// the message is sent to each destination apart, so the outcome is
+// recorded only on the circuit of the partition it belongs. The
+// failures are returned after trying every destination.
+func (b *BreakerTransport) Broadcast(message types.Message) error {
+	for i, partition := range message.Destination {
+		if err := b.allow(partition); err != nil {
+			for _, allowed := range message.Destination[:i] {
+				b.release(allowed)
+			}
+			return err
+		}
+	}
+
+	return FanOut(b.fanout, message.Destination, func(partition types.Partition) error {
+		err := b.Transport.Unicast(message, partition)
+		b.record(partition, err)
+		return err
+	})
+}
+
+// Implements the Transport interface.
+func (b *BreakerTransport) Unicast(message types.Message, partition types.Partition) error {
+	if err := b.allow(partition); err != nil {
+		return err
+	}
+
+	err := b.Transport.Unicast(message, partition)
+	b.record(partition, err)
+	return err
+}
+
+// Implements the FlowControl interface.
+func (b *BreakerTransport) Grant(credits int) {
+	grant(b.Transport, credits)
+}
+
+// The counters of the circuit of each partition.
+func (b *BreakerTransport) Breakers() map[types.Partition]types.BreakerMetrics {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	metrics := make(map[types.Partition]types.BreakerMetrics)
+	for partition, c := range b.circuits {
+		metrics[partition] = c.metrics
+	}
+	return metrics
+}
+
+// Verify if a message can be sent to the partition. An open circuit
+// past the cooldown moves to half-open and the message is the probe.
+func (b *BreakerTransport) allow(partition types.Partition) error {
+	b.mutex.Lock()
+	c := b.circuit(partition)
+	var events []types.BreakerEvent
+	var err error
+	switch c.metrics.State {
+	case types.BreakerOpen:
+		if time.Since(c.opened) < b.policy.Cooldown {
+			err = fmt.Errorf("%w: %s", ErrPartitionUnavailable, partition)
+			break
+		}
+		events = append(events, b.transition(partition, c, types.BreakerHalfOpen))
+		fallthrough
+	case types.BreakerHalfOpen:
+		if c.probing {
+			err = fmt.Errorf("%w: %s probing", ErrPartitionUnavailable, partition)
+			break
+		}
+		c.probing = true
+		c.metrics.Probes++
+	}
+	if err != nil {
+		c.metrics.Rejected++
+	}
+	b.mutex.Unlock()
+
+	b.emit(events)
+	return err
+}
+
+// Record the outcome of a message sent to the partition.
+func (b *BreakerTransport) record(partition types.Partition, err error) {
+	b.mutex.Lock()
+	c := b.circuit(partition)
+	c.probing = false
+	var events []types.BreakerEvent
+	if err == nil {
+		c.failures = 0
+		if c.metrics.State != types.BreakerClosed {
+			events = append(events, b.transition(partition, c, types.BreakerClosed))
+		}
+	} else {
+		c.failures++
+		if c.metrics.State == types.BreakerHalfOpen ||
+			(c.metrics.State == types.BreakerClosed && c.failures >= b.policy.Failures) {
+			events = append(events, b.transition(partition, c, types.BreakerOpen))
+		}
+	}
+	b.mutex.Unlock()
+
+	b.emit(events)
+}
+
+// Release the probe allowed to the partition without sending it.
+func (b *BreakerTransport) release(partition types.Partition) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.circuit(partition).probing = false
+}
+
+// The circuit of the partition, created closed.
+// This method should be called while holding the mutex.
+func (b *BreakerTransport) circuit(partition types.Partition) *circuit {
+	c, ok := b.circuits[partition]
+	if !ok {
+		c = &circuit{}
+		b.circuits[partition] = c
+	}
+	return c
+}
+
+// Move the circuit to the state.
+// This method should be called while holding the mutex.
+func (b *BreakerTransport) transition(partition types.Partition, c *circuit, state types.BreakerState) types.BreakerEvent {
+	event := types.BreakerEvent{
+		Peer:      b.name,
+		Partition: partition,
+		From:      c.metrics.State,
+		To:        state,
+		Time:      time.Now(),
+	}
+	c.metrics.State = state
+	if state == types.BreakerOpen {
+		c.opened = event.Time
+		c.metrics.Opened++
+	}
+	return event
+}
+
+// Log and hand the state changes to the listener.
+func (b *BreakerTransport) emit(events []types.BreakerEvent) {
+	for _, event := range events {
+		b.log.Warnf("circuit to %s moved from %s to %s", event.Partition, event.From, event.To)
+		if b.listener != nil {
+			Protect("breaker listener", func() {
+				b.listener(event)
+			})
+		}
+	}
+}
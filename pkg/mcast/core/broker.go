@@ -0,0 +1,256 @@
+package core
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"github.com/jabolina/relt/pkg/relt"
+	"sync"
+)
+
+var (
+	// Returned when publishing on a closed connection.
+	ErrBrokerClosed = errors.New("broker connection closed")
+)
+
+// A broker connecting through RabbitMQ using relt.
+// This is the default broker.
+type ReltBroker struct {
+	// The connection URL, if empty connects to a local
+	// broker using the default credentials.
+	URL string
+}
+
+// Implements the Broker interface.
+func (b ReltBroker) Connect(name string, address types.Address) (types.BrokerConnection, error) {
+	conf := relt.DefaultReltConfiguration()
+	conf.Name = name
+	if len(b.URL) > 0 {
+		conf.Url = b.URL
+	}
+	conf.Exchange = relt.GroupAddress(address)
+	r, err := relt.NewRelt(*conf)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &reltConnection{
+		relt:     r,
+		received: make(chan types.Delivery, ReceiveBatch),
+		done:     make(chan struct{}),
+		once:     &sync.Once{},
+	}
+	InvokerInstance().Spawn(c.poll)
+	return c, nil
+}
+
+// A connection to RabbitMQ using relt.
+type reltConnection struct {
+	// Reliable transport.
+	relt *relt.Relt
+
+	// The data received from relt.
+	received chan types.Delivery
+
+	// Closed when the connection closes.
+	done chan struct{}
+
+	// Close the connection only once.
+	once *sync.Once
+}
+
+// Implements the BrokerConnection interface.
+func (r *reltConnection) Publish(address types.Address, data []byte) error {
+	return r.relt.Broadcast(relt.Send{
+		Address: relt.GroupAddress(address),
+		Data:    data,
+	})
+}
+
+// Implements the BrokerConnection interface.
+func (r *reltConnection) Consume() <-chan types.Delivery {
+	return r.received
+}
+
+// Implements the BrokerConnection interface.
+func (r *reltConnection) Close() {
+	r.once.Do(func() {
+		close(r.done)
+		r.relt.Close()
+	})
+}
+
+// Forward the data received from relt until closed.
+func (r *reltConnection) poll() {
+	defer close(r.received)
+	for {
+		select {
+		case <-r.done:
+			return
+		case recv, ok := <-r.relt.Consume():
+			if !ok {
+				return
+			}
+			select {
+			case <-r.done:
+				return
+			case r.received <- types.Delivery{Data: recv.Data, Error: recv.Error}:
+			}
+		}
+	}
+}
+
+// A broker living in the process memory, where every partition
+// is running on the same process. Useful for tests and examples,
+// since no external broker is needed.
+//
+// The data published is delivered to all the subscribed
+// connections at once, so every connection receives the data
+// in the same order, even from different publishers.
+type MemoryBroker struct {
+	// Synchronize the subscriptions and the publishing.
+	mutex *sync.Mutex
+
+	// The connections subscribed to each address.
+	subscriptions map[types.Address][]*memoryConnection
+}
+
+// Creates a new empty in memory broker.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		mutex:         &sync.Mutex{},
+		subscriptions: make(map[types.Address][]*memoryConnection),
+	}
+}
+
+// Implements the Broker interface.
+func (m *MemoryBroker) Connect(name string, address types.Address) (types.BrokerConnection, error) {
+	c := &memoryConnection{
+		mutex:    &sync.Mutex{},
+		broker:   m,
+		address:  address,
+		notify:   make(chan struct{}, 1),
+		received: make(chan types.Delivery),
+		done:     make(chan struct{}),
+		once:     &sync.Once{},
+	}
+	m.mutex.Lock()
+	m.subscriptions[address] = append(m.subscriptions[address], c)
+	m.mutex.Unlock()
+	InvokerInstance().Spawn(c.poll)
+	return c, nil
+}
+
+// Deliver the data to every connection subscribed to the address.
+func (m *MemoryBroker) publish(address types.Address, data []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, c := range m.subscriptions[address] {
+		c.deliver(append([]byte{}, data...))
+	}
+}
+
+// Remove the connection from the subscriptions.
+func (m *MemoryBroker) unsubscribe(c *memoryConnection) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	connections := m.subscriptions[c.address]
+	for i, connection := range connections {
+		if connection == c {
+			m.subscriptions[c.address] = append(connections[:i], connections[i+1:]...)
+			break
+		}
+	}
+	if len(m.subscriptions[c.address]) == 0 {
+		delete(m.subscriptions, c.address)
+	}
+}
+
+// A single connection to the in memory broker. The data is
+// held until consumed, so publishing never waits.
+type memoryConnection struct {
+	// Synchronize access to the pending data.
+	mutex *sync.Mutex
+
+	// The broker the connection belongs to.
+	broker *MemoryBroker
+
+	// The subscribed address.
+	address types.Address
+
+	// The data delivered and not consumed yet.
+	pending []types.Delivery
+
+	// Signaled when data is delivered.
+	notify chan struct{}
+
+	// The data consumed from the connection.
+	received chan types.Delivery
+
+	// Closed when the connection closes.
+	done chan struct{}
+
+	// Close the connection only once.
+	once *sync.Once
+}
+
+// Implements the BrokerConnection interface.
+func (c *memoryConnection) Publish(address types.Address, data []byte) error {
+	select {
+	case <-c.done:
+		return ErrBrokerClosed
+	default:
+	}
+	c.broker.publish(address, data)
+	return nil
+}
+
+// Implements the BrokerConnection interface.
+func (c *memoryConnection) Consume() <-chan types.Delivery {
+	return c.received
+}
+
+// Implements the BrokerConnection interface.
+func (c *memoryConnection) Close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.broker.unsubscribe(c)
+	})
+}
+
+// Hold the data until consumed.
+func (c *memoryConnection) deliver(data []byte) {
+	c.mutex.Lock()
+	c.pending = append(c.pending, types.Delivery{Data: data})
+	c.mutex.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Publish the pending data, in order, until closed.
+func (c *memoryConnection) poll() {
+	defer close(c.received)
+	for {
+		c.mutex.Lock()
+		if len(c.pending) == 0 {
+			c.mutex.Unlock()
+			select {
+			case <-c.done:
+				return
+			case <-c.notify:
+				continue
+			}
+		}
+		delivery := c.pending[0]
+		c.pending = c.pending[1:]
+		c.mutex.Unlock()
+
+		select {
+		case <-c.done:
+			return
+		case c.received <- delivery:
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"time"
+)
+
+// Returned when the write of the causality token is not delivered
+// before the read gives up waiting.
+var ErrStaleRead = errors.New("causality token not delivered")
+
+// A read waiting for the delivery of a write.
+type awaiting struct {
+	// The final timestamp of the write.
+	timestamp uint64
+
+	// Closed once the write is delivered.
+	done chan struct{}
+}
+
+// Tracks the greatest timestamp delivered for each key, so the reads
+// wait for the writes they must observe. Only the messages conflicting
+// are delivered in the timestamp order, so the watermark of a key is
+// exact when the writes of the same key conflict.
+type Watermark struct {
+	// Synchronize access to the keys.
+	mutex *sync.Mutex
+
+	// The greatest timestamp delivered for each key.
+	delivered map[string]uint64
+
+	// The reads waiting on each key.
+	waiting map[string][]awaiting
+}
+
+// Creates a new watermark without deliveries.
+func NewWatermark() *Watermark {
+	return &Watermark{
+		mutex:     &sync.Mutex{},
+		delivered: make(map[string]uint64),
+		waiting:   make(map[string][]awaiting),
+	}
+}
+
+// The message was delivered, releasing the reads waiting for it.
+func (w *Watermark) Advance(key []byte, timestamp uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	k := string(key)
+	if timestamp > w.delivered[k] {
+		w.delivered[k] = timestamp
+	}
+
+	var pending []awaiting
+	for _, a := range w.waiting[k] {
+		if a.timestamp <= w.delivered[k] {
+			close(a.done)
+			continue
+		}
+		pending = append(pending, a)
+	}
+	if len(pending) == 0 {
+		delete(w.waiting, k)
+		return
+	}
+	w.waiting[k] = pending
+}
+
+// Verify if the write of the token was delivered.
+func (w *Watermark) Observed(token types.CausalityToken) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.delivered[string(token.Key)] >= token.Timestamp
+}
+
+// Wait until the write of the token is delivered. Fails with
+// ErrStaleRead when the context is done or the timeout expires
+// before. A zero timeout waits only for the context.
+func (w *Watermark) Await(ctx context.Context, token types.CausalityToken, timeout time.Duration) error {
+	w.mutex.Lock()
+	k := string(token.Key)
+	if w.delivered[k] >= token.Timestamp {
+		w.mutex.Unlock()
+		return nil
+	}
+	a := awaiting{timestamp: token.Timestamp, done: make(chan struct{})}
+	w.waiting[k] = append(w.waiting[k], a)
+	w.mutex.Unlock()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		w.abandon(k, a)
+		return fmt.Errorf("%w: %v", ErrStaleRead, ctx.Err())
+	case <-expired:
+		w.abandon(k, a)
+		return ErrStaleRead
+	}
+}
+
+// Stop waiting for the delivery.
+func (w *Watermark) abandon(key string, a awaiting) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	waiting := w.waiting[key]
+	for i := range waiting {
+		if waiting[i].done == a.done {
+			w.waiting[key] = append(waiting[:i], waiting[i+1:]...)
+			break
+		}
+	}
+	if len(w.waiting[key]) == 0 {
+		delete(w.waiting, key)
+	}
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Observed(token types.CausalityToken) bool {
+	return p.watermark.Observed(token)
+}
+
+// Implements the PartitionPeer interface.
+// The delivery is awaited at most the ObserverTTL.
+func (p *Peer) AwaitObserved(ctx context.Context, token types.CausalityToken) error {
+	return p.watermark.Await(ctx, token, p.configuration.ObserverTTL)
+}
@@ -1,21 +1,31 @@
 package core
 
 import (
+	"errors"
+	"math"
 	"sync"
 )
 
+// Returned when the clock can not advance without wrapping around,
+// which would order the next messages before the ones delivered.
+var ErrClockOverflow = errors.New("logical clock overflow")
+
 // A logical clock to provide the timestamp for a single peer.
 // Using atomic operations for thread safety across concurrent
 // requests.
 type LogicalClock interface {
-	// The clock is increased.
-	Tick()
+	// The clock is increased. Fails with ErrClockOverflow when
+	// the clock would wrap around, keeping the current value.
+	Tick() error
 
 	// The value present on the clock is retrieved.
 	Tock() uint64
 
 	// The value on the clock leaps to the given value.
 	Leap(to uint64)
+
+	// Verify if the clock still ticks without wrapping around.
+	Exhausted() bool
 }
 
 // Logical clock for a single process, implements the
@@ -26,13 +36,20 @@ type ProcessClock struct {
 
 	// Logical operation index.
 	index uint64
+
+	// How much each tick increases the index, one when zero.
+	step uint64
 }
 
 // Implements the LogicalClock interface.
-func (p *ProcessClock) Tick() {
+func (p *ProcessClock) Tick() error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
-	p.index += 1
+	if p.exhausted() {
+		return ErrClockOverflow
+	}
+	p.index += p.granularity()
+	return nil
 }
 
 // Implements the LogicalClock interface.
@@ -45,13 +62,42 @@ func (p *ProcessClock) Tock() uint64 {
 // Implements the LogicalClock interface.
 func (p *ProcessClock) Leap(to uint64) {
 	p.mutex.Lock()
-	p.mutex.Unlock()
+	defer p.mutex.Unlock()
 	p.index = to
 }
 
+// Implements the LogicalClock interface.
+func (p *ProcessClock) Exhausted() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.exhausted()
+}
+
+// If the next tick wraps around.
+// This method should be called while holding the mutex.
+func (p *ProcessClock) exhausted() bool {
+	return p.index > math.MaxUint64-p.granularity()
+}
+
+// How much each tick increases the index.
+func (p *ProcessClock) granularity() uint64 {
+	if p.step == 0 {
+		return 1
+	}
+	return p.step
+}
+
 func NewClock() LogicalClock {
+	return NewSteppedClock(1)
+}
+
+// Creates a clock increasing by the given step on each tick. A
+// step greater than one spaces the timestamps assigned, leaving
+// room between them.
+func NewSteppedClock(step uint64) LogicalClock {
 	return &ProcessClock{
 		mutex: &sync.Mutex{},
 		index: 0,
+		step:  step,
 	}
 }
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"time"
+)
+
+// Reclaim the entries of the finished messages on each interval.
+func (p *Peer) collect() {
+	ticker := time.NewTicker(p.configuration.GCInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-p.context.Done():
+			return
+		case now := <-ticker.C:
+			p.reclaim(last)
+			last = now
+		}
+	}
+}
+
+// Reclaim the entries kept for the messages no longer pending. The
+// timestamps exchanged are removed once the message left the received
+// queue, delivered, dropped or expired, unless a timestamp arrived
+// after the given time, since the message itself may still arrive.
+//
+// The previous set only loses the messages dropped or expired, the
+// delivered ones are still needed to order the conflicting messages
+// proposed on the same clock value, and leave the set once the
+// clock ticks. Returns how many entries were reclaimed.
+func (p *Peer) reclaim(before time.Time) int {
+	pending := make(map[types.UID]bool)
+	for _, message := range p.rqueue.Pending() {
+		pending[message.Identifier] = true
+	}
+
+	p.mutex.Lock()
+	dropped := p.dropped
+	p.dropped = make(map[types.UID]bool)
+	p.mutex.Unlock()
+
+	keep := func(uid types.UID) bool {
+		return pending[uid]
+	}
+	reclaimed := p.received.Sweep(before, keep) + p.tree.Sweep(before, keep)
+	reclaimed += p.previousSet.Sweep(func(message types.Message) bool {
+		return dropped[message.Identifier] && !pending[message.Identifier]
+	})
+	if reclaimed > 0 {
+		p.stats.Reclaimed(reclaimed)
+		p.log.Debugf("peer %s reclaimed %d entries of finished messages", p.configuration.Name, reclaimed)
+	}
+	return reclaimed
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"hash/fnv"
+	"sync"
+)
+
+// Returned when the content of a received message can not be
+// decompressed, such as when the dictionary is not known.
+var ErrDecompression = errors.New("failed decompressing content")
+
+// Compresses the contents of the messages sent with a dictionary
+// trained from the delivered contents, and decompresses the received
+// ones with the dictionary on their header. Each new dictionary has
+// a new version, and the previous versions are still decompressed,
+// so the messages sent before the training are not lost.
+//
+// A nil compressor neither compresses nor decompresses.
+type DictionaryCompressor struct {
+	// The compression policy.
+	policy types.Compression
+
+	// Logs the dictionaries trained.
+	log types.Logger
+
+	// Synchronize access to the samples and dictionaries.
+	mutex *sync.Mutex
+
+	// The contents sampled for the next dictionary.
+	samples [][]byte
+
+	// If a dictionary is being trained.
+	training bool
+
+	// Version of the dictionary compressing the sent contents,
+	// zero until the first dictionary is trained.
+	version uint32
+
+	// The dictionaries already used, by version.
+	known map[uint32][]byte
+}
+
+// Creates the compressor for the policy, nil when disabled.
+func NewDictionaryCompressor(policy types.Compression, log types.Logger) *DictionaryCompressor {
+	if !policy.Enabled() {
+		return nil
+	}
+	if policy.Samples <= 0 {
+		policy.Samples = types.DefaultCompressionSamples
+	}
+	if policy.Size <= 0 {
+		policy.Size = types.DefaultCompressionSize
+	}
+	return &DictionaryCompressor{
+		policy: policy,
+		log:    log,
+		mutex:  &sync.Mutex{},
+		known:  make(map[uint32][]byte),
+	}
+}
+
+// Sample the delivered content. Once enough contents are sampled a
+// new dictionary is trained asynchronously, and the contents delivered
+// meanwhile are not sampled.
+func (c *DictionaryCompressor) Sample(content []byte) {
+	if c == nil || len(content) == 0 || len(content) > c.policy.Size {
+		return
+	}
+
+	c.mutex.Lock()
+	if c.training {
+		c.mutex.Unlock()
+		return
+	}
+	c.samples = append(c.samples, append([]byte(nil), content...))
+	if len(c.samples) < c.policy.Samples {
+		c.mutex.Unlock()
+		return
+	}
+	samples := c.samples
+	c.samples = nil
+	c.training = true
+	c.mutex.Unlock()
+	InvokerInstance().Spawn(func() {
+		c.train(samples)
+	})
+}
+
+// Train and share a new dictionary, compressing the next contents
+// with it.
+func (c *DictionaryCompressor) train(samples [][]byte) {
+	defer func() {
+		c.mutex.Lock()
+		c.training = false
+		c.mutex.Unlock()
+	}()
+
+	dictionary, err := c.policy.Codec.Train(samples)
+	if err != nil {
+		c.log.Warnf("failed training dictionary. %v", err)
+		return
+	}
+	version := dictionaryVersion(dictionary)
+	if err := c.policy.Dictionaries.Put(version, dictionary); err != nil {
+		c.log.Warnf("failed storing dictionary %d. %v", version, err)
+		return
+	}
+
+	c.mutex.Lock()
+	c.version = version
+	c.known[version] = dictionary
+	c.mutex.Unlock()
+	c.log.Infof("compressing with dictionary %d trained from %d samples", version, len(samples))
+}
+
+// Version of the dictionary compressing the sent contents, zero
+// while no dictionary was trained.
+func (c *DictionaryCompressor) Version() uint32 {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.version
+}
+
+// Compress the content of the protocol message with the current
+// dictionary. Only the messages exchanged between the partitions are
+// compressed, since the clients and the replicas do not share the
+// dictionaries. The content is kept as is when there is no dictionary
+// yet, when it is too large or when the compression does not make it
+// smaller.
+func (c *DictionaryCompressor) Compress(message types.Message) types.Message {
+	if c == nil || message.Header.Dictionary != 0 {
+		return message
+	}
+	if message.Header.Type != types.Initial && message.Header.Type != types.External {
+		return message
+	}
+	content := message.Content.Content
+	if len(content) == 0 || len(content) > c.policy.Size {
+		return message
+	}
+
+	c.mutex.Lock()
+	version := c.version
+	dictionary := c.known[version]
+	c.mutex.Unlock()
+	if version == 0 {
+		return message
+	}
+
+	compressed, err := c.policy.Codec.Compress(dictionary, content)
+	if err != nil || len(compressed) >= len(content) {
+		return message
+	}
+	message.Content.Content = compressed
+	message.Header.Dictionary = version
+	return message
+}
+
+// Decompress the message content with the dictionary on the header.
+func (c *DictionaryCompressor) Decompress(message types.Message) (types.Message, error) {
+	version := message.Header.Dictionary
+	if version == 0 {
+		return message, nil
+	}
+	if c == nil {
+		return message, fmt.Errorf("%w: dictionary %d without compression", ErrDecompression, version)
+	}
+
+	dictionary, err := c.lookup(version)
+	if err != nil {
+		return message, fmt.Errorf("%w: %v", ErrDecompression, err)
+	}
+	content, err := c.policy.Codec.Decompress(dictionary, message.Content.Content)
+	if err != nil {
+		return message, fmt.Errorf("%w: %v", ErrDecompression, err)
+	}
+	message.Content.Content = content
+	message.Header.Dictionary = 0
+	return message, nil
+}
+
+// The dictionary with the version, loaded from the store once.
+func (c *DictionaryCompressor) lookup(version uint32) ([]byte, error) {
+	c.mutex.Lock()
+	dictionary, ok := c.known[version]
+	c.mutex.Unlock()
+	if ok {
+		return dictionary, nil
+	}
+
+	dictionary, err := c.policy.Dictionaries.Get(version)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	c.known[version] = dictionary
+	c.mutex.Unlock()
+	return dictionary, nil
+}
+
+// The version of the dictionary, derived from its content so the
+// dictionaries trained by different peers do not collide. Zero is
+// reserved for the contents not compressed.
+func dictionaryVersion(dictionary []byte) uint32 {
+	h := fnv.New32a()
+	h.Write(dictionary)
+	if version := h.Sum32(); version != 0 {
+		return version
+	}
+	return 1
+}
@@ -0,0 +1,35 @@
+package core
+
+import "github.com/jabolina/go-mcast/pkg/mcast/types"
+
+// Decorates the conflict relationship applying the consistency
+// level requested for each message.
+//
+// A message with total order conflicts with every message and a
+// local message conflicts with none, except the ones with total
+// order. Messages with the generic level follow the relationship.
+type ConsistentConflict struct {
+	types.ConflictRelationship
+}
+
+// Implements the ConflictRelationship interface.
+func (c ConsistentConflict) Conflict(message types.Message, messages []types.Message) bool {
+	if message.Header.Consistency == types.ConsistencyTotalOrder {
+		return true
+	}
+
+	var generic []types.Message
+	for _, other := range messages {
+		switch other.Header.Consistency {
+		case types.ConsistencyTotalOrder:
+			return true
+		case types.ConsistencyGeneric:
+			generic = append(generic, other)
+		}
+	}
+
+	if message.Header.Consistency == types.ConsistencyLocal {
+		return false
+	}
+	return c.ConflictRelationship.Conflict(message, generic)
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+)
+
+// Implements the PartitionPeer interface.
+func (p *Peer) AwaitCut(uid types.UID) <-chan types.Cut {
+	p.delivery.Lock()
+	defer p.delivery.Unlock()
+	reached := make(chan types.Cut, 1)
+	p.cuts[uid] = reached
+	return reached
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) AbandonCut(uid types.UID) {
+	p.delivery.Lock()
+	defer p.delivery.Unlock()
+	delete(p.cuts, uid)
+}
+
+// Read the state of the partition on the cut marker delivered. The
+// messages before the marker are committed and the ones after are
+// not, so the state is exactly the one at the cut.
+// This method should be called while holding the delivery mutex.
+func (p *Peer) cut(m types.Message) {
+	cut := types.Cut{
+		Identifier: m.Identifier,
+		Partition:  p.configuration.Partition,
+		Timestamp:  m.Timestamp,
+	}
+	cut.Entries, cut.Failure = p.deliver.History(types.HistoryFilter{})
+	if cut.Failure != nil {
+		p.log.Warnf("peer %s failed reading cut %s. %v", p.configuration.Name, m.Identifier, cut.Failure)
+	}
+	if p.configuration.OnCut != nil {
+		p.configuration.OnCut(cut)
+	}
+
+	p.notify(m.Identifier, types.Response{Success: cut.Failure == nil, Identifier: m.Identifier, Failure: cut.Failure})
+	if reached, ok := p.cuts[m.Identifier]; ok {
+		delete(p.cuts, m.Identifier)
+		reached <- cut
+	}
+}
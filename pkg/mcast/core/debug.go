@@ -0,0 +1,7 @@
+//go:build mcastdebug
+// +build mcastdebug
+
+package core
+
+// Verify the state transitions of every message.
+const debugTransitions = true
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"runtime"
+	"sync"
+)
+
+// A received frame after decoding.
+type Decoded struct {
+	// The decoded message.
+	Message types.Message
+
+	// Why the frame could not be decoded.
+	Err error
+}
+
+// A frame waiting for a decoder.
+type decodeJob struct {
+	data   []byte
+	result *Decoded
+	group  *sync.WaitGroup
+}
+
+// Decodes batches of frames in parallel, on a fixed number of
+// workers living while the context is open. The results keep
+// the order of the batch, so the messages are published in the
+// same order they were received.
+type DecodePool struct {
+	// Decodes the frames.
+	codec types.Codec
+
+	// Frames waiting for a worker.
+	jobs chan decodeJob
+
+	// The pool context.
+	context context.Context
+}
+
+// Creates a new pool with the given number of workers, or one
+// worker for each CPU when not positive.
+func NewDecodePool(ctx context.Context, codec types.Codec, workers int) *DecodePool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	d := &DecodePool{
+		codec:   codec,
+		jobs:    make(chan decodeJob),
+		context: ctx,
+	}
+	for i := 0; i < workers; i++ {
+		InvokerInstance().Spawn(d.work)
+	}
+	return d
+}
+
+// Decode the frames, where a nil frame results in an empty message.
+// A batch with a single frame is decoded without the workers, and
+// once the pool is closed the frames are decoded sequentially.
+func (d *DecodePool) Decode(batch [][]byte) []Decoded {
+	results := make([]Decoded, len(batch))
+	if len(batch) == 1 {
+		d.decode(batch[0], &results[0])
+		return results
+	}
+
+	group := &sync.WaitGroup{}
+	for i, data := range batch {
+		job := decodeJob{data: data, result: &results[i], group: group}
+		group.Add(1)
+		select {
+		case <-d.context.Done():
+			d.run(job)
+		case d.jobs <- job:
+		}
+	}
+	group.Wait()
+	return results
+}
+
+func (d *DecodePool) work() {
+	for {
+		select {
+		case <-d.context.Done():
+			return
+		case job := <-d.jobs:
+			d.run(job)
+		}
+	}
+}
+
+func (d *DecodePool) run(job decodeJob) {
+	defer job.group.Done()
+	d.decode(job.data, job.result)
+}
+
+func (d *DecodePool) decode(data []byte, result *Decoded) {
+	if data == nil {
+		return
+	}
+	result.Message, result.Err = DecodeFrame(d.codec, data)
+}
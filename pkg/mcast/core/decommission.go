@@ -0,0 +1,235 @@
+package core
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned to the requests that have a decommissioned
+	// partition as destination.
+	ErrDecommissioned = errors.New("partition decommissioned")
+)
+
+// How often a decommissioned peer verifies if all its
+// pending messages were delivered.
+const drainInterval = 10 * time.Millisecond
+
+// Holds the decommissioned partitions known by a peer.
+//
+// The peer own partition is decommissioned once the cut is
+// processed, from there the peer does not accept new messages.
+// Other partitions are decommissioned once their final marker
+// is received, from there the peer does not wait for their
+// timestamps anymore.
+type Retirement struct {
+	// Synchronize access to the partitions.
+	mutex *sync.Mutex
+
+	// The peer own partition.
+	partition types.Partition
+
+	// If the own partition stopped accepting new messages.
+	cut bool
+
+	// If the own partition delivered all the pending
+	// messages and sent the final marker.
+	drained bool
+
+	// The other partitions decommissioned.
+	retired map[types.Partition]bool
+}
+
+// Create a new retirement for the given partition, where
+// no partition is decommissioned.
+func NewRetirement(partition types.Partition) *Retirement {
+	return &Retirement{
+		mutex:     &sync.Mutex{},
+		partition: partition,
+		retired:   make(map[types.Partition]bool),
+	}
+}
+
+// Stop the own partition from accepting new messages. Returns
+// false if the partition was already cut.
+func (r *Retirement) Cut() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.cut {
+		return false
+	}
+	r.cut = true
+	return true
+}
+
+// The own partition delivered the pending messages.
+func (r *Retirement) Drain() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.drained = true
+}
+
+// Verify if the own partition delivered the pending messages.
+func (r *Retirement) Drained() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.drained
+}
+
+// Decommission another partition. Returns false if the
+// partition was already decommissioned.
+func (r *Retirement) Retire(partition types.Partition) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if partition == r.partition || r.retired[partition] {
+		return false
+	}
+	r.retired[partition] = true
+	return true
+}
+
+// Verify if the partition is decommissioned.
+func (r *Retirement) Retired(partition types.Partition) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if partition == r.partition {
+		return r.cut
+	}
+	return r.retired[partition]
+}
+
+// The partitions on the destination still participating on
+// the protocol, the own partition is always participating.
+func (r *Retirement) Participants(destination []types.Partition) []types.Partition {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var participants []types.Partition
+	for _, partition := range destination {
+		if !r.retired[partition] {
+			participants = append(participants, partition)
+		}
+	}
+	return participants
+}
+
+// Implements the PartitionPeer interface.
+// The cut is sent through the partition, so every replica stops
+// accepting new messages at the same point.
+func (p *Peer) Decommission(notify []types.Partition) error {
+	message := types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: p.configuration.Version,
+			Type:            types.Decommission,
+		},
+		Identifier:  types.UID(helper.GenerateUID()),
+		Destination: notify,
+		From:        p.configuration.Partition,
+	}
+	return p.transport.Unicast(message, p.configuration.Partition)
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Drained() bool {
+	return p.retirement.Drained()
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Retired(partition types.Partition) bool {
+	return p.retirement.Retired(partition)
+}
+
+// Handle a decommission message. When sent by the own partition
+// is the cut, the peer drains the pending messages and then sends
+// the final marker to the partitions on the message destination.
+// Otherwise is the final marker of the partition that sent it.
+func (p *Peer) decommission(message types.Message) {
+	if message.From != p.configuration.Partition {
+		p.retire(message.From)
+		return
+	}
+
+	if !p.retirement.Cut() {
+		return
+	}
+	p.log.Infof("peer %s decommissioned, draining", p.configuration.Name)
+	notify := message.Destination
+	p.invoker.Spawn(func() {
+		p.drain(notify)
+	})
+}
+
+// Wait until every pending message is delivered, then send
+// the final marker to the given partitions.
+func (p *Peer) drain(notify []types.Partition) {
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for len(p.rqueue.Pending()) > 0 {
+		select {
+		case <-p.context.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+
+	marker := types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: p.configuration.Version,
+			Type:            types.Decommission,
+		},
+		Identifier: types.UID(helper.GenerateUID()),
+		From:       p.configuration.Partition,
+	}
+	for _, partition := range notify {
+		if partition != p.configuration.Partition {
+			p.unicast(marker, partition)
+		}
+	}
+	p.retirement.Drain()
+	p.log.Infof("peer %s drained, final marker sent to %v", p.configuration.Name, notify)
+}
+
+// Decommission the given partition. The messages still waiting
+// for the partition timestamp are completed with the timestamps
+// of the partitions participating.
+func (p *Peer) retire(partition types.Partition) {
+	if !p.retirement.Retire(partition) {
+		return
+	}
+
+	p.log.Infof("peer %s received the final marker of %s", p.configuration.Name, partition)
+	for _, pending := range p.rqueue.Pending() {
+		if pending.State != types.S1 {
+			continue
+		}
+		message := pending
+		if p.complete(&message) {
+			p.finishMessageProcessing(&message)
+		}
+	}
+}
+
+// Verify if the message must be rejected because the own
+// partition is decommissioned. Only the messages accepted
+// before the cut are processed, a new request fails.
+func (p *Peer) rejected(message types.Message) bool {
+	header := message.Extract()
+	if header.Type != types.Initial && header.Type != types.External {
+		return false
+	}
+	if !p.retirement.Retired(p.configuration.Partition) || p.rqueue.GetIfExists(string(message.Identifier)) != nil {
+		return false
+	}
+
+	if header.Type == types.Initial && message.State == types.S0 {
+		p.log.Warnf("peer %s decommissioned, rejecting %s", p.configuration.Name, message.Identifier)
+		res := types.Response{Identifier: message.Identifier, Failure: ErrDecommissioned}
+		p.notify(message.Identifier, res)
+		if len(header.ReplyTo) > 0 {
+			p.reply(message, res)
+		}
+	}
+	return true
+}
@@ -2,14 +2,33 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sort"
+	"sync"
+)
+
+var (
+	// Returned when the state machine panics while committing.
+	ErrCommitPanic = errors.New("state machine panicked")
 )
 
 // Interface to deliver messages.
 type Deliverable interface {
 	// Commit the given message on the state machine.
 	Commit(message types.Message) types.Response
+
+	// Commit the given messages in order, returning the
+	// responses in the same order.
+	CommitBatch(messages []types.Message) []types.Response
+
+	// Apply the entries on the state machine, bypassing
+	// the protocol. Stops on the first failure.
+	Load(entries []*types.Entry) error
+
+	// Read the committed entries matching the filter.
+	History(filter types.HistoryFilter) ([]types.Entry, error)
 }
 
 // A struct that is able to deliver message from the protocol.
@@ -29,21 +48,38 @@ type Deliver struct {
 	// The peer state machine.
 	sm types.StateMachine
 
+	// How many messages not conflicting are committed
+	// concurrently by CommitBatch.
+	parallelism int
+
 	// Deliver logger.
 	log types.Logger
 }
 
 // Creates a new instance of the Deliverable interface.
+// If the storage also implements the StateMachine interface it
+// is used directly, so the storage controls how entries are committed.
 func NewDeliver(ctx context.Context, log types.Logger, conflict types.ConflictRelationship, storage types.Storage) (Deliverable, error) {
-	sm := types.NewStateMachine(storage)
+	return NewParallelDeliver(ctx, log, conflict, storage, 1)
+}
+
+// Creates a new instance of the Deliverable interface that commits
+// the messages not conflicting concurrently, up to the parallelism.
+// The state machine must be safe for concurrent commits.
+func NewParallelDeliver(ctx context.Context, log types.Logger, conflict types.ConflictRelationship, storage types.Storage, parallelism int) (Deliverable, error) {
+	sm, ok := storage.(types.StateMachine)
+	if !ok {
+		sm = types.NewStateMachine(storage)
+	}
 	if err := sm.Restore(); err != nil {
 		return nil, err
 	}
 	d := &Deliver{
-		ctx:      ctx,
-		conflict: conflict,
-		sm:       sm,
-		log:      log,
+		ctx:         ctx,
+		conflict:    conflict,
+		sm:          sm,
+		parallelism: parallelism,
+		log:         log,
 	}
 	return d, nil
 }
@@ -51,15 +87,165 @@ func NewDeliver(ctx context.Context, log types.Logger, conflict types.ConflictRe
 // Commit the message on the peer state machine.
 // After the commit a notification is sent through the commit channel.
 func (d Deliver) Commit(m types.Message) types.Response {
-	res := types.Response{
-		Success:    false,
-		Identifier: m.Identifier,
-		Data:       nil,
-		Extra:      nil,
-		Failure:    nil,
-	}
 	d.log.Debugf("commit request %#v", m)
-	entry := &types.Entry{
+	var commit interface{}
+	var err error
+	if Protect("commit "+string(m.Identifier), func() {
+		commit, err = d.sm.Commit(entryOf(m))
+	}) {
+		err = ErrCommitPanic
+	}
+	if err != nil {
+		d.log.Errorf("failed to commit %#v. %v", m, err)
+	}
+	return responseOf(m, commit, err)
+}
+
+// Commit the messages on the peer state machine. When the state
+// machine implements the BatchStateMachine interface the consecutive
+// commands are committed in a single call, otherwise the messages
+// are committed one by one. If a batch fails its messages are
+// committed again one by one, so a single failing message does
+// not fail the others.
+//
+// With parallelism, the messages are split into conflict classes,
+// each class is committed in order and the classes concurrently.
+func (d Deliver) CommitBatch(messages []types.Message) []types.Response {
+	if d.parallelism < 2 || len(messages) < 2 {
+		return d.commitSequential(messages)
+	}
+
+	classes := d.classes(messages)
+	if len(classes) == 1 {
+		return d.commitSequential(messages)
+	}
+
+	d.log.Debugf("commit %d messages on %d classes", len(messages), len(classes))
+	responses := make([]types.Response, len(messages))
+	slots := make(chan struct{}, d.parallelism)
+	group := &sync.WaitGroup{}
+	for _, class := range classes {
+		class := class
+		slots <- struct{}{}
+		group.Add(1)
+		InvokerInstance().Spawn(func() {
+			defer func() {
+				<-slots
+				group.Done()
+			}()
+			ordered := make([]types.Message, len(class))
+			for i, at := range class {
+				ordered[i] = messages[at]
+			}
+			for i, res := range d.commitSequential(ordered) {
+				responses[class[i]] = res
+			}
+		})
+	}
+	group.Wait()
+	return responses
+}
+
+// Split the messages into conflict classes, holding the positions
+// of the messages in order. A message conflicting with messages of
+// many classes joins them into a single class, so every pair of
+// messages conflicting is on the same class.
+func (d Deliver) classes(messages []types.Message) [][]int {
+	var classes [][]int
+	for i, m := range messages {
+		joined := []int{i}
+		var others [][]int
+		for _, class := range classes {
+			members := make([]types.Message, len(class))
+			for j, at := range class {
+				members[j] = messages[at]
+			}
+			if d.conflict.Conflict(m, members) {
+				joined = append(joined, class...)
+			} else {
+				others = append(others, class)
+			}
+		}
+		sort.Ints(joined)
+		classes = append(others, joined)
+	}
+	return classes
+}
+
+// Commit the messages in order, batching the consecutive commands.
+func (d Deliver) commitSequential(messages []types.Message) []types.Response {
+	batcher, ok := d.sm.(types.BatchStateMachine)
+	responses := make([]types.Response, 0, len(messages))
+	for start := 0; start < len(messages); {
+		end := start + 1
+		for ok && end < len(messages) && messages[start].Content.Operation == types.Command &&
+			messages[end].Content.Operation == types.Command {
+			end++
+		}
+
+		if end-start == 1 {
+			responses = append(responses, d.Commit(messages[start]))
+		} else {
+			responses = append(responses, d.commitBatch(batcher, messages[start:end])...)
+		}
+		start = end
+	}
+	return responses
+}
+
+func (d Deliver) commitBatch(batcher types.BatchStateMachine, messages []types.Message) []types.Response {
+	d.log.Debugf("commit batch of %d messages", len(messages))
+	entries := make([]*types.Entry, len(messages))
+	for i, m := range messages {
+		entries[i] = entryOf(m)
+	}
+
+	var commits []interface{}
+	var err error
+	if Protect("commit batch", func() {
+		commits, err = batcher.CommitBatch(entries)
+	}) {
+		err = ErrCommitPanic
+	}
+	if err == nil && len(commits) != len(messages) {
+		err = fmt.Errorf("commit batch responses %d, expected %d", len(commits), len(messages))
+	}
+
+	responses := make([]types.Response, len(messages))
+	if err != nil {
+		d.log.Warnf("failed to commit batch, committing one by one. %v", err)
+		for i, m := range messages {
+			responses[i] = d.Commit(m)
+		}
+		return responses
+	}
+
+	for i, m := range messages {
+		responses[i] = responseOf(m, commits[i], nil)
+	}
+	return responses
+}
+
+// Load the entries on the peer state machine. When the state machine
+// implements the BatchStateMachine interface all the entries are
+// applied in a single call.
+func (d Deliver) Load(entries []*types.Entry) error {
+	if batcher, ok := d.sm.(types.BatchStateMachine); ok {
+		_, err := batcher.CommitBatch(entries)
+		return err
+	}
+
+	for _, entry := range entries {
+		if _, err := d.sm.Commit(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create the state machine entry for the message.
+func entryOf(m types.Message) *types.Entry {
+	return &types.Entry{
 		Operation:      m.Content.Operation,
 		Identifier:     m.Identifier,
 		Key:            m.Content.Key,
@@ -67,21 +253,35 @@ func (d Deliver) Commit(m types.Message) types.Response {
 		Data:           m.Content.Content,
 		Extensions:     m.Content.Extensions,
 	}
-	commit, err := d.sm.Commit(entry)
+}
+
+// Create the response for the message from the commit result.
+func responseOf(m types.Message, commit interface{}, err error) types.Response {
+	res := types.Response{
+		Success:    false,
+		Identifier: m.Identifier,
+	}
 	if err != nil {
-		d.log.Errorf("failed to commit %#v. %v", m, err)
-		res.Success = false
 		res.Failure = err
-	} else {
-		switch c := commit.(type) {
-		case *types.Entry:
-			res.Success = true
-			res.Data = c.Data
-			res.Extra = c.Extensions
-		default:
-			res.Success = false
-			res.Failure = fmt.Errorf("commit unknown response. %#v", c)
-		}
+		return res
+	}
+
+	switch c := commit.(type) {
+	case *types.Entry:
+		res.Success = true
+		res.Data = c.Data
+		res.Extra = c.Extensions
+	default:
+		res.Failure = fmt.Errorf("commit unknown response. %#v", c)
 	}
 	return res
 }
+
+// Read the history from the state machine, if it keeps the history.
+func (d Deliver) History(filter types.HistoryFilter) ([]types.Entry, error) {
+	reader, ok := d.sm.(types.HistoryReader)
+	if !ok {
+		return nil, types.ErrHistoryUnsupported
+	}
+	return reader.History(filter)
+}
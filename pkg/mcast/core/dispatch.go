@@ -0,0 +1,181 @@
+package core
+
+import (
+	"context"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"time"
+)
+
+// How long a dispatched message is remembered, so the same
+// message committed by the other peers of the unity is ignored.
+const DispatchMemory = 10 * time.Minute
+
+// Runs the delivery callback of a unity. Every peer of the unity
+// dispatches the messages it commits, the first peer committing
+// a message hands it to the callback and the others are ignored.
+//
+// The deliveries are queued by the message key, and a single task
+// for each key is submitted to the executor, draining the queue in
+// the delivery order. This way the order of the same key is kept
+// whatever is the order the executor runs the tasks.
+type Dispatcher struct {
+	// Synchronize access to the queues.
+	mutex *sync.Mutex
+
+	// Runs the tasks draining the queues.
+	executor types.Executor
+
+	// The user callback.
+	callback types.DeliverCallback
+
+	// The deliveries waiting for each key. A key is present
+	// while a task draining it was submitted.
+	queues map[string][]types.Delivered
+
+	// When each message was dispatched.
+	dispatched map[types.UID]time.Time
+
+	// When the old dispatched messages were last removed.
+	swept time.Time
+}
+
+// Creates a new dispatcher running the callback on the executor.
+// When the executor is nil, the callback runs on the goroutine
+// of the peer that committed the message.
+func NewDispatcher(executor types.Executor, callback types.DeliverCallback) *Dispatcher {
+	if executor == nil {
+		executor = SynchronousExecutor{}
+	}
+	return &Dispatcher{
+		mutex:      &sync.Mutex{},
+		executor:   executor,
+		callback:   callback,
+		queues:     make(map[string][]types.Delivered),
+		dispatched: make(map[types.UID]time.Time),
+		swept:      time.Now(),
+	}
+}
+
+// Hand the committed message to the callback, if it was not
+// handed yet. The peer must dispatch the messages in the order
+// they are committed.
+func (d *Dispatcher) Dispatch(delivered types.Delivered) {
+	key := string(delivered.Message.Content.Key)
+	d.mutex.Lock()
+	if _, ok := d.dispatched[delivered.Message.Identifier]; ok {
+		d.mutex.Unlock()
+		return
+	}
+	d.dispatched[delivered.Message.Identifier] = time.Now()
+	d.sweep()
+	pending, draining := d.queues[key]
+	d.queues[key] = append(pending, delivered)
+	d.mutex.Unlock()
+
+	if !draining {
+		d.executor.Execute(func() {
+			d.drain(key)
+		})
+	}
+}
+
+// Run the callback for the deliveries of the key until the queue
+// is empty. A failing callback does not stop the next deliveries.
+func (d *Dispatcher) drain(key string) {
+	for {
+		d.mutex.Lock()
+		pending := d.queues[key]
+		if len(pending) == 0 {
+			delete(d.queues, key)
+			d.mutex.Unlock()
+			return
+		}
+		next := pending[0]
+		d.queues[key] = pending[1:]
+		d.mutex.Unlock()
+
+		Protect("deliver callback of "+string(next.Message.Identifier), func() {
+			d.callback(next)
+		})
+	}
+}
+
+// Forget the messages dispatched long ago, at most once a minute.
+// This method should be called while holding the mutex.
+func (d *Dispatcher) sweep() {
+	now := time.Now()
+	if now.Sub(d.swept) < time.Minute {
+		return
+	}
+	d.swept = now
+	for uid, at := range d.dispatched {
+		if now.Sub(at) >= DispatchMemory {
+			delete(d.dispatched, uid)
+		}
+	}
+}
+
+// Runs the task on the caller goroutine. With this executor the
+// delivery callback runs before the peer commits the next message,
+// so a slow callback slows the delivery.
+type SynchronousExecutor struct{}
+
+// Implements the Executor interface.
+func (SynchronousExecutor) Execute(task func()) {
+	task()
+}
+
+// Runs the tasks on a fixed number of goroutines. Submitting a task
+// blocks while every worker is busy and the backlog is full, so the
+// callbacks must not wait for other messages to be delivered.
+type WorkerPool struct {
+	// The tasks waiting for a worker.
+	tasks chan func()
+
+	// Stops the workers.
+	context context.Context
+	finish  context.CancelFunc
+}
+
+// Creates a new pool with the given number of workers.
+func NewWorkerPool(workers int) *WorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &WorkerPool{
+		tasks:   make(chan func(), workers),
+		context: ctx,
+		finish:  cancel,
+	}
+	for i := 0; i < workers; i++ {
+		InvokerInstance().Spawn(w.work)
+	}
+	return w
+}
+
+// Implements the Executor interface.
+// After the pool is closed the tasks are discarded.
+func (w *WorkerPool) Execute(task func()) {
+	select {
+	case w.tasks <- task:
+	case <-w.context.Done():
+	}
+}
+
+// Stop the workers, the tasks not started are discarded.
+func (w *WorkerPool) Close() {
+	w.finish()
+}
+
+func (w *WorkerPool) work() {
+	for {
+		select {
+		case <-w.context.Done():
+			return
+		case task := <-w.tasks:
+			task()
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// Reported when a message sent on an older epoch is rejected.
+	ErrStaleEpoch = errors.New("message from a stale epoch")
+)
+
+// A transport decorator fencing the stale peers. Every message sent
+// is stamped with the epoch of the peer partition, and the messages
+// received from an origin peer on an epoch older than the greatest
+// epoch seen from the same origin are rejected.
+//
+// The epoch of a partition is incremented on membership or recovery
+// events, through a message sent to the partition itself, so every
+// replica moves to the new epoch at the same point. The epoch is
+// persisted on the peer storage, so a restarted peer continues from
+// the epoch it was. When a peer is replaced by a new instance with
+// the same name, the old instance isolated by a network partition
+// keeps sending on the older epoch, and its messages are rejected
+// once the new instance is seen on the new epoch, so it can not
+// interfere on the ordering after the network heals.
+//
+// The fencing is per origin peer, so the messages from a replica that
+// did not see the new epoch yet are still accepted, since the replica
+// never sent on the new epoch.
+type EpochTransport struct {
+	// Synchronize access to the epochs.
+	mutex *sync.Mutex
+
+	// The underlying transport.
+	Transport
+
+	// The name of the peer using the transport.
+	name string
+
+	// The partition of the peer using the transport.
+	partition types.Partition
+
+	// Stable storage to persist the peer epoch, if any.
+	storage types.Storage
+
+	// Key used to persist the epoch on the storage.
+	key []byte
+
+	// The epoch of the peer partition.
+	epoch uint64
+
+	// The greatest epoch seen from each origin peer.
+	origins map[string]uint64
+
+	// How many messages were rejected.
+	fenced uint64
+
+	// Channel to publish the received messages.
+	producer chan types.Message
+
+	// Transport logger.
+	log types.Logger
+
+	// Where the asynchronous failures are reported.
+	errors *types.ErrorReporter
+
+	// The transport context.
+	context context.Context
+
+	// Finish the transport.
+	finish context.CancelFunc
+}
+
+// Creates a new epoch transport decorating the given transport,
+// starting on the epoch persisted on the peer storage, or on the
+// first epoch if nothing was persisted yet.
+func NewEpochTransport(transport Transport, peer *types.PeerConfiguration, log types.Logger) *EpochTransport {
+	ctx, done := context.WithCancel(context.Background())
+	e := &EpochTransport{
+		mutex:     &sync.Mutex{},
+		Transport: transport,
+		name:      peer.Name,
+		partition: peer.Partition,
+		storage:   peer.Storage,
+		key:       []byte(fmt.Sprintf("epoch-%s", peer.Name)),
+		origins:   make(map[string]uint64),
+		producer:  make(chan types.Message),
+		log:       log,
+		errors:    peer.Errors,
+		context:   ctx,
+		finish:    done,
+	}
+	e.epoch = e.read()
+	InvokerInstance().Spawn(func() {
+		defer close(e.producer)
+		Supervise(ctx, "epoch transport "+peer.Name, e.poll)
+	})
+	return e
+}
+
+// Implements the Transport interface.
+func (e *EpochTransport) Broadcast(message types.Message) error {
+	e.stamp(&message)
+	return e.Transport.Broadcast(message)
+}
+
+// Implements the Transport interface.
+func (e *EpochTransport) Unicast(message types.Message, partition types.Partition) error {
+	e.stamp(&message)
+	return e.Transport.Unicast(message, partition)
+}
+
+// Implements the Transport interface.
+func (e *EpochTransport) Listen() <-chan types.Message {
+	return e.producer
+}
+
+// Implements the Transport interface.
+func (e *EpochTransport) Close() {
+	e.finish()
+	e.Transport.Close()
+}
+
+// Implements the FlowControl interface.
+func (e *EpochTransport) Grant(credits int) {
+	grant(e.Transport, credits)
+}
+
+// The epoch of the peer partition.
+func (e *EpochTransport) Epoch() uint64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.epoch
+}
+
+// How many messages were rejected from stale epochs.
+func (e *EpochTransport) Fenced() uint64 {
+	return atomic.LoadUint64(&e.fenced)
+}
+
+// Move the peer partition to the next epoch. The epoch changes
+// once the message is received back from the partition.
+func (e *EpochTransport) Advance() error {
+	message := types.Message{
+		Header: types.ProtocolHeader{
+			Type:  types.Epoch,
+			Epoch: e.Epoch() + 1,
+		},
+		Identifier: types.UID(helper.GenerateUID()),
+		From:       e.partition,
+	}
+	return e.Unicast(message, e.partition)
+}
+
+// Stamp the message with the peer epoch. The message moving
+// the partition to the next epoch is already stamped.
+func (e *EpochTransport) stamp(message *types.Message) {
+	if message.Header.Type != types.Epoch {
+		message.Header.Epoch = e.Epoch()
+	}
+}
+
+// Keep polling the underlying transport while the context is
+// open, publishing only the messages from the current epochs.
+func (e *EpochTransport) poll() {
+	for {
+		select {
+		case <-e.context.Done():
+			return
+		case m, ok := <-e.Transport.Listen():
+			if !ok {
+				return
+			}
+
+			if !e.accept(m) {
+				grant(e.Transport, 1)
+				continue
+			}
+
+			select {
+			case <-e.context.Done():
+				return
+			case e.producer <- m:
+			}
+		}
+	}
+}
+
+// Verify the message epoch against the greatest epoch seen from
+// the origin peer that sent it. A message from the own partition on
+// a newer epoch moves the peer to the message epoch.
+func (e *EpochTransport) accept(m types.Message) bool {
+	e.mutex.Lock()
+	current := e.origins[m.Header.Origin]
+	if m.Header.Epoch >= current {
+		e.origins[m.Header.Origin] = m.Header.Epoch
+		moved := m.From == e.partition && m.Header.Epoch > e.epoch
+		if moved {
+			e.epoch = m.Header.Epoch
+			e.write(e.epoch)
+		}
+		e.mutex.Unlock()
+		if moved {
+			e.log.Infof("peer %s moved to epoch %d", e.name, m.Header.Epoch)
+		}
+		return true
+	}
+	e.mutex.Unlock()
+
+	atomic.AddUint64(&e.fenced, 1)
+	e.log.Warnf("rejecting %s from %s on epoch %d, current is %d", m.Identifier, m.Header.Origin, m.Header.Epoch, current)
+	e.errors.Report(&types.AsyncError{
+		Kind:       types.DroppedMessage,
+		Peer:       e.name,
+		Identifier: m.Identifier,
+		Err:        fmt.Errorf("%w: %s on epoch %d, current is %d", ErrStaleEpoch, m.Header.Origin, m.Header.Epoch, current),
+	})
+	return false
+}
+
+// Read the persisted epoch from the storage.
+func (e *EpochTransport) read() uint64 {
+	if e.storage == nil {
+		return 0
+	}
+	data, err := e.storage.Get(e.key)
+	if err != nil || len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// Persist the epoch into the storage.
+// This method should be called while holding the mutex.
+func (e *EpochTransport) write(epoch uint64) {
+	if e.storage == nil {
+		return
+	}
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, epoch)
+	if err := e.storage.Set(e.key, data); err != nil {
+		e.log.Errorf("failed persisting epoch %s. %v", string(e.key), err)
+	}
+}
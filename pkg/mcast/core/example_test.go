@@ -0,0 +1,58 @@
+package core_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"time"
+)
+
+// Issues a protocol message directly on a single peer. The unity
+// builds the same message on each write.
+func ExamplePartitionPeer_Command() {
+	conf := mcast.DefaultConfiguration("example-peer")
+	conf.Broker = core.NewMemoryBroker()
+	conf.Logger.ToggleDebug(false)
+	peer, err := core.NewPeer(mcast.NewPeerConfiguration(conf, 0, nil), conf.Logger)
+	if err != nil {
+		fmt.Println("failed creating peer.", err)
+		return
+	}
+	defer peer.Stop()
+
+	message := types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: conf.Version,
+			Type:            types.Initial,
+		},
+		Identifier: types.UID(helper.GenerateUID()),
+		Content: types.DataHolder{
+			Operation: types.Command,
+			Key:       []byte("greeting"),
+			Content:   []byte("hello"),
+		},
+		State:       types.S0,
+		Destination: []types.Partition{conf.Name},
+		From:        conf.Name,
+	}
+	select {
+	case res := <-peer.Command(context.Background(), message):
+		fmt.Println("committed:", res.Success)
+	case <-time.After(5 * time.Second):
+		fmt.Println("command timeout")
+		return
+	}
+
+	res, err := peer.FastRead(types.Request{Key: []byte("greeting")})
+	if err != nil {
+		fmt.Println("failed reading.", err)
+		return
+	}
+	fmt.Println("read:", string(res.Data))
+	// Output:
+	// committed: true
+	// read: hello
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+)
+
+// Send to every destination partition, up to the policy workers at
+// once. Every destination is attempted and the failures are returned
+// by partition on a types.MultiError, following the policy on when a
+// broadcast reaching some destinations fails.
+func FanOut(policy types.Fanout, destinations []types.Partition, send func(types.Partition) error) error {
+	mutex := &sync.Mutex{}
+	failures := make(types.MultiError)
+	attempt := func(partition types.Partition) {
+		if err := send(partition); err != nil {
+			mutex.Lock()
+			failures[partition] = err
+			mutex.Unlock()
+		}
+	}
+
+	workers := policy.Workers
+	if workers <= 0 {
+		workers = types.DefaultFanoutWorkers
+	}
+	if workers > len(destinations) {
+		workers = len(destinations)
+	}
+	if workers <= 1 {
+		for _, partition := range destinations {
+			attempt(partition)
+		}
+	} else {
+		group := &sync.WaitGroup{}
+		queue := make(chan types.Partition)
+		group.Add(workers)
+		for i := 0; i < workers; i++ {
+			InvokerInstance().Spawn(func() {
+				defer group.Done()
+				for partition := range queue {
+					attempt(partition)
+				}
+			})
+		}
+		for _, partition := range destinations {
+			queue <- partition
+		}
+		close(queue)
+		group.Wait()
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	if policy.Partial == types.FailOnAll && len(failures) < len(destinations) {
+		return nil
+	}
+	return failures
+}
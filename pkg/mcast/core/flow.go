@@ -0,0 +1,18 @@
+package core
+
+// A transport receiving from the broker only while its listener has
+// credits. The listener grants a credit back as it finishes each
+// message, so a burst waits on the broker instead of piling up in
+// front of a slow listener. The transports wrapping another grant the
+// credits to it, also for the messages they do not publish.
+type FlowControl interface {
+	// Return the credits of the messages the listener finished.
+	Grant(credits int)
+}
+
+// Return the credits to the transport, if flow controlled.
+func grant(transport Transport, credits int) {
+	if flow, ok := transport.(FlowControl); ok {
+		flow.Grant(credits)
+	}
+}
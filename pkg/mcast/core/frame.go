@@ -0,0 +1,152 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+)
+
+const (
+	// First byte of every framed message. This value does not
+	// start a valid JSON nor a valid msgpack message, so frames
+	// can be distinguished from the unframed messages.
+	frameMagic byte = 0xfe
+
+	// Current version of the frame layout.
+	FrameVersion byte = 1
+
+	// Version of the frame layout carrying the codec identifier,
+	// used when the sender negotiates the codecs.
+	FrameVersionCodec byte = 2
+
+	// Size of the fixed part of the frame: the magic byte, the
+	// version and the header length.
+	frameFixedSize = 6
+)
+
+var (
+	// Returned when the frame is not valid.
+	ErrInvalidFrame = errors.New("invalid frame")
+
+	// Returned when the frame version is not known.
+	ErrUnknownFrameVersion = errors.New("unknown frame version")
+
+	// Returned when the frame codec is not known.
+	ErrUnsupportedCodec = errors.New("unsupported codec")
+)
+
+// Encodes the message into a frame, where the header is
+// encoded separately from the payload:
+//
+//	| magic (1) | version (1) | header length (4) | header | payload |
+//
+// The payload contains the message without the header, and
+// its length is written on the header.
+//
+// When the header advertises the sender codecs and the codec is
+// identified, the codec identifier follows the version, so the
+// receiver knows how to decode both the header and the payload:
+//
+//	| magic (1) | version (1) | codec (1) | header length (4) | header | payload |
+func EncodeFrame(codec types.Codec, message types.Message) ([]byte, error) {
+	header := message.Header
+	header.Destination = message.Destination
+	message.Header = types.ProtocolHeader{}
+	payload, err := codec.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	header.ContentLength = uint32(len(payload))
+	encoded, err := codec.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, frameFixedSize, frameFixedSize+1+len(encoded)+len(payload))
+	frame[0] = frameMagic
+	frame[1] = FrameVersion
+	if identified, ok := codec.(types.IdentifiedCodec); ok && header.Codecs != 0 {
+		frame[1] = FrameVersionCodec
+		frame = append(frame[:2], byte(identified.ID()), 0, 0, 0, 0)
+	}
+	binary.BigEndian.PutUint32(frame[len(frame)-4:], uint32(len(encoded)))
+	frame = append(frame, encoded...)
+	return append(frame, payload...), nil
+}
+
+// Decodes only the header of the frame, the payload is
+// not touched. Useful to route or inspect a message.
+func DecodeHeader(codec types.Codec, data []byte) (types.ProtocolHeader, error) {
+	header, _, _, err := splitFrame(codec, data)
+	return header, err
+}
+
+// Decodes the whole message from the data. Data that is not
+// framed is decoded as a message serialized in a single
+// piece, as done by peers previous to the frame introduction.
+func DecodeFrame(codec types.Codec, data []byte) (types.Message, error) {
+	var message types.Message
+	if len(data) == 0 || data[0] != frameMagic {
+		err := codec.Unmarshal(data, &message)
+		return message, err
+	}
+
+	header, payload, codec, err := splitFrame(codec, data)
+	if err != nil {
+		return message, err
+	}
+
+	if err := codec.Unmarshal(payload, &message); err != nil {
+		return message, err
+	}
+	message.Header = header
+	return message, nil
+}
+
+// Decode the header and return the remaining payload, along
+// with the codec used by the sender.
+func splitFrame(codec types.Codec, data []byte) (types.ProtocolHeader, []byte, types.Codec, error) {
+	var header types.ProtocolHeader
+	if len(data) < frameFixedSize || data[0] != frameMagic {
+		return header, nil, nil, ErrInvalidFrame
+	}
+
+	offset := 2
+	switch data[1] {
+	case FrameVersion:
+	case FrameVersionCodec:
+		if len(data) < frameFixedSize+1 {
+			return header, nil, nil, ErrInvalidFrame
+		}
+		id := types.CodecID(data[2])
+		if identified, ok := codec.(types.IdentifiedCodec); !ok || identified.ID() != id {
+			known, ok := definition.CodecOf(id)
+			if !ok {
+				return header, nil, nil, fmt.Errorf("%w: %d", ErrUnsupportedCodec, id)
+			}
+			codec = known
+		}
+		offset++
+	default:
+		return header, nil, nil, fmt.Errorf("%w: %d", ErrUnknownFrameVersion, data[1])
+	}
+
+	start := offset + 4
+	size := int(binary.BigEndian.Uint32(data[offset:start]))
+	if size > len(data)-start {
+		return header, nil, nil, fmt.Errorf("%w: header length %d", ErrInvalidFrame, size)
+	}
+
+	if err := codec.Unmarshal(data[start:start+size], &header); err != nil {
+		return header, nil, nil, err
+	}
+
+	payload := data[start+size:]
+	if int(header.ContentLength) != len(payload) {
+		return header, nil, nil, fmt.Errorf("%w: expected %d bytes found %d", ErrInvalidFrame, header.ContentLength, len(payload))
+	}
+	return header, payload, codec, nil
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync/atomic"
+	"time"
+)
+
+// Upper bound for the time waiting between the attempts.
+const maxGatherWait = 30 * time.Second
+
+var (
+	// Returned to the request when the other partitions did not
+	// answer with their timestamps after all the retry attempts.
+	ErrGatherTimeout = errors.New("timeout gathering timestamps")
+)
+
+// Watch the timestamp exchange for the message, sending the
+// timestamp again to the partitions that did not answer in time.
+//
+// The attempts continue until the exchange completes or the peer
+// stops. Only when configured with a limit of attempts, the request
+// fails with ErrGatherTimeout once the limit is reached. The message
+// is not discarded even then, since the other partitions may already
+// have it, so it can still be delivered after the failure.
+func (p *Peer) gather(message types.Message) {
+	wait := p.timeouts.Greatest(message.Destination)
+	if _, _, height, ok := p.position(message); ok {
+		wait *= time.Duration(2 * height)
+	}
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-p.context.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if !p.gathering(message.Identifier) {
+			return
+		}
+
+		missing := p.received.Missing(message.Identifier, p.retirement.Participants(message.Destination))
+		if len(missing) == 0 {
+			return
+		}
+
+		atomic.AddUint64(p.timedOut, 1)
+		p.log.Warnf("timeout gathering %s from %v, attempt %d", message.Identifier, missing, attempt)
+		if attempt == p.configuration.Retry.Attempts+1 && p.configuration.Retry.Attempts > 0 {
+			res := types.Response{
+				Success:    false,
+				Identifier: message.Identifier,
+				Failure:    ErrGatherTimeout,
+			}
+			p.report(types.GatherFailure, message.Identifier, ErrGatherTimeout)
+			p.notify(message.Identifier, res)
+			if len(message.Header.ReplyTo) > 0 {
+				p.reply(message, res)
+			}
+		}
+
+		p.resend(message, missing)
+		if p.configuration.Retry.Backoff > 1 {
+			wait = time.Duration(float64(wait) * p.configuration.Retry.Backoff)
+		}
+		if wait > maxGatherWait {
+			wait = maxGatherWait
+		}
+	}
+}
+
+// Send the message timestamp again to the given partitions.
+func (p *Peer) resend(message types.Message, partitions []types.Partition) {
+	message.Header.Type = types.External
+	message.From = p.configuration.Partition
+	for _, partition := range partitions {
+		p.unicast(message, partition)
+	}
+}
+
+// Verify if the message is still waiting for the timestamps.
+func (p *Peer) gathering(uid types.UID) bool {
+	value := p.rqueue.GetIfExists(string(uid))
+	if value == nil {
+		return false
+	}
+	return value.(types.Message).State == types.S1
+}
@@ -0,0 +1,26 @@
+package core
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+var (
+	// Returned when the peer is stopped.
+	ErrPeerStopped = errors.New("peer stopped")
+
+	// Returned when the transport closed the listener, so the
+	// peer does not receive the messages anymore.
+	ErrTransportClosed = errors.New("transport closed")
+)
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Healthy() error {
+	if p.context.Err() != nil {
+		return ErrPeerStopped
+	}
+	if atomic.LoadInt32(p.closed) == 1 {
+		return ErrTransportClosed
+	}
+	return nil
+}
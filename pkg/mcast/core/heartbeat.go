@@ -0,0 +1,70 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync/atomic"
+	"time"
+)
+
+// Multicast a no-op on each heartbeat interval, while messages are
+// pending and the peer received nothing during the interval.
+func (p *Peer) heartbeat() {
+	ticker := time.NewTicker(p.configuration.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.context.Done():
+			return
+		case now := <-ticker.C:
+			heard := time.Unix(0, atomic.LoadInt64(p.heard))
+			if now.Sub(heard) >= p.configuration.Heartbeat {
+				p.beat()
+			}
+		}
+	}
+}
+
+// Multicast the no-op to the own partition and the partitions of
+// every pending message. The no-op is ordered as a local message,
+// so it does not delay the pending messages, and is exchanged with
+// the other partitions as any message, processing their queues.
+// While a no-op is pending no other is sent, so the no-ops do not
+// pile up when a partition is unreachable.
+func (p *Peer) beat() {
+	pending := p.rqueue.Pending()
+	if len(pending) == 0 {
+		return
+	}
+
+	destination := []types.Partition{p.configuration.Partition}
+	included := map[types.Partition]bool{p.configuration.Partition: true}
+	for _, message := range pending {
+		if message.Header.Flags.Has(types.FlagNoop) {
+			return
+		}
+		for _, partition := range message.Destination {
+			if !included[partition] && !p.retirement.Retired(partition) {
+				included[partition] = true
+				destination = append(destination, partition)
+			}
+		}
+	}
+
+	noop := types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: p.configuration.Version,
+			Type:            types.Initial,
+			Flags:           types.FlagNoop,
+			Consistency:     types.ConsistencyLocal,
+		},
+		Identifier:  types.UID(helper.GenerateUID()),
+		State:       types.S0,
+		Destination: destination,
+		From:        p.configuration.Partition,
+	}
+	p.log.Debugf("peer %s heartbeat %s to %v", p.configuration.Name, noop.Identifier, destination)
+	if err := p.transport.Broadcast(noop); err != nil {
+		p.log.Warnf("peer %s failed sending heartbeat. %v", p.configuration.Name, err)
+	}
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+)
+
+// Boost the pending messages conflicting with the urgent message,
+// sending their timestamps again to the partitions that did not
+// answer yet. The urgent message is delivered after the conflicting
+// messages with a timestamp not greater, so a neglected exchange
+// would delay the urgent message until its retransmission timeout.
+func (p *Peer) inherit(message types.Message) {
+	if !message.Header.Flags.Has(types.FlagUrgent) || !p.configuration.Features.Enabled(types.FeaturePriorityInheritance) {
+		return
+	}
+
+	boosted := 0
+	for _, pending := range p.rqueue.Pending() {
+		if pending.Identifier == message.Identifier || pending.State != types.S1 || pending.Timestamp > message.Timestamp {
+			continue
+		}
+		if !p.conflict.Conflict(message, []types.Message{pending}) {
+			continue
+		}
+
+		missing := p.received.Missing(pending.Identifier, p.retirement.Participants(pending.Destination))
+		if len(missing) == 0 {
+			continue
+		}
+		p.log.Debugf("peer %s boosting %s to %v for %s", p.configuration.Name, pending.Identifier, missing, message.Identifier)
+		pending := pending
+		p.invoker.Spawn(func() {
+			p.resend(pending, missing)
+		})
+		boosted++
+	}
+	if boosted > 0 {
+		p.stats.Boosted(boosted)
+	}
+}
@@ -1,6 +1,9 @@
 package core
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 var (
 	// Ensure thread safety while creating a new Invoker.
@@ -19,9 +22,14 @@ var (
 type Invoker interface {
 	// Spawn a new goroutine and manage through the SyncGroup.
 	// This is used to ensure that go routines do not leak.
+	// A panic on the goroutine is recovered and logged.
 	Spawn(func())
 
-	// Stop the invoker, after this any invoked go routine
+	// Spawn a critical loop that is restarted with backoff
+	// after a panic, until it returns or the context is done.
+	Supervise(ctx context.Context, name string, f func())
+
+	// Stop the invoker, while stopping any invoked go routine
 	// will panic.
 	Stop()
 }
@@ -68,16 +76,29 @@ func (c *SingletonInvoker) Spawn(f func()) {
 	c.group.Add(1)
 	go func() {
 		defer c.group.Done()
-		f()
+		Protect("spawned routine", f)
 	}()
 }
 
+// Spawn the loop restarting it after a panic.
+func (c *SingletonInvoker) Supervise(ctx context.Context, name string, f func()) {
+	c.Spawn(func() {
+		Supervise(ctx, name, f)
+	})
+}
+
 // Blocks while waiting for go routines to stop.
-// This will set the working mode to off, so after
-// this is called any spawned go routine will panic.
+// This will set the working mode to off, so while
+// waiting any spawned go routine will panic. Once every
+// routine stopped the invoker is available again, so the
+// process can create a new unity after a shutdown.
 func (c *SingletonInvoker) Stop() {
 	c.mutex.Lock()
 	c.working = false
 	c.mutex.Unlock()
 	c.group.Wait()
+
+	c.mutex.Lock()
+	c.working = true
+	c.mutex.Unlock()
 }
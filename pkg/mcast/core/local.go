@@ -0,0 +1,68 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+)
+
+// Returned on local mode when a message is addressed to
+// a partition other than the partition of the peer.
+var ErrNotLocal = errors.New("destination outside the local partition")
+
+// On local mode, address the message to the partition of the peer
+// when the destination is empty, and fail a message addressed to
+// any other partition.
+func (p *Peer) confine(message types.Message) (types.Message, error) {
+	if !p.configuration.Local {
+		return message, nil
+	}
+	if len(message.Destination) == 0 {
+		message.Destination = []types.Partition{p.configuration.Partition}
+		return message, nil
+	}
+	if !p.confined(message) {
+		return message, fmt.Errorf("%w: %v", ErrNotLocal, message.Destination)
+	}
+	return message, nil
+}
+
+// Verify if the message is addressed only to the partition of the peer.
+func (p *Peer) confined(message types.Message) bool {
+	for _, partition := range message.Destination {
+		if partition != p.configuration.Partition {
+			return false
+		}
+	}
+	return len(message.Destination) > 0
+}
+
+// On local mode, drop the messages the partition does not handle. A
+// new message to other partitions is rejected and the timestamps of
+// other partitions are ignored, since every message goes from S0 to
+// S3 without the exchange.
+func (p *Peer) foreign(message types.Message) bool {
+	if !p.configuration.Local {
+		return false
+	}
+	header := message.Extract()
+	switch {
+	case header.Type == types.External:
+		p.log.Warnf("peer %s on local mode ignoring timestamp of %s from %s", p.configuration.Name, message.Identifier, message.From)
+		return true
+	case header.Type != types.Initial || message.State != types.S0 || p.confined(message):
+		return false
+	}
+
+	err := fmt.Errorf("%w: %v", ErrNotLocal, message.Destination)
+	p.log.Warnf("peer %s dropping %s. %v", p.configuration.Name, message.Identifier, err)
+	p.report(types.DroppedMessage, message.Identifier, err)
+	res := types.Response{Identifier: message.Identifier, Failure: err}
+	p.notify(message.Identifier, res)
+	if len(header.ReplyTo) > 0 {
+		p.invoker.Spawn(func() {
+			p.reply(message, res)
+		})
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+)
+
+// Returned when the destination holds a partition not known
+// on the membership.
+var ErrUnknownPartition = errors.New("unknown partition")
+
+// Verify the destination of the message against the membership,
+// the peer own partition is always known.
+func (p *Peer) admit(message types.Message) error {
+	for _, partition := range message.Destination {
+		if partition != p.configuration.Partition && !p.configuration.Membership.Known(partition) {
+			return fmt.Errorf("%w: %s", ErrUnknownPartition, partition)
+		}
+	}
+	return nil
+}
+
+// When verifying on receive, drops the new messages to a partition
+// not known, failing the request on the peer and on the client
+// waiting for the reply.
+func (p *Peer) unknown(message types.Message) bool {
+	header := message.Extract()
+	if p.configuration.Membership.Check != types.CheckOnReceive || header.Type != types.Initial || message.State != types.S0 {
+		return false
+	}
+	err := p.admit(message)
+	if err == nil {
+		return false
+	}
+
+	p.log.Warnf("peer %s dropping %s. %v", p.configuration.Name, message.Identifier, err)
+	p.report(types.DroppedMessage, message.Identifier, err)
+	res := types.Response{Identifier: message.Identifier, Failure: err}
+	p.notify(message.Identifier, res)
+	if len(header.ReplyTo) > 0 {
+		p.invoker.Spawn(func() {
+			p.reply(message, res)
+		})
+	}
+	return true
+}
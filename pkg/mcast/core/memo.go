@@ -3,6 +3,7 @@ package core
 import (
 	"github.com/jabolina/go-mcast/pkg/mcast/types"
 	"sync"
+	"time"
 )
 
 // An exchange object to be used when holding information
@@ -23,12 +24,20 @@ type Memo struct {
 	// Holds information as serialized values for a
 	// unique key.
 	values map[types.UID][]exchanged
+
+	// When each key last received a value.
+	touched map[types.UID]time.Time
+
+	// The peers that voted for each key.
+	voters map[types.UID]map[string]bool
 }
 
 func NewMemo() *Memo {
 	return &Memo{
-		mutex:  &sync.Mutex{},
-		values: make(map[types.UID][]exchanged),
+		mutex:   &sync.Mutex{},
+		values:  make(map[types.UID][]exchanged),
+		touched: make(map[types.UID]time.Time),
+		voters:  make(map[types.UID]map[string]bool),
 	}
 }
 
@@ -38,36 +47,52 @@ func NewMemo() *Memo {
 // voted for a timestamp than the vote can be ignored,
 // since is needed only a single peer from each partition
 // to send the timestamp.
+//
+// The insert is idempotent: a partition voting again, as the
+// other peers of the partition or a retransmission, keeps the
+// greatest timestamp voted by the partition, so the votes can
+// arrive in any order and any number of times.
 func (m *Memo) Insert(key types.UID, from types.Partition, value uint64) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	_, exists := m.values[key]
-	if !exists {
-		m.values[key] = []exchanged{
-			{
-				from:      from,
-				timestamp: value,
-			},
-		}
-	} else {
-		skip := false
-		for _, e := range m.values[key] {
-			if e.from == from {
-				skip = true
-				if e.timestamp < value {
-					e.timestamp = value
-				}
-				break
-			}
-		}
+	m.insert(key, from, value)
+}
 
-		if !skip {
-			m.values[key] = append(m.values[key], exchanged{
-				from:      from,
-				timestamp: value,
-			})
+// Insert the vote sent by the origin peer of the partition.
+// Returns true if the same peer already voted for the key, as
+// when the vote is retransmitted. The votes of the other peers
+// of the partition are not repeated votes.
+func (m *Memo) Vote(key types.UID, from types.Partition, origin string, value uint64) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.insert(key, from, value)
+	voters, ok := m.voters[key]
+	if !ok {
+		voters = make(map[string]bool)
+		m.voters[key] = voters
+	}
+	repeated := voters[origin]
+	voters[origin] = true
+	return repeated
+}
+
+// Merge the vote keeping the greatest value of the partition.
+// This method should be called while holding the mutex.
+func (m *Memo) insert(key types.UID, from types.Partition, value uint64) {
+	m.touched[key] = time.Now()
+	for i, e := range m.values[key] {
+		if e.from == from {
+			if e.timestamp < value {
+				m.values[key][i].timestamp = value
+			}
+			return
 		}
 	}
+
+	m.values[key] = append(m.values[key], exchanged{
+		from:      from,
+		timestamp: value,
+	})
 }
 
 // This method will remove the information
@@ -76,6 +101,25 @@ func (m *Memo) Remove(key types.UID) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 	delete(m.values, key)
+	delete(m.touched, key)
+	delete(m.voters, key)
+}
+
+// Remove the keys that did not receive a value since the given
+// time, except the ones kept, returning how many were removed.
+func (m *Memo) Sweep(before time.Time, keep func(types.UID) bool) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	removed := 0
+	for key, at := range m.touched {
+		if at.Before(before) && !keep(key) {
+			delete(m.values, key)
+			delete(m.touched, key)
+			delete(m.voters, key)
+			removed++
+		}
+	}
+	return removed
 }
 
 // This method will return all proposed values
@@ -89,3 +133,43 @@ func (m *Memo) Read(key types.UID) []uint64 {
 	}
 	return timestamps
 }
+
+// Return the timestamps proposed by the partitions on the
+// destination, and if all of them already proposed. Values
+// from partitions outside the destination are not counted.
+func (m *Memo) Collect(key types.UID, destination []types.Partition) ([]uint64, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var timestamps []uint64
+	for _, partition := range destination {
+		for _, e := range m.values[key] {
+			if e.from == partition {
+				timestamps = append(timestamps, e.timestamp)
+				break
+			}
+		}
+	}
+	return timestamps, len(timestamps) == len(destination)
+}
+
+// Return the partitions on the destination that did not
+// send a timestamp for the message yet.
+func (m *Memo) Missing(key types.UID, destination []types.Partition) []types.Partition {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var missing []types.Partition
+	for _, partition := range destination {
+		found := false
+		for _, e := range m.values[key] {
+			if e.from == partition {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, partition)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+)
+
+// Negotiates the codec used to send messages to each partition.
+//
+// Every message sent advertises on the header the codecs the
+// sender is able to decode. The negotiator records the codecs
+// advertised by each peer, and a message is serialized with the
+// cheapest codec every peer seen on the destination partitions
+// is able to decode. While a destination did not advertise, or
+// when there is no codec in common, the configured codec is used.
+type CodecNegotiator struct {
+	// Synchronize access to the advertised codecs.
+	mutex *sync.Mutex
+
+	// Used when no codec is negotiated.
+	fallback types.Codec
+
+	// Codecs supported locally, cheapest first.
+	codecs []types.IdentifiedCodec
+
+	// The set of codecs supported locally.
+	supported types.CodecID
+
+	// The codecs advertised by each peer of each partition.
+	advertised map[types.Partition]map[string]types.CodecID
+}
+
+// Create a new negotiator for the supported codecs. Without
+// codecs the negotiation is disabled and the fallback is used.
+func NewCodecNegotiator(fallback types.Codec, codecs []types.IdentifiedCodec) *CodecNegotiator {
+	var supported types.CodecID
+	for _, codec := range codecs {
+		supported |= codec.ID()
+	}
+	return &CodecNegotiator{
+		mutex:      &sync.Mutex{},
+		fallback:   fallback,
+		codecs:     codecs,
+		supported:  supported,
+		advertised: make(map[types.Partition]map[string]types.CodecID),
+	}
+}
+
+// The set of codecs advertised on the messages sent.
+func (c *CodecNegotiator) Supported() types.CodecID {
+	return c.supported
+}
+
+// Record the codecs advertised on a received message. A peer
+// that stops advertising falls back to the configured codec.
+func (c *CodecNegotiator) Observe(message types.Message) {
+	if c.supported == 0 || message.From == "" || message.Header.Origin == "" {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	peers, ok := c.advertised[message.From]
+	if !ok {
+		peers = make(map[string]types.CodecID)
+		c.advertised[message.From] = peers
+	}
+	peers[message.Header.Origin] = message.Header.Codecs
+}
+
+// The codec to serialize a message to the given partitions.
+func (c *CodecNegotiator) Choose(destination []types.Partition) types.Codec {
+	if c.supported == 0 {
+		return c.fallback
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	common := c.supported
+	for _, partition := range destination {
+		peers := c.advertised[partition]
+		if len(peers) == 0 {
+			return c.fallback
+		}
+		for _, codecs := range peers {
+			common &= codecs
+		}
+	}
+
+	for _, codec := range c.codecs {
+		if common.Has(codec.ID()) {
+			return codec
+		}
+	}
+	return c.fallback
+}
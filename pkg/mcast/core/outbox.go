@@ -0,0 +1,346 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sort"
+	"sync"
+)
+
+// Prefix of every key the outbox writes on the storage, apart
+// from the keys written by the state machine.
+const OutboxNamespace = "\x00mcast/outbox/"
+
+// How many messages each peer holds on the outbox. The entries
+// reuse the slots once acknowledged.
+const DefaultOutboxSlots = 4096
+
+var (
+	// Returned when sending while the outbox holds as many
+	// messages as it has slots.
+	ErrOutboxFull = errors.New("outbox full")
+
+	// Returned when sending after the outbox is closed.
+	ErrOutboxClosed = errors.New("outbox closed")
+
+	// Returned when creating a peer with an outbox storage that
+	// evicts values, since an evicted message is never sent.
+	ErrEvictingOutbox = errors.New("outbox storage evicts values")
+)
+
+// A single message waiting on the outbox to be
+// acknowledged by the underlying transport.
+type OutgoingMessage struct {
+	// The outgoing message.
+	Message types.Message
+
+	// If the message was sent through an unicast, this
+	// holds the destination partition. When the message
+	// was a broadcast this value is empty.
+	Partition types.Partition
+
+	// If the message was sent as a broadcast.
+	Broadcast bool
+}
+
+// The entry persisted on an outbox slot.
+type outboxEntry struct {
+	// The position of the entry on the outbox. A slot holding
+	// other position is left from an entry already removed.
+	Sequence uint64
+
+	// If the entry was removed while not on the head.
+	Removed bool
+
+	// The message to send.
+	Outgoing OutgoingMessage
+}
+
+// A transport decorator that implements the outbox pattern.
+// Before handing a message to the underlying transport, the
+// message is persisted into the stable storage and only after
+// the transport answers the send, the message is removed.
+//
+// If the peer crashes between accepting a command and actually
+// transmitting it, the message will still be on the outbox and
+// will be sent again when the peer restarts, in the order the
+// messages were accepted. A message the transport failed to send
+// is removed as well, since the failure is returned to the caller.
+//
+// Each message is persisted on its own key under the OutboxNamespace,
+// on a fixed number of slots reused in order, and the position of
+// the oldest message is persisted apart. So each send writes only
+// the message and, once answered, the position or the removal.
+type OutboxTransport struct {
+	// Synchronize access to the persisted outbox.
+	mutex *sync.Mutex
+
+	// The underlying transport that will send the messages.
+	Transport
+
+	// Stable storage where the outbox is persisted.
+	storage types.Storage
+
+	// Prefix of the keys of the peer, since a storage can
+	// be shared between multiple peers.
+	prefix string
+
+	// How many slots the outbox has.
+	slots uint64
+
+	// Position of the oldest message not answered.
+	head uint64
+
+	// Position the next message is stored.
+	tail uint64
+
+	// The messages not answered yet, by position.
+	pending map[uint64]OutgoingMessage
+
+	// The sends in progress, waited when closing.
+	sending *sync.WaitGroup
+
+	// If the outbox is closed.
+	closed bool
+
+	// Outbox logger.
+	log types.Logger
+}
+
+// Creates a new outbox decorating the given transport. All pending
+// messages found on the storage for the given peer are replayed
+// before returning.
+func NewOutboxTransport(transport Transport, storage types.Storage, peer string, log types.Logger) *OutboxTransport {
+	o := &OutboxTransport{
+		mutex:     &sync.Mutex{},
+		Transport: transport,
+		storage:   storage,
+		prefix:    fmt.Sprintf("%s%s/", OutboxNamespace, peer),
+		slots:     DefaultOutboxSlots,
+		pending:   make(map[uint64]OutgoingMessage),
+		sending:   &sync.WaitGroup{},
+		log:       log,
+	}
+	o.load()
+	o.Replay()
+	return o
+}
+
+// Implements the Transport interface.
+// The message is persisted before the broadcast and removed
+// once the transport answered.
+func (o *OutboxTransport) Broadcast(message types.Message) error {
+	return o.send(OutgoingMessage{
+		Message:   message,
+		Broadcast: true,
+	})
+}
+
+// Implements the Transport interface.
+// The message is persisted before the unicast and removed
+// once the transport answered.
+func (o *OutboxTransport) Unicast(message types.Message, partition types.Partition) error {
+	return o.send(OutgoingMessage{
+		Message:   message,
+		Partition: partition,
+	})
+}
+
+// Implements the Transport interface.
+// Closes the underlying transport and waits for the sends in
+// progress, the messages sent after are refused.
+func (o *OutboxTransport) Close() {
+	o.mutex.Lock()
+	o.closed = true
+	o.mutex.Unlock()
+	o.Transport.Close()
+	o.sending.Wait()
+}
+
+// Implements the FlowControl interface.
+func (o *OutboxTransport) Grant(credits int) {
+	grant(o.Transport, credits)
+}
+
+// Returns all messages that are still waiting to be
+// sent by the transport, oldest first.
+func (o *OutboxTransport) Pending() []OutgoingMessage {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	positions := make([]uint64, 0, len(o.pending))
+	for position := range o.pending {
+		positions = append(positions, position)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i] < positions[j]
+	})
+
+	pending := make([]OutgoingMessage, len(positions))
+	for i, position := range positions {
+		pending[i] = o.pending[position]
+	}
+	return pending
+}
+
+// Send again all messages present on the outbox, oldest first.
+// Stops on the first message that fails, keeping it and the
+// newer ones to be sent on the next replay.
+func (o *OutboxTransport) Replay() {
+	o.mutex.Lock()
+	positions := make([]uint64, 0, len(o.pending))
+	for position := range o.pending {
+		positions = append(positions, position)
+	}
+	o.mutex.Unlock()
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i] < positions[j]
+	})
+
+	for _, position := range positions {
+		o.mutex.Lock()
+		outgoing, ok := o.pending[position]
+		o.mutex.Unlock()
+		if !ok {
+			continue
+		}
+
+		if err := o.transmit(outgoing); err != nil {
+			o.log.Errorf("failed replaying outbox message %s. %v", outgoing.Message.Identifier, err)
+			return
+		}
+		o.remove(position)
+	}
+}
+
+// Persist the message, send it through the transport and remove it.
+func (o *OutboxTransport) send(outgoing OutgoingMessage) error {
+	position, err := o.store(outgoing)
+	if err != nil {
+		return err
+	}
+	defer o.sending.Done()
+	defer o.remove(position)
+	return o.transmit(outgoing)
+}
+
+// Send the message through the underlying transport.
+func (o *OutboxTransport) transmit(outgoing OutgoingMessage) error {
+	if outgoing.Broadcast {
+		return o.Transport.Broadcast(outgoing.Message)
+	}
+	return o.Transport.Unicast(outgoing.Message, outgoing.Partition)
+}
+
+// Persist the outgoing message on the next slot and returns
+// its position on the outbox.
+func (o *OutboxTransport) store(outgoing OutgoingMessage) (uint64, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.closed {
+		return 0, ErrOutboxClosed
+	}
+	if o.tail-o.head >= o.slots {
+		return 0, fmt.Errorf("%w: %d messages", ErrOutboxFull, o.tail-o.head)
+	}
+
+	position := o.tail
+	if err := o.write(outboxEntry{Sequence: position, Outgoing: outgoing}); err != nil {
+		o.log.Errorf("failed persisting outbox message %s. %v", outgoing.Message.Identifier, err)
+		return 0, err
+	}
+	o.tail++
+	o.pending[position] = outgoing
+	o.sending.Add(1)
+	return position, nil
+}
+
+// Removes the answered entry from the outbox. When the entry is the
+// oldest the head moves past every removed entry, otherwise the slot
+// is marked as removed.
+func (o *OutboxTransport) remove(position uint64) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	outgoing, ok := o.pending[position]
+	if !ok {
+		return
+	}
+	delete(o.pending, position)
+
+	if position != o.head {
+		entry := outboxEntry{Sequence: position, Removed: true, Outgoing: outgoing}
+		if err := o.write(entry); err != nil {
+			o.log.Errorf("failed removing outbox entry %d. %v", position, err)
+		}
+		return
+	}
+
+	for o.head < o.tail {
+		if _, ok := o.pending[o.head]; ok {
+			break
+		}
+		o.head++
+	}
+	data, _ := json.Marshal(o.head)
+	if err := o.storage.Set(o.key("head"), data); err != nil {
+		o.log.Errorf("failed moving outbox head to %d. %v", o.head, err)
+	}
+}
+
+// Read the outbox from the storage, from the head until the first
+// slot not holding the next position.
+func (o *OutboxTransport) load() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if data, err := o.storage.Get(o.key("head")); err == nil && data != nil {
+		if err := json.Unmarshal(data, &o.head); err != nil {
+			o.log.Errorf("failed reading outbox head %s. %v", o.prefix, err)
+		}
+	}
+
+	for o.tail = o.head; o.tail-o.head < o.slots; o.tail++ {
+		entry, ok := o.read(o.tail)
+		if !ok {
+			break
+		}
+		if !entry.Removed {
+			o.pending[o.tail] = entry.Outgoing
+		}
+	}
+}
+
+// Read the entry on the slot of the position, if it holds the position.
+// This method should be called while holding the mutex.
+func (o *OutboxTransport) read(position uint64) (outboxEntry, bool) {
+	var entry outboxEntry
+	data, err := o.storage.Get(o.slot(position))
+	if err != nil || data == nil {
+		return entry, false
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		o.log.Errorf("failed reading outbox entry %d. %v", position, err)
+		return entry, false
+	}
+	return entry, entry.Sequence == position
+}
+
+// Write the entry on the slot of its position.
+// This method should be called while holding the mutex.
+func (o *OutboxTransport) write(entry outboxEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return o.storage.Set(o.slot(entry.Sequence), data)
+}
+
+// The key of the slot holding the position.
+func (o *OutboxTransport) slot(position uint64) []byte {
+	return o.key(fmt.Sprintf("%06d", position%o.slots))
+}
+
+// The key of the peer outbox with the given suffix.
+func (o *OutboxTransport) key(suffix string) []byte {
+	return []byte(o.prefix + suffix)
+}
@@ -3,12 +3,28 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/jabolina/go-mcast/pkg/mcast/helper"
 	"github.com/jabolina/go-mcast/pkg/mcast/types"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	// Returned when the peer panicked while stopping.
+	ErrStopPanic = errors.New("peer panicked while stopping")
+
+	// Returned when the outbox still holds messages after the
+	// peer stopped, they are sent when the peer restarts.
+	ErrUnsentMessages = errors.New("messages not sent")
+
+	// Returned when the request was not delivered before the
+	// observer deadline, the request may still be delivered.
+	ErrObserverExpired = errors.New("observer expired")
+)
+
 // When sending a message the peer must choose
 // which kind of message will be emitted.
 type emission = uint
@@ -24,16 +40,31 @@ const (
 	outer
 )
 
+// How many responses the mailbox of each observer holds.
+const observerMailbox = 1
+
+// How often the observers are verified for cancellation
+// and expiration.
+const ObserverSweep = time.Second
+
 // An observer that waits until the issued request
 // is committed by one of the peers.
 // When the response is committed it will be sent
-// back through the observer channel.
+// back through the observer mailbox, a buffered channel,
+// so the delivery never waits for a slow client.
 type observer struct {
 	// Request UID.
 	uid types.UID
 
-	// Channel to notify the response back.
+	// Mailbox to notify the response back.
 	notify chan types.Response
+
+	// The request context, the observer is removed
+	// once the context is done.
+	ctx context.Context
+
+	// When the observer expires, zero if never.
+	deadline time.Time
 }
 
 // Interface that a single peer must implement.
@@ -43,7 +74,9 @@ type PartitionPeer interface {
 	// This method does not work in the request-response model
 	// so after the message is committed onto the unity
 	// a response will be sent back through the channel.
-	Command(message types.Message) <-chan types.Response
+	// Once the context is done the peer stops waiting for
+	// the response, failing with the context error.
+	Command(ctx context.Context, message types.Message) <-chan types.Response
 
 	// A fast read directly into the storage.
 	// Since all peers will be consistent, the read
@@ -53,8 +86,134 @@ type PartitionPeer interface {
 	// that the read will be executed after the write.
 	FastRead(request types.Request) (types.Response, error)
 
-	// Stop the peer.
-	Stop()
+	// Stop committing messages into the state machine. The
+	// messages are still processed by the protocol, and the
+	// ones ready to be delivered are buffered.
+	PauseDelivery()
+
+	// Commit all buffered messages and resume the delivery.
+	ResumeDelivery()
+
+	// Load the entries directly on the state machine,
+	// without issuing protocol requests.
+	Seed(entries []*types.Entry) error
+
+	// Wait for the seed marker with the given identifier. Once
+	// the marker is delivered the delivery is paused and the
+	// returned channel is closed.
+	AwaitSeed(uid types.UID) <-chan struct{}
+
+	// Stop waiting for the seed marker, so the delivery is
+	// not paused if the marker is delivered later.
+	AbandonSeed(uid types.UID)
+
+	// Wait for the cut marker with the given identifier. Once the
+	// marker is delivered the cut of the partition is sent on the
+	// returned channel.
+	AwaitCut(uid types.UID) <-chan types.Cut
+
+	// Stop waiting for the cut marker.
+	AbandonCut(uid types.UID)
+
+	// The state of the message if it is pending on the peer.
+	Inspect(uid types.UID) (types.MessageStatus, bool)
+
+	// Copy of the messages pending on the received queue.
+	Dump() types.QueueDump
+
+	// Discard the pending message, failing the request. Returns
+	// false if the message is not pending on the peer.
+	Expire(uid types.UID) bool
+
+	// Send the message timestamp again to the partitions that
+	// did not answer. Returns false if the message is not
+	// exchanging the timestamp on the peer.
+	Resend(uid types.UID) bool
+
+	// Counters of the messages exchanged with each zone.
+	Zones() map[types.Zone]types.ZoneMetrics
+
+	// How far the clock jumped when the messages received the
+	// final timestamp, for each destination set.
+	ClockSkew() map[string]types.SkewMetrics
+
+	// The protocol counters of the peer.
+	Stats() types.PartitionStats
+
+	// How many messages were detected as missing from
+	// the transport.
+	Missed() uint64
+
+	// How the peer keeps up with the received messages.
+	Consumer() types.ConsumerMetrics
+
+	// How many times the timestamp exchange timed out
+	// waiting for other partitions.
+	GatherTimeouts() uint64
+
+	// The circuit breaker of each partition the peer sent to.
+	Breakers() map[types.Partition]types.BreakerMetrics
+
+	// How many responses were evicted because the observer
+	// mailbox was full.
+	EvictedResponses() uint64
+
+	// How many observers were removed without a response, since
+	// the request was cancelled or the observer expired.
+	AbandonedObservers() uint64
+
+	// Verify if the peer is active and delivering messages.
+	Ready() bool
+
+	// Verify if the peer can handle new requests, returning why
+	// not. A peer with the delivery paused is still healthy.
+	Healthy() error
+
+	// Failures that happened asynchronously on the peer.
+	Errors() <-chan error
+
+	// Decommission the peer partition. Once every replica stops
+	// accepting new messages and delivers the pending ones, the
+	// final marker is sent to the given partitions.
+	Decommission(notify []types.Partition) error
+
+	// Verify if the peer partition was decommissioned and
+	// the peer delivered all the pending messages.
+	Drained() bool
+
+	// Verify if the partition was decommissioned, either the
+	// peer own partition or a partition that sent the final marker.
+	Retired(partition types.Partition) bool
+
+	// The epoch of the peer partition known by the peer.
+	Epoch() uint64
+
+	// Move the peer partition to the next epoch, fencing the
+	// peers still on the current epoch.
+	AdvanceEpoch() error
+
+	// Send the progress of the request issued on the peer to
+	// the events channel, until delivered on every destination.
+	Follow(uid types.UID, destination []types.Partition, events chan<- types.Progress)
+
+	// Expect the receipts of the given number of destination
+	// partitions for the request issued on the peer.
+	ExpectReceipts(uid types.UID, required int)
+
+	// Forward the response once the expected receipts arrived,
+	// failing with ErrWriteConcern when they do not arrive in time.
+	AwaitReceipts(ctx context.Context, uid types.UID, res <-chan types.Response) <-chan types.Response
+
+	// Verify if the peer delivered the write of the token.
+	Observed(token types.CausalityToken) bool
+
+	// Wait until the peer delivers the write of the token, failing
+	// with ErrStaleRead when it is not delivered in time.
+	AwaitObserved(ctx context.Context, token types.CausalityToken) error
+
+	// Stop the peer, returning what failed while stopping.
+	// Stopping a peer already stopped does nothing.
+	Stop() error
 }
 
 // This structure defines a single peer for the protocol.
@@ -77,6 +236,9 @@ type Peer struct {
 
 	// Transport used for communication between peers
 	// and between partitions.
+	// Every outgoing message goes through the outbox, so
+	// messages are not lost if the peer crashes before
+	// the transport sends them.
 	transport Transport
 
 	// The peer clock for defining a message timestamp.
@@ -92,6 +254,26 @@ type Peer struct {
 	// right order.
 	deliver Deliverable
 
+	// Synchronize the delivery, so messages buffered while
+	// paused are committed before the new ones.
+	delivery *sync.Mutex
+
+	// If the delivery is paused.
+	paused bool
+
+	// Messages ready to be delivered while paused.
+	buffered []types.Message
+
+	// The seed markers waited, closed once delivered.
+	seeds map[types.UID]chan struct{}
+
+	// The cut markers waited, receiving the cut once delivered.
+	cuts map[types.UID]chan types.Cut
+
+	// Messages ready to be committed in batch, only
+	// when batching is enabled.
+	ready chan types.Message
+
 	// Holds the peer storage, this will be used
 	// for reads only, all writes will come from the
 	// state machine when a commit is applied.
@@ -103,10 +285,83 @@ type Peer struct {
 	// Peer logger.
 	log types.Logger
 
+	// Describes where the partitions are deployed.
+	topology types.Topology
+
+	// Messages exchanged with each zone.
+	zones *ZoneStatistics
+
+	// Clock jumps for each destination set.
+	skew *SkewStatistics
+
+	// The protocol counters.
+	stats *PartitionStatistics
+
+	// The progress of the requests issued on the peer.
+	progress *ProgressTracker
+
+	// The receipts of the requests issued with a write concern.
+	receipts *ReceiptTracker
+
+	// The writes delivered, for the reads with a causality token.
+	watermark *Watermark
+
+	// Samples the delivered contents to train the dictionaries.
+	compressor *DictionaryCompressor
+
+	// Sequence the messages to detect the ones dropped
+	// by the transport.
+	sequenced *SequencedTransport
+
+	// Fence the messages from stale epochs.
+	epochs *EpochTransport
+
+	// Fails fast the messages to unavailable partitions,
+	// nil when the breaker is disabled.
+	breaker *BreakerTransport
+
+	// Observes the listener of the underlying transport.
+	consumer ConsumerObserver
+
+	// Timeouts adapted from the round trips observed
+	// while exchanging timestamps with other partitions.
+	timeouts *RTTEstimator
+
 	// When external requests exchange timestamp,
 	// this will hold the received values.
 	received *Memo
 
+	// The timestamps exchanged through the tree.
+	tree *TreeState
+
+	// The messages dropped or expired since the last collection.
+	dropped map[types.UID]bool
+
+	// Counts the timestamp exchanges that timed out.
+	timedOut *uint64
+
+	// Counts the responses evicted from full mailboxes.
+	evicted *uint64
+
+	// Counts the observers cancelled or expired.
+	abandoned *uint64
+
+	// The decommissioned partitions, including the own partition.
+	retirement *Retirement
+
+	// Ships the delivered log to the read replicas.
+	shipper *Shipper
+
+	// Verify the state transitions, only on debug builds.
+	transitions *TransitionChecker
+
+	// Set once the transport closed the listener.
+	closed *int32
+
+	// When the peer last received a message, in
+	// nanoseconds since the epoch.
+	heard *int64
+
 	// When a message state is updated locally
 	// and need to trigger the process again.
 	updated chan types.Message
@@ -121,33 +376,108 @@ type Peer struct {
 // Creates a new peer for the given configuration and
 // start polling for new messages.
 func NewPeer(configuration *types.PeerConfiguration, log types.Logger) (PartitionPeer, error) {
-	t, err := NewTransport(configuration, log)
+	if configuration.Errors == nil {
+		configuration.Errors = types.NewErrorReporter(types.DefaultErrorBuffer)
+	}
+	for _, storage := range append([]types.Storage{configuration.Storage}, configuration.Shards...) {
+		if durable, ok := storage.(types.DurableStorage); ok {
+			durable.SetDurability(configuration.Durability)
+		}
+	}
+
+	outbox := configuration.Outbox
+	if outbox == nil {
+		outbox = configuration.Storage
+	}
+	if evicting, ok := outbox.(types.EvictingStorage); ok && evicting.Evicts() {
+		return nil, ErrEvictingOutbox
+	}
+
+	timeouts := NewRTTEstimator()
+	if !configuration.Features.Enabled(types.FeatureAdaptiveTimeouts) {
+		timeouts = NewRTTEstimatorBounded(DefaultInitialTimeout, DefaultInitialTimeout, DefaultInitialTimeout)
+	}
+	compressor := NewDictionaryCompressor(configuration.Compression, types.SubsystemLogger(log, types.SubsystemTransport))
+	reliable, err := newTransport(configuration, timeouts, compressor, types.SubsystemLogger(log, types.SubsystemTransport))
 	if err != nil {
 		return nil, err
 	}
+	sending := reliable
+	var breaker *BreakerTransport
+	if configuration.Breaker.Failures > 0 {
+		breaker = NewBreakerTransport(reliable, configuration, types.SubsystemLogger(log, types.SubsystemBreaker))
+		sending = breaker
+	}
+	sequenced := NewSequencedTransport(sending, configuration, types.SubsystemLogger(log, types.SubsystemSequence))
+	epochs := NewEpochTransport(sequenced, configuration, types.SubsystemLogger(log, types.SubsystemEpoch))
+	t := NewOutboxTransport(epochs, outbox, configuration.Name, types.SubsystemLogger(log, types.SubsystemOutbox))
 
+	topology := configuration.Topology
+	if topology == nil {
+		topology = types.StaticTopology{}
+	}
+
+	consumer, _ := reliable.(ConsumerObserver)
 	ctx, done := context.WithCancel(context.Background())
-	deliver, err := NewDeliver(ctx, log, configuration.Conflict, configuration.Storage)
+	conflict := ConsistentConflict{ConflictRelationship: configuration.Conflict}
+	storage := configuration.Storage
+	var deliver Deliverable
+	if len(configuration.Shards) > 0 {
+		storage = ShardedStorage(configuration.Shards)
+		deliver, err = NewShardedDeliver(ctx, types.SubsystemLogger(log, types.SubsystemDeliver), conflict, configuration.Shards, configuration.Parallelism)
+	} else {
+		deliver, err = NewParallelDeliver(ctx, types.SubsystemLogger(log, types.SubsystemDeliver), conflict, configuration.Storage, configuration.Parallelism)
+	}
 	if err != nil {
 		done()
 		return nil, err
 	}
 
+	labels := map[string]string{
+		types.LabelPartition: string(configuration.Partition),
+		types.LabelPeer:      configuration.Name,
+	}
 	p := &Peer{
 		mutex:         &sync.Mutex{},
 		observers:     make(map[types.UID]observer),
 		invoker:       InvokerInstance(),
 		configuration: configuration,
 		transport:     t,
+		sequenced:     sequenced,
+		breaker:       breaker,
+		epochs:        epochs,
+		consumer:      consumer,
 		clock: &ProcessClock{
 			mutex: &sync.Mutex{},
+			step:  configuration.ClockStep,
 		},
 		previousSet: NewPreviousSet(),
 		deliver:     deliver,
-		storage:     configuration.Storage,
-		conflict:    configuration.Conflict,
-		log:         log,
+		delivery:    &sync.Mutex{},
+		seeds:       make(map[types.UID]chan struct{}),
+		cuts:        make(map[types.UID]chan types.Cut),
+		storage:     storage,
+		conflict:    conflict,
+		log:         types.SubsystemLogger(log, types.SubsystemPeer),
+		topology:    topology,
+		zones:       NewZoneStatistics(),
+		skew:        NewSkewStatistics(),
+		stats:       NewPartitionStatistics(configuration.Features, configuration.Metrics, labels),
+		progress:    NewProgressTracker(),
+		receipts:    NewReceiptTracker(),
+		watermark:   NewWatermark(),
+		compressor:  compressor,
+		timeouts:    timeouts,
+		timedOut:    new(uint64),
+		evicted:     new(uint64),
+		abandoned:   new(uint64),
+		retirement:  NewRetirement(configuration.Partition),
+		shipper:     NewShipper(configuration.Name, DefaultShippingHistory),
 		received:    NewMemo(),
+		tree:        NewTreeState(),
+		dropped:     make(map[types.UID]bool),
+		heard:       new(int64),
+		closed:      new(int32),
 		updated:     make(chan types.Message),
 		context:     ctx,
 		finish:      done,
@@ -155,39 +485,80 @@ func NewPeer(configuration *types.PeerConfiguration, log types.Logger) (Partitio
 	applyDeliver := func(i interface{}) {
 		p.doDeliver(i.(types.Message))
 	}
-	p.rqueue = NewQueue(ctx, configuration.Conflict, applyDeliver)
-	p.invoker.Spawn(p.poll)
+	if debugTransitions {
+		p.transitions = NewTransitionChecker()
+	}
+	var applied Cache
+	if configuration.Dedup.Capacity > 0 {
+		applied = NewRotatingBloom(ctx, configuration.Dedup, configuration.Storage, configuration.Name, types.SubsystemLogger(log, types.SubsystemDedup))
+	}
+	p.rqueue = NewQueue(ctx, conflict, applied, applyDeliver)
+	p.invoker.Supervise(ctx, "peer "+configuration.Name, p.poll)
+	p.invoker.Supervise(ctx, "observers "+configuration.Name, p.janitor)
+	if configuration.BatchSize > 1 && configuration.Features.Enabled(types.FeatureBatching) {
+		p.ready = make(chan types.Message, configuration.BatchSize)
+		p.invoker.Supervise(ctx, "batch "+configuration.Name, p.batch)
+	}
+	if configuration.Heartbeat > 0 {
+		p.invoker.Supervise(ctx, "heartbeat "+configuration.Name, p.heartbeat)
+	}
+	if configuration.GCInterval > 0 {
+		p.invoker.Supervise(ctx, "collect "+configuration.Name, p.collect)
+	}
 	return p, nil
 }
 
 // Implements the PartitionPeer interface.
-func (p *Peer) Command(message types.Message) <-chan types.Response {
-	res := make(chan types.Response)
+// The observer is registered before broadcasting, so a response
+// committed before the broadcast returns is not lost. The observer
+// expires on the context deadline or after the ObserverTTL, what
+// happens first.
+func (p *Peer) Command(ctx context.Context, message types.Message) <-chan types.Response {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	deadline, _ := ctx.Deadline()
+	if ttl := p.configuration.ObserverTTL; ttl > 0 {
+		if limit := time.Now().Add(ttl); deadline.IsZero() || limit.Before(deadline) {
+			deadline = limit
+		}
+	}
+
+	res := make(chan types.Response, observerMailbox)
+	p.mutex.Lock()
+	p.observers[message.Identifier] = observer{
+		uid:      message.Identifier,
+		notify:   res,
+		ctx:      ctx,
+		deadline: deadline,
+	}
+	p.mutex.Unlock()
+
 	apply := func() {
-		err := p.transport.Broadcast(message)
+		message, err := p.route(message)
+		if err == nil {
+			message, err = p.confine(message)
+		}
+		if err == nil && p.configuration.Membership.Check == types.CheckOnSend {
+			err = p.admit(message)
+		}
+		if err == nil {
+			err = p.transport.Broadcast(message)
+		}
 		if err != nil {
-			finalResponse := types.Response{
+			p.notify(message.Identifier, types.Response{
 				Success:    false,
 				Identifier: message.Identifier,
 				Data:       message.Content.Content,
 				Extra:      message.Content.Extensions,
 				Failure:    err,
-			}
-
-			select {
-			case res <- finalResponse:
-			case <-time.After(100 * time.Millisecond):
-			}
+			})
 			return
 		}
 
-		p.mutex.Lock()
-		defer p.mutex.Unlock()
-		obs := observer{
-			uid:    message.Identifier,
-			notify: res,
+		for _, partition := range message.Destination {
+			p.record(types.EventSent, message, partition)
 		}
-		p.observers[message.Identifier] = obs
 	}
 	p.invoker.Spawn(apply)
 	return res
@@ -195,6 +566,12 @@ func (p *Peer) Command(message types.Message) <-chan types.Response {
 
 // Implements the PartitionPeer interface.
 func (p *Peer) FastRead(request types.Request) (types.Response, error) {
+	return ReadCommitted(p.storage, p.deliver, request)
+}
+
+// Read the value committed for the request key, or the history when
+// requested, directly from the storage without the protocol.
+func ReadCommitted(storage types.Storage, deliver Deliverable, request types.Request) (types.Response, error) {
 	res := types.Response{
 		Success:    false,
 		Identifier: "",
@@ -202,7 +579,18 @@ func (p *Peer) FastRead(request types.Request) (types.Response, error) {
 		Extra:      nil,
 		Failure:    nil,
 	}
-	data, err := p.storage.Get(request.Key)
+	if request.History != nil {
+		entries, err := deliver.History(*request.History)
+		if err != nil {
+			res.Failure = err
+			return res, err
+		}
+		res.Success = true
+		res.History = entries
+		return res, nil
+	}
+
+	data, err := storage.Get(request.Key)
 	if err != nil {
 		res.Failure = err
 		return res, err
@@ -221,18 +609,161 @@ func (p *Peer) FastRead(request types.Request) (types.Response, error) {
 }
 
 // Implements the PartitionPeer interface.
-func (p *Peer) Stop() {
-	defer func() {
-		close(p.updated)
-	}()
+func (p *Peer) PauseDelivery() {
+	p.delivery.Lock()
+	defer p.delivery.Unlock()
+	p.paused = true
+}
+
+// Implements the PartitionPeer interface.
+// All messages buffered while paused are committed before
+// any new message is delivered.
+func (p *Peer) ResumeDelivery() {
+	p.delivery.Lock()
+	defer p.delivery.Unlock()
+	p.paused = false
+	size := p.configuration.BatchSize
+	if size < 1 || p.ready == nil {
+		size = 1
+	}
+	buffered := p.buffered
+	p.buffered = nil
+	for len(buffered) > 0 && !p.paused {
+		n := size
+		if n > len(buffered) {
+			n = len(buffered)
+		}
+		p.release(buffered[:n])
+		buffered = buffered[n:]
+	}
+	p.buffered = append(p.buffered, buffered...)
+}
+
+// Implements the PartitionPeer interface.
+// The entries are applied while holding the delivery, so no
+// message is committed in the middle of the seed.
+func (p *Peer) Seed(entries []*types.Entry) error {
+	p.delivery.Lock()
+	defer p.delivery.Unlock()
+	return p.deliver.Load(entries)
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) AwaitSeed(uid types.UID) <-chan struct{} {
+	p.delivery.Lock()
+	defer p.delivery.Unlock()
+	reached := make(chan struct{})
+	p.seeds[uid] = reached
+	return reached
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) AbandonSeed(uid types.UID) {
+	p.delivery.Lock()
+	defer p.delivery.Unlock()
+	delete(p.seeds, uid)
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Zones() map[types.Zone]types.ZoneMetrics {
+	return p.zones.Snapshot()
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) ClockSkew() map[string]types.SkewMetrics {
+	return p.skew.Snapshot()
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Stats() types.PartitionStats {
+	return p.stats.Snapshot()
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Missed() uint64 {
+	return p.sequenced.Missed()
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Epoch() uint64 {
+	return p.epochs.Epoch()
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) AdvanceEpoch() error {
+	return p.epochs.Advance()
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Consumer() types.ConsumerMetrics {
+	if p.consumer == nil {
+		return types.ConsumerMetrics{}
+	}
+	return p.consumer.Consumer()
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) GatherTimeouts() uint64 {
+	return atomic.LoadUint64(p.timedOut)
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Breakers() map[types.Partition]types.BreakerMetrics {
+	if p.breaker == nil {
+		return map[types.Partition]types.BreakerMetrics{}
+	}
+	return p.breaker.Breakers()
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) EvictedResponses() uint64 {
+	return atomic.LoadUint64(p.evicted)
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) AbandonedObservers() uint64 {
+	return atomic.LoadUint64(p.abandoned)
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Ready() bool {
+	p.delivery.Lock()
+	defer p.delivery.Unlock()
+	return p.context.Err() == nil && !p.paused
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Errors() <-chan error {
+	return p.configuration.Errors.Errors()
+}
+
+// Implements the PartitionPeer interface.
+// The updated channel is not closed, since messages can still be
+// reprocessed while stopping, the poll finishes with the context.
+func (p *Peer) Stop() error {
+	if p.context.Err() != nil {
+		return nil
+	}
+
 	p.finish()
-	p.transport.Close()
+	if Protect("stop "+p.configuration.Name, p.transport.Close) {
+		return fmt.Errorf("%w: %s", ErrStopPanic, p.configuration.Name)
+	}
+	if outbox, ok := p.transport.(*OutboxTransport); ok {
+		if pending := len(outbox.Pending()); pending > 0 {
+			return fmt.Errorf("%w: %d on %s", ErrUnsentMessages, pending, p.configuration.Name)
+		}
+	}
+	return nil
 }
 
 // This method will keep polling as long as the peer
 // is active.
 // Listening for messages received from the transport
 // and processing following the protocol definition.
+// The received messages are processed in the order they
+// arrive, so every replica of the partition assigns the
+// same group timestamps.
 // If the context is cancelled, this method will stop.
 func (p *Peer) poll() {
 	defer p.log.Debugf("closing the peer %s", p.configuration.Name)
@@ -249,11 +780,11 @@ func (p *Peer) poll() {
 			})
 		case m, ok := <-p.transport.Listen():
 			if !ok {
+				atomic.StoreInt32(p.closed, 1)
 				return
 			}
-			p.invoker.Spawn(func() {
-				p.process(m)
-			})
+			p.process(m)
+			grant(p.transport, 1)
 		}
 	}
 }
@@ -268,6 +799,11 @@ func (p *Peer) poll() {
 // After processing the message, updates the value on the
 // received queue and then trigger the deliver method to
 // start commit on the state machine.
+//
+// The messages are processed one at a time, in the order received,
+// so every replica of the partition assigns the same timestamps.
+// Anything waiting on other partitions, as sending or replying,
+// runs apart, so a slow partition does not delay the processing.
 func (p Peer) process(message types.Message) {
 	header := message.Extract()
 	if header.ProtocolVersion != p.configuration.Version {
@@ -275,29 +811,100 @@ func (p Peer) process(message types.Message) {
 		return
 	}
 
-	if !p.rqueue.IsEligible(message) {
+	atomic.StoreInt64(p.heard, time.Now().UnixNano())
+	p.zones.Received(p.topology.Locate(message.From).Zone)
+	p.record(types.EventReceived, message, message.From)
+	if header.Type == types.Reply {
+		p.replied(message)
+		return
+	}
+	if !p.rqueue.IsEligible(message) || p.rejected(message) || p.unknown(message) || p.overflowed(message) || p.foreign(message) {
 		return
 	}
-	enqueue := true
+	enqueue, acknowledge := true, false
 	defer func() {
 		if enqueue {
 			p.finishMessageProcessing(&message)
 		}
+		if acknowledge {
+			p.acknowledge(message)
+		}
 	}()
 
 	switch header.Type {
 	case types.Initial:
 		p.log.Debugf("processing internal request %#v", message)
+		acknowledge = message.State == types.S0 && header.Flags.Has(types.FlagReceipt)
 		p.processInitialMessage(&message)
+		p.inherit(message)
+		if message.State == types.S1 {
+			// The timestamps of the other partitions may arrive
+			// before the message, completing the exchange already.
+			p.finishMessageProcessing(&message)
+			enqueue = p.complete(&message)
+		}
 	case types.External:
 		p.log.Debugf("processing external request %#v", message)
 		enqueue = p.exchangeTimestamp(&message)
+	case types.Retrieve:
+		p.log.Debugf("processing client read %#v", message)
+		enqueue = false
+		p.invoker.Spawn(func() {
+			p.read(message)
+		})
+	case types.Decommission:
+		enqueue = false
+		p.decommission(message)
+	case types.Epoch:
+		enqueue = false
+		p.log.Infof("peer %s received the epoch %d", p.configuration.Name, header.Epoch)
+	case types.Subscribe:
+		enqueue = false
+		p.invoker.Spawn(func() {
+			p.subscribe(message)
+		})
 	default:
 		p.log.Warnf("unknown message type %d", header.Type)
 		enqueue = false
 	}
 }
 
+// Answer the client read directly from the storage.
+func (p Peer) read(message types.Message) {
+	read := types.Request{Key: message.Content.Key}
+	if message.Header.Flags.Has(types.FlagHistory) {
+		read.History = &types.HistoryFilter{}
+		if err := json.Unmarshal(message.Content.Content, read.History); err != nil {
+			p.log.Errorf("failed decoding history filter %s. %v", message.Identifier, err)
+		}
+	}
+	res, _ := p.FastRead(read)
+	res.Identifier = message.Identifier
+	p.reply(message, res)
+}
+
+// Reject the new message once the clock is exhausted, since a
+// conflicting message would receive a timestamp not greater than the
+// ones delivered. The replicas of the partition process the messages
+// in the same order, so every replica rejects the same messages.
+func (p *Peer) overflowed(message types.Message) bool {
+	header := message.Extract()
+	if header.Type != types.Initial || message.State != types.S0 || !p.clock.Exhausted() {
+		return false
+	}
+
+	p.log.Errorf("peer %s clock exhausted at %d, dropping %s", p.configuration.Name, p.clock.Tock(), message.Identifier)
+	p.report(types.DroppedMessage, message.Identifier, ErrClockOverflow)
+	res := types.Response{Identifier: message.Identifier, Failure: ErrClockOverflow}
+	p.notify(message.Identifier, res)
+	if len(header.ReplyTo) > 0 {
+		p.invoker.Spawn(func() {
+			p.reply(message, res)
+		})
+	}
+	return true
+}
+
 // After the process GB-Deliver m, if m.State is equals to S0, firstly the
 // algorithm check if m conflict with any other message on previousSet,
 // if so, the process p increment its local clock and empty the previousSet.
@@ -318,27 +925,55 @@ func (p Peer) process(message types.Message) {
 // m.Timestamp is greater than local clock value, the clock is updated to hold
 // the received timestamp and the previousSet can be cleaned.
 func (p *Peer) processInitialMessage(message *types.Message) {
+	proposed := message.State == types.S0
+	defer func() {
+		if proposed {
+			p.progress.Report(message.Identifier, types.ProgressProposed, p.configuration.Partition, message.Timestamp)
+		}
+	}()
+
 	if message.State == types.S0 {
-		if p.conflict.Conflict(*message, p.previousSet.Snapshot()) {
-			p.clock.Tick()
+		conflict := p.conflict.Conflict(*message, p.conflicting(*message))
+		if conflict {
+			if err := p.clock.Tick(); err != nil {
+				p.log.Errorf("peer %s failed ticking for %s. %v", p.configuration.Name, message.Identifier, err)
+			}
 			p.previousSet.Clear()
 		}
+		p.stats.Proposed(len(message.Destination), conflict)
 		message.Timestamp = p.clock.Tock()
+		p.stats.Clock(message.Timestamp)
 		p.previousSet.Append(*message)
 	}
 
-	if len(message.Destination) > 1 {
+	// A message to a decommissioned partition and a single
+	// participating partition does not exchange timestamps.
+	single := len(message.Destination) == 1 ||
+		(message.State == types.S0 && len(p.retirement.Participants(message.Destination)) == 1)
+	if !single {
 		if message.State == types.S0 {
 			message.State = types.S1
 			message.Timestamp = p.clock.Tock()
 			p.received.Insert(message.Identifier, p.configuration.Partition, message.Timestamp)
-			p.send(*message, types.External, outer)
+			gathering := *message
+			tree := p.disseminate(gathering)
+			p.invoker.Spawn(func() {
+				if !tree {
+					p.send(gathering, types.External, outer)
+				}
+				p.gather(gathering)
+			})
 		} else if message.State == types.S2 {
 			message.State = types.S3
 			if message.Timestamp > p.clock.Tock() {
 				p.clock.Leap(message.Timestamp)
 				p.previousSet.Clear()
 			}
+			// A conflicting message proposed on the same clock
+			// value must be ordered after this one.
+			if message.Timestamp == p.clock.Tock() {
+				p.previousSet.Append(*message)
+			}
 		}
 	} else {
 		message.Timestamp = p.clock.Tock()
@@ -346,6 +981,23 @@ func (p *Peer) processInitialMessage(message *types.Message) {
 	}
 }
 
+// The messages the given message is evaluated against for conflicts.
+// Besides the previous set, on strict mode the messages pending on the
+// received queue are also evaluated.
+func (p *Peer) conflicting(message types.Message) []types.Message {
+	messages := p.previousSet.Snapshot()
+	if p.configuration.Strictness < types.ConflictPending {
+		return messages
+	}
+
+	for _, pending := range p.rqueue.Pending() {
+		if pending.Identifier != message.Identifier {
+			messages = append(messages, pending)
+		}
+	}
+	return messages
+}
+
 // When a message m has more than one destination group, the destination groups
 // have to exchange its timestamps to decide the final timestamp to m.
 // Thus, after receiving all other timestamp values, a temporary variable tsm is
@@ -356,19 +1008,54 @@ func (p *Peer) processInitialMessage(message *types.Message) {
 // avoided and, the state of m can jump directly to state S3 since the group local
 // clock is already bigger than tsm.
 func (p *Peer) exchangeTimestamp(message *types.Message) bool {
-	p.received.Insert(message.Identifier, message.From, message.Timestamp)
-	values := p.received.Read(message.Identifier)
-	if len(values) < len(message.Destination) {
+	p.timeouts.Received(message.Identifier, message.From, message.Header)
+	if message.Header.Echo.Peer == p.configuration.Name {
+		p.timeouts.Answered(message.From, message.Header.Echo)
+	}
+	if message.Header.Flags.Has(types.FlagSubtree) || message.Header.Flags.Has(types.FlagFinal) {
+		p.traverse(*message)
+	} else if p.received.Vote(message.Identifier, message.From, message.Header.Origin, message.Timestamp) {
+		p.stats.Duplicated()
+	}
+	return p.complete(message)
+}
+
+// Select the final timestamp, if every partition participating
+// on the message already sent its timestamp. Decommissioned
+// partitions are not waited for.
+//
+// The decision is taken once, while the message is on state S1,
+// comparing the final timestamp with the timestamp of the own
+// partition, never with the timestamp of the partition that sent
+// the message. The message is replaced by the pending version.
+func (p *Peer) complete(message *types.Message) bool {
+	value := p.rqueue.GetIfExists(string(message.Identifier))
+	if value == nil || value.(types.Message).State != types.S1 {
+		return false
+	}
+
+	pending := value.(types.Message)
+	destination := p.retirement.Participants(pending.Destination)
+	values, complete := p.received.Collect(pending.Identifier, destination)
+	if !complete {
 		return false
 	}
 
 	tsm := helper.MaxValue(values)
-	if message.Timestamp >= tsm {
-		message.State = types.S3
+	for i, partition := range destination {
+		if partition == p.configuration.Partition {
+			p.skew.Observe(pending.Destination, tsm-values[i])
+		}
+	}
+
+	if pending.Timestamp >= tsm {
+		pending.State = types.S3
 	} else {
-		message.Timestamp = tsm
-		message.State = types.S2
+		pending.Timestamp = tsm
+		pending.State = types.S2
 	}
+	*message = pending
+	p.progress.Report(message.Identifier, types.ProgressExchanged, p.configuration.Partition, message.Timestamp)
 	return true
 }
 
@@ -384,16 +1071,42 @@ func (p Peer) send(message types.Message, t types.MessageType, emission emission
 		destination = append(destination, p.configuration.Partition)
 	} else {
 		for _, partition := range message.Destination {
-			if partition != p.configuration.Partition {
+			if partition != p.configuration.Partition && !p.retirement.Retired(partition) {
 				destination = append(destination, partition)
 			}
 		}
 	}
 
-	for _, partition := range destination {
-		for err := p.transport.Unicast(message, partition); err != nil; {
-			p.log.Errorf("error unicast %s to partition %s. %v", message.Identifier, partition, err)
-		}
+	// Partitions on the same datacenter are sent first, in order,
+	// while the partitions across datacenters are sent in parallel,
+	// so a slow WAN link does not delay the other exchanges.
+	near, far := SplitByProximity(p.configuration.Location, p.topology, destination)
+	for _, partition := range near {
+		p.unicast(message, partition)
+	}
+
+	group := &sync.WaitGroup{}
+	for _, partition := range far {
+		group.Add(1)
+		target := partition
+		p.invoker.Spawn(func() {
+			defer group.Done()
+			p.unicast(message, target)
+		})
+	}
+	group.Wait()
+}
+
+// Unicast the message to the given partition.
+func (p Peer) unicast(message types.Message, partition types.Partition) {
+	p.zones.Sent(p.topology.Locate(partition).Zone)
+	p.record(types.EventSent, message, partition)
+	if message.Header.Type == types.External {
+		message.Header.Echo = p.timeouts.Echo(message.Identifier, partition)
+		message.Header.Sent = time.Now().UnixNano()
+	}
+	if err := p.transport.Unicast(message, partition); err != nil {
+		p.log.Errorf("error unicast %s to partition %s. %v", message.Identifier, partition, err)
 	}
 }
 
@@ -402,15 +1115,38 @@ func (p Peer) send(message types.Message, t types.MessageType, emission emission
 // state S0 or S2 it needs to be broadcast internally to the
 // partition.
 func (p *Peer) finishMessageProcessing(message *types.Message) {
+	if p.enqueue(*message) {
+		p.record(types.EventStateChanged, *message, "")
+		p.verifyTransition(*message)
+	}
+}
+
+// Add the message to the rqueue and returns true if the
+// message changed.
+func (p *Peer) enqueue(message types.Message) (changed bool) {
 	defer func() {
 		recover()
 	}()
 
-	if p.rqueue.Enqueue(*message) {
+	if p.rqueue.Enqueue(message) {
 		uid := message.Identifier
 		p.invoker.Spawn(func() {
 			p.reprocessMessage(uid)
 		})
+		return true
+	}
+	return false
+}
+
+// On debug builds, panics when the message changed to an illegal state.
+func (p *Peer) verifyTransition(message types.Message) {
+	if p.transitions == nil {
+		return
+	}
+
+	if err := p.transitions.Observe(message); err != nil {
+		p.log.Errorf("peer %s found violation. %v", p.configuration.Name, err)
+		panic(err)
 	}
 }
 
@@ -419,6 +1155,11 @@ func (p *Peer) finishMessageProcessing(message *types.Message) {
 // This methods receives the UID instead of the message
 // object, so this ensures that the r_queue and the
 // protocols see the same object state.
+//
+// The time waiting between each attempt adapts to the
+// round trips observed with the message destinations. Without
+// the generic delivery, the messages in S3 wait to reach the
+// head of the queue.
 func (p Peer) reprocessMessage(uid types.UID) {
 	value := p.rqueue.GetIfExists(string(uid))
 	if value == nil {
@@ -429,7 +1170,7 @@ func (p Peer) reprocessMessage(uid types.UID) {
 		select {
 		case <-p.context.Done():
 			return
-		case <-time.After(100 * time.Millisecond):
+		case <-time.After(p.timeouts.Greatest(message.Destination)):
 			p.reprocessMessage(uid)
 			return
 		case p.updated <- message:
@@ -437,8 +1178,10 @@ func (p Peer) reprocessMessage(uid types.UID) {
 		}
 	}
 
-	if message.State == types.S3 {
-		p.rqueue.GenericDeliver(message)
+	if message.State == types.S3 && p.configuration.Features.Enabled(types.FeatureGenericDelivery) {
+		if p.rqueue.GenericDeliver(message) {
+			p.stats.GenericDelivered()
+		}
 	}
 }
 
@@ -453,22 +1196,262 @@ func (p Peer) reprocessMessage(uid types.UID) {
 // contains the lowest timestamp, so the message is ready to
 // be delivered, which means, it will be committed on the
 // local peer state machine.
+//
+// If the delivery is paused the message is buffered, to
+// be committed in the same order once resumed. When batching
+// is enabled the message is handed to the batch loop.
 func (p *Peer) doDeliver(m types.Message) {
 	p.received.Remove(m.Identifier)
-	res := p.deliver.Commit(m)
-	p.invoker.Spawn(func() {
-		p.mutex.Lock()
-		defer p.mutex.Unlock()
-		obs, ok := p.observers[m.Identifier]
-		if ok {
-			select {
-			case <-time.After(150 * time.Millisecond):
-				break
-			case obs.notify <- res:
-				break
+	p.tree.Forget(m.Identifier)
+	p.timeouts.Forget(m.Identifier)
+	if p.transitions != nil {
+		p.transitions.Forget(m.Identifier)
+	}
+
+	if p.ready != nil {
+		select {
+		case p.ready <- m:
+		case <-p.context.Done():
+		}
+		return
+	}
+	p.deliverBatch([]types.Message{m})
+}
+
+// Commit the ready messages in batches. The first ready message
+// is committed with all the messages that became ready meanwhile,
+// up to the batch size, so the batches grow with the load without
+// delaying the messages when the peer is idle.
+func (p *Peer) batch() {
+	for {
+		select {
+		case <-p.context.Done():
+			return
+		case m := <-p.ready:
+			messages := []types.Message{m}
+		drain:
+			for len(messages) < p.configuration.BatchSize {
+				select {
+				case m := <-p.ready:
+					messages = append(messages, m)
+				default:
+					break drain
+				}
 			}
-			close(obs.notify)
-			delete(p.observers, obs.uid)
+			p.deliverBatch(messages)
+		}
+	}
+}
+
+// Commit the messages, or buffer them if the delivery is paused.
+func (p *Peer) deliverBatch(messages []types.Message) {
+	p.delivery.Lock()
+	defer p.delivery.Unlock()
+	if p.paused {
+		p.buffered = append(p.buffered, messages...)
+		return
+	}
+	p.release(messages)
+}
+
+// Commit the messages, skipping the heartbeat no-ops, reading the
+// state on a cut marker and stopping on a seed marker. The markers
+// are never committed. When a seed marker is waited the delivery is
+// paused and the messages after the marker are buffered, so the seed
+// is loaded on the same point of the delivery order by every peer.
+// This method should be called while holding the delivery mutex.
+func (p *Peer) release(messages []types.Message) {
+	start := 0
+	for i, m := range messages {
+		if !m.Header.Flags.Has(types.FlagSeed) && !m.Header.Flags.Has(types.FlagNoop) && !m.Header.Flags.Has(types.FlagCut) {
+			continue
+		}
+
+		if i > start {
+			p.commit(messages[start:i])
+		}
+		start = i + 1
+		if m.Header.Flags.Has(types.FlagNoop) {
+			continue
+		}
+		if m.Header.Flags.Has(types.FlagCut) {
+			p.cut(m)
+			continue
+		}
+
+		p.notify(m.Identifier, types.Response{Success: true, Identifier: m.Identifier})
+		reached, ok := p.seeds[m.Identifier]
+		if !ok {
+			continue
+		}
+		delete(p.seeds, m.Identifier)
+		close(reached)
+		p.paused = true
+		p.buffered = append(p.buffered, messages[i+1:]...)
+		return
+	}
+
+	if start < len(messages) {
+		p.commit(messages[start:])
+	}
+}
+
+// Commit the messages on the state machine and notify
+// the observers about the responses.
+// This method should be called while holding the delivery mutex.
+func (p *Peer) commit(messages []types.Message) {
+	responses := p.deliver.CommitBatch(messages)
+	p.stats.Delivered(len(messages))
+	p.ship(messages, responses)
+	for i := range messages {
+		m, res := messages[i], responses[i]
+		p.record(types.EventDelivered, m, "")
+		p.watermark.Advance(m.Content.Key, m.Timestamp)
+		p.compressor.Sample(m.Content.Content)
+		if res.Success {
+			res.Token = &types.CausalityToken{Key: m.Content.Key, Timestamp: m.Timestamp}
+		}
+		if res.Failure != nil {
+			p.report(types.CommitFailure, m.Identifier, res.Failure)
+		}
+		if len(m.Header.ReplyTo) > 0 {
+			p.invoker.Spawn(func() {
+				p.reply(m, res)
+			})
+		}
+		p.notify(m.Identifier, res)
+		if res.Failure == nil {
+			p.progress.Report(m.Identifier, types.ProgressDelivered, p.configuration.Partition, m.Timestamp)
+			p.progress.Delivered(m.Identifier, p.configuration.Partition, m.Timestamp)
 		}
+		if p.configuration.OnDeliver != nil {
+			p.configuration.OnDeliver(types.Delivered{Message: m, Response: res})
+		}
+	}
+}
+
+// Record and trace the protocol event of the message, if enabled.
+func (p *Peer) record(kind types.EventKind, message types.Message, partition types.Partition) {
+	event := types.Event{
+		Kind:      kind,
+		Peer:      p.configuration.Name,
+		Type:      message.Header.Type,
+		State:     message.State,
+		Timestamp: message.Timestamp,
+		Partition: partition,
+		Key:       message.Content.Key,
+	}
+	p.configuration.Recorder.Record(message.Identifier, event)
+	p.configuration.Tracer.Trace(p.configuration.Partition, message.Identifier, event)
+}
+
+// Report an asynchronous failure of the peer.
+func (p *Peer) report(kind types.FailureKind, uid types.UID, err error) {
+	if kind == types.DroppedMessage && len(uid) > 0 {
+		p.mutex.Lock()
+		p.dropped[uid] = true
+		p.mutex.Unlock()
+	}
+	p.configuration.Errors.Report(&types.AsyncError{
+		Kind:       kind,
+		Peer:       p.configuration.Name,
+		Identifier: uid,
+		Err:        err,
 	})
 }
+
+// Notify the observer waiting for the message, if any.
+// This never blocks, when the mailbox is full the response
+// is evicted and counted.
+func (p *Peer) notify(uid types.UID, res types.Response) {
+	p.mutex.Lock()
+	obs, ok := p.observers[uid]
+	delete(p.observers, uid)
+	p.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case obs.notify <- res:
+	default:
+		atomic.AddUint64(p.evicted, 1)
+	}
+	close(obs.notify)
+}
+
+// Remove the observers of the requests cancelled or past their
+// deadline, failing them, until the peer stops.
+func (p *Peer) janitor() {
+	ticker := time.NewTicker(ObserverSweep)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.context.Done():
+			return
+		case now := <-ticker.C:
+			p.sweep(now)
+		}
+	}
+}
+
+// Fail the observers of the requests cancelled or expired.
+func (p *Peer) sweep(now time.Time) {
+	failures := make(map[types.UID]error)
+	p.mutex.Lock()
+	for uid, obs := range p.observers {
+		if err := obs.ctx.Err(); err != nil {
+			failures[uid] = err
+		} else if !obs.deadline.IsZero() && now.After(obs.deadline) {
+			failures[uid] = ErrObserverExpired
+		}
+	}
+	p.mutex.Unlock()
+
+	for uid, err := range failures {
+		p.log.Debugf("removing observer of %s. %v", uid, err)
+		atomic.AddUint64(p.abandoned, 1)
+		p.notify(uid, types.Response{Identifier: uid, Failure: err})
+	}
+}
+
+// Sends the response back to the client that issued the
+// request. Since every peer on every destination will send
+// a reply, the client is responsible for ignoring the
+// duplicated responses.
+func (p *Peer) reply(message types.Message, res types.Response) {
+	reply := types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: p.configuration.Version,
+			Type:            types.Reply,
+		},
+		Identifier: message.Identifier,
+		Content: types.DataHolder{
+			Operation:  message.Content.Operation,
+			Key:        message.Content.Key,
+			Content:    res.Data,
+			Extensions: res.Extra,
+		},
+		State:     message.State,
+		Timestamp: message.Timestamp,
+		From:      p.configuration.Partition,
+	}
+	if message.Header.Flags.Has(types.FlagHistory) && res.Success {
+		history, err := json.Marshal(res.History)
+		if err != nil {
+			res.Failure = err
+		}
+		reply.Header.Flags |= types.FlagHistory
+		reply.Content.Content = history
+	}
+
+	if res.Failure != nil {
+		reply.Header.Failure = res.Failure.Error()
+	} else if !res.Success {
+		reply.Header.Failure = "request failed"
+	}
+
+	if err := p.transport.Unicast(reply, message.Header.ReplyTo); err != nil {
+		p.log.Errorf("failed replying %s to %s. %v", message.Identifier, message.Header.ReplyTo, err)
+	}
+}
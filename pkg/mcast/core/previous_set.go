@@ -18,6 +18,10 @@ type PreviousSet interface {
 	// Creates an snapshot of the messages present
 	// on the previous set and returns as a slice.
 	Snapshot() []types.Message
+
+	// Remove the messages matching the predicate,
+	// returning how many were removed.
+	Sweep(remove func(types.Message) bool) int
 }
 
 type ConcurrentPreviousSet struct {
@@ -66,3 +70,18 @@ func (c *ConcurrentPreviousSet) Snapshot() []types.Message {
 	}
 	return messages
 }
+
+// Implements the PreviousSet interface.
+func (c *ConcurrentPreviousSet) Sweep(remove func(types.Message) bool) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	removed := 0
+	for uid, message := range c.values {
+		if remove(message) {
+			delete(c.values, uid)
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"time"
+)
+
+// How long the progress of a request is followed. A request
+// not delivered on every partition until then is forgotten.
+const ProgressMemory = 10 * time.Minute
+
+// The progress of a single request.
+type following struct {
+	// Where the progress is sent.
+	events chan<- types.Progress
+
+	// The request destination.
+	destination []types.Partition
+
+	// The partitions that delivered the request.
+	delivered map[types.Partition]bool
+
+	// The stages already sent.
+	reported map[types.ProgressStage]bool
+
+	// When the request started being followed.
+	since time.Time
+}
+
+// Follows the progress of the requests issued on the peer,
+// sending each stage once to the request progress channel.
+// The channel is never blocked, when it is full the event
+// is dropped, and it is never closed, since it belongs to
+// the request issuer.
+type ProgressTracker struct {
+	// Synchronize access to the requests.
+	mutex *sync.Mutex
+
+	// The requests followed.
+	requests map[types.UID]*following
+
+	// When the old requests were last removed.
+	swept time.Time
+}
+
+// Creates a new tracker without requests.
+func NewProgressTracker() *ProgressTracker {
+	return &ProgressTracker{
+		mutex:    &sync.Mutex{},
+		requests: make(map[types.UID]*following),
+		swept:    time.Now(),
+	}
+}
+
+// Start following the request with the given destination.
+func (t *ProgressTracker) Follow(uid types.UID, destination []types.Partition, events chan<- types.Progress) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.sweep()
+	t.requests[uid] = &following{
+		events:      events,
+		destination: destination,
+		delivered:   make(map[types.Partition]bool),
+		reported:    make(map[types.ProgressStage]bool),
+		since:       time.Now(),
+	}
+}
+
+// Report the stage of the request, if followed and the
+// stage was not reported yet.
+func (t *ProgressTracker) Report(uid types.UID, stage types.ProgressStage, partition types.Partition, timestamp uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if f, ok := t.requests[uid]; ok {
+		f.send(uid, stage, partition, timestamp)
+	}
+}
+
+// The request was delivered on the partition. Once delivered on
+// every destination the last stage is sent and the request is
+// no longer followed.
+func (t *ProgressTracker) Delivered(uid types.UID, partition types.Partition, timestamp uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	f, ok := t.requests[uid]
+	if !ok {
+		return
+	}
+
+	f.delivered[partition] = true
+	for _, destination := range f.destination {
+		if !f.delivered[destination] {
+			return
+		}
+	}
+	f.send(uid, types.ProgressDeliveredEverywhere, partition, timestamp)
+	delete(t.requests, uid)
+}
+
+// Remove the requests followed for longer than the memory.
+// This method should be called while holding the mutex.
+func (t *ProgressTracker) sweep() {
+	now := time.Now()
+	if now.Sub(t.swept) < time.Minute {
+		return
+	}
+	t.swept = now
+	for uid, f := range t.requests {
+		if now.Sub(f.since) > ProgressMemory {
+			delete(t.requests, uid)
+		}
+	}
+}
+
+func (f *following) send(uid types.UID, stage types.ProgressStage, partition types.Partition, timestamp uint64) {
+	if f.reported[stage] {
+		return
+	}
+	f.reported[stage] = true
+	select {
+	case f.events <- types.Progress{
+		Identifier: uid,
+		Stage:      stage,
+		Partition:  partition,
+		Timestamp:  timestamp,
+		Time:       time.Now(),
+	}:
+	default:
+	}
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) Follow(uid types.UID, destination []types.Partition, events chan<- types.Progress) {
+	p.progress.Follow(uid, destination, events)
+}
+
+// A partition replied after committing a request issued on
+// the peer, sent only when following the request progress, or
+// acknowledged enqueueing a request with a write concern.
+func (p *Peer) replied(message types.Message) {
+	if message.Header.Flags.Has(types.FlagReceipt) {
+		p.receipts.Received(message.Identifier, message.From)
+		return
+	}
+	if message.State == types.S3 && len(message.Header.Failure) == 0 {
+		p.progress.Delivered(message.Identifier, message.From, message.Timestamp)
+	}
+}
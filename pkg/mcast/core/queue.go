@@ -23,14 +23,18 @@ type Queue interface {
 	// Get the element if it exists on the memory.
 	GetIfExists(id string) interface{}
 
+	// Copy of the messages pending on the queue.
+	Pending() []types.Message
+
 	// This method is what turns the protocol into its generic
 	// form, where not all messages are sorted.
 	// This will verify if the given message conflict with other
 	// messages and will delivery if possible.
 	//
 	// A message will only be able to be delivered if is on state
-	// S3 and do not conflict with any other messages.
-	GenericDeliver(interface{})
+	// S3 and do not conflict with any other messages. Returns true
+	// if the message was delivered.
+	GenericDeliver(interface{}) bool
 
 	// Verify if the given interface is eligible to be added
 	// to the queue.
@@ -89,21 +93,26 @@ type RQueue struct {
 	deliver func(interface{})
 }
 
-// Create a new queue data structure.
-func NewQueue(ctx context.Context, conflict types.ConflictRelationship, f func(interface{})) Queue {
+// Create a new queue data structure. The applied cache remembers
+// the messages already delivered, if nil the messages are kept
+// in memory for a few minutes.
+func NewQueue(ctx context.Context, conflict types.ConflictRelationship, applied Cache, f func(interface{})) Queue {
+	if applied == nil {
+		applied = NewTtlCache(ctx)
+	}
 	headChannel := make(chan types.Message)
 	r := &RQueue{
 		ctx:        ctx,
 		mutex:      &sync.Mutex{},
 		conflict:   conflict,
-		applied:    NewTtlCache(ctx),
+		applied:    applied,
 		headChange: headChannel,
 		deliver:    f,
 		set: NewPriorityQueue(headChannel, func(m types.Message) bool {
 			return m.State == types.S3
 		}),
 	}
-	InvokerInstance().Spawn(r.poll)
+	InvokerInstance().Supervise(ctx, "received queue", r.poll)
 	return r
 }
 
@@ -118,14 +127,17 @@ func (r *RQueue) IsEligible(i interface{}) bool {
 	return !r.applied.Contains(string(m.Identifier))
 }
 
+// Deliver the message notified on the head, if not applied yet.
+// The message is removed by its identifier, since the head may
+// have changed after the notification.
 func (r RQueue) verifyAndDeliverHead(message types.Message) {
-	if r.IsEligible(message) {
-		r.mutex.Lock()
-		defer r.mutex.Unlock()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.applied.Contains(string(message.Identifier)) {
 		r.applied.Set(string(message.Identifier))
 		r.deliver(message)
 	}
-	r.set.Pop()
+	r.set.Remove(message.Identifier)
 }
 
 // This method will be polling while the application is
@@ -215,9 +227,19 @@ func (r *RQueue) GetIfExists(id string) interface{} {
 }
 
 // Implements the Queue interface.
-func (r *RQueue) GenericDeliver(i interface{}) {
+func (r *RQueue) Pending() []types.Message {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	values := r.set.Values()
+	pending := make([]types.Message, len(values))
+	copy(pending, values)
+	return pending
+}
+
+// Implements the Queue interface.
+func (r *RQueue) GenericDeliver(i interface{}) bool {
 	if !r.IsEligible(i) {
-		return
+		return false
 	}
 
 	message := i.(types.Message)
@@ -242,5 +264,7 @@ func (r *RQueue) GenericDeliver(i interface{}) {
 	if !r.conflict.Conflict(message, messages) {
 		r.Dequeue(message)
 		r.deliver(message)
+		return true
 	}
+	return false
 }
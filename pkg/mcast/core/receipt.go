@@ -0,0 +1,170 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"time"
+)
+
+// Returned when fewer destination partitions than required
+// acknowledged enqueueing the request in time.
+var ErrWriteConcern = errors.New("write concern not satisfied")
+
+// The receipts expected for a single request.
+type expected struct {
+	// How many partitions must acknowledge.
+	required int
+
+	// The partitions that acknowledged.
+	partitions map[types.Partition]bool
+
+	// Closed once enough partitions acknowledged.
+	done chan struct{}
+}
+
+// Tracks the receipts of the requests with a write concern. Each
+// destination partition acknowledges once it enqueued the request,
+// and a request waits for the required number of partitions before
+// its response is released.
+type ReceiptTracker struct {
+	// Synchronize access to the requests.
+	mutex *sync.Mutex
+
+	// The requests waiting for receipts.
+	requests map[types.UID]*expected
+}
+
+// Creates a new tracker without requests.
+func NewReceiptTracker() *ReceiptTracker {
+	return &ReceiptTracker{
+		mutex:    &sync.Mutex{},
+		requests: make(map[types.UID]*expected),
+	}
+}
+
+// Expect the receipts of the given number of partitions for the
+// request. Must be called before the request is sent, so no
+// receipt is missed.
+func (t *ReceiptTracker) Expect(uid types.UID, required int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.requests[uid] = &expected{
+		required:   required,
+		partitions: make(map[types.Partition]bool),
+		done:       make(chan struct{}),
+	}
+}
+
+// The partition acknowledged enqueueing the request. The receipts
+// of each partition are counted once, since every peer of the
+// partition sends the receipt.
+func (t *ReceiptTracker) Received(uid types.UID, partition types.Partition) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	e, ok := t.requests[uid]
+	if !ok || e.partitions[partition] {
+		return
+	}
+	e.partitions[partition] = true
+	if len(e.partitions) == e.required {
+		close(e.done)
+	}
+}
+
+// Forward the response once the receipts of the request arrived.
+// When the receipts do not arrive before the context is done or
+// the timeout, the response fails with ErrWriteConcern. A zero
+// timeout waits only for the context.
+func (t *ReceiptTracker) Await(ctx context.Context, uid types.UID, timeout time.Duration, res <-chan types.Response) <-chan types.Response {
+	t.mutex.Lock()
+	e, ok := t.requests[uid]
+	t.mutex.Unlock()
+	if !ok {
+		return res
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	forward := make(chan types.Response, 1)
+	InvokerInstance().Spawn(func() {
+		defer close(forward)
+		defer t.forget(uid)
+		var expired <-chan time.Time
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			forward <- t.unsatisfied(uid, e, ctx.Err())
+			return
+		case <-expired:
+			forward <- t.unsatisfied(uid, e, nil)
+			return
+		}
+		if r, ok := <-res; ok {
+			forward <- r
+		}
+	})
+	return forward
+}
+
+// The failure of a request without enough receipts.
+func (t *ReceiptTracker) unsatisfied(uid types.UID, e *expected, cause error) types.Response {
+	t.mutex.Lock()
+	acknowledged := len(e.partitions)
+	t.mutex.Unlock()
+	err := fmt.Errorf("%w: %d of %d partitions acknowledged", ErrWriteConcern, acknowledged, e.required)
+	if cause != nil {
+		err = fmt.Errorf("%w: %v", err, cause)
+	}
+	return types.Response{Identifier: uid, Failure: err}
+}
+
+// Stop tracking the receipts of the request.
+func (t *ReceiptTracker) forget(uid types.UID) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.requests, uid)
+}
+
+// Implements the PartitionPeer interface.
+func (p *Peer) ExpectReceipts(uid types.UID, required int) {
+	p.receipts.Expect(uid, required)
+}
+
+// Implements the PartitionPeer interface.
+// The receipts are awaited at most the ObserverTTL.
+func (p *Peer) AwaitReceipts(ctx context.Context, uid types.UID, res <-chan types.Response) <-chan types.Response {
+	return p.receipts.Await(ctx, uid, p.configuration.ObserverTTL, res)
+}
+
+// Acknowledge the request was enqueued on the peer partition,
+// sending the receipt to the partition waiting for it.
+func (p *Peer) acknowledge(message types.Message) {
+	if len(message.Header.ReplyTo) == 0 {
+		return
+	}
+	receipt := types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: p.configuration.Version,
+			Type:            types.Reply,
+			Flags:           types.FlagReceipt,
+		},
+		Identifier: message.Identifier,
+		State:      types.S0,
+		From:       p.configuration.Partition,
+	}
+	p.invoker.Spawn(func() {
+		if err := p.transport.Unicast(receipt, message.Header.ReplyTo); err != nil {
+			p.log.Errorf("failed acknowledging %s to %s. %v", message.Identifier, message.Header.ReplyTo, err)
+		}
+	})
+}
@@ -280,10 +280,14 @@ func (p *PriorityQueue) Values() []types.Message {
 }
 
 // Implements the RecvQueue interface.
+// Returns a copy, since the values are moved while holding the mutex.
 func (p *PriorityQueue) GetByKey(uid types.UID) *types.Message {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 	index := p.getIndexByUid(uid)
 	if index < 0 {
 		return nil
 	}
-	return &p.values[index]
+	value := p.values[index]
+	return &value
 }
@@ -0,0 +1,8 @@
+//go:build !mcastdebug
+// +build !mcastdebug
+
+package core
+
+// Verify the state transitions of every message.
+// Enabled by building with the mcastdebug tag.
+const debugTransitions = false
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"strings"
+)
+
+var (
+	// Returned to the requests vetoed by a send interceptor.
+	ErrSendVetoed = errors.New("send vetoed")
+)
+
+// Apply the send interceptors in order, each interceptor sees the
+// destination decided by the previous ones. Returns the message with
+// the final destination, or an error if an interceptor vetoed it.
+// Every decision changing the message is recorded and logged.
+func (p *Peer) route(message types.Message) (types.Message, error) {
+	for _, intercept := range p.configuration.Interceptors {
+		decision := intercept(message)
+		if decision.Veto == nil && decision.Destination != nil && len(decision.Destination) == 0 {
+			decision.Veto = errors.New("empty destination")
+		}
+
+		if decision.Veto != nil {
+			p.audit(types.EventVetoed, message, decision.Reason)
+			p.log.Infof("peer %s vetoed %s to %v. %s: %v", p.configuration.Name, message.Identifier, message.Destination, decision.Reason, decision.Veto)
+			return message, fmt.Errorf("%w: %v", ErrSendVetoed, decision.Veto)
+		}
+
+		if decision.Destination != nil {
+			previous := message.Destination
+			message.Destination = append([]types.Partition(nil), decision.Destination...)
+			p.audit(types.EventRouted, message, decision.Reason)
+			p.log.Infof("peer %s routed %s from %v to %v. %s", p.configuration.Name, message.Identifier, previous, message.Destination, decision.Reason)
+		}
+	}
+	return message, nil
+}
+
+// Record the routing decision on the events of the message, the
+// detail holds the reason and the destination decided. The decision
+// is not traced, the specification has no step for the routing.
+func (p *Peer) audit(kind types.EventKind, message types.Message, reason string) {
+	partitions := make([]string, len(message.Destination))
+	for i, partition := range message.Destination {
+		partitions[i] = string(partition)
+	}
+	p.configuration.Recorder.Record(message.Identifier, types.Event{
+		Kind:      kind,
+		Peer:      p.configuration.Name,
+		Type:      message.Header.Type,
+		State:     message.State,
+		Timestamp: message.Timestamp,
+		Key:       message.Content.Key,
+		Detail:    fmt.Sprintf("%s [%s]", reason, strings.Join(partitions, ",")),
+	})
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"time"
+)
+
+const (
+	// Timeout used before any round trip is observed.
+	DefaultInitialTimeout = 100 * time.Millisecond
+
+	// Lower bound for the computed timeout.
+	DefaultMinimumTimeout = 20 * time.Millisecond
+
+	// Upper bound for the computed timeout.
+	DefaultMaximumTimeout = 5 * time.Second
+)
+
+// The first timestamp exchange received from a partition.
+type exchange struct {
+	// The peer that sent the exchange.
+	peer string
+
+	// When the peer sent the exchange, on its clock.
+	sent int64
+
+	// When the exchange was received.
+	at time.Time
+}
+
+// Smoothed round trip time for a single partition.
+type roundTrip struct {
+	// The smoothed round trip time.
+	srtt time.Duration
+
+	// The round trip time variation.
+	rttvar time.Duration
+}
+
+// Estimates the timeouts to be used with each partition,
+// following the same approach as the TCP retransmission
+// timeout, described in the RFC 6298.
+//
+// Each observed round trip updates an exponentially weighted
+// moving average and its variance, the timeout is then given
+// by SRTT + 4 * RTTVAR, bounded by the configured values.
+// This way partitions across a WAN will have a greater timeout
+// than the partitions within the same LAN.
+//
+// The partitions send the timestamp of a message to each other
+// without waiting for an answer, so the round trip is sampled
+// only when a partition received the exchange of the other before
+// sending its own. The exchange sent echoes the one received, and
+// the peer that sent the echoed exchange samples the time since
+// sending it without the time the echo was held.
+type RTTEstimator struct {
+	// Synchronize access to the estimations.
+	mutex *sync.Mutex
+
+	// The estimations for each partition.
+	partitions map[types.Partition]*roundTrip
+
+	// The exchanges received for each message, to be echoed.
+	received map[types.UID]map[types.Partition]exchange
+
+	// Timeout used when the partition was not observed yet.
+	initial time.Duration
+
+	// Lower bound for the timeout.
+	minimum time.Duration
+
+	// Upper bound for the timeout.
+	maximum time.Duration
+}
+
+// Creates a new estimator using the default bounds.
+func NewRTTEstimator() *RTTEstimator {
+	return NewRTTEstimatorBounded(DefaultInitialTimeout, DefaultMinimumTimeout, DefaultMaximumTimeout)
+}
+
+// Creates a new estimator using the given bounds.
+func NewRTTEstimatorBounded(initial, minimum, maximum time.Duration) *RTTEstimator {
+	return &RTTEstimator{
+		mutex:      &sync.Mutex{},
+		partitions: make(map[types.Partition]*roundTrip),
+		received:   make(map[types.UID]map[types.Partition]exchange),
+		initial:    initial,
+		minimum:    minimum,
+		maximum:    maximum,
+	}
+}
+
+// Register a new round trip sample for the partition.
+func (r *RTTEstimator) Observe(partition types.Partition, sample time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	rt, ok := r.partitions[partition]
+	if !ok {
+		r.partitions[partition] = &roundTrip{
+			srtt:   sample,
+			rttvar: sample / 2,
+		}
+		return
+	}
+
+	diff := rt.srtt - sample
+	if diff < 0 {
+		diff = -diff
+	}
+	rt.rttvar = (3*rt.rttvar + diff) / 4
+	rt.srtt = (7*rt.srtt + sample) / 8
+}
+
+// Return the timeout to be used with the given partition.
+func (r *RTTEstimator) Timeout(partition types.Partition) time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	rt, ok := r.partitions[partition]
+	if !ok {
+		return r.initial
+	}
+
+	timeout := rt.srtt + 4*rt.rttvar
+	if timeout < r.minimum {
+		return r.minimum
+	}
+
+	if timeout > r.maximum {
+		return r.maximum
+	}
+	return timeout
+}
+
+// Return the greatest timeout between all the given partitions.
+func (r *RTTEstimator) Greatest(partitions []types.Partition) time.Duration {
+	var timeout time.Duration
+	for _, partition := range partitions {
+		if t := r.Timeout(partition); t > timeout {
+			timeout = t
+		}
+	}
+
+	if timeout == 0 {
+		return r.initial
+	}
+	return timeout
+}
+
+// Register the timestamp exchange of the message received from the
+// partition. Only the first exchange from each partition is kept.
+func (r *RTTEstimator) Received(uid types.UID, partition types.Partition, header types.ProtocolHeader) {
+	if header.Sent == 0 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	partitions, ok := r.received[uid]
+	if !ok {
+		partitions = make(map[types.Partition]exchange)
+		r.received[uid] = partitions
+	}
+	if _, ok := partitions[partition]; !ok {
+		partitions[partition] = exchange{
+			peer: header.Origin,
+			sent: header.Sent,
+			at:   time.Now(),
+		}
+	}
+}
+
+// The echo to send on the exchange of the message to the partition.
+// Empty when the exchange of the partition was not received yet.
+func (r *RTTEstimator) Echo(uid types.UID, partition types.Partition) types.Echo {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	received, ok := r.received[uid][partition]
+	if !ok {
+		return types.Echo{}
+	}
+	return types.Echo{
+		Peer: received.peer,
+		Sent: received.sent,
+		Held: int64(time.Since(received.at)),
+	}
+}
+
+// The partition answered an exchange sent by this peer, sampling
+// the round trip for the partition.
+func (r *RTTEstimator) Answered(partition types.Partition, echo types.Echo) {
+	sample := time.Duration(time.Now().UnixNano() - echo.Sent - echo.Held)
+	if echo.Sent == 0 || sample <= 0 {
+		return
+	}
+	r.Observe(partition, sample)
+}
+
+// Stop tracking the given message.
+func (r *RTTEstimator) Forget(uid types.UID) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.received, uid)
+}
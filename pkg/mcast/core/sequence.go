@@ -0,0 +1,343 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"sync/atomic"
+)
+
+// How many sent messages are kept for each partition,
+// so they can be sent again when requested.
+const DefaultSequenceHistory = 1024
+
+var (
+	// Reported when a gap is detected on the received sequence.
+	ErrMissingMessages = errors.New("messages missing from the transport")
+)
+
+// Keep track of the sequence numbers received from
+// a single origin peer.
+type sequenceTracker struct {
+	// The next expected sequence number.
+	next uint64
+
+	// Sequence numbers that were not received yet.
+	missing map[uint64]bool
+}
+
+// Register the received sequence number and return the
+// sequence numbers that were skipped, and if the message
+// must be processed. A sequence already received is a
+// duplicate, and processing it again would tick the clock
+// again for the same message.
+func (s *sequenceTracker) observe(sequence uint64) ([]uint64, bool) {
+	// The origin restarted and the sequence started again.
+	if sequence == 1 && s.next > 2 {
+		s.next = 0
+		s.missing = make(map[uint64]bool)
+	}
+
+	// First message received from the origin, there is no
+	// way to know about the previous messages.
+	if s.next == 0 {
+		s.next = sequence + 1
+		return nil, true
+	}
+
+	// A message arriving late or retransmitted is processed only
+	// if it was missing, the gap was already reported when the
+	// next one arrived.
+	if sequence < s.next {
+		if !s.missing[sequence] {
+			return nil, false
+		}
+		delete(s.missing, sequence)
+		return nil, true
+	}
+
+	var gaps []uint64
+	for i := s.next; i < sequence; i++ {
+		s.missing[i] = true
+		gaps = append(gaps, i)
+	}
+	s.next = sequence + 1
+
+	// The origin only keeps the last messages sent, the older
+	// ones can not be retransmitted anymore.
+	for missing := range s.missing {
+		if missing+DefaultSequenceHistory < s.next {
+			delete(s.missing, missing)
+		}
+	}
+	return gaps, true
+}
+
+// A transport decorator that adds monotonically increasing
+// sequence numbers for each pair of origin peer and destination
+// partition.
+//
+// When a receiver detects a gap on the sequence, a message was
+// dropped by the underlying transport, so a retransmission is
+// requested to the origin peer, which keeps a bounded history
+// of the sent messages.
+type SequencedTransport struct {
+	// Synchronize access to the sequences and history.
+	mutex *sync.Mutex
+
+	// The underlying transport.
+	Transport
+
+	// The name of the peer using the transport.
+	name string
+
+	// The partition of the peer using the transport.
+	partition types.Partition
+
+	// Ensure messages to the same partition are sent
+	// in the sequence order.
+	sending map[types.Partition]*sync.Mutex
+
+	// Last sequence number sent to each partition.
+	sequences map[types.Partition]uint64
+
+	// Messages sent to each partition.
+	history map[types.Partition][]types.Message
+
+	// How many messages are kept on the history.
+	limit int
+
+	// How a broadcast fans out to the destinations.
+	fanout types.Fanout
+
+	// Sequence numbers received for each origin peer.
+	received map[string]*sequenceTracker
+
+	// How many messages were detected as missing.
+	missed uint64
+
+	// Channel to publish the received messages.
+	producer chan types.Message
+
+	// Transport logger.
+	log types.Logger
+
+	// Where the asynchronous failures are reported.
+	errors *types.ErrorReporter
+
+	// The transport context.
+	context context.Context
+
+	// Finish the transport.
+	finish context.CancelFunc
+}
+
+// Creates a new sequenced transport decorating the given transport.
+func NewSequencedTransport(transport Transport, peer *types.PeerConfiguration, log types.Logger) *SequencedTransport {
+	ctx, done := context.WithCancel(context.Background())
+	s := &SequencedTransport{
+		mutex:     &sync.Mutex{},
+		Transport: transport,
+		name:      peer.Name,
+		partition: peer.Partition,
+		sending:   make(map[types.Partition]*sync.Mutex),
+		sequences: make(map[types.Partition]uint64),
+		history:   make(map[types.Partition][]types.Message),
+		limit:     DefaultSequenceHistory,
+		fanout:    peer.Fanout,
+		received:  make(map[string]*sequenceTracker),
+		producer:  make(chan types.Message),
+		log:       log,
+		errors:    peer.Errors,
+		context:   ctx,
+		finish:    done,
+	}
+	InvokerInstance().Spawn(func() {
+		defer close(s.producer)
+		Supervise(ctx, "sequenced transport "+peer.Name, s.poll)
+	})
+	return s
+}
+
+// Implements the Transport interface.
+// The message is sent to each destination partition with
+// its own sequence number.
+func (s *SequencedTransport) Broadcast(message types.Message) error {
+	return FanOut(s.fanout, message.Destination, func(partition types.Partition) error {
+		return s.Unicast(message, partition)
+	})
+}
+
+// Implements the Transport interface.
+func (s *SequencedTransport) Unicast(message types.Message, partition types.Partition) error {
+	lock := s.partitionLock(partition)
+	lock.Lock()
+	defer lock.Unlock()
+
+	s.mutex.Lock()
+	s.sequences[partition]++
+	message.Header.Origin = s.name
+	message.Header.Sequence = s.sequences[partition]
+	history := append(s.history[partition], message)
+	if len(history) > s.limit {
+		history = history[len(history)-s.limit:]
+	}
+	s.history[partition] = history
+	s.mutex.Unlock()
+
+	return s.Transport.Unicast(message, partition)
+}
+
+// Implements the Transport interface.
+func (s *SequencedTransport) Listen() <-chan types.Message {
+	return s.producer
+}
+
+// Implements the Transport interface.
+func (s *SequencedTransport) Close() {
+	s.finish()
+	s.Transport.Close()
+}
+
+// Implements the FlowControl interface.
+func (s *SequencedTransport) Grant(credits int) {
+	grant(s.Transport, credits)
+}
+
+// How many messages were detected as missing.
+func (s *SequencedTransport) Missed() uint64 {
+	return atomic.LoadUint64(&s.missed)
+}
+
+// Return the lock used when sending to the partition.
+func (s *SequencedTransport) partitionLock(partition types.Partition) *sync.Mutex {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	lock, ok := s.sending[partition]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.sending[partition] = lock
+	}
+	return lock
+}
+
+// Keep polling the underlying transport while the context is open.
+// Retransmission requests are handled here, all the other
+// messages are verified for gaps and published to the listener.
+func (s *SequencedTransport) poll() {
+	for {
+		select {
+		case <-s.context.Done():
+			return
+		case m, ok := <-s.Transport.Listen():
+			if !ok {
+				return
+			}
+
+			if m.Header.Type == types.Retransmit {
+				s.retransmit(m)
+				grant(s.Transport, 1)
+				continue
+			}
+
+			if !s.verify(m) {
+				grant(s.Transport, 1)
+				continue
+			}
+			select {
+			case <-s.context.Done():
+				return
+			case s.producer <- m:
+			}
+		}
+	}
+}
+
+// Verify the message sequence number and request the
+// retransmission of any skipped message. Returns false if
+// the sequence was already received, so the message is
+// discarded instead of published.
+func (s *SequencedTransport) verify(m types.Message) bool {
+	if m.Header.Sequence == 0 || len(m.Header.Origin) == 0 || m.Header.Origin == s.name {
+		return true
+	}
+
+	s.mutex.Lock()
+	tracker, ok := s.received[m.Header.Origin]
+	if !ok {
+		tracker = &sequenceTracker{missing: make(map[uint64]bool)}
+		s.received[m.Header.Origin] = tracker
+	}
+	gaps, accepted := tracker.observe(m.Header.Sequence)
+	s.mutex.Unlock()
+
+	if !accepted {
+		if m.Header.Flags.Has(types.FlagRetransmitted) {
+			s.log.Debugf("discarding retransmitted %d from %s, already received", m.Header.Sequence, m.Header.Origin)
+		} else {
+			s.log.Debugf("discarding duplicated %d from %s", m.Header.Sequence, m.Header.Origin)
+		}
+		return false
+	}
+
+	if len(gaps) == 0 {
+		return true
+	}
+
+	atomic.AddUint64(&s.missed, uint64(len(gaps)))
+	s.log.Warnf("missing %d messages from %s", len(gaps), m.Header.Origin)
+	s.errors.Report(&types.AsyncError{
+		Kind: types.DroppedMessage,
+		Peer: s.name,
+		Err:  fmt.Errorf("%w: %d from %s", ErrMissingMessages, len(gaps), m.Header.Origin),
+	})
+	for _, sequence := range gaps {
+		request := types.Message{
+			Header: types.ProtocolHeader{
+				ProtocolVersion: m.Header.ProtocolVersion,
+				Type:            types.Retransmit,
+				Origin:          s.name,
+				Sequence:        sequence,
+				Target:          m.Header.Origin,
+			},
+			From: s.partition,
+		}
+		if err := s.Transport.Unicast(request, m.From); err != nil {
+			s.log.Errorf("failed requesting retransmission of %d to %s. %v", sequence, m.Header.Origin, err)
+		}
+	}
+	return true
+}
+
+// Send again the requested message, if still present on
+// the history. Requests targeting other peers are ignored.
+// The copy reaches every peer of the requesting partition, so
+// it is flagged for the peers that received it to discard.
+func (s *SequencedTransport) retransmit(request types.Message) {
+	if request.Header.Target != s.name {
+		return
+	}
+
+	s.mutex.Lock()
+	var found *types.Message
+	for _, m := range s.history[request.From] {
+		if m.Header.Sequence == request.Header.Sequence {
+			message := m
+			found = &message
+			break
+		}
+	}
+	s.mutex.Unlock()
+
+	if found == nil {
+		s.log.Warnf("message %d to %s not found for retransmission", request.Header.Sequence, request.From)
+		return
+	}
+
+	found.Header.Flags |= types.FlagRetransmitted
+	if err := s.Transport.Unicast(*found, request.From); err != nil {
+		s.log.Errorf("failed retransmitting %d to %s. %v", request.Header.Sequence, request.From, err)
+	}
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"context"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// The shard of the key, between zero and the number of shards.
+func ShardOf(key []byte, shards int) int {
+	if shards < 2 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(shards))
+}
+
+// A storage routing each key to one of the shards.
+type ShardedStorage []types.Storage
+
+// Implements the Storage interface.
+func (s ShardedStorage) Set(key []byte, value []byte) error {
+	return s[ShardOf(key, len(s))].Set(key, value)
+}
+
+// Implements the Storage interface.
+func (s ShardedStorage) Get(key []byte) ([]byte, error) {
+	return s[ShardOf(key, len(s))].Get(key)
+}
+
+// Delivers the messages on independent state machines, one for
+// each shard. The messages are routed to a shard by the hash of
+// the key, the messages of the same shard are committed in order
+// and the shards are committed concurrently. So the messages of
+// a key are still committed in the delivery order.
+type ShardedDeliver struct {
+	// The delivery of each shard.
+	shards []Deliverable
+}
+
+// Creates a new instance of the Deliverable interface committing
+// each shard on its own state machine, created from the storage
+// of the shard the same way as NewParallelDeliver.
+func NewShardedDeliver(ctx context.Context, log types.Logger, conflict types.ConflictRelationship, storages []types.Storage, parallelism int) (Deliverable, error) {
+	s := &ShardedDeliver{}
+	for _, storage := range storages {
+		deliver, err := NewParallelDeliver(ctx, log, conflict, storage, parallelism)
+		if err != nil {
+			return nil, err
+		}
+		s.shards = append(s.shards, deliver)
+	}
+	return s, nil
+}
+
+// Implements the Deliverable interface.
+func (s *ShardedDeliver) Commit(message types.Message) types.Response {
+	return s.shards[ShardOf(message.Content.Key, len(s.shards))].Commit(message)
+}
+
+// Implements the Deliverable interface.
+// The messages are split by shard and each shard commits its
+// messages in order, concurrently with the other shards.
+func (s *ShardedDeliver) CommitBatch(messages []types.Message) []types.Response {
+	positions := make(map[int][]int)
+	for i, m := range messages {
+		shard := ShardOf(m.Content.Key, len(s.shards))
+		positions[shard] = append(positions[shard], i)
+	}
+
+	responses := make([]types.Response, len(messages))
+	group := &sync.WaitGroup{}
+	for shard, at := range positions {
+		shard, at := shard, at
+		group.Add(1)
+		InvokerInstance().Spawn(func() {
+			defer group.Done()
+			ordered := make([]types.Message, len(at))
+			for i, position := range at {
+				ordered[i] = messages[position]
+			}
+			for i, res := range s.shards[shard].CommitBatch(ordered) {
+				responses[at[i]] = res
+			}
+		})
+	}
+	group.Wait()
+	return responses
+}
+
+// Implements the Deliverable interface.
+func (s *ShardedDeliver) Load(entries []*types.Entry) error {
+	shards := make([][]*types.Entry, len(s.shards))
+	for _, entry := range entries {
+		shard := ShardOf(entry.Key, len(s.shards))
+		shards[shard] = append(shards[shard], entry)
+	}
+
+	for shard, loaded := range shards {
+		if len(loaded) == 0 {
+			continue
+		}
+		if err := s.shards[shard].Load(loaded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Implements the Deliverable interface.
+// With a key only the shard of the key is read, otherwise the
+// history of every shard is merged by the final timestamp.
+func (s *ShardedDeliver) History(filter types.HistoryFilter) ([]types.Entry, error) {
+	if len(filter.Key) > 0 {
+		return s.shards[ShardOf(filter.Key, len(s.shards))].History(filter)
+	}
+
+	var entries []types.Entry
+	for _, shard := range s.shards {
+		history, err := shard.History(filter)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, history...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].FinalTimestamp < entries[j].FinalTimestamp
+	})
+	return filter.Apply(entries), nil
+}
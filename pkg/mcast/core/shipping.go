@@ -0,0 +1,309 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"hash/crc32"
+	"sync"
+	"time"
+)
+
+// How many delivered entries each peer keeps, so they
+// can be shipped to the read replicas that fell behind.
+const DefaultShippingHistory = 1024
+
+// How long a read replica stays subscribed without
+// subscribing again.
+const SubscriptionLease = 5 * time.Second
+
+// Size of the chunks a snapshot is shipped in.
+const DefaultSnapshotChunk = 1 << 20
+
+var (
+	// Sent to a read replica asking for entries the peer does not
+	// hold anymore, when the state machine does not keep the history.
+	ErrLogTruncated = errors.New("delivered log truncated")
+)
+
+// Holds the log delivered by a peer and the read replicas
+// subscribed to it.
+//
+// Each committed command receives the next position on the log,
+// the positions are only meaningful for the peer that assigned
+// them, so a read replica receives the log from a single peer.
+// Only the last entries are kept, a replica asking for an older
+// entry must be hydrated with a snapshot of the state machine.
+type Shipper struct {
+	// Synchronize access to the log and subscribers.
+	mutex *sync.Mutex
+
+	// The name of the peer shipping the log.
+	name string
+
+	// How many entries are kept.
+	history int
+
+	// The last entries delivered, oldest first.
+	log []types.Message
+
+	// Position of the last entry delivered.
+	head uint64
+
+	// When the subscription of each read replica expires.
+	subscribers map[types.Partition]time.Time
+
+	// The last snapshot read, shipped again while the log holds
+	// the entries after it.
+	snapshot []byte
+
+	// Position on the log of the last snapshot read.
+	snapshotAt uint64
+}
+
+// Creates a new shipper for the given peer, keeping
+// up to the given number of entries.
+func NewShipper(name string, history int) *Shipper {
+	return &Shipper{
+		mutex:       &sync.Mutex{},
+		name:        name,
+		history:     history,
+		subscribers: make(map[types.Partition]time.Time),
+	}
+}
+
+// Append the delivered entries to the log, returning the
+// entries positioned and the read replicas subscribed.
+func (s *Shipper) Append(messages []types.Message) ([]types.Message, []types.Partition) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	shipped := make([]types.Message, 0, len(messages))
+	for _, m := range messages {
+		s.head++
+		m.Header.Index = s.head
+		shipped = append(shipped, m)
+	}
+
+	s.log = append(s.log, shipped...)
+	if len(s.log) > s.history {
+		s.log = append([]types.Message{}, s.log[len(s.log)-s.history:]...)
+	}
+	return shipped, s.active()
+}
+
+// Subscribe the read replica to the log, returning the entries from
+// the given position and the position of the last entry delivered.
+// If the log does not hold the position anymore returns false. A
+// replica subscribing to another peer is removed.
+func (s *Shipper) Subscribe(replica types.Partition, from uint64, source string) ([]types.Message, uint64, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(source) > 0 && source != s.name {
+		delete(s.subscribers, replica)
+		return nil, s.head, true
+	}
+
+	s.subscribers[replica] = time.Now().Add(SubscriptionLease)
+	if from == 0 {
+		from = 1
+	}
+	if from > s.head {
+		return nil, s.head, true
+	}
+
+	oldest := s.head - uint64(len(s.log)) + 1
+	if from < oldest {
+		return nil, s.head, false
+	}
+	return append([]types.Message{}, s.log[from-oldest:]...), s.head, true
+}
+
+// The chunks of the snapshot hydrating a read replica, followed by
+// the entries delivered after the snapshot. The last snapshot read is
+// shipped again while the log holds the entries after it, so a replica
+// resumes the same snapshot from the offset it received. Otherwise a
+// new snapshot is read at the head of the log and shipped from start.
+func (s *Shipper) Snapshot(resume types.Chunk, size int, read func() ([]byte, error)) ([]types.Message, []types.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	oldest := s.head - uint64(len(s.log)) + 1
+	if s.snapshot == nil || s.snapshotAt+1 < oldest {
+		data, err := read()
+		if err != nil {
+			return nil, nil, err
+		}
+		s.snapshot, s.snapshotAt = data, s.head
+	}
+
+	var entries []types.Message
+	if s.snapshotAt < s.head {
+		entries = append(entries, s.log[s.snapshotAt+1-oldest:]...)
+	}
+
+	total := uint64(len(s.snapshot))
+	from := uint64(0)
+	if resume.Position == s.snapshotAt && resume.Total == total && resume.Offset < total {
+		from = resume.Offset
+	}
+	return SnapshotChunks(s.snapshot, s.snapshotAt, from, size), entries, nil
+}
+
+// Split the snapshot at the position in chunks of the given size,
+// starting from the offset. A size not positive ships a single chunk.
+func SnapshotChunks(data []byte, position, from uint64, size int) []types.Message {
+	total := uint64(len(data))
+	step := uint64(size)
+	if size <= 0 {
+		step = total
+	}
+
+	var chunks []types.Message
+	for offset := from; offset < total; offset += step {
+		end := offset + step
+		if end > total {
+			end = total
+		}
+		content := data[offset:end]
+		chunks = append(chunks, types.Message{
+			Header: types.ProtocolHeader{
+				Index: position,
+				Flags: types.FlagSnapshot,
+				Chunk: types.Chunk{
+					Position: position,
+					Offset:   offset,
+					Total:    total,
+					Checksum: crc32.ChecksumIEEE(content),
+				},
+			},
+			Content: types.DataHolder{Content: content},
+		})
+	}
+	return chunks
+}
+
+// Verify if the read replica is subscribed.
+func (s *Shipper) Subscribed(replica types.Partition) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	expires, ok := s.subscribers[replica]
+	return ok && time.Now().Before(expires)
+}
+
+// The read replicas still subscribed, removing the expired ones.
+// This method should be called while holding the mutex.
+func (s *Shipper) active() []types.Partition {
+	now := time.Now()
+	var replicas []types.Partition
+	for replica, expires := range s.subscribers {
+		if now.After(expires) {
+			delete(s.subscribers, replica)
+			continue
+		}
+		replicas = append(replicas, replica)
+	}
+	return replicas
+}
+
+// Ship the committed commands to the read replicas subscribed.
+// This method should be called while holding the delivery mutex,
+// so the entries are positioned in the order they were committed.
+func (p *Peer) ship(messages []types.Message, responses []types.Response) {
+	var committed []types.Message
+	for i, m := range messages {
+		if responses[i].Success && m.Content.Operation == types.Command {
+			committed = append(committed, p.shipped(m))
+		}
+	}
+	if len(committed) == 0 {
+		return
+	}
+
+	shipped, replicas := p.shipper.Append(committed)
+	for _, replica := range replicas {
+		replica := replica
+		p.invoker.Spawn(func() {
+			p.shipTo(shipped, replica)
+		})
+	}
+}
+
+// Handle the subscription of a read replica. The peer answers with
+// its last position, followed by the entries the replica is missing,
+// or a snapshot of the state machine if the log does not hold them.
+func (p Peer) subscribe(message types.Message) {
+	replica := message.Header.ReplyTo
+	p.delivery.Lock()
+	entries, head, ok := p.shipper.Subscribe(replica, message.Header.Index, message.Header.Target)
+	messages := []types.Message{p.shipped(types.Message{})}
+	messages[0].Header.Index = head
+	if !ok {
+		var chunks []types.Message
+		chunks, entries = p.snapshot(message.Header.Chunk)
+		messages = append(messages, chunks...)
+	}
+	p.delivery.Unlock()
+
+	if !p.shipper.Subscribed(replica) {
+		return
+	}
+	p.shipTo(append(messages, entries...), replica)
+}
+
+// Hydrate the read replica with every entry committed up to the
+// snapshot position, resuming from the chunk the replica received.
+// The snapshot is read from the state machine history, without the
+// history the replica receives a failure. Returns the chunks and the
+// entries after the snapshot.
+// This method should be called while holding the delivery mutex.
+func (p Peer) snapshot(resume types.Chunk) ([]types.Message, []types.Message) {
+	chunks, entries, err := p.shipper.Snapshot(resume, DefaultSnapshotChunk, func() ([]byte, error) {
+		history, err := p.deliver.History(types.HistoryFilter{})
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(history)
+	})
+	if err != nil {
+		p.log.Warnf("peer %s failed hydrating replica. %v", p.configuration.Name, err)
+		failure := p.shipped(types.Message{})
+		failure.Header.Failure = fmt.Errorf("%w: %v", ErrLogTruncated, err).Error()
+		return []types.Message{failure}, nil
+	}
+
+	for i, chunk := range chunks {
+		m := p.shipped(chunk)
+		m.Header.Index = chunk.Header.Index
+		m.Header.Flags = chunk.Header.Flags
+		m.Header.Chunk = chunk.Header.Chunk
+		chunks[i] = m
+	}
+	return chunks, entries
+}
+
+// Creates the message shipping the committed message.
+func (p Peer) shipped(m types.Message) types.Message {
+	return types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: p.configuration.Version,
+			Type:            types.Ship,
+		},
+		Identifier: m.Identifier,
+		Content:    m.Content,
+		State:      m.State,
+		Timestamp:  m.Timestamp,
+		From:       p.configuration.Partition,
+	}
+}
+
+// Send the messages to the read replica. The log is not sent through
+// the outbox, a message lost is shipped again once the replica
+// subscribes asking for it.
+func (p Peer) shipTo(messages []types.Message, replica types.Partition) {
+	for _, m := range messages {
+		if err := p.epochs.Unicast(m, replica); err != nil {
+			p.log.Warnf("peer %s failed shipping %d to %s. %v", p.configuration.Name, m.Header.Index, replica, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+// Size of the tag appended to a signed frame.
+const SignatureSize = sha256.Size
+
+// Returned when the frame is not signed with the cluster key.
+var ErrUnauthenticated = errors.New("message authentication failed")
+
+// Signs the frames with the key shared by the cluster, so a
+// process connected to the broker without the key can not send
+// messages claiming to be from another partition.
+//
+// The HMAC-SHA256 of the whole frame, header included, is
+// appended to the frame. A nil signer does not sign nor verify.
+type FrameSigner struct {
+	// The key shared by the cluster.
+	key []byte
+}
+
+// Creates a signer for the given key, nil when the key is empty.
+func NewFrameSigner(key []byte) *FrameSigner {
+	if len(key) == 0 {
+		return nil
+	}
+	return &FrameSigner{key: append([]byte(nil), key...)}
+}
+
+// Appends the tag to the frame.
+func (s *FrameSigner) Sign(frame []byte) []byte {
+	if s == nil {
+		return frame
+	}
+	return append(frame, s.tag(frame)...)
+}
+
+// Verify the tag of the signed data, returning the frame
+// without the tag.
+func (s *FrameSigner) Verify(data []byte) ([]byte, error) {
+	if s == nil {
+		return data, nil
+	}
+	if len(data) < SignatureSize {
+		return nil, ErrUnauthenticated
+	}
+	frame, tag := data[:len(data)-SignatureSize], data[len(data)-SignatureSize:]
+	if !hmac.Equal(tag, s.tag(frame)) {
+		return nil, ErrUnauthenticated
+	}
+	return frame, nil
+}
+
+// The HMAC of the frame.
+func (s *FrameSigner) tag(frame []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(frame)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Keeps the clock jumps observed for each destination set.
+type SkewStatistics struct {
+	// Synchronize access to the metrics.
+	mutex *sync.Mutex
+
+	// The metrics of each destination set.
+	destinations map[string]*types.SkewMetrics
+}
+
+// Creates a new empty statistics.
+func NewSkewStatistics() *SkewStatistics {
+	return &SkewStatistics{
+		mutex:        &sync.Mutex{},
+		destinations: make(map[string]*types.SkewMetrics),
+	}
+}
+
+// Register the clock jump of a message to the destinations.
+func (s *SkewStatistics) Observe(destination []types.Partition, jump uint64) {
+	key := DestinationSet(destination)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok := s.destinations[key]
+	if !ok {
+		m = &types.SkewMetrics{}
+		s.destinations[key] = m
+	}
+	m.Observe(jump)
+}
+
+// Creates a copy of the current metrics.
+func (s *SkewStatistics) Snapshot() map[string]types.SkewMetrics {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot := make(map[string]types.SkewMetrics)
+	for key, m := range s.destinations {
+		snapshot[key] = m.Merge(types.SkewMetrics{})
+	}
+	return snapshot
+}
+
+// Identifies the destination set, independent of the order.
+func DestinationSet(destination []types.Partition) string {
+	var partitions []string
+	for _, partition := range destination {
+		partitions = append(partitions, string(partition))
+	}
+	sort.Strings(partitions)
+	return strings.Join(partitions, ",")
+}
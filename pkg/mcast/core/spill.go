@@ -0,0 +1,124 @@
+package core
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// Size of the prefix holding the length of each spilled entry.
+const spillPrefixSize = 4
+
+// A FIFO queue backed by a file, holding the messages the
+// listener did not consume in time. Once every entry is
+// consumed the file is truncated, so the file only grows
+// while the listener lags behind.
+type SpillQueue struct {
+	// Synchronize access to the file offsets.
+	mutex *sync.Mutex
+
+	// The file holding the entries.
+	file *os.File
+
+	// Offset of the first entry not consumed.
+	read int64
+
+	// Offset where the next entry is written.
+	write int64
+
+	// Entries written and not consumed.
+	pending uint64
+
+	// Signaled when an entry is written.
+	notify chan struct{}
+}
+
+// Creates a new spill queue on a new file inside the directory.
+func NewSpillQueue(directory, name string) (*SpillQueue, error) {
+	file, err := ioutil.TempFile(directory, "mcast-spill-"+name+"-")
+	if err != nil {
+		return nil, err
+	}
+	return &SpillQueue{
+		mutex:  &sync.Mutex{},
+		file:   file,
+		notify: make(chan struct{}, 1),
+	}, nil
+}
+
+// Append the entry at the end of the queue.
+func (s *SpillQueue) Push(data []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry := make([]byte, spillPrefixSize, spillPrefixSize+len(data))
+	binary.BigEndian.PutUint32(entry, uint32(len(data)))
+	entry = append(entry, data...)
+	if _, err := s.file.WriteAt(entry, s.write); err != nil {
+		return err
+	}
+	s.write += int64(len(entry))
+	s.pending++
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Read the first entry without removing it, returns false
+// when the queue is empty.
+func (s *SpillQueue) Peek() ([]byte, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.pending == 0 {
+		return nil, false, nil
+	}
+
+	prefix := make([]byte, spillPrefixSize)
+	if _, err := s.file.ReadAt(prefix, s.read); err != nil {
+		return nil, false, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(prefix))
+	if _, err := s.file.ReadAt(data, s.read+spillPrefixSize); err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Remove the first entry, with the given size, from the queue.
+func (s *SpillQueue) Pop(size int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.read += int64(spillPrefixSize + size)
+	s.pending--
+	if s.pending > 0 {
+		return nil
+	}
+
+	s.read, s.write = 0, 0
+	return s.file.Truncate(0)
+}
+
+// How many entries are on the queue.
+func (s *SpillQueue) Pending() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.pending
+}
+
+// Signaled when an entry is written.
+func (s *SpillQueue) Notify() <-chan struct{} {
+	return s.notify
+}
+
+// Close and remove the file, the pending entries are lost.
+func (s *SpillQueue) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(s.file.Name())
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+)
+
+// Keeps the protocol counters of a peer, reporting each change
+// to the configured metrics.
+type PartitionStatistics struct {
+	// Synchronize access to the counters.
+	mutex *sync.Mutex
+
+	// The current counters.
+	stats types.PartitionStats
+
+	// The instruments the changes are reported to.
+	proposed     types.Counter
+	conflicts    types.Counter
+	delivered    types.Counter
+	generic      types.Counter
+	boosted      types.Counter
+	reclaimed    types.Counter
+	duplicates   types.Counter
+	clock        types.Gauge
+	destinations types.Histogram
+	batch        types.Histogram
+}
+
+// Creates a new empty statistics, counting with the given features.
+// The instruments are created from the metrics with the labels, when
+// nil the measurements are discarded.
+func NewPartitionStatistics(features types.Features, metrics types.Metrics, labels map[string]string) *PartitionStatistics {
+	if metrics == nil {
+		metrics = types.NoopMetrics{}
+	}
+	return &PartitionStatistics{
+		mutex:        &sync.Mutex{},
+		stats:        types.PartitionStats{Features: features.List()},
+		proposed:     metrics.Counter(types.MetricProposed, labels),
+		conflicts:    metrics.Counter(types.MetricConflicts, labels),
+		delivered:    metrics.Counter(types.MetricDelivered, labels),
+		generic:      metrics.Counter(types.MetricGenericDelivered, labels),
+		boosted:      metrics.Counter(types.MetricBoosted, labels),
+		reclaimed:    metrics.Counter(types.MetricReclaimed, labels),
+		duplicates:   metrics.Counter(types.MetricDuplicates, labels),
+		clock:        metrics.Gauge(types.MetricClock, labels),
+		destinations: metrics.Histogram(types.MetricDestinations, labels),
+		batch:        metrics.Histogram(types.MetricBatchSize, labels),
+	}
+}
+
+// Register a timestamp proposal for a message to the destinations,
+// and if the proposal conflicted and increased the clock.
+func (s *PartitionStatistics) Proposed(destinations int, conflict bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stats.Proposed++
+	s.stats.Destinations += uint64(destinations)
+	if conflict {
+		s.stats.Conflicts++
+		s.stats.ClockTicks++
+		s.conflicts.Add(1)
+	}
+	s.proposed.Add(1)
+	s.destinations.Observe(float64(destinations))
+}
+
+// Register the clock value after a proposal.
+func (s *PartitionStatistics) Clock(timestamp uint64) {
+	s.clock.Set(float64(timestamp))
+}
+
+// Register a message delivered without waiting for its order.
+func (s *PartitionStatistics) GenericDelivered() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stats.GenericDelivered++
+	s.generic.Add(1)
+}
+
+// Register the pending messages boosted by an urgent message.
+func (s *PartitionStatistics) Boosted(messages int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stats.Boosted += uint64(messages)
+	s.boosted.Add(float64(messages))
+}
+
+// Register the entries reclaimed for finished messages.
+func (s *PartitionStatistics) Reclaimed(entries int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stats.Reclaimed += uint64(entries)
+	s.reclaimed.Add(float64(entries))
+}
+
+// Register a timestamp received again from the same peer.
+func (s *PartitionStatistics) Duplicated() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stats.Duplicates++
+	s.duplicates.Add(1)
+}
+
+// Register the messages committed on the state machine.
+func (s *PartitionStatistics) Delivered(messages int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stats.Delivered += uint64(messages)
+	s.delivered.Add(float64(messages))
+	s.batch.Observe(float64(messages))
+}
+
+// Creates a copy of the current counters.
+func (s *PartitionStatistics) Snapshot() types.PartitionStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats
+}
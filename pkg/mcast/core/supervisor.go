@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"github.com/prometheus/common/log"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Time waiting before restarting a loop after the first panic.
+	minRestartWait = 10 * time.Millisecond
+
+	// Upper bound for the time waiting before restarting a loop.
+	maxRestartWait = 5 * time.Second
+)
+
+// How many panics were recovered on the process.
+var recovered uint64
+
+// How many panics were recovered on the process since it started.
+func Panics() uint64 {
+	return atomic.LoadUint64(&recovered)
+}
+
+// Execute the function recovering from a panic. The stack trace
+// is logged and the panic counted, so a single failure does
+// not kill the whole process.
+// Returns true if the function panicked.
+func Protect(name string, f func()) (panicked bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			atomic.AddUint64(&recovered, 1)
+			log.Errorf("recovered panic on %s. %v\n%s", name, err, debug.Stack())
+			panicked = true
+		}
+	}()
+	f()
+	return false
+}
+
+// Execute a critical loop, restarting it after a panic. Each
+// consecutive restart waits twice the previous time, the wait
+// is reset if the loop was running longer than the maximum wait.
+// Blocks until the loop returns or the context is done.
+func Supervise(ctx context.Context, name string, f func()) {
+	wait := minRestartWait
+	for {
+		started := time.Now()
+		if !Protect(name, f) {
+			return
+		}
+
+		if time.Since(started) > maxRestartWait {
+			wait = minRestartWait
+		}
+
+		log.Warnf("restarting %s in %v", name, wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		wait *= 2
+		if wait > maxRestartWait {
+			wait = maxRestartWait
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+)
+
+// Split the given partitions by proximity to the local location.
+// Partitions in the same datacenter are returned as near and the
+// ones in a different datacenter are returned as far, keeping
+// the partitions on the same zone at the beginning of near.
+func SplitByProximity(local types.Location, topology types.Topology, partitions []types.Partition) ([]types.Partition, []types.Partition) {
+	var zone, datacenter, far []types.Partition
+	for _, partition := range partitions {
+		location := topology.Locate(partition)
+		if local.SameZone(location) {
+			zone = append(zone, partition)
+		} else if local.SameDatacenter(location) {
+			datacenter = append(datacenter, partition)
+		} else {
+			far = append(far, partition)
+		}
+	}
+	return append(zone, datacenter...), far
+}
+
+// Keep track of the messages exchanged with each zone.
+type ZoneStatistics struct {
+	// Synchronize access to the counters.
+	mutex *sync.Mutex
+
+	// The zone counters.
+	zones map[types.Zone]*types.ZoneMetrics
+}
+
+// Creates a new empty statistics.
+func NewZoneStatistics() *ZoneStatistics {
+	return &ZoneStatistics{
+		mutex: &sync.Mutex{},
+		zones: make(map[types.Zone]*types.ZoneMetrics),
+	}
+}
+
+// Get the counter for the zone, creating if needed.
+// This method should be called while holding the mutex.
+func (z *ZoneStatistics) get(zone types.Zone) *types.ZoneMetrics {
+	m, ok := z.zones[zone]
+	if !ok {
+		m = &types.ZoneMetrics{}
+		z.zones[zone] = m
+	}
+	return m
+}
+
+// Register a message sent to the zone.
+func (z *ZoneStatistics) Sent(zone types.Zone) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	z.get(zone).Sent++
+}
+
+// Register a message received from the zone.
+func (z *ZoneStatistics) Received(zone types.Zone) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	z.get(zone).Received++
+}
+
+// Creates a copy of the current counters.
+func (z *ZoneStatistics) Snapshot() map[types.Zone]types.ZoneMetrics {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	snapshot := make(map[types.Zone]types.ZoneMetrics)
+	for zone, m := range z.zones {
+		snapshot[zone] = *m
+	}
+	return snapshot
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"strings"
+	"sync"
+)
+
+// How many observations are kept for each message.
+const transitionHistory = 32
+
+var (
+	// The message moved between states not allowed by the protocol.
+	ErrIllegalTransition = errors.New("illegal state transition")
+
+	// The message timestamp decreased.
+	ErrTimestampDecreased = errors.New("timestamp decreased")
+)
+
+// The states each state can move to. A message that must exchange
+// timestamps goes S0 -> S1 -> S2 -> S3, skipping S2 when the group
+// timestamp is already the greatest, and a message to a single
+// partition goes S0 -> S3.
+var transitions = map[types.MessageState][]types.MessageState{
+	types.S0: {types.S0, types.S1, types.S3},
+	types.S1: {types.S1, types.S2, types.S3},
+	types.S2: {types.S2, types.S3},
+	types.S3: {types.S3},
+}
+
+// Verifies that the messages observed by a peer follow the legal
+// state transitions and that the timestamps never decrease.
+//
+// This is enabled only on debug builds, using the mcastdebug tag,
+// since keeping the history of every message is expensive.
+type TransitionChecker struct {
+	// Synchronize access to the history.
+	mutex *sync.Mutex
+
+	// The observed versions of each message, oldest first.
+	history map[types.UID][]types.Message
+}
+
+// Create a new empty checker.
+func NewTransitionChecker() *TransitionChecker {
+	return &TransitionChecker{
+		mutex:   &sync.Mutex{},
+		history: make(map[types.UID][]types.Message),
+	}
+}
+
+// Observe the new version of the message. When the transition is
+// not legal an error with the full message history is returned.
+func (t *TransitionChecker) Observe(message types.Message) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	history := append(t.history[message.Identifier], message)
+	if len(history) > transitionHistory {
+		history = history[len(history)-transitionHistory:]
+	}
+	t.history[message.Identifier] = history
+	if len(history) == 1 {
+		return nil
+	}
+
+	previous := history[len(history)-2]
+	if !legalTransition(previous.State, message.State) {
+		return t.violation(ErrIllegalTransition, history)
+	}
+
+	if message.Timestamp < previous.Timestamp {
+		return t.violation(ErrTimestampDecreased, history)
+	}
+	return nil
+}
+
+// Remove the history of a delivered message.
+func (t *TransitionChecker) Forget(uid types.UID) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.history, uid)
+}
+
+func (t *TransitionChecker) violation(err error, history []types.Message) error {
+	var steps []string
+	for _, message := range history {
+		steps = append(steps, fmt.Sprintf("S%d@%d from %s", message.State, message.Timestamp, message.From))
+	}
+	return fmt.Errorf("%w: %s history [%s]", err, history[0].Identifier, strings.Join(steps, ", "))
+}
+
+func legalTransition(from, to types.MessageState) bool {
+	for _, state := range transitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
@@ -2,15 +2,41 @@ package core
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
 	"github.com/jabolina/go-mcast/pkg/mcast/types"
-	"github.com/jabolina/relt/pkg/relt"
-	"github.com/prometheus/common/log"
+	"sync/atomic"
 	"time"
 )
 
+// Maximum number of messages read at once from the
+// underlying transport and decoded in parallel.
+const ReceiveBatch = 64
+
+var (
+	// Reported when the listener did not consume a received
+	// message in time.
+	ErrNotConsumed = errors.New("message not consumed in time")
+)
+
 // The transport interface providing the communication
 // primitives by the protocol.
+//
+// Every implementation must respect the following contract,
+// which is verified by the conformance suite on the package
+// transporttest:
+//
+// - Acknowledgment: when Broadcast or Unicast returns nil, the
+// transport took responsibility for the message and it will
+// eventually be delivered to the destinations;
+// - Delivery: a message sent to a partition is delivered to
+// every open transport listening on that partition;
+// - Ordering: messages from a single sender to a partition are
+// delivered in the same order they were sent;
+// - Duplication: a message is delivered at least once, so a
+// message can be duplicated and the listener must be idempotent;
+// - Closing: after Close, the channel returned by Listen is
+// eventually closed.
 type Transport interface {
 	// Reliably deliver the message to all correct processes
 	// in the same order.
@@ -29,18 +55,81 @@ type Transport interface {
 	Close()
 }
 
+// A transport reporting how its listener keeps up
+// with the received messages.
+type ConsumerObserver interface {
+	// The metrics of the transport listener.
+	Consumer() types.ConsumerMetrics
+}
+
 // An instance of the Transport interface that
 // provides the required reliable transport primitives.
 type ReliableTransport struct {
 	// Transport logger.
 	log types.Logger
 
-	// Reliable transport.
-	relt *relt.Relt
+	// Connection to the broker.
+	connection types.BrokerConnection
 
 	// Channel to publish the receiving messages.
 	producer chan types.Message
 
+	// Timeouts adapted from the observed round trips.
+	timeouts *RTTEstimator
+
+	// Codec to deserialize the messages.
+	codec types.Codec
+
+	// Decodes the received messages in parallel.
+	decoder *DecodePool
+
+	// Choose the codec to serialize each message.
+	codecs *CodecNegotiator
+
+	// Signs the sent frames and verifies the received ones.
+	signer *FrameSigner
+
+	// Compresses the contents sent and decompresses the
+	// received ones.
+	compressor *DictionaryCompressor
+
+	// Resolve the partition addresses.
+	resolver types.Resolver
+
+	// How a broadcast fans out to the destinations.
+	fanout types.Fanout
+
+	// The transport owner name.
+	name string
+
+	// Where the asynchronous failures are reported.
+	errors *types.ErrorReporter
+
+	// How to handle a slow listener.
+	consumer types.SlowConsumer
+
+	// Holds the messages not consumed in time, when spilling.
+	spill *SpillQueue
+
+	// Closed once the spilled messages are not published anymore.
+	drained chan struct{}
+
+	// Messages not consumed in time.
+	slow *uint64
+
+	// Messages written to the spill queue.
+	spilled *uint64
+
+	// Nanoseconds waiting for the listener after the timeout.
+	blocked *int64
+
+	// The credits to receive from the broker, nil without
+	// flow control.
+	credits chan struct{}
+
+	// Nanoseconds waiting for credits.
+	throttled *int64
+
 	// The transport context.
 	context context.Context
 
@@ -49,60 +138,140 @@ type ReliableTransport struct {
 }
 
 // Create a new instance of the transport interface.
-func NewTransport(peer *types.PeerConfiguration, log types.Logger) (Transport, error) {
-	conf := relt.DefaultReltConfiguration()
-	conf.Name = peer.Name
-	conf.Exchange = relt.GroupAddress(peer.Partition)
-	r, err := relt.NewRelt(*conf)
+// The given estimator defines how long the transport waits
+// for the consumer when publishing a received message.
+func NewTransport(peer *types.PeerConfiguration, timeouts *RTTEstimator, log types.Logger) (Transport, error) {
+	return newTransport(peer, timeouts, NewDictionaryCompressor(peer.Compression, log), log)
+}
+
+// Create the transport with the compressor shared with the peer,
+// which samples the delivered contents.
+func newTransport(peer *types.PeerConfiguration, timeouts *RTTEstimator, compressor *DictionaryCompressor, log types.Logger) (Transport, error) {
+	resolver := peer.Resolver
+	if resolver == nil {
+		resolver = definition.IdentityResolver{}
+	}
+	address, err := resolver.Resolve(peer.Partition)
+	if err != nil {
+		return nil, err
+	}
+
+	broker := peer.Broker
+	if broker == nil {
+		broker = ReltBroker{}
+	}
+	connection, err := broker.Connect(peer.Name, address)
 	if err != nil {
 		return nil, err
 	}
+	codec := peer.Codec
+	if codec == nil {
+		codec = definition.JSONCodec{}
+	}
+	var spill *SpillQueue
+	if peer.Consumer.Strategy == types.ConsumerSpill {
+		spill, err = NewSpillQueue(peer.Consumer.Directory, peer.Name)
+		if err != nil {
+			connection.Close()
+			return nil, err
+		}
+	}
+	var credits chan struct{}
+	if peer.Credits > 0 {
+		credits = make(chan struct{}, peer.Credits)
+		for i := 0; i < peer.Credits; i++ {
+			credits <- struct{}{}
+		}
+	}
 	ctx, done := context.WithCancel(context.Background())
 	t := &ReliableTransport{
-		log:      log,
-		relt:     r,
-		producer: make(chan types.Message),
-		context:  ctx,
-		finish:   done,
+		log:        log,
+		connection: connection,
+		producer:   make(chan types.Message),
+		timeouts:   timeouts,
+		codec:      codec,
+		decoder:    NewDecodePool(ctx, codec, 0),
+		codecs:     NewCodecNegotiator(codec, peer.Codecs),
+		signer:     NewFrameSigner(peer.SigningKey),
+		compressor: compressor,
+		resolver:   resolver,
+		fanout:     peer.Fanout,
+		name:       peer.Name,
+		errors:     peer.Errors,
+		consumer:   peer.Consumer,
+		spill:      spill,
+		drained:    make(chan struct{}),
+		slow:       new(uint64),
+		spilled:    new(uint64),
+		blocked:    new(int64),
+		credits:    credits,
+		throttled:  new(int64),
+		context:    ctx,
+		finish:     done,
 	}
-	InvokerInstance().Spawn(t.poll)
+	if spill != nil {
+		InvokerInstance().Spawn(t.drain)
+	} else {
+		close(t.drained)
+	}
+	InvokerInstance().Spawn(func() {
+		defer close(t.producer)
+		Supervise(ctx, "transport "+peer.Name, t.poll)
+		<-t.drained
+	})
 	return t, nil
 }
 
 // ReliableTransport implements Transport interface.
 func (r *ReliableTransport) Broadcast(message types.Message) error {
-	data, err := json.Marshal(message)
+	message.Header.Codecs = r.codecs.Supported()
+	message = r.compressor.Compress(message)
+	data, err := EncodeFrame(r.codecs.Choose(message.Destination), message)
 	if err != nil {
-		log.Errorf("failed marshalling message %#v. %v", message, err)
+		r.log.Errorf("failed marshalling message %#v. %v", message, err)
 		return err
 	}
+	data = r.signer.Sign(data)
 
 	r.log.Debugf("broadcasting message %#v", message)
-	for _, partition := range message.Destination {
-		m := relt.Send{
-			Address: relt.GroupAddress(partition),
-			Data:    data,
+	return FanOut(r.fanout, message.Destination, func(partition types.Partition) error {
+		address, err := r.resolver.Resolve(partition)
+		if err != nil {
+			r.log.Errorf("failed resolving %s. %v", partition, err)
+			return err
 		}
-		if err = r.relt.Broadcast(m); err != nil {
-			r.log.Errorf("failed sending %#v. %v", m, err)
+
+		if err = r.connection.Publish(address, data); err != nil {
+			r.log.Errorf("failed sending %s to %s. %v", message.Identifier, address, err)
+			r.report(types.TransportFailure, message.Identifier, err)
 			return err
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 // ReliableTransport implements Transport interface.
 func (r *ReliableTransport) Unicast(message types.Message, partition types.Partition) error {
-	data, err := json.Marshal(message)
+	message.Header.Codecs = r.codecs.Supported()
+	message = r.compressor.Compress(message)
+	data, err := EncodeFrame(r.codecs.Choose([]types.Partition{partition}), message)
+	if err != nil {
+		r.log.Errorf("failed marshalling unicast message %#v. %v", message, err)
+		return err
+	}
+	data = r.signer.Sign(data)
+
+	address, err := r.resolver.Resolve(partition)
 	if err != nil {
-		log.Errorf("failed marshalling unicast message %#v. %v", message, err)
+		r.log.Errorf("failed resolving %s. %v", partition, err)
+		return err
 	}
 
-	m := relt.Send{
-		Address: relt.GroupAddress(partition),
-		Data:    data,
+	if err := r.connection.Publish(address, data); err != nil {
+		r.report(types.TransportFailure, message.Identifier, err)
+		return err
 	}
-	return r.relt.Broadcast(m)
+	return nil
 }
 
 // ReliableTransport implements Transport interface.
@@ -112,64 +281,297 @@ func (r *ReliableTransport) Listen() <-chan types.Message {
 
 // ReliableTransport implements Transport interface.
 func (r *ReliableTransport) Close() {
-	r.relt.Close()
+	r.connection.Close()
 	r.finish()
 }
 
 // This method will keep polling until
 // the transport context cancelled.
+// The producer channel is closed only after the poll
+// returns, so the poll can be restarted after a panic.
 // The messages that arrives through the underlying
-// transport channel will be sent to the consume
-// method to be parsed and publish to the listeners.
+// transport channel are drained in batches, every message
+// already available is read at once, up to ReceiveBatch,
+// and sent to the consume method to be parsed and publish
+// to the listeners. With flow control, each message received
+// takes a credit, so the transport waits while the listener
+// has no credits left.
 func (r ReliableTransport) poll() {
+	batch := make([]types.Delivery, 0, ReceiveBatch)
 	for {
+		if !r.acquire() {
+			return
+		}
 		select {
 		case <-r.context.Done():
 			return
-		case recv, ok := <-r.relt.Consume():
+		case recv, ok := <-r.connection.Consume():
 			if !ok {
 				return
 			}
-			r.consume(recv)
+			batch = append(batch[:0], recv)
+			closed := r.drainLoop(&batch)
+			r.consume(batch)
+			if closed {
+				return
+			}
 		}
 	}
 }
 
-// Consume will receive a message from the transport
-// and will parse into a valid object to be consumed
-// by the channel listener.
-func (r *ReliableTransport) consume(recv relt.Recv) {
+// Read the messages already available without waiting, until the
+// batch is full or the credits end. Returns true if the underlying
+// channel is closed.
+func (r ReliableTransport) drainLoop(batch *[]types.Delivery) bool {
+	for len(*batch) < cap(*batch) && r.available() {
+		select {
+		case recv, ok := <-r.connection.Consume():
+			if !ok {
+				return true
+			}
+			*batch = append(*batch, recv)
+		default:
+			r.Grant(1)
+			return false
+		}
+	}
+	return false
+}
+
+// Take a credit to receive the next message, waiting until the
+// listener grants one. Returns false once the transport closes.
+func (r ReliableTransport) acquire() bool {
+	if r.credits == nil || r.available() {
+		return true
+	}
+
+	start := time.Now()
+	defer func() {
+		atomic.AddInt64(r.throttled, int64(time.Since(start)))
+	}()
+	select {
+	case <-r.context.Done():
+		return false
+	case <-r.credits:
+		return true
+	}
+}
+
+// Take a credit if there is one, without waiting.
+func (r ReliableTransport) available() bool {
+	if r.credits == nil {
+		return true
+	}
+	select {
+	case <-r.credits:
+		return true
+	default:
+		return false
+	}
+}
+
+// Implements the FlowControl interface.
+// The credits beyond the window are discarded.
+func (r *ReliableTransport) Grant(credits int) {
+	for i := 0; i < credits && r.credits != nil; i++ {
+		select {
+		case r.credits <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// Consume will receive a batch of messages from the transport,
+// decode the messages in parallel and publish them in order to
+// be consumed by the channel listener. When signing, the frames
+// not signed with the cluster key are dropped before decoding.
+func (r *ReliableTransport) consume(batch []types.Delivery) {
+	frames := make([][]byte, len(batch))
+	rejected := make([]error, len(batch))
+	for i, recv := range batch {
+		if recv.Error == nil && recv.Data != nil {
+			frames[i], rejected[i] = r.signer.Verify(recv.Data)
+		}
+	}
+
+	for i, result := range r.decoder.Decode(frames) {
+		if rejected[i] != nil {
+			result.Err = rejected[i]
+		}
+		r.handle(batch[i], result)
+	}
+}
+
+// Publish a single decoded message, reporting the failures.
+func (r *ReliableTransport) handle(recv types.Delivery, result Decoded) {
 	defer func() {
 		if err := recover(); err != nil {
 			select {
 			case <-r.context.Done():
-				close(r.producer)
+				return
 			default:
-				r.consume(recv)
+				r.handle(recv, result)
 			}
 		}
 	}()
 
 	if recv.Error != nil {
 		r.log.Errorf("failed consuming message. %v", recv.Error)
+		r.report(types.TransportFailure, "", recv.Error)
+		r.Grant(1)
 		return
 	}
 
 	if recv.Data == nil {
+		r.Grant(1)
 		return
 	}
 
-	var m types.Message
-	if err := json.Unmarshal(recv.Data, &m); err != nil {
-		r.log.Errorf("failed unmarshalling message %#v. %v", recv, err)
+	if result.Err != nil {
+		r.log.Errorf("failed unmarshalling message %#v. %v", recv, result.Err)
+		r.report(types.DroppedMessage, "", result.Err)
+		r.Grant(1)
+		return
+	}
+	message, err := r.compressor.Decompress(result.Message)
+	if err != nil {
+		r.log.Errorf("failed decompressing message %s. %v", message.Identifier, err)
+		r.report(types.DroppedMessage, message.Identifier, err)
+		r.Grant(1)
+		return
+	}
+	r.codecs.Observe(message)
+
+	r.publish(message)
+}
+
+// Publish the message to the listener. Once the message is late
+// it is handled following the slow consumer strategy, and is never
+// discarded while the transport is open. While there are spilled
+// messages the next ones are spilled as well, to keep the order.
+func (r *ReliableTransport) publish(m types.Message) {
+	if r.spill != nil && r.spill.Pending() > 0 {
+		r.spillMessage(m)
 		return
 	}
 
 	select {
-	case <-time.After(100 * time.Millisecond):
-		r.log.Warnf("failed consuming %#v", m)
+	case <-r.context.Done():
 		return
 	case r.producer <- m:
 		return
+	case <-time.After(r.timeouts.Timeout(m.From)):
+	}
+
+	atomic.AddUint64(r.slow, 1)
+	r.log.Warnf("listener of %s did not consume %s in time, applying %s", r.name, m.Identifier, r.consumer.Strategy)
+	r.report(types.ConsumerFailure, m.Identifier, ErrNotConsumed)
+	switch r.consumer.Strategy {
+	case types.ConsumerSpill:
+		r.spillMessage(m)
+		return
+	case types.ConsumerCrash:
+		r.log.Fatalf("listener of %s did not consume %s in time. %v", r.name, m.Identifier, ErrNotConsumed)
+	}
+	r.block(m)
+}
+
+// Wait until the listener consumes the message or the transport closes.
+func (r *ReliableTransport) block(m types.Message) {
+	start := time.Now()
+	defer func() {
+		atomic.AddInt64(r.blocked, int64(time.Since(start)))
+	}()
+
+	select {
+	case <-r.context.Done():
+	case r.producer <- m:
+	}
+}
+
+// Write the message to the spill queue. If the message can not be
+// written the transport blocks instead, rather than discarding it.
+func (r *ReliableTransport) spillMessage(m types.Message) {
+	data, err := EncodeFrame(r.codec, m)
+	if err == nil {
+		err = r.spill.Push(data)
+	}
+	if err != nil {
+		r.log.Errorf("failed spilling %s, blocking. %v", m.Identifier, err)
+		r.report(types.TransportFailure, m.Identifier, err)
+		r.block(m)
+		return
 	}
+	atomic.AddUint64(r.spilled, 1)
+}
+
+// Publish the spilled messages to the listener, in order, until the
+// transport closes. The spill file is removed when closed.
+func (r *ReliableTransport) drain() {
+	defer close(r.drained)
+	defer func() {
+		if err := r.spill.Close(); err != nil {
+			r.log.Errorf("failed removing spill of %s. %v", r.name, err)
+		}
+	}()
+
+	for {
+		data, ok, err := r.spill.Peek()
+		if err != nil {
+			r.log.Errorf("failed reading spill of %s. %v", r.name, err)
+			r.report(types.TransportFailure, "", err)
+			return
+		}
+
+		if !ok {
+			select {
+			case <-r.context.Done():
+				return
+			case <-r.spill.Notify():
+				continue
+			}
+		}
+
+		m, err := DecodeFrame(r.codec, data)
+		if err != nil {
+			r.log.Errorf("failed decoding spilled message. %v", err)
+			r.report(types.DroppedMessage, "", err)
+		} else {
+			select {
+			case <-r.context.Done():
+				return
+			case r.producer <- m:
+			}
+		}
+
+		if err := r.spill.Pop(len(data)); err != nil {
+			r.log.Errorf("failed truncating spill of %s. %v", r.name, err)
+		}
+	}
+}
+
+// Implements the ConsumerObserver interface.
+func (r *ReliableTransport) Consumer() types.ConsumerMetrics {
+	var pending uint64
+	if r.spill != nil {
+		pending = r.spill.Pending()
+	}
+	return types.ConsumerMetrics{
+		Slow:      atomic.LoadUint64(r.slow),
+		Blocked:   time.Duration(atomic.LoadInt64(r.blocked)),
+		Spilled:   atomic.LoadUint64(r.spilled),
+		Pending:   pending,
+		Throttled: time.Duration(atomic.LoadInt64(r.throttled)),
+	}
+}
+
+// Report an asynchronous failure of the transport.
+func (r *ReliableTransport) report(kind types.FailureKind, uid types.UID, err error) {
+	r.errors.Report(&types.AsyncError{
+		Kind:       kind,
+		Peer:       r.name,
+		Identifier: uid,
+		Err:        err,
+	})
 }
@@ -0,0 +1,208 @@
+// Package transporttest provides a conformance suite that
+// every Transport implementation must pass.
+//
+// The suite verifies the contract described on the Transport
+// interface, so a custom implementation can be plugged into
+// the protocol safely. To use it, provide a factory that creates
+// a transport connected to a partition:
+//
+//	func TestMyTransport(t *testing.T) {
+//		transporttest.Run(t, func(partition types.Partition, name string) (core.Transport, error) {
+//			return NewMyTransport(partition, name)
+//		})
+//	}
+package transporttest
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+// How long the suite waits for a message.
+var Timeout = 5 * time.Second
+
+// Creates a new transport with the given name that
+// listens for messages sent to the given partition.
+type Factory func(partition types.Partition, name string) (core.Transport, error)
+
+// Executes the whole conformance suite using the given factory.
+func Run(t *testing.T, factory Factory) {
+	t.Run("UnicastDeliveredToAllListeners", func(t *testing.T) {
+		TestUnicastDeliveredToAllListeners(t, factory)
+	})
+	t.Run("BroadcastDeliveredToAllDestinations", func(t *testing.T) {
+		TestBroadcastDeliveredToAllDestinations(t, factory)
+	})
+	t.Run("OrderingFromSingleSender", func(t *testing.T) {
+		TestOrderingFromSingleSender(t, factory)
+	})
+	t.Run("CloseListenChannel", func(t *testing.T) {
+		TestCloseListenChannel(t, factory)
+	})
+}
+
+// A message sent to a partition must be delivered to
+// every transport listening on the partition.
+func TestUnicastDeliveredToAllListeners(t *testing.T, factory Factory) {
+	partition := randomPartition()
+	receivers := create(t, factory, partition, 3)
+	defer closeAll(receivers)
+	sender := create(t, factory, randomPartition(), 1)[0]
+	defer sender.Close()
+
+	message := randomMessage(partition)
+	if err := sender.Unicast(message, partition); err != nil {
+		t.Fatalf("failed unicast. %v", err)
+	}
+
+	for i, receiver := range receivers {
+		received := receive(t, receiver, 1)
+		if len(received) != 1 || received[0].Identifier != message.Identifier {
+			t.Errorf("receiver %d did not receive %s. %#v", i, message.Identifier, received)
+		}
+	}
+}
+
+// A broadcast must be delivered to every transport
+// listening on any of the destination partitions.
+func TestBroadcastDeliveredToAllDestinations(t *testing.T, factory Factory) {
+	first := randomPartition()
+	second := randomPartition()
+	receivers := append(create(t, factory, first, 2), create(t, factory, second, 2)...)
+	defer closeAll(receivers)
+	sender := create(t, factory, randomPartition(), 1)[0]
+	defer sender.Close()
+
+	message := randomMessage(first, second)
+	if err := sender.Broadcast(message); err != nil {
+		t.Fatalf("failed broadcast. %v", err)
+	}
+
+	for i, receiver := range receivers {
+		received := receive(t, receiver, 1)
+		if len(received) != 1 || received[0].Identifier != message.Identifier {
+			t.Errorf("receiver %d did not receive %s. %#v", i, message.Identifier, received)
+		}
+	}
+}
+
+// Messages from a single sender to a partition must be
+// delivered in the order they were sent. Since the delivery
+// is at least once, duplicates are ignored.
+func TestOrderingFromSingleSender(t *testing.T, factory Factory) {
+	partition := randomPartition()
+	receiver := create(t, factory, partition, 1)[0]
+	defer receiver.Close()
+	sender := create(t, factory, randomPartition(), 1)[0]
+	defer sender.Close()
+
+	size := 50
+	var sent []types.UID
+	for i := 0; i < size; i++ {
+		message := randomMessage(partition)
+		sent = append(sent, message.Identifier)
+		if err := sender.Unicast(message, partition); err != nil {
+			t.Fatalf("failed unicast %d. %v", i, err)
+		}
+	}
+
+	received := receive(t, receiver, size)
+	for i, uid := range sent {
+		if i >= len(received) {
+			t.Fatalf("received only %d of %d messages", len(received), size)
+		}
+
+		if received[i].Identifier != uid {
+			t.Fatalf("message %d out of order, expected %s found %s", i, uid, received[i].Identifier)
+		}
+	}
+}
+
+// After closing, the listen channel must be closed.
+func TestCloseListenChannel(t *testing.T, factory Factory) {
+	transport := create(t, factory, randomPartition(), 1)[0]
+	transport.Close()
+
+	deadline := time.After(Timeout)
+	for {
+		select {
+		case _, ok := <-transport.Listen():
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("listen channel not closed")
+		}
+	}
+}
+
+// Creates the given number of transports on the partition.
+func create(t *testing.T, factory Factory, partition types.Partition, size int) []core.Transport {
+	var transports []core.Transport
+	for i := 0; i < size; i++ {
+		transport, err := factory(partition, fmt.Sprintf("%s-%d", partition, i))
+		if err != nil {
+			closeAll(transports)
+			t.Fatalf("failed creating transport. %v", err)
+		}
+		transports = append(transports, transport)
+	}
+	return transports
+}
+
+// Close all the given transports.
+func closeAll(transports []core.Transport) {
+	for _, transport := range transports {
+		transport.Close()
+	}
+}
+
+// Receive the given number of distinct messages from the
+// transport, duplicated messages are discarded.
+func receive(t *testing.T, transport core.Transport, size int) []types.Message {
+	var messages []types.Message
+	seen := make(map[types.UID]bool)
+	deadline := time.After(Timeout)
+	for len(messages) < size {
+		select {
+		case m, ok := <-transport.Listen():
+			if !ok {
+				t.Errorf("listen channel closed")
+				return messages
+			}
+
+			if !seen[m.Identifier] {
+				seen[m.Identifier] = true
+				messages = append(messages, m)
+			}
+		case <-deadline:
+			t.Errorf("timeout receiving, received %d of %d", len(messages), size)
+			return messages
+		}
+	}
+	return messages
+}
+
+func randomPartition() types.Partition {
+	return types.Partition(fmt.Sprintf("transporttest-%s", helper.GenerateUID()))
+}
+
+func randomMessage(destination ...types.Partition) types.Message {
+	return types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: types.LatestProtocolVersion,
+			Type:            types.Initial,
+		},
+		Identifier: types.UID(helper.GenerateUID()),
+		Content: types.DataHolder{
+			Operation: types.Command,
+			Key:       []byte(helper.GenerateUID()),
+			Content:   []byte(helper.GenerateUID()),
+		},
+		Destination: destination,
+	}
+}
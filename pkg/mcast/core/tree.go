@@ -0,0 +1,162 @@
+package core
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"time"
+)
+
+// Holds the state of the timestamps exchanged through the tree.
+type TreeState struct {
+	// The greatest timestamp of the subtree of each child.
+	subtrees *Memo
+
+	// Synchronize access to the messages sent.
+	mutex *sync.Mutex
+
+	// The messages already sent up to the parent.
+	climbed map[types.UID]bool
+
+	// The messages already sent down to the children.
+	descended map[types.UID]bool
+}
+
+func NewTreeState() *TreeState {
+	return &TreeState{
+		subtrees:  NewMemo(),
+		mutex:     &sync.Mutex{},
+		climbed:   make(map[types.UID]bool),
+		descended: make(map[types.UID]bool),
+	}
+}
+
+// Mark the message as sent on the direction, returning false if
+// it was already sent.
+func (t *TreeState) mark(sent map[types.UID]bool, uid types.UID) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if sent[uid] {
+		return false
+	}
+	sent[uid] = true
+	return true
+}
+
+// Remove the state of the message.
+func (t *TreeState) Forget(uid types.UID) {
+	t.subtrees.Remove(uid)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.climbed, uid)
+	delete(t.descended, uid)
+}
+
+// Remove the state of the messages without a subtree timestamp
+// since the given time, except the ones kept, returning how many
+// subtree timestamps were removed.
+func (t *TreeState) Sweep(before time.Time, keep func(types.UID) bool) int {
+	removed := t.subtrees.Sweep(before, keep)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, sent := range []map[types.UID]bool{t.climbed, t.descended} {
+		for uid := range sent {
+			if !keep(uid) {
+				delete(sent, uid)
+			}
+		}
+	}
+	return removed
+}
+
+// The position of the peer partition on the exchange tree of the
+// message, if the message exchanges through the tree.
+func (p *Peer) position(message types.Message) (types.Partition, []types.Partition, int, bool) {
+	participants := p.retirement.Participants(message.Destination)
+	if !p.configuration.Tree.Applies(len(participants)) {
+		return "", nil, 0, false
+	}
+	parent, children, height := p.configuration.Tree.Position(participants, p.configuration.Partition)
+	return parent, children, height, true
+}
+
+// Start exchanging the message timestamp through the tree, returning
+// false when the message exchanges all-to-all.
+func (p *Peer) disseminate(message types.Message) bool {
+	if _, _, _, ok := p.position(message); !ok {
+		return false
+	}
+	p.climb(message)
+	return true
+}
+
+// Send the greatest timestamp of the subtree to the parent, once the
+// own partition and every child have their timestamps. The root
+// decides the final timestamp instead. The timestamps are sent apart,
+// the final timestamp is already taken once this returns.
+func (p *Peer) climb(message types.Message) {
+	parent, children, _, ok := p.position(message)
+	if !ok {
+		return
+	}
+
+	own, complete := p.received.Collect(message.Identifier, []types.Partition{p.configuration.Partition})
+	if !complete {
+		return
+	}
+	subtrees, complete := p.tree.subtrees.Collect(message.Identifier, children)
+	if !complete {
+		return
+	}
+
+	greatest := helper.MaxValue(append(subtrees, own...))
+	if len(parent) == 0 {
+		p.descend(message, greatest)
+		return
+	}
+	if p.tree.mark(p.tree.climbed, message.Identifier) {
+		up := p.branch(message, types.FlagSubtree, greatest)
+		p.invoker.Spawn(func() {
+			p.unicast(up, parent)
+		})
+	}
+}
+
+// Send the final timestamp down to the children, and take it as the
+// timestamp of every participating partition, completing the exchange.
+func (p *Peer) descend(message types.Message, final uint64) {
+	_, children, _, ok := p.position(message)
+	if !ok || !p.tree.mark(p.tree.descended, message.Identifier) {
+		return
+	}
+
+	down := p.branch(message, types.FlagFinal, final)
+	p.invoker.Spawn(func() {
+		for _, child := range children {
+			p.unicast(down, child)
+		}
+	})
+	for _, partition := range p.retirement.Participants(message.Destination) {
+		p.received.Insert(message.Identifier, partition, final)
+	}
+}
+
+// The message sent through the tree with the timestamp.
+func (p *Peer) branch(message types.Message, flag types.HeaderFlag, timestamp uint64) types.Message {
+	message.Header.Type = types.External
+	message.Header.Flags &^= types.FlagSubtree | types.FlagFinal
+	message.Header.Flags |= flag
+	message.Timestamp = timestamp
+	message.From = p.configuration.Partition
+	return message
+}
+
+// Handle the timestamp received through the tree.
+func (p *Peer) traverse(message types.Message) {
+	if message.Header.Flags.Has(types.FlagFinal) {
+		p.descend(message, message.Timestamp)
+		return
+	}
+	p.tree.subtrees.Insert(message.Identifier, message.From, message.Timestamp)
+	p.climb(message)
+}
@@ -0,0 +1,46 @@
+package definition
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+)
+
+var (
+	// Returned when the data is not a valid CBOR value.
+	ErrCBORInvalid = errors.New("invalid cbor data")
+
+	// Returned when the value can not be serialized.
+	ErrCBORUnsupported = errors.New("unsupported type for cbor")
+)
+
+// A codec that serializes values using CBOR, as defined on RFC
+// 8949, a binary format close to MessagePack and standardized.
+//
+// Structs are serialized as maps using the exported field
+// names, so fields can be added or removed between versions,
+// unknown fields are ignored when deserializing.
+type CBORCodec struct{}
+
+// Implements the Codec interface.
+func (CBORCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCBORUnsupported, err)
+	}
+	return data, nil
+}
+
+// Implements the Codec interface.
+func (CBORCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := cbor.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrCBORInvalid, err)
+	}
+	return nil
+}
+
+// Implements the IdentifiedCodec interface.
+func (CBORCodec) ID() types.CodecID {
+	return types.CodecCBOR
+}
@@ -0,0 +1,26 @@
+package definition
+
+import "github.com/jabolina/go-mcast/pkg/mcast/types"
+
+var knownCodecs = []types.IdentifiedCodec{
+	MsgpackCodec{},
+	CBORCodec{},
+	GobCodec{},
+	JSONCodec{},
+}
+
+// The codecs known on the wire, from the cheapest to the most
+// expensive to serialize a message.
+func KnownCodecs() []types.IdentifiedCodec {
+	return append([]types.IdentifiedCodec(nil), knownCodecs...)
+}
+
+// Find the known codec with the given identifier.
+func CodecOf(id types.CodecID) (types.IdentifiedCodec, bool) {
+	for _, codec := range knownCodecs {
+		if codec.ID() == id {
+			return codec, true
+		}
+	}
+	return nil, false
+}
@@ -12,3 +12,18 @@ type AlwaysConflict struct{}
 func (a AlwaysConflict) Conflict(_ types.Message, __ []types.Message) bool {
 	return true
 }
+
+// A conflict relationship where messages conflict when
+// they touch at least one common key, considering all the
+// keys declared by each message.
+type KeyConflict struct{}
+
+// Returns true if any message touches a key of the given message.
+func (k KeyConflict) Conflict(message types.Message, messages []types.Message) bool {
+	for _, other := range messages {
+		if message.Content.Overlaps(other.Content) {
+			return true
+		}
+	}
+	return false
+}
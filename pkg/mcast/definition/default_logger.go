@@ -2,8 +2,11 @@ package definition
 
 import (
 	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
 	"log"
 	"os"
+	"strings"
+	"sync"
 )
 
 const (
@@ -18,70 +21,165 @@ const (
 func NewDefaultLogger() *DefaultLogger {
 	return &DefaultLogger{
 		Logger: log.New(os.Stderr, "mcast", log.LstdFlags),
-		debug:  false,
+		levels: &subsystemLevels{
+			mutex:  &sync.RWMutex{},
+			levels: map[string]types.LogLevel{"": types.LevelInfo},
+		},
 	}
 }
 
-// Use the given log level as prefix
-func level(prefix, message string) string {
-	return fmt.Sprintf("[%s]: %s", prefix, message)
+// The levels shared by the loggers of every subsystem.
+type subsystemLevels struct {
+	mutex  *sync.RWMutex
+	levels map[string]types.LogLevel
+}
+
+// The level of the subsystem, or of its closest parent.
+func (s *subsystemLevels) level(subsystem string) types.LogLevel {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for {
+		if level, ok := s.levels[subsystem]; ok {
+			return level
+		}
+		i := strings.LastIndex(subsystem, ".")
+		if i < 0 {
+			return s.levels[""]
+		}
+		subsystem = subsystem[:i]
+	}
 }
 
 // The default logger used if the user does not provide its
 // own implementation.
+//
+// Implements the LevelLogger interface, each subsystem logs
+// with its own level, so a single component can be debugged
+// without the entries of the others.
 type DefaultLogger struct {
 	*log.Logger
-	debug bool
+
+	// The levels of every subsystem.
+	levels *subsystemLevels
+
+	// The subsystem logging, empty for the root logger.
+	subsystem string
+}
+
+// Use the given log level and the subsystem as prefix
+func (l *DefaultLogger) level(prefix, message string) string {
+	if len(l.subsystem) > 0 {
+		return fmt.Sprintf("[%s %s]: %s", prefix, l.subsystem, message)
+	}
+	return fmt.Sprintf("[%s]: %s", prefix, message)
+}
+
+func (l *DefaultLogger) enabled(level types.LogLevel) bool {
+	return l.levels.level(l.subsystem) <= level
+}
+
+// Implements the LevelLogger interface.
+func (l *DefaultLogger) Subsystem(name string) types.Logger {
+	return &DefaultLogger{
+		Logger:    l.Logger,
+		levels:    l.levels,
+		subsystem: name,
+	}
+}
+
+// Implements the LevelLogger interface.
+func (l *DefaultLogger) SetLevel(subsystem string, level types.LogLevel) {
+	l.levels.mutex.Lock()
+	defer l.levels.mutex.Unlock()
+	l.levels.levels[subsystem] = level
+}
+
+// Implements the LevelLogger interface.
+// The root level is never removed.
+func (l *DefaultLogger) ResetLevel(subsystem string) {
+	if len(subsystem) == 0 {
+		return
+	}
+	l.levels.mutex.Lock()
+	defer l.levels.mutex.Unlock()
+	delete(l.levels.levels, subsystem)
+}
+
+// Implements the LevelLogger interface.
+func (l *DefaultLogger) Levels() map[string]types.LogLevel {
+	l.levels.mutex.RLock()
+	defer l.levels.mutex.RUnlock()
+	levels := make(map[string]types.LogLevel, len(l.levels.levels))
+	for subsystem, level := range l.levels.levels {
+		levels[subsystem] = level
+	}
+	return levels
 }
 
 func (l *DefaultLogger) Info(v ...interface{}) {
-	l.Output(calldepth, level(info, fmt.Sprint(v...)))
+	if l.enabled(types.LevelInfo) {
+		l.Output(calldepth, l.level(info, fmt.Sprint(v...)))
+	}
 }
 
 func (l *DefaultLogger) Infof(format string, v ...interface{}) {
-	l.Output(calldepth, level(info, fmt.Sprintf(format, v...)))
+	if l.enabled(types.LevelInfo) {
+		l.Output(calldepth, l.level(info, fmt.Sprintf(format, v...)))
+	}
 }
 
 func (l *DefaultLogger) Warn(v ...interface{}) {
-	l.Output(calldepth, level(warn, fmt.Sprint(v...)))
+	if l.enabled(types.LevelWarn) {
+		l.Output(calldepth, l.level(warn, fmt.Sprint(v...)))
+	}
 }
 
 func (l *DefaultLogger) Warnf(format string, v ...interface{}) {
-	l.Output(calldepth, level(warn, fmt.Sprintf(format, v...)))
+	if l.enabled(types.LevelWarn) {
+		l.Output(calldepth, l.level(warn, fmt.Sprintf(format, v...)))
+	}
 }
 
 func (l *DefaultLogger) Error(v ...interface{}) {
-	l.Output(calldepth, level(errorl, fmt.Sprint(v...)))
+	if l.enabled(types.LevelError) {
+		l.Output(calldepth, l.level(errorl, fmt.Sprint(v...)))
+	}
 }
 
 func (l *DefaultLogger) Errorf(format string, v ...interface{}) {
-	l.Output(calldepth, level(errorl, fmt.Sprintf(format, v...)))
+	if l.enabled(types.LevelError) {
+		l.Output(calldepth, l.level(errorl, fmt.Sprintf(format, v...)))
+	}
 }
 
 func (l *DefaultLogger) Debug(v ...interface{}) {
-	if l.debug {
-		l.Output(calldepth, level(debug, fmt.Sprint(v...)))
+	if l.enabled(types.LevelDebug) {
+		l.Output(calldepth, l.level(debug, fmt.Sprint(v...)))
 	}
 }
 
 func (l *DefaultLogger) Debugf(format string, v ...interface{}) {
-	if l.debug {
-		l.Output(calldepth, level(debug, fmt.Sprintf(format, v...)))
+	if l.enabled(types.LevelDebug) {
+		l.Output(calldepth, l.level(debug, fmt.Sprintf(format, v...)))
 	}
 }
 
 func (l *DefaultLogger) ToggleDebug(value bool) bool {
-	l.debug = value
-	return l.debug
+	if value {
+		l.SetLevel("", types.LevelDebug)
+	} else {
+		l.SetLevel("", types.LevelInfo)
+	}
+	return value
 }
 
 func (l *DefaultLogger) Fatal(v ...interface{}) {
-	l.Output(calldepth, level(fatal, fmt.Sprint(v...)))
+	l.Output(calldepth, l.level(fatal, fmt.Sprint(v...)))
 	os.Exit(1)
 }
 
 func (l *DefaultLogger) Fatalf(format string, v ...interface{}) {
-	l.Output(calldepth, level(fatal, fmt.Sprintf(format, v...)))
+	l.Output(calldepth, l.level(fatal, fmt.Sprintf(format, v...)))
 	os.Exit(1)
 }
 
@@ -0,0 +1,60 @@
+package definition
+
+import (
+	"sync"
+	"time"
+)
+
+// Calls a flush function on a fixed interval, on the background,
+// so the writes batched by the SyncBatched policy are synced even
+// when no other write arrives after them.
+type flusher struct {
+	// Synchronize starting and stopping.
+	mutex *sync.Mutex
+
+	// Closed to stop the flushing.
+	stop chan struct{}
+
+	// Closed once the flushing stopped.
+	done chan struct{}
+}
+
+func newFlusher() *flusher {
+	return &flusher{mutex: &sync.Mutex{}}
+}
+
+// Start calling the flush function every interval, stopping the
+// previous flushing, if any.
+func (f *flusher) start(interval time.Duration, flush func()) {
+	f.halt()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.stop = make(chan struct{})
+	f.done = make(chan struct{})
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}(f.stop, f.done)
+}
+
+// Stop the flushing and wait until the last flush returns. This
+// must not be called while holding a lock the flush function uses.
+func (f *flusher) halt() {
+	f.mutex.Lock()
+	stop, done := f.stop, f.done
+	f.stop, f.done = nil, nil
+	f.mutex.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,31 @@
+package definition
+
+import (
+	"bytes"
+	"encoding/gob"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+)
+
+// A codec that serializes values using the Go gob format.
+// Each value is serialized with its own type description,
+// so the values can be deserialized independently.
+type GobCodec struct{}
+
+// Implements the Codec interface.
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Implements the Codec interface.
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Implements the IdentifiedCodec interface.
+func (GobCodec) ID() types.CodecID {
+	return types.CodecGob
+}
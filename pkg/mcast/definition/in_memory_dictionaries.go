@@ -0,0 +1,48 @@
+package definition
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Returned when the dictionary version is not stored.
+var ErrUnknownDictionary = errors.New("unknown dictionary")
+
+// Provides an implementation of the DictionaryStore interface using
+// only the memory, so the dictionaries are shared only by the peers
+// on the same process.
+type InMemoryDictionaries struct {
+	// Synchronize access to the dictionaries.
+	mutex *sync.RWMutex
+
+	// The dictionaries by version.
+	dictionaries map[uint32][]byte
+}
+
+// Creates a new store without dictionaries.
+func NewInMemoryDictionaries() *InMemoryDictionaries {
+	return &InMemoryDictionaries{
+		mutex:        &sync.RWMutex{},
+		dictionaries: make(map[uint32][]byte),
+	}
+}
+
+// Implements the DictionaryStore interface.
+func (d *InMemoryDictionaries) Put(version uint32, dictionary []byte) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.dictionaries[version] = append([]byte(nil), dictionary...)
+	return nil
+}
+
+// Implements the DictionaryStore interface.
+func (d *InMemoryDictionaries) Get(version uint32) ([]byte, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	dictionary, ok := d.dictionaries[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownDictionary, version)
+	}
+	return dictionary, nil
+}
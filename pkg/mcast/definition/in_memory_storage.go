@@ -1,26 +1,86 @@
 package definition
 
 import (
+	"container/list"
 	"fmt"
 	"sync"
+	"time"
 )
 
+// Options for the in-memory storage. The zero value keeps every
+// entry forever and copies the values on every operation.
+type InMemoryStorageOptions struct {
+	// How long an entry lives after set. Zero means forever.
+	// Can be changed for a single entry using SetWithTTL.
+	TTL time.Duration
+
+	// Maximum number of entries, when exceeded the least
+	// recently used entries are evicted. Zero means unbounded.
+	MaxEntries int
+
+	// Maximum size in bytes of all keys and values, when exceeded
+	// the least recently used entries are evicted. Zero means unbounded.
+	MaxBytes int
+
+	// Do not copy the values on Set and Get. The callers must
+	// not modify the slices after set or returned.
+	NoCopy bool
+}
+
+// A single value stored in memory.
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
 // Provides a basic implementation of the Storage interface
 // that will use only the memory, no stable storage is provided
 // with this implementation. Is up to the user to use its desired storage.
+//
+// When bounded, this works as a cache and older values are evicted,
+// so should not be used as the state machine storage.
 type InMemoryStorage struct {
 	// Mutex for operations executions
 	mutex *sync.Mutex
 
+	// The storage options.
+	options InMemoryStorageOptions
+
 	// The in-memory storage
-	kv map[string][]byte
+	kv map[string]*list.Element
+
+	// Entries ordered from the most to the least recently used.
+	lru *list.List
+
+	// Current size of all keys and values.
+	size int
 }
 
 // Implements the Set for the Storage interface
 func (s *InMemoryStorage) Set(key []byte, value []byte) error {
+	return s.SetWithTTL(key, value, s.options.TTL)
+}
+
+// Set the value associated with the key that expires after the
+// given duration. A zero duration means the value never expires.
+func (s *InMemoryStorage) SetWithTTL(key []byte, value []byte, ttl time.Duration) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	s.kv[string(key)] = value
+	entry := &memoryEntry{
+		key:   string(key),
+		value: s.copy(value),
+	}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+
+	if element, ok := s.kv[entry.key]; ok {
+		s.remove(element)
+	}
+	s.kv[entry.key] = s.lru.PushFront(entry)
+	s.size += len(entry.key) + len(entry.value)
+	s.evict()
 	return nil
 }
 
@@ -29,17 +89,95 @@ func (s *InMemoryStorage) Set(key []byte, value []byte) error {
 func (s *InMemoryStorage) Get(key []byte) ([]byte, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	value, ok := s.kv[string(key)]
+	element, ok := s.kv[string(key)]
 	if !ok {
 		return nil, fmt.Errorf("not found value for %s", string(key))
 	}
-	return value, nil
+
+	entry := element.Value.(*memoryEntry)
+	if s.expired(entry, time.Now()) {
+		s.remove(element)
+		return nil, fmt.Errorf("not found value for %s", string(key))
+	}
+	s.lru.MoveToFront(element)
+	return s.copy(entry.value), nil
+}
+
+// Remove all the expired entries.
+func (s *InMemoryStorage) Purge() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	for element := s.lru.Back(); element != nil; {
+		previous := element.Prev()
+		if s.expired(element.Value.(*memoryEntry), now) {
+			s.remove(element)
+		}
+		element = previous
+	}
+}
+
+// How many entries are stored, including the expired
+// entries not removed yet.
+func (s *InMemoryStorage) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lru.Len()
+}
+
+// Implements the EvictingStorage interface.
+// The storage evicts entries when it has a TTL or is bounded. The
+// entries set using SetWithTTL expire regardless.
+func (s *InMemoryStorage) Evicts() bool {
+	return s.options.TTL > 0 || s.options.MaxEntries > 0 || s.options.MaxBytes > 0
+}
+
+// Evict the least recently used entries while the bounds
+// are exceeded. This method should be called while holding the mutex.
+func (s *InMemoryStorage) evict() {
+	for s.lru.Len() > 1 && s.exceeded() {
+		s.remove(s.lru.Back())
+	}
+}
+
+// Verify if the bounds are exceeded.
+// This method should be called while holding the mutex.
+func (s *InMemoryStorage) exceeded() bool {
+	if s.options.MaxEntries > 0 && s.lru.Len() > s.options.MaxEntries {
+		return true
+	}
+	return s.options.MaxBytes > 0 && s.size > s.options.MaxBytes
+}
+
+// This method should be called while holding the mutex.
+func (s *InMemoryStorage) remove(element *list.Element) {
+	entry := s.lru.Remove(element).(*memoryEntry)
+	delete(s.kv, entry.key)
+	s.size -= len(entry.key) + len(entry.value)
+}
+
+func (s *InMemoryStorage) expired(entry *memoryEntry, now time.Time) bool {
+	return !entry.expires.IsZero() && !now.Before(entry.expires)
+}
+
+func (s *InMemoryStorage) copy(value []byte) []byte {
+	if s.options.NoCopy || value == nil {
+		return value
+	}
+	return append(make([]byte, 0, len(value)), value...)
 }
 
 // Create a new storage using memory only.
 func NewInMemoryStorage() *InMemoryStorage {
+	return NewInMemoryStorageWith(InMemoryStorageOptions{})
+}
+
+// Create a new storage using memory only with the given options.
+func NewInMemoryStorageWith(options InMemoryStorageOptions) *InMemoryStorage {
 	return &InMemoryStorage{
-		mutex: &sync.Mutex{},
-		kv:    make(map[string][]byte),
+		mutex:   &sync.Mutex{},
+		options: options,
+		kv:      make(map[string]*list.Element),
+		lru:     list.New(),
 	}
 }
@@ -0,0 +1,25 @@
+package definition
+
+import (
+	"encoding/json"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+)
+
+// A codec that serializes values using JSON.
+// This is the default codec used by the transport.
+type JSONCodec struct{}
+
+// Implements the Codec interface.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Implements the Codec interface.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Implements the IdentifiedCodec interface.
+func (JSONCodec) ID() types.CodecID {
+	return types.CodecJSON
+}
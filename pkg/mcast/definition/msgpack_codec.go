@@ -0,0 +1,46 @@
+package definition
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	// Returned when the data is not a valid MessagePack value.
+	ErrMsgpackInvalid = errors.New("invalid msgpack data")
+
+	// Returned when the value can not be serialized.
+	ErrMsgpackUnsupported = errors.New("unsupported type for msgpack")
+)
+
+// A codec that serializes values using MessagePack, a binary
+// format more compact and faster to parse than JSON.
+//
+// Structs are serialized as maps using the exported field
+// names, so fields can be added or removed between versions,
+// unknown fields are ignored when deserializing.
+type MsgpackCodec struct{}
+
+// Implements the Codec interface.
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMsgpackUnsupported, err)
+	}
+	return data, nil
+}
+
+// Implements the Codec interface.
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := msgpack.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrMsgpackInvalid, err)
+	}
+	return nil
+}
+
+// Implements the IdentifiedCodec interface.
+func (MsgpackCodec) ID() types.CodecID {
+	return types.CodecMsgpack
+}
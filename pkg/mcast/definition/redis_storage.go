@@ -0,0 +1,199 @@
+package definition
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned when Redis replies with something not expected.
+	ErrRedisProtocol = errors.New("invalid redis reply")
+)
+
+// Options for the Redis storage.
+type RedisStorageOptions struct {
+	// The Redis address, e.g., 127.0.0.1:6379.
+	Address string
+
+	// The password, if authentication is required.
+	Password string
+
+	// The database index.
+	Database int
+
+	// The hash where all values are stored. Each partition
+	// should use its own hash, e.g., mcast:<partition>.
+	Hash string
+
+	// Timeout for each operation. Defaults to 5 seconds.
+	Timeout time.Duration
+}
+
+// Implements the Storage interface using a Redis hash, so an
+// existing Redis deployment can be used as the stable storage.
+// The commands are sent through a single connection, that is
+// opened again after a failure.
+type RedisStorage struct {
+	// Synchronize access to the connection.
+	mutex *sync.Mutex
+
+	// The storage options.
+	options RedisStorageOptions
+
+	// The current connection, nil if not connected.
+	conn redis.Conn
+}
+
+// Create a new storage using Redis. The connection is verified
+// before returning.
+func NewRedisStorage(options RedisStorageOptions) (*RedisStorage, error) {
+	if options.Timeout <= 0 {
+		options.Timeout = 5 * time.Second
+	}
+	r := &RedisStorage{
+		mutex:   &sync.Mutex{},
+		options: options,
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.connect(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Implements the Storage interface.
+func (r *RedisStorage) Set(key []byte, value []byte) error {
+	_, err := r.pipeline([]interface{}{"HSET", r.options.Hash, key, value})
+	return err
+}
+
+// Implements the Storage interface.
+// On this implementation if no value was found, an error will be returned.
+func (r *RedisStorage) Get(key []byte) ([]byte, error) {
+	values, err := r.GetMany([][]byte{key})
+	if err != nil {
+		return nil, err
+	}
+
+	if values[0] == nil {
+		return nil, fmt.Errorf("not found value for %s", string(key))
+	}
+	return values[0], nil
+}
+
+// Read the values associated with all keys, pipelining the
+// commands on a single round trip. Keys not found have a
+// nil value at the same position.
+func (r *RedisStorage) GetMany(keys [][]byte) ([][]byte, error) {
+	commands := make([][]interface{}, len(keys))
+	for i, key := range keys {
+		commands[i] = []interface{}{"HGET", r.options.Hash, key}
+	}
+
+	replies, err := r.pipeline(commands...)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, len(keys))
+	for i, reply := range replies {
+		if reply == nil {
+			continue
+		}
+		value, err := redis.Bytes(reply, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRedisProtocol, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// Close the connection.
+func (r *RedisStorage) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// Send all commands and read all replies. A command replied with
+// an error fails the call. If the connection fails it is closed
+// and opened again on the next call.
+func (r *RedisStorage) pipeline(commands ...[]interface{}) ([]interface{}, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.conn == nil {
+		if err := r.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	replies, err := r.roundTrip(commands)
+	if r.conn.Err() != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+	return replies, err
+}
+
+// Open the connection, authenticate and select the database.
+// This method should be called while holding the mutex.
+func (r *RedisStorage) connect() error {
+	conn, err := redis.Dial("tcp", r.options.Address,
+		redis.DialConnectTimeout(r.options.Timeout),
+		redis.DialReadTimeout(r.options.Timeout),
+		redis.DialWriteTimeout(r.options.Timeout),
+		redis.DialPassword(r.options.Password),
+		redis.DialDatabase(r.options.Database))
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn.Do("PING"); err != nil {
+		conn.Close()
+		return err
+	}
+	r.conn = conn
+	return nil
+}
+
+// This method should be called while holding the mutex.
+func (r *RedisStorage) roundTrip(commands [][]interface{}) ([]interface{}, error) {
+	for _, command := range commands {
+		if err := r.conn.Send(command[0].(string), command[1:]...); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.conn.Flush(); err != nil {
+		return nil, err
+	}
+
+	var failure error
+	replies := make([]interface{}, len(commands))
+	for i := range commands {
+		reply, err := r.conn.Receive()
+		if _, ok := err.(redis.Error); ok {
+			// Keep reading, so the replies left do not
+			// answer the next commands.
+			if failure == nil {
+				failure = err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, failure
+}
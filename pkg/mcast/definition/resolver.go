@@ -0,0 +1,202 @@
+package definition
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A resolver where the partition name is the transport address.
+// This is the default resolver.
+type IdentityResolver struct{}
+
+// Implements the Resolver interface.
+func (IdentityResolver) Resolve(partition types.Partition) (types.Address, error) {
+	return types.Address(partition), nil
+}
+
+// A resolver where the addresses are known beforehand and do not
+// change. Partitions not present are resolved to their own name.
+type StaticResolver map[types.Partition]types.Address
+
+// Implements the Resolver interface.
+func (s StaticResolver) Resolve(partition types.Partition) (types.Address, error) {
+	if address, ok := s[partition]; ok {
+		return address, nil
+	}
+	return types.Address(partition), nil
+}
+
+// A resolver that reads the addresses from a JSON file mapping
+// each partition name to its address. The file is read again
+// when modified, so the addresses can change while running.
+type FileResolver struct {
+	// Synchronize access to the addresses.
+	mutex *sync.Mutex
+
+	// The file path.
+	path string
+
+	// When the file was last modified.
+	modified time.Time
+
+	// The addresses read from the file.
+	addresses map[types.Partition]types.Address
+}
+
+// Creates a new resolver reading from the given file.
+func NewFileResolver(path string) (*FileResolver, error) {
+	f := &FileResolver{
+		mutex: &sync.Mutex{},
+		path:  path,
+	}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Read the file again if it was modified.
+// This method should be called while holding the mutex.
+func (f *FileResolver) reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+
+	if f.addresses != nil && !info.ModTime().After(f.modified) {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	addresses := make(map[types.Partition]types.Address)
+	if err := json.Unmarshal(data, &addresses); err != nil {
+		return err
+	}
+	f.addresses = addresses
+	f.modified = info.ModTime()
+	return nil
+}
+
+// Implements the Resolver interface.
+func (f *FileResolver) Resolve(partition types.Partition) (types.Address, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.reload(); err != nil {
+		return "", err
+	}
+
+	address, ok := f.addresses[partition]
+	if !ok {
+		return "", fmt.Errorf("partition %s not found on %s", partition, f.path)
+	}
+	return address, nil
+}
+
+// A resolver using DNS SRV records. The partition is resolved
+// by looking up _service._proto.partition.domain and the address
+// is the target and port with the lowest priority.
+type DNSResolver struct {
+	// The service name of the SRV record.
+	Service string
+
+	// The protocol of the SRV record.
+	Proto string
+
+	// The domain appended to the partition name.
+	Domain string
+}
+
+// Implements the Resolver interface.
+func (d DNSResolver) Resolve(partition types.Partition) (types.Address, error) {
+	name := string(partition)
+	if len(d.Domain) > 0 {
+		name = fmt.Sprintf("%s.%s", partition, d.Domain)
+	}
+
+	_, records, err := net.LookupSRV(d.Service, d.Proto, name)
+	if err != nil {
+		return "", err
+	}
+
+	if len(records) == 0 {
+		return "", fmt.Errorf("no SRV record for partition %s", partition)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Priority < records[j].Priority
+	})
+	return types.Address(net.JoinHostPort(records[0].Target, fmt.Sprint(records[0].Port))), nil
+}
+
+// A resolver using the Consul catalog. Each partition is a
+// service registered on Consul and the address is the first
+// healthy instance found.
+type ConsulResolver struct {
+	// The Consul agent address, e.g., http://127.0.0.1:8500.
+	Agent string
+
+	// Prefix added to the partition name to build the
+	// service name.
+	Prefix string
+
+	// The HTTP client used to query the agent.
+	Client *http.Client
+}
+
+// A single service instance returned by Consul.
+type consulService struct {
+	Service struct {
+		Address string
+		Port    int
+	}
+	Node struct {
+		Address string
+	}
+}
+
+// Implements the Resolver interface.
+func (c ConsulResolver) Resolve(partition types.Partition) (types.Address, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	service := url.PathEscape(c.Prefix + string(partition))
+	res, err := client.Get(fmt.Sprintf("%s/v1/health/service/%s?passing=true", c.Agent, service))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("consul returned %s resolving %s", res.Status, partition)
+	}
+
+	var services []consulService
+	if err := json.NewDecoder(res.Body).Decode(&services); err != nil {
+		return "", err
+	}
+
+	if len(services) == 0 {
+		return "", fmt.Errorf("no healthy instance for partition %s", partition)
+	}
+
+	host := services[0].Service.Address
+	if len(host) == 0 {
+		host = services[0].Node.Address
+	}
+	return types.Address(net.JoinHostPort(host, fmt.Sprint(services[0].Service.Port))), nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package definition
+
+import "os"
+
+// The platform does not map the segments, they are read from the file.
+func mapSegment(file *os.File, size int64) ([]byte, error) {
+	return nil, nil
+}
+
+// Nothing to release, the segments are not mapped.
+func unmapSegment(data []byte) error {
+	return nil
+}
@@ -0,0 +1,493 @@
+package definition
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The size of each segment file when not given.
+	DefaultSegmentSize = 64 * 1024 * 1024
+
+	// Suffix of the segment files.
+	segmentSuffix = ".segment"
+)
+
+var (
+	// Returned when the record does not fit on a segment.
+	ErrRecordTooLarge = errors.New("record larger than the segment")
+
+	// Returned when appending a record without data, since an
+	// empty header marks the end of the segment.
+	ErrEmptyRecord = errors.New("empty record")
+)
+
+// A single segment file, holding consecutive records.
+type segment struct {
+	// The index of the first record.
+	first uint64
+
+	// The segment file, with the size of the segment.
+	file *os.File
+
+	// The file mapped on memory, nil when the platform
+	// does not support it and the file is read instead.
+	data []byte
+
+	// The size of the segment file.
+	capacity int64
+
+	// Where each record starts.
+	offsets []int64
+
+	// The bytes used by the records.
+	size int64
+}
+
+// Implements the Log interface using append only segment files on
+// a directory. Each segment is created with its full size and mapped
+// on memory for reading, once a record does not fit the segment a
+// new one is created. Each record has the same header of the write
+// ahead log: the payload length and checksum.
+//
+// When opening, the records of every segment are verified. A record
+// partially written at the end of the last segment, when the process
+// crashed while appending it, is discarded. A corrupted record on
+// any other position fails with ErrCorruptedLog.
+type SegmentLog struct {
+	// Synchronize access to the segments.
+	mutex *sync.Mutex
+
+	// Where the segment files are.
+	directory string
+
+	// The size of each segment file.
+	size int64
+
+	// The segments, oldest first. The last is the one appending.
+	segments []*segment
+
+	// When the segments are synced.
+	durability types.Durability
+
+	// When the segment was synced the last time.
+	synced time.Time
+
+	// If there are records appended after the last sync.
+	dirty bool
+
+	// Syncs the batched records on the background.
+	flusher *flusher
+}
+
+// Open the log on the directory, creating it if missing, with
+// segments of the given size. Zero uses the DefaultSegmentSize.
+func NewSegmentLog(directory string, size int64) (*SegmentLog, error) {
+	if size <= 0 {
+		size = DefaultSegmentSize
+	}
+	if size <= walHeaderSize {
+		return nil, fmt.Errorf("segment size %d smaller than a record", size)
+	}
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, err
+	}
+
+	l := &SegmentLog{
+		mutex:     &sync.Mutex{},
+		directory: directory,
+		size:      size,
+		flusher:   newFlusher(),
+	}
+	if err := l.open(); err != nil {
+		l.release()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Implements the Log interface.
+// The records are synced following the durability policy.
+func (l *SegmentLog) Append(records ...[]byte) (uint64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.segments == nil {
+		return 0, os.ErrClosed
+	}
+	for _, record := range records {
+		if len(record) == 0 {
+			return 0, ErrEmptyRecord
+		}
+		if int64(walHeaderSize+len(record)) > l.size {
+			return 0, fmt.Errorf("%w: %d bytes", ErrRecordTooLarge, len(record))
+		}
+	}
+
+	first := l.last() + 1
+	for _, record := range records {
+		current := l.segments[len(l.segments)-1]
+		if current.size+int64(walHeaderSize+len(record)) > l.size {
+			if err := current.file.Sync(); err != nil {
+				return 0, err
+			}
+			next, err := l.create(l.last() + 1)
+			if err != nil {
+				return 0, err
+			}
+			l.segments = append(l.segments, next)
+			current = next
+		}
+
+		data := make([]byte, walHeaderSize, walHeaderSize+len(record))
+		binary.BigEndian.PutUint32(data[0:4], uint32(len(record)))
+		binary.BigEndian.PutUint32(data[4:8], crc32.ChecksumIEEE(record))
+		if _, err := current.file.WriteAt(append(data, record...), current.size); err != nil {
+			return 0, err
+		}
+		current.offsets = append(current.offsets, current.size)
+		current.size += int64(len(data) + len(record))
+	}
+	return first, l.sync()
+}
+
+// Implements the Log interface.
+func (l *SegmentLog) Get(index uint64) ([]byte, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.segments == nil {
+		return nil, os.ErrClosed
+	}
+	if index < l.first() || index > l.last() || index == 0 {
+		return nil, fmt.Errorf("%w: %d", types.ErrLogIndex, index)
+	}
+
+	i := sort.Search(len(l.segments), func(i int) bool {
+		return l.segments[i].first > index
+	}) - 1
+	s := l.segments[i]
+	payload, _, err := s.read(s.offsets[index-s.first])
+	return payload, err
+}
+
+// Implements the Log interface.
+func (l *SegmentLog) FirstIndex() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.first()
+}
+
+// Implements the Log interface.
+func (l *SegmentLog) LastIndex() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.last()
+}
+
+// Implements the Log interface.
+// Only whole segments are removed, the segment appending is kept.
+func (l *SegmentLog) Compact(index uint64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for len(l.segments) > 1 && l.segments[1].first <= index {
+		s := l.segments[0]
+		if err := s.close(); err != nil {
+			return err
+		}
+		if err := os.Remove(s.file.Name()); err != nil {
+			return err
+		}
+		l.segments = l.segments[1:]
+	}
+	return nil
+}
+
+// Implements the Log interface.
+func (l *SegmentLog) Sync() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.segments == nil {
+		return os.ErrClosed
+	}
+	if err := l.segments[len(l.segments)-1].file.Sync(); err != nil {
+		return err
+	}
+	l.synced = time.Now()
+	l.dirty = false
+	return nil
+}
+
+// Implements the Log interface.
+func (l *SegmentLog) Close() error {
+	l.flusher.halt()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.segments == nil {
+		return nil
+	}
+	err := l.segments[len(l.segments)-1].file.Sync()
+	if releaseErr := l.release(); err == nil {
+		err = releaseErr
+	}
+	return err
+}
+
+// Implements the DurableStorage interface.
+// When not syncing on every append, a record appended but not
+// synced is lost if the machine crashes. When batching, the records
+// are synced at most one interval after they are appended.
+func (l *SegmentLog) SetDurability(durability types.Durability) {
+	l.flusher.halt()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.durability = durability
+	if durability.Policy == types.SyncBatched && durability.Interval > 0 {
+		l.flusher.start(durability.Interval, l.flush)
+	}
+}
+
+// Sync the records appended since the last sync, if any.
+func (l *SegmentLog) flush() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.dirty || l.segments == nil {
+		return
+	}
+	if err := l.segments[len(l.segments)-1].file.Sync(); err != nil {
+		return
+	}
+	l.synced = time.Now()
+	l.dirty = false
+}
+
+// Open the existing segments, or create the first one.
+func (l *SegmentLog) open() error {
+	files, err := ioutil.ReadDir(l.directory)
+	if err != nil {
+		return err
+	}
+
+	var firsts []uint64
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		firsts = append(firsts, first)
+	}
+	sort.Slice(firsts, func(i, j int) bool {
+		return firsts[i] < firsts[j]
+	})
+
+	if len(firsts) == 0 {
+		s, err := l.create(1)
+		if err != nil {
+			return err
+		}
+		l.segments = append(l.segments, s)
+		return nil
+	}
+
+	for i, first := range firsts {
+		s, err := l.load(first, i == len(firsts)-1)
+		if err != nil {
+			return err
+		}
+		if i > 0 && first != l.last()+1 {
+			s.close()
+			return fmt.Errorf("%w: segment %d does not follow %d", ErrCorruptedLog, first, l.last())
+		}
+		l.segments = append(l.segments, s)
+	}
+	return nil
+}
+
+// Create a new segment starting at the index.
+func (l *SegmentLog) create(first uint64) (*segment, error) {
+	file, err := os.OpenFile(l.path(first), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := file.Truncate(l.size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return l.mapped(first, file)
+}
+
+// Open the segment starting at the index, verifying its records.
+// Only the last segment can end on a partial record, the bytes
+// after the last valid record are zeroed so the next appends
+// do not leave garbage after them.
+func (l *SegmentLog) load(first uint64, last bool) (*segment, error) {
+	file, err := os.OpenFile(l.path(first), os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := l.mapped(first, file)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity := s.capacity
+	for s.size+walHeaderSize <= capacity {
+		_, length, err := s.read(s.size)
+		if err == nil && length == 0 {
+			return s, nil
+		}
+		if err != nil {
+			if !last {
+				s.close()
+				return nil, fmt.Errorf("%w: segment %d at %d", ErrCorruptedLog, first, s.size)
+			}
+			break
+		}
+		s.offsets = append(s.offsets, s.size)
+		s.size += walHeaderSize + length
+	}
+
+	if last && s.size < capacity {
+		if _, err := file.WriteAt(make([]byte, capacity-s.size), s.size); err != nil {
+			s.close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Create the segment for the file, mapping it on memory.
+func (l *SegmentLog) mapped(first uint64, file *os.File) (*segment, error) {
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	data, err := mapSegment(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &segment{first: first, file: file, data: data, capacity: info.Size()}, nil
+}
+
+// Sync the segment appending following the durability policy.
+// This method should be called while holding the mutex.
+func (l *SegmentLog) sync() error {
+	switch l.durability.Policy {
+	case types.NoSync:
+		return nil
+	case types.SyncBatched:
+		if time.Since(l.synced) < l.durability.Interval {
+			l.dirty = true
+			return nil
+		}
+	}
+
+	if err := l.segments[len(l.segments)-1].file.Sync(); err != nil {
+		return err
+	}
+	l.synced = time.Now()
+	l.dirty = false
+	return nil
+}
+
+// Close every segment.
+func (l *SegmentLog) release() error {
+	var err error
+	for _, s := range l.segments {
+		if closeErr := s.close(); err == nil {
+			err = closeErr
+		}
+	}
+	l.segments = nil
+	return err
+}
+
+// The index of the first record.
+// This method should be called while holding the mutex.
+func (l *SegmentLog) first() uint64 {
+	if l.last() == 0 {
+		return 0
+	}
+	return l.segments[0].first
+}
+
+// The index of the last record.
+// This method should be called while holding the mutex.
+func (l *SegmentLog) last() uint64 {
+	if len(l.segments) == 0 {
+		return 0
+	}
+	s := l.segments[len(l.segments)-1]
+	return s.first + uint64(len(s.offsets)) - 1
+}
+
+func (l *SegmentLog) path(first uint64) string {
+	return filepath.Join(l.directory, fmt.Sprintf("%020d%s", first, segmentSuffix))
+}
+
+// Read the record at the offset, returning the payload and its
+// length. An empty header is the end of the segment, with a
+// zero length.
+func (s *segment) read(offset int64) ([]byte, int64, error) {
+	header := make([]byte, walHeaderSize)
+	if err := s.readAt(header, offset); err != nil {
+		return nil, 0, err
+	}
+
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	if length == 0 && checksum == 0 {
+		return nil, 0, nil
+	}
+	if offset+walHeaderSize+length > s.capacity {
+		return nil, 0, ErrCorruptedLog
+	}
+
+	payload := make([]byte, length)
+	if err := s.readAt(payload, offset+walHeaderSize); err != nil {
+		return nil, 0, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, 0, ErrCorruptedLog
+	}
+	return payload, length, nil
+}
+
+// Read from the memory mapping, or from the file if not mapped.
+func (s *segment) readAt(data []byte, offset int64) error {
+	if s.data == nil {
+		_, err := s.file.ReadAt(data, offset)
+		return err
+	}
+
+	copy(data, s.data[offset:])
+	return nil
+}
+
+func (s *segment) close() error {
+	err := unmapSegment(s.data)
+	s.data = nil
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
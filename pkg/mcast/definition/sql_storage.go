@@ -0,0 +1,300 @@
+package definition
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"regexp"
+	"sync/atomic"
+)
+
+var (
+	// Returned when the table name is not a valid identifier.
+	ErrInvalidTableName = errors.New("invalid table name")
+
+	// Only simple identifiers are accepted as table names,
+	// since they are concatenated into the statements.
+	tableNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// The schema migrations, applied in order. The version of each
+// migration is its position starting at 1, so migrations must
+// only be appended. The table prefix replaces the %[1]s verb.
+var sqlMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS %[1]s_values (
+		key BYTEA PRIMARY KEY,
+		value BYTEA NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS %[1]s_log (
+		sequence BIGSERIAL PRIMARY KEY,
+		identifier TEXT NOT NULL UNIQUE,
+		key BYTEA NOT NULL,
+		timestamp BIGINT NOT NULL,
+		data BYTEA,
+		extensions BYTEA,
+		committed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS %[1]s_log_key ON %[1]s_log (key, sequence)`,
+}
+
+// Implements both the Storage and the StateMachine interfaces using
+// a Postgres database. Every committed entry is appended to a log
+// table and the value is set in the same transaction, so the log is
+// an auditable history consistent with the values.
+//
+// The database driver is up to the user, any driver registered
+// for Postgres on database/sql can be used.
+type SQLStorage struct {
+	// The database connection pool.
+	db *sql.DB
+
+	// Prefix of all tables.
+	table string
+
+	// The durability policy, accessed atomically.
+	policy int32
+}
+
+// Create a new storage using the given database and table prefix.
+// The pending schema migrations are applied before returning.
+func NewSQLStorage(db *sql.DB, table string) (*SQLStorage, error) {
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTableName, table)
+	}
+
+	s := &SQLStorage{db: db, table: table}
+	if err := s.Migrate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Apply the migrations not applied yet. Each migration is applied
+// in its own transaction with the version update.
+func (s *SQLStorage) Migrate() error {
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_migrations (version INTEGER PRIMARY KEY)", s.table)
+	if _, err := s.db.Exec(create); err != nil {
+		return err
+	}
+
+	var version int
+	query := fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s_migrations", s.table)
+	if err := s.db.QueryRow(query).Scan(&version); err != nil {
+		return err
+	}
+
+	for ; version < len(sqlMigrations); version++ {
+		err := s.transaction(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(fmt.Sprintf(sqlMigrations[version], s.table)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(fmt.Sprintf("INSERT INTO %s_migrations (version) VALUES ($1)", s.table), version+1)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed migration %d. %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+// Implements the Storage interface.
+func (s *SQLStorage) Set(key []byte, value []byte) error {
+	_, err := s.db.Exec(s.upsert(), key, value)
+	return err
+}
+
+// Implements the Storage interface.
+// On this implementation if no value was found, an error will be returned.
+func (s *SQLStorage) Get(key []byte) ([]byte, error) {
+	var value []byte
+	query := fmt.Sprintf("SELECT value FROM %s_values WHERE key = $1", s.table)
+	err := s.db.QueryRow(query, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("not found value for %s", string(key))
+	}
+	return value, err
+}
+
+// Implements the StateMachine interface.
+// A command is appended to the log and the value is set in a single
+// transaction. Since all peers of a partition can share the same
+// database, an entry already on the log is not applied again.
+func (s *SQLStorage) Commit(entry *types.Entry) (interface{}, error) {
+	switch entry.Operation {
+	case types.Command:
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.transaction(func(tx *sql.Tx) error {
+			if err := s.asynchronous(tx); err != nil {
+				return err
+			}
+			return s.insert(tx, entry, data)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return entry, nil
+	case types.Query:
+		data, err := s.Get(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		var committed types.Entry
+		if err := json.Unmarshal(data, &committed); err != nil {
+			return nil, err
+		}
+		return &committed, nil
+	default:
+		return nil, types.ErrCommandUnknown
+	}
+}
+
+// Implements the BatchStateMachine interface.
+// All the commands are appended to the log and set in a single
+// transaction, so the batch costs a single database flush. Only
+// commands can be committed in batch.
+func (s *SQLStorage) CommitBatch(entries []*types.Entry) ([]interface{}, error) {
+	values := make([][]byte, len(entries))
+	for i, entry := range entries {
+		if entry.Operation != types.Command {
+			return nil, types.ErrCommandUnknown
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = data
+	}
+
+	err := s.transaction(func(tx *sql.Tx) error {
+		if err := s.asynchronous(tx); err != nil {
+			return err
+		}
+
+		for i, entry := range entries {
+			if err := s.insert(tx, entry, values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		responses[i] = entry
+	}
+	return responses, nil
+}
+
+// Implements the DurableStorage interface.
+// The database flushes the commits, so when not syncing on commit the
+// synchronous commit is disabled and the database flushes in background,
+// following its own configured interval.
+func (s *SQLStorage) SetDurability(durability types.Durability) {
+	atomic.StoreInt32(&s.policy, int32(durability.Policy))
+}
+
+// Implements the StateMachine interface.
+// The values are already durable, so nothing is done.
+func (s *SQLStorage) Restore() error {
+	return nil
+}
+
+// Implements the HistoryReader interface.
+// The filter is applied by the database, so only the matching
+// entries are read.
+func (s *SQLStorage) History(filter types.HistoryFilter) ([]types.Entry, error) {
+	query := fmt.Sprintf(`SELECT identifier, key, timestamp, data, extensions FROM %s_log
+		WHERE ($1::bytea IS NULL OR key = $1) AND timestamp >= $2 AND ($3 = 0 OR timestamp <= $3)
+		ORDER BY sequence DESC LIMIT $4`, s.table)
+	var key, limit interface{}
+	if len(filter.Key) > 0 {
+		key = filter.Key
+	}
+	if filter.Last > 0 {
+		limit = int64(filter.Last)
+	}
+
+	rows, err := s.db.Query(query, key, int64(filter.From), int64(filter.To), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []types.Entry
+	for rows.Next() {
+		var identifier string
+		var timestamp int64
+		entry := types.Entry{Operation: types.Command}
+		if err := rows.Scan(&identifier, &entry.Key, &timestamp, &entry.Data, &entry.Extensions); err != nil {
+			return nil, err
+		}
+		entry.Identifier = types.UID(identifier)
+		entry.FinalTimestamp = uint64(timestamp)
+		entries = append(entries, entry)
+	}
+
+	// Read from the newest, so the limit keeps the last entries.
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, rows.Err()
+}
+
+// Append the entry to the log and set the value, unless the entry
+// is already on the log.
+func (s *SQLStorage) insert(tx *sql.Tx, entry *types.Entry, data []byte) error {
+	insert := fmt.Sprintf(`INSERT INTO %s_log (identifier, key, timestamp, data, extensions)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT (identifier) DO NOTHING`, s.table)
+	res, err := tx.Exec(insert, string(entry.Identifier), entry.Key, int64(entry.FinalTimestamp), entry.Data, entry.Extensions)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return err
+	}
+	_, err = tx.Exec(s.upsert(), entry.Key, data)
+	return err
+}
+
+// Disable the synchronous commit of the transaction when
+// not syncing on every commit.
+func (s *SQLStorage) asynchronous(tx *sql.Tx) error {
+	if types.SyncPolicy(atomic.LoadInt32(&s.policy)) == types.SyncOnCommit {
+		return nil
+	}
+	_, err := tx.Exec("SET LOCAL synchronous_commit TO off")
+	return err
+}
+
+func (s *SQLStorage) upsert() string {
+	return fmt.Sprintf(`INSERT INTO %s_values (key, value) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value`, s.table)
+}
+
+// Execute the function inside a transaction, committed only
+// if the function does not return an error.
+func (s *SQLStorage) transaction(f func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
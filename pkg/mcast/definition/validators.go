@@ -0,0 +1,102 @@
+package definition
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+)
+
+// Rejects requests with an empty key or a key larger than the maximum,
+// also verifying the other keys touched by the request.
+type KeySizeValidator struct {
+	// Maximum key size in bytes. Zero means no limit.
+	Max int
+}
+
+// Implements the Validator interface.
+func (k KeySizeValidator) Validate(request types.Request) error {
+	if len(request.Key) == 0 {
+		return &types.ValidationError{Field: "Key", Reason: "is empty"}
+	}
+
+	if k.Max > 0 && len(request.Key) > k.Max {
+		return &types.ValidationError{Field: "Key", Reason: fmt.Sprintf("has %d bytes, maximum is %d", len(request.Key), k.Max)}
+	}
+
+	for _, key := range request.Keys {
+		if len(key) == 0 {
+			return &types.ValidationError{Field: "Keys", Reason: "has an empty key"}
+		}
+
+		if k.Max > 0 && len(key) > k.Max {
+			return &types.ValidationError{Field: "Keys", Reason: fmt.Sprintf("has a key with %d bytes, maximum is %d", len(key), k.Max)}
+		}
+	}
+	return nil
+}
+
+// Rejects requests with a value or extra larger than the maximum.
+type ValueSizeValidator struct {
+	// Maximum size in bytes of the value and extra together.
+	Max int
+}
+
+// Implements the Validator interface.
+func (v ValueSizeValidator) Validate(request types.Request) error {
+	if size := len(request.Value) + len(request.Extra); size > v.Max {
+		return &types.ValidationError{Field: "Value", Reason: fmt.Sprintf("has %d bytes, maximum is %d", size, v.Max)}
+	}
+	return nil
+}
+
+// Rejects requests which the value is not a valid JSON document.
+type JSONValueValidator struct{}
+
+// Implements the Validator interface.
+func (JSONValueValidator) Validate(request types.Request) error {
+	if !json.Valid(request.Value) {
+		return &types.ValidationError{Field: "Value", Reason: "is not valid JSON"}
+	}
+	return nil
+}
+
+// Rejects requests without destination, with repeated or invalid
+// destinations or, if the known partitions are given, with an
+// unknown destination.
+type DestinationValidator struct {
+	// The partitions that can receive requests. Empty means any.
+	Known []types.Partition
+}
+
+// Implements the Validator interface.
+func (d DestinationValidator) Validate(request types.Request) error {
+	if len(request.Destination) == 0 {
+		return &types.ValidationError{Field: "Destination", Reason: "is empty"}
+	}
+
+	seen := make(map[types.Partition]bool)
+	for _, partition := range request.Destination {
+		if seen[partition] {
+			return &types.ValidationError{Field: "Destination", Reason: fmt.Sprintf("repeats %s", partition)}
+		}
+		seen[partition] = true
+
+		if err := partition.Validate(); err != nil {
+			return &types.ValidationError{Field: "Destination", Reason: err.Error()}
+		}
+
+		if len(d.Known) > 0 && !d.known(partition) {
+			return &types.ValidationError{Field: "Destination", Reason: fmt.Sprintf("has unknown partition %s", partition)}
+		}
+	}
+	return nil
+}
+
+func (d DestinationValidator) known(partition types.Partition) bool {
+	for _, p := range d.Known {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
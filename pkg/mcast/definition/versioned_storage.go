@@ -0,0 +1,118 @@
+package definition
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sort"
+	"sync"
+)
+
+// A version of a key, positioned on the commit order.
+type version struct {
+	// Position of the entry on the commit order.
+	sequence uint64
+
+	// The entry committed.
+	entry types.Entry
+}
+
+// Implements the Storage, StateMachine and HistoryReader interfaces,
+// keeping in memory the last versions of each key committed, so a key
+// is read as it was at a final timestamp.
+//
+// The values are applied on the underlying storage, through its own
+// state machine when it implements one. Only the versions
+// are kept in memory and they are not restored after a restart. Since
+// every peer of the partition commits the same entries, an entry
+// already kept is ignored.
+type VersionedStorage struct {
+	types.Storage
+
+	// Applies the entries on the underlying storage.
+	sm types.StateMachine
+
+	// Synchronize access to the versions.
+	mutex *sync.Mutex
+
+	// How many versions of each key are kept, zero keeps all.
+	retention int
+
+	// Position of the last entry committed.
+	sequence uint64
+
+	// The versions of each key, oldest first.
+	versions map[string][]version
+}
+
+// Create a storage keeping up to the given number of versions of each
+// key and applying the entries on the given storage. Zero keeps every
+// version.
+func NewVersionedStorage(storage types.Storage, retention int) *VersionedStorage {
+	sm, ok := storage.(types.StateMachine)
+	if !ok {
+		sm = types.NewStateMachine(storage)
+	}
+	return &VersionedStorage{
+		Storage:   storage,
+		sm:        sm,
+		mutex:     &sync.Mutex{},
+		retention: retention,
+		versions:  make(map[string][]version),
+	}
+}
+
+// Implements the StateMachine interface.
+func (v *VersionedStorage) Commit(entry *types.Entry) (interface{}, error) {
+	if entry.Operation != types.Command {
+		return v.sm.Commit(entry)
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	key := string(entry.Key)
+	for _, kept := range v.versions[key] {
+		if kept.entry.Identifier == entry.Identifier {
+			return entry, nil
+		}
+	}
+
+	res, err := v.sm.Commit(entry)
+	if err != nil {
+		return nil, err
+	}
+	v.sequence++
+	versions := append(v.versions[key], version{sequence: v.sequence, entry: *entry})
+	if v.retention > 0 && len(versions) > v.retention {
+		versions = append([]version{}, versions[len(versions)-v.retention:]...)
+	}
+	v.versions[key] = versions
+	return res, nil
+}
+
+// Implements the StateMachine interface.
+func (v *VersionedStorage) Restore() error {
+	return v.sm.Restore()
+}
+
+// Implements the HistoryReader interface.
+// Only the versions kept are returned.
+func (v *VersionedStorage) History(filter types.HistoryFilter) ([]types.Entry, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	var versions []version
+	if len(filter.Key) > 0 {
+		versions = v.versions[string(filter.Key)]
+	} else {
+		for _, kept := range v.versions {
+			versions = append(versions, kept...)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].sequence < versions[j].sequence
+		})
+	}
+
+	entries := make([]types.Entry, 0, len(versions))
+	for _, kept := range versions {
+		entries = append(entries, kept.entry)
+	}
+	return filter.Apply(entries), nil
+}
@@ -0,0 +1,116 @@
+package definition
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// Suffix of the file holding the index snapshot, next to the log.
+const walIndexSuffix = ".index"
+
+// Where the records are on the log, so the entries of a message
+// or a key are read without scanning the whole log.
+type walIndex struct {
+	// The offset of the entry of each message not aborted.
+	UIDs map[types.UID]int64 `json:"uids"`
+
+	// The offsets of the entries not aborted of each key, hex
+	// encoded, in the order they were appended. The last offset
+	// is the value currently applied for the key.
+	Keys map[string][]int64 `json:"keys"`
+
+	// The size of the log covered by the index.
+	Size int64 `json:"size"`
+
+	// The offset of the last record covered by the index, used
+	// to verify the snapshot matches the log.
+	Last int64 `json:"last"`
+}
+
+func newWALIndex() *walIndex {
+	return &walIndex{
+		UIDs: make(map[types.UID]int64),
+		Keys: make(map[string][]int64),
+	}
+}
+
+// Add the record appended at the offset, with the given size.
+func (i *walIndex) add(record walRecord, offset, size int64) {
+	i.Size = offset + size
+	i.Last = offset
+	id := record.Entry.Identifier
+	if record.Kind == walAbort {
+		at, ok := i.UIDs[id]
+		if !ok {
+			return
+		}
+		delete(i.UIDs, id)
+		for key, offsets := range i.Keys {
+			for j, o := range offsets {
+				if o == at {
+					i.Keys[key] = append(offsets[:j:j], offsets[j+1:]...)
+					if len(i.Keys[key]) == 0 {
+						delete(i.Keys, key)
+					}
+					return
+				}
+			}
+		}
+		return
+	}
+
+	i.UIDs[id] = offset
+	key := hex.EncodeToString(record.Entry.Key)
+	i.Keys[key] = append(i.Keys[key], offset)
+}
+
+// The offsets of the entries of the key, in the append order.
+func (i *walIndex) key(key []byte) []int64 {
+	return i.Keys[hex.EncodeToString(key)]
+}
+
+// The offset of the last entry of each key, in the append order.
+func (i *walIndex) latest() []int64 {
+	offsets := make([]int64, 0, len(i.Keys))
+	for _, entries := range i.Keys {
+		offsets = append(offsets, entries[len(entries)-1])
+	}
+	sort.Slice(offsets, func(a, b int) bool {
+		return offsets[a] < offsets[b]
+	})
+	return offsets
+}
+
+// Read the index snapshot of the log, nil if there is none or it
+// does not fit the log of the given size.
+func loadWALIndex(path string, size int64) *walIndex {
+	data, err := ioutil.ReadFile(path + walIndexSuffix)
+	if err != nil {
+		return nil
+	}
+
+	index := newWALIndex()
+	if err := json.Unmarshal(data, index); err != nil || index.Size > size || index.UIDs == nil || index.Keys == nil {
+		return nil
+	}
+	return index
+}
+
+// Write the index snapshot next to the log, replacing the
+// previous one atomically.
+func (i *walIndex) save(path string) error {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + walIndexSuffix + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path+walIndexSuffix)
+}
@@ -0,0 +1,471 @@
+package definition
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Kinds of records written on the log.
+const (
+	// The entry is about to be applied on the storage.
+	walEntry byte = iota
+
+	// Applying the entry failed, it must not be applied again.
+	walAbort
+)
+
+// Size of the record header: the payload length and checksum.
+const walHeaderSize = 8
+
+var (
+	// Returned when a record in the middle of the log is corrupted.
+	ErrCorruptedLog = errors.New("corrupted write ahead log")
+)
+
+// A single record of the write ahead log.
+type walRecord struct {
+	Kind  byte
+	Entry types.Entry
+}
+
+// Implements both the Storage and the StateMachine interfaces,
+// writing every entry to a write ahead log before applying it
+// on the underlying storage.
+//
+// The commit is atomic even if the process crashes in the middle
+// of it: an entry on the log not applied on the storage is applied
+// again when restoring, and an entry that failed is marked as aborted
+// so it is never applied. Values set directly, without a commit,
+// are not written to the log.
+//
+// The log is indexed by the message and by the key, so the history
+// of a key is read without scanning the log. The index is written
+// next to the log on Snapshot and Close, when restoring only the
+// records after the snapshot are read to complete the index, and
+// only the last entry of each key is applied again.
+type WALStorage struct {
+	types.Storage
+
+	// Synchronize access to the log.
+	mutex *sync.Mutex
+
+	// The log file, opened for appending.
+	file *os.File
+
+	// Where the log file is.
+	path string
+
+	// Where the records are on the log, nil until the
+	// log is read for the first time.
+	index *walIndex
+
+	// If the log was already replayed.
+	restored bool
+
+	// When the log is synced.
+	durability types.Durability
+
+	// When the log was synced the last time.
+	synced time.Time
+
+	// If there are records appended after the last sync.
+	dirty bool
+
+	// Syncs the batched records on the background.
+	flusher *flusher
+}
+
+// Create a new storage writing the log on the given path and
+// applying the entries on the given storage.
+func NewWALStorage(path string, storage types.Storage) (*WALStorage, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &WALStorage{
+		Storage: storage,
+		mutex:   &sync.Mutex{},
+		file:    file,
+		path:    path,
+		flusher: newFlusher(),
+	}, nil
+}
+
+// Implements the StateMachine interface.
+// The entry is written and synced to the log before it is applied.
+// Since every peer of the partition commits the same entries, an
+// entry already on the index is ignored. The index is the only
+// record of the applied entries, so they are not kept apart.
+func (w *WALStorage) Commit(entry *types.Entry) (interface{}, error) {
+	if entry.Operation != types.Command {
+		return types.NewStateMachine(w.Storage).Commit(entry)
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err := w.prepare(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := w.index.UIDs[entry.Identifier]; ok {
+		return entry, nil
+	}
+
+	if err := w.append(walRecord{Kind: walEntry, Entry: *entry}); err != nil {
+		return nil, err
+	}
+
+	if err := w.apply(*entry); err != nil {
+		// If the abort is not written the entry is applied
+		// again when restoring, turning the commit complete.
+		if abortErr := w.append(walRecord{Kind: walAbort, Entry: types.Entry{Identifier: entry.Identifier}}); abortErr != nil {
+			return nil, abortErr
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Implements the StateMachine interface.
+// The last entry of each key not aborted is applied again, in the
+// order they were appended. A record partially written at the end
+// of the log, when the process crashed while appending it, is
+// discarded.
+func (w *WALStorage) Restore() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.restored {
+		return nil
+	}
+
+	if err := w.prepare(); err != nil {
+		return err
+	}
+
+	for _, offset := range w.index.latest() {
+		record, err := w.readAt(offset)
+		if err != nil {
+			return err
+		}
+
+		if err := w.apply(record.Entry); err != nil {
+			return err
+		}
+	}
+
+	w.restored = true
+	return nil
+}
+
+// Implements the HistoryReader interface.
+// The entries are read from the log, ignoring the aborted ones.
+// When filtering by key, only the entries of the key are read.
+func (w *WALStorage) History(filter types.HistoryFilter) ([]types.Entry, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err := w.prepare(); err != nil {
+		return nil, err
+	}
+
+	var entries []types.Entry
+	if len(filter.Key) > 0 {
+		for _, offset := range w.index.key(filter.Key) {
+			record, err := w.readAt(offset)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, record.Entry)
+		}
+		return filter.Apply(entries), nil
+	}
+
+	records, offsets, _, err := w.read(0)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, record := range records {
+		if at, ok := w.index.UIDs[record.Entry.Identifier]; ok && record.Kind == walEntry && at == offsets[i] {
+			entries = append(entries, record.Entry)
+		}
+	}
+	return filter.Apply(entries), nil
+}
+
+// The entry committed for the message, false if the message was
+// not committed or was aborted.
+func (w *WALStorage) Lookup(uid types.UID) (types.Entry, bool, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err := w.prepare(); err != nil {
+		return types.Entry{}, false, err
+	}
+
+	offset, ok := w.index.UIDs[uid]
+	if !ok {
+		return types.Entry{}, false, nil
+	}
+
+	record, err := w.readAt(offset)
+	if err != nil {
+		return types.Entry{}, false, err
+	}
+	return record.Entry, true, nil
+}
+
+// Sync the log and write the index next to it, so restoring
+// only reads the records appended after the snapshot.
+func (w *WALStorage) Snapshot() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err := w.prepare(); err != nil {
+		return err
+	}
+	return w.snapshot()
+}
+
+// Implements the DurableStorage interface.
+// When not syncing on every commit, an entry acknowledged but not
+// synced is lost if the machine crashes. When batching, the entries
+// are synced at most one interval after they are appended.
+func (w *WALStorage) SetDurability(durability types.Durability) {
+	w.flusher.halt()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.durability = durability
+	if durability.Policy == types.SyncBatched && durability.Interval > 0 {
+		w.flusher.start(durability.Interval, w.flush)
+	}
+}
+
+// Sync the entries appended since the last sync, if any.
+func (w *WALStorage) flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if !w.dirty {
+		return
+	}
+	if err := w.file.Sync(); err != nil {
+		return
+	}
+	w.synced = time.Now()
+	w.dirty = false
+}
+
+// Sync and close the log file, writing the index snapshot if
+// the log was read.
+func (w *WALStorage) Close() error {
+	w.flusher.halt()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.index != nil {
+		if err := w.snapshot(); err != nil {
+			w.file.Close()
+			return err
+		}
+	}
+
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Sync the log and write the index.
+// This method should be called while holding the mutex.
+func (w *WALStorage) snapshot() error {
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.synced = time.Now()
+	w.dirty = false
+	return w.index.save(w.path)
+}
+
+// Build the index the first time, from the snapshot and the records
+// appended after it, or from the whole log if the snapshot is missing
+// or does not match the log. The partial record at the end of the
+// log is discarded.
+// This method should be called while holding the mutex.
+func (w *WALStorage) prepare() error {
+	if w.index != nil {
+		return nil
+	}
+
+	info, err := w.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	index := loadWALIndex(w.path, info.Size())
+	if index == nil || !w.covers(index) {
+		index = newWALIndex()
+	}
+
+	records, offsets, valid, err := w.read(index.Size)
+	if err != nil && index.Size > 0 {
+		index = newWALIndex()
+		records, offsets, valid, err = w.read(0)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := w.file.Truncate(valid); err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		end := valid
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		index.add(record, offsets[i], end-offsets[i])
+	}
+	index.Size = valid
+	w.index = index
+	return nil
+}
+
+// Apply the entry on the storage, the same way the default
+// state machine does.
+func (w *WALStorage) apply(entry types.Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return w.Storage.Set(entry.Key, data)
+}
+
+// Write the record at the end of the log, adding it to the index,
+// and sync the file following the durability policy.
+// This method should be called while holding the mutex.
+func (w *WALStorage) append(record walRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, walHeaderSize, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(data[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(data[4:8], crc32.ChecksumIEEE(payload))
+	if _, err := w.file.Write(append(data, payload...)); err != nil {
+		return err
+	}
+	w.index.add(record, w.index.Size, int64(len(data)+len(payload)))
+	return w.sync()
+}
+
+// Sync the log file following the durability policy.
+// This method should be called while holding the mutex.
+func (w *WALStorage) sync() error {
+	switch w.durability.Policy {
+	case types.NoSync:
+		return nil
+	case types.SyncBatched:
+		if time.Since(w.synced) < w.durability.Interval {
+			w.dirty = true
+			return nil
+		}
+	}
+
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.synced = time.Now()
+	w.dirty = false
+	return nil
+}
+
+// Verify the index ends on the last record it covers, otherwise
+// the snapshot is from another log.
+// This method should be called while holding the mutex.
+func (w *WALStorage) covers(index *walIndex) bool {
+	if index.Size == 0 {
+		return true
+	}
+
+	header := make([]byte, walHeaderSize)
+	if _, err := w.file.ReadAt(header, index.Last); err != nil {
+		return false
+	}
+
+	end := index.Last + walHeaderSize + int64(binary.BigEndian.Uint32(header[0:4]))
+	if end != index.Size {
+		return false
+	}
+	_, err := w.readAt(index.Last)
+	return err == nil
+}
+
+// Read the record written at the offset.
+// This method should be called while holding the mutex.
+func (w *WALStorage) readAt(offset int64) (walRecord, error) {
+	var record walRecord
+	header := make([]byte, walHeaderSize)
+	if _, err := w.file.ReadAt(header, offset); err != nil {
+		return record, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+	if _, err := w.file.ReadAt(payload, offset+walHeaderSize); err != nil {
+		return record, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) || json.Unmarshal(payload, &record) != nil {
+		return record, ErrCorruptedLog
+	}
+	return record, nil
+}
+
+// Read all the valid records starting at the offset, with the offset
+// of each record and the size of the valid part of the log. Only the
+// last record can be invalid, otherwise the log is corrupted.
+// This method should be called while holding the mutex.
+func (w *WALStorage) read(from int64) ([]walRecord, []int64, int64, error) {
+	if _, err := w.file.Seek(from, io.SeekStart); err != nil {
+		return nil, nil, 0, err
+	}
+
+	var records []walRecord
+	var offsets []int64
+	valid := from
+	reader := bufio.NewReader(w.file)
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return records, offsets, valid, nil
+			}
+			return nil, nil, 0, err
+		}
+
+		payload := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return records, offsets, valid, nil
+			}
+			return nil, nil, 0, err
+		}
+
+		var record walRecord
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) || json.Unmarshal(payload, &record) != nil {
+			if _, err := reader.Peek(1); err == io.EOF {
+				return records, offsets, valid, nil
+			}
+			return nil, nil, 0, ErrCorruptedLog
+		}
+		records = append(records, record)
+		offsets = append(offsets, valid)
+		valid += int64(walHeaderSize + len(payload))
+	}
+}
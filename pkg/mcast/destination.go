@@ -0,0 +1,110 @@
+package mcast
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// Builds the destination of the requests from the topology, so the
+// clients do not duplicate the routing logic. Only the partitions on
+// the topology are returned, without duplicates, so a request never
+// waits for the timestamp of a partition that does not exist.
+type Destinations struct {
+	// The partitions and where each one is deployed.
+	Topology types.StaticTopology
+
+	// Where the requests are issued, to prefer the closer partitions.
+	Location types.Location
+
+	// Relative weight of each partition when choosing the owners of
+	// a key. A partition not listed weights 1, and a partition with
+	// a weight of zero or less never owns a key.
+	Weights map[types.Partition]float64
+}
+
+// Every partition on the topology, sorted by name.
+func (d Destinations) All() []types.Partition {
+	partitions := make([]types.Partition, 0, len(d.Topology))
+	for partition := range d.Topology {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i] < partitions[j]
+	})
+	return partitions
+}
+
+// The n partitions owning the key, chosen with a weighted rendezvous
+// hash. The same key has the same owners while the topology and the
+// weights do not change, and adding a partition only moves the keys
+// the new partition owns. Returns fewer partitions when the topology
+// has less than n partitions with weight.
+func (d Destinations) Owners(key []byte, n int) []types.Partition {
+	type scored struct {
+		partition types.Partition
+		score     float64
+	}
+	var candidates []scored
+	for _, partition := range d.All() {
+		weight, ok := d.Weights[partition]
+		if !ok {
+			weight = 1
+		}
+		if weight <= 0 {
+			continue
+		}
+		candidates = append(candidates, scored{partition: partition, score: rendezvous(partition, key, weight)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	var owners []types.Partition
+	for _, c := range candidates[:clamp(n, len(candidates))] {
+		owners = append(owners, c.partition)
+	}
+	return owners
+}
+
+// The n partitions closest to the location, the ones on the same zone
+// first, then on the same datacenter and then the others, each group
+// sorted by name.
+func (d Destinations) Nearest(n int) []types.Partition {
+	near, far := core.SplitByProximity(d.Location, d.Topology, d.All())
+	closest := append(near, far...)
+	return closest[:clamp(n, len(closest))]
+}
+
+// The score of the partition for the key, the greatest score owns
+// the key. The hash is mapped to (0, 1) and the score is weighted
+// so each partition owns a share of the keys proportional to its
+// weight.
+func rendezvous(partition types.Partition, key []byte, weight float64) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(partition))
+	h.Write([]byte{0})
+	h.Write(key)
+	// Mix the bits, so similar keys spread evenly.
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	unit := (float64(x>>11) + 0.5) / (1 << 53)
+	return -weight / math.Log(unit)
+}
+
+// The n between zero and the limit.
+func clamp(n, limit int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > limit {
+		return limit
+	}
+	return n
+}
@@ -0,0 +1,155 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"net/http"
+	"net/url"
+)
+
+// A registry using the Consul agent HTTP API. Each member is
+// registered as an instance of a single service, with a TTL
+// check that must be kept alive by calling Heartbeat.
+type ConsulRegistry struct {
+	// The Consul agent address, e.g., http://127.0.0.1:8500.
+	Agent string
+
+	// The service name where all members are registered.
+	Service string
+
+	// The TTL for the health check, e.g., 15s.
+	TTL string
+
+	// The HTTP client used to query the agent.
+	Client *http.Client
+}
+
+// The service registration on the Consul agent.
+type consulRegistration struct {
+	ID    string
+	Name  string
+	Tags  []string
+	Meta  map[string]string
+	Check consulCheck
+}
+
+// The TTL check of the registration.
+type consulCheck struct {
+	CheckID                        string
+	TTL                            string
+	DeregisterCriticalServiceAfter string
+}
+
+// A service instance returned by the health endpoint.
+type consulEntry struct {
+	Service struct {
+		ID   string
+		Meta map[string]string
+	}
+}
+
+func (c ConsulRegistry) client() *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
+
+func (c ConsulRegistry) do(method, path string, body interface{}) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.Agent+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("consul returned %s for %s", res.Status, path)
+	}
+	return res, nil
+}
+
+// Implements the Registry interface.
+func (c ConsulRegistry) Register(member Member) error {
+	registration := consulRegistration{
+		ID:   member.Name,
+		Name: c.Service,
+		Tags: []string{string(member.Partition)},
+		Meta: map[string]string{
+			"partition": string(member.Partition),
+			"address":   string(member.Address),
+		},
+		Check: consulCheck{
+			CheckID:                        c.checkID(member),
+			TTL:                            c.TTL,
+			DeregisterCriticalServiceAfter: "1m",
+		},
+	}
+	res, err := c.do(http.MethodPut, "/v1/agent/service/register", registration)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return c.Heartbeat(member)
+}
+
+// Keep the member registration alive, must be called
+// within the configured TTL.
+func (c ConsulRegistry) Heartbeat(member Member) error {
+	res, err := c.do(http.MethodPut, "/v1/agent/check/pass/"+url.PathEscape(c.checkID(member)), nil)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Implements the Registry interface.
+func (c ConsulRegistry) Deregister(member Member) error {
+	res, err := c.do(http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(member.Name), nil)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Implements the Registry interface.
+func (c ConsulRegistry) Members() ([]Member, error) {
+	res, err := c.do(http.MethodGet, "/v1/health/service/"+url.PathEscape(c.Service)+"?passing=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var entries []consulEntry
+	if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	var members []Member
+	for _, entry := range entries {
+		members = append(members, Member{
+			Name:      entry.Service.ID,
+			Partition: types.Partition(entry.Service.Meta["partition"]),
+			Address:   types.Address(entry.Service.Meta["address"]),
+		})
+	}
+	return members, nil
+}
+
+func (c ConsulRegistry) checkID(member Member) string {
+	return "mcast:" + member.Name
+}
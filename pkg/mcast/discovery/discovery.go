@@ -0,0 +1,239 @@
+// Package discovery provides an optional membership discovery,
+// where peers register themselves on a service registry, e.g.,
+// Consul or etcd, and watch for membership changes.
+//
+// The Discovery implements the Resolver interface, so it can be
+// used directly on the configuration, and notifies subscribers
+// about the membership changes.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned when no member is known for the partition.
+	ErrNoMember = errors.New("no member available")
+)
+
+// A single peer registered on the registry.
+type Member struct {
+	// The peer name, unique across the whole cluster.
+	Name string
+
+	// The partition the peer belongs to.
+	Partition types.Partition
+
+	// The transport address of the peer partition.
+	Address types.Address
+}
+
+// A snapshot of the known members of each partition.
+type Membership map[types.Partition][]Member
+
+// Verify if both memberships contain the same members.
+func (m Membership) Equal(o Membership) bool {
+	if len(m) != len(o) {
+		return false
+	}
+
+	for partition, members := range m {
+		others, ok := o[partition]
+		if !ok || len(members) != len(others) {
+			return false
+		}
+
+		for i := range members {
+			if members[i] != others[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Moves a partition to the next epoch, the Unity of
+// the partition can be used.
+type EpochAdvancer interface {
+	// Move the partition to the next epoch.
+	AdvanceEpoch() error
+}
+
+// The service registry where peers are registered.
+type Registry interface {
+	// Register the member on the registry. The registration
+	// must be kept alive while the member is registered.
+	Register(member Member) error
+
+	// Remove the member from the registry.
+	Deregister(member Member) error
+
+	// List all the alive members registered.
+	Members() ([]Member, error)
+}
+
+// Keep watching the registry for membership changes.
+type Discovery struct {
+	// Synchronize access to the membership.
+	mutex *sync.Mutex
+
+	// The registry to watch.
+	registry Registry
+
+	// Current known membership.
+	membership Membership
+
+	// Functions notified when the membership changes.
+	subscribers []func(Membership)
+
+	// How often the registry is verified.
+	interval time.Duration
+
+	// Discovery logger.
+	log types.Logger
+
+	// The discovery context.
+	context context.Context
+
+	// Finish the discovery.
+	finish context.CancelFunc
+}
+
+// Creates a new discovery watching the registry on the given interval.
+// The membership is loaded before returning.
+func NewDiscovery(registry Registry, interval time.Duration, log types.Logger) (*Discovery, error) {
+	ctx, done := context.WithCancel(context.Background())
+	d := &Discovery{
+		mutex:      &sync.Mutex{},
+		registry:   registry,
+		membership: make(Membership),
+		interval:   interval,
+		log:        log,
+		context:    ctx,
+		finish:     done,
+	}
+	if err := d.Refresh(); err != nil {
+		done()
+		return nil, err
+	}
+	core.InvokerInstance().Spawn(d.poll)
+	return d, nil
+}
+
+// Implements the Resolver interface.
+// Returns the address of the first member of the partition.
+func (d *Discovery) Resolve(partition types.Partition) (types.Address, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	members := d.membership[partition]
+	if len(members) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrNoMember, partition)
+	}
+	return members[0].Address, nil
+}
+
+// Implements the types.Members interface.
+// A partition is known while it has registered members.
+func (d *Discovery) Known(partition types.Partition) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.membership[partition]) > 0
+}
+
+// Return a copy of the current membership.
+func (d *Discovery) Membership() Membership {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	membership := make(Membership)
+	for partition, members := range d.membership {
+		membership[partition] = append([]Member{}, members...)
+	}
+	return membership
+}
+
+// Register a function to be called when the membership changes.
+func (d *Discovery) Subscribe(f func(Membership)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.subscribers = append(d.subscribers, f)
+}
+
+// Advance the epoch of the partition whenever its members change,
+// so a member that left is fenced if it comes back.
+func (d *Discovery) AdvanceOnChange(partition types.Partition, advancer EpochAdvancer) {
+	mutex := &sync.Mutex{}
+	previous := Membership{partition: d.Membership()[partition]}
+	d.Subscribe(func(membership Membership) {
+		current := Membership{partition: membership[partition]}
+		mutex.Lock()
+		changed := !previous.Equal(current)
+		previous = current
+		mutex.Unlock()
+		if !changed {
+			return
+		}
+
+		if err := advancer.AdvanceEpoch(); err != nil {
+			d.log.Errorf("failed advancing %s epoch. %v", partition, err)
+		}
+	})
+}
+
+// Read the registry and notify the subscribers if
+// the membership changed.
+func (d *Discovery) Refresh() error {
+	members, err := d.registry.Members()
+	if err != nil {
+		return err
+	}
+
+	membership := make(Membership)
+	for _, member := range members {
+		membership[member.Partition] = append(membership[member.Partition], member)
+	}
+	for _, m := range membership {
+		sort.Slice(m, func(i, j int) bool {
+			return m[i].Name < m[j].Name
+		})
+	}
+
+	d.mutex.Lock()
+	if d.membership.Equal(membership) {
+		d.mutex.Unlock()
+		return nil
+	}
+	d.membership = membership
+	subscribers := append([]func(Membership){}, d.subscribers...)
+	d.mutex.Unlock()
+
+	for _, subscriber := range subscribers {
+		subscriber(d.Membership())
+	}
+	return nil
+}
+
+// Stop watching the registry.
+func (d *Discovery) Close() {
+	d.finish()
+}
+
+// Keep refreshing the membership while the context is open.
+func (d *Discovery) poll() {
+	for {
+		select {
+		case <-d.context.Done():
+			return
+		case <-time.After(d.interval):
+			if err := d.Refresh(); err != nil {
+				d.log.Errorf("failed refreshing membership. %v", err)
+			}
+		}
+	}
+}
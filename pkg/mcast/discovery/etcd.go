@@ -0,0 +1,134 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// A registry using the etcd v3 JSON gateway. Each member is
+// stored as a key under the prefix, attached to a lease that
+// must be kept alive by calling Heartbeat.
+type EtcdRegistry struct {
+	// The etcd gateway address, e.g., http://127.0.0.1:2379.
+	Endpoint string
+
+	// Prefix for the keys, e.g., /mcast/members/.
+	Prefix string
+
+	// The lease TTL in seconds.
+	TTL int64
+
+	// The HTTP client used to query the gateway.
+	Client *http.Client
+
+	// The lease of the registered member.
+	lease string
+}
+
+// A key value returned by the range endpoint.
+type etcdKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (e *EtcdRegistry) post(path string, body interface{}, response interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Post(e.Endpoint+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("etcd returned %s for %s", res.Status, path)
+	}
+
+	if response == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(response)
+}
+
+func encode(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+// Implements the Registry interface.
+// A lease is granted and the member is stored attached to it.
+func (e *EtcdRegistry) Register(member Member) error {
+	var grant struct {
+		ID string `json:"ID"`
+	}
+	if err := e.post("/v3/lease/grant", map[string]string{"TTL": strconv.FormatInt(e.TTL, 10)}, &grant); err != nil {
+		return err
+	}
+	e.lease = grant.ID
+
+	value, err := json.Marshal(member)
+	if err != nil {
+		return err
+	}
+
+	put := map[string]string{
+		"key":   encode(e.Prefix + member.Name),
+		"value": base64.StdEncoding.EncodeToString(value),
+		"lease": e.lease,
+	}
+	return e.post("/v3/kv/put", put, nil)
+}
+
+// Keep the member lease alive, must be called within the TTL.
+func (e *EtcdRegistry) Heartbeat() error {
+	return e.post("/v3/lease/keepalive", map[string]string{"ID": e.lease}, nil)
+}
+
+// Implements the Registry interface.
+func (e *EtcdRegistry) Deregister(member Member) error {
+	return e.post("/v3/kv/deleterange", map[string]string{"key": encode(e.Prefix + member.Name)}, nil)
+}
+
+// Implements the Registry interface.
+// All keys under the prefix are read.
+func (e *EtcdRegistry) Members() ([]Member, error) {
+	var res struct {
+		Kvs []etcdKeyValue `json:"kvs"`
+	}
+	// The range end for a prefix is the prefix with the last byte incremented.
+	end := []byte(e.Prefix)
+	end[len(end)-1]++
+	query := map[string]string{
+		"key":       encode(e.Prefix),
+		"range_end": base64.StdEncoding.EncodeToString(end),
+	}
+	if err := e.post("/v3/kv/range", query, &res); err != nil {
+		return nil, err
+	}
+
+	var members []Member
+	for _, kv := range res.Kvs {
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		var member Member
+		if err := json.Unmarshal(value, &member); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
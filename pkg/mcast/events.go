@@ -0,0 +1,25 @@
+package mcast
+
+import (
+	"encoding/json"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"net/http"
+)
+
+// Creates an HTTP handler dumping the events recorded as JSON.
+// The events of a single message are returned when the uid query
+// parameter is set, otherwise the events of all messages are
+// returned grouped by the message UID.
+func EventsHandler(recorder *types.EventRecorder) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body interface{}
+		if uid := r.URL.Query().Get("uid"); len(uid) > 0 {
+			body = recorder.Events(types.UID(uid))
+		} else {
+			body = recorder.Dump()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	})
+}
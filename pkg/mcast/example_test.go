@@ -0,0 +1,68 @@
+package mcast_test
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"time"
+)
+
+// Creates a unity exchanging the messages through the in memory
+// broker, so the example runs without a network, and shuts it down.
+func ExampleNewUnity() {
+	conf := mcast.DefaultConfiguration("example-orders")
+	conf.Broker = core.NewMemoryBroker()
+	conf.Logger.ToggleDebug(false)
+	unity, err := mcast.NewUnity(conf)
+	if err != nil {
+		fmt.Println("failed creating unity.", err)
+		return
+	}
+
+	fmt.Println("ready:", unity.Ready())
+	fmt.Println("stopped:", unity.Shutdown().Error())
+	// Output:
+	// ready: true
+	// stopped: <nil>
+}
+
+// Writes a value to the partitions on the destination and reads it
+// back once the write is delivered.
+func ExampleUnity_Write() {
+	conf := mcast.DefaultConfiguration("example-users")
+	conf.Broker = core.NewMemoryBroker()
+	conf.Logger.ToggleDebug(false)
+	unity, err := mcast.NewUnity(conf)
+	if err != nil {
+		fmt.Println("failed creating unity.", err)
+		return
+	}
+	defer func() {
+		unity.Shutdown().Error()
+	}()
+
+	destination := []types.Partition{"example-users"}
+	write := types.Request{
+		Key:         []byte("alice"),
+		Value:       []byte("admin"),
+		Destination: destination,
+	}
+	select {
+	case res := <-unity.Write(write):
+		fmt.Println("written:", res.Success)
+	case <-time.After(5 * time.Second):
+		fmt.Println("write timeout")
+		return
+	}
+
+	res, err := unity.Read(types.Request{Key: []byte("alice"), Destination: destination})
+	if err != nil {
+		fmt.Println("failed reading.", err)
+		return
+	}
+	fmt.Println("read:", string(res.Data))
+	// Output:
+	// written: true
+	// read: admin
+}
@@ -0,0 +1,214 @@
+package mcast
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+)
+
+// Default number of requests issued concurrently by the scheduler.
+const DefaultFairInFlight = 64
+
+// Options for the fair scheduler.
+type FairOptions struct {
+	// How many requests are issued to the protocol at the same
+	// time, the others wait on the queue of their clients.
+	InFlight int
+
+	// The weight of each client, a client with weight 3 issues
+	// three requests on its turn. Clients not present, including
+	// the requests without a client, have weight 1.
+	Weights map[string]int
+}
+
+// Metrics of the requests of a single client.
+type ClientMetrics struct {
+	// Requests waiting on the queue.
+	Pending int
+
+	// Requests already issued to the protocol.
+	Dispatched uint64
+}
+
+// A request waiting on the queue of its client.
+type scheduled struct {
+	request types.Request
+	res     chan types.Response
+}
+
+// Schedules the requests fairly across the clients, so a single
+// client issuing thousands of requests does not starve the requests
+// of the other clients. Each client has its own queue and, once the
+// number of requests in flight reaches the limit, the queues are
+// served in a weighted round robin.
+//
+// The order of the requests of the same client is kept, the
+// requests are written in the order of the queue and only the
+// responses are awaited concurrently.
+type FairScheduler struct {
+	// Synchronize access to the queues.
+	mutex *sync.Mutex
+
+	// Applies the request.
+	write WriteFunc
+
+	// The scheduler options.
+	options FairOptions
+
+	// Requests issued and not answered yet.
+	running int
+
+	// The queue of each client.
+	queues map[string][]scheduled
+
+	// Requests dispatched for each client.
+	dispatched map[string]uint64
+
+	// Clients with pending requests, in the round robin order.
+	order []string
+
+	// The client currently being served.
+	current int
+
+	// How many requests the current client can still issue on its turn.
+	credit int
+
+	// Used to spawn the dispatch go routines.
+	invoker core.Invoker
+}
+
+// Creates a new scheduler applying the requests with the given function.
+func NewFairScheduler(write WriteFunc, options FairOptions) *FairScheduler {
+	if options.InFlight <= 0 {
+		options.InFlight = DefaultFairInFlight
+	}
+	return &FairScheduler{
+		mutex:      &sync.Mutex{},
+		write:      write,
+		options:    options,
+		queues:     make(map[string][]scheduled),
+		dispatched: make(map[string]uint64),
+		invoker:    core.InvokerInstance(),
+	}
+}
+
+// Write the request once its client turn arrives.
+func (f *FairScheduler) Write(request types.Request) <-chan types.Response {
+	res := make(chan types.Response, 1)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.queues[request.Client]) == 0 {
+		f.order = append(f.order, request.Client)
+	}
+	f.queues[request.Client] = append(f.queues[request.Client], scheduled{request: request, res: res})
+	f.dispatch()
+	return res
+}
+
+// The metrics of each client seen by the scheduler.
+func (f *FairScheduler) Clients() map[string]ClientMetrics {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	clients := make(map[string]ClientMetrics)
+	for client, dispatched := range f.dispatched {
+		clients[client] = ClientMetrics{Dispatched: dispatched}
+	}
+	for client, queue := range f.queues {
+		metrics := clients[client]
+		metrics.Pending = len(queue)
+		clients[client] = metrics
+	}
+	return clients
+}
+
+// Issue the requests while there is room in flight. The request
+// is written while holding the mutex, so the requests of a client
+// reach the protocol in the order of its queue.
+// This method should be called while holding the mutex.
+func (f *FairScheduler) dispatch() {
+	for f.running < f.options.InFlight && len(f.order) > 0 {
+		s := f.next()
+		f.running++
+		response := f.write(s.request)
+		f.invoker.Spawn(func() {
+			f.answer(s, response)
+		})
+	}
+}
+
+// Remove the next request following the weighted round robin.
+// This method should be called while holding the mutex.
+func (f *FairScheduler) next() scheduled {
+	client := f.order[f.current]
+	if f.credit <= 0 {
+		f.credit = f.weight(client)
+	}
+
+	queue := f.queues[client]
+	s := queue[0]
+	f.credit--
+	f.dispatched[client]++
+	if len(queue) == 1 {
+		delete(f.queues, client)
+		f.order = append(f.order[:f.current], f.order[f.current+1:]...)
+		f.credit = 0
+	} else {
+		f.queues[client] = queue[1:]
+		if f.credit == 0 {
+			f.current++
+		}
+	}
+
+	if f.current >= len(f.order) {
+		f.current = 0
+	}
+	return s
+}
+
+// Answer the writer once the response arrives and issue the next request.
+func (f *FairScheduler) answer(s scheduled, res <-chan types.Response) {
+	response, ok := <-res
+	if ok {
+		s.res <- response
+	}
+	close(s.res)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.running--
+	f.dispatch()
+}
+
+func (f *FairScheduler) weight(client string) int {
+	if weight, ok := f.options.Weights[client]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// A Unity that schedules the writes fairly across the clients.
+type FairUnity struct {
+	Unity
+
+	// Schedules the writes.
+	scheduler *FairScheduler
+}
+
+// Decorates the unity scheduling the writes fairly across clients.
+func NewFairUnity(unity Unity, options FairOptions) *FairUnity {
+	return &FairUnity{
+		Unity:     unity,
+		scheduler: NewFairScheduler(unity.Write, options),
+	}
+}
+
+// Implements the Unity interface.
+func (f *FairUnity) Write(request types.Request) <-chan types.Response {
+	return f.scheduler.Write(request)
+}
+
+// The metrics of each client seen by the scheduler.
+func (f *FairUnity) Clients() map[string]ClientMetrics {
+	return f.scheduler.Clients()
+}
@@ -0,0 +1,81 @@
+package mcast
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+)
+
+// Reads the request, as done by the Unity and the Client.
+type ReadFunc func(request types.Request) (types.Response, error)
+
+// Intercepts a write, calling next to continue the chain. The
+// interceptor can change the request, the response, or answer
+// without calling next to reject the request.
+type WriteInterceptor func(request types.Request, next WriteFunc) <-chan types.Response
+
+// Intercepts a read, calling next to continue the chain.
+type ReadInterceptor func(request types.Request, next ReadFunc) (types.Response, error)
+
+// The interceptors applied on the unity requests. The first
+// interceptor is the outermost, it receives the request first
+// and the response last.
+type Interceptors struct {
+	// Applied on every write.
+	Write []WriteInterceptor
+
+	// Applied on every read.
+	Read []ReadInterceptor
+}
+
+// A Unity that applies interceptors on the writes and reads, so
+// cross-cutting concerns such as authorization, metrics and tagging
+// are added without changing the peers.
+type InterceptedUnity struct {
+	Unity
+
+	// The write chain, ending on the unity.
+	write WriteFunc
+
+	// The read chain, ending on the unity.
+	read ReadFunc
+}
+
+// Decorates the unity applying the interceptors.
+func NewInterceptedUnity(unity Unity, interceptors Interceptors) *InterceptedUnity {
+	write := WriteFunc(unity.Write)
+	for i := len(interceptors.Write) - 1; i >= 0; i-- {
+		interceptor, next := interceptors.Write[i], write
+		write = func(request types.Request) <-chan types.Response {
+			return interceptor(request, next)
+		}
+	}
+
+	read := ReadFunc(unity.Read)
+	for i := len(interceptors.Read) - 1; i >= 0; i-- {
+		interceptor, next := interceptors.Read[i], read
+		read = func(request types.Request) (types.Response, error) {
+			return interceptor(request, next)
+		}
+	}
+
+	return &InterceptedUnity{
+		Unity: unity,
+		write: write,
+		read:  read,
+	}
+}
+
+// Implements the Unity interface.
+func (i *InterceptedUnity) Write(request types.Request) <-chan types.Response {
+	return i.write(request)
+}
+
+// Implements the Unity interface.
+func (i *InterceptedUnity) Read(request types.Request) (types.Response, error) {
+	return i.read(request)
+}
+
+// Answers the write with the failure, so a write interceptor
+// can reject the request without calling the next.
+func Reject(err error) <-chan types.Response {
+	return failed("", err)
+}
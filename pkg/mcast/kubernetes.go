@@ -0,0 +1,118 @@
+package mcast
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables read by the Kubernetes bootstrap. The pod
+// name is usually exposed through the downward API, while the others
+// are set directly on the StatefulSet spec.
+const (
+	// The pod name, following the StatefulSet `<name>-<ordinal>` format.
+	// When not set the HOSTNAME is used, which has the same value.
+	EnvPodName = "POD_NAME"
+
+	// The partition name. Defaults to the StatefulSet name.
+	EnvPartition = "MCAST_PARTITION"
+
+	// How many peers each pod creates. Defaults to 1.
+	EnvReplication = "MCAST_REPLICATION"
+
+	// The datacenter and zone where the pod is deployed.
+	EnvDatacenter = "MCAST_DATACENTER"
+	EnvZone       = "MCAST_ZONE"
+
+	// The partition addresses, as a comma separated list of
+	// `partition=address` pairs.
+	EnvAddresses = "MCAST_ADDRESSES"
+
+	// The RabbitMQ connection URL. Defaults to a local broker.
+	EnvBrokerURL = "MCAST_BROKER_URL"
+)
+
+var (
+	// Returned when the pod name does not follow the StatefulSet format.
+	ErrInvalidPodName = errors.New("pod name is not <statefulset>-<ordinal>")
+)
+
+// Creates the configuration for a partition deployed as a Kubernetes
+// StatefulSet, reading the values from the environment.
+func KubernetesConfiguration() (*types.Configuration, error) {
+	return KubernetesConfigurationFrom(os.Getenv)
+}
+
+// Creates the configuration for a partition deployed as a Kubernetes
+// StatefulSet, reading the values using the given lookup function.
+//
+// The partition name and the replica ordinal are derived from the pod
+// name, so every pod of the StatefulSet belongs to the same partition
+// and the peer names are unique and stable across restarts.
+func KubernetesConfigurationFrom(lookup func(string) string) (*types.Configuration, error) {
+	pod := lookup(EnvPodName)
+	if len(pod) == 0 {
+		pod = lookup("HOSTNAME")
+	}
+
+	id, err := types.ParsePeerID(pod)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPodName, pod)
+	}
+
+	ordinal, name := id.Ordinal, id.Partition
+	if partition := lookup(EnvPartition); len(partition) > 0 {
+		name = types.Partition(partition)
+	}
+
+	replication := 1
+	if value := lookup(EnvReplication); len(value) > 0 {
+		if replication, err = strconv.Atoi(value); err != nil || replication <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", EnvReplication, value)
+		}
+	}
+
+	configuration := DefaultConfiguration(name)
+	configuration.Replication = replication
+	configuration.Ordinal = ordinal * replication
+	configuration.Location = types.Location{
+		Datacenter: lookup(EnvDatacenter),
+		Zone:       types.Zone(lookup(EnvZone)),
+	}
+
+	if value := lookup(EnvAddresses); len(value) > 0 {
+		addresses := make(definition.StaticResolver)
+		for _, pair := range strings.Split(value, ",") {
+			parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+				return nil, fmt.Errorf("invalid %s entry %q", EnvAddresses, pair)
+			}
+			addresses[types.Partition(parts[0])] = types.Address(parts[1])
+		}
+		configuration.Resolver = addresses
+	}
+
+	if url := lookup(EnvBrokerURL); len(url) > 0 {
+		configuration.Broker = core.ReltBroker{URL: url}
+	}
+	return configuration, nil
+}
+
+// Creates an HTTP handler to be used as the readiness probe. The
+// probe fails while the unity is not ready, so the pod only receives
+// requests after the peers started and while delivering messages.
+func ReadinessHandler(unity Unity) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !unity.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
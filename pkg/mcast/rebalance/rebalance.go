@@ -0,0 +1,258 @@
+// Package rebalance moves keys between partitions when the
+// partitioning policy changes, using the protocol itself so
+// the partitions keep serving requests during the migration.
+//
+// Each key is moved with three steps. First the key is frozen
+// on the router, waiting for the writes in flight and holding the
+// new ones. Then the committed value is exported from the old
+// partition and imported with a single request multicast to both
+// the old and the new partitions, which is also the cutover barrier:
+// both partitions order the import after every previous request on
+// the key. At last the router sends the requests for the key to
+// the new partition and the held writes are released.
+package rebalance
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned when the import is not answered in time.
+	ErrImportTimeout = errors.New("timeout importing key")
+
+	// Returned when the import is not applied.
+	ErrImportFailed = errors.New("failed importing key")
+
+	// Returned when the policy still sends keys to the
+	// partition being decommissioned.
+	ErrStillOwner = errors.New("policy still owns keys on the partition")
+)
+
+// Default time to wait for each key to be imported.
+const DefaultImportTimeout = 10 * time.Second
+
+// Issues the requests to the partitions. Both the Unity
+// and the Client can be used.
+type Client interface {
+	// Apply a request to the protocol.
+	Write(request types.Request) <-chan types.Response
+
+	// Query a value from one of the destination partitions.
+	Read(request types.Request) (types.Response, error)
+}
+
+// Decommissions a partition, the Unity of the partition
+// can be used.
+type Decommissioner interface {
+	// Stop the partition, notifying the given partitions.
+	Decommission(notify []types.Partition, timeout time.Duration) error
+}
+
+// Decides the partition owning each key.
+type Policy interface {
+	// The partition owning the key.
+	Partition(key []byte) types.Partition
+}
+
+// Adapts a function to the Policy interface.
+type PolicyFunc func(key []byte) types.Partition
+
+// Implements the Policy interface.
+func (f PolicyFunc) Partition(key []byte) types.Partition {
+	return f(key)
+}
+
+// Sends the requests to the partition owning the key, following the
+// policy and the keys already moved by a running migration.
+type Router struct {
+	// Issues the requests.
+	client Client
+
+	// Synchronize access to the policy and the keys.
+	mutex *sync.Mutex
+
+	// The current partitioning policy.
+	policy Policy
+
+	// Keys moved by the running migration, to their new partition.
+	moved map[string]types.Partition
+
+	// Held exclusively while moving the key, and shared by the writes.
+	locks map[string]*sync.RWMutex
+}
+
+// Create a new router issuing requests with the client.
+func NewRouter(client Client, policy Policy) *Router {
+	return &Router{
+		client: client,
+		mutex:  &sync.Mutex{},
+		policy: policy,
+		moved:  make(map[string]types.Partition),
+		locks:  make(map[string]*sync.RWMutex),
+	}
+}
+
+// The partition currently owning the key.
+func (r *Router) Partition(key []byte) types.Partition {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if partition, ok := r.moved[string(key)]; ok {
+		return partition
+	}
+	return r.policy.Partition(key)
+}
+
+// Write the request on the partition owning the key. If the key is
+// being moved, the write waits until the migration of the key finishes.
+func (r *Router) Write(request types.Request) <-chan types.Response {
+	lock := r.lock(request.Key)
+	lock.RLock()
+	request.Destination = []types.Partition{r.Partition(request.Key)}
+	res := make(chan types.Response, 1)
+	obs := r.client.Write(request)
+	core.InvokerInstance().Spawn(func() {
+		defer lock.RUnlock()
+		if response, ok := <-obs; ok {
+			res <- response
+		}
+		close(res)
+	})
+	return res
+}
+
+// Read the key from the partition owning it.
+func (r *Router) Read(request types.Request) (types.Response, error) {
+	request.Destination = []types.Partition{r.Partition(request.Key)}
+	return r.client.Read(request)
+}
+
+func (r *Router) lock(key []byte) *sync.RWMutex {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	lock, ok := r.locks[string(key)]
+	if !ok {
+		lock = &sync.RWMutex{}
+		r.locks[string(key)] = lock
+	}
+	return lock
+}
+
+// Finish the migration, so the policy owns all keys.
+func (r *Router) cutover(policy Policy) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.policy = policy
+	r.moved = make(map[string]types.Partition)
+	r.locks = make(map[string]*sync.RWMutex)
+}
+
+// The result of a migration.
+type Report struct {
+	// Keys moved to another partition.
+	Moved int
+
+	// Keys already on the right partition.
+	Unchanged int
+}
+
+// Moves the keys between partitions through the router.
+type Rebalancer struct {
+	// Routes the requests while migrating.
+	router *Router
+
+	// Time to wait for each key to be imported.
+	timeout time.Duration
+}
+
+// Create a new rebalancer migrating the keys of the router.
+func NewRebalancer(router *Router, timeout time.Duration) *Rebalancer {
+	if timeout <= 0 {
+		timeout = DefaultImportTimeout
+	}
+	return &Rebalancer{router: router, timeout: timeout}
+}
+
+// Move the given keys to the partitions of the new policy. The keys
+// are moved one at a time, and the router only uses the new policy
+// once every key is moved. When a key fails the migration stops, the
+// keys already moved stay on their new partition and the migration
+// can be started again with the same policy.
+func (r *Rebalancer) Rebalance(policy Policy, keys [][]byte) (Report, error) {
+	var report Report
+	for _, key := range keys {
+		from, to := r.router.Partition(key), policy.Partition(key)
+		if from == to {
+			report.Unchanged++
+			continue
+		}
+
+		if err := r.move(key, from, to); err != nil {
+			return report, fmt.Errorf("failed moving %q from %s to %s. %w", key, from, to, err)
+		}
+		report.Moved++
+	}
+	r.router.cutover(policy)
+	return report, nil
+}
+
+// Export the key from the old partition and import it on the new.
+func (r *Rebalancer) move(key []byte, from, to types.Partition) error {
+	lock := r.router.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	exported, err := r.router.client.Read(types.Request{Key: key, Destination: []types.Partition{from}})
+	if err != nil {
+		return err
+	}
+
+	request := types.Request{
+		Key:         key,
+		Value:       exported.Data,
+		Extra:       exported.Extra,
+		Destination: []types.Partition{from, to},
+	}
+	select {
+	case res, ok := <-r.router.client.Write(request):
+		if !ok || !res.Success {
+			if res.Failure != nil {
+				return res.Failure
+			}
+			return ErrImportFailed
+		}
+	case <-time.After(r.timeout):
+		return ErrImportTimeout
+	}
+
+	r.router.mutex.Lock()
+	defer r.router.mutex.Unlock()
+	r.router.moved[string(key)] = to
+	return nil
+}
+
+// Move the keys of the partition to the partitions of the new
+// policy and then decommission it through the given unity, waiting
+// up to the import timeout for the partition to drain. The policy
+// must not own any key on the decommissioned partition. The given
+// partitions receive the final marker of the partition.
+//
+// The partition is decommissioned only after every key is moved,
+// if the migration fails the partition is still serving requests.
+func (r *Rebalancer) Decommission(unity Decommissioner, partition types.Partition, policy Policy, keys [][]byte, notify []types.Partition) (Report, error) {
+	for _, key := range keys {
+		if policy.Partition(key) == partition {
+			return Report{}, fmt.Errorf("%w: %q on %s", ErrStillOwner, key, partition)
+		}
+	}
+
+	report, err := r.Rebalance(policy, keys)
+	if err != nil {
+		return report, err
+	}
+	return report, unity.Decommission(notify, r.timeout)
+}
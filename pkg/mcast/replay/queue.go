@@ -0,0 +1,34 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io"
+)
+
+// Read the queues as dumped by the queue handler.
+func LoadQueues(reader io.Reader) ([]types.QueueDump, error) {
+	var dumps []types.QueueDump
+	if err := json.NewDecoder(reader).Decode(&dumps); err != nil {
+		return nil, err
+	}
+	return dumps, nil
+}
+
+// Reconstruct the received queue of the dump, enqueueing the
+// messages in the order they were dumped. As on the peer, the
+// deliver function is called once a message on S3 reaches the head
+// of the queue, so the messages that were stuck stay pending and the
+// messages ready are delivered in the order the peer would commit.
+// The queue stops once the context is done.
+func RestoreQueue(ctx context.Context, dump types.QueueDump, conflict types.ConflictRelationship, deliver func(types.Message)) core.Queue {
+	queue := core.NewQueue(ctx, conflict, nil, func(i interface{}) {
+		deliver(i.(types.Message))
+	})
+	for _, message := range dump.Messages {
+		queue.Enqueue(message)
+	}
+	return queue
+}
@@ -0,0 +1,164 @@
+// Package replay re-executes offline the protocol decisions
+// recorded by the EventRecorder, so stuck or misordered messages
+// can be reproduced and bisected without the running cluster.
+//
+// The events of each peer are replayed in the order they were
+// recorded, verifying the state transitions of every message
+// and that all peers delivered the messages in the same order.
+//
+// The received queue dumped by the peers is reconstructed as well,
+// to investigate the ordering of the messages pending on a peer.
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io"
+	"sort"
+	"time"
+)
+
+// A protocol decision that does not hold.
+type Violation struct {
+	// The peer where the violation happened.
+	Peer string
+
+	// The message that violated the protocol.
+	Identifier types.UID
+
+	// Description of the violation.
+	Reason string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s on %s: %s", v.Identifier, v.Peer, v.Reason)
+}
+
+// The result of replaying the recorded events.
+type Report struct {
+	// How many events were replayed.
+	Events int
+
+	// The messages delivered by each peer, in order.
+	Delivered map[string][]types.UID
+
+	// All the violations found.
+	Violations []Violation
+}
+
+// A recorded event with its message.
+type recorded struct {
+	uid   types.UID
+	event types.Event
+}
+
+// Read the events as dumped by the events handler.
+func Load(reader io.Reader) (map[types.UID][]types.Event, error) {
+	var events map[types.UID][]types.Event
+	if err := json.NewDecoder(reader).Decode(&events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Replay the events recorded until the given time. When the
+// time is zero all the events are replayed.
+func Replay(events map[types.UID][]types.Event, until time.Time) *Report {
+	report := &Report{Delivered: make(map[string][]types.UID)}
+	peers := make(map[string][]recorded)
+	for uid, recordedEvents := range events {
+		for _, event := range recordedEvents {
+			if !until.IsZero() && event.Time.After(until) {
+				continue
+			}
+			peers[event.Peer] = append(peers[event.Peer], recorded{uid: uid, event: event})
+		}
+	}
+
+	var names []string
+	for name, timeline := range peers {
+		names = append(names, name)
+		sort.SliceStable(timeline, func(i, j int) bool {
+			if timeline[i].event.Time.Equal(timeline[j].event.Time) {
+				return timeline[i].uid < timeline[j].uid
+			}
+			return timeline[i].event.Time.Before(timeline[j].event.Time)
+		})
+		report.Events += len(timeline)
+		replayPeer(report, name, timeline)
+	}
+
+	sort.Strings(names)
+	for i := 1; i < len(names); i++ {
+		compareDelivery(report, names[0], names[i])
+	}
+	return report
+}
+
+// Replay the timeline of a single peer.
+func replayPeer(report *Report, peer string, timeline []recorded) {
+	checker := core.NewTransitionChecker()
+	delivered := make(map[types.UID]bool)
+	for _, r := range timeline {
+		switch r.event.Kind {
+		case types.EventStateChanged:
+			message := types.Message{Identifier: r.uid, State: r.event.State, Timestamp: r.event.Timestamp}
+			if err := checker.Observe(message); err != nil {
+				report.Violations = append(report.Violations, Violation{Peer: peer, Identifier: r.uid, Reason: err.Error()})
+			}
+		case types.EventDelivered:
+			if delivered[r.uid] {
+				report.Violations = append(report.Violations, Violation{Peer: peer, Identifier: r.uid, Reason: "delivered twice"})
+				continue
+			}
+			if r.event.State != types.S3 {
+				report.Violations = append(report.Violations, Violation{
+					Peer:       peer,
+					Identifier: r.uid,
+					Reason:     fmt.Sprintf("delivered on state S%d", r.event.State),
+				})
+			}
+			delivered[r.uid] = true
+			report.Delivered[peer] = append(report.Delivered[peer], r.uid)
+		}
+	}
+}
+
+// Verify the messages delivered by both peers are delivered in
+// the same relative order.
+func compareDelivery(report *Report, reference, peer string) {
+	common := make(map[types.UID]bool)
+	for _, uid := range report.Delivered[reference] {
+		common[uid] = true
+	}
+
+	var expected, found []types.UID
+	for _, uid := range report.Delivered[peer] {
+		if common[uid] {
+			found = append(found, uid)
+		}
+	}
+
+	delivered := make(map[types.UID]bool)
+	for _, uid := range found {
+		delivered[uid] = true
+	}
+	for _, uid := range report.Delivered[reference] {
+		if delivered[uid] {
+			expected = append(expected, uid)
+		}
+	}
+
+	for i := range expected {
+		if expected[i] != found[i] {
+			report.Violations = append(report.Violations, Violation{
+				Peer:       peer,
+				Identifier: found[i],
+				Reason:     fmt.Sprintf("delivered at position %d, where %s delivered %s", i, reference, expected[i]),
+			})
+			return
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package mcast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"hash/crc32"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned when the replica is configured without refreshing
+	// the subscription.
+	ErrReplicaRefresh = errors.New("replica refresh must be positive")
+)
+
+// A read-only node hydrated through log shipping. The replica
+// subscribes to the log delivered by a partition and applies the
+// entries locally, in the same order the peer shipping committed
+// them. Reads are served from the local storage, so they may
+// return values behind the partition, by how much is given by
+// the metrics.
+type ReadReplica interface {
+	// Query a value from the local storage.
+	Read(request types.Request) (types.Response, error)
+
+	// How far the replica is from the peer shipping the log.
+	Metrics() types.ReplicaMetrics
+
+	// Close the replica.
+	Close()
+}
+
+// Concrete implementation of the ReadReplica interface.
+type LogReplica struct {
+	// Synchronize access to the log position.
+	mutex *sync.Mutex
+
+	// The replica configuration.
+	configuration *types.ReplicaConfiguration
+
+	// Transport used to subscribe and receive the log.
+	transport core.Transport
+
+	// Applies the shipped entries on the state machine.
+	deliver core.Deliverable
+
+	// The peer shipping the log, empty until a peer answers.
+	source string
+
+	// Position of the last entry applied.
+	applied uint64
+
+	// The greatest position known on the peer log.
+	head uint64
+
+	// Entries received ahead of the next position.
+	pending map[uint64]types.Message
+
+	// The snapshot being received and how much of it arrived.
+	snapshot types.Chunk
+
+	// The chunks of the snapshot received so far, in order.
+	chunks []byte
+
+	// Last time the replica was caught up with the peer.
+	caught time.Time
+
+	// Last time the peer shipping the log answered.
+	contact time.Time
+
+	// Replica logger.
+	log types.Logger
+
+	// Used to spawn and control go routines.
+	invoker core.Invoker
+
+	// The replica cancellable context.
+	context context.Context
+
+	// Cancel function to finish the replica.
+	finish context.CancelFunc
+}
+
+// Creates a new read replica using the given configuration, the
+// replica subscribes to the partition right away.
+func NewReadReplica(configuration *types.ReplicaConfiguration) (ReadReplica, error) {
+	if configuration.Refresh <= 0 {
+		return nil, ErrReplicaRefresh
+	}
+	pc := &types.PeerConfiguration{
+		Name:       string(configuration.Name),
+		Partition:  configuration.Name,
+		Version:    configuration.Version,
+		Codec:      configuration.Codec,
+		Codecs:     configuration.Codecs,
+		SigningKey: configuration.SigningKey,
+		Resolver:   configuration.Resolver,
+		Broker:     configuration.Broker,
+		Consumer:   configuration.Consumer,
+	}
+	types.ApplyLogLevels(configuration.Logger, configuration.LogLevels)
+	reliable, err := core.NewTransport(pc, core.NewRTTEstimator(), types.SubsystemLogger(configuration.Logger, types.SubsystemTransport))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, done := context.WithCancel(context.Background())
+	log := types.SubsystemLogger(configuration.Logger, types.SubsystemReplica)
+	deliver, err := core.NewDeliver(ctx, log, &definition.AlwaysConflict{}, configuration.Storage)
+	if err != nil {
+		done()
+		reliable.Close()
+		return nil, err
+	}
+
+	r := &LogReplica{
+		mutex:         &sync.Mutex{},
+		configuration: configuration,
+		transport:     core.NewEpochTransport(reliable, pc, types.SubsystemLogger(configuration.Logger, types.SubsystemEpoch)),
+		deliver:       deliver,
+		pending:       make(map[uint64]types.Message),
+		caught:        time.Now(),
+		contact:       time.Now(),
+		log:           log,
+		invoker:       core.InvokerInstance(),
+		context:       ctx,
+		finish:        done,
+	}
+	r.invoker.Supervise(ctx, "replica "+string(configuration.Name), r.poll)
+	r.invoker.Supervise(ctx, "replica refresh "+string(configuration.Name), r.refresh)
+	return r, nil
+}
+
+// Implements the ReadReplica interface.
+func (r *LogReplica) Read(request types.Request) (types.Response, error) {
+	return core.ReadCommitted(r.configuration.Storage, r.deliver, request)
+}
+
+// Implements the ReadReplica interface.
+func (r *LogReplica) Metrics() types.ReplicaMetrics {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	metrics := types.ReplicaMetrics{
+		Source:  r.source,
+		Applied: r.applied,
+		Head:    r.head,
+	}
+	if r.head > r.applied {
+		metrics.Lag = r.head - r.applied
+		metrics.Behind = time.Since(r.caught)
+	}
+	return metrics
+}
+
+// Implements the ReadReplica interface.
+func (r *LogReplica) Close() {
+	r.finish()
+	r.transport.Close()
+}
+
+// Subscribe again to the partition while the replica is open. When
+// the peer shipping the log does not answer for three subscriptions
+// the replica subscribes to any peer, starting the log again.
+func (r *LogReplica) refresh() {
+	ticker := time.NewTicker(r.configuration.Refresh)
+	defer ticker.Stop()
+	for {
+		r.subscribe()
+		select {
+		case <-r.context.Done():
+			return
+		case <-ticker.C:
+		}
+
+		r.mutex.Lock()
+		if len(r.source) > 0 && time.Since(r.contact) > 3*r.configuration.Refresh {
+			r.log.Warnf("replica %s lost %s, subscribing again", r.configuration.Name, r.source)
+			r.source = ""
+			r.applied, r.head = 0, 0
+			r.pending = make(map[uint64]types.Message)
+			r.snapshot, r.chunks = types.Chunk{}, nil
+		}
+		r.mutex.Unlock()
+	}
+}
+
+// Ask the partition for the entries after the last applied, and for
+// the rest of the snapshot being received, if any.
+func (r *LogReplica) subscribe() {
+	r.mutex.Lock()
+	message := types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: r.configuration.Version,
+			Type:            types.Subscribe,
+			ReplyTo:         r.configuration.Name,
+			Target:          r.source,
+			Index:           r.applied + 1,
+			Chunk:           r.snapshot,
+		},
+		Identifier: types.UID(helper.GenerateUID()),
+		From:       r.configuration.Name,
+	}
+	r.mutex.Unlock()
+	if err := r.transport.Unicast(message, r.configuration.Partition); err != nil {
+		r.log.Errorf("replica %s failed subscribing to %s. %v", r.configuration.Name, r.configuration.Partition, err)
+	}
+}
+
+// Keep polling for the shipped log while the replica is open.
+func (r *LogReplica) poll() {
+	for {
+		select {
+		case <-r.context.Done():
+			return
+		case m, ok := <-r.transport.Listen():
+			if !ok {
+				return
+			}
+
+			if m.Header.Type != types.Ship {
+				r.log.Warnf("replica %s ignoring message %#v", r.configuration.Name, m)
+				continue
+			}
+			r.receive(m)
+		}
+	}
+}
+
+// Handle a message shipped by a peer. The replica stays with the
+// first peer answering, the others stop shipping once the replica
+// subscribes to the chosen peer.
+func (r *LogReplica) receive(m types.Message) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(m.Header.Failure) > 0 {
+		r.log.Errorf("replica %s failed hydrating from %s. %s", r.configuration.Name, m.Header.Origin, m.Header.Failure)
+		return
+	}
+
+	if len(r.source) == 0 {
+		r.source = m.Header.Origin
+		r.log.Infof("replica %s receiving the log from %s", r.configuration.Name, r.source)
+		r.invoker.Spawn(r.subscribe)
+	}
+	if m.Header.Origin != r.source {
+		return
+	}
+
+	r.contact = time.Now()
+	if m.Header.Index > r.head {
+		r.head = m.Header.Index
+	}
+
+	switch {
+	case m.Header.Flags.Has(types.FlagSnapshot):
+		r.hydrate(m)
+	case len(m.Identifier) > 0 && m.Header.Index > r.applied:
+		r.pending[m.Header.Index] = m
+	}
+	r.apply()
+}
+
+// Load the snapshot shipped, skipping the log up to its position.
+// The snapshot is loaded once every chunk is received.
+// This method should be called while holding the mutex.
+func (r *LogReplica) hydrate(m types.Message) {
+	if m.Header.Index <= r.applied {
+		return
+	}
+
+	content, ok := r.assemble(m)
+	if !ok {
+		return
+	}
+	var entries []*types.Entry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		r.log.Errorf("replica %s failed decoding snapshot. %v", r.configuration.Name, err)
+		return
+	}
+	if err := r.deliver.Load(entries); err != nil {
+		r.log.Errorf("replica %s failed loading snapshot. %v", r.configuration.Name, err)
+		return
+	}
+
+	r.applied = m.Header.Index
+	for index := range r.pending {
+		if index <= r.applied {
+			delete(r.pending, index)
+		}
+	}
+}
+
+// Append the chunk to the snapshot being received, returning the
+// whole snapshot once the last chunk arrives. A chunk corrupted or out
+// of order is discarded, the transfer is resumed from the last chunk
+// received on the next subscription.
+// This method should be called while holding the mutex.
+func (r *LogReplica) assemble(m types.Message) ([]byte, bool) {
+	chunk := m.Header.Chunk
+	if chunk.Total == 0 {
+		return m.Content.Content, true
+	}
+	if crc32.ChecksumIEEE(m.Content.Content) != chunk.Checksum {
+		r.log.Warnf("replica %s discarding corrupted chunk %d of snapshot %d", r.configuration.Name, chunk.Offset, chunk.Position)
+		return nil, false
+	}
+
+	if chunk.Position != r.snapshot.Position || chunk.Total != r.snapshot.Total {
+		if chunk.Offset != 0 {
+			return nil, false
+		}
+		r.snapshot = types.Chunk{Position: chunk.Position, Total: chunk.Total}
+		r.chunks = nil
+	}
+	if chunk.Offset != r.snapshot.Offset {
+		return nil, false
+	}
+
+	r.chunks = append(r.chunks, m.Content.Content...)
+	r.snapshot.Offset = uint64(len(r.chunks))
+	if r.snapshot.Offset < r.snapshot.Total {
+		return nil, false
+	}
+	content := r.chunks
+	r.snapshot, r.chunks = types.Chunk{}, nil
+	return content, true
+}
+
+// Apply the pending entries following the last applied, in order.
+// This method should be called while holding the mutex.
+func (r *LogReplica) apply() {
+	var ready []types.Message
+	for next := r.applied + 1; ; next++ {
+		m, ok := r.pending[next]
+		if !ok {
+			break
+		}
+		delete(r.pending, next)
+		ready = append(ready, m)
+	}
+
+	if len(ready) > 0 {
+		for _, res := range r.deliver.CommitBatch(ready) {
+			if res.Failure != nil {
+				r.log.Errorf("replica %s failed applying %s. %v", r.configuration.Name, res.Identifier, res.Failure)
+			}
+		}
+		r.applied += uint64(len(ready))
+	}
+	if r.applied >= r.head {
+		r.caught = time.Now()
+	}
+}
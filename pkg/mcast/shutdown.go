@@ -0,0 +1,87 @@
+package mcast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The failures of the peers while shutting down a unity.
+type ShutdownError struct {
+	// The failure of each peer that did not stop cleanly.
+	Errors []error
+}
+
+func (s *ShutdownError) Error() string {
+	failures := make([]string, len(s.Errors))
+	for i, err := range s.Errors {
+		failures[i] = err.Error()
+	}
+	return fmt.Sprintf("shutdown failed on %d peers: %s", len(s.Errors), strings.Join(failures, "; "))
+}
+
+// Verify if any peer failure matches the target.
+func (s *ShutdownError) Is(target error) bool {
+	for _, err := range s.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// The result of a unity shutdown. The peers are stopped when the
+// shutdown starts, the shutdown completes once every goroutine
+// of the unity finished.
+type Future interface {
+	// Closed once the shutdown completes.
+	Done() <-chan struct{}
+
+	// Blocks until the shutdown completes, returning the failures
+	// of the peers as a *ShutdownError, or nil if every peer
+	// stopped cleanly.
+	Error() error
+
+	// Same as Error, returning the context error if the context
+	// is done before the shutdown completes.
+	Wait(ctx context.Context) error
+}
+
+// Implements the Future interface.
+type shutdownFuture struct {
+	// Closed once the shutdown completes.
+	done chan struct{}
+
+	// The failures, only read after done is closed.
+	err error
+}
+
+// Implements the Future interface.
+func (s *shutdownFuture) Done() <-chan struct{} {
+	return s.done
+}
+
+// Implements the Future interface.
+func (s *shutdownFuture) Error() error {
+	<-s.done
+	return s.err
+}
+
+// Implements the Future interface.
+func (s *shutdownFuture) Wait(ctx context.Context) error {
+	select {
+	case <-s.done:
+		return s.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Complete the future with the failures of the peers.
+func (s *shutdownFuture) complete(failures []error) {
+	if len(failures) > 0 {
+		s.err = &ShutdownError{Errors: failures}
+	}
+	close(s.done)
+}
@@ -0,0 +1,266 @@
+// Package testutil provides test doubles to verify how the protocol
+// behaves from outside, as the warnings and errors it logs.
+//
+// The Logger records every entry, so a test asserts the paths that
+// only log, e.g., a peer discarding a message on another version:
+//
+//	func TestMismatch(t *testing.T) {
+//		logger := testutil.NewLogger()
+//		conf := mcast.DefaultConfiguration("orders")
+//		conf.Logger = logger
+//		...
+//		logger.AssertLogged(t, types.LevelWarn, "on version")
+//	}
+package testutil
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// An entry recorded by the Logger.
+type Entry struct {
+	// The severity of the entry, fatal and panic entries are
+	// recorded as errors.
+	Level types.LogLevel
+
+	// The subsystem logging, empty for the root logger.
+	Subsystem string
+
+	// The formatted message.
+	Message string
+}
+
+func (e Entry) String() string {
+	if len(e.Subsystem) > 0 {
+		return fmt.Sprintf("[%s %s]: %s", e.Level, e.Subsystem, e.Message)
+	}
+	return fmt.Sprintf("[%s]: %s", e.Level, e.Message)
+}
+
+// The entries and levels shared by the loggers of every subsystem.
+type recorder struct {
+	mutex   *sync.Mutex
+	entries []Entry
+	levels  map[string]types.LogLevel
+}
+
+// Implements the LevelLogger interface, recording the entries in
+// memory instead of writing them. Every level is recorded unless the
+// subsystem is configured above it, so the protocol configuring the
+// levels from the peer configuration still works as usual.
+//
+// Fatal does not exit and Panic still panics, both are recorded
+// as errors first.
+type Logger struct {
+	// The entries recorded by every subsystem.
+	recorder *recorder
+
+	// The subsystem logging, empty for the root logger.
+	subsystem string
+}
+
+// Creates a logger recording every entry.
+func NewLogger() *Logger {
+	return &Logger{
+		recorder: &recorder{
+			mutex:  &sync.Mutex{},
+			levels: map[string]types.LogLevel{"": types.LevelDebug},
+		},
+	}
+}
+
+// The level of the subsystem, or of its closest parent.
+// This method should be called while holding the mutex.
+func (r *recorder) level(subsystem string) types.LogLevel {
+	for {
+		if level, ok := r.levels[subsystem]; ok {
+			return level
+		}
+		i := strings.LastIndex(subsystem, ".")
+		if i < 0 {
+			return r.levels[""]
+		}
+		subsystem = subsystem[:i]
+	}
+}
+
+func (l *Logger) record(level types.LogLevel, message string) {
+	l.recorder.mutex.Lock()
+	defer l.recorder.mutex.Unlock()
+	if l.recorder.level(l.subsystem) > level {
+		return
+	}
+	l.recorder.entries = append(l.recorder.entries, Entry{
+		Level:     level,
+		Subsystem: l.subsystem,
+		Message:   message,
+	})
+}
+
+// The entries recorded so far, in order.
+func (l *Logger) Entries() []Entry {
+	l.recorder.mutex.Lock()
+	defer l.recorder.mutex.Unlock()
+	entries := make([]Entry, len(l.recorder.entries))
+	copy(entries, l.recorder.entries)
+	return entries
+}
+
+// Discard the entries recorded so far.
+func (l *Logger) Reset() {
+	l.recorder.mutex.Lock()
+	defer l.recorder.mutex.Unlock()
+	l.recorder.entries = nil
+}
+
+// The entries recorded on the level containing the substring.
+func (l *Logger) Logged(level types.LogLevel, substring string) []Entry {
+	var found []Entry
+	for _, entry := range l.Entries() {
+		if entry.Level == level && strings.Contains(entry.Message, substring) {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// Fail the test when no entry on the level contains the substring.
+func (l *Logger) AssertLogged(t testing.TB, level types.LogLevel, substring string) {
+	t.Helper()
+	if len(l.Logged(level, substring)) == 0 {
+		t.Errorf("expected %s entry with %q, found:\n%s", level, substring, l.dump())
+	}
+}
+
+// Fail the test when an entry on the level contains the substring.
+func (l *Logger) AssertNotLogged(t testing.TB, level types.LogLevel, substring string) {
+	t.Helper()
+	if found := l.Logged(level, substring); len(found) > 0 {
+		t.Errorf("unexpected %s entry with %q: %s", level, substring, found[0])
+	}
+}
+
+// Wait until an entry on the level contains the substring, failing
+// the test after the timeout. The peers log asynchronously, so the
+// entry may be recorded after the action causing it returned.
+func (l *Logger) AwaitLogged(t testing.TB, level types.LogLevel, substring string, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for len(l.Logged(level, substring)) == 0 {
+		if time.Now().After(deadline) {
+			t.Errorf("expected %s entry with %q in %s, found:\n%s", level, substring, timeout, l.dump())
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (l *Logger) dump() string {
+	var lines []string
+	for _, entry := range l.Entries() {
+		lines = append(lines, entry.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Implements the LevelLogger interface.
+func (l *Logger) Subsystem(name string) types.Logger {
+	return &Logger{recorder: l.recorder, subsystem: name}
+}
+
+// Implements the LevelLogger interface.
+func (l *Logger) SetLevel(subsystem string, level types.LogLevel) {
+	l.recorder.mutex.Lock()
+	defer l.recorder.mutex.Unlock()
+	l.recorder.levels[subsystem] = level
+}
+
+// Implements the LevelLogger interface.
+// The root level is never removed.
+func (l *Logger) ResetLevel(subsystem string) {
+	if len(subsystem) == 0 {
+		return
+	}
+	l.recorder.mutex.Lock()
+	defer l.recorder.mutex.Unlock()
+	delete(l.recorder.levels, subsystem)
+}
+
+// Implements the LevelLogger interface.
+func (l *Logger) Levels() map[string]types.LogLevel {
+	l.recorder.mutex.Lock()
+	defer l.recorder.mutex.Unlock()
+	levels := make(map[string]types.LogLevel, len(l.recorder.levels))
+	for subsystem, level := range l.recorder.levels {
+		levels[subsystem] = level
+	}
+	return levels
+}
+
+// Implements the Logger interface.
+// Changes the root level between debug and info.
+func (l *Logger) ToggleDebug(value bool) bool {
+	if value {
+		l.SetLevel("", types.LevelDebug)
+	} else {
+		l.SetLevel("", types.LevelInfo)
+	}
+	return value
+}
+
+func (l *Logger) Info(v ...interface{}) {
+	l.record(types.LevelInfo, fmt.Sprint(v...))
+}
+
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.record(types.LevelInfo, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Warn(v ...interface{}) {
+	l.record(types.LevelWarn, fmt.Sprint(v...))
+}
+
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.record(types.LevelWarn, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Error(v ...interface{}) {
+	l.record(types.LevelError, fmt.Sprint(v...))
+}
+
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.record(types.LevelError, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Debug(v ...interface{}) {
+	l.record(types.LevelDebug, fmt.Sprint(v...))
+}
+
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.record(types.LevelDebug, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Fatal(v ...interface{}) {
+	l.record(types.LevelError, fmt.Sprint(v...))
+}
+
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.record(types.LevelError, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Panic(v ...interface{}) {
+	message := fmt.Sprint(v...)
+	l.record(types.LevelError, message)
+	panic(message)
+}
+
+func (l *Logger) Panicf(format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	l.record(types.LevelError, message)
+	panic(message)
+}
@@ -0,0 +1,217 @@
+// Package timeline renders the events recorded by the EventRecorder
+// as a timeline for each key, showing how every message moved from
+// S0 to S3 on each peer and where it was delivered.
+//
+// The timelines are rendered as Mermaid sequence diagrams, Graphviz
+// graphs or JSON, to be visualized or consumed by a user interface.
+package timeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Returned when the format is not known.
+var ErrUnknownFormat = errors.New("unknown timeline format")
+
+// How the timelines are rendered.
+type Format string
+
+const (
+	// A Mermaid sequence diagram for each key.
+	Mermaid Format = "mermaid"
+
+	// A Graphviz graph for each key, one column for each peer.
+	Graphviz Format = "dot"
+
+	// The timelines as JSON.
+	JSON Format = "json"
+)
+
+// A single event of a message on the timeline.
+type Step struct {
+	// The message the event happened with.
+	Identifier types.UID `json:"uid"`
+
+	// The recorded event.
+	Event types.Event `json:"event"`
+}
+
+// The events of every message with the same key, in the
+// order they happened across the peers.
+type Timeline struct {
+	// The key of the messages.
+	Key string `json:"key"`
+
+	// The peers where the events happened, sorted.
+	Peers []string `json:"peers"`
+
+	// The events, oldest first.
+	Steps []Step `json:"steps"`
+}
+
+// Group the recorded events by the message key. When the given key
+// is not empty, only the timeline of the key is built. The timelines
+// are sorted by key.
+func Build(events map[types.UID][]types.Event, key string) []Timeline {
+	byKey := make(map[string]*Timeline)
+	for uid, recorded := range events {
+		for _, event := range recorded {
+			k := string(event.Key)
+			if len(key) > 0 && k != key {
+				continue
+			}
+			t, ok := byKey[k]
+			if !ok {
+				t = &Timeline{Key: k}
+				byKey[k] = t
+			}
+			t.Steps = append(t.Steps, Step{Identifier: uid, Event: event})
+		}
+	}
+
+	timelines := make([]Timeline, 0, len(byKey))
+	for _, t := range byKey {
+		peers := make(map[string]bool)
+		for _, step := range t.Steps {
+			peers[step.Event.Peer] = true
+		}
+		for peer := range peers {
+			t.Peers = append(t.Peers, peer)
+		}
+		sort.Strings(t.Peers)
+		sort.SliceStable(t.Steps, func(i, j int) bool {
+			a, b := t.Steps[i], t.Steps[j]
+			if a.Event.Time.Equal(b.Event.Time) {
+				return a.Identifier < b.Identifier
+			}
+			return a.Event.Time.Before(b.Event.Time)
+		})
+		timelines = append(timelines, *t)
+	}
+	sort.Slice(timelines, func(i, j int) bool {
+		return timelines[i].Key < timelines[j].Key
+	})
+	return timelines
+}
+
+// Write the timelines on the given format.
+func Render(writer io.Writer, timelines []Timeline, format Format) error {
+	switch format {
+	case Mermaid:
+		return render(writer, timelines, mermaid)
+	case Graphviz:
+		return render(writer, timelines, graphviz)
+	case JSON:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(timelines)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+// Write each timeline with the given function, separated by a line.
+func render(writer io.Writer, timelines []Timeline, f func(*strings.Builder, Timeline)) error {
+	for i, t := range timelines {
+		b := &strings.Builder{}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		f(b, t)
+		if _, err := io.WriteString(writer, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A sequence diagram with a participant for each peer. The messages
+// sent and received are arrows between the peer and the partition,
+// the state changes and deliveries are notes over the peer.
+func mermaid(b *strings.Builder, t Timeline) {
+	b.WriteString("sequenceDiagram\n")
+	fmt.Fprintf(b, "    title key %q\n", t.Key)
+	aliases := make(map[string]string)
+	for i, peer := range t.Peers {
+		aliases[peer] = fmt.Sprintf("p%d", i)
+		fmt.Fprintf(b, "    participant p%d as %s\n", i, peer)
+	}
+	for _, step := range t.Steps {
+		partition := string(step.Event.Partition)
+		if _, ok := aliases[partition]; !ok && len(partition) > 0 {
+			aliases[partition] = fmt.Sprintf("g%d", len(aliases))
+			fmt.Fprintf(b, "    participant %s as %s\n", aliases[partition], partition)
+		}
+	}
+
+	for _, step := range t.Steps {
+		// The semicolon separates the statements.
+		peer, e, text := aliases[step.Event.Peer], step.Event, strings.ReplaceAll(label(step), ";", ",")
+		switch {
+		case e.Kind == types.EventSent && len(e.Partition) > 0:
+			fmt.Fprintf(b, "    %s->>%s: %s\n", peer, aliases[string(e.Partition)], text)
+		case e.Kind == types.EventReceived && len(e.Partition) > 0:
+			fmt.Fprintf(b, "    %s-->>%s: %s\n", aliases[string(e.Partition)], peer, text)
+		default:
+			fmt.Fprintf(b, "    Note over %s: %s\n", peer, text)
+		}
+	}
+}
+
+// A graph with a cluster for each peer, holding the events of the
+// peer in order. The events of the same message are linked across
+// the peers, following the order they happened.
+func graphviz(b *strings.Builder, t Timeline) {
+	fmt.Fprintf(b, "digraph %q {\n", "key "+t.Key)
+	b.WriteString("    rankdir=LR;\n    node [shape=box];\n")
+	nodes := make(map[string][]string)
+	messages := make(map[types.UID][]string)
+	for i, step := range t.Steps {
+		node := fmt.Sprintf("e%d", i)
+		nodes[step.Event.Peer] = append(nodes[step.Event.Peer], node)
+		messages[step.Identifier] = append(messages[step.Identifier], node)
+		fmt.Fprintf(b, "    %s [label=%q];\n", node, label(step))
+	}
+
+	for i, peer := range t.Peers {
+		fmt.Fprintf(b, "    subgraph cluster_%d {\n        label=%q;\n", i, peer)
+		for j, node := range nodes[peer] {
+			fmt.Fprintf(b, "        %s;\n", node)
+			if j > 0 {
+				fmt.Fprintf(b, "        %s -> %s;\n", nodes[peer][j-1], node)
+			}
+		}
+		b.WriteString("    }\n")
+	}
+
+	var uids []types.UID
+	for uid := range messages {
+		uids = append(uids, uid)
+	}
+	sort.Slice(uids, func(i, j int) bool {
+		return uids[i] < uids[j]
+	})
+	for _, uid := range uids {
+		chain := messages[uid]
+		for i := 1; i < len(chain); i++ {
+			fmt.Fprintf(b, "    %s -> %s [style=dashed];\n", chain[i-1], chain[i])
+		}
+	}
+	b.WriteString("}\n")
+}
+
+// Describes the event of the step.
+func label(step Step) string {
+	e := step.Event
+	text := fmt.Sprintf("%s %s S%d@%d", step.Identifier, e.Kind, e.State, e.Timestamp)
+	if len(e.Detail) > 0 {
+		text += " " + e.Detail
+	}
+	return text
+}
@@ -0,0 +1,38 @@
+package types
+
+// The state of a message pending on a peer, used by
+// operators to diagnose stuck messages.
+type MessageStatus struct {
+	// The peer holding the message.
+	Peer string `json:"peer"`
+
+	// The message identifier.
+	Identifier UID `json:"identifier"`
+
+	// The message state and timestamp on the peer.
+	State     MessageState `json:"state"`
+	Timestamp uint64       `json:"timestamp"`
+
+	// The partitions receiving the message.
+	Destination []Partition `json:"destination"`
+
+	// The partitions that did not send their timestamp yet.
+	Missing []Partition `json:"missing,omitempty"`
+
+	// How many messages are ordered before it on the peer.
+	Position int `json:"position"`
+}
+
+// The received queue of a peer, dumped so the ordering of the
+// pending messages can be investigated offline.
+type QueueDump struct {
+	// The peer holding the queue.
+	Peer string `json:"peer"`
+
+	// The partition of the peer.
+	Partition Partition `json:"partition"`
+
+	// The messages pending on the queue, with their state and
+	// timestamp, in the order of the queue.
+	Messages []Message `json:"messages"`
+}
@@ -0,0 +1,92 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// The state of the circuit breaker of a partition.
+type BreakerState int
+
+const (
+	// The messages are sent to the partition.
+	BreakerClosed BreakerState = iota
+
+	// A single message is sent to the partition as a probe,
+	// the others fail fast until the probe finishes.
+	BreakerHalfOpen
+
+	// The messages to the partition fail fast.
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("breaker %d", int(s))
+	}
+}
+
+// When the circuit breaker of a partition opens.
+type BreakerPolicy struct {
+	// How many consecutive failures sending to a partition
+	// open its circuit. Zero disables the breaker.
+	Failures int
+
+	// How long the circuit stays open before a probe is sent.
+	Cooldown time.Duration
+}
+
+// The circuit breaker of a partition changed its state.
+type BreakerEvent struct {
+	// The peer sending to the partition.
+	Peer string
+
+	// The partition of the circuit.
+	Partition Partition
+
+	// The previous and the new state.
+	From BreakerState
+	To   BreakerState
+
+	// When the state changed.
+	Time time.Time
+}
+
+// Called when the circuit of a partition changes its state.
+type BreakerListener func(BreakerEvent)
+
+// Counters of the circuit breaker of a single partition.
+type BreakerMetrics struct {
+	// The current state.
+	State BreakerState
+
+	// How many times the circuit opened.
+	Opened uint64
+
+	// How many probes were sent while half-open.
+	Probes uint64
+
+	// How many messages failed fast.
+	Rejected uint64
+}
+
+// Merge the metrics of two peers, keeping the worst state.
+func (b BreakerMetrics) Merge(other BreakerMetrics) BreakerMetrics {
+	state := b.State
+	if other.State > state {
+		state = other.State
+	}
+	return BreakerMetrics{
+		State:    state,
+		Opened:   b.Opened + other.Opened,
+		Probes:   b.Probes + other.Probes,
+		Rejected: b.Rejected + other.Rejected,
+	}
+}
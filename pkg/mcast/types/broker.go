@@ -0,0 +1,33 @@
+package types
+
+// Data received from the broker.
+type Delivery struct {
+	// The received data.
+	Data []byte
+
+	// Why the data could not be received.
+	Error error
+}
+
+// The broker connecting the transports of every partition.
+// The data published to an address is delivered to every
+// connection subscribed to that address, in the order it
+// was published. By default a RabbitMQ broker is used.
+type Broker interface {
+	// Connect the named peer, subscribed to the given address.
+	Connect(name string, address Address) (BrokerConnection, error)
+}
+
+// A connection of a single peer to the broker.
+type BrokerConnection interface {
+	// Publish the data to every connection subscribed
+	// to the given address.
+	Publish(address Address, data []byte) error
+
+	// The data received on the subscribed address. The
+	// channel is closed after the connection is closed.
+	Consume() <-chan Delivery
+
+	// Close the connection for publishing and consuming.
+	Close()
+}
@@ -0,0 +1,27 @@
+package types
+
+// Identifies a write delivered on a partition, so a later read of
+// the key observes it. Returned on the response of the writes.
+type CausalityToken struct {
+	// The key written.
+	Key []byte
+
+	// The final timestamp of the write.
+	Timestamp uint64
+}
+
+// Selects how a read with a causality token is answered.
+type ReadMode uint8
+
+const (
+	// The read is answered by the peer with the value it delivered,
+	// which may not include the write of the token yet.
+	ReadLocal ReadMode = iota
+
+	// The read waits until the peer delivers the write of the token.
+	ReadWait
+
+	// The read is answered by a peer of the unity known to have
+	// delivered the write of the token, waiting when none did.
+	ReadProxy
+)
@@ -0,0 +1,37 @@
+package types
+
+// Used to serialize the messages sent through the transport.
+// All peers and clients communicating must use the same codec,
+// unless the codecs are negotiated, see CodecID.
+type Codec interface {
+	// Serialize the given value.
+	Marshal(v interface{}) ([]byte, error)
+
+	// Deserialize the data into the value pointed by v.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Identifies a codec on the wire. Each codec has its own bit, so
+// a set of codecs is also represented as a CodecID.
+type CodecID uint8
+
+const (
+	CodecJSON CodecID = 1 << iota
+	CodecMsgpack
+	CodecGob
+	CodecCBOR
+)
+
+// Verify if the codec is present on the set.
+func (c CodecID) Has(codec CodecID) bool {
+	return codec != 0 && c&codec == codec
+}
+
+// A codec that can be identified on the wire. Only identified
+// codecs take part on the negotiation.
+type IdentifiedCodec interface {
+	Codec
+
+	// The codec identifier.
+	ID() CodecID
+}
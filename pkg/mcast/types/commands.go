@@ -1,5 +1,7 @@
 package types
 
+import "context"
+
 // Unique identifier to be associated with the message.
 // When a request is made, the user will receive this unique
 // identifier and the request will be processed throughout the
@@ -50,6 +52,35 @@ const (
 	// when exchanging the message timestamp between partitions.
 	External
 
+	// A reply sent back to a client that is not participating
+	// on the protocol, after its request was handled.
+	Reply
+
+	// A read request issued by a client that is not participating
+	// on the protocol, this will be answered by a fast read.
+	Retrieve
+
+	// Request the retransmission of a message that was not
+	// received, identified by the sequence number.
+	Retransmit
+
+	// Stops a partition from participating on the protocol. Sent
+	// to the partition itself as the cut, after which no new
+	// message is accepted, and once drained, sent to the other
+	// partitions as the final marker of the partition.
+	Decommission
+
+	// Moves a partition to the next epoch, sent to the partition
+	// itself on membership or recovery events.
+	Epoch
+
+	// Sent by a read replica to the partition, to receive the
+	// delivered log starting from the header index.
+	Subscribe
+
+	// An entry of the delivered log shipped to a read replica.
+	Ship
+
 	// Defines the latest protocol version
 	LatestProtocolVersion = 0
 
@@ -62,19 +93,6 @@ const (
 	Query Operation = "query"
 )
 
-// Internal use only, to transport any specific
-// information between the peers.
-type ProtocolHeader struct {
-	// Transport the configured version at which the protocol
-	// will work, any increments in version must be described
-	// and what have changed.
-	ProtocolVersion uint
-
-	// Information about the kind of message that will be
-	// processed.
-	Type MessageType
-}
-
 // Implemented by the protocol messages that will be sent
 // internally by the protocol.
 type HeaderExtract interface {
@@ -90,6 +108,10 @@ type Request struct {
 	// The request key the value will be associated with.
 	Key []byte
 
+	// Other keys the request touches, considered when
+	// verifying the conflicts with other requests.
+	Keys [][]byte
+
 	// The concrete value that will be replicated.
 	Value []byte
 
@@ -99,6 +121,48 @@ type Request struct {
 
 	// Partitions that will receive the request.
 	Destination []Partition
+
+	// When reading, if set the committed entries matching
+	// the filter are returned instead of the current value.
+	History *HistoryFilter
+
+	// How the request is ordered relative to the others.
+	Consistency ConsistencyLevel
+
+	// How many destination partitions must acknowledge enqueueing
+	// the request before the write succeeds. Without the receipts
+	// in time the write fails with ErrWriteConcern, though the
+	// request may still be delivered. Zero does not wait.
+	Acknowledgments int
+
+	// When reading, the write the read must observe, taken from the
+	// response of the write. Honoured following the read mode, and
+	// only by the unity.
+	After *CausalityToken
+
+	// How the read waits for the write of the causality token.
+	ReadMode ReadMode
+
+	// When writing, the pending messages conflicting with the
+	// request are boosted, sending their timestamps again to the
+	// partitions that did not answer, see FeaturePriorityInheritance.
+	Urgent bool
+
+	// Identifies who issued the request, such as a client or
+	// a namespace, so the requests are scheduled fairly. This
+	// is not replicated.
+	Client string
+
+	// When set, receives the progress of the request until it is
+	// delivered on every destination partition. The events are
+	// dropped when the channel is full, and the channel is never
+	// closed. Only followed by the unity issuing the request.
+	Progress chan<- Progress
+
+	// When done, the unity stops waiting for the response and the
+	// response channel fails with the context error. The request
+	// may still be delivered. This is not replicated.
+	Context context.Context
 }
 
 // The final user will only receive as response what is
@@ -120,6 +184,12 @@ type Response struct {
 	// Replicated extra information.
 	Extra []byte
 
+	// The committed entries, when reading the history.
+	History []Entry
+
+	// The write delivered, for reading it afterwards.
+	Token *CausalityToken
+
 	// If an error happened, this will transfer the
 	// error back.
 	Failure error
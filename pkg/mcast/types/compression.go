@@ -0,0 +1,59 @@
+package types
+
+// Default values for the dictionary compression.
+const (
+	DefaultCompressionSamples = 1000
+	DefaultCompressionSize    = 1024
+)
+
+// Compresses the small contents with a dictionary trained from
+// the contents delivered before, which compresses far better than
+// compressing each content alone.
+type DictionaryCodec interface {
+	// Train a dictionary from the sampled contents.
+	Train(samples [][]byte) ([]byte, error)
+
+	// Compress the content with the dictionary.
+	Compress(dictionary, content []byte) ([]byte, error)
+
+	// Decompress the content compressed with the dictionary.
+	Decompress(dictionary, content []byte) ([]byte, error)
+}
+
+// Holds the trained dictionaries by version. Every partition must
+// share the store, so the receivers find the dictionary used by the
+// sender. A dictionary is never changed once stored.
+type DictionaryStore interface {
+	// Store the dictionary with the version.
+	Put(version uint32, dictionary []byte) error
+
+	// The dictionary with the version. Fails when not stored.
+	Get(version uint32) ([]byte, error)
+}
+
+// Controls the compression of the message contents between the
+// partitions. Disabled while the codec or the store are not set.
+//
+// The peer samples the contents it delivers and, once enough samples
+// are collected, trains a new dictionary. The next messages are sent
+// compressed with the new dictionary, and its version is carried on
+// the message header.
+type Compression struct {
+	// Trains the dictionaries and compresses the contents.
+	Codec DictionaryCodec
+
+	// Where the dictionaries are shared.
+	Dictionaries DictionaryStore
+
+	// How many contents train each dictionary.
+	Samples int
+
+	// Contents larger than this, in bytes, are neither sampled
+	// nor compressed.
+	Size int
+}
+
+// Verify if the compression is enabled.
+func (c Compression) Enabled() bool {
+	return c.Codec != nil && c.Dictionaries != nil
+}
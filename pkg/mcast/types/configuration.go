@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 // Holds the peer configuration.
 type PeerConfiguration struct {
 	// The peer name.
@@ -15,9 +17,121 @@ type PeerConfiguration struct {
 	// delivery sequence.
 	Conflict ConflictRelationship
 
+	// Which messages are evaluated for conflicts.
+	Strictness ConflictStrictness
+
+	// Verifies the destination of the messages.
+	Membership Membership
+
 	// Stable storage to commit the values of the state
 	// machine.
 	Storage Storage
+
+	// Storages of the independent state machines, the
+	// values are committed on the shard of the key.
+	Shards []Storage
+
+	// Stable storage where the outbox is persisted.
+	Outbox Storage
+
+	// When the storage flushes the commits, if the storage
+	// supports controlling it.
+	Durability Durability
+
+	// How many ready messages are committed at once.
+	BatchSize int
+
+	// How many ready messages not conflicting are applied
+	// concurrently.
+	Parallelism int
+
+	// How the delivered messages are remembered.
+	Dedup DedupWindow
+
+	// Where the peer partition is deployed.
+	Location Location
+
+	// Describes where the other partitions are deployed.
+	Topology Topology
+
+	// Codec used to serialize the messages on the transport.
+	Codec Codec
+
+	// Codecs negotiated with the other partitions, cheapest first.
+	Codecs []IdentifiedCodec
+
+	// Key shared by the cluster signing the messages.
+	SigningKey []byte
+
+	// Compresses the contents sent to the partitions.
+	Compression Compression
+
+	// Resolve the transport address of the partitions.
+	Resolver Resolver
+
+	// The broker connecting the transports. RabbitMQ when nil.
+	Broker Broker
+
+	// How the transport handles a slow listener.
+	Consumer SlowConsumer
+
+	// How to retry the timestamp exchange with other partitions.
+	Retry RetryPolicy
+
+	// When the circuit of a partition opens.
+	Breaker BreakerPolicy
+
+	// How a broadcast fans out to the destinations.
+	Fanout Fanout
+
+	// When the timestamps are exchanged through a tree.
+	Tree TreeExchange
+
+	// Called when the circuit of a partition changes, if set.
+	OnBreaker BreakerListener
+
+	// How long a request waits for its response.
+	ObserverTTL time.Duration
+
+	// Interval of the no-op multicast while messages are pending.
+	Heartbeat time.Duration
+
+	// Interval reclaiming the entries of the finished messages.
+	GCInterval time.Duration
+
+	// Received messages the peer may have unfinished at once.
+	Credits int
+
+	// How much the clock increases on each tick.
+	ClockStep uint64
+
+	// If every message is addressed only to the own partition.
+	Local bool
+
+	// The optimizations enabled on the peer.
+	Features Features
+
+	// Called after each message is committed, if set.
+	OnDeliver DeliverCallback
+
+	// Called once a cut marker is delivered, if set.
+	OnCut CutListener
+
+	// Inspects the requests before they are sent.
+	Interceptors []SendInterceptor
+
+	// Where the asynchronous failures are reported. Peers of
+	// the same unity share the reporter.
+	Errors *ErrorReporter
+
+	// Records the protocol events of each message, if set.
+	Recorder *EventRecorder
+
+	// Writes every protocol step, if set.
+	Tracer *StepTracer
+
+	// Where the protocol measurements are reported, if set.
+	Metrics Metrics
 }
 
 // The configuration for using the atomic multicast.
@@ -32,6 +146,11 @@ type Configuration struct {
 	// this partition create.
 	Replication int
 
+	// The index of the first peer created. When each peer
+	// runs on its own process, e.g., a StatefulSet pod, the
+	// ordinal keeps the peer names unique on the partition.
+	Ordinal int
+
 	// Which version of the protocol will be used.
 	Version uint
 
@@ -39,9 +158,255 @@ type Configuration struct {
 	// to order the requests for delivery.
 	Conflict ConflictRelationship
 
+	// Which messages are evaluated for conflicts when
+	// ordering the requests.
+	Strictness ConflictStrictness
+
+	// The partitions known on the cluster. When the members are set,
+	// a message to a partition not known fails with the error
+	// core.ErrUnknownPartition, instead of waiting forever for the
+	// timestamp of the partition.
+	Membership Membership
+
 	// Stable storage to maintaining the state machine data.
 	Storage Storage
 
+	// Split the state machine into independent shards, each one
+	// committing on its own storage. The messages are routed to a
+	// shard by the hash of the key, only the messages of the same
+	// shard are committed in order and the shards concurrently, so
+	// the ordering of each key is kept. The Storage still holds the
+	// protocol data, as the outbox. Empty commits on the Storage.
+	Shards []Storage
+
+	// Stable storage where the messages not sent yet are persisted,
+	// so they are sent again after a crash. When nil, the outbox is
+	// persisted on the Storage under core.OutboxNamespace. A storage
+	// evicting values, as a bounded definition.InMemoryStorage, is
+	// rejected with core.ErrEvictingOutbox.
+	Outbox Storage
+
+	// When the storage flushes the commits. Only applied if the
+	// storage implements the DurableStorage interface.
+	Durability Durability
+
+	// The maximum number of ready messages committed on a single
+	// storage call. Only applied if the storage implements the
+	// BatchStateMachine interface, values below 2 disable batching.
+	BatchSize int
+
+	// The maximum number of ready messages committed concurrently.
+	// The messages committed at once are split by the conflict
+	// relationship, the messages conflicting are committed in order
+	// and the others concurrently, so the state machine must be safe
+	// for concurrent commits. Only applied to the ready messages
+	// committed at once, values below 2 commit them sequentially.
+	Parallelism int
+
+	// How the delivered messages are remembered to ignore the
+	// messages replayed by the transport, also across restarts.
+	Dedup DedupWindow
+
 	// Logger to be used by the protocol.
 	Logger Logger
+
+	// The level of each subsystem, applied if the logger
+	// implements the LevelLogger interface. See ParseLogLevels.
+	LogLevels map[string]LogLevel
+
+	// Where this partition is deployed.
+	Location Location
+
+	// Describes where the other partitions are deployed,
+	// used to prefer closer partitions when sending.
+	Topology Topology
+
+	// Codec used to serialize the messages on the transport.
+	// All partitions must use the same codec, unless the
+	// codecs are negotiated.
+	Codec Codec
+
+	// Codecs this partition is able to decode, from the cheapest
+	// to the most expensive. When set, the messages to a partition
+	// are serialized with the first codec every peer of that
+	// partition advertised, and with the Codec while the partition
+	// did not advertise yet. Empty disables the negotiation.
+	Codecs []IdentifiedCodec
+
+	// Key shared by the cluster, every message is signed with the
+	// key and the messages not signed with it are dropped, so a
+	// process on the broker can not impersonate a partition. Every
+	// partition and client must use the same key. Empty disables
+	// the signing.
+	SigningKey []byte
+
+	// Compresses the small contents sent to the partitions with
+	// dictionaries trained from the delivered contents. Every
+	// partition must share the dictionaries. Disabled when not set.
+	Compression Compression
+
+	// Resolve the transport address of the partitions, so
+	// the topology can change without changing the names.
+	Resolver Resolver
+
+	// The broker connecting the transports of every partition,
+	// all partitions must use the same broker. When nil, the
+	// RabbitMQ broker is used through relt.
+	Broker Broker
+
+	// How the transport handles the peers not consuming the
+	// received messages in time. By default the transport
+	// blocks until the peer consumes the message.
+	Consumer SlowConsumer
+	// Verify the requests before they enter the protocol.
+	Validators []Validator
+
+	// Rewrite the destination of the requests or veto them before
+	// they are sent, applied in order. The decisions are recorded
+	// on the events of the message and logged.
+	Interceptors []SendInterceptor
+
+	// How to retry the timestamp exchange with partitions
+	// that are slow or unreachable.
+	Retry RetryPolicy
+
+	// When the messages to a partition failing consecutively
+	// start failing fast with core.ErrPartitionUnavailable.
+	Breaker BreakerPolicy
+
+	// How many destinations a broadcast sends to concurrently, and
+	// whether a broadcast reaching only some destinations fails. The
+	// failures are returned by partition on a types.MultiError.
+	Fanout Fanout
+
+	// Messages with many destinations exchange the timestamps
+	// through a tree, aggregating the timestamps on the way up, so
+	// the messages exchanged grow linearly with the destinations.
+	// When the tree does not complete in time, the timestamps are
+	// sent again directly to the partitions missing. Every partition
+	// must use the same tree configuration.
+	Tree TreeExchange
+
+	// Called when the circuit of a partition changes its state
+	// on any peer. The listener must not block.
+	OnBreaker BreakerListener
+
+	// How long a request waits for its response before failing
+	// with core.ErrObserverExpired, also when the client abandoned
+	// the response channel. Zero waits until the request context
+	// is done, or forever without a context.
+	ObserverTTL time.Duration
+
+	// When messages are pending and the peer received nothing for
+	// this interval, the peer multicasts a no-op to the partitions of
+	// the pending messages. The no-op is ordered as any message and
+	// never committed, so the pending messages are processed again
+	// when the traffic is sparse. Zero disables the heartbeat.
+	Heartbeat time.Duration
+
+	// On each interval the peer reclaims the timestamps exchanged
+	// for the messages no longer pending, and removes the dropped
+	// or expired messages from the set evaluated for conflicts.
+	// Zero disables the collection.
+	GCInterval time.Duration
+
+	// How many received messages the peer may have unfinished at
+	// once. The transport stops receiving from the broker once the
+	// peer has this many messages it did not finish processing, so
+	// a burst waits on the broker instead of being handled by the
+	// slow consumer strategy. Zero disables the flow control.
+	Credits int
+
+	// How much the logical clock increases on each tick, one when
+	// zero. Once the clock can not tick without wrapping around the
+	// peer rejects the new messages with core.ErrClockOverflow.
+	ClockStep uint64
+
+	// Runs the partition as a replicated log on its own. Every
+	// message is addressed only to the partition, a write without
+	// destination is addressed to it and a write to other partitions
+	// fails with core.ErrNotLocal. The timestamps are never exchanged,
+	// so the messages go from S0 to S3 on a single step.
+	Local bool
+
+	// Switches the protocol optimizations individually, so they
+	// can be enabled incrementally and their effect compared on
+	// the partition stats. The features not set use the defaults.
+	Features Features
+
+	// Called after each message is committed on the state machine,
+	// once for the unity. See DeliverCallback for the ordering.
+	OnDeliver DeliverCallback
+
+	// Called on each peer once a cut marker is delivered, with the
+	// state of the partition at the cut, for example, to write the
+	// partition part of a global backup. The messages after the
+	// marker are committed once the listener returns.
+	OnCut CutListener
+
+	// Runs the delivery callback. When nil, the callback runs on
+	// the goroutine committing the message, see core.WorkerPool to
+	// run the callbacks concurrently.
+	Executor Executor
+
+	// Records the last protocol events of each message for
+	// diagnosing. Disabled when not set.
+	Recorder *EventRecorder
+
+	// Writes every protocol step of the peers, to validate the
+	// execution against the specification. Disabled when not set.
+	Tracer *StepTracer
+
+	// Where the peers report the protocol measurements, labeled
+	// with the partition and the peer name. Discarded when not set.
+	Metrics Metrics
+}
+
+// The configuration for a client that only issues requests
+// and do not participate on the protocol.
+type ClientConfiguration struct {
+	// The client name. This will also be used as the client
+	// address on the transport, so must be unique.
+	Name Partition
+
+	// Which version of the protocol will be used.
+	Version uint
+
+	// How long the client waits for a reply.
+	Timeout time.Duration
+
+	// Logger to be used by the client.
+	Logger Logger
+
+	// The level of each subsystem, applied if the logger
+	// implements the LevelLogger interface.
+	LogLevels map[string]LogLevel
+
+	// Where the client is deployed.
+	Location Location
+
+	// Describes where the partitions are deployed, used
+	// to prefer a partition on the same zone when reading.
+	Topology Topology
+
+	// Codec used to serialize the messages on the transport.
+	Codec Codec
+
+	// Codecs negotiated with the partitions, cheapest first.
+	Codecs []IdentifiedCodec
+
+	// Key shared by the cluster signing the messages.
+	SigningKey []byte
+
+	// Resolve the transport address of the partitions.
+	Resolver Resolver
+
+	// The broker connecting to the partitions. RabbitMQ when nil.
+	Broker Broker
+
+	// How the transport handles the replies not consumed in time.
+	Consumer SlowConsumer
+
+	// Verify the requests before they are sent.
+	Validators []Validator
 }
@@ -8,3 +8,19 @@ type ConflictRelationship interface {
 	// previous messages on the set.
 	Conflict(message Message, messages []Message) bool
 }
+
+// How strictly the conflicts are evaluated when a
+// message receives its group timestamp.
+type ConflictStrictness int
+
+const (
+	// Conflicts are evaluated only against the previous set,
+	// as on the protocol definition.
+	ConflictPreviousSet ConflictStrictness = iota
+
+	// Conflicts are also evaluated against the messages still
+	// pending on the received queue. This closes the window where
+	// a conflicting message in flight was removed from the previous
+	// set, at the cost of more clock increments.
+	ConflictPending
+)
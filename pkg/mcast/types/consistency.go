@@ -0,0 +1,19 @@
+package types
+
+// Selects how a request is ordered relative to the others.
+type ConsistencyLevel uint8
+
+const (
+	// The request is ordered only relative to the requests that
+	// conflict with it, following the conflict relationship.
+	ConsistencyGeneric ConsistencyLevel = iota
+
+	// The request is ordered relative to every other request,
+	// even the ones that would not conflict with it.
+	ConsistencyTotalOrder
+
+	// The request is never ordered relative to the others, it is
+	// delivered as soon as it receives its final timestamp. Requests
+	// with total order are still ordered relative to it.
+	ConsistencyLocal
+)
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// What the transport does when the listener does not consume a
+// received message in time. No strategy discards the message.
+type ConsumerStrategy int
+
+const (
+	// Wait until the listener consumes the message, which also
+	// stops receiving the next messages from the broker.
+	ConsumerBlock ConsumerStrategy = iota
+
+	// Write the message to a file on the disk, and the following
+	// messages as well, until the listener consumes them all.
+	ConsumerSpill
+
+	// Log at fatal level, so the process crashes and the broker
+	// delivers the messages again once restarted.
+	ConsumerCrash
+)
+
+func (c ConsumerStrategy) String() string {
+	switch c {
+	case ConsumerBlock:
+		return "block"
+	case ConsumerSpill:
+		return "spill"
+	case ConsumerCrash:
+		return "crash"
+	default:
+		return fmt.Sprintf("strategy %d", int(c))
+	}
+}
+
+// Controls how the transport handles a listener that does not
+// keep up with the received messages. A message is considered
+// late after the timeout adapted from the round trips to the
+// sender, and each late message is reported as a ConsumerFailure.
+type SlowConsumer struct {
+	// What to do with the messages not consumed in time.
+	Strategy ConsumerStrategy
+
+	// Directory where the messages are spilled, the temporary
+	// directory if empty. Only used by the ConsumerSpill strategy.
+	Directory string
+}
+
+// How the listener of a transport keeps up with the messages.
+type ConsumerMetrics struct {
+	// Messages not consumed in time.
+	Slow uint64
+
+	// How long the transport waited for the listener after the
+	// messages were late.
+	Blocked time.Duration
+
+	// Messages written to the disk.
+	Spilled uint64
+
+	// Messages spilled and not consumed yet.
+	Pending uint64
+
+	// How long the transport waited for the listener to grant
+	// credits before receiving from the broker.
+	Throttled time.Duration
+}
+
+// Merge the metrics of two transports.
+func (c ConsumerMetrics) Merge(other ConsumerMetrics) ConsumerMetrics {
+	return ConsumerMetrics{
+		Slow:      c.Slow + other.Slow,
+		Blocked:   c.Blocked + other.Blocked,
+		Spilled:   c.Spilled + other.Spilled,
+		Pending:   c.Pending + other.Pending,
+		Throttled: c.Throttled + other.Throttled,
+	}
+}
@@ -0,0 +1,34 @@
+package types
+
+import "time"
+
+// How long to wait for the cut marker to be delivered.
+const DefaultCutTimeout = 30 * time.Second
+
+// The state of a partition on a consistent cut. The cut marker is
+// ordered against every message, so a message delivered on many
+// partitions is either before the marker on all of them or after
+// the marker on all of them. The cuts of the partitions with the
+// same identifier form a globally consistent snapshot.
+type Cut struct {
+	// Identifies the marker establishing the cut, the same on
+	// every partition.
+	Identifier UID
+
+	// The partition the state belongs to.
+	Partition Partition
+
+	// The final timestamp of the marker.
+	Timestamp uint64
+
+	// The entries committed before the marker.
+	Entries []Entry
+
+	// Why the state could not be read, such as when the state
+	// machine does not keep the history.
+	Failure error
+}
+
+// Called once the cut marker is delivered, before any message
+// after the marker is committed.
+type CutListener func(Cut)
@@ -11,6 +11,11 @@ type DataHolder struct {
 	// be used based on the cluster information.
 	Key []byte
 
+	// Other keys touched by the operation, such as on
+	// transactions and batch updates. The value is still
+	// associated only with the Key.
+	Keys [][]byte
+
 	// If there is any value to be written into the
 	// state machine it will be hold here.
 	// This will only have a value if the operation is
@@ -26,6 +31,35 @@ type DataHolder struct {
 	Extensions []byte
 }
 
+// All the keys touched by the operation, without repetition.
+func (d DataHolder) KeySet() [][]byte {
+	seen := make(map[string]bool)
+	var keys [][]byte
+	for _, key := range append([][]byte{d.Key}, d.Keys...) {
+		if len(key) == 0 || seen[string(key)] {
+			continue
+		}
+		seen[string(key)] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Verify if both operations touch at least one common key.
+func (d DataHolder) Overlaps(other DataHolder) bool {
+	keys := make(map[string]bool)
+	for _, key := range d.KeySet() {
+		keys[string(key)] = true
+	}
+
+	for _, key := range other.KeySet() {
+		if keys[string(key)] {
+			return true
+		}
+	}
+	return false
+}
+
 // Entry that is committed into the state machine.
 type Entry struct {
 	// Which kind of entry is this.
@@ -0,0 +1,36 @@
+package types
+
+import "time"
+
+// Default values for the dedup window.
+const (
+	DefaultDedupFalsePositive = 0.0001
+	DefaultDedupRotation      = 10 * time.Minute
+	DefaultDedupPersist       = 10 * time.Second
+)
+
+// Controls how the peer remembers the messages already delivered,
+// so messages replayed by the transport are not delivered again.
+//
+// When the capacity is zero the delivered messages are kept only in
+// memory for a few minutes. Otherwise they are kept on a rotating bloom
+// filter, persisted on the storage so the window survives restarts.
+// A bloom filter can report a message never delivered as delivered,
+// and such message is ignored by the peer, so the false positive rate
+// must be low enough for the workload.
+type DedupWindow struct {
+	// How many messages are expected on each rotation.
+	Capacity int
+
+	// The expected false positive rate with the capacity,
+	// between 0 and 1.
+	FalsePositive float64
+
+	// How long until the oldest messages are forgotten. A message
+	// is remembered for at least one and at most two rotations.
+	Rotation time.Duration
+
+	// How often the window is persisted on the storage. Messages
+	// delivered after the last persist are forgotten on a crash.
+	Persist time.Duration
+}
@@ -0,0 +1,38 @@
+package types
+
+// A message committed on the state machine, handed to the
+// delivery callback.
+type Delivered struct {
+	// The committed message.
+	Message Message
+
+	// The result of committing the message.
+	Response Response
+}
+
+// Executed after a message is committed on the state machine, to
+// integrate side effects such as cache invalidation.
+//
+// The callback runs once for each message delivered by the unity,
+// even though every peer of the unity commits the message. The
+// deliveries of messages with the same key are handed to the
+// callback in the delivery order and one at a time, the deliveries
+// of different keys may run concurrently, depending on the executor.
+type DeliverCallback func(Delivered)
+
+// Runs the delivery callbacks. The executor does not need to
+// preserve any order, the ordering guarantees of the callback are
+// enforced before the tasks are submitted, but every task submitted
+// must eventually run.
+type Executor interface {
+	// Run the task, on the caller goroutine or asynchronously.
+	Execute(task func())
+}
+
+// Adapts a function to the Executor interface.
+type ExecutorFunc func(task func())
+
+// Implements the Executor interface.
+func (f ExecutorFunc) Execute(task func()) {
+	f(task)
+}
@@ -0,0 +1,36 @@
+package types
+
+import "time"
+
+// When the persistent storage flushes the committed values,
+// trading the commit latency for the durability.
+type SyncPolicy int
+
+const (
+	// Every commit is flushed before it is acknowledged.
+	SyncOnCommit SyncPolicy = iota
+
+	// The storage never flushes explicitly, relying on the
+	// operating system. A crash can lose acknowledged commits.
+	NoSync
+
+	// The commits are flushed at most once per interval. A crash
+	// can lose the commits acknowledged during the last interval.
+	SyncBatched
+)
+
+// Controls when the persistent storage flushes.
+type Durability struct {
+	// When the storage flushes.
+	Policy SyncPolicy
+
+	// The interval between flushes, for the batched policy.
+	Interval time.Duration
+}
+
+// Implemented by the storage backends that can control
+// when the commits are flushed.
+type DurableStorage interface {
+	// Use the given durability for the next commits.
+	SetDurability(durability Durability)
+}
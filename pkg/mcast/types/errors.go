@@ -0,0 +1,125 @@
+package types
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Default size of the buffer holding the failures not
+// consumed by the application yet.
+const DefaultErrorBuffer = 256
+
+// Kinds of failures that happen asynchronously.
+type FailureKind int
+
+const (
+	// The transport failed sending or receiving a message.
+	TransportFailure FailureKind = iota
+
+	// The state machine failed committing a message.
+	CommitFailure
+
+	// A message was lost by the transport or discarded
+	// because it could not be decoded.
+	DroppedMessage
+
+	// The other partitions did not answer the timestamp
+	// exchange after all the attempts.
+	GatherFailure
+
+	// The listener did not consume a received message in time,
+	// the message is handled following the SlowConsumer strategy.
+	ConsumerFailure
+)
+
+func (k FailureKind) String() string {
+	switch k {
+	case TransportFailure:
+		return "transport failure"
+	case CommitFailure:
+		return "commit failure"
+	case DroppedMessage:
+		return "dropped message"
+	case GatherFailure:
+		return "gather failure"
+	case ConsumerFailure:
+		return "consumer failure"
+	default:
+		return fmt.Sprintf("failure %d", int(k))
+	}
+}
+
+// A failure that happened outside of any call made by
+// the application, which otherwise would only be logged.
+type AsyncError struct {
+	// What kind of failure happened.
+	Kind FailureKind
+
+	// The peer where the failure happened.
+	Peer string
+
+	// The message related to the failure, if any.
+	Identifier UID
+
+	// The underlying error.
+	Err error
+}
+
+func (e *AsyncError) Error() string {
+	if len(e.Identifier) > 0 {
+		return fmt.Sprintf("%s on %s for %s. %v", e.Kind, e.Peer, e.Identifier, e.Err)
+	}
+	return fmt.Sprintf("%s on %s. %v", e.Kind, e.Peer, e.Err)
+}
+
+func (e *AsyncError) Unwrap() error {
+	return e.Err
+}
+
+// Publishes the asynchronous failures to the application.
+// Reporting never blocks the protocol, when the buffer is full
+// the failure is discarded and only counted.
+//
+// A nil reporter ignores all failures.
+type ErrorReporter struct {
+	// How many failures were discarded.
+	discarded uint64
+
+	// Buffer of failures not consumed yet.
+	errors chan error
+}
+
+// Create a new reporter buffering up to size failures.
+func NewErrorReporter(size int) *ErrorReporter {
+	return &ErrorReporter{errors: make(chan error, size)}
+}
+
+// Publish the failure without blocking.
+func (e *ErrorReporter) Report(err *AsyncError) {
+	if e == nil {
+		return
+	}
+
+	select {
+	case e.errors <- err:
+	default:
+		atomic.AddUint64(&e.discarded, 1)
+	}
+}
+
+// The channel where the failures are published. The channel
+// is never closed, since failures can happen while stopping.
+func (e *ErrorReporter) Errors() <-chan error {
+	if e == nil {
+		return nil
+	}
+	return e.errors
+}
+
+// How many failures were discarded because the buffer was full.
+func (e *ErrorReporter) Discarded() uint64 {
+	if e == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&e.discarded)
+}
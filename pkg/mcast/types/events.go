@@ -0,0 +1,144 @@
+package types
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Kinds of protocol events recorded.
+type EventKind string
+
+const (
+	// The message was received from the transport.
+	EventReceived EventKind = "received"
+
+	// The message changed its state or timestamp.
+	EventStateChanged EventKind = "state"
+
+	// The message was sent to a partition.
+	EventSent EventKind = "sent"
+
+	// The message was committed on the state machine.
+	EventDelivered EventKind = "delivered"
+
+	// A send interceptor changed the message destination.
+	EventRouted EventKind = "routed"
+
+	// A send interceptor vetoed the message.
+	EventVetoed EventKind = "vetoed"
+)
+
+// A single protocol event of a message.
+type Event struct {
+	// What happened with the message.
+	Kind EventKind `json:"kind"`
+
+	// The peer where the event happened.
+	Peer string `json:"peer"`
+
+	// The message type and state when the event happened.
+	Type      MessageType  `json:"type"`
+	State     MessageState `json:"state"`
+	Timestamp uint64       `json:"timestamp"`
+
+	// The partition the message was received from or sent to.
+	Partition Partition `json:"partition,omitempty"`
+
+	// The key of the message.
+	Key []byte `json:"key,omitempty"`
+
+	// Describes the event, such as why the message was routed.
+	Detail string `json:"detail,omitempty"`
+
+	// When the event happened.
+	Time time.Time `json:"time"`
+}
+
+// Records the last protocol events of each message, so stuck
+// or misordered messages can be diagnosed without enabling the
+// debug logs. Only a bounded number of messages is kept, the
+// ones recorded first are evicted first.
+//
+// A nil recorder ignores all events.
+type EventRecorder struct {
+	// Synchronize access to the events.
+	mutex *sync.Mutex
+
+	// How many events are kept for each message.
+	events int
+
+	// How many messages are kept.
+	messages int
+
+	// The events of each message, oldest first.
+	recorded map[UID][]Event
+
+	// The order the messages were first recorded.
+	order *list.List
+}
+
+// Create a new recorder keeping the last events of each message,
+// for up to the given number of messages.
+func NewEventRecorder(events, messages int) *EventRecorder {
+	return &EventRecorder{
+		mutex:    &sync.Mutex{},
+		events:   events,
+		messages: messages,
+		recorded: make(map[UID][]Event),
+		order:    list.New(),
+	}
+}
+
+// Record the event for the message.
+func (e *EventRecorder) Record(uid UID, event Event) {
+	if e == nil || e.events <= 0 || e.messages <= 0 {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	events, ok := e.recorded[uid]
+	if !ok {
+		e.order.PushBack(uid)
+		for e.order.Len() > e.messages {
+			delete(e.recorded, e.order.Remove(e.order.Front()).(UID))
+		}
+	}
+
+	events = append(events, event)
+	if len(events) > e.events {
+		events = append([]Event(nil), events[len(events)-e.events:]...)
+	}
+	e.recorded[uid] = events
+}
+
+// The events recorded for the message, oldest first.
+func (e *EventRecorder) Events(uid UID) []Event {
+	if e == nil {
+		return nil
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]Event(nil), e.recorded[uid]...)
+}
+
+// The events recorded for all messages.
+func (e *EventRecorder) Dump() map[UID][]Event {
+	dump := make(map[UID][]Event)
+	if e == nil {
+		return dump
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for uid, events := range e.recorded {
+		dump[uid] = append([]Event(nil), events...)
+	}
+	return dump
+}
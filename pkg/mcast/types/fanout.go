@@ -0,0 +1,64 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// How many destinations a broadcast sends to concurrently
+// when not configured.
+const DefaultFanoutWorkers = 8
+
+// How a broadcast reaching only some destinations is handled.
+type PartialFailure uint8
+
+const (
+	// The broadcast fails when any destination fails.
+	FailOnAny PartialFailure = iota
+
+	// The broadcast fails only when every destination fails. The
+	// failed destinations are recovered by the protocol, when the
+	// timestamps are exchanged again or the gaps are retransmitted.
+	FailOnAll
+)
+
+// How a broadcast fans out to the destination partitions. Every
+// destination is attempted, also after a failure, and the failures
+// are returned by partition on a MultiError.
+type Fanout struct {
+	// How many destinations are sent concurrently, one sends in
+	// sequence. Zero uses DefaultFanoutWorkers.
+	Workers int
+
+	// When the broadcast fails.
+	Partial PartialFailure
+}
+
+// The failures of sending to many partitions, by partition.
+type MultiError map[Partition]error
+
+func (m MultiError) Error() string {
+	partitions := make([]string, 0, len(m))
+	for partition := range m {
+		partitions = append(partitions, string(partition))
+	}
+	sort.Strings(partitions)
+
+	failures := make([]string, 0, len(partitions))
+	for _, partition := range partitions {
+		failures = append(failures, fmt.Sprintf("%s: %v", partition, m[Partition(partition)]))
+	}
+	return strings.Join(failures, "; ")
+}
+
+// Verify if the failure of any partition matches the target.
+func (m MultiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// An optimization of the protocol that can be switched on and
+// off individually, so its effect can be measured.
+type Feature string
+
+const (
+	// Delivers the messages in state S3 that do not conflict with
+	// any pending message, without waiting to reach the queue head.
+	FeatureGenericDelivery Feature = "generic_delivery"
+
+	// Adapts the time waiting for the partitions to the round trips
+	// observed, instead of always waiting the same initial timeout.
+	FeatureAdaptiveTimeouts Feature = "adaptive_timeouts"
+
+	// Commits the ready messages in batches of up to BatchSize.
+	FeatureBatching Feature = "batching"
+
+	// Sends again the timestamps of the pending messages conflicting
+	// with an urgent message, so the urgent message is not delayed
+	// behind the exchanges of neglected messages.
+	FeaturePriorityInheritance Feature = "priority_inheritance"
+)
+
+// Returned when parsing a feature that does not exist.
+var ErrUnknownFeature = errors.New("unknown feature")
+
+// Whether each feature is enabled. The features not present
+// use the default, see DefaultFeatures.
+type Features map[Feature]bool
+
+// The features enabled when not configured.
+var DefaultFeatures = Features{
+	FeatureGenericDelivery:     true,
+	FeatureAdaptiveTimeouts:    true,
+	FeatureBatching:            true,
+	FeaturePriorityInheritance: true,
+}
+
+// Verify if the feature is enabled.
+func (f Features) Enabled(feature Feature) bool {
+	if enabled, ok := f[feature]; ok {
+		return enabled
+	}
+	return DefaultFeatures[feature]
+}
+
+// The features enabled, sorted by name.
+func (f Features) List() []Feature {
+	var enabled []Feature
+	for feature := range DefaultFeatures {
+		if f.Enabled(feature) {
+			enabled = append(enabled, feature)
+		}
+	}
+	sort.Slice(enabled, func(i, j int) bool {
+		return enabled[i] < enabled[j]
+	})
+	return enabled
+}
+
+// Parse a comma separated list of features, a feature prefixed
+// by `-` is disabled, e.g., "batching,-generic_delivery".
+func ParseFeatures(spec string) (Features, error) {
+	features := make(Features)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		enabled := !strings.HasPrefix(entry, "-")
+		feature := Feature(strings.TrimPrefix(entry, "-"))
+		if _, ok := DefaultFeatures[feature]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownFeature, feature)
+		}
+		features[feature] = enabled
+	}
+	return features, nil
+}
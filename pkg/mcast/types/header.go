@@ -0,0 +1,181 @@
+package types
+
+// Bits enabling optional features for a message. Each
+// feature that needs to be signaled on the header defines
+// its own bit.
+type HeaderFlag uint32
+
+const (
+	// On a client read, the content holds a HistoryFilter and the
+	// reply content holds only the entries matching the filter.
+	// Peers that do not know the flag reply with the current value.
+	FlagHistory HeaderFlag = 1 << iota
+
+	// On a shipped message, the content holds every entry committed
+	// up to the message index, so a read replica is hydrated once the
+	// peer does not hold the entries requested anymore.
+	FlagSnapshot
+
+	// Marks the point of the delivery order where the seed is
+	// loaded. The marker is not committed, each peer waiting for
+	// it pauses the delivery once the marker is delivered.
+	FlagSeed
+
+	// A no-op multicast by the peer heartbeat, processed by the
+	// protocol as any message and never committed.
+	FlagNoop
+
+	// On an initial message, each destination partition sends a
+	// receipt to the ReplyTo partition once the message is enqueued.
+	// On a reply, the reply is the receipt.
+	FlagReceipt
+
+	// On an initial message, the pending messages conflicting with
+	// it exchange their timestamps again, so the message does not
+	// wait behind them longer than needed.
+	FlagUrgent
+
+	// Marks the consistent cut of the partitions on the destination.
+	// The marker is not committed, each peer reads its state once the
+	// marker is delivered.
+	FlagCut
+
+	// On an external message, the timestamp is the greatest of the
+	// subtree of the sender, exchanged through the tree.
+	FlagSubtree
+
+	// On an external message, the timestamp is the final timestamp
+	// decided by the root of the exchange tree.
+	FlagFinal
+
+	// The message is sent again on the request of a peer that missed
+	// it. Every peer of the partition receives the copy, the peers
+	// that already received the sequence discard it.
+	FlagRetransmitted
+)
+
+// Verify if the given flag is set.
+func (h HeaderFlag) Has(flag HeaderFlag) bool {
+	return h&flag == flag
+}
+
+// Internal use only, to transport any specific
+// information between the peers.
+//
+// The header is encoded separately from the message payload,
+// so transports and middlewares can route and inspect the
+// message without decoding the whole body.
+type ProtocolHeader struct {
+	// Transport the configured version at which the protocol
+	// will work, any increments in version must be described
+	// and what have changed.
+	ProtocolVersion uint
+
+	// Information about the kind of message that will be
+	// processed.
+	Type MessageType
+
+	// Address of a client waiting for the response. When
+	// this is set, the peers will send a reply back after
+	// the message is handled.
+	ReplyTo Partition
+
+	// If the message is a reply, this transports the failure
+	// back to the client.
+	Failure string
+
+	// Name of the peer that sent the message.
+	Origin string
+
+	// Sequence number of the message for the pair origin peer
+	// and destination partition. Used to detect gaps, a zero
+	// value means the message is not sequenced.
+	Sequence uint64
+
+	// When requesting a retransmission, this is the name of
+	// the peer that must send the message again. When subscribing
+	// to the delivered log, the name of the peer shipping the log,
+	// empty when any peer can ship.
+	Target string
+
+	// Position of a shipped entry on the delivered log of the peer.
+	// When subscribing, the position of the first entry requested.
+	Index uint64
+
+	// Partitions that will receive the message, a copy of
+	// the message destination available for routing.
+	Destination []Partition
+
+	// Bits enabling optional features for the message.
+	Flags HeaderFlag
+
+	// How the message is ordered relative to the others.
+	Consistency ConsistencyLevel
+
+	// Codecs the sender is able to decode, zero when the sender
+	// does not negotiate and only uses the configured codec.
+	Codecs CodecID
+
+	// Trace context propagated along with the message, for
+	// example, the W3C traceparent and tracestate entries.
+	Trace map[string]string
+
+	// Epoch of the partition of the peer that sent the message.
+	// Messages from an epoch older than the greatest seen from
+	// the same origin peer are rejected.
+	Epoch uint64
+
+	// When the peer sent the timestamp exchange, in nanoseconds
+	// on the clock of the peer. Zero on the other messages.
+	Sent int64
+
+	// The exchange of the same message received from the
+	// destination partition before sending this one, if any.
+	Echo Echo
+
+	// Version of the dictionary compressing the content, zero
+	// when the content is not compressed.
+	Dictionary uint32
+
+	// Where the content is on the snapshot shipped in chunks. When
+	// subscribing, how much of the snapshot the replica received,
+	// so the peer resumes the transfer.
+	Chunk Chunk
+
+	// Length of the encoded payload following the header.
+	ContentLength uint32
+}
+
+// Echo of a timestamp exchange received from a peer, sent back
+// on the exchange of the same message. Only the peer named on
+// the echo samples the round trip, since the send time is on its
+// clock, discounting the time the echo was held.
+type Echo struct {
+	// Name of the peer that sent the echoed exchange.
+	Peer string
+
+	// When the peer sent the echoed exchange.
+	Sent int64
+
+	// Nanoseconds between receiving the echoed exchange and
+	// sending the echo.
+	Held int64
+}
+
+// A piece of a snapshot shipped to a read replica. The snapshot is
+// sent in chunks, so a transfer interrupted is resumed from the last
+// chunk received instead of from the start.
+type Chunk struct {
+	// Position on the delivered log of the snapshot.
+	Position uint64
+
+	// Where the chunk starts on the snapshot. When subscribing, how
+	// many bytes of the snapshot were received.
+	Offset uint64
+
+	// Size of the whole snapshot, zero when not chunked.
+	Total uint64
+
+	// The CRC-32 (IEEE) of the chunk content.
+	Checksum uint32
+}
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	// Returned when the state machine does not keep the
+	// history of the committed entries.
+	ErrHistoryUnsupported = errors.New("state machine does not keep the history")
+)
+
+// Filters the committed entries when reading the history.
+// The zero value matches every entry.
+type HistoryFilter struct {
+	// Only entries with this key. Empty means any key.
+	Key []byte
+
+	// Only entries with final timestamp greater or equal.
+	From uint64
+
+	// Only entries with final timestamp less or equal.
+	// Zero means no upper bound.
+	To uint64
+
+	// Only the last entries matching the filter.
+	// Zero means all entries.
+	Last int
+}
+
+// Verify if the entry matches the key and timestamp range.
+func (h HistoryFilter) Match(entry Entry) bool {
+	if len(h.Key) > 0 && !bytes.Equal(h.Key, entry.Key) {
+		return false
+	}
+
+	if entry.FinalTimestamp < h.From {
+		return false
+	}
+	return h.To == 0 || entry.FinalTimestamp <= h.To
+}
+
+// Return the entries matching the filter, the entries must be
+// in the order they were committed.
+func (h HistoryFilter) Apply(entries []Entry) []Entry {
+	var matches []Entry
+	for _, entry := range entries {
+		if h.Match(entry) {
+			matches = append(matches, entry)
+		}
+	}
+
+	if h.Last > 0 && len(matches) > h.Last {
+		matches = matches[len(matches)-h.Last:]
+	}
+	return matches
+}
+
+// A state machine that keeps the history of the committed entries.
+type HistoryReader interface {
+	// Return the committed entries matching the filter, in
+	// the order they were committed.
+	History(filter HistoryFilter) ([]Entry, error)
+}
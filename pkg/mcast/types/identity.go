@@ -0,0 +1,97 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The longest partition name accepted, the limit of the
+// exchange names on the broker.
+const MaxPartitionLength = 255
+
+var (
+	// Returned when a partition name is empty, too long or holds
+	// characters not accepted as a transport address.
+	ErrInvalidPartition = errors.New("invalid partition name")
+
+	// Returned when a peer name does not follow <partition>-<ordinal>.
+	ErrInvalidPeerID = errors.New("invalid peer name")
+)
+
+// Parse and validate the partition name.
+func ParsePartition(name string) (Partition, error) {
+	partition := Partition(name)
+	if err := partition.Validate(); err != nil {
+		return "", err
+	}
+	return partition, nil
+}
+
+// Verify the partition name is not empty, fits the limit and holds
+// only letters, digits and the characters '.', '_', ':' and '-'.
+// The name is used as the transport address when not resolved, so
+// an invalid name would route the messages nowhere.
+func (p Partition) Validate() error {
+	if len(p) == 0 {
+		return fmt.Errorf("%w: empty", ErrInvalidPartition)
+	}
+	if len(p) > MaxPartitionLength {
+		return fmt.Errorf("%w: longer than %d", ErrInvalidPartition, MaxPartitionLength)
+	}
+	for _, c := range p {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '_', c == ':', c == '-':
+		default:
+			return fmt.Errorf("%w: %q has %q", ErrInvalidPartition, string(p), c)
+		}
+	}
+	return nil
+}
+
+// Identifies a peer by its partition and ordinal. The name of
+// the peer is deterministic, so it is stable across restarts.
+type PeerID struct {
+	// The partition of the peer.
+	Partition Partition
+
+	// The index of the peer on the partition.
+	Ordinal int
+}
+
+// The peer name, <partition>-<ordinal>.
+func (p PeerID) String() string {
+	return fmt.Sprintf("%s-%d", p.Partition, p.Ordinal)
+}
+
+// Parse the peer name, the ordinal follows the last '-' and
+// is written without sign nor leading zeros.
+func ParsePeerID(name string) (PeerID, error) {
+	index := strings.LastIndex(name, "-")
+	if index <= 0 {
+		return PeerID{}, fmt.Errorf("%w: %q", ErrInvalidPeerID, name)
+	}
+
+	ordinal, err := strconv.Atoi(name[index+1:])
+	if err != nil || ordinal < 0 || strconv.Itoa(ordinal) != name[index+1:] {
+		return PeerID{}, fmt.Errorf("%w: %q", ErrInvalidPeerID, name)
+	}
+
+	partition, err := ParsePartition(name[:index])
+	if err != nil {
+		return PeerID{}, err
+	}
+	return PeerID{Partition: partition, Ordinal: ordinal}, nil
+}
+
+// The names of the replicas of the partition, starting
+// from the given ordinal.
+func ReplicaNames(partition Partition, ordinal, replication int) []string {
+	names := make([]string, replication)
+	for i := range names {
+		names[i] = PeerID{Partition: partition, Ordinal: ordinal + i}.String()
+	}
+	return names
+}
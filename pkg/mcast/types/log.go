@@ -0,0 +1,37 @@
+package types
+
+import "errors"
+
+var (
+	// Returned when reading an index not on the log.
+	ErrLogIndex = errors.New("index not on the log")
+)
+
+// A durable append only log of records, addressed by a sequential
+// index starting at 1. The records are opaque to the log, e.g., the
+// encoded entries of the state machine.
+type Log interface {
+	// Append the records in order, returning the index of the first.
+	Append(records ...[]byte) (uint64, error)
+
+	// Read the record at the index.
+	Get(index uint64) ([]byte, error)
+
+	// The index of the first record still on the log, zero
+	// when the log is empty.
+	FirstIndex() uint64
+
+	// The index of the last record appended, zero when the
+	// log is empty.
+	LastIndex() uint64
+
+	// Discard the records before the index. The log may keep
+	// some of them, the records at or after the index are kept.
+	Compact(index uint64) error
+
+	// Flush the appended records.
+	Sync() error
+
+	// Flush and release the log.
+	Close() error
+}
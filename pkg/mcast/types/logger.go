@@ -1,5 +1,11 @@
 package types
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // This interface will be created by the client, so its
 // own logger can be provided. If none is provided the default
 // logger will use the the golang logger.
@@ -26,6 +32,121 @@ type Logger interface {
 	Panic(v ...interface{})
 	Panicf(format string, v ...interface{})
 
-	// Toggle debug on/off
+	// Toggle debug on/off. For a LevelLogger this changes
+	// the root level between debug and info.
 	ToggleDebug(value bool) bool
 }
+
+// The severity of a log entry, entries below the level
+// configured for the subsystem are discarded.
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// Names of the subsystems logging separately. Subsystems are
+// hierarchical, separated by dots, and a subsystem without its
+// own level uses the level of its parent, e.g., the level of
+// transport.sequence defaults to the transport level.
+const (
+	SubsystemPeer      = "peer"
+	SubsystemDeliver   = "deliver"
+	SubsystemDedup     = "dedup"
+	SubsystemShipping  = "shipping"
+	SubsystemTransport = "transport"
+	SubsystemSequence  = "transport.sequence"
+	SubsystemOutbox    = "transport.outbox"
+	SubsystemEpoch     = "transport.epoch"
+	SubsystemBreaker   = "transport.breaker"
+	SubsystemClient    = "client"
+	SubsystemReplica   = "replica"
+)
+
+var levelNames = map[LogLevel]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// Returned when the log level or the level specification is not valid.
+var ErrInvalidLogLevel = errors.New("invalid log level")
+
+func (l LogLevel) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Parse the level from its name.
+func ParseLogLevel(name string) (LogLevel, error) {
+	for level, n := range levelNames {
+		if strings.EqualFold(n, strings.TrimSpace(name)) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidLogLevel, name)
+}
+
+// Parse a level specification as transport=debug,queue=warn. An
+// entry without a subsystem, e.g., only info, is the root level.
+func ParseLogLevels(spec string) (map[string]LogLevel, error) {
+	levels := make(map[string]LogLevel)
+	for _, entry := range strings.Split(spec, ",") {
+		if len(strings.TrimSpace(entry)) == 0 {
+			continue
+		}
+
+		subsystem, name := "", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			subsystem, name = strings.TrimSpace(entry[:i]), entry[i+1:]
+		}
+		level, err := ParseLogLevel(name)
+		if err != nil {
+			return nil, err
+		}
+		levels[subsystem] = level
+	}
+	return levels, nil
+}
+
+// A logger with a level for each subsystem, adjustable at runtime.
+type LevelLogger interface {
+	Logger
+
+	// A logger for the subsystem, sharing the levels.
+	Subsystem(name string) Logger
+
+	// Change the level of the subsystem, an empty subsystem is the
+	// root level, used by the subsystems without their own level.
+	SetLevel(subsystem string, level LogLevel)
+
+	// Remove the level of the subsystem, so it uses its parent level.
+	ResetLevel(subsystem string)
+
+	// The levels configured for each subsystem.
+	Levels() map[string]LogLevel
+}
+
+// The logger for the subsystem, if the logger supports
+// subsystems, otherwise the logger itself.
+func SubsystemLogger(log Logger, subsystem string) Logger {
+	if leveled, ok := log.(LevelLogger); ok {
+		return leveled.Subsystem(subsystem)
+	}
+	return log
+}
+
+// Apply the levels, if the logger supports subsystems.
+func ApplyLogLevels(log Logger, levels map[string]LogLevel) {
+	if leveled, ok := log.(LevelLogger); ok {
+		for subsystem, level := range levels {
+			leveled.SetLevel(subsystem, level)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package types
+
+// The partitions known on the cluster.
+type Members interface {
+	// Verify if the partition is known.
+	Known(partition Partition) bool
+}
+
+// The partitions known beforehand.
+type StaticMembers []Partition
+
+// Implements the Members interface.
+func (s StaticMembers) Known(partition Partition) bool {
+	for _, p := range s {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
+
+// When the destination of the messages is verified.
+type MembershipCheck int
+
+const (
+	// The destination is verified when the request is sent,
+	// failing the request on the sending peer.
+	CheckOnSend MembershipCheck = iota
+
+	// The destination is verified when the message is received,
+	// each destination partition drops the message and replies
+	// with the failure. Covers the messages sent by clients and
+	// by peers not verifying the destination.
+	CheckOnReceive
+)
+
+// Verifies the destination of the messages against the members,
+// so a message never waits for the timestamp of a partition that
+// does not exist.
+type Membership struct {
+	// The partitions known, nil when the destination is not verified.
+	Members Members
+
+	// When the destination is verified.
+	Check MembershipCheck
+}
+
+// Verify if the partition is known, every partition is known
+// when the members are not set.
+func (m Membership) Known(partition Partition) bool {
+	return m.Members == nil || m.Members.Known(partition)
+}
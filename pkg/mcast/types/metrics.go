@@ -0,0 +1,105 @@
+package types
+
+// The names of the measurements reported by the peers. Every
+// measurement is labeled with the peer partition and name.
+const (
+	// Messages that received a timestamp proposal.
+	MetricProposed = "mcast_proposed_total"
+
+	// Proposals that conflicted and increased the clock.
+	MetricConflicts = "mcast_conflicts_total"
+
+	// Messages committed on the state machine.
+	MetricDelivered = "mcast_delivered_total"
+
+	// Messages delivered without waiting for the ones before them.
+	MetricGenericDelivered = "mcast_generic_delivered_total"
+
+	// Pending messages boosted by a conflicting urgent message.
+	MetricBoosted = "mcast_boosted_total"
+
+	// Entries reclaimed for the finished messages.
+	MetricReclaimed = "mcast_reclaimed_total"
+
+	// Timestamps received again from the same peer.
+	MetricDuplicates = "mcast_duplicate_timestamps_total"
+
+	// The destination set size of the proposed messages.
+	MetricDestinations = "mcast_destinations"
+
+	// How many messages are committed at once.
+	MetricBatchSize = "mcast_batch_size"
+
+	// The logical clock of the peer after each proposal.
+	MetricClock = "mcast_clock"
+)
+
+// The labels of the measurements.
+const (
+	LabelPartition = "partition"
+	LabelPeer      = "peer"
+)
+
+// A value that only increases.
+type Counter interface {
+	// Increase the counter by the non negative delta.
+	Add(delta float64)
+}
+
+// A value that goes up and down.
+type Gauge interface {
+	// Replace the current value.
+	Set(value float64)
+}
+
+// The distribution of the observed values.
+type Histogram interface {
+	// Record the value on the distribution.
+	Observe(value float64)
+}
+
+// Creates the instruments the peers report to, so the protocol is
+// instrumented on any metrics stack. The instruments are created
+// once when the peer starts, and used concurrently afterwards.
+//
+// An implementation for Prometheus registers a vector for each name,
+// with the label names, and returns the child with the label values.
+// For OpenTelemetry, the instruments are created from a meter and
+// record the labels as attributes.
+type Metrics interface {
+	// The counter with the name and labels.
+	Counter(name string, labels map[string]string) Counter
+
+	// The gauge with the name and labels.
+	Gauge(name string, labels map[string]string) Gauge
+
+	// The histogram with the name and labels.
+	Histogram(name string, labels map[string]string) Histogram
+}
+
+// Discards every measurement, used when no metrics are configured.
+type NoopMetrics struct{}
+
+// An instrument discarding the measurements.
+type noopInstrument struct{}
+
+// Implements the Metrics interface.
+func (NoopMetrics) Counter(string, map[string]string) Counter {
+	return noopInstrument{}
+}
+
+// Implements the Metrics interface.
+func (NoopMetrics) Gauge(string, map[string]string) Gauge {
+	return noopInstrument{}
+}
+
+// Implements the Metrics interface.
+func (NoopMetrics) Histogram(string, map[string]string) Histogram {
+	return noopInstrument{}
+}
+
+func (noopInstrument) Add(float64) {}
+
+func (noopInstrument) Set(float64) {}
+
+func (noopInstrument) Observe(float64) {}
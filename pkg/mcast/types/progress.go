@@ -0,0 +1,42 @@
+package types
+
+import "time"
+
+// The stages a request goes through until it is delivered
+// on every destination partition.
+type ProgressStage string
+
+const (
+	// The peer proposed the timestamp of its partition.
+	ProgressProposed ProgressStage = "proposed"
+
+	// The partitions exchanged the timestamps and the final
+	// timestamp was selected. Requests with a single
+	// destination do not exchange timestamps.
+	ProgressExchanged ProgressStage = "exchanged"
+
+	// The request was committed on the peer state machine.
+	ProgressDelivered ProgressStage = "delivered"
+
+	// Every destination partition committed the request.
+	ProgressDeliveredEverywhere ProgressStage = "delivered_everywhere"
+)
+
+// The progress of a request, sent to the progress channel of
+// the request as it moves through the protocol.
+type Progress struct {
+	// The request UID.
+	Identifier UID
+
+	// What happened with the request.
+	Stage ProgressStage
+
+	// The partition where it happened.
+	Partition Partition
+
+	// The request timestamp on the stage.
+	Timestamp uint64
+
+	// When it happened.
+	Time time.Time
+}
@@ -0,0 +1,69 @@
+package types
+
+import "time"
+
+// The configuration for a read replica, a read-only node that applies
+// the log delivered by a partition without participating on the
+// protocol, scaling the reads beyond the partition replication.
+type ReplicaConfiguration struct {
+	// The replica name. This will also be used as the replica
+	// address on the transport, so must be unique.
+	Name Partition
+
+	// The partition shipping the delivered log.
+	Partition Partition
+
+	// Which version of the protocol will be used.
+	Version uint
+
+	// Where the shipped entries are applied.
+	Storage Storage
+
+	// How often the replica subscribes again to the partition, asking
+	// for the entries missing. The peer shipping is replaced when it
+	// does not answer for three consecutive subscriptions.
+	Refresh time.Duration
+
+	// Logger to be used by the replica.
+	Logger Logger
+
+	// The level of each subsystem, applied if the logger
+	// implements the LevelLogger interface.
+	LogLevels map[string]LogLevel
+
+	// Codec used to serialize the messages on the transport.
+	Codec Codec
+
+	// Codecs negotiated with the partition, cheapest first.
+	Codecs []IdentifiedCodec
+
+	// Key shared by the cluster signing the messages.
+	SigningKey []byte
+
+	// Resolve the transport address of the partition.
+	Resolver Resolver
+
+	// The broker connecting to the partition. RabbitMQ when nil.
+	Broker Broker
+
+	// How the transport handles the messages not consumed in time.
+	Consumer SlowConsumer
+}
+
+// How far a read replica is from the peer shipping the log.
+type ReplicaMetrics struct {
+	// The peer shipping the log, empty while no peer answered.
+	Source string
+
+	// Position of the last entry applied.
+	Applied uint64
+
+	// The greatest position known on the peer log.
+	Head uint64
+
+	// How many entries the replica is behind the peer.
+	Lag uint64
+
+	// How long since the replica was last caught up with the peer.
+	Behind time.Duration
+}
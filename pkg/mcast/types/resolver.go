@@ -0,0 +1,12 @@
+package types
+
+// The address used by the transport to reach a partition.
+type Address string
+
+// Maps the logical partition names to the addresses used by
+// the transport. Using a resolver, the topology can change
+// without changing the partition names used by the clients.
+type Resolver interface {
+	// Resolve the transport address for the given partition.
+	Resolve(partition Partition) (Address, error)
+}
@@ -0,0 +1,16 @@
+package types
+
+// How a peer retries sending the timestamp to partitions that
+// did not answer while exchanging the timestamps.
+type RetryPolicy struct {
+	// How many times the timestamp is sent again before the
+	// request fails with a timeout. Zero means it never fails,
+	// which is the default. The message is still delivered if
+	// the exchange completes after the request failed, so this
+	// should only be set when the caller handles that.
+	Attempts int
+
+	// Multiplies the time waiting for the answer after each
+	// attempt. Values lower than 1 keep the same time.
+	Backoff float64
+}
@@ -0,0 +1,23 @@
+package types
+
+// What a send interceptor decided about an outgoing message.
+type SendDecision struct {
+	// The partitions that receive the message instead of its
+	// destination. Nil keeps the destination.
+	Destination []Partition
+
+	// Why the message must not be sent. The request fails
+	// with the veto.
+	Veto error
+
+	// Why the decision was taken, recorded on the events of
+	// the message when the destination changes or on a veto.
+	Reason string
+}
+
+// Inspects a request before the peer sends it to its destination
+// partitions, to enforce rules about which partitions may receive
+// the message, e.g., geo-fencing some keys. The interceptors run
+// once for each request, on the peer issuing it, so every partition
+// agrees on the rewritten destination.
+type SendInterceptor func(message Message) SendDecision
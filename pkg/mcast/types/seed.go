@@ -0,0 +1,54 @@
+package types
+
+import "time"
+
+// How many seed entries are loaded at once.
+const DefaultSeedBatch = 1024
+
+// How long to wait for every peer to deliver the seed marker.
+const DefaultSeedTimeout = 30 * time.Second
+
+// A single key and value of the initial dataset.
+type SeedEntry struct {
+	// The key the value is associated with.
+	Key []byte
+
+	// The value loaded.
+	Value []byte
+
+	// Any extra information loaded along with the value.
+	Extra []byte
+}
+
+// Iterates over the initial dataset loaded into the storage.
+type SeedIterator interface {
+	// The next entry of the dataset. When the dataset is
+	// exhausted false is returned.
+	Next() (SeedEntry, bool, error)
+}
+
+// Iterates over the entries of the slice.
+type SeedSlice []SeedEntry
+
+// Implements the SeedIterator interface.
+func (s *SeedSlice) Next() (SeedEntry, bool, error) {
+	if len(*s) == 0 {
+		return SeedEntry{}, false, nil
+	}
+	entry := (*s)[0]
+	*s = (*s)[1:]
+	return entry, true, nil
+}
+
+// The entry committed on the state machine for the seed entry. The
+// identifier is derived from the key, so loading the same dataset
+// again, or through peers sharing a storage, is idempotent.
+func (s SeedEntry) Entry() *Entry {
+	return &Entry{
+		Operation:  Command,
+		Identifier: UID("seed/" + string(s.Key)),
+		Key:        s.Key,
+		Data:       s.Value,
+		Extensions: s.Extra,
+	}
+}
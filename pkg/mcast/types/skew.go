@@ -0,0 +1,84 @@
+package types
+
+// Upper bound of each bucket of the clock jump histogram. The
+// jumps greater than the last bound are counted on an extra bucket.
+var SkewBuckets = []uint64{0, 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024}
+
+// How far the local clock jumps when a message receives its final
+// timestamp, for messages to the same destination set. The jump is the
+// distance between the timestamp proposed by the partition and the final
+// timestamp. Since the final timestamp is the greatest proposed by the
+// destinations, a partition that constantly lags behind the others jumps
+// more often and further.
+type SkewMetrics struct {
+	// Messages that received the final timestamp.
+	Exchanges uint64
+
+	// Exchanges where the clock leaped forward.
+	Leaps uint64
+
+	// The sum of all jumps.
+	Total uint64
+
+	// The greatest jump.
+	Max uint64
+
+	// How many jumps fell on each bucket of SkewBuckets.
+	Buckets []uint64
+}
+
+// Observe a clock jump.
+func (s *SkewMetrics) Observe(jump uint64) {
+	if s.Buckets == nil {
+		s.Buckets = make([]uint64, len(SkewBuckets)+1)
+	}
+
+	s.Exchanges++
+	s.Total += jump
+	if jump > 0 {
+		s.Leaps++
+	}
+	if jump > s.Max {
+		s.Max = jump
+	}
+
+	bucket := len(SkewBuckets)
+	for i, bound := range SkewBuckets {
+		if jump <= bound {
+			bucket = i
+			break
+		}
+	}
+	s.Buckets[bucket]++
+}
+
+// Combine the metrics with the other.
+func (s SkewMetrics) Merge(other SkewMetrics) SkewMetrics {
+	merged := SkewMetrics{
+		Exchanges: s.Exchanges + other.Exchanges,
+		Leaps:     s.Leaps + other.Leaps,
+		Total:     s.Total + other.Total,
+		Max:       s.Max,
+		Buckets:   make([]uint64, len(SkewBuckets)+1),
+	}
+	if other.Max > merged.Max {
+		merged.Max = other.Max
+	}
+	for i := range merged.Buckets {
+		if i < len(s.Buckets) {
+			merged.Buckets[i] += s.Buckets[i]
+		}
+		if i < len(other.Buckets) {
+			merged.Buckets[i] += other.Buckets[i]
+		}
+	}
+	return merged
+}
+
+// The mean jump.
+func (s SkewMetrics) Mean() float64 {
+	if s.Exchanges == 0 {
+		return 0
+	}
+	return float64(s.Total) / float64(s.Exchanges)
+}
@@ -13,12 +13,26 @@ var (
 // to use the replicated value across replicas.
 type StateMachine interface {
 	// Commit the given entry into the state machine, turning it available for all clients.
+	// The commit must be atomic: the entry is either applied completely or not at all,
+	// also if the process crashes in the middle of the commit. When an error is
+	// returned nothing of the entry can be applied.
 	Commit(*Entry) (interface{}, error)
 
 	// Restores the state machine back to a given a state.
+	// Called before any commit, this must complete any commit
+	// interrupted by a crash.
 	Restore() error
 }
 
+// Implemented by the state machines able to commit many entries
+// at once, amortizing the cost of each commit on persistent backends.
+type BatchStateMachine interface {
+	// Commit all the entries in order, returning the responses in the
+	// same order. The batch must be atomic: when an error is returned
+	// none of the entries can be applied.
+	CommitBatch([]*Entry) ([]interface{}, error)
+}
+
 // A in memory default value to be used.
 type InMemoryStateMachine struct {
 	// State machine stable storage for committing
@@ -26,6 +40,8 @@ type InMemoryStateMachine struct {
 }
 
 // Commit the operation into the stable storage.
+// A command is applied with a single Set, so the commit is atomic
+// as long as the storage Set is atomic.
 // Some operations will change values into the state machine
 // while some other operations is just querying the state
 // machine for values.
@@ -41,17 +57,17 @@ func (i *InMemoryStateMachine) Commit(entry *Entry) (interface{}, error) {
 			return nil, err
 		}
 		return entry, nil
-	// Read an entry.
+	// Read an entry, only the entry committed for the key is returned.
 	case Query:
 		data, err := i.store.Get(entry.Key)
 		if err != nil {
 			return nil, err
 		}
-		var message Message
-		if err := json.Unmarshal(data, &message); err != nil {
+		var committed Entry
+		if err := json.Unmarshal(data, &committed); err != nil {
 			return nil, err
 		}
-		return message, nil
+		return &committed, nil
 	default:
 		return nil, ErrCommandUnknown
 	}
@@ -0,0 +1,78 @@
+package types
+
+// Counters of the protocol on a partition, meant to be embedded
+// on dashboards. The counters only grow while the peer is running.
+type PartitionStats struct {
+	// Messages that received a timestamp proposal from the partition.
+	Proposed uint64
+
+	// Messages committed on the state machine.
+	Delivered uint64
+
+	// Delivered messages that did not conflict with any pending
+	// message, so they were delivered as soon as they received the
+	// final timestamp, without waiting for the messages before them.
+	GenericDelivered uint64
+
+	// Proposals that conflicted with a previous message and
+	// increased the clock.
+	Conflicts uint64
+
+	// How many times the clock increased.
+	ClockTicks uint64
+
+	// The sum of the destination set size of the proposed messages.
+	Destinations uint64
+
+	// Pending messages whose timestamps were sent again since they
+	// conflicted with an urgent message.
+	Boosted uint64
+
+	// Entries kept for finished messages reclaimed by the collection.
+	Reclaimed uint64
+
+	// Timestamps received again from the peer that already sent its
+	// timestamp for the message, as the retransmitted timestamps. Only
+	// the greatest timestamp of each partition is kept.
+	Duplicates uint64
+
+	// The features enabled while counting, to compare the
+	// counters of partitions with different features.
+	Features []Feature
+}
+
+// The average number of partitions on the destination of the
+// proposed messages.
+func (s PartitionStats) AverageDestinations() float64 {
+	if s.Proposed == 0 {
+		return 0
+	}
+	return float64(s.Destinations) / float64(s.Proposed)
+}
+
+// Since every peer of the partition handles the same messages, the
+// stats of the partition are the greatest counters of its peers.
+func (s PartitionStats) Greatest(other PartitionStats) PartitionStats {
+	greatest := func(a, b uint64) uint64 {
+		if a > b {
+			return a
+		}
+		return b
+	}
+	features := s.Features
+	if features == nil {
+		features = other.Features
+	}
+	return PartitionStats{
+		Proposed:         greatest(s.Proposed, other.Proposed),
+		Delivered:        greatest(s.Delivered, other.Delivered),
+		GenericDelivered: greatest(s.GenericDelivered, other.GenericDelivered),
+		Conflicts:        greatest(s.Conflicts, other.Conflicts),
+		ClockTicks:       greatest(s.ClockTicks, other.ClockTicks),
+		Destinations:     greatest(s.Destinations, other.Destinations),
+		Boosted:          greatest(s.Boosted, other.Boosted),
+		Reclaimed:        greatest(s.Reclaimed, other.Reclaimed),
+		Duplicates:       greatest(s.Duplicates, other.Duplicates),
+		Features:         features,
+	}
+}
@@ -8,3 +8,11 @@ type Storage interface {
 	// Get the serialized value associated with the key.
 	Get(key []byte) ([]byte, error)
 }
+
+// Implemented by the storages that can lose the values set, as
+// caches expiring or evicting the older entries. Such storage
+// can not hold the protocol data, as the outbox.
+type EvictingStorage interface {
+	// If the values set can be removed by the storage.
+	Evicts() bool
+}
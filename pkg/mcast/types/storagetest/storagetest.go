@@ -0,0 +1,227 @@
+// Package storagetest provides a conformance suite that
+// every Storage implementation must pass.
+//
+// The suite verifies the behavior the state machine expects
+// from the storage, so a custom backend can be plugged into
+// the protocol safely. To use it, provide a factory that
+// creates an empty storage:
+//
+//	func TestMyStorage(t *testing.T) {
+//		storagetest.Run(t, func() (types.Storage, error) {
+//			return NewMyStorage()
+//		})
+//	}
+package storagetest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+)
+
+// Size of the value used when verifying large values.
+var LargeValueSize = 8 << 20
+
+// How many goroutines are used when verifying concurrent access.
+var Concurrency = 16
+
+// Creates a new empty storage.
+type Factory func() (types.Storage, error)
+
+// Executes the whole conformance suite using the given factory.
+func Run(t *testing.T, factory Factory) {
+	t.Run("SetThenGet", func(t *testing.T) {
+		TestSetThenGet(t, factory)
+	})
+	t.Run("OverwriteValue", func(t *testing.T) {
+		TestOverwriteValue(t, factory)
+	})
+	t.Run("BinaryKeysAndValues", func(t *testing.T) {
+		TestBinaryKeysAndValues(t, factory)
+	})
+	t.Run("LargeValue", func(t *testing.T) {
+		TestLargeValue(t, factory)
+	})
+	t.Run("MissingKey", func(t *testing.T) {
+		TestMissingKey(t, factory)
+	})
+	t.Run("ConcurrentAccess", func(t *testing.T) {
+		TestConcurrentAccess(t, factory)
+	})
+}
+
+// A value set must be returned when reading the same key.
+func TestSetThenGet(t *testing.T, factory Factory) {
+	storage := create(t, factory)
+	set(t, storage, []byte("key"), []byte("value"))
+	get(t, storage, []byte("key"), []byte("value"))
+}
+
+// Setting an existing key replaces the previous value.
+func TestOverwriteValue(t *testing.T, factory Factory) {
+	storage := create(t, factory)
+	set(t, storage, []byte("key"), []byte("first"))
+	set(t, storage, []byte("key"), []byte("second"))
+	get(t, storage, []byte("key"), []byte("second"))
+}
+
+// Keys and values are arbitrary bytes, not only text, and
+// keys that are prefixes of each other are different keys.
+func TestBinaryKeysAndValues(t *testing.T, factory Factory) {
+	storage := create(t, factory)
+	key := []byte{0x00, 0xff, 0x10, 0x00}
+	value := []byte{0x00, 0x00, 0xfe, 0x01}
+	set(t, storage, key, value)
+	set(t, storage, key[:2], []byte("prefix"))
+	get(t, storage, key, value)
+	get(t, storage, key[:2], []byte("prefix"))
+}
+
+// Values larger than a few megabytes are stored without
+// being truncated.
+func TestLargeValue(t *testing.T, factory Factory) {
+	storage := create(t, factory)
+	value := make([]byte, LargeValueSize)
+	for i := range value {
+		value[i] = byte(i % 251)
+	}
+	set(t, storage, []byte("large"), value)
+	get(t, storage, []byte("large"), value)
+}
+
+// Reading a key never set must return an error, so the
+// failure is propagated to the client issuing the request.
+func TestMissingKey(t *testing.T, factory Factory) {
+	storage := create(t, factory)
+	set(t, storage, []byte("key"), []byte("value"))
+	if value, err := storage.Get([]byte("missing")); err == nil {
+		t.Errorf("expected error reading missing key, found %q", value)
+	}
+}
+
+// The storage is accessed by all peers concurrently, so
+// concurrent operations must not lose nor mix values.
+func TestConcurrentAccess(t *testing.T, factory Factory) {
+	storage := create(t, factory)
+	group := &sync.WaitGroup{}
+	failures := make(chan error, Concurrency)
+	for i := 0; i < Concurrency; i++ {
+		group.Add(1)
+		go func(i int) {
+			defer group.Done()
+			key := []byte(fmt.Sprintf("key-%d", i))
+			for j := 0; j < 100; j++ {
+				value := []byte(fmt.Sprintf("value-%d-%d", i, j))
+				if err := storage.Set(key, value); err != nil {
+					failures <- err
+					return
+				}
+				if err := storage.Set([]byte("shared"), value); err != nil {
+					failures <- err
+					return
+				}
+				found, err := storage.Get(key)
+				if err != nil {
+					failures <- err
+					return
+				}
+				if !bytes.Equal(found, value) {
+					failures <- fmt.Errorf("expected %q, found %q", value, found)
+					return
+				}
+			}
+		}(i)
+	}
+	group.Wait()
+	close(failures)
+
+	for err := range failures {
+		t.Error(err)
+	}
+
+	if _, err := storage.Get([]byte("shared")); err != nil {
+		t.Errorf("failed reading shared key. %v", err)
+	}
+}
+
+// Returned by the FailingStorage.
+var ErrInjected = errors.New("injected storage failure")
+
+// A storage that returns ErrInjected for every operation
+// while failing, used to verify that the storage errors
+// are propagated instead of silently ignored.
+type FailingStorage struct {
+	types.Storage
+
+	// Synchronize access to the flag.
+	mutex *sync.Mutex
+
+	// If operations must fail.
+	failing bool
+}
+
+// Wraps the storage, failing every operation while
+// the failure is enabled.
+func NewFailingStorage(storage types.Storage) *FailingStorage {
+	return &FailingStorage{
+		Storage: storage,
+		mutex:   &sync.Mutex{},
+	}
+}
+
+// Enable or disable the failures.
+func (f *FailingStorage) Fail(failing bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.failing = failing
+}
+
+// Implements the Storage interface.
+func (f *FailingStorage) Set(key []byte, value []byte) error {
+	if f.isFailing() {
+		return ErrInjected
+	}
+	return f.Storage.Set(key, value)
+}
+
+// Implements the Storage interface.
+func (f *FailingStorage) Get(key []byte) ([]byte, error) {
+	if f.isFailing() {
+		return nil, ErrInjected
+	}
+	return f.Storage.Get(key)
+}
+
+func (f *FailingStorage) isFailing() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.failing
+}
+
+func create(t *testing.T, factory Factory) types.Storage {
+	storage, err := factory()
+	if err != nil {
+		t.Fatalf("failed creating storage. %v", err)
+	}
+	return storage
+}
+
+func set(t *testing.T, storage types.Storage, key, value []byte) {
+	if err := storage.Set(key, value); err != nil {
+		t.Fatalf("failed setting %q. %v", key, err)
+	}
+}
+
+func get(t *testing.T, storage types.Storage, key, expected []byte) {
+	value, err := storage.Get(key)
+	if err != nil {
+		t.Fatalf("failed reading %q. %v", key, err)
+	}
+
+	if !bytes.Equal(value, expected) {
+		t.Errorf("key %q expected %d bytes, found %d bytes", key, len(expected), len(value))
+	}
+}
@@ -0,0 +1,51 @@
+package types
+
+// A zone inside a datacenter where a partition is deployed.
+type Zone string
+
+// Labels describing where a partition is deployed.
+// On WAN deployments the protocol can use this information
+// to prefer closer partitions and to parallelize the
+// communication with the distant ones.
+type Location struct {
+	// The datacenter the partition belongs to.
+	Datacenter string
+
+	// The zone inside the datacenter.
+	Zone Zone
+}
+
+// Verify if both locations are at the same datacenter.
+func (l Location) SameDatacenter(o Location) bool {
+	return l.Datacenter == o.Datacenter
+}
+
+// Verify if both locations are at the same zone.
+func (l Location) SameZone(o Location) bool {
+	return l.SameDatacenter(o) && l.Zone == o.Zone
+}
+
+// Describes the location of the partitions.
+type Topology interface {
+	// Return where the given partition is deployed. If the
+	// partition is unknown the zero Location is returned.
+	Locate(partition Partition) Location
+}
+
+// A topology where the partition locations are known
+// beforehand and do not change.
+type StaticTopology map[Partition]Location
+
+// Implements the Topology interface.
+func (s StaticTopology) Locate(partition Partition) Location {
+	return s[partition]
+}
+
+// Counters about the messages exchanged with a single zone.
+type ZoneMetrics struct {
+	// Messages sent to partitions on the zone.
+	Sent uint64
+
+	// Messages received from partitions on the zone.
+	Received uint64
+}
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// A single protocol step on the trace. The fields are always
+// written in this order, one step per line, so the trace can be
+// read by the TLA+ trace validation on the spec folder.
+type TraceStep struct {
+	// The position of the step on the trace, starting at 1.
+	Sequence uint64 `json:"seq"`
+
+	// The peer and the partition where the step happened.
+	Peer  string    `json:"peer"`
+	Group Partition `json:"group"`
+
+	// What happened with the message.
+	Action EventKind `json:"action"`
+
+	// The message identifier.
+	Identifier UID `json:"uid"`
+
+	// The message type, state and timestamp after the step.
+	Type      MessageType  `json:"type"`
+	State     MessageState `json:"state"`
+	Timestamp uint64       `json:"ts"`
+
+	// The partition the message was received from or sent to.
+	Partition Partition `json:"partition"`
+}
+
+// Writes every protocol step of the peers in a canonical format,
+// so an execution can be checked against the specification of the
+// algorithm. Differently from the EventRecorder nothing is evicted,
+// the steps are written in the order they happened across every
+// peer sharing the tracer.
+//
+// A nil tracer ignores all steps.
+type StepTracer struct {
+	// Synchronize the writes, so the sequence follows the lines.
+	mutex *sync.Mutex
+
+	// Where the steps are written.
+	encoder *json.Encoder
+
+	// The last step written.
+	sequence uint64
+
+	// The first error writing the trace.
+	err error
+}
+
+// Creates a new tracer writing the steps on the writer.
+func NewStepTracer(writer io.Writer) *StepTracer {
+	return &StepTracer{
+		mutex:   &sync.Mutex{},
+		encoder: json.NewEncoder(writer),
+	}
+}
+
+// Write the step of the peer on the given partition. After
+// failing to write, the following steps are ignored.
+func (s *StepTracer) Trace(group Partition, uid UID, event Event) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil {
+		return
+	}
+	s.sequence++
+	s.err = s.encoder.Encode(TraceStep{
+		Sequence:   s.sequence,
+		Peer:       event.Peer,
+		Group:      group,
+		Action:     event.Kind,
+		Identifier: uid,
+		Type:       event.Type,
+		State:      event.State,
+		Timestamp:  event.Timestamp,
+		Partition:  event.Partition,
+	})
+}
+
+// The first error writing the trace, if any.
+func (s *StepTracer) Err() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
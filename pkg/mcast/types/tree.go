@@ -0,0 +1,69 @@
+package types
+
+import "sort"
+
+// How many children each partition has on the exchange tree
+// when not configured.
+const DefaultTreeFanout = 4
+
+// Exchanges the timestamps of the messages with many destinations
+// through a tree instead of all-to-all. The participating partitions
+// are sorted and placed on a tree, each partition sends the greatest
+// timestamp of its subtree to its parent, and the root sends the
+// final timestamp back down. The exchange costs a message per edge
+// instead of one per pair of partitions, taking a round trip for
+// each level of the tree.
+type TreeExchange struct {
+	// Messages with at least this many participating partitions
+	// exchange through the tree. Zero always exchanges all-to-all.
+	Threshold int
+
+	// Children of each partition on the tree. Zero uses
+	// DefaultTreeFanout.
+	Fanout int
+}
+
+// If a message with the participating partitions exchanges
+// through the tree.
+func (t TreeExchange) Applies(participants int) bool {
+	return t.Threshold > 0 && participants >= t.Threshold && participants > 1
+}
+
+// The position of the partition on the tree of the participating
+// partitions: its parent, empty on the root, its children and the
+// height of the tree. Every partition builds the same tree, since
+// the participants are sorted.
+func (t TreeExchange) Position(participants []Partition, partition Partition) (Partition, []Partition, int) {
+	fanout := t.Fanout
+	if fanout <= 0 {
+		fanout = DefaultTreeFanout
+	}
+
+	sorted := append([]Partition(nil), participants...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	height := 0
+	for size, level := 1, 1; size < len(sorted); size += level {
+		level *= fanout
+		height++
+	}
+
+	index := sort.Search(len(sorted), func(i int) bool {
+		return sorted[i] >= partition
+	})
+	if index == len(sorted) || sorted[index] != partition {
+		return "", nil, height
+	}
+
+	var parent Partition
+	if index > 0 {
+		parent = sorted[(index-1)/fanout]
+	}
+	var children []Partition
+	for i := fanout*index + 1; i <= fanout*index+fanout && i < len(sorted); i++ {
+		children = append(children, sorted[i])
+	}
+	return parent, children, height
+}
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// Wrapped by every validation error, can be used
+	// to verify if a request was rejected by a validator.
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// Describes why a request was rejected.
+type ValidationError struct {
+	// The request field rejected, e.g., Key or Value.
+	Field string
+
+	// Why the field was rejected.
+	Reason string
+}
+
+// Implements the error interface.
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s %s", ErrInvalidRequest, v.Field, v.Reason)
+}
+
+// So the error matches ErrInvalidRequest.
+func (v *ValidationError) Unwrap() error {
+	return ErrInvalidRequest
+}
+
+// Verifies a request before it enters the protocol, so invalid
+// requests fail right away instead of being replicated.
+type Validator interface {
+	// Return a ValidationError if the request is invalid.
+	Validate(request Request) error
+}
+
+// Allows using a function as a Validator.
+type ValidatorFunc func(request Request) error
+
+// Implements the Validator interface.
+func (f ValidatorFunc) Validate(request Request) error {
+	return f(request)
+}
+
+// Verify the write concern of the request is satisfiable by
+// its destination.
+func ValidateAcknowledgments(request Request) error {
+	if request.Acknowledgments < 0 || request.Acknowledgments > len(request.Destination) {
+		return &ValidationError{Field: "Acknowledgments", Reason: "must be between zero and the destination length"}
+	}
+	return nil
+}
+
+// Execute all validators in order, returning the first error.
+func ValidateRequest(request Request, validators []Validator) error {
+	for _, validator := range validators {
+		if err := validator.Validate(request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
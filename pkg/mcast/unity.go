@@ -1,10 +1,34 @@
 package mcast
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/jabolina/go-mcast/pkg/mcast/core"
 	"github.com/jabolina/go-mcast/pkg/mcast/helper"
 	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"strings"
+	"time"
+)
+
+var (
+	// Returned when the peers did not deliver the pending
+	// requests in time after decommissioning the partition.
+	ErrDecommissionTimeout = errors.New("timeout draining decommissioned partition")
+
+	// Returned when the peers did not deliver the seed marker in time.
+	ErrSeedTimeout = errors.New("timeout waiting the seed marker")
+
+	// Returned when the cut marker is not delivered in time.
+	ErrCutTimeout = errors.New("timeout waiting the cut marker")
+
+	// Returned when reading a key at a timestamp before any version
+	// kept by the state machine.
+	ErrNoVersion = errors.New("no version at the timestamp")
+
+	// Returned when writing while every peer of the unity is
+	// stopped or lost its transport.
+	ErrNoHealthyPeer = errors.New("no healthy peer")
 )
 
 // The unity interface, responsible for interacting
@@ -23,16 +47,139 @@ type Unity interface {
 	// To listen when the request is applied and if it was
 	// applied successfully a channel will be returned where
 	// a response will be sent back once the request is applied
-	// in one of the participants.
+	// in one of the participants. With a write concern, the
+	// response also waits for the receipts of the destinations.
 	Write(request types.Request) <-chan types.Response
 
-	// Query a value from the unity.
+	// Query a value from the unity. With a causality token, the
+	// read observes the write of the token following the read mode.
 	Read(request types.Request) (types.Response, error)
 
+	// Query the value of the key at the final timestamp, the last
+	// committed with a timestamp not greater. The final timestamps
+	// are taken from the causality token of the write responses, and
+	// zero reads the last value. Needs a state machine keeping the
+	// history, such as definition.VersionedStorage.
+	ReadAt(key []byte, timestamp uint64) (types.Response, error)
+
+	// Stop committing into the state machine on all peers,
+	// while the protocol messages are still being processed.
+	// This can be used to quiesce the unity for a backup.
+	PauseDelivery()
+
+	// Resume committing into the state machine on all peers.
+	ResumeDelivery()
+
+	// Load an initial dataset directly into the storage of all
+	// peers, without a protocol round for each key. A marker is
+	// multicast to the partition with total order, every peer pauses
+	// the delivery once the marker is delivered and the dataset is
+	// loaded, so every peer applies the whole dataset at the same
+	// point of the delivery order.
+	// Since only the local peers are seeded, the same dataset
+	// must be loaded on every unity of the partition.
+	Seed(iterator types.SeedIterator) error
+
+	// Establish a consistent cut across the partitions, the unity
+	// partition included. A marker is multicast with total order, so
+	// every message is delivered before the marker on all its
+	// destinations or after it on all of them. Every peer reading its
+	// state at the marker calls the OnCut listener, and the cut of
+	// this partition is returned.
+	Cut(partitions []types.Partition) (types.Cut, error)
+
+	// The state of the message on every peer where it is pending.
+	Inspect(uid types.UID) []types.MessageStatus
+
+	// The received queue of every peer, see replay.RestoreQueue
+	// to reconstruct a queue from the dump.
+	Dump() []types.QueueDump
+
+	// Discard the pending message on all peers, failing the request.
+	// Once expired the message is never delivered by the local
+	// peers, while other partitions may still deliver it, so this
+	// must only be used to unstick messages the operator verified
+	// are safe to discard. Returns false if no peer had the message.
+	Expire(uid types.UID) bool
+
+	// Send the message timestamp again to the partitions that did
+	// not answer, on all peers. Returns false if no peer was
+	// exchanging the timestamp.
+	Resend(uid types.UID) bool
+
+	// Counters of the messages exchanged with each zone,
+	// aggregated for all peers.
+	Zones() map[types.Zone]types.ZoneMetrics
+
+	// How far the clocks jumped when the messages received the
+	// final timestamp, for each destination set, aggregated for
+	// all peers. The destination set is the sorted partitions
+	// joined by comma.
+	ClockSkew() map[string]types.SkewMetrics
+
+	// The protocol counters of the partition, the greatest
+	// counters amongst the peers.
+	Stats() types.PartitionStats
+
+	// How many messages were detected as missing from the
+	// transport, aggregated for all peers.
+	Missed() uint64
+
+	// How the peers keep up with the received messages,
+	// aggregated for all peers.
+	Consumer() types.ConsumerMetrics
+
+	// How many times the timestamp exchange with other
+	// partitions timed out, aggregated for all peers.
+	GatherTimeouts() uint64
+
+	// The circuit breaker of each partition, aggregated for all
+	// peers with the worst state amongst them.
+	Breakers() map[types.Partition]types.BreakerMetrics
+
+	// How many responses were discarded because the mailbox
+	// of the request was full, aggregated for all peers.
+	EvictedResponses() uint64
+
+	// How many requests stopped waiting for the response, since
+	// the request context was done or the response did not arrive
+	// within the ObserverTTL, aggregated for all peers.
+	AbandonedObservers() uint64
+
+	// Verify if the unity is ready to receive requests. The
+	// unity is not ready while the delivery is paused or after
+	// the shutdown.
+	Ready() bool
+
+	// Failures that happened asynchronously on any peer, such
+	// as transport failures, commit failures and dropped messages.
+	// The failures are buffered and discarded when the buffer is
+	// full, so the channel must be consumed to receive them.
+	Errors() <-chan error
+
+	// Decommission the partition. The partition stops accepting
+	// new requests on all unities, delivers the requests already
+	// accepted and sends its final marker to the given partitions,
+	// so they stop waiting for its timestamps. Returns an error if
+	// the local peers do not finish in time.
+	//
+	// The keys must be moved to other partitions before, see the
+	// rebalance package. A request to multiple partitions racing
+	// the decommission may fail here and be delivered on the others.
+	Decommission(notify []types.Partition, timeout time.Duration) error
+
+	// Move the partition to the next epoch, this must be called on
+	// membership or recovery events. The messages still sent on the
+	// previous epoch, for example, by a peer isolated by a network
+	// partition, are rejected by the peers that know the new epoch.
+	AdvanceEpoch() error
+
 	// Shutdown the unity.
 	// This is NOT a graceful shutdown, everything that
-	// is going on will stop.
-	Shutdown()
+	// is going on will stop. The peers are stopped before
+	// returning, the future completes once every goroutine
+	// of the unity finished, with the failures of the peers.
+	Shutdown() Future
 }
 
 // Concrete implementation of the Unity interface.
@@ -51,17 +198,68 @@ type PeerUnity struct {
 	Invoker core.Invoker
 }
 
+// Creates the configuration of the peer with the given index on
+// the partition, the peer name is offset by the partition ordinal.
+func NewPeerConfiguration(configuration *types.Configuration, index int, reporter *types.ErrorReporter) *types.PeerConfiguration {
+	return &types.PeerConfiguration{
+		Name:         types.PeerID{Partition: configuration.Name, Ordinal: configuration.Ordinal + index}.String(),
+		Partition:    configuration.Name,
+		Version:      configuration.Version,
+		Conflict:     configuration.Conflict,
+		Strictness:   configuration.Strictness,
+		Membership:   configuration.Membership,
+		Storage:      configuration.Storage,
+		Shards:       configuration.Shards,
+		Outbox:       configuration.Outbox,
+		Durability:   configuration.Durability,
+		BatchSize:    configuration.BatchSize,
+		Parallelism:  configuration.Parallelism,
+		Dedup:        configuration.Dedup,
+		Location:     configuration.Location,
+		Topology:     configuration.Topology,
+		Codec:        configuration.Codec,
+		Codecs:       configuration.Codecs,
+		SigningKey:   configuration.SigningKey,
+		Compression:  configuration.Compression,
+		Resolver:     configuration.Resolver,
+		Broker:       configuration.Broker,
+		Consumer:     configuration.Consumer,
+		Retry:        configuration.Retry,
+		Breaker:      configuration.Breaker,
+		Fanout:       configuration.Fanout,
+		Tree:         configuration.Tree,
+		OnBreaker:    configuration.OnBreaker,
+		OnCut:        configuration.OnCut,
+		ObserverTTL:  configuration.ObserverTTL,
+		Heartbeat:    configuration.Heartbeat,
+		GCInterval:   configuration.GCInterval,
+		Credits:      configuration.Credits,
+		ClockStep:    configuration.ClockStep,
+		Local:        configuration.Local,
+		Features:     configuration.Features,
+		Interceptors: configuration.Interceptors,
+		Errors:       reporter,
+		Recorder:     configuration.Recorder,
+		Tracer:       configuration.Tracer,
+		Metrics:      configuration.Metrics,
+	}
+}
+
 func NewUnity(configuration *types.Configuration) (Unity, error) {
+	if err := configuration.Name.Validate(); err != nil {
+		return nil, err
+	}
 	invk := core.InvokerInstance()
+	types.ApplyLogLevels(configuration.Logger, configuration.LogLevels)
+	reporter := types.NewErrorReporter(types.DefaultErrorBuffer)
+	var dispatch types.DeliverCallback
+	if configuration.OnDeliver != nil {
+		dispatch = core.NewDispatcher(configuration.Executor, configuration.OnDeliver).Dispatch
+	}
 	var peers []core.PartitionPeer
 	for i := 0; i < configuration.Replication; i++ {
-		pc := &types.PeerConfiguration{
-			Name:      fmt.Sprintf("%s-%d", configuration.Name, i),
-			Partition: configuration.Name,
-			Version:   configuration.Version,
-			Conflict:  configuration.Conflict,
-			Storage:   configuration.Storage,
-		}
+		pc := NewPeerConfiguration(configuration, i, reporter)
+		pc.OnDeliver = dispatch
 		peer, err := core.NewPeer(pc, configuration.Logger)
 		if err != nil {
 			return nil, err
@@ -81,15 +279,34 @@ func NewUnity(configuration *types.Configuration) (Unity, error) {
 // Implements the Unity interface.
 func (p *PeerUnity) Write(request types.Request) <-chan types.Response {
 	id := types.UID(helper.GenerateUID())
+	if err := types.ValidateAcknowledgments(request); err != nil {
+		return failed(id, err)
+	}
+	if err := types.ValidateRequest(request, p.Configuration.Validators); err != nil {
+		return failed(id, err)
+	}
+
+	peer, err := p.resolveHealthyPeer()
+	if err != nil {
+		return failed(id, err)
+	}
+	for _, partition := range request.Destination {
+		if peer.Retired(partition) {
+			return failed(id, fmt.Errorf("%w: %s", core.ErrDecommissioned, partition))
+		}
+	}
+
 	message := types.Message{
 		Header: types.ProtocolHeader{
 			ProtocolVersion: p.Configuration.Version,
 			Type:            types.Initial,
+			Consistency:     request.Consistency,
 		},
 		Identifier: id,
 		Content: types.DataHolder{
 			Operation:  types.Command,
 			Key:        request.Key,
+			Keys:       request.Keys,
 			Content:    request.Value,
 			Extensions: request.Extra,
 		},
@@ -98,23 +315,390 @@ func (p *PeerUnity) Write(request types.Request) <-chan types.Response {
 		Destination: request.Destination,
 		From:        p.Configuration.Name,
 	}
-	peer := p.resolveNextPeer()
+	if request.Progress != nil {
+		p.follow(peer, &message, request.Progress)
+	}
+	if request.Acknowledgments > 0 {
+		message.Header.Flags |= types.FlagReceipt
+		message.Header.ReplyTo = p.Configuration.Name
+		peer.ExpectReceipts(id, request.Acknowledgments)
+	}
+	if request.Urgent {
+		message.Header.Flags |= types.FlagUrgent
+	}
 	p.Configuration.Logger.Infof("sending request %#v", request)
-	return peer.Command(message)
+	res := peer.Command(request.Context, message)
+	if request.Acknowledgments > 0 {
+		return peer.AwaitReceipts(request.Context, id, res)
+	}
+	return res
 }
 
 // Implements the Unity interface.
+// A read with a causality token is answered once the write of the
+// token is delivered, following the read mode.
 func (p *PeerUnity) Read(request types.Request) (types.Response, error) {
-	peer := p.resolveNextPeer()
+	peer := p.resolveCurrentPeer()
+	if request.After == nil || request.ReadMode == types.ReadLocal {
+		return peer.FastRead(request)
+	}
+
+	if request.ReadMode == types.ReadProxy {
+		for _, replica := range p.Peers {
+			if replica.Observed(*request.After) {
+				return replica.FastRead(request)
+			}
+		}
+	}
+	if err := peer.AwaitObserved(request.Context, *request.After); err != nil {
+		return types.Response{Failure: err}, err
+	}
 	return peer.FastRead(request)
 }
 
 // Implements the Unity interface.
-func (p *PeerUnity) Shutdown() {
+func (p *PeerUnity) ReadAt(key []byte, timestamp uint64) (types.Response, error) {
+	filter := &types.HistoryFilter{Key: key, To: timestamp, Last: 1}
+	res, err := p.resolveCurrentPeer().FastRead(types.Request{Key: key, History: filter})
+	if err != nil {
+		return res, err
+	}
+	if len(res.History) == 0 {
+		res.Success = false
+		res.Failure = fmt.Errorf("%w: %s at %d", ErrNoVersion, key, timestamp)
+		return res, res.Failure
+	}
+
+	entry := res.History[0]
+	res.Identifier = entry.Identifier
+	res.Data = entry.Data
+	res.Extra = entry.Extensions
+	res.History = nil
+	return res, nil
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) PauseDelivery() {
+	for _, peer := range p.Peers {
+		peer.PauseDelivery()
+	}
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) ResumeDelivery() {
+	for _, peer := range p.Peers {
+		peer.ResumeDelivery()
+	}
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Seed(iterator types.SeedIterator) error {
+	marker := types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: p.Configuration.Version,
+			Type:            types.Initial,
+			Flags:           types.FlagSeed,
+			Consistency:     types.ConsistencyTotalOrder,
+		},
+		Identifier:  types.UID(helper.GenerateUID()),
+		State:       types.S0,
+		Destination: []types.Partition{p.Configuration.Name},
+		From:        p.Configuration.Name,
+	}
+
+	var reached []<-chan struct{}
+	for _, peer := range p.Peers {
+		reached = append(reached, peer.AwaitSeed(marker.Identifier))
+	}
+	defer func() {
+		for _, peer := range p.Peers {
+			peer.AbandonSeed(marker.Identifier)
+		}
+		p.ResumeDelivery()
+	}()
+
+	res := p.resolveCurrentPeer().Command(context.Background(), marker)
+	deadline := time.After(types.DefaultSeedTimeout)
+	for _, marked := range reached {
+	wait:
+		for {
+			select {
+			case <-marked:
+				break wait
+			case r, ok := <-res:
+				if ok && !r.Success {
+					return r.Failure
+				}
+				res = nil
+			case <-deadline:
+				return ErrSeedTimeout
+			}
+		}
+	}
+
+	for {
+		var entries []*types.Entry
+		for len(entries) < types.DefaultSeedBatch {
+			seed, ok, err := iterator.Next()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			entries = append(entries, seed.Entry())
+		}
+
+		if len(entries) == 0 {
+			return nil
+		}
+
+		for _, peer := range p.Peers {
+			if err := peer.Seed(entries); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Cut(partitions []types.Partition) (types.Cut, error) {
+	destination := []types.Partition{p.Configuration.Name}
+	for _, partition := range partitions {
+		if partition != p.Configuration.Name {
+			destination = append(destination, partition)
+		}
+	}
+	marker := types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: p.Configuration.Version,
+			Type:            types.Initial,
+			Flags:           types.FlagCut,
+			Consistency:     types.ConsistencyTotalOrder,
+		},
+		Identifier:  types.UID(helper.GenerateUID()),
+		State:       types.S0,
+		Destination: destination,
+		From:        p.Configuration.Name,
+	}
+
+	peer := p.resolveCurrentPeer()
+	reached := peer.AwaitCut(marker.Identifier)
+	defer peer.AbandonCut(marker.Identifier)
+
+	res := peer.Command(context.Background(), marker)
+	deadline := time.After(types.DefaultCutTimeout)
+	for {
+		select {
+		case cut := <-reached:
+			return cut, cut.Failure
+		case r, ok := <-res:
+			if ok && !r.Success {
+				return types.Cut{}, r.Failure
+			}
+			res = nil
+		case <-deadline:
+			return types.Cut{}, ErrCutTimeout
+		}
+	}
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Inspect(uid types.UID) []types.MessageStatus {
+	var statuses []types.MessageStatus
+	for _, peer := range p.Peers {
+		if status, ok := peer.Inspect(uid); ok {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Dump() []types.QueueDump {
+	dumps := make([]types.QueueDump, 0, len(p.Peers))
+	for _, peer := range p.Peers {
+		dumps = append(dumps, peer.Dump())
+	}
+	return dumps
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Expire(uid types.UID) bool {
+	expired := false
+	for _, peer := range p.Peers {
+		expired = peer.Expire(uid) || expired
+	}
+	return expired
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Resend(uid types.UID) bool {
+	resent := false
+	for _, peer := range p.Peers {
+		resent = peer.Resend(uid) || resent
+	}
+	return resent
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Zones() map[types.Zone]types.ZoneMetrics {
+	zones := make(map[types.Zone]types.ZoneMetrics)
+	for _, peer := range p.Peers {
+		for zone, m := range peer.Zones() {
+			aggregated := zones[zone]
+			aggregated.Sent += m.Sent
+			aggregated.Received += m.Received
+			zones[zone] = aggregated
+		}
+	}
+	return zones
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Stats() types.PartitionStats {
+	var stats types.PartitionStats
+	for _, peer := range p.Peers {
+		stats = stats.Greatest(peer.Stats())
+	}
+	return stats
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) ClockSkew() map[string]types.SkewMetrics {
+	skew := make(map[string]types.SkewMetrics)
+	for _, peer := range p.Peers {
+		for destination, m := range peer.ClockSkew() {
+			skew[destination] = skew[destination].Merge(m)
+		}
+	}
+	return skew
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Missed() uint64 {
+	var missed uint64
+	for _, peer := range p.Peers {
+		missed += peer.Missed()
+	}
+	return missed
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Consumer() types.ConsumerMetrics {
+	var metrics types.ConsumerMetrics
 	for _, peer := range p.Peers {
-		peer.Stop()
+		metrics = metrics.Merge(peer.Consumer())
 	}
-	p.Invoker.Stop()
+	return metrics
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) GatherTimeouts() uint64 {
+	var timeouts uint64
+	for _, peer := range p.Peers {
+		timeouts += peer.GatherTimeouts()
+	}
+	return timeouts
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Breakers() map[types.Partition]types.BreakerMetrics {
+	breakers := make(map[types.Partition]types.BreakerMetrics)
+	for _, peer := range p.Peers {
+		for partition, metrics := range peer.Breakers() {
+			breakers[partition] = breakers[partition].Merge(metrics)
+		}
+	}
+	return breakers
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) AbandonedObservers() uint64 {
+	var abandoned uint64
+	for _, peer := range p.Peers {
+		abandoned += peer.AbandonedObservers()
+	}
+	return abandoned
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) EvictedResponses() uint64 {
+	var evicted uint64
+	for _, peer := range p.Peers {
+		evicted += peer.EvictedResponses()
+	}
+	return evicted
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Ready() bool {
+	for _, peer := range p.Peers {
+		if !peer.Ready() {
+			return false
+		}
+	}
+	return len(p.Peers) > 0
+}
+
+// Implements the Unity interface.
+// All peers of the unity share the same reporter.
+func (p *PeerUnity) Errors() <-chan error {
+	if len(p.Peers) == 0 {
+		return nil
+	}
+	return p.Peers[0].Errors()
+}
+
+// Implements the Unity interface.
+func (p *PeerUnity) Decommission(notify []types.Partition, timeout time.Duration) error {
+	if err := p.resolveNextPeer().Decommission(notify); err != nil {
+		return err
+	}
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		drained := true
+		for _, peer := range p.Peers {
+			drained = drained && peer.Drained()
+		}
+		if drained {
+			return nil
+		}
+
+		select {
+		case <-deadline:
+			return ErrDecommissionTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// Implements the Unity interface.
+// The epoch is advanced through the peer on the greatest epoch.
+func (p *PeerUnity) AdvanceEpoch() error {
+	return p.resolveCurrentPeer().AdvanceEpoch()
+}
+
+// Implements the Unity interface.
+// The goroutines are waited outside of the invoker, since
+// stopping the invoker waits for every goroutine it spawned.
+func (p *PeerUnity) Shutdown() Future {
+	var failures []error
+	for _, peer := range p.Peers {
+		if err := peer.Stop(); err != nil {
+			failures = append(failures, err)
+		}
+	}
+
+	future := &shutdownFuture{done: make(chan struct{})}
+	go func() {
+		p.Invoker.Stop()
+		future.complete(failures)
+	}()
+	return future
 }
 
 // Returns the next peer to be used. This will
@@ -125,3 +709,78 @@ func (p PeerUnity) resolveNextPeer() core.PartitionPeer {
 	}()
 	return p.Peers[p.Last%len(p.Peers)]
 }
+
+// Returns the next peer if it is on the greatest epoch amongst
+// the peers, otherwise a peer on the greatest epoch, so requests
+// are redirected from the stale peers.
+func (p PeerUnity) resolveCurrentPeer() core.PartitionPeer {
+	var greatest uint64
+	for _, peer := range p.Peers {
+		if epoch := peer.Epoch(); epoch > greatest {
+			greatest = epoch
+		}
+	}
+
+	next := p.resolveNextPeer()
+	if next.Epoch() == greatest {
+		return next
+	}
+	for _, peer := range p.Peers {
+		if peer.Epoch() == greatest {
+			return peer
+		}
+	}
+	return next
+}
+
+// Returns the current peer if healthy, otherwise a healthy peer on
+// the greatest epoch amongst the healthy peers. When no peer is
+// healthy fails with ErrNoHealthyPeer, with the reason of each peer.
+func (p PeerUnity) resolveHealthyPeer() (core.PartitionPeer, error) {
+	if len(p.Peers) > 0 {
+		if current := p.resolveCurrentPeer(); current.Healthy() == nil {
+			return current, nil
+		}
+	}
+
+	var healthy core.PartitionPeer
+	var reasons []string
+	for i, peer := range p.Peers {
+		if err := peer.Healthy(); err != nil {
+			reasons = append(reasons, fmt.Sprintf("peer %d: %v", i, err))
+			continue
+		}
+		if healthy == nil || peer.Epoch() > healthy.Epoch() {
+			healthy = peer
+		}
+	}
+	if healthy == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoHealthyPeer, strings.Join(reasons, "; "))
+	}
+	return healthy, nil
+}
+
+// Follow the progress of the message on the peer issuing it. When
+// the message has other destinations, they reply to the partition
+// once delivered, so the peer knows when every destination delivered.
+func (p *PeerUnity) follow(peer core.PartitionPeer, message *types.Message, events chan<- types.Progress) {
+	for _, partition := range message.Destination {
+		if partition != p.Configuration.Name {
+			message.Header.ReplyTo = p.Configuration.Name
+			break
+		}
+	}
+	peer.Follow(message.Identifier, message.Destination, events)
+}
+
+// Creates a channel holding the failed response.
+func failed(id types.UID, err error) <-chan types.Response {
+	res := make(chan types.Response, 1)
+	res <- types.Response{
+		Success:    false,
+		Identifier: id,
+		Failure:    err,
+	}
+	close(res)
+	return res
+}
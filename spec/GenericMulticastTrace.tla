@@ -0,0 +1,103 @@
+------------------------ MODULE GenericMulticastTrace ------------------------
+(***************************************************************************)
+(* Validates a trace written by the types.StepTracer against the generic  *)
+(* multicast algorithm. Each line of the trace is a step of a peer, the   *)
+(* steps are replayed in order and every step must be enabled on the     *)
+(* specification. A step that is not enabled makes TLC report a deadlock  *)
+(* before the whole trace is consumed.                                    *)
+(*                                                                         *)
+(* The trace is read from the file on the TRACE_PATH environment variable *)
+(* and every message on the trace is assumed to conflict.                 *)
+(***************************************************************************)
+EXTENDS Naturals, Sequences, Json, IOUtils
+
+Trace == ndJsonDeserialize(IOEnv.TRACE_PATH)
+
+Steps == 1..Len(Trace)
+
+Peers == {Trace[k].peer : k \in Steps}
+
+Messages == {Trace[k].uid : k \in Steps}
+
+\* The message states, as the types.MessageState values.
+S0 == 0
+S3 == 3
+
+Range(s) == {s[k] : k \in DOMAIN s}
+
+VARIABLES
+    i,          \* The next step on the trace.
+    state,      \* The state of each message on each peer.
+    delivered,  \* The messages each peer committed, in order.
+    final       \* The timestamp each peer committed each message.
+
+vars == <<i, state, delivered, final>>
+
+Init ==
+    /\ i = 1
+    /\ state = [p \in Peers |-> [m \in Messages |-> S0]]
+    /\ delivered = [p \in Peers |-> <<>>]
+    /\ final = [p \in Peers |-> [m \in Messages |-> 0]]
+
+Step == Trace[i]
+
+(***************************************************************************)
+(* The message was received from or sent to a partition. The transport    *)
+(* does not change the message state.                                     *)
+(***************************************************************************)
+Transport ==
+    /\ Step.action \in {"received", "sent"}
+    /\ UNCHANGED <<state, delivered, final>>
+
+(***************************************************************************)
+(* The message changed its state or timestamp. A message committed by the *)
+(* peer does not change anymore.                                          *)
+(***************************************************************************)
+ChangeState ==
+    /\ Step.action = "state"
+    /\ Step.uid \notin Range(delivered[Step.peer])
+    /\ state' = [state EXCEPT ![Step.peer][Step.uid] = Step.state]
+    /\ UNCHANGED <<delivered, final>>
+
+(***************************************************************************)
+(* The message is committed on the state machine. Only messages with the  *)
+(* final timestamp are committed, at most once on each peer, with the     *)
+(* same timestamp on every peer and in the order of the timestamps.       *)
+(***************************************************************************)
+Deliver ==
+    LET p == Step.peer
+        m == Step.uid
+    IN /\ Step.action = "delivered"
+       /\ Step.state = S3
+       /\ m \notin Range(delivered[p])
+       /\ \A q \in Peers : m \in Range(delivered[q]) => final[q][m] = Step.ts
+       /\ Len(delivered[p]) > 0 => final[p][delivered[p][Len(delivered[p])]] <= Step.ts
+       /\ state' = [state EXCEPT ![p][m] = S3]
+       /\ delivered' = [delivered EXCEPT ![p] = Append(@, m)]
+       /\ final' = [final EXCEPT ![p][m] = Step.ts]
+
+\* The whole trace was consumed.
+Done ==
+    /\ i > Len(Trace)
+    /\ UNCHANGED vars
+
+Next ==
+    \/ /\ i <= Len(Trace)
+       /\ Transport \/ ChangeState \/ Deliver
+       /\ i' = i + 1
+    \/ Done
+
+Spec == Init /\ [][Next]_vars
+
+(***************************************************************************)
+(* Once the trace is consumed, every peer that received a message also    *)
+(* committed it. Only the Initial and External messages are committed,    *)
+(* reads and decommission markers are not.                                *)
+(***************************************************************************)
+Agreement ==
+    i > Len(Trace) =>
+        \A k \in Steps :
+            Trace[k].action = "received" /\ Trace[k].type \in {4, 5} =>
+                Trace[k].uid \in Range(delivered[Trace[k].peer])
+
+=============================================================================
@@ -0,0 +1,100 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func adminRequest(unity mcast.Unity, method, query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	mcast.AdminHandler(unity).ServeHTTP(w, httptest.NewRequest(method, "/admin?"+query, nil))
+	return w
+}
+
+func TestAdmin_UnstickMessage(t *testing.T) {
+	partitionName := types.Partition("admin-unity")
+	absent := types.Partition("admin-absent")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Retry = types.RetryPolicy{}
+	conf.Recorder = types.NewEventRecorder(16, 16)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	// The absent partition never answers, so the message is stuck.
+	// Wait until every replica holds it, since the request is
+	// answered by the replica that received the write.
+	obs := unity.Write(GenerateRandomRequest([]types.Partition{partitionName, absent}))
+	var uid types.UID
+	stuck := func(status []types.MessageStatus) bool {
+		if len(status) != conf.Replication {
+			return false
+		}
+		for _, s := range status {
+			if s.State != types.S1 {
+				return false
+			}
+		}
+		return true
+	}
+	if !WaitThisOrTimeout(func() {
+		for {
+			for recorded := range conf.Recorder.Dump() {
+				if stuck(unity.Inspect(recorded)) {
+					uid = recorded
+					return
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}, 5*time.Second) {
+		t.Fatalf("expected message pending on S1")
+	}
+
+	w := adminRequest(unity, http.MethodGet, "uid="+string(uid))
+	var status []types.MessageStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil || len(status) == 0 {
+		t.Fatalf("failed inspecting %s. %v", uid, err)
+	}
+	if len(status[0].Missing) != 1 || status[0].Missing[0] != absent {
+		t.Errorf("expected absent partition missing, found %#v", status[0])
+	}
+
+	var result mcast.AdminResult
+	w = adminRequest(unity, http.MethodPost, "action=resend&uid="+string(uid))
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil || !result.Applied {
+		t.Errorf("expected timestamp resent, found %#v. %v", result, err)
+	}
+
+	w = adminRequest(unity, http.MethodPost, "action=expire&uid="+string(uid))
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil || !result.Applied || len(result.Status) != 0 {
+		t.Errorf("expected message expired, found %#v. %v", result, err)
+	}
+
+	select {
+	case res := <-obs:
+		if res.Success || !errors.Is(res.Failure, core.ErrMessageExpired) {
+			t.Errorf("expected request expired, found %#v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expired request not answered")
+	}
+
+	if w := adminRequest(unity, http.MethodGet, "uid="+string(uid)); w.Code != http.StatusNotFound {
+		t.Errorf("expected expired message not found, found %d", w.Code)
+	}
+
+	if w := adminRequest(unity, http.MethodPost, "action=unknown&uid="+string(uid)); w.Code != http.StatusBadRequest {
+		t.Errorf("expected unknown action rejected, found %d", w.Code)
+	}
+}
@@ -0,0 +1,145 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A state machine recording the size of each batch committed.
+type batchingStorage struct {
+	*types.InMemoryStateMachine
+	types.Storage
+
+	mutex   *sync.Mutex
+	batches []int
+	fail    bool
+}
+
+func newBatchingStorage() *batchingStorage {
+	storage := definition.NewInMemoryStorage()
+	return &batchingStorage{
+		InMemoryStateMachine: types.NewStateMachine(storage),
+		Storage:              storage,
+		mutex:                &sync.Mutex{},
+	}
+}
+
+func (b *batchingStorage) CommitBatch(entries []*types.Entry) ([]interface{}, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.fail {
+		return nil, errors.New("batch failure")
+	}
+
+	b.batches = append(b.batches, len(entries))
+	var responses []interface{}
+	for _, entry := range entries {
+		res, err := b.InMemoryStateMachine.Commit(entry)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, res)
+	}
+	return responses, nil
+}
+
+func (b *batchingStorage) Batches() []int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]int(nil), b.batches...)
+}
+
+func batchMessage(uid types.UID, operation types.Operation) types.Message {
+	return types.Message{
+		Identifier: uid,
+		Content:    types.DataHolder{Operation: operation, Key: []byte("key"), Content: []byte(uid)},
+	}
+}
+
+func TestDeliver_CommitConsecutiveCommandsInBatch(t *testing.T) {
+	storage := newBatchingStorage()
+	deliver, err := core.NewDeliver(context.Background(), definition.NewDefaultLogger(), &definition.AlwaysConflict{}, storage)
+	if err != nil {
+		t.Fatalf("failed creating deliver. %v", err)
+	}
+
+	messages := []types.Message{
+		batchMessage("first", types.Command),
+		batchMessage("second", types.Command),
+		batchMessage("read", types.Query),
+		batchMessage("third", types.Command),
+	}
+	responses := deliver.CommitBatch(messages)
+	for i, res := range responses {
+		if !res.Success || res.Identifier != messages[i].Identifier {
+			t.Errorf("expected %s committed, found %#v", messages[i].Identifier, res)
+		}
+	}
+
+	if string(responses[2].Data) != "second" {
+		t.Errorf("expected query to read the second value, found %s", string(responses[2].Data))
+	}
+
+	if batches := storage.Batches(); len(batches) != 1 || batches[0] != 2 {
+		t.Errorf("expected a single batch of 2, found %v", batches)
+	}
+}
+
+func TestDeliver_FailedBatchCommittedOneByOne(t *testing.T) {
+	storage := newBatchingStorage()
+	storage.fail = true
+	deliver, err := core.NewDeliver(context.Background(), definition.NewDefaultLogger(), &definition.AlwaysConflict{}, storage)
+	if err != nil {
+		t.Fatalf("failed creating deliver. %v", err)
+	}
+
+	responses := deliver.CommitBatch([]types.Message{batchMessage("first", types.Command), batchMessage("second", types.Command)})
+	for _, res := range responses {
+		if !res.Success {
+			t.Errorf("expected committed one by one, found %#v", res)
+		}
+	}
+}
+
+func TestUnity_CommitInBatches(t *testing.T) {
+	partitionName := types.Partition("batch-unity")
+	storage := newBatchingStorage()
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Storage = storage
+	conf.BatchSize = 8
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	var observers []<-chan types.Response
+	for i := 0; i < 30; i++ {
+		observers = append(observers, unity.Write(GenerateRandomRequest([]types.Partition{partitionName})))
+	}
+
+	for _, obs := range observers {
+		select {
+		case res := <-obs:
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+
+	for _, size := range storage.Batches() {
+		if size > conf.BatchSize {
+			t.Errorf("batch of %d greater than %d", size, conf.BatchSize)
+		}
+	}
+}
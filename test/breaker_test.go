@@ -0,0 +1,149 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+func failing(transport *recordingTransport, fail bool) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	transport.fail = fail
+}
+
+func TestBreaker_OpenProbeAndClose(t *testing.T) {
+	transport := newRecordingTransport(true)
+	mutex := &sync.Mutex{}
+	var events []types.BreakerEvent
+	peer := &types.PeerConfiguration{
+		Name:    "breaker-peer",
+		Breaker: types.BreakerPolicy{Failures: 3, Cooldown: 50 * time.Millisecond},
+		OnBreaker: func(event types.BreakerEvent) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			events = append(events, event)
+		},
+	}
+	breaker := core.NewBreakerTransport(transport, peer, definition.NewDefaultLogger())
+	message := types.Message{Identifier: "breaker"}
+
+	for i := 0; i < 3; i++ {
+		if err := breaker.Unicast(message, "dead"); err == nil || errors.Is(err, core.ErrPartitionUnavailable) {
+			t.Fatalf("expected the transport failure, found %v", err)
+		}
+	}
+	if err := breaker.Unicast(message, "dead"); !errors.Is(err, core.ErrPartitionUnavailable) {
+		t.Fatalf("expected failing fast, found %v", err)
+	}
+	if err := breaker.Unicast(message, "alive"); err == nil || errors.Is(err, core.ErrPartitionUnavailable) {
+		t.Fatalf("expected other partitions not affected, found %v", err)
+	}
+	if metrics := breaker.Breakers()["dead"]; metrics.State != types.BreakerOpen || metrics.Opened != 1 || metrics.Rejected != 1 {
+		t.Fatalf("unexpected metrics %#v", metrics)
+	}
+
+	// The probe fails and the circuit opens again.
+	time.Sleep(60 * time.Millisecond)
+	if err := breaker.Unicast(message, "dead"); err == nil || errors.Is(err, core.ErrPartitionUnavailable) {
+		t.Fatalf("expected the probe sent, found %v", err)
+	}
+	if err := breaker.Unicast(message, "dead"); !errors.Is(err, core.ErrPartitionUnavailable) {
+		t.Fatalf("expected open after the failed probe, found %v", err)
+	}
+
+	// The probe succeeds and the circuit closes.
+	failing(transport, false)
+	time.Sleep(60 * time.Millisecond)
+	if err := breaker.Unicast(message, "dead"); err != nil {
+		t.Fatalf("expected the probe sent, found %v", err)
+	}
+	metrics := breaker.Breakers()["dead"]
+	if metrics.State != types.BreakerClosed || metrics.Probes != 2 || metrics.Opened != 2 {
+		t.Fatalf("unexpected metrics %#v", metrics)
+	}
+
+	expected := []types.BreakerState{types.BreakerOpen, types.BreakerHalfOpen, types.BreakerOpen, types.BreakerHalfOpen, types.BreakerClosed}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d state changes, found %#v", len(expected), events)
+	}
+	for i, event := range events {
+		if event.To != expected[i] || event.Partition != "dead" || event.Peer != "breaker-peer" {
+			t.Errorf("unexpected state change %#v", event)
+		}
+	}
+}
+
+func TestBreaker_BroadcastFailsFastOnOpenDestination(t *testing.T) {
+	transport := newRecordingTransport(true)
+	peer := &types.PeerConfiguration{
+		Name:    "breaker-broadcast",
+		Breaker: types.BreakerPolicy{Failures: 1, Cooldown: time.Hour},
+	}
+	breaker := core.NewBreakerTransport(transport, peer, definition.NewDefaultLogger())
+	if err := breaker.Unicast(types.Message{}, "dead"); err == nil {
+		t.Fatalf("expected the transport failure")
+	}
+
+	failing(transport, false)
+	message := types.Message{Identifier: "broadcast", Destination: []types.Partition{"alive", "dead"}}
+	if err := breaker.Broadcast(message); !errors.Is(err, core.ErrPartitionUnavailable) {
+		t.Fatalf("expected failing fast, found %v", err)
+	}
+	if sent, _ := transport.Sent(); len(sent) != 0 {
+		t.Errorf("expected nothing sent, found %d", len(sent))
+	}
+}
+
+// A transport failing only the sends to a single partition.
+type partitionFailingTransport struct {
+	*recordingTransport
+	dead types.Partition
+}
+
+func (p partitionFailingTransport) Broadcast(message types.Message) error {
+	for _, partition := range message.Destination {
+		if err := p.Unicast(message, partition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p partitionFailingTransport) Unicast(message types.Message, partition types.Partition) error {
+	if partition == p.dead {
+		return errors.New("partition down")
+	}
+	return p.recordingTransport.Unicast(message, partition)
+}
+
+func TestBreaker_BroadcastRecordsEachDestination(t *testing.T) {
+	transport := partitionFailingTransport{recordingTransport: newRecordingTransport(false), dead: "dead"}
+	peer := &types.PeerConfiguration{
+		Name:    "breaker-destinations",
+		Breaker: types.BreakerPolicy{Failures: 1, Cooldown: time.Hour},
+	}
+	breaker := core.NewBreakerTransport(transport, peer, definition.NewDefaultLogger())
+	message := types.Message{Identifier: "broadcast", Destination: []types.Partition{"dead", "alive"}}
+	if err := breaker.Broadcast(message); err == nil {
+		t.Fatalf("expected the transport failure")
+	}
+
+	if _, partitions := transport.Sent(); len(partitions) != 1 || partitions[0] != "alive" {
+		t.Errorf("expected sent only to alive, found %v", partitions)
+	}
+
+	metrics := breaker.Breakers()
+	if metrics["dead"].State != types.BreakerOpen {
+		t.Errorf("expected dead circuit open, found %#v", metrics["dead"])
+	}
+	if metrics["alive"].State != types.BreakerClosed {
+		t.Errorf("expected alive circuit closed, found %#v", metrics["alive"])
+	}
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func causalRead(unity mcast.Unity, token *types.CausalityToken, mode types.ReadMode, timeout time.Duration) (types.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return unity.Read(types.Request{Key: []byte("causal"), After: token, ReadMode: mode, Context: ctx})
+}
+
+func TestCausality_ReadAfterWrite(t *testing.T) {
+	partition := types.Partition("causality-unity")
+	conf := mcast.DefaultConfiguration(partition)
+	conf.Logger.ToggleDebug(false)
+	conf.Replication = 3
+	tokens := make(chan *types.CausalityToken, 1)
+	conf.OnDeliver = func(d types.Delivered) {
+		if string(d.Message.Content.Content) == "second" {
+			tokens <- d.Response.Token
+		}
+	}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	res := awaitResponse(t, unity.Write(GenerateRequest([]byte("causal"), []byte("first"), []types.Partition{partition})))
+	if !res.Success || res.Token == nil {
+		t.Fatalf("expected the causality token, found %#v", res)
+	}
+	if read, err := causalRead(unity, res.Token, types.ReadWait, time.Second); err != nil || string(read.Data) != "first" {
+		t.Fatalf("expected the first write, found %#v. %v", read, err)
+	}
+
+	// The first peer answers the reads and does not deliver the second write.
+	unity.(*mcast.PeerUnity).Peers[0].PauseDelivery()
+	unity.Write(GenerateRequest([]byte("causal"), []byte("second"), []types.Partition{partition}))
+	var token *types.CausalityToken
+	select {
+	case token = <-tokens:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("second write not delivered")
+	}
+
+	// The local read does not wait for the token.
+	if _, err := causalRead(unity, &types.CausalityToken{Key: token.Key, Timestamp: token.Timestamp + 100}, types.ReadLocal, time.Second); err != nil {
+		t.Errorf("expected the local read, found %v", err)
+	}
+	if _, err := causalRead(unity, token, types.ReadWait, 100*time.Millisecond); !errors.Is(err, core.ErrStaleRead) {
+		t.Errorf("expected a stale read, found %v", err)
+	}
+	if read, err := causalRead(unity, token, types.ReadProxy, time.Second); err != nil || string(read.Data) != "second" {
+		t.Errorf("expected the read proxied to a replica, found %#v. %v", read, err)
+	}
+
+	unity.(*mcast.PeerUnity).Peers[0].ResumeDelivery()
+	if read, err := causalRead(unity, token, types.ReadWait, time.Second); err != nil || string(read.Data) != "second" {
+		t.Errorf("expected the second write after resuming, found %#v. %v", read, err)
+	}
+}
+
+func TestWatermark_AwaitDelivery(t *testing.T) {
+	watermark := core.NewWatermark()
+	token := types.CausalityToken{Key: []byte("key"), Timestamp: 2}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- watermark.Await(context.Background(), token, time.Second)
+	}()
+	watermark.Advance([]byte("key"), 1)
+	watermark.Advance([]byte("other"), 5)
+	if watermark.Observed(token) {
+		t.Fatalf("expected the token not observed")
+	}
+	watermark.Advance([]byte("key"), 3)
+	if err := <-done; err != nil {
+		t.Errorf("expected the delivery observed, found %v", err)
+	}
+	if err := watermark.Await(context.Background(), types.CausalityToken{Key: []byte("key"), Timestamp: 9}, 10*time.Millisecond); !errors.Is(err, core.ErrStaleRead) {
+		t.Errorf("expected a stale read, found %v", err)
+	}
+}
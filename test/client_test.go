@@ -0,0 +1,69 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+// A client will write a value into a unity without
+// participating on the protocol, and then read back
+// the committed value.
+func TestClient_WriteAndReadFromUnity(t *testing.T) {
+	partitionName := types.Partition("client-unity")
+	unity := CreateUnity(partitionName, t)
+	defer unity.Shutdown()
+
+	client, err := mcast.NewClient(mcast.DefaultClientConfiguration("client-" + helper.GenerateUID()))
+	if err != nil {
+		t.Fatalf("failed creating client. %v", err)
+	}
+	defer client.Close()
+
+	key := []byte("client-key")
+	value := []byte("client-value")
+	write := GenerateRequest(key, value, []types.Partition{partitionName})
+	select {
+	case res := <-client.Write(write):
+		if !res.Success {
+			t.Fatalf("failed writing request %v", res.Failure)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("write timeout")
+	}
+
+	res, err := client.Read(GenerateRequest(key, nil, []types.Partition{partitionName}))
+	if err != nil {
+		t.Fatalf("failed reading value. %v", err)
+	}
+
+	if !bytes.Equal(value, res.Data) {
+		t.Errorf("retrieved response should be %s but was %s", string(value), string(res.Data))
+	}
+}
+
+// A write without reply fails after the client timeout,
+// instead of waiting forever.
+func TestClient_WriteTimeout(t *testing.T) {
+	conf := mcast.DefaultClientConfiguration("client-" + helper.GenerateUID())
+	conf.Timeout = 100 * time.Millisecond
+	client, err := mcast.NewClient(conf)
+	if err != nil {
+		t.Fatalf("failed creating client. %v", err)
+	}
+	defer client.Close()
+
+	write := GenerateRequest([]byte("key"), []byte("value"), []types.Partition{"client-absent-partition"})
+	select {
+	case res := <-client.Write(write):
+		if res.Success || !errors.Is(res.Failure, mcast.ErrClientTimeout) {
+			t.Errorf("expected client timeout, found %#v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("write did not time out")
+	}
+}
@@ -1,7 +1,10 @@
 package test
 
 import (
+	"errors"
 	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"math"
 	"sync"
 	"testing"
 )
@@ -33,3 +36,63 @@ func TestLogicalClock_GroupTick(t *testing.T) {
 		t.Fatalf("failed on define: %d", clk.Tock())
 	}
 }
+
+func TestLogicalClock_SteppedTick(t *testing.T) {
+	clk := core.NewSteppedClock(5)
+	for i := 0; i < 2; i++ {
+		if err := clk.Tick(); err != nil {
+			t.Fatalf("failed ticking. %v", err)
+		}
+	}
+	if clk.Tock() != 10 {
+		t.Errorf("expected clock on 10, found %d", clk.Tock())
+	}
+}
+
+func TestLogicalClock_NearOverflow(t *testing.T) {
+	clk := core.NewSteppedClock(10)
+	clk.Leap(math.MaxUint64 - 20)
+	for i := 0; i < 2; i++ {
+		if clk.Exhausted() {
+			t.Fatalf("clock exhausted on %d", clk.Tock())
+		}
+		if err := clk.Tick(); err != nil {
+			t.Fatalf("failed ticking on %d. %v", clk.Tock(), err)
+		}
+	}
+	if clk.Tock() != math.MaxUint64 || !clk.Exhausted() {
+		t.Fatalf("expected clock exhausted on the maximum, found %d", clk.Tock())
+	}
+	if err := clk.Tick(); !errors.Is(err, core.ErrClockOverflow) {
+		t.Errorf("expected overflow, found %v", err)
+	}
+	if clk.Tock() != math.MaxUint64 {
+		t.Errorf("expected clock kept on the maximum, found %d", clk.Tock())
+	}
+
+	clk = core.NewClock()
+	clk.Leap(math.MaxUint64 - 1)
+	if err := clk.Tick(); err != nil || !clk.Exhausted() {
+		t.Errorf("expected last tick before exhausted. %v", err)
+	}
+}
+
+func TestLogicalClock_RejectOnceExhausted(t *testing.T) {
+	cluster := CreateClusterWith(1, "overflow", t, func(conf *types.Configuration) {
+		conf.ClockStep = 1 << 63
+	})
+	defer cluster.Off()
+
+	unity := cluster.Unities[0]
+	write := func() types.Response {
+		return awaitResponse(t, unity.Write(GenerateRequest([]byte("key"), []byte("value"), cluster.Names)))
+	}
+
+	// The write conflicts, ticking the clock to the last step.
+	if res := write(); !res.Success {
+		t.Fatalf("failed writing. %v", res.Failure)
+	}
+	if res := write(); res.Success || !errors.Is(res.Failure, core.ErrClockOverflow) {
+		t.Errorf("expected clock overflow, found %#v", res)
+	}
+}
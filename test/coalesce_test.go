@@ -0,0 +1,130 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Records the requests applied, answering each one right away.
+type recordingWriter struct {
+	mutex   *sync.Mutex
+	applied []types.Request
+}
+
+func (r *recordingWriter) Write(request types.Request) <-chan types.Response {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.applied = append(r.applied, request)
+	res := make(chan types.Response, 1)
+	res <- types.Response{Success: true, Identifier: types.UID(request.Value)}
+	return res
+}
+
+func TestCoalescer_MergeSameKey(t *testing.T) {
+	writer := &recordingWriter{mutex: &sync.Mutex{}}
+	coalescer := mcast.NewCoalescer(writer.Write, 50*time.Millisecond)
+	destination := []types.Partition{"coalesce"}
+
+	var responses []<-chan types.Response
+	for _, value := range []string{"first", "second", "third"} {
+		responses = append(responses, coalescer.Write(GenerateRequest([]byte("counter"), []byte(value), destination)))
+	}
+	other := coalescer.Write(GenerateRequest([]byte("other"), []byte("other"), destination))
+
+	for i, res := range responses {
+		select {
+		case r := <-res:
+			if !r.Success || r.Identifier != "third" {
+				t.Errorf("write %d expected last value applied, found %#v", i, r)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("write %d not answered", i)
+		}
+	}
+
+	if r := <-other; r.Identifier != "other" {
+		t.Errorf("expected other key applied separately, found %#v", r)
+	}
+
+	if len(writer.applied) != 2 || coalescer.Merged() != 2 {
+		t.Errorf("expected 2 writes applied and 2 merged, found %d and %d", len(writer.applied), coalescer.Merged())
+	}
+}
+
+func TestCoalescer_NewWindowAfterFlush(t *testing.T) {
+	writer := &recordingWriter{mutex: &sync.Mutex{}}
+	coalescer := mcast.NewCoalescer(writer.Write, 10*time.Millisecond)
+	destination := []types.Partition{"coalesce"}
+
+	<-coalescer.Write(GenerateRequest([]byte("counter"), []byte("first"), destination))
+	<-coalescer.Write(GenerateRequest([]byte("counter"), []byte("second"), destination))
+	if len(writer.applied) != 2 || coalescer.Merged() != 0 {
+		t.Errorf("expected both writes applied, found %d", len(writer.applied))
+	}
+}
+
+func TestCoalescer_NotMergingDistinctRequests(t *testing.T) {
+	writer := &recordingWriter{mutex: &sync.Mutex{}}
+	coalescer := mcast.NewCoalescer(writer.Write, 50*time.Millisecond)
+	destination := []types.Partition{"coalesce"}
+
+	keys := GenerateRequest([]byte("counter"), []byte("keys"), destination)
+	keys.Keys = [][]byte{[]byte("other")}
+	acknowledged := GenerateRequest([]byte("counter"), []byte("acknowledged"), destination)
+	acknowledged.Acknowledgments = 1
+	urgent := GenerateRequest([]byte("counter"), []byte("urgent"), destination)
+	urgent.Urgent = true
+
+	var responses []<-chan types.Response
+	for _, request := range []types.Request{GenerateRequest([]byte("counter"), []byte("plain"), destination), keys, acknowledged, urgent} {
+		responses = append(responses, coalescer.Write(request))
+	}
+
+	for i, res := range responses {
+		select {
+		case <-res:
+		case <-time.After(time.Second):
+			t.Fatalf("write %d not answered", i)
+		}
+	}
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	if len(writer.applied) != 4 || coalescer.Merged() != 0 {
+		t.Errorf("expected 4 writes applied and none merged, found %d and %d", len(writer.applied), coalescer.Merged())
+	}
+}
+
+func TestCoalescingUnity_Write(t *testing.T) {
+	partitionName := types.Partition("coalesce-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	created, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	unity := mcast.NewCoalescingUnity(created, 20*time.Millisecond)
+	defer unity.Shutdown()
+
+	destination := []types.Partition{partitionName}
+	first := unity.Write(GenerateRequest([]byte("key"), []byte("first"), destination))
+	second := unity.Write(GenerateRequest([]byte("key"), []byte("second"), destination))
+	for _, res := range []<-chan types.Response{first, second} {
+		select {
+		case r := <-res:
+			if !r.Success {
+				t.Fatalf("failed writing. %v", r.Failure)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout writing")
+		}
+	}
+
+	res, err := unity.Read(GenerateRequest([]byte("key"), nil, destination))
+	if err != nil || string(res.Data) != "second" {
+		t.Errorf("expected last value, found %#v. %v", res, err)
+	}
+}
@@ -0,0 +1,320 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/core/transporttest"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func codecMessage() types.Message {
+	return types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: types.LatestProtocolVersion,
+			Type:            types.External,
+			ReplyTo:         "client",
+			Origin:          "peer-0",
+			Sequence:        70000,
+		},
+		Identifier: types.UID(helper.GenerateUID()),
+		Content: types.DataHolder{
+			Operation:  types.Command,
+			Key:        []byte("key"),
+			Content:    make([]byte, 300),
+			Extensions: nil,
+		},
+		State:       types.S2,
+		Timestamp:   1 << 40,
+		Destination: []types.Partition{"a", "b", "c"},
+		From:        "a",
+	}
+}
+
+func verifyCodecRoundTrip(t *testing.T, codec types.Codec) {
+	message := codecMessage()
+	data, err := codec.Marshal(message)
+	if err != nil {
+		t.Fatalf("failed marshalling. %v", err)
+	}
+
+	var decoded types.Message
+	if err := codec.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed unmarshalling. %v", err)
+	}
+
+	if !reflect.DeepEqual(message, decoded) {
+		t.Errorf("decoded message differs.\n%#v\n%#v", message, decoded)
+	}
+}
+
+func TestCodec_JSONRoundTrip(t *testing.T) {
+	verifyCodecRoundTrip(t, definition.JSONCodec{})
+}
+
+func TestCodec_MsgpackRoundTrip(t *testing.T) {
+	verifyCodecRoundTrip(t, definition.MsgpackCodec{})
+}
+
+func TestCodec_GobRoundTrip(t *testing.T) {
+	verifyCodecRoundTrip(t, definition.GobCodec{})
+}
+
+func TestCodec_CBORRoundTrip(t *testing.T) {
+	verifyCodecRoundTrip(t, definition.CBORCodec{})
+}
+
+func TestCodec_CBORScalars(t *testing.T) {
+	codec := definition.CBORCodec{}
+	values := map[string]int64{"small": -5, "int8": -100, "int16": -1000, "int32": -100000, "int64": -1 << 40, "max": 1<<63 - 1}
+	data, err := codec.Marshal(values)
+	if err != nil {
+		t.Fatalf("failed marshalling. %v", err)
+	}
+
+	var decoded map[string]int64
+	if err := codec.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed unmarshalling. %v", err)
+	}
+
+	if !reflect.DeepEqual(values, decoded) {
+		t.Errorf("expected %v, found %v", values, decoded)
+	}
+}
+
+func TestCodec_CBORWellKnownEncoding(t *testing.T) {
+	// Examples from the RFC 8949 appendix A.
+	examples := map[string]struct {
+		value    interface{}
+		expected []byte
+	}{
+		"zero":     {uint64(0), []byte{0x00}},
+		"uint8":    {uint64(100), []byte{0x18, 0x64}},
+		"uint16":   {uint64(1000), []byte{0x19, 0x03, 0xe8}},
+		"negative": {int64(-1000), []byte{0x39, 0x03, 0xe7}},
+		"text":     {"IETF", []byte{0x64, 0x49, 0x45, 0x54, 0x46}},
+		"bytes":    {[]byte{1, 2, 3, 4}, []byte{0x44, 0x01, 0x02, 0x03, 0x04}},
+		"array":    {[]int{1, 2, 3}, []byte{0x83, 0x01, 0x02, 0x03}},
+		"true":     {true, []byte{0xf5}},
+		"float":    {1.1, []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+	}
+	for name, example := range examples {
+		data, err := (definition.CBORCodec{}).Marshal(example.value)
+		if err != nil {
+			t.Fatalf("failed marshalling %s. %v", name, err)
+		}
+
+		if !reflect.DeepEqual(example.expected, data) {
+			t.Errorf("%s: expected %x, found %x", name, example.expected, data)
+		}
+	}
+}
+
+func TestCodec_CBORInvalidData(t *testing.T) {
+	var decoded types.Message
+	if err := (definition.CBORCodec{}).Unmarshal([]byte{0xa5, 0x61}, &decoded); err == nil {
+		t.Errorf("truncated data should fail")
+	}
+
+	if err := (definition.CBORCodec{}).Unmarshal([]byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, &decoded); err == nil {
+		t.Errorf("length beyond the data should fail")
+	}
+}
+
+func TestCodec_MsgpackScalars(t *testing.T) {
+	codec := definition.MsgpackCodec{}
+	values := map[string]int64{"fix": -5, "int8": -100, "int16": -1000, "int32": -100000, "int64": -1 << 40}
+	data, err := codec.Marshal(values)
+	if err != nil {
+		t.Fatalf("failed marshalling. %v", err)
+	}
+
+	var decoded map[string]int64
+	if err := codec.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed unmarshalling. %v", err)
+	}
+
+	if !reflect.DeepEqual(values, decoded) {
+		t.Errorf("expected %v, found %v", values, decoded)
+	}
+}
+
+func TestCodec_MsgpackIgnoreUnknownFields(t *testing.T) {
+	codec := definition.MsgpackCodec{}
+	data, err := codec.Marshal(struct {
+		Identifier types.UID
+		Unknown    []string
+	}{Identifier: "uid", Unknown: []string{"ignored"}})
+	if err != nil {
+		t.Fatalf("failed marshalling. %v", err)
+	}
+
+	var decoded types.Message
+	if err := codec.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed unmarshalling. %v", err)
+	}
+
+	if decoded.Identifier != "uid" {
+		t.Errorf("expected uid, found %s", decoded.Identifier)
+	}
+}
+
+func TestCodec_MsgpackInvalidData(t *testing.T) {
+	var decoded types.Message
+	if err := (definition.MsgpackCodec{}).Unmarshal([]byte{0x85, 0xa1}, &decoded); err == nil {
+		t.Errorf("truncated data should fail")
+	}
+}
+
+func TestCodec_MsgpackTransportConformance(t *testing.T) {
+	transporttest.Run(t, func(partition types.Partition, name string) (core.Transport, error) {
+		peer := &types.PeerConfiguration{
+			Name:      name,
+			Partition: partition,
+			Codec:     definition.MsgpackCodec{},
+		}
+		return core.NewTransport(peer, core.NewRTTEstimator(), definition.NewDefaultLogger())
+	})
+}
+
+func TestCodec_CBORTransportConformance(t *testing.T) {
+	transporttest.Run(t, func(partition types.Partition, name string) (core.Transport, error) {
+		peer := &types.PeerConfiguration{
+			Name:      name,
+			Partition: partition,
+			Codec:     definition.CBORCodec{},
+		}
+		return core.NewTransport(peer, core.NewRTTEstimator(), definition.NewDefaultLogger())
+	})
+}
+
+func TestCodec_NegotiateCheapestCommonCodec(t *testing.T) {
+	negotiator := core.NewCodecNegotiator(definition.JSONCodec{}, definition.KnownCodecs())
+	if codec := negotiator.Choose([]types.Partition{"a"}); codec != (definition.JSONCodec{}) {
+		t.Errorf("expected fallback before advertising, found %T", codec)
+	}
+
+	advertise := func(from types.Partition, origin string, codecs types.CodecID) {
+		message := types.Message{From: from}
+		message.Header.Origin = origin
+		message.Header.Codecs = codecs
+		negotiator.Observe(message)
+	}
+	advertise("a", "a-0", types.CodecJSON|types.CodecMsgpack|types.CodecCBOR)
+	advertise("a", "a-1", types.CodecJSON|types.CodecCBOR)
+	advertise("b", "b-0", types.CodecJSON|types.CodecMsgpack)
+
+	if codec := negotiator.Choose([]types.Partition{"a"}); codec != (definition.CBORCodec{}) {
+		t.Errorf("expected cbor for a, found %T", codec)
+	}
+
+	if codec := negotiator.Choose([]types.Partition{"b"}); codec != (definition.MsgpackCodec{}) {
+		t.Errorf("expected msgpack for b, found %T", codec)
+	}
+
+	if codec := negotiator.Choose([]types.Partition{"a", "b"}); codec != (definition.JSONCodec{}) {
+		t.Errorf("expected json for a and b, found %T", codec)
+	}
+
+	// A peer that stops negotiating falls back to the configured codec.
+	advertise("b", "b-0", 0)
+	if codec := negotiator.Choose([]types.Partition{"b"}); codec != (definition.JSONCodec{}) {
+		t.Errorf("expected fallback for b, found %T", codec)
+	}
+}
+
+func TestCodec_NegotiatedTransport(t *testing.T) {
+	create := func(name string, codec types.Codec, codecs []types.IdentifiedCodec) core.Transport {
+		peer := &types.PeerConfiguration{
+			Name:      name,
+			Partition: types.Partition(name),
+			Codec:     codec,
+			Codecs:    codecs,
+		}
+		transport, err := core.NewTransport(peer, core.NewRTTEstimator(), definition.NewDefaultLogger())
+		if err != nil {
+			t.Fatalf("failed creating transport. %v", err)
+		}
+		return transport
+	}
+	first := create("negotiated-first", definition.JSONCodec{}, definition.KnownCodecs())
+	defer first.Close()
+	second := create("negotiated-second", definition.MsgpackCodec{}, []types.IdentifiedCodec{definition.GobCodec{}, definition.MsgpackCodec{}})
+	defer second.Close()
+
+	send := func(from core.Transport, source, target types.Partition, to core.Transport) types.Message {
+		message := codecMessage()
+		message.From = source
+		message.Header.Origin = string(source)
+		message.Destination = []types.Partition{target}
+		if err := from.Unicast(message, target); err != nil {
+			t.Fatalf("failed sending. %v", err)
+		}
+		select {
+		case received := <-to.Listen():
+			return received
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message not received")
+		}
+		return types.Message{}
+	}
+
+	// Each side decodes the configured codec of the other before
+	// negotiating, since the frame identifies the codec.
+	received := send(first, "negotiated-first", "negotiated-second", second)
+	if received.Header.Codecs != types.CodecJSON|types.CodecMsgpack|types.CodecGob|types.CodecCBOR {
+		t.Errorf("wrong codecs advertised %d", received.Header.Codecs)
+	}
+
+	received = send(second, "negotiated-second", "negotiated-first", first)
+	if received.Identifier == "" || received.Header.Codecs != types.CodecGob|types.CodecMsgpack {
+		t.Errorf("wrong message received %#v", received)
+	}
+
+	received = send(first, "negotiated-first", "negotiated-second", second)
+	if !reflect.DeepEqual(received.Content, codecMessage().Content) {
+		t.Errorf("wrong content after negotiating %#v", received.Content)
+	}
+}
+
+// A codec failing to serialize any value.
+type failingCodec struct {
+	definition.JSONCodec
+}
+
+func (failingCodec) Marshal(interface{}) ([]byte, error) {
+	return nil, errors.New("codec unavailable")
+}
+
+func TestCodec_EncodeFailureNotPublished(t *testing.T) {
+	peer := &types.PeerConfiguration{
+		Name:      "encode-failure",
+		Partition: "encode-failure",
+		Codec:     failingCodec{},
+		Broker:    core.NewMemoryBroker(),
+	}
+	transport, err := core.NewTransport(peer, core.NewRTTEstimator(), definition.NewDefaultLogger())
+	if err != nil {
+		t.Fatalf("failed creating transport. %v", err)
+	}
+	defer transport.Close()
+
+	message := codecMessage()
+	message.Destination = []types.Partition{"encode-failure"}
+	if err := transport.Unicast(message, "encode-failure"); err == nil {
+		t.Errorf("expected unicast failing to encode")
+	}
+	if err := transport.Broadcast(message); err == nil {
+		t.Errorf("expected broadcast failing to encode")
+	}
+
+	select {
+	case m := <-transport.Listen():
+		t.Errorf("expected nothing published, found %#v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
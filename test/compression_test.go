@@ -0,0 +1,144 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Compresses the contents starting with the common prefix of
+// the samples, counting the decompressed contents.
+type prefixCodec struct {
+	decompressed *int64
+}
+
+func newPrefixCodec() prefixCodec {
+	return prefixCodec{decompressed: new(int64)}
+}
+
+func (prefixCodec) Train(samples [][]byte) ([]byte, error) {
+	prefix := samples[0]
+	for _, sample := range samples[1:] {
+		for !bytes.HasPrefix(sample, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(prefix) == 0 {
+		return nil, errors.New("no common prefix")
+	}
+	return prefix, nil
+}
+
+func (prefixCodec) Compress(dictionary, content []byte) ([]byte, error) {
+	if !bytes.HasPrefix(content, dictionary) {
+		return nil, errors.New("content without prefix")
+	}
+	return content[len(dictionary):], nil
+}
+
+func (c prefixCodec) Decompress(dictionary, content []byte) ([]byte, error) {
+	atomic.AddInt64(c.decompressed, 1)
+	return append(append([]byte(nil), dictionary...), content...), nil
+}
+
+func trained(compressor *core.DictionaryCompressor) uint32 {
+	deadline := time.Now().Add(5 * time.Second)
+	for compressor.Version() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return compressor.Version()
+}
+
+func TestDictionaryCompressor_TrainAndShare(t *testing.T) {
+	store := definition.NewInMemoryDictionaries()
+	policy := types.Compression{Codec: newPrefixCodec(), Dictionaries: store, Samples: 3, Size: 64}
+	sender := core.NewDictionaryCompressor(policy, definition.NewDefaultLogger())
+	message := types.Message{
+		Header:  types.ProtocolHeader{Type: types.Initial},
+		Content: types.DataHolder{Content: []byte("common-prefix-value-4")},
+	}
+	if compressed := sender.Compress(message); compressed.Header.Dictionary != 0 {
+		t.Fatalf("expected no compression before training")
+	}
+
+	for i := 0; i < 3; i++ {
+		sender.Sample([]byte(fmt.Sprintf("common-prefix-value-%d", i)))
+	}
+	version := trained(sender)
+	if version == 0 {
+		t.Fatalf("dictionary not trained")
+	}
+	compressed := sender.Compress(message)
+	if compressed.Header.Dictionary != version || string(compressed.Content.Content) != "4" {
+		t.Fatalf("expected the content compressed, found %#v", compressed)
+	}
+
+	receiver := core.NewDictionaryCompressor(policy, definition.NewDefaultLogger())
+	decompressed, err := receiver.Decompress(compressed)
+	if err != nil || decompressed.Header.Dictionary != 0 || string(decompressed.Content.Content) != "common-prefix-value-4" {
+		t.Errorf("expected the content back, found %#v. %v", decompressed, err)
+	}
+
+	other := types.Compression{Codec: newPrefixCodec(), Dictionaries: definition.NewInMemoryDictionaries()}
+	if _, err := core.NewDictionaryCompressor(other, definition.NewDefaultLogger()).Decompress(compressed); !errors.Is(err, core.ErrDecompression) {
+		t.Errorf("expected the unknown dictionary to fail, found %v", err)
+	}
+	var disabled *core.DictionaryCompressor
+	if _, err := disabled.Decompress(compressed); !errors.Is(err, core.ErrDecompression) {
+		t.Errorf("expected the disabled compression to fail, found %v", err)
+	}
+	large := message
+	large.Content.Content = bytes.Repeat([]byte("common-prefix-value-"), 10)
+	if sender.Compress(large).Header.Dictionary != 0 {
+		t.Errorf("expected the large content not compressed")
+	}
+	reply := message
+	reply.Header.Type = types.Reply
+	if sender.Compress(reply).Header.Dictionary != 0 {
+		t.Errorf("expected the reply not compressed")
+	}
+}
+
+func TestDictionaryCompressor_UnityRoundTrip(t *testing.T) {
+	partition := types.Partition("compression-unity")
+	codec := newPrefixCodec()
+	conf := mcast.DefaultConfiguration(partition)
+	conf.Logger.ToggleDebug(false)
+	conf.Compression = types.Compression{Codec: codec, Dictionaries: definition.NewInMemoryDictionaries(), Samples: 5}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	write := func(i int) {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		res := awaitResponse(t, unity.Write(GenerateRequest(key, []byte(fmt.Sprintf("compressed-value-%d", i)), []types.Partition{partition})))
+		if !res.Success {
+			t.Fatalf("failed writing %d. %v", i, res.Failure)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		write(i)
+	}
+
+	// The dictionary is trained asynchronously after the samples.
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 5; atomic.LoadInt64(codec.decompressed) == 0; i++ {
+		if time.Now().After(deadline) {
+			t.Fatalf("no message received compressed")
+		}
+		write(i)
+	}
+	res, err := unity.Read(types.Request{Key: []byte("key-5")})
+	if err != nil || string(res.Data) != "compressed-value-5" {
+		t.Errorf("expected the value decompressed, found %#v. %v", res, err)
+	}
+}
@@ -0,0 +1,325 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/config"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const yamlConfiguration = `
+name: orders # the partition
+replication: 2
+conflict: key
+strictness: pending
+clock_step: 4
+log_levels: "info,transport=debug"
+features: "-generic_delivery"
+codec: msgpack
+codecs:
+  - msgpack
+  - json
+addresses:
+  orders: orders-exchange
+  'users': "users-exchange"
+location:
+  datacenter: dc-a
+  zone: 1
+topology:
+  users:
+    datacenter: dc-b
+    zone: zone-2
+durability:
+  policy: batched
+  interval: 50ms
+dedup:
+  capacity: 1000
+  false_positive: 0.01
+retry:
+  attempts: 3
+  backoff: 1.5
+membership:
+  members:
+    - orders
+    - users
+  check: receive
+fanout:
+  workers: 2
+  partial: all
+tree:
+  threshold: 8
+`
+
+const tomlConfiguration = `
+name = "orders" # the partition
+replication = 2
+conflict = "key"
+strictness = "pending"
+clock_step = 4
+log_levels = "info,transport=debug"
+features = "-generic_delivery"
+codec = "msgpack"
+codecs = ["msgpack", "json"]
+
+[addresses]
+orders = "orders-exchange"
+"users" = 'users-exchange'
+
+[location]
+datacenter = "dc-a"
+zone = "1"
+
+[topology.users]
+datacenter = "dc-b"
+zone = "zone-2"
+
+[durability]
+policy = "batched"
+interval = "50ms"
+
+[dedup]
+capacity = 1_000
+false_positive = 0.01
+
+[retry]
+attempts = 3
+backoff = 1.5
+
+[membership]
+members = ["orders", "users"]
+check = "receive"
+
+[fanout]
+workers = 2
+partial = "all"
+
+[tree]
+threshold = 8
+`
+
+// The fields of the configuration loaded from the files.
+func declared(c *types.Configuration) []interface{} {
+	return []interface{}{
+		c.Name, c.Replication, c.Ordinal, c.Version, reflect.TypeOf(c.Conflict), c.Strictness,
+		c.BatchSize, c.Parallelism, c.ObserverTTL, c.Heartbeat, c.ClockStep, c.LogLevels, c.Codec, c.Codecs, c.SigningKey, c.Resolver, c.Location, c.Topology,
+		c.Broker, c.Durability, c.Dedup, c.Consumer, c.Retry, c.Breaker, c.Fanout, c.Tree, c.Membership, c.Features,
+	}
+}
+
+func TestConfig_YAMLAndTOMLEquivalent(t *testing.T) {
+	fromYAML, err := config.Decode([]byte(yamlConfiguration), config.YAML, nil)
+	if err != nil {
+		t.Fatalf("failed decoding yaml. %v", err)
+	}
+	fromTOML, err := config.Decode([]byte(tomlConfiguration), config.TOML, nil)
+	if err != nil {
+		t.Fatalf("failed decoding toml. %v", err)
+	}
+	if !reflect.DeepEqual(declared(fromYAML), declared(fromTOML)) {
+		t.Fatalf("expected same configuration, found\n%#v\n%#v", declared(fromYAML), declared(fromTOML))
+	}
+
+	expected := mcast.DefaultConfiguration("orders")
+	expected.Replication = 2
+	expected.Conflict = &definition.KeyConflict{}
+	expected.Strictness = types.ConflictPending
+	expected.ClockStep = 4
+	expected.LogLevels = map[string]types.LogLevel{"": types.LevelInfo, "transport": types.LevelDebug}
+	expected.Features = types.Features{types.FeatureGenericDelivery: false}
+	expected.Codec = definition.MsgpackCodec{}
+	expected.Codecs = []types.IdentifiedCodec{definition.MsgpackCodec{}, definition.JSONCodec{}}
+	expected.Resolver = definition.StaticResolver{"orders": "orders-exchange", "users": "users-exchange"}
+	expected.Location = types.Location{Datacenter: "dc-a", Zone: "1"}
+	expected.Topology = types.StaticTopology{"users": {Datacenter: "dc-b", Zone: "zone-2"}}
+	expected.Durability = types.Durability{Policy: types.SyncBatched, Interval: 50 * time.Millisecond}
+	expected.Dedup = types.DedupWindow{Capacity: 1000, FalsePositive: 0.01}
+	expected.Retry = types.RetryPolicy{Attempts: 3, Backoff: 1.5}
+	expected.Fanout = types.Fanout{Workers: 2, Partial: types.FailOnAll}
+	expected.Tree = types.TreeExchange{Threshold: 8}
+	expected.Membership = types.Membership{Members: types.StaticMembers{"orders", "users"}, Check: types.CheckOnReceive}
+	if !reflect.DeepEqual(declared(fromYAML), declared(expected)) {
+		t.Errorf("expected\n%#v\nfound\n%#v", declared(expected), declared(fromYAML))
+	}
+}
+
+func TestConfig_ReferenceFilesAreDefaults(t *testing.T) {
+	none := func(string) string { return "" }
+	fromYAML, err := config.LoadFrom("../pkg/mcast/config/reference.yaml", none)
+	if err != nil {
+		t.Fatalf("failed loading yaml reference. %v", err)
+	}
+	fromTOML, err := config.LoadFrom("../pkg/mcast/config/reference.toml", none)
+	if err != nil {
+		t.Fatalf("failed loading toml reference. %v", err)
+	}
+	if !reflect.DeepEqual(declared(fromYAML), declared(fromTOML)) {
+		t.Fatalf("expected same configuration, found\n%#v\n%#v", declared(fromYAML), declared(fromTOML))
+	}
+
+	expected := mcast.DefaultConfiguration("reference")
+	expected.LogLevels = map[string]types.LogLevel{}
+	expected.Features = types.Features{}
+	expected.Resolver = definition.StaticResolver{}
+	expected.Dedup = types.DedupWindow{
+		FalsePositive: types.DefaultDedupFalsePositive,
+		Rotation:      types.DefaultDedupRotation,
+		Persist:       types.DefaultDedupPersist,
+	}
+	if !reflect.DeepEqual(declared(fromYAML), declared(expected)) {
+		t.Errorf("expected reference with the defaults\n%#v\nfound\n%#v", declared(expected), declared(fromYAML))
+	}
+}
+
+func TestConfig_EnvironmentOverrides(t *testing.T) {
+	env := map[string]string{
+		"MCAST_REPLICATION":       "5",
+		"MCAST_RETRY_ATTEMPTS":    "1",
+		"MCAST_CODECS":            "cbor, json",
+		"MCAST_ADDRESSES":         "orders=other-exchange",
+		"MCAST_CONSUMER_STRATEGY": "spill",
+	}
+	lookup := func(key string) string { return env[key] }
+	c, err := config.Decode([]byte(yamlConfiguration), config.YAML, lookup)
+	if err != nil {
+		t.Fatalf("failed decoding. %v", err)
+	}
+	if c.Replication != 5 || c.Retry.Attempts != 1 || c.Retry.Backoff != 1.5 {
+		t.Errorf("expected values overridden, found %d replicas and %#v", c.Replication, c.Retry)
+	}
+	if !reflect.DeepEqual(c.Codecs, []types.IdentifiedCodec{definition.CBORCodec{}, definition.JSONCodec{}}) {
+		t.Errorf("expected codecs overridden, found %#v", c.Codecs)
+	}
+	if !reflect.DeepEqual(c.Resolver, definition.StaticResolver{"orders": "other-exchange"}) {
+		t.Errorf("expected addresses overridden, found %#v", c.Resolver)
+	}
+	if c.Consumer.Strategy != types.ConsumerSpill {
+		t.Errorf("expected table created from the environment, found %#v", c.Consumer)
+	}
+
+	env["MCAST_BATCH_SIZE"] = "many"
+	env["MCAST_STRICTNESS"] = "everything"
+	_, err = config.Decode([]byte(yamlConfiguration), config.YAML, lookup)
+	if !errors.Is(err, config.ErrInvalidConfiguration) ||
+		!strings.Contains(err.Error(), "MCAST_BATCH_SIZE") || !strings.Contains(err.Error(), "strictness") {
+		t.Errorf("expected both invalid variables reported, found %v", err)
+	}
+}
+
+func TestConfig_SchemaValidation(t *testing.T) {
+	document := `
+replication: 0
+conflict: sometimes
+batch_size: many
+unknown: true
+dedup:
+  false_positive: 2
+  window: 1m
+durability:
+  interval: soon
+`
+	_, err := config.Decode([]byte(document), config.YAML, nil)
+	if !errors.Is(err, config.ErrInvalidConfiguration) {
+		t.Fatalf("expected invalid configuration, found %v", err)
+	}
+	for _, problem := range []string{
+		"name: required",
+		"replication: must be at least 1",
+		"conflict: expected one of always, key",
+		"batch_size: expected an integer",
+		"unknown: unknown key",
+		"dedup.false_positive: must be between 0 and 1",
+		"dedup.window: unknown key",
+		"durability.interval: expected a duration",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q reported, found %v", problem, err)
+		}
+	}
+
+	if _, err := config.Decode([]byte("name: a\ndurability:\n  policy: batched\n"), config.YAML, nil); !errors.Is(err, config.ErrInvalidConfiguration) {
+		t.Errorf("expected batched policy without interval rejected, found %v", err)
+	}
+}
+
+func TestConfig_MalformedDocuments(t *testing.T) {
+	for name, document := range map[string]string{
+		"tab":         "location:\n\tzone: a\n",
+		"indentation": "name: a\n  zone: b\n",
+		"duplicated":  "name: a\nname: b\n",
+		"sequence":    "- name: a\n",
+	} {
+		if _, err := config.Decode([]byte(document), config.YAML, nil); !errors.Is(err, config.ErrYAMLInvalid) {
+			t.Errorf("expected %s rejected, found %v", name, err)
+		}
+	}
+
+	for name, document := range map[string]string{
+		"duplicated":   "name = \"a\"\nname = \"b\"\n",
+		"bare string":  "name = orders\n",
+		"unterminated": "[location\n",
+	} {
+		if _, err := config.Decode([]byte(document), config.TOML, nil); !errors.Is(err, config.ErrTOMLInvalid) {
+			t.Errorf("expected %s rejected, found %v", name, err)
+		}
+	}
+}
+
+// The documents are decoded by complete parsers, so anchors
+// and inline tables are read as any other value.
+func TestConfig_FullSyntax(t *testing.T) {
+	fromYAML, err := config.Decode([]byte("name: &name orders\nlocation: {datacenter: *name, zone: a}\n"), config.YAML, nil)
+	if err != nil {
+		t.Fatalf("failed decoding yaml. %v", err)
+	}
+	fromTOML, err := config.Decode([]byte("name = \"orders\"\nlocation = { datacenter = \"orders\", zone = \"a\" }\n"), config.TOML, nil)
+	if err != nil {
+		t.Fatalf("failed decoding toml. %v", err)
+	}
+
+	expected := types.Location{Datacenter: "orders", Zone: "a"}
+	if fromYAML.Location != expected || fromTOML.Location != expected {
+		t.Errorf("expected %#v, found %#v and %#v", expected, fromYAML.Location, fromTOML.Location)
+	}
+
+	if _, err := config.Decode([]byte("[[topology]]\ndatacenter = \"a\"\n"), config.TOML, nil); !errors.Is(err, config.ErrInvalidConfiguration) {
+		t.Errorf("expected array of tables rejected by the schema, found %v", err)
+	}
+}
+
+func TestConfig_LoadPeerFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("failed creating directory. %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "partition.yml")
+	wal := filepath.Join(dir, "partition.wal")
+	document := "name: orders\nordinal: 3\nstorage:\n  type: wal\n  path: " + wal + "\n"
+	if err := ioutil.WriteFile(path, []byte(document), 0600); err != nil {
+		t.Fatalf("failed writing file. %v", err)
+	}
+
+	peer, err := config.LoadPeer(path, 1)
+	if err != nil {
+		t.Fatalf("failed loading peer. %v", err)
+	}
+	if peer.Name != "orders-4" || peer.Partition != "orders" {
+		t.Errorf("expected peer orders-4, found %s on %s", peer.Name, peer.Partition)
+	}
+	if _, ok := peer.Storage.(*definition.WALStorage); !ok {
+		t.Errorf("expected wal storage, found %T", peer.Storage)
+	}
+
+	if _, err := config.Load(filepath.Join(dir, "partition.json")); !errors.Is(err, config.ErrUnknownFormat) {
+		t.Errorf("expected unknown format, found %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+)
+
+func keysMessage(key string, keys ...string) types.Message {
+	message := types.Message{Content: types.DataHolder{Key: []byte(key)}}
+	for _, k := range keys {
+		message.Content.Keys = append(message.Content.Keys, []byte(k))
+	}
+	return message
+}
+
+func TestKeyConflict_MultipleKeys(t *testing.T) {
+	transaction := keysMessage("account-a", "account-b", "account-a")
+	cases := []struct {
+		name     string
+		others   []types.Message
+		conflict bool
+	}{
+		{"single key write on declared key", []types.Message{keysMessage("account-b")}, true},
+		{"single key write on main key", []types.Message{keysMessage("account-a")}, true},
+		{"transactions sharing a key", []types.Message{keysMessage("account-c", "account-b")}, true},
+		{"disjoint keys", []types.Message{keysMessage("account-c", "account-d")}, false},
+		{"no other messages", nil, false},
+	}
+
+	for _, c := range cases {
+		if found := (definition.KeyConflict{}).Conflict(transaction, c.others); found != c.conflict {
+			t.Errorf("%s: expected %t, found %t", c.name, c.conflict, found)
+		}
+	}
+
+	if keys := transaction.Content.KeySet(); len(keys) != 2 {
+		t.Errorf("expected 2 unique keys, found %q", keys)
+	}
+}
@@ -0,0 +1,286 @@
+//go:build conformance
+// +build conformance
+
+package test
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A message committed by a peer.
+type conformanceDelivery struct {
+	uid       types.UID
+	timestamp uint64
+	at        time.Time
+}
+
+// What happened during a schedule: the messages cast with their
+// destinations and the messages each peer committed, in order.
+type conformanceHistory struct {
+	cast       map[types.UID][]types.Partition
+	peers      map[types.Partition][]string
+	deliveries map[string][]conformanceDelivery
+}
+
+// Build the history from the events recorded during the schedule.
+func newConformanceHistory(cluster *UnityCluster, cast map[types.UID][]types.Partition, recorder *types.EventRecorder) conformanceHistory {
+	h := conformanceHistory{
+		cast:       cast,
+		peers:      make(map[types.Partition][]string),
+		deliveries: make(map[string][]conformanceDelivery),
+	}
+	for i, name := range cluster.Names {
+		for j := 0; j < cluster.Unities[i].(*mcast.PeerUnity).Configuration.Replication; j++ {
+			h.peers[name] = append(h.peers[name], fmt.Sprintf("%s-%d", name, j))
+		}
+	}
+	for uid, events := range recorder.Dump() {
+		for _, event := range events {
+			if event.Kind == types.EventDelivered {
+				h.deliveries[event.Peer] = append(h.deliveries[event.Peer], conformanceDelivery{uid, event.Timestamp, event.Time})
+			}
+		}
+	}
+	for _, deliveries := range h.deliveries {
+		sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].at.Before(deliveries[j].at) })
+	}
+	return h
+}
+
+// A property of the algorithm verified against the history.
+type conformanceProperty struct {
+	name  string
+	check func(h conformanceHistory) error
+}
+
+var conformanceProperties = []conformanceProperty{
+	{
+		// Every peer commits a message at most once, and only
+		// if the message was cast to the peer partition.
+		name: "uniform integrity",
+		check: func(h conformanceHistory) error {
+			for peer, deliveries := range h.deliveries {
+				seen := make(map[types.UID]bool)
+				for _, d := range deliveries {
+					if seen[d.uid] {
+						return fmt.Errorf("%s committed %s twice", peer, d.uid)
+					}
+					seen[d.uid] = true
+					destination, ok := h.cast[d.uid]
+					if !ok {
+						return fmt.Errorf("%s committed %s that was never cast", peer, d.uid)
+					}
+					if !conformanceIsDestination(peer, destination) {
+						return fmt.Errorf("%s committed %s cast to %v", peer, d.uid, destination)
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name:  "uniform agreement",
+		check: conformanceAgreement,
+	},
+	{
+		// Every peer commits a message with the same final
+		// timestamp, the maximum of the partitions proposals.
+		name: "timestamp agreement",
+		check: func(h conformanceHistory) error {
+			timestamps := make(map[types.UID]uint64)
+			for peer, deliveries := range h.deliveries {
+				for _, d := range deliveries {
+					if ts, ok := timestamps[d.uid]; ok && ts != d.timestamp {
+						return fmt.Errorf("%s committed %s with timestamp %d, another peer with %d", peer, d.uid, d.timestamp, ts)
+					}
+					timestamps[d.uid] = d.timestamp
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Any two peers commit the conflicting messages they
+		// have in common in the same relative order. Every
+		// message conflicts on the schedules.
+		name: "partial order",
+		check: func(h conformanceHistory) error {
+			for p, first := range h.deliveries {
+				for q, second := range h.deliveries {
+					if p >= q {
+						continue
+					}
+					if err := conformanceSameOrder(first, second); err != nil {
+						return fmt.Errorf("%s and %s: %v", p, q, err)
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Conflicting messages are committed in the order of
+		// their final timestamps.
+		name: "timestamp order",
+		check: func(h conformanceHistory) error {
+			for peer, deliveries := range h.deliveries {
+				for i := 1; i < len(deliveries); i++ {
+					if deliveries[i].timestamp < deliveries[i-1].timestamp {
+						return fmt.Errorf("%s committed %s with timestamp %d after %s with %d", peer,
+							deliveries[i].uid, deliveries[i].timestamp, deliveries[i-1].uid, deliveries[i-1].timestamp)
+					}
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// Every message cast is committed by every peer of every
+// destination partition.
+func conformanceAgreement(h conformanceHistory) error {
+	for uid, destination := range h.cast {
+		for _, partition := range destination {
+			for _, peer := range h.peers[partition] {
+				if !conformanceCommitted(h.deliveries[peer], uid) {
+					return fmt.Errorf("%s did not commit %s", peer, uid)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func conformanceIsDestination(peer string, destination []types.Partition) bool {
+	for _, partition := range destination {
+		if strings.HasPrefix(peer, string(partition)+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+func conformanceCommitted(deliveries []conformanceDelivery, uid types.UID) bool {
+	for _, d := range deliveries {
+		if d.uid == uid {
+			return true
+		}
+	}
+	return false
+}
+
+func conformanceSameOrder(first, second []conformanceDelivery) error {
+	common := make(map[types.UID]bool)
+	for _, d := range second {
+		common[d.uid] = true
+	}
+	var order []types.UID
+	for _, d := range first {
+		if common[d.uid] {
+			order = append(order, d.uid)
+		}
+	}
+	i := 0
+	for _, d := range second {
+		if i < len(order) && d.uid == order[i] {
+			i++
+			continue
+		}
+		if conformanceCommitted(first, d.uid) {
+			return fmt.Errorf("%s committed in a different order", d.uid)
+		}
+	}
+	return nil
+}
+
+// Execute a random schedule derived from the seed: random latencies
+// on the broker, random destinations and random concurrency. Returns
+// the messages cast with their destinations.
+func runConformanceSchedule(t *testing.T, seed int64, cluster *UnityCluster, broker *FaultyBroker) map[types.UID][]types.Partition {
+	random := rand.New(rand.NewSource(seed))
+	broker.Latency(0, time.Duration(random.Intn(10)+1)*time.Millisecond)
+
+	mutex := &sync.Mutex{}
+	cast := make(map[types.UID][]types.Partition)
+	writers := random.Intn(3) + 1
+	requests := make(chan []int, 30)
+	for i := 0; i < cap(requests); i++ {
+		// At least two destinations, the first one issues the write.
+		requests <- random.Perm(len(cluster.Names))[:random.Intn(len(cluster.Names)-1)+2]
+	}
+	close(requests)
+
+	group := &sync.WaitGroup{}
+	for i := 0; i < writers; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for indexes := range requests {
+				var destination []types.Partition
+				for _, index := range indexes {
+					destination = append(destination, cluster.Names[index])
+				}
+				req := GenerateRandomRequestValue([]byte("conformance"), destination)
+				select {
+				case res := <-cluster.Unities[indexes[0]].Write(req):
+					if !res.Success {
+						t.Errorf("seed %d failed writing. %v", seed, res.Failure)
+						continue
+					}
+					mutex.Lock()
+					cast[res.Identifier] = destination
+					mutex.Unlock()
+				case <-time.After(10 * time.Second):
+					t.Errorf("seed %d write timeout to %v", seed, destination)
+					return
+				}
+			}
+		}()
+	}
+	group.Wait()
+	return cast
+}
+
+// Check the properties of the generic multicast algorithm against
+// the history of random schedules, executed only with the
+// conformance tag. A failing schedule is reproduced with its seed.
+func TestConformance_RandomSchedules(t *testing.T) {
+	for _, seed := range []int64{1, 2, 3, time.Now().UnixNano()} {
+		seed := seed
+		t.Run(fmt.Sprintf("seed-%d", seed), func(t *testing.T) {
+			recorder := types.NewEventRecorder(256, 1024)
+			broker := NewFaultyBroker(core.NewMemoryBroker())
+			cluster := CreateClusterWith(3, "conformance", t, func(conf *types.Configuration) {
+				conf.Broker = broker
+				conf.Recorder = recorder
+			})
+			defer cluster.Off()
+
+			cast := runConformanceSchedule(t, seed, cluster, broker)
+			var history conformanceHistory
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				history = newConformanceHistory(cluster, cast, recorder)
+				if conformanceAgreement(history) == nil || time.Now().After(deadline) {
+					break
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			for _, property := range conformanceProperties {
+				if err := property.check(history); err != nil {
+					t.Errorf("seed %d violates %s: %v", seed, property.name, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+)
+
+// A relationship where messages never conflict.
+type neverConflict struct{}
+
+func (n neverConflict) Conflict(types.Message, []types.Message) bool {
+	return false
+}
+
+func consistencyMessage(level types.ConsistencyLevel) types.Message {
+	return types.Message{Header: types.ProtocolHeader{Consistency: level}}
+}
+
+func TestConsistentConflict_Levels(t *testing.T) {
+	generic := consistencyMessage(types.ConsistencyGeneric)
+	total := consistencyMessage(types.ConsistencyTotalOrder)
+	local := consistencyMessage(types.ConsistencyLocal)
+	cases := []struct {
+		name     string
+		relation types.ConflictRelationship
+		message  types.Message
+		others   []types.Message
+		conflict bool
+	}{
+		{"generic follows relationship", neverConflict{}, generic, []types.Message{generic}, false},
+		{"total order always conflicts", neverConflict{}, total, []types.Message{generic}, true},
+		{"generic conflicts with pending total order", neverConflict{}, generic, []types.Message{total}, true},
+		{"local never conflicts", definition.AlwaysConflict{}, local, []types.Message{generic}, false},
+		{"local conflicts with total order", definition.AlwaysConflict{}, local, []types.Message{total}, true},
+		{"generic ignores local", neverConflict{}, generic, []types.Message{local}, false},
+	}
+
+	for _, c := range cases {
+		conflict := core.ConsistentConflict{ConflictRelationship: c.relation}
+		if found := conflict.Conflict(c.message, c.others); found != c.conflict {
+			t.Errorf("%s: expected %t, found %t", c.name, c.conflict, found)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// Records the fatal entries instead of exiting.
+type fatalRecorder struct {
+	*definition.DefaultLogger
+	fatal chan string
+}
+
+func (f *fatalRecorder) Fatalf(format string, v ...interface{}) {
+	f.fatal <- fmt.Sprintf(format, v...)
+}
+
+func slowTransport(t *testing.T, name string, consumer types.SlowConsumer, log types.Logger) (core.Transport, *types.ErrorReporter) {
+	reporter := types.NewErrorReporter(types.DefaultErrorBuffer)
+	peer := &types.PeerConfiguration{
+		Name:      name,
+		Partition: types.Partition(name),
+		Version:   types.LatestProtocolVersion,
+		Errors:    reporter,
+		Consumer:  consumer,
+	}
+	timeout := 10 * time.Millisecond
+	transport, err := core.NewTransport(peer, core.NewRTTEstimatorBounded(timeout, timeout, timeout), log)
+	if err != nil {
+		t.Fatalf("failed creating transport. %v", err)
+	}
+	return transport, reporter
+}
+
+// Send the messages to the partition, which are late
+// until the listener consumes them.
+func sendLate(t *testing.T, transport core.Transport, partition types.Partition, n int) []types.UID {
+	var uids []types.UID
+	for i := 0; i < n; i++ {
+		message := codecMessage()
+		message.Identifier = types.UID(helper.GenerateUID())
+		message.Destination = []types.Partition{partition}
+		if err := transport.Unicast(message, partition); err != nil {
+			t.Fatalf("failed sending. %v", err)
+		}
+		uids = append(uids, message.Identifier)
+	}
+	return uids
+}
+
+func consumeInOrder(t *testing.T, transport core.Transport, uids []types.UID) {
+	for _, uid := range uids {
+		select {
+		case m := <-transport.Listen():
+			if m.Identifier != uid {
+				t.Fatalf("expected %s, found %s", uid, m.Identifier)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %s not received", uid)
+		}
+	}
+}
+
+func awaitConsumerFailure(t *testing.T, reporter *types.ErrorReporter) {
+	select {
+	case err := <-reporter.Errors():
+		var async *types.AsyncError
+		if !errors.As(err, &async) || async.Kind != types.ConsumerFailure {
+			t.Errorf("expected consumer failure, found %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("slow consumer not reported")
+	}
+}
+
+func TestSlowConsumer_BlockWithoutDropping(t *testing.T) {
+	sender, _ := slowTransport(t, "block-sender", types.SlowConsumer{}, definition.NewDefaultLogger())
+	defer sender.Close()
+	receiver, reporter := slowTransport(t, "block-receiver", types.SlowConsumer{}, definition.NewDefaultLogger())
+	defer receiver.Close()
+
+	uids := sendLate(t, sender, "block-receiver", 3)
+	awaitConsumerFailure(t, reporter)
+	time.Sleep(50 * time.Millisecond)
+	consumeInOrder(t, receiver, uids)
+
+	metrics := receiver.(core.ConsumerObserver).Consumer()
+	if metrics.Slow == 0 || metrics.Blocked == 0 || metrics.Spilled != 0 {
+		t.Errorf("wrong metrics %#v", metrics)
+	}
+}
+
+func TestSlowConsumer_SpillToDisk(t *testing.T) {
+	directory, err := ioutil.TempDir("", "spill")
+	if err != nil {
+		t.Fatalf("failed creating directory. %v", err)
+	}
+	consumer := types.SlowConsumer{Strategy: types.ConsumerSpill, Directory: directory}
+	sender, _ := slowTransport(t, "spill-sender", types.SlowConsumer{}, definition.NewDefaultLogger())
+	defer sender.Close()
+	receiver, reporter := slowTransport(t, "spill-receiver", consumer, definition.NewDefaultLogger())
+
+	uids := sendLate(t, sender, "spill-receiver", 10)
+	awaitConsumerFailure(t, reporter)
+	observer := receiver.(core.ConsumerObserver)
+	for start := time.Now(); observer.Consumer().Spilled < 9; {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("messages not spilled %#v", observer.Consumer())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if pending := observer.Consumer().Pending; pending == 0 {
+		t.Errorf("expected spilled messages pending")
+	}
+	consumeInOrder(t, receiver, uids)
+
+	// The entry is removed from the spill once published.
+	for start := time.Now(); observer.Consumer().Pending > 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("spilled messages still pending %#v", observer.Consumer())
+		}
+	}
+
+	if metrics := observer.Consumer(); metrics.Blocked != 0 {
+		t.Errorf("wrong metrics after consuming %#v", metrics)
+	}
+
+	receiver.Close()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		files, err := ioutil.ReadDir(directory)
+		if err != nil {
+			t.Fatalf("failed reading directory. %v", err)
+		}
+		if len(files) == 0 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("spill file not removed")
+		}
+	}
+}
+
+func TestSlowConsumer_Crash(t *testing.T) {
+	logger := &fatalRecorder{DefaultLogger: definition.NewDefaultLogger(), fatal: make(chan string, 1)}
+	sender, _ := slowTransport(t, "crash-sender", types.SlowConsumer{}, definition.NewDefaultLogger())
+	defer sender.Close()
+	receiver, _ := slowTransport(t, "crash-receiver", types.SlowConsumer{Strategy: types.ConsumerCrash}, logger)
+	defer receiver.Close()
+
+	uids := sendLate(t, sender, "crash-receiver", 1)
+	select {
+	case <-logger.fatal:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("transport did not crash")
+	}
+
+	// When the logger does not exit, the message is still not dropped.
+	consumeInOrder(t, receiver, uids)
+}
+
+// Without credits the transport leaves the messages on the broker,
+// and receives the next one once the listener grants a credit.
+func TestConsumer_CreditsHoldMessages(t *testing.T) {
+	name := "credits-" + helper.GenerateUID()
+	peer := &types.PeerConfiguration{
+		Name:      name,
+		Partition: types.Partition(name),
+		Version:   types.LatestProtocolVersion,
+		Errors:    types.NewErrorReporter(types.DefaultErrorBuffer),
+		Credits:   1,
+	}
+	transport, err := core.NewTransport(peer, core.NewRTTEstimator(), definition.NewDefaultLogger())
+	if err != nil {
+		t.Fatalf("failed creating transport. %v", err)
+	}
+	defer transport.Close()
+
+	uids := sendLate(t, transport, peer.Partition, 3)
+	consumeInOrder(t, transport, uids[:1])
+	select {
+	case m := <-transport.Listen():
+		t.Fatalf("received %s without credits", m.Identifier)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for _, uid := range uids[1:] {
+		transport.(core.FlowControl).Grant(1)
+		consumeInOrder(t, transport, []types.UID{uid})
+	}
+
+	metrics := transport.(core.ConsumerObserver).Consumer()
+	if metrics.Slow != 0 || metrics.Throttled == 0 {
+		t.Errorf("expected throttled without slow messages, found %#v", metrics)
+	}
+}
+
+func TestConsumer_PeerGrantsCredits(t *testing.T) {
+	partitionName := types.Partition("credits-unity-" + helper.GenerateUID())
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Credits = 2
+	conf.Logger.ToggleDebug(false)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	for i := 0; i < 10; i++ {
+		select {
+		case res := <-unity.Write(GenerateRequest([]byte("credits"), []byte(fmt.Sprint(i)), []types.Partition{partitionName})):
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("write %d timeout", i)
+		}
+	}
+}
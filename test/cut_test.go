@@ -0,0 +1,102 @@
+package test
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCut_ConsistentAcrossPartitions(t *testing.T) {
+	mutex := &sync.Mutex{}
+	cuts := make(map[types.Partition]types.Cut)
+	var cleanup []func()
+	defer func() {
+		for _, clean := range cleanup {
+			clean()
+		}
+	}()
+	cluster := CreateClusterWith(2, "cut", t, func(conf *types.Configuration) {
+		// The cut is read from the history of the state machine,
+		// a single replica, since the replicas share the storage.
+		conf.Replication = 1
+		path, clean := walPath(t)
+		storage := openWAL(t, path, definition.NewInMemoryStorage())
+		cleanup = append(cleanup, func() {
+			storage.Close()
+			clean()
+		})
+		conf.Storage = storage
+		conf.OnCut = func(cut types.Cut) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			cuts[cut.Partition] = cut
+		}
+	})
+	defer cluster.Off()
+
+	// Writes to both partitions race with the cut, each one must
+	// be on the cut of both partitions or on none of them.
+	var writes []<-chan types.Response
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			key := []byte(fmt.Sprintf("cut-%d", i))
+			writes = append(writes, cluster.Unities[i%2].Write(GenerateRequest(key, []byte("value"), cluster.Names)))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	time.Sleep(5 * time.Millisecond)
+	cut, err := cluster.Unities[0].Cut(cluster.Names[1:])
+	if err != nil {
+		t.Fatalf("failed cutting. %v", err)
+	}
+	<-done
+	for _, res := range writes {
+		if r := awaitResponse(t, res); !r.Success {
+			t.Fatalf("failed writing. %v", r.Failure)
+		}
+	}
+
+	if cut.Partition != cluster.Names[0] || cut.Timestamp == 0 {
+		t.Errorf("unexpected cut %#v", cut)
+	}
+	if !WaitThisOrTimeout(func() {
+		for {
+			mutex.Lock()
+			n := len(cuts)
+			mutex.Unlock()
+			if n == 2 {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}, 5*time.Second) {
+		t.Fatalf("cut not delivered on every partition, found %v", cuts)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	included := func(c types.Cut) map[types.UID]bool {
+		entries := make(map[types.UID]bool)
+		for _, entry := range c.Entries {
+			entries[entry.Identifier] = true
+		}
+		return entries
+	}
+	first, second := included(cuts[cluster.Names[0]]), included(cuts[cluster.Names[1]])
+	if len(first) != len(second) {
+		t.Errorf("expected same entries, found %d and %d", len(first), len(second))
+	}
+	for uid := range first {
+		if !second[uid] {
+			t.Errorf("%s on the cut of %s only", uid, cluster.Names[0])
+		}
+	}
+	if cuts[cluster.Names[0]].Identifier != cut.Identifier || cuts[cluster.Names[1]].Identifier != cut.Identifier {
+		t.Errorf("expected the same marker on both partitions, found %v", cuts)
+	}
+}
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"context"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func encodedBatch(tb testing.TB, codec types.Codec, n int) ([][]byte, []types.UID) {
+	var frames [][]byte
+	var uids []types.UID
+	for i := 0; i < n; i++ {
+		message := codecMessage()
+		message.Identifier = types.UID(helper.GenerateUID())
+		data, err := core.EncodeFrame(codec, message)
+		if err != nil {
+			tb.Fatalf("failed encoding. %v", err)
+		}
+		frames = append(frames, data)
+		uids = append(uids, message.Identifier)
+	}
+	return frames, uids
+}
+
+func TestDecodePool_KeepBatchOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	codec := definition.JSONCodec{}
+	pool := core.NewDecodePool(ctx, codec, 4)
+
+	frames, uids := encodedBatch(t, codec, core.ReceiveBatch)
+	frames[3] = nil
+	frames[5] = []byte{0xfe, 0x01}
+	results := pool.Decode(frames)
+	for i, result := range results {
+		switch i {
+		case 3:
+			if result.Err != nil || result.Message.Identifier != "" {
+				t.Errorf("nil frame should be empty, found %#v", result)
+			}
+		case 5:
+			if result.Err == nil {
+				t.Errorf("invalid frame should fail")
+			}
+		default:
+			if result.Err != nil || result.Message.Identifier != uids[i] {
+				t.Errorf("expected %s at %d, found %#v", uids[i], i, result)
+			}
+		}
+	}
+
+	// After closing the pool the frames are still decoded.
+	cancel()
+	results = pool.Decode(frames[:2])
+	if results[1].Message.Identifier != uids[1] {
+		t.Errorf("expected %s after closing, found %#v", uids[1], results[1])
+	}
+}
+
+func benchmarkDecode(b *testing.B, workers int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	codec := definition.JSONCodec{}
+	pool := core.NewDecodePool(ctx, codec, workers)
+	frames, _ := encodedBatch(b, codec, core.ReceiveBatch)
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if workers == 1 {
+			for _, frame := range frames {
+				core.DecodeFrame(codec, frame)
+			}
+			continue
+		}
+		pool.Decode(frames)
+	}
+	b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(b.N*len(frames)), "ns/msg")
+}
+
+// Decoding each message of a burst one at a time, as done
+// before the receive path was batched.
+func BenchmarkDecode_Sequential(b *testing.B) {
+	benchmarkDecode(b, 1)
+}
+
+// Decoding a burst on the worker pool.
+func BenchmarkDecode_Pool(b *testing.B) {
+	benchmarkDecode(b, 0)
+}
+
+// Bursts of messages through the transport, measuring the time
+// until the listener receives the whole burst.
+func BenchmarkTransport_BurstReceive(b *testing.B) {
+	sender, err := reliableTransport("burst-sender", "burst-sender")
+	if err != nil {
+		b.Fatalf("failed creating transport. %v", err)
+	}
+	defer sender.Close()
+	receiver, err := reliableTransport("burst-receiver", "burst-receiver")
+	if err != nil {
+		b.Fatalf("failed creating transport. %v", err)
+	}
+	defer receiver.Close()
+
+	message := codecMessage()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < core.ReceiveBatch; j++ {
+			if err := sender.Unicast(message, "burst-receiver"); err != nil {
+				b.Fatalf("failed sending. %v", err)
+			}
+		}
+		for j := 0; j < core.ReceiveBatch; j++ {
+			select {
+			case <-receiver.Listen():
+			case <-time.After(5 * time.Second):
+				b.Fatalf("burst not received")
+			}
+		}
+	}
+	b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(b.N*core.ReceiveBatch), "ns/msg")
+}
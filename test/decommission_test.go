@@ -0,0 +1,71 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func TestDecommission_PendingRequestCompletesWithoutPartition(t *testing.T) {
+	broker := NewFaultyBroker(core.NewMemoryBroker())
+	cluster := CreateClusterWith(3, "decommission", t, func(conf *types.Configuration) {
+		conf.Broker = broker
+	})
+	defer cluster.Off()
+
+	retired := cluster.Names[2]
+	remaining := cluster.Names[:2]
+
+	// The request never reaches the decommissioned partition, so
+	// the others wait for its timestamp.
+	broker.Sever(retired, remaining[0])
+	broker.Sever(retired, remaining[1])
+	key := []byte("decommission")
+	obs := cluster.Unities[0].Write(GenerateRequest(key, []byte("value"), cluster.Names))
+	select {
+	case res := <-obs:
+		t.Fatalf("expected request waiting for the partition, found %#v", res)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := cluster.Unities[2].Decommission(remaining, 5*time.Second); err != nil {
+		t.Fatalf("failed decommissioning. %v", err)
+	}
+	if res := <-cluster.Unities[2].Write(GenerateRequest(key, []byte("other"), cluster.Names)); !errors.Is(res.Failure, core.ErrDecommissioned) {
+		t.Errorf("expected write rejected by the decommissioned partition, found %#v", res)
+	}
+
+	// The final marker is held with the request, the request is
+	// rejected by the decommissioned partition and completed
+	// by the others after the marker.
+	broker.Heal()
+	select {
+	case res := <-obs:
+		if !res.Success {
+			t.Fatalf("failed writing. %v", res.Failure)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("request not completed after the final marker")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	for i, unity := range cluster.Unities {
+		res, err := unity.Read(GenerateRequest(key, nil, cluster.Names))
+		if i < len(remaining) && (err != nil || string(res.Data) != "value") {
+			t.Errorf("expected value on %s, found %s. %v", cluster.Names[i], res.Data, err)
+		}
+		if i == len(remaining) && err == nil {
+			t.Errorf("expected no value on the decommissioned partition, found %s", res.Data)
+		}
+	}
+
+	res := <-cluster.Unities[0].Write(GenerateRequest(key, []byte("after"), cluster.Names))
+	if !errors.Is(res.Failure, core.ErrDecommissioned) {
+		t.Errorf("expected decommissioned partition rejected as destination, found %#v", res)
+	}
+	if res := <-cluster.Unities[0].Write(GenerateRequest(key, []byte("after"), remaining)); !res.Success {
+		t.Errorf("failed writing to the remaining partitions. %v", res.Failure)
+	}
+}
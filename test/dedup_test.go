@@ -0,0 +1,95 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func TestBloomFilter_FalsePositiveRate(t *testing.T) {
+	filter := core.NewBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		filter.Add(fmt.Sprintf("added-%d", i))
+	}
+
+	for i := 0; i < 1000; i++ {
+		if !filter.Contains(fmt.Sprintf("added-%d", i)) {
+			t.Fatalf("expected added-%d on the filter", i)
+		}
+	}
+
+	positives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.Contains(fmt.Sprintf("other-%d", i)) {
+			positives++
+		}
+	}
+
+	if rate := float64(positives) / 10000; rate > 0.02 {
+		t.Errorf("false positive rate %f greater than expected", rate)
+	}
+}
+
+func TestRotatingBloom_RememberAcrossRestart(t *testing.T) {
+	storage := definition.NewInMemoryStorage()
+	window := types.DedupWindow{Capacity: 100, Rotation: time.Hour, Persist: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	bloom := core.NewRotatingBloom(ctx, window, storage, "peer", definition.NewDefaultLogger())
+	bloom.Set("delivered")
+	bloom.Persist()
+	cancel()
+
+	restarted := core.NewRotatingBloom(context.Background(), window, storage, "peer", definition.NewDefaultLogger())
+	if !restarted.Contains("delivered") {
+		t.Errorf("expected delivered message remembered after restart")
+	}
+
+	other := core.NewRotatingBloom(context.Background(), window, storage, "other", definition.NewDefaultLogger())
+	if other.Contains("delivered") {
+		t.Errorf("expected peers with independent windows")
+	}
+}
+
+func TestRotatingBloom_ForgetAfterRotations(t *testing.T) {
+	window := types.DedupWindow{Capacity: 100, Rotation: 20 * time.Millisecond, Persist: 5 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bloom := core.NewRotatingBloom(ctx, window, definition.NewInMemoryStorage(), "peer", definition.NewDefaultLogger())
+	bloom.Set("delivered")
+
+	if !WaitThisOrTimeout(func() {
+		for bloom.Contains("delivered") {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}, time.Second) {
+		t.Errorf("expected message forgotten after two rotations")
+	}
+}
+
+func TestUnity_DeliverWithDedupWindow(t *testing.T) {
+	partitionName := types.Partition("dedup-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Dedup = types.DedupWindow{Capacity: 1000}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	for i := 0; i < 5; i++ {
+		select {
+		case res := <-unity.Write(GenerateRandomRequest([]types.Partition{partitionName})):
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"reflect"
+	"testing"
+)
+
+func destinations() mcast.Destinations {
+	return mcast.Destinations{
+		Topology: types.StaticTopology{
+			"eu-a": {Datacenter: "eu", Zone: "a"},
+			"eu-b": {Datacenter: "eu", Zone: "b"},
+			"us-a": {Datacenter: "us", Zone: "a"},
+			"us-b": {Datacenter: "us", Zone: "b"},
+		},
+		Location: types.Location{Datacenter: "us", Zone: "b"},
+	}
+}
+
+func TestDestinations_AllAndNearest(t *testing.T) {
+	d := destinations()
+	if all := d.All(); !reflect.DeepEqual(all, []types.Partition{"eu-a", "eu-b", "us-a", "us-b"}) {
+		t.Errorf("expected every partition sorted, found %v", all)
+	}
+	if nearest := d.Nearest(3); !reflect.DeepEqual(nearest, []types.Partition{"us-b", "us-a", "eu-a"}) {
+		t.Errorf("expected the zone, the datacenter and then the others, found %v", nearest)
+	}
+	if nearest := d.Nearest(10); len(nearest) != 4 {
+		t.Errorf("expected at most every partition, found %v", nearest)
+	}
+	if nearest := d.Nearest(-1); len(nearest) != 0 {
+		t.Errorf("expected no partition, found %v", nearest)
+	}
+}
+
+func TestDestinations_OwnersStable(t *testing.T) {
+	d := destinations()
+	owners := d.Owners([]byte("key"), 2)
+	if len(owners) != 2 || owners[0] == owners[1] {
+		t.Fatalf("expected two distinct owners, found %v", owners)
+	}
+	if again := d.Owners([]byte("key"), 2); !reflect.DeepEqual(owners, again) {
+		t.Errorf("expected the same owners, found %v and %v", owners, again)
+	}
+
+	// Adding a partition only moves the keys to the new partition.
+	grown := destinations()
+	grown.Topology["ap-a"] = types.Location{Datacenter: "ap", Zone: "a"}
+	for i := 0; i < 200; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		before, after := d.Owners(key, 1)[0], grown.Owners(key, 1)[0]
+		if before != after && after != "ap-a" {
+			t.Fatalf("key %s moved from %s to %s", key, before, after)
+		}
+	}
+}
+
+func TestDestinations_OwnersWeighted(t *testing.T) {
+	d := destinations()
+	d.Weights = map[types.Partition]float64{"eu-a": 3, "us-b": 0}
+	owned := make(map[types.Partition]int)
+	for i := 0; i < 6000; i++ {
+		owned[d.Owners([]byte(fmt.Sprintf("key-%d", i)), 1)[0]]++
+	}
+
+	if owned["us-b"] != 0 {
+		t.Errorf("expected no key on a partition without weight, found %d", owned["us-b"])
+	}
+	// The weights are 3, 1 and 1, so eu-a owns about 3600 keys.
+	if owned["eu-a"] < 3200 || owned["eu-a"] > 4000 {
+		t.Errorf("expected the keys proportional to the weight, found %v", owned)
+	}
+	if owners := d.Owners([]byte("key"), 10); len(owners) != 3 {
+		t.Errorf("expected only the partitions with weight, found %v", owners)
+	}
+}
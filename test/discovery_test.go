@@ -0,0 +1,176 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/discovery"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryRegistry struct {
+	mutex   *sync.Mutex
+	members map[string]discovery.Member
+}
+
+func newMemoryRegistry() *memoryRegistry {
+	return &memoryRegistry{mutex: &sync.Mutex{}, members: make(map[string]discovery.Member)}
+}
+
+func (m *memoryRegistry) Register(member discovery.Member) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.members[member.Name] = member
+	return nil
+}
+
+func (m *memoryRegistry) Deregister(member discovery.Member) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.members, member.Name)
+	return nil
+}
+
+func (m *memoryRegistry) Members() ([]discovery.Member, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var members []discovery.Member
+	for _, member := range m.members {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func TestDiscovery_ResolveAndWatchChanges(t *testing.T) {
+	registry := newMemoryRegistry()
+	_ = registry.Register(discovery.Member{Name: "b", Partition: "partition", Address: "exchange-b"})
+	_ = registry.Register(discovery.Member{Name: "a", Partition: "partition", Address: "exchange-a"})
+
+	d, err := discovery.NewDiscovery(registry, 10*time.Millisecond, definition.NewDefaultLogger())
+	if err != nil {
+		t.Fatalf("failed creating discovery. %v", err)
+	}
+	defer d.Close()
+
+	address, err := d.Resolve("partition")
+	if err != nil || address != "exchange-a" {
+		t.Fatalf("expected exchange-a, found %s. %v", address, err)
+	}
+
+	if _, err := d.Resolve("unknown"); !errors.Is(err, discovery.ErrNoMember) {
+		t.Errorf("expected no member, found %v", err)
+	}
+
+	changed := make(chan discovery.Membership, 1)
+	d.Subscribe(func(membership discovery.Membership) {
+		changed <- membership
+	})
+
+	_ = registry.Deregister(discovery.Member{Name: "a"})
+	select {
+	case membership := <-changed:
+		if len(membership["partition"]) != 1 {
+			t.Errorf("expected 1 member, found %v", membership)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("membership change not notified")
+	}
+
+	address, err = d.Resolve("partition")
+	if err != nil || address != "exchange-b" {
+		t.Errorf("expected exchange-b, found %s. %v", address, err)
+	}
+}
+
+func TestDiscovery_ConsulRegistry(t *testing.T) {
+	mutex := &sync.Mutex{}
+	var registered map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch {
+		case r.URL.Path == "/v1/agent/service/register":
+			_ = json.NewDecoder(r.Body).Decode(&registered)
+		case strings.HasPrefix(r.URL.Path, "/v1/agent/check/pass/"):
+		case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+			registered = nil
+		case r.URL.Path == "/v1/health/service/mcast":
+			var entries []interface{}
+			if registered != nil {
+				entries = append(entries, map[string]interface{}{
+					"Service": map[string]interface{}{"ID": registered["ID"], "Meta": registered["Meta"]},
+				})
+			}
+			_ = json.NewEncoder(w).Encode(entries)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry := discovery.ConsulRegistry{Agent: server.URL, Service: "mcast", TTL: "10s"}
+	member := discovery.Member{Name: "peer-1", Partition: "partition", Address: "exchange-1"}
+	if err := registry.Register(member); err != nil {
+		t.Fatalf("failed registering. %v", err)
+	}
+
+	members, err := registry.Members()
+	if err != nil || len(members) != 1 || members[0] != member {
+		t.Fatalf("expected %v, found %v. %v", member, members, err)
+	}
+
+	if err := registry.Deregister(member); err != nil {
+		t.Fatalf("failed deregistering. %v", err)
+	}
+
+	members, err = registry.Members()
+	if err != nil || len(members) != 0 {
+		t.Errorf("expected no members, found %v. %v", members, err)
+	}
+}
+
+// Counts the epochs advanced.
+type countingAdvancer struct {
+	advanced chan struct{}
+}
+
+func (c *countingAdvancer) AdvanceEpoch() error {
+	c.advanced <- struct{}{}
+	return nil
+}
+
+func TestDiscovery_AdvanceEpochOnChange(t *testing.T) {
+	registry := newMemoryRegistry()
+	_ = registry.Register(discovery.Member{Name: "a", Partition: "partition", Address: "exchange-a"})
+	_ = registry.Register(discovery.Member{Name: "other", Partition: "other", Address: "exchange-other"})
+
+	d, err := discovery.NewDiscovery(registry, time.Hour, definition.NewDefaultLogger())
+	if err != nil {
+		t.Fatalf("failed creating discovery. %v", err)
+	}
+	defer d.Close()
+
+	advancer := &countingAdvancer{advanced: make(chan struct{}, 10)}
+	d.AdvanceOnChange("partition", advancer)
+
+	_ = registry.Register(discovery.Member{Name: "other-1", Partition: "other", Address: "exchange-other"})
+	if err := d.Refresh(); err != nil {
+		t.Fatalf("failed refreshing. %v", err)
+	}
+	if len(advancer.advanced) != 0 {
+		t.Fatalf("expected epoch kept when other partition changes")
+	}
+
+	_ = registry.Register(discovery.Member{Name: "b", Partition: "partition", Address: "exchange-a"})
+	if err := d.Refresh(); err != nil {
+		t.Fatalf("failed refreshing. %v", err)
+	}
+	if len(advancer.advanced) != 1 {
+		t.Errorf("expected epoch advanced once, found %d", len(advancer.advanced))
+	}
+}
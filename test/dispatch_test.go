@@ -0,0 +1,146 @@
+package test
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func dispatched(uid string, key string) types.Delivered {
+	return types.Delivered{
+		Message: types.Message{
+			Identifier: types.UID(uid),
+			Content:    types.DataHolder{Key: []byte(key)},
+		},
+	}
+}
+
+func TestDispatcher_SameKeyInDeliveryOrder(t *testing.T) {
+	keys := []string{"a", "b", "c"}
+	mutex := &sync.Mutex{}
+	order := make(map[string][]string)
+	running := make(map[string]*int32)
+	for _, key := range keys {
+		running[key] = new(int32)
+	}
+	group := &sync.WaitGroup{}
+
+	// The executor runs every task on a new goroutine, in any order.
+	executor := types.ExecutorFunc(func(task func()) {
+		go task()
+	})
+	dispatcher := core.NewDispatcher(executor, func(d types.Delivered) {
+		defer group.Done()
+		key := string(d.Message.Content.Key)
+		if atomic.AddInt32(running[key], 1) > 1 {
+			t.Errorf("concurrent callbacks for key %s", key)
+		}
+		time.Sleep(time.Millisecond)
+		mutex.Lock()
+		order[key] = append(order[key], string(d.Message.Identifier))
+		mutex.Unlock()
+		atomic.AddInt32(running[key], -1)
+	})
+
+	messages := 60
+	group.Add(messages)
+	for i := 0; i < messages; i++ {
+		delivered := dispatched(fmt.Sprintf("%02d", i), keys[i%len(keys)])
+		// Every peer of the unity dispatches the same message.
+		dispatcher.Dispatch(delivered)
+		dispatcher.Dispatch(delivered)
+	}
+
+	if !WaitThisOrTimeout(group.Wait, 5*time.Second) {
+		t.Fatalf("callbacks did not finish")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for i, key := range keys {
+		if len(order[key]) != messages/len(keys) {
+			t.Fatalf("expected %d callbacks for key %s, found %v", messages/len(keys), key, order[key])
+		}
+		for j, uid := range order[key] {
+			if expected := fmt.Sprintf("%02d", i+j*len(keys)); uid != expected {
+				t.Errorf("expected %s at position %d of key %s, found %s", expected, j, key, uid)
+			}
+		}
+	}
+}
+
+func TestDispatcher_FailingCallbackKeepsDelivering(t *testing.T) {
+	var calls []string
+	dispatcher := core.NewDispatcher(nil, func(d types.Delivered) {
+		calls = append(calls, string(d.Message.Identifier))
+		if d.Message.Identifier == "first" {
+			panic("callback failure")
+		}
+	})
+
+	dispatcher.Dispatch(dispatched("first", "key"))
+	dispatcher.Dispatch(dispatched("second", "key"))
+	if len(calls) != 2 || calls[1] != "second" {
+		t.Errorf("expected both callbacks on the caller goroutine, found %v", calls)
+	}
+}
+
+func TestUnity_DeliverCallbackOncePerMessage(t *testing.T) {
+	partitionName := types.Partition("deliver-callback")
+	pool := core.NewWorkerPool(4)
+	defer pool.Close()
+
+	mutex := &sync.Mutex{}
+	calls := make(map[types.UID]int)
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Executor = pool
+	conf.OnDeliver = func(d types.Delivered) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !d.Response.Success {
+			t.Errorf("expected committed message, found %#v", d.Response)
+		}
+		calls[d.Message.Identifier]++
+	}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	var observers []<-chan types.Response
+	for i := 0; i < 10; i++ {
+		observers = append(observers, unity.Write(GenerateRandomRequest([]types.Partition{partitionName})))
+	}
+	var written []types.UID
+	for _, obs := range observers {
+		select {
+		case res := <-obs:
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+			written = append(written, res.Identifier)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+
+	// Wait for every peer to commit, so duplicates would be seen.
+	time.Sleep(500 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(calls) != len(written) {
+		t.Errorf("expected %d callbacks, found %d", len(written), len(calls))
+	}
+	for _, uid := range written {
+		if calls[uid] != 1 {
+			t.Errorf("expected a single callback for %s, found %d", uid, calls[uid])
+		}
+	}
+}
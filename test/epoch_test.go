@@ -0,0 +1,142 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func TestEpochTransport_FenceStaleMessages(t *testing.T) {
+	inner := newRecordingTransport(false)
+	storage := definition.NewInMemoryStorage()
+	peer := &types.PeerConfiguration{Name: "own-0", Partition: "own", Storage: storage}
+	transport := core.NewEpochTransport(inner, peer, definition.NewDefaultLogger())
+	defer transport.Close()
+
+	published := func(m types.Message) bool {
+		inner.listen <- m
+		select {
+		case <-transport.Listen():
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}
+
+	other := types.Message{Header: types.ProtocolHeader{Epoch: 2, Origin: "other-0"}, From: "other"}
+	if !published(other) {
+		t.Fatalf("expected message on epoch 2 published")
+	}
+	other.Header.Epoch = 1
+	if published(other) || transport.Fenced() != 1 {
+		t.Fatalf("expected message on epoch 1 fenced, found %d fenced", transport.Fenced())
+	}
+
+	if err := transport.Advance(); err != nil {
+		t.Fatalf("failed advancing. %v", err)
+	}
+	sent, partitions := inner.Sent()
+	if len(sent) != 1 || sent[0].Header.Type != types.Epoch || sent[0].Header.Epoch != 1 || partitions[0] != "own" {
+		t.Fatalf("expected next epoch sent to the own partition, found %#v", sent)
+	}
+	if transport.Epoch() != 0 {
+		t.Errorf("expected epoch changed only after received back, found %d", transport.Epoch())
+	}
+
+	sent[0].Header.Origin = peer.Name
+	if !published(sent[0]) || transport.Epoch() != 1 {
+		t.Fatalf("expected epoch 1, found %d", transport.Epoch())
+	}
+	if err := transport.Unicast(types.Message{}, "other"); err != nil {
+		t.Fatalf("failed unicast. %v", err)
+	}
+	if sent, _ = inner.Sent(); sent[1].Header.Epoch != 1 {
+		t.Errorf("expected message stamped with epoch 1, found %d", sent[1].Header.Epoch)
+	}
+
+	// A replica of the own partition that did not see the epoch yet.
+	sibling := types.Message{Header: types.ProtocolHeader{Origin: "own-1"}, From: "own"}
+	if !published(sibling) {
+		t.Errorf("expected message from the replica on epoch 0 published")
+	}
+
+	restarted := core.NewEpochTransport(newRecordingTransport(false), peer, definition.NewDefaultLogger())
+	defer restarted.Close()
+	if restarted.Epoch() != 1 {
+		t.Errorf("expected restarted transport on the persisted epoch 1, found %d", restarted.Epoch())
+	}
+}
+
+func TestEpoch_ZombiePeerFenced(t *testing.T) {
+	broker := core.NewMemoryBroker()
+	cluster := CreateClusterWith(2, "epoch", t, func(conf *types.Configuration) {
+		conf.Broker = broker
+	})
+	defer cluster.Off()
+
+	unity := cluster.Unities[0].(*mcast.PeerUnity)
+	if err := unity.AdvanceEpoch(); err != nil {
+		t.Fatalf("failed advancing epoch. %v", err)
+	}
+	if !WaitThisOrTimeout(func() {
+		for _, peer := range unity.Peers {
+			for peer.Epoch() != 1 {
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}, 5*time.Second) {
+		t.Fatalf("peers did not move to the next epoch")
+	}
+
+	key := []byte("epoch")
+	if res := <-unity.Write(GenerateRequest(key, []byte("current"), cluster.Names)); !res.Success {
+		t.Fatalf("failed writing. %v", res.Failure)
+	}
+
+	// The old instance of the first peer, isolated before the
+	// new instance moved to the next epoch.
+	conf := mcast.NewPeerConfiguration(unity.Configuration, 0, nil)
+	conf.Storage = definition.NewInMemoryStorage()
+	zombie, err := core.NewPeer(conf, unity.Configuration.Logger)
+	if err != nil {
+		t.Fatalf("failed creating zombie peer. %v", err)
+	}
+	defer zombie.Stop()
+
+	zombie.Command(context.Background(), types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: unity.Configuration.Version,
+			Type:            types.Initial,
+		},
+		Identifier: types.UID(helper.GenerateUID()),
+		Content: types.DataHolder{
+			Operation: types.Command,
+			Key:       key,
+			Content:   []byte("zombie"),
+		},
+		State:       types.S0,
+		Destination: []types.Partition{cluster.Names[1]},
+		From:        cluster.Names[0],
+	})
+
+	deadline := time.After(5 * time.Second)
+	for fenced := false; !fenced; {
+		select {
+		case err := <-cluster.Unities[1].Errors():
+			fenced = errors.Is(err, core.ErrStaleEpoch)
+		case <-deadline:
+			t.Fatalf("zombie message not fenced")
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if res, err := cluster.Unities[1].Read(GenerateRequest(key, nil, nil)); err != nil || string(res.Data) != "current" {
+		t.Errorf("expected current value, found %s. %v", res.Data, err)
+	}
+}
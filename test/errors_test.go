@@ -0,0 +1,110 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+// Errors injected when committing.
+var errCommit = errors.New("commit failed")
+
+// A storage used as state machine that never commits.
+type rejectingStorage struct {
+	types.Storage
+}
+
+func (r rejectingStorage) Commit(*types.Entry) (interface{}, error) {
+	return nil, errCommit
+}
+
+func (r rejectingStorage) Restore() error {
+	return nil
+}
+
+// Wait for an asynchronous failure of the given kind.
+func awaitFailure(t *testing.T, unity mcast.Unity, kind types.FailureKind) *types.AsyncError {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-unity.Errors():
+			var failure *types.AsyncError
+			if !errors.As(err, &failure) {
+				t.Fatalf("expected async error, found %#v", err)
+			}
+			if failure.Kind == kind {
+				return failure
+			}
+		case <-timeout:
+			t.Fatalf("no %s reported", kind)
+			return nil
+		}
+	}
+}
+
+func TestErrorReporter_DiscardWhenFull(t *testing.T) {
+	reporter := types.NewErrorReporter(1)
+	first := &types.AsyncError{Kind: types.TransportFailure, Peer: "peer", Err: errors.New("first")}
+	reporter.Report(first)
+	reporter.Report(&types.AsyncError{Kind: types.TransportFailure, Peer: "peer", Err: errors.New("second")})
+
+	if discarded := reporter.Discarded(); discarded != 1 {
+		t.Errorf("expected 1 discarded, found %d", discarded)
+	}
+
+	if err := <-reporter.Errors(); err != first {
+		t.Errorf("expected first failure, found %v", err)
+	}
+}
+
+func TestErrorReporter_NilIgnoresFailures(t *testing.T) {
+	var reporter *types.ErrorReporter
+	reporter.Report(&types.AsyncError{Kind: types.CommitFailure, Err: errors.New("ignored")})
+	if reporter.Errors() != nil || reporter.Discarded() != 0 {
+		t.Errorf("nil reporter should ignore failures")
+	}
+}
+
+func TestUnity_ReportCommitFailure(t *testing.T) {
+	partitionName := types.Partition("errors-commit-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Storage = rejectingStorage{Storage: definition.NewInMemoryStorage()}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	request := GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partitionName})
+	unity.Write(request)
+
+	failure := awaitFailure(t, unity, types.CommitFailure)
+	if !errors.Is(failure, errCommit) {
+		t.Errorf("expected injected failure, found %v", failure)
+	}
+}
+
+func TestUnity_ReportGatherFailure(t *testing.T) {
+	partitionName := types.Partition("errors-gather-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Retry = types.RetryPolicy{Attempts: 1, Backoff: 1}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	destination := []types.Partition{partitionName, "errors-absent-partition"}
+	unity.Write(GenerateRequest([]byte("key"), []byte("value"), destination))
+
+	failure := awaitFailure(t, unity, types.GatherFailure)
+	if !errors.Is(failure, core.ErrGatherTimeout) || len(failure.Identifier) == 0 {
+		t.Errorf("expected gather timeout, found %v", failure)
+	}
+}
@@ -0,0 +1,82 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEventRecorder_KeepLastEvents(t *testing.T) {
+	recorder := types.NewEventRecorder(2, 10)
+	for _, kind := range []types.EventKind{types.EventReceived, types.EventStateChanged, types.EventDelivered} {
+		recorder.Record("uid", types.Event{Kind: kind})
+	}
+
+	events := recorder.Events("uid")
+	if len(events) != 2 || events[0].Kind != types.EventStateChanged || events[1].Kind != types.EventDelivered {
+		t.Errorf("expected last 2 events, found %#v", events)
+	}
+}
+
+func TestEventRecorder_EvictOldestMessage(t *testing.T) {
+	recorder := types.NewEventRecorder(2, 2)
+	for _, uid := range []types.UID{"first", "second", "third"} {
+		recorder.Record(uid, types.Event{Kind: types.EventReceived})
+	}
+
+	dump := recorder.Dump()
+	if _, ok := dump["first"]; ok || len(dump) != 2 {
+		t.Errorf("expected first message evicted, found %#v", dump)
+	}
+}
+
+func TestEventRecorder_NilIgnoresEvents(t *testing.T) {
+	var recorder *types.EventRecorder
+	recorder.Record("uid", types.Event{Kind: types.EventReceived})
+	if events := recorder.Events("uid"); len(events) != 0 {
+		t.Errorf("nil recorder should ignore events")
+	}
+}
+
+func TestUnity_RecordMessageEvents(t *testing.T) {
+	partitionName := types.Partition("events-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Recorder = types.NewEventRecorder(64, 16)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	var res types.Response
+	select {
+	case res = <-unity.Write(GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partitionName})):
+		if !res.Success {
+			t.Fatalf("failed writing. %v", res.Failure)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout writing")
+	}
+
+	w := httptest.NewRecorder()
+	mcast.EventsHandler(conf.Recorder).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events?uid="+string(res.Identifier), nil))
+	var events []types.Event
+	if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+		t.Fatalf("failed decoding events. %v", err)
+	}
+
+	kinds := make(map[types.EventKind]bool)
+	for _, event := range events {
+		kinds[event.Kind] = true
+	}
+	for _, kind := range []types.EventKind{types.EventReceived, types.EventStateChanged, types.EventSent, types.EventDelivered} {
+		if !kinds[kind] {
+			t.Errorf("expected %s event, found %#v", kind, events)
+		}
+	}
+}
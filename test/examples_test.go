@@ -0,0 +1,133 @@
+package test
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/examples/app"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestExamples_KVServer(t *testing.T) {
+	cluster, err := app.NewCluster(app.TransportMemory, NewTestingUnity)
+	if err != nil {
+		t.Fatalf("failed creating cluster. %v", err)
+	}
+	defer cluster.Shutdown()
+	unity, err := cluster.Partition("example-kv", definition.NewInMemoryStorage())
+	if err != nil {
+		t.Fatalf("failed creating partition. %v", err)
+	}
+	server := httptest.NewServer(app.NewKVServer(unity, "example-kv"))
+	defer server.Close()
+
+	if res, err := http.Get(server.URL + app.KeysPath + "hello"); err != nil || res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected key not found, found %#v. %v", res, err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL+app.KeysPath+"hello", strings.NewReader("world"))
+	if res, err := http.DefaultClient.Do(req); err != nil || res.StatusCode != http.StatusNoContent {
+		t.Fatalf("failed writing key, found %#v. %v", res, err)
+	}
+
+	res, err := http.Get(server.URL + app.KeysPath + "hello")
+	if err != nil {
+		t.Fatalf("failed reading key. %v", err)
+	}
+	defer res.Body.Close()
+	value := make([]byte, 5)
+	if n, _ := res.Body.Read(value); string(value[:n]) != "world" {
+		t.Errorf("expected world, found %s", value[:n])
+	}
+}
+
+func TestExamples_CounterConcurrentIncrements(t *testing.T) {
+	cluster, err := app.NewCluster(app.TransportMemory, NewTestingUnity)
+	if err != nil {
+		t.Fatalf("failed creating cluster. %v", err)
+	}
+	defer cluster.Shutdown()
+	unity, err := cluster.Partition("example-counter", app.NewCounterStorage())
+	if err != nil {
+		t.Fatalf("failed creating partition. %v", err)
+	}
+
+	counter := app.NewCounter(unity, "example-counter", "visits")
+	group := &sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for j := 0; j < 10; j++ {
+				if err := counter.Add(2); err != nil {
+					t.Errorf("failed incrementing. %v", err)
+					return
+				}
+			}
+		}()
+	}
+	group.Wait()
+
+	if value, err := counter.Value(); err != nil || value != 60 {
+		t.Errorf("expected counter at 60, found %d. %v", value, err)
+	}
+}
+
+func TestExamples_ChatRoomsOrdering(t *testing.T) {
+	cluster, err := app.NewCluster(app.TransportMemory, NewTestingUnity)
+	if err != nil {
+		t.Fatalf("failed creating cluster. %v", err)
+	}
+	defer cluster.Shutdown()
+	rooms := make(map[string]mcast.Unity)
+	for _, room := range []string{"example-general", "example-random"} {
+		unity, err := cluster.Partition(room, app.NewRoomStorage())
+		if err != nil {
+			t.Fatalf("failed creating room. %v", err)
+		}
+		rooms[room] = unity
+	}
+
+	chat := app.NewChat(rooms)
+	group := &sync.WaitGroup{}
+	for _, user := range []string{"alice", "bob"} {
+		group.Add(1)
+		go func(user string) {
+			defer group.Done()
+			for i := 0; i < 5; i++ {
+				if err := chat.Post(user, fmt.Sprint(i), "example-general", "example-random"); err != nil {
+					t.Errorf("failed posting. %v", err)
+				}
+			}
+		}(user)
+	}
+	group.Wait()
+
+	if err := chat.Post("carol", "only general", "example-general"); err != nil {
+		t.Fatalf("failed posting. %v", err)
+	}
+	if err := chat.Post("carol", "nowhere", "example-absent"); err == nil {
+		t.Errorf("expected unknown room rejected")
+	}
+
+	general, err := chat.Messages("example-general")
+	if err != nil {
+		t.Fatalf("failed reading general. %v", err)
+	}
+	random, err := chat.Messages("example-random")
+	if err != nil {
+		t.Fatalf("failed reading random. %v", err)
+	}
+	if len(general) != 11 || len(random) != 10 {
+		t.Fatalf("expected 11 and 10 messages, found %d and %d", len(general), len(random))
+	}
+	for i, message := range random {
+		if general[i].Identifier != message.Identifier {
+			t.Errorf("message %d differs between rooms, %s and %s", i, general[i], message)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package test
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Records the order the requests are applied, answering
+// only when released.
+type gatedWriter struct {
+	mutex   *sync.Mutex
+	applied []string
+	keys    []string
+	release chan bool
+}
+
+func (g *gatedWriter) Write(request types.Request) <-chan types.Response {
+	g.mutex.Lock()
+	g.applied = append(g.applied, request.Client)
+	g.keys = append(g.keys, string(request.Key))
+	g.mutex.Unlock()
+
+	res := make(chan types.Response, 1)
+	go func() {
+		<-g.release
+		res <- types.Response{Success: true}
+		close(res)
+	}()
+	return res
+}
+
+func (g *gatedWriter) Applied() []string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return append([]string(nil), g.applied...)
+}
+
+func scheduleRequests(scheduler *mcast.FairScheduler, client string, n int) []<-chan types.Response {
+	var responses []<-chan types.Response
+	for i := 0; i < n; i++ {
+		request := GenerateRandomRequest([]types.Partition{"fair"})
+		request.Client = client
+		responses = append(responses, scheduler.Write(request))
+	}
+	return responses
+}
+
+func drainScheduled(t *testing.T, writer *gatedWriter, responses []<-chan types.Response) {
+	for range responses {
+		writer.release <- true
+	}
+
+	for _, res := range responses {
+		select {
+		case r := <-res:
+			if !r.Success {
+				t.Fatalf("expected success, found %#v", r)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("request not answered")
+		}
+	}
+}
+
+func TestFairScheduler_DoNotStarveClients(t *testing.T) {
+	writer := &gatedWriter{mutex: &sync.Mutex{}, release: make(chan bool)}
+	scheduler := mcast.NewFairScheduler(writer.Write, mcast.FairOptions{InFlight: 1})
+
+	responses := scheduleRequests(scheduler, "greedy", 10)
+	responses = append(responses, scheduleRequests(scheduler, "polite", 2)...)
+	if pending := scheduler.Clients()["polite"].Pending; pending != 2 {
+		t.Errorf("expected 2 pending requests, found %d", pending)
+	}
+	drainScheduled(t, writer, responses)
+
+	applied := writer.Applied()
+	expected := []string{"greedy", "greedy", "polite", "greedy", "polite", "greedy"}
+	for i, client := range expected {
+		if applied[i] != client {
+			t.Fatalf("expected order %v, found %v", expected, applied)
+		}
+	}
+
+	if dispatched := scheduler.Clients()["greedy"].Dispatched; dispatched != 10 {
+		t.Errorf("expected 10 dispatched, found %d", dispatched)
+	}
+}
+
+func TestFairScheduler_WeightedClients(t *testing.T) {
+	writer := &gatedWriter{mutex: &sync.Mutex{}, release: make(chan bool)}
+	scheduler := mcast.NewFairScheduler(writer.Write, mcast.FairOptions{
+		InFlight: 1,
+		Weights:  map[string]int{"heavy": 3},
+	})
+
+	responses := scheduleRequests(scheduler, "light", 4)
+	responses = append(responses, scheduleRequests(scheduler, "heavy", 6)...)
+	drainScheduled(t, writer, responses)
+
+	applied := writer.Applied()
+	expected := []string{"light", "light", "heavy", "heavy", "heavy", "light", "heavy", "heavy", "heavy", "light"}
+	for i, client := range expected {
+		if applied[i] != client {
+			t.Fatalf("expected order %v, found %v", expected, applied)
+		}
+	}
+}
+
+// With many requests in flight, the requests of each client are
+// still written in the order they were scheduled.
+func TestFairScheduler_KeepClientOrderInFlight(t *testing.T) {
+	writer := &gatedWriter{mutex: &sync.Mutex{}, release: make(chan bool)}
+	scheduler := mcast.NewFairScheduler(writer.Write, mcast.FairOptions{InFlight: 8})
+
+	var responses []<-chan types.Response
+	for i := 0; i < 20; i++ {
+		for _, client := range []string{"first", "second"} {
+			request := GenerateRequest([]byte(fmt.Sprintf("%s-%02d", client, i)), []byte("value"), []types.Partition{"fair"})
+			request.Client = client
+			responses = append(responses, scheduler.Write(request))
+		}
+	}
+	drainScheduled(t, writer, responses)
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	last := make(map[string]string)
+	for i, key := range writer.keys {
+		client := writer.applied[i]
+		if key <= last[client] {
+			t.Fatalf("expected %s written after %s, found %v", key, last[client], writer.keys)
+		}
+		last[client] = key
+	}
+	if len(writer.keys) != len(responses) {
+		t.Errorf("expected %d written, found %d", len(responses), len(writer.keys))
+	}
+}
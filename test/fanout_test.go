@@ -0,0 +1,78 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFanOut_BoundedWorkers(t *testing.T) {
+	var running, greatest int32
+	mutex := &sync.Mutex{}
+	sent := make(map[types.Partition]bool)
+	destinations := []types.Partition{"a", "b", "c", "d", "e", "f"}
+	err := core.FanOut(types.Fanout{Workers: 2}, destinations, func(partition types.Partition) error {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			previous := atomic.LoadInt32(&greatest)
+			if current <= previous || atomic.CompareAndSwapInt32(&greatest, previous, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		sent[partition] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed fanning out. %v", err)
+	}
+	if len(sent) != len(destinations) {
+		t.Errorf("expected every destination sent, found %v", sent)
+	}
+	if greatest != 2 {
+		t.Errorf("expected 2 concurrent sends, found %d", greatest)
+	}
+}
+
+func TestFanOut_PartialFailure(t *testing.T) {
+	down := errors.New("partition down")
+	destinations := []types.Partition{"a", "b", "c"}
+	failing := func(dead ...types.Partition) func(types.Partition) error {
+		return func(partition types.Partition) error {
+			for _, d := range dead {
+				if d == partition {
+					return down
+				}
+			}
+			return nil
+		}
+	}
+
+	err := core.FanOut(types.Fanout{}, destinations, failing("b"))
+	var failures types.MultiError
+	if !errors.As(err, &failures) || len(failures) != 1 || failures["b"] != down {
+		t.Fatalf("expected failure only on b, found %v", err)
+	}
+	if !errors.Is(err, down) {
+		t.Errorf("expected the partition failure on %v", err)
+	}
+
+	if err := core.FanOut(types.Fanout{Partial: types.FailOnAll}, destinations, failing("b")); err != nil {
+		t.Errorf("expected broadcast reaching some destinations, found %v", err)
+	}
+	err = core.FanOut(types.Fanout{Partial: types.FailOnAll}, destinations, failing(destinations...))
+	if !errors.As(err, &failures) || len(failures) != len(destinations) {
+		t.Errorf("expected every destination failed, found %v", err)
+	}
+	if err.Error() != "a: partition down; b: partition down; c: partition down" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
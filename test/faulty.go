@@ -0,0 +1,148 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// A link between two addresses.
+type link struct {
+	from types.Address
+	to   types.Address
+}
+
+// Data published through a severed link, held until healed.
+type held struct {
+	connection types.BrokerConnection
+	address    types.Address
+	data       []byte
+}
+
+// A broker decorator used to inject network partitions and
+// latency. The data published between severed addresses is held,
+// and once healed is published in the same order it was held, so
+// the channels are still quasi-reliable as the protocol expects.
+type FaultyBroker struct {
+	// Synchronize access to the links and the held data.
+	mutex *sync.Mutex
+
+	// The decorated broker.
+	broker types.Broker
+
+	// The severed links.
+	severed map[link]bool
+
+	// The data held by the severed links, in order.
+	held []held
+
+	// The minimum latency added to each publish.
+	minLatency time.Duration
+
+	// The maximum latency added to each publish.
+	maxLatency time.Duration
+}
+
+// Creates a new faulty broker decorating the given broker,
+// with every link healthy.
+func NewFaultyBroker(broker types.Broker) *FaultyBroker {
+	return &FaultyBroker{
+		mutex:   &sync.Mutex{},
+		broker:  broker,
+		severed: make(map[link]bool),
+	}
+}
+
+// Implements the Broker interface.
+func (f *FaultyBroker) Connect(name string, address types.Address) (types.BrokerConnection, error) {
+	connection, err := f.broker.Connect(name, address)
+	if err != nil {
+		return nil, err
+	}
+	return &faultyConnection{BrokerConnection: connection, broker: f, address: address}, nil
+}
+
+// Sever the communication between the partitions, on both ways.
+func (f *FaultyBroker) Sever(a, b types.Partition) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.severed[link{types.Address(a), types.Address(b)}] = true
+	f.severed[link{types.Address(b), types.Address(a)}] = true
+}
+
+// Heal every severed link, publishing the held data.
+func (f *FaultyBroker) Heal() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.severed = make(map[link]bool)
+	for _, h := range f.held {
+		h.connection.Publish(h.address, h.data)
+	}
+	f.held = nil
+}
+
+// Add a random latency between min and max to each publish.
+// The publisher waits the latency, so the data published in
+// sequence is still delivered in order.
+func (f *FaultyBroker) Latency(min, max time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.minLatency = min
+	f.maxLatency = max
+}
+
+// The latency for the next publish.
+func (f *FaultyBroker) latency() time.Duration {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.maxLatency <= f.minLatency {
+		return f.minLatency
+	}
+	return f.minLatency + time.Duration(rand.Int63n(int64(f.maxLatency-f.minLatency)))
+}
+
+// How many publishes are held by the severed links.
+func (f *FaultyBroker) Held() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.held)
+}
+
+// Publish the data or hold it if the link is severed. The data is
+// published while holding the lock, so nothing published after
+// healing overtakes the held data.
+func (f *FaultyBroker) publish(c *faultyConnection, address types.Address, data []byte) error {
+	if latency := f.latency(); latency > 0 {
+		time.Sleep(latency)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.severed[link{c.address, address}] {
+		f.held = append(f.held, held{
+			connection: c.BrokerConnection,
+			address:    address,
+			data:       append([]byte{}, data...),
+		})
+		return nil
+	}
+	return c.BrokerConnection.Publish(address, data)
+}
+
+// A connection to the faulty broker.
+type faultyConnection struct {
+	// The decorated connection.
+	types.BrokerConnection
+
+	// The broker the connection belongs to.
+	broker *FaultyBroker
+
+	// The address the connection is subscribed to.
+	address types.Address
+}
+
+// Implements the BrokerConnection interface.
+func (f *faultyConnection) Publish(address types.Address, data []byte) error {
+	return f.broker.publish(f, address, data)
+}
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFeatures_ParseAndDefaults(t *testing.T) {
+	features, err := types.ParseFeatures(" batching, -generic_delivery ,")
+	if err != nil {
+		t.Fatalf("failed parsing. %v", err)
+	}
+	if !features.Enabled(types.FeatureBatching) || features.Enabled(types.FeatureGenericDelivery) {
+		t.Errorf("unexpected features %#v", features)
+	}
+	if !features.Enabled(types.FeatureAdaptiveTimeouts) {
+		t.Errorf("expected feature not listed to use the default")
+	}
+
+	expected := []types.Feature{types.FeatureAdaptiveTimeouts, types.FeatureBatching, types.FeaturePriorityInheritance}
+	if enabled := features.List(); !reflect.DeepEqual(enabled, expected) {
+		t.Errorf("expected %v enabled, found %v", expected, enabled)
+	}
+
+	var none types.Features
+	if !none.Enabled(types.FeatureGenericDelivery) || len(none.List()) != len(types.DefaultFeatures) {
+		t.Errorf("expected defaults without features, found %v", none.List())
+	}
+
+	if _, err := types.ParseFeatures("batching,-telepathy"); !errors.Is(err, types.ErrUnknownFeature) {
+		t.Errorf("expected unknown feature, found %v", err)
+	}
+}
+
+func TestUnity_FeaturesDisabled(t *testing.T) {
+	partitionName := types.Partition("features-unity")
+	storage := newBatchingStorage()
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Storage = storage
+	conf.BatchSize = 8
+	conf.Features = types.Features{
+		types.FeatureGenericDelivery: false,
+		types.FeatureBatching:        false,
+	}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	var observers []<-chan types.Response
+	for i := 0; i < 20; i++ {
+		observers = append(observers, unity.Write(GenerateRandomRequest([]types.Partition{partitionName})))
+	}
+
+	for _, obs := range observers {
+		select {
+		case res := <-obs:
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+
+	for _, size := range storage.Batches() {
+		if size > 1 {
+			t.Errorf("expected no batches, found a batch of %d", size)
+		}
+	}
+
+	stats := unity.Stats()
+	if stats.GenericDelivered != 0 {
+		t.Errorf("expected no generic delivery, found %d", stats.GenericDelivered)
+	}
+	if !reflect.DeepEqual(stats.Features, []types.Feature{types.FeatureAdaptiveTimeouts, types.FeaturePriorityInheritance}) {
+		t.Errorf("expected only adaptive timeouts and priority inheritance on the stats, found %v", stats.Features)
+	}
+}
@@ -0,0 +1,144 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"reflect"
+	"testing"
+)
+
+func TestFrame_EncodeAndDecode(t *testing.T) {
+	for _, codec := range []types.Codec{definition.JSONCodec{}, definition.MsgpackCodec{}} {
+		message := codecMessage()
+		message.Header.Trace = map[string]string{"traceparent": "00-trace-span-01"}
+		data, err := core.EncodeFrame(codec, message)
+		if err != nil {
+			t.Fatalf("failed encoding frame. %v", err)
+		}
+
+		decoded, err := core.DecodeFrame(codec, data)
+		if err != nil {
+			t.Fatalf("failed decoding frame. %v", err)
+		}
+
+		if !reflect.DeepEqual(message.Destination, decoded.Header.Destination) {
+			t.Errorf("header destination should be %v, found %v", message.Destination, decoded.Header.Destination)
+		}
+
+		if decoded.Header.ContentLength == 0 {
+			t.Errorf("header without content length")
+		}
+
+		decoded.Header.Destination = nil
+		decoded.Header.ContentLength = 0
+		if !reflect.DeepEqual(message, decoded) {
+			t.Errorf("decoded message differs.\n%#v\n%#v", message, decoded)
+		}
+	}
+}
+
+func TestFrame_DecodeOnlyHeader(t *testing.T) {
+	codec := definition.JSONCodec{}
+	message := codecMessage()
+	data, err := core.EncodeFrame(codec, message)
+	if err != nil {
+		t.Fatalf("failed encoding frame. %v", err)
+	}
+
+	// Corrupting the payload must not affect reading the header.
+	data[len(data)-1] = 0x00
+	header, err := core.DecodeHeader(codec, data)
+	if err != nil {
+		t.Fatalf("failed decoding header. %v", err)
+	}
+
+	if header.Type != message.Header.Type || header.Origin != message.Header.Origin {
+		t.Errorf("wrong header decoded %#v", header)
+	}
+
+	if _, err := core.DecodeFrame(codec, data); err == nil {
+		t.Errorf("decoding corrupted payload should fail")
+	}
+}
+
+func TestFrame_DecodeUnframedMessage(t *testing.T) {
+	codec := definition.JSONCodec{}
+	message := codecMessage()
+	data, err := codec.Marshal(message)
+	if err != nil {
+		t.Fatalf("failed marshalling. %v", err)
+	}
+
+	decoded, err := core.DecodeFrame(codec, data)
+	if err != nil {
+		t.Fatalf("failed decoding unframed message. %v", err)
+	}
+
+	if !reflect.DeepEqual(message, decoded) {
+		t.Errorf("decoded message differs.\n%#v\n%#v", message, decoded)
+	}
+}
+
+func TestFrame_InvalidFrames(t *testing.T) {
+	codec := definition.JSONCodec{}
+	data, err := core.EncodeFrame(codec, codecMessage())
+	if err != nil {
+		t.Fatalf("failed encoding frame. %v", err)
+	}
+
+	if _, err := core.DecodeFrame(codec, data[:len(data)-1]); !errors.Is(err, core.ErrInvalidFrame) {
+		t.Errorf("expected invalid frame for truncated payload, found %v", err)
+	}
+
+	unknown := append([]byte{}, data...)
+	unknown[1] = core.FrameVersionCodec + 1
+	if _, err := core.DecodeFrame(codec, unknown); !errors.Is(err, core.ErrUnknownFrameVersion) {
+		t.Errorf("expected unknown version, found %v", err)
+	}
+
+	if _, err := core.DecodeHeader(codec, []byte{0xfe}); !errors.Is(err, core.ErrInvalidFrame) {
+		t.Errorf("expected invalid frame for short data, found %v", err)
+	}
+}
+
+func TestFrame_DecodeWithSenderCodec(t *testing.T) {
+	message := codecMessage()
+	message.Header.Codecs = types.CodecCBOR | types.CodecJSON
+	data, err := core.EncodeFrame(definition.CBORCodec{}, message)
+	if err != nil {
+		t.Fatalf("failed encoding frame. %v", err)
+	}
+
+	if data[1] != core.FrameVersionCodec || types.CodecID(data[2]) != types.CodecCBOR {
+		t.Fatalf("frame should identify the codec, found % x", data[:3])
+	}
+
+	// The receiver uses another codec, but decodes using the frame codec.
+	decoded, err := core.DecodeFrame(definition.JSONCodec{}, data)
+	if err != nil {
+		t.Fatalf("failed decoding frame. %v", err)
+	}
+
+	if decoded.Identifier != message.Identifier || decoded.Header.Codecs != message.Header.Codecs {
+		t.Errorf("wrong message decoded %#v", decoded)
+	}
+
+	unsupported := append([]byte{}, data...)
+	unsupported[2] = 0x80
+	if _, err := core.DecodeFrame(definition.JSONCodec{}, unsupported); !errors.Is(err, core.ErrUnsupportedCodec) {
+		t.Errorf("expected unsupported codec, found %v", err)
+	}
+}
+
+func TestFrame_KeepVersionWithoutNegotiation(t *testing.T) {
+	data, err := core.EncodeFrame(definition.MsgpackCodec{}, codecMessage())
+	if err != nil {
+		t.Fatalf("failed encoding frame. %v", err)
+	}
+
+	if data[1] != core.FrameVersion {
+		t.Errorf("expected version %d, found %d", core.FrameVersion, data[1])
+	}
+}
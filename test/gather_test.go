@@ -0,0 +1,38 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+// Writing to a partition that never answers must fail the
+// request after the retry attempts, instead of waiting forever.
+func TestGather_TimeoutFailsRequest(t *testing.T) {
+	partitionName := types.Partition("gather-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Retry = types.RetryPolicy{Attempts: 2, Backoff: 1}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	destination := []types.Partition{partitionName, "gather-absent-partition"}
+	select {
+	case res := <-unity.Write(GenerateRequest([]byte("key"), []byte("value"), destination)):
+		if res.Success || !errors.Is(res.Failure, core.ErrGatherTimeout) {
+			t.Errorf("expected gather timeout, found %#v", res)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("request did not fail")
+	}
+
+	if timeouts := unity.GatherTimeouts(); timeouts < 3 {
+		t.Errorf("expected at least 3 gather timeouts, found %d", timeouts)
+	}
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth_WriteSkipsStoppedPeers(t *testing.T) {
+	partitionName := types.Partition("health-unity")
+	unity := CreateUnity(partitionName, t)
+	defer unity.Shutdown()
+
+	peers := unity.(*mcast.PeerUnity).Peers
+	for _, peer := range peers[:len(peers)-1] {
+		if err := peer.Stop(); err != nil {
+			t.Fatalf("failed stopping peer. %v", err)
+		}
+		if !errors.Is(peer.Healthy(), core.ErrPeerStopped) {
+			t.Errorf("expected stopped peer, found %v", peer.Healthy())
+		}
+	}
+
+	for i := 0; i < len(peers); i++ {
+		select {
+		case res := <-unity.Write(GenerateRequest([]byte("health"), []byte("value"), []types.Partition{partitionName})):
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+
+	if err := peers[len(peers)-1].Stop(); err != nil {
+		t.Fatalf("failed stopping peer. %v", err)
+	}
+	res := <-unity.Write(GenerateRequest([]byte("health"), []byte("value"), []types.Partition{partitionName}))
+	if !errors.Is(res.Failure, mcast.ErrNoHealthyPeer) || strings.Count(res.Failure.Error(), core.ErrPeerStopped.Error()) != len(peers) {
+		t.Errorf("expected no healthy peer with every reason, found %v", res.Failure)
+	}
+}
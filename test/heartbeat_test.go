@@ -0,0 +1,39 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat_SingleNoopWhilePending(t *testing.T) {
+	partitionName := types.Partition("heartbeat-pending")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Replication = 1
+	conf.Heartbeat = 50 * time.Millisecond
+	delivered := make(chan types.Delivered, 10)
+	conf.OnDeliver = func(d types.Delivered) {
+		delivered <- d
+	}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	// The other partition does not exist, so the write stays pending.
+	unity.Write(GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partitionName, "heartbeat-missing"}))
+	time.Sleep(10 * conf.Heartbeat)
+
+	stats := unity.Stats()
+	if stats.Proposed != 2 {
+		t.Errorf("expected the write and a single no-op proposed, found %d", stats.Proposed)
+	}
+	select {
+	case d := <-delivered:
+		t.Errorf("expected nothing delivered, found %#v", d.Message)
+	default:
+	}
+}
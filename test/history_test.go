@@ -0,0 +1,207 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func historyEntries() []types.Entry {
+	var entries []types.Entry
+	for i, key := range []string{"a", "b", "a", "a", "b"} {
+		entries = append(entries, types.Entry{
+			Operation:      types.Command,
+			Identifier:     types.UID(string(rune('0' + i))),
+			Key:            []byte(key),
+			FinalTimestamp: uint64(i + 1),
+		})
+	}
+	return entries
+}
+
+func historyIdentifiers(entries []types.Entry) string {
+	var ids string
+	for _, entry := range entries {
+		ids += string(entry.Identifier)
+	}
+	return ids
+}
+
+func TestHistory_FilterEntries(t *testing.T) {
+	cases := []struct {
+		filter   types.HistoryFilter
+		expected string
+	}{
+		{types.HistoryFilter{}, "01234"},
+		{types.HistoryFilter{Key: []byte("a")}, "023"},
+		{types.HistoryFilter{From: 2, To: 4}, "123"},
+		{types.HistoryFilter{Key: []byte("a"), From: 2}, "23"},
+		{types.HistoryFilter{Last: 2}, "34"},
+		{types.HistoryFilter{Key: []byte("b"), Last: 1}, "4"},
+		{types.HistoryFilter{From: 10}, ""},
+	}
+	for _, c := range cases {
+		if found := historyIdentifiers(c.filter.Apply(historyEntries())); found != c.expected {
+			t.Errorf("filter %#v expected %q, found %q", c.filter, c.expected, found)
+		}
+	}
+}
+
+func TestHistory_SQLStorageFilters(t *testing.T) {
+	db, _ := openFakeSQL(t)
+	defer db.Close()
+	storage, err := definition.NewSQLStorage(db, "mcast")
+	if err != nil {
+		t.Fatalf("failed creating storage. %v", err)
+	}
+
+	for _, entry := range historyEntries() {
+		e := entry
+		if _, err := storage.Commit(&e); err != nil {
+			t.Fatalf("failed committing. %v", err)
+		}
+	}
+
+	history, err := storage.History(types.HistoryFilter{Key: []byte("a"), From: 2, Last: 1})
+	if err != nil || historyIdentifiers(history) != "3" {
+		t.Errorf("expected entry 3, found %#v. %v", history, err)
+	}
+
+	history, err = storage.History(types.HistoryFilter{To: 2})
+	if err != nil || historyIdentifiers(history) != "01" {
+		t.Errorf("expected entries 01, found %#v. %v", history, err)
+	}
+}
+
+func TestHistory_ReadThroughUnity(t *testing.T) {
+	path, clean := walPath(t)
+	defer clean()
+	storage, err := definition.NewWALStorage(path, definition.NewInMemoryStorage())
+	if err != nil {
+		t.Fatalf("failed creating storage. %v", err)
+	}
+	defer storage.Close()
+
+	partitionName := types.Partition("history-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Storage = storage
+	conf.Logger.ToggleDebug(false)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	key := []byte("history-key")
+	for _, value := range []string{"first", "second", "third"} {
+		obs := unity.Write(GenerateRequest(key, []byte(value), []types.Partition{partitionName}))
+		select {
+		case res := <-obs:
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+
+	res, err := unity.Read(*mcast.NewHistoryRequest(key, 0, 0, 2, []string{string(partitionName)}))
+	if err != nil || !res.Success {
+		t.Fatalf("failed reading history. %v", err)
+	}
+
+	if len(res.History) != 2 || string(res.History[0].Data) != "second" || string(res.History[1].Data) != "third" {
+		t.Errorf("expected last two entries, found %#v", res.History)
+	}
+}
+
+func TestHistory_UnsupportedByDefault(t *testing.T) {
+	partitionName := types.Partition("no-history-unity")
+	unity := CreateUnity(partitionName, t)
+	defer unity.Shutdown()
+
+	_, err := unity.Read(*mcast.NewHistoryRequest([]byte("key"), 0, 0, 0, []string{string(partitionName)}))
+	if !errors.Is(err, types.ErrHistoryUnsupported) {
+		t.Errorf("expected history unsupported, found %v", err)
+	}
+}
+
+func TestHistory_QueryCommitReturnsOnlyKey(t *testing.T) {
+	storage := definition.NewInMemoryStorage()
+	deliver, err := core.NewDeliver(context.Background(), definition.NewDefaultLogger(), &definition.AlwaysConflict{}, storage)
+	if err != nil {
+		t.Fatalf("failed creating deliver. %v", err)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		res := deliver.Commit(types.Message{
+			Identifier: types.UID(key),
+			Content:    types.DataHolder{Operation: types.Command, Key: []byte(key), Content: []byte("value-" + key)},
+		})
+		if !res.Success {
+			t.Fatalf("failed committing %s. %v", key, res.Failure)
+		}
+	}
+
+	res := deliver.Commit(types.Message{Content: types.DataHolder{Operation: types.Query, Key: []byte("a")}})
+	if !res.Success || string(res.Data) != "value-a" {
+		t.Errorf("expected only value-a, found %#v", res)
+	}
+}
+
+func TestHistory_ReadThroughClient(t *testing.T) {
+	path, clean := walPath(t)
+	defer clean()
+	storage, err := definition.NewWALStorage(path, definition.NewInMemoryStorage())
+	if err != nil {
+		t.Fatalf("failed creating storage. %v", err)
+	}
+	defer storage.Close()
+
+	partitionName := types.Partition("client-history-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Storage = storage
+	conf.Logger.ToggleDebug(false)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	client, err := mcast.NewClient(mcast.DefaultClientConfiguration("client-" + helper.GenerateUID()))
+	if err != nil {
+		t.Fatalf("failed creating client. %v", err)
+	}
+	defer client.Close()
+
+	for _, key := range []string{"a", "b", "a"} {
+		select {
+		case res := <-client.Write(GenerateRequest([]byte(key), []byte(key), []types.Partition{partitionName})):
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+
+	res, err := client.Read(*mcast.NewHistoryRequest([]byte("a"), 0, 0, 0, []string{string(partitionName)}))
+	if err != nil {
+		t.Fatalf("failed reading history. %v", err)
+	}
+
+	if len(res.History) != 2 || res.Data != nil {
+		t.Errorf("expected only the 2 entries for a, found %#v", res)
+	}
+
+	res, err = client.Read(GenerateRequest([]byte("a"), nil, []types.Partition{partitionName}))
+	if err != nil || string(res.Data) != "a" || res.History != nil {
+		t.Errorf("expected only the current value, found %#v. %v", res, err)
+	}
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIdentity_ParsePartition(t *testing.T) {
+	for _, name := range []string{"orders", "orders-eu.1", "tenant:orders_v2"} {
+		if _, err := types.ParsePartition(name); err != nil {
+			t.Errorf("expected %q valid. %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"", "with space", "slash/name", "tab\t", strings.Repeat("a", types.MaxPartitionLength+1)} {
+		if _, err := types.ParsePartition(name); !errors.Is(err, types.ErrInvalidPartition) {
+			t.Errorf("expected %q invalid, found %v", name, err)
+		}
+	}
+}
+
+func TestIdentity_PeerIDRoundTrip(t *testing.T) {
+	id := types.PeerID{Partition: "orders-eu", Ordinal: 12}
+	parsed, err := types.ParsePeerID(id.String())
+	if err != nil || parsed != id {
+		t.Errorf("expected %#v, found %#v. %v", id, parsed, err)
+	}
+
+	for _, name := range []string{"orders", "-1", "orders-", "orders-x", "orders-+1", "bad name-1"} {
+		if _, err := types.ParsePeerID(name); err == nil {
+			t.Errorf("expected %q invalid", name)
+		}
+	}
+
+	expected := []string{"orders-3", "orders-4", "orders-5"}
+	if names := types.ReplicaNames("orders", 3, 3); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, found %v", expected, names)
+	}
+	if name := mcast.NewPeerConfiguration(&types.Configuration{Name: "orders", Ordinal: 3}, 1, nil).Name; name != expected[1] {
+		t.Errorf("expected peer %s, found %s", expected[1], name)
+	}
+}
+
+func TestIdentity_RejectInvalidNames(t *testing.T) {
+	if _, err := mcast.NewUnity(mcast.DefaultConfiguration("invalid name")); !errors.Is(err, types.ErrInvalidPartition) {
+		t.Errorf("expected invalid partition, found %v", err)
+	}
+
+	request := types.Request{Key: []byte("key"), Destination: []types.Partition{"orders", "orders/eu"}}
+	if err := (definition.DestinationValidator{}).Validate(request); !errors.Is(err, types.ErrInvalidRequest) {
+		t.Errorf("expected invalid destination, found %v", err)
+	}
+}
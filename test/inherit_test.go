@@ -0,0 +1,51 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func boostedWrites(t *testing.T, features types.Features) types.PartitionStats {
+	broker := NewFaultyBroker(core.NewMemoryBroker())
+	cluster := CreateClusterWith(2, "inherit", t, func(conf *types.Configuration) {
+		conf.Broker = broker
+		conf.Features = features
+	})
+	defer cluster.Off()
+
+	// The first write waits for the timestamp of the severed
+	// partition, the urgent write conflicts with it.
+	broker.Sever(cluster.Names[0], cluster.Names[1])
+	key := []byte("inherit")
+	pending := cluster.Unities[0].Write(GenerateRequest(key, []byte("pending"), cluster.Names))
+	time.Sleep(100 * time.Millisecond)
+
+	request := GenerateRequest(key, []byte("urgent"), cluster.Names[:1])
+	request.Urgent = true
+	urgent := cluster.Unities[0].Write(request)
+	time.Sleep(100 * time.Millisecond)
+	stats := cluster.Unities[0].Stats()
+
+	broker.Heal()
+	for _, res := range []types.Response{awaitResponse(t, pending), awaitResponse(t, urgent)} {
+		if !res.Success {
+			t.Errorf("failed writing %s. %v", res.Identifier, res.Failure)
+		}
+	}
+	return stats
+}
+
+func TestInherit_BoostPendingConflicting(t *testing.T) {
+	if stats := boostedWrites(t, nil); stats.Boosted == 0 {
+		t.Errorf("expected pending message boosted, found %#v", stats)
+	}
+}
+
+func TestInherit_FeatureDisabled(t *testing.T) {
+	features := types.Features{types.FeaturePriorityInheritance: false}
+	if stats := boostedWrites(t, features); stats.Boosted != 0 {
+		t.Errorf("expected nothing boosted, found %#v", stats)
+	}
+}
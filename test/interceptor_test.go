@@ -0,0 +1,65 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+var errUnauthorized = errors.New("unauthorized")
+
+func TestInterceptedUnity_ChainInOrder(t *testing.T) {
+	partitionName := types.Partition("intercepted-unity")
+	unity := CreateUnity(partitionName, t)
+	defer unity.Shutdown()
+
+	var order []string
+	tracing := func(name string) mcast.WriteInterceptor {
+		return func(request types.Request, next mcast.WriteFunc) <-chan types.Response {
+			order = append(order, name)
+			return next(request)
+		}
+	}
+	authorize := func(request types.Request, next mcast.WriteFunc) <-chan types.Response {
+		if request.Client != "allowed" {
+			return mcast.Reject(errUnauthorized)
+		}
+		return next(request)
+	}
+	tenant := func(request types.Request, next mcast.ReadFunc) (types.Response, error) {
+		request.Key = append([]byte("tenant/"), request.Key...)
+		return next(request)
+	}
+
+	intercepted := mcast.NewInterceptedUnity(unity, mcast.Interceptors{
+		Write: []mcast.WriteInterceptor{tracing("first"), tracing("second"), authorize},
+		Read:  []mcast.ReadInterceptor{tenant},
+	})
+
+	destination := []types.Partition{partitionName}
+	if res := <-intercepted.Write(GenerateRequest([]byte("key"), []byte("value"), destination)); !errors.Is(res.Failure, errUnauthorized) {
+		t.Errorf("expected unauthorized write, found %#v", res)
+	}
+
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("expected interceptors in order, found %v", order)
+	}
+
+	request := GenerateRequest([]byte("tenant/key"), []byte("value"), destination)
+	request.Client = "allowed"
+	select {
+	case res := <-intercepted.Write(request):
+		if !res.Success {
+			t.Fatalf("failed writing. %v", res.Failure)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write timeout")
+	}
+
+	res, err := intercepted.Read(GenerateRequest([]byte("key"), nil, destination))
+	if err != nil || string(res.Data) != "value" {
+		t.Errorf("expected read with tenant key, found %#v. %v", res, err)
+	}
+}
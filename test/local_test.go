@@ -0,0 +1,43 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+)
+
+func TestLocal_WriteWithoutDestination(t *testing.T) {
+	partition := types.Partition("local-" + helper.GenerateUID())
+	conf := mcast.DefaultConfiguration(partition)
+	conf.Logger.ToggleDebug(false)
+	conf.Local = true
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	key := []byte("local")
+	for _, value := range []string{"first", "second"} {
+		res := awaitResponse(t, unity.Write(types.Request{Key: key, Value: []byte(value)}))
+		if !res.Success {
+			t.Fatalf("failed writing %s. %v", value, res.Failure)
+		}
+	}
+	res, err := unity.Read(types.Request{Key: key})
+	if err != nil || string(res.Data) != "second" {
+		t.Errorf("expected second, found %s. %v", res.Data, err)
+	}
+
+	if stats := unity.Stats(); stats.Proposed != 2 || stats.Delivered != 2 {
+		t.Errorf("expected both writes proposed and delivered, found %#v", stats)
+	}
+
+	res = awaitResponse(t, unity.Write(GenerateRequest(key, []byte("other"), []types.Partition{partition, "local-other"})))
+	if res.Success || !errors.Is(res.Failure, core.ErrNotLocal) {
+		t.Errorf("expected write to other partition rejected, found %#v", res)
+	}
+}
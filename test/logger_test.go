@@ -0,0 +1,158 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/testutil"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bufferedLogger() (*definition.DefaultLogger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger := definition.NewDefaultLogger()
+	logger.Logger = log.New(&buf, "", 0)
+	return logger, &buf
+}
+
+func TestLogger_SubsystemLevels(t *testing.T) {
+	logger, buf := bufferedLogger()
+	logger.SetLevel(types.SubsystemTransport, types.LevelDebug)
+	logger.SetLevel(types.SubsystemDeliver, types.LevelWarn)
+
+	logger.Subsystem(types.SubsystemSequence).Debug("sequence debug")
+	logger.Subsystem(types.SubsystemDeliver).Info("deliver info")
+	logger.Subsystem(types.SubsystemDeliver).Warn("deliver warn")
+	logger.Subsystem(types.SubsystemPeer).Debug("peer debug")
+	logger.Subsystem(types.SubsystemPeer).Info("peer info")
+
+	expected := "[DEBUG transport.sequence]: sequence debug\n[WARN deliver]: deliver warn\n[INFO peer]: peer info\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\nfound:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	logger.ResetLevel(types.SubsystemTransport)
+	logger.ToggleDebug(true)
+	logger.Subsystem(types.SubsystemPeer).Debug("peer debug")
+	logger.Subsystem(types.SubsystemDeliver).Info("deliver info")
+	if buf.String() != "[DEBUG peer]: peer debug\n" {
+		t.Errorf("expected only the peer debug entry, found:\n%s", buf.String())
+	}
+}
+
+func TestLogger_ParseLevels(t *testing.T) {
+	levels, err := types.ParseLogLevels("info, transport=debug,queue=WARN")
+	if err != nil {
+		t.Fatalf("failed parsing levels. %v", err)
+	}
+
+	expected := map[string]types.LogLevel{"": types.LevelInfo, "transport": types.LevelDebug, "queue": types.LevelWarn}
+	for subsystem, level := range expected {
+		if levels[subsystem] != level {
+			t.Errorf("expected %s for %q, found %s", level, subsystem, levels[subsystem])
+		}
+	}
+
+	if _, err := types.ParseLogLevels("transport=verbose"); err == nil {
+		t.Errorf("unknown level should fail")
+	}
+}
+
+func TestLogger_AdjustLevelsAtRuntime(t *testing.T) {
+	logger, buf := bufferedLogger()
+	server := httptest.NewServer(mcast.LogLevelsHandler(logger))
+	defer server.Close()
+
+	transport := logger.Subsystem(types.SubsystemTransport)
+	transport.Debug("hidden")
+
+	res, err := http.Post(server.URL+"?subsystem=transport&level=debug", "application/json", nil)
+	if err != nil {
+		t.Fatalf("failed changing level. %v", err)
+	}
+	defer res.Body.Close()
+
+	var levels map[string]string
+	if err := json.NewDecoder(res.Body).Decode(&levels); err != nil {
+		t.Fatalf("failed reading levels. %v", err)
+	}
+
+	if levels["transport"] != "debug" || levels[""] != "info" {
+		t.Errorf("wrong levels %v", levels)
+	}
+
+	transport.Debug("visible")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "visible") {
+		t.Errorf("level not applied at runtime:\n%s", buf.String())
+	}
+
+	res, err = http.Post(server.URL+"?subsystem=transport&level=verbose", "application/json", nil)
+	if err != nil {
+		t.Fatalf("failed posting. %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected bad request, found %s", res.Status)
+	}
+}
+
+func TestLogger_RecordSubsystemEntries(t *testing.T) {
+	logger := testutil.NewLogger()
+	logger.SetLevel(types.SubsystemTransport, types.LevelWarn)
+	types.SubsystemLogger(logger, types.SubsystemSequence).Info("sequence info")
+	types.SubsystemLogger(logger, types.SubsystemSequence).Warnf("sequence %s", "warn")
+	logger.Debug("root debug")
+
+	entries := logger.Entries()
+	if len(entries) != 2 || entries[0].Subsystem != types.SubsystemSequence || entries[1].Level != types.LevelDebug {
+		t.Fatalf("expected the sequence warn and root debug, found %v", entries)
+	}
+	logger.AssertLogged(t, types.LevelWarn, "sequence warn")
+	logger.AssertNotLogged(t, types.LevelInfo, "sequence")
+
+	logger.Reset()
+	if len(logger.Entries()) != 0 {
+		t.Errorf("expected no entries after reset")
+	}
+}
+
+func TestLogger_WarnVersionMismatch(t *testing.T) {
+	logger := testutil.NewLogger()
+	partitionName := types.Partition("mismatch-" + helper.GenerateUID())
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger = logger
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	transport, err := reliableTransport("mismatch-sender-"+types.Partition(helper.GenerateUID()), "sender")
+	if err != nil {
+		t.Fatalf("failed creating transport. %v", err)
+	}
+	defer transport.Close()
+
+	message := types.Message{
+		Header: types.ProtocolHeader{
+			ProtocolVersion: conf.Version + 1,
+			Type:            types.Initial,
+		},
+		Identifier:  types.UID(helper.GenerateUID()),
+		State:       types.S0,
+		Destination: []types.Partition{partitionName},
+	}
+	if err := transport.Unicast(message, partitionName); err != nil {
+		t.Fatalf("failed sending. %v", err)
+	}
+	logger.AwaitLogged(t, types.LevelWarn, "not processing message", time.Second)
+}
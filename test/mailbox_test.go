@@ -0,0 +1,111 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+// A client that reads the responses only after a while must
+// still receive them, without delaying the deliveries.
+func TestMailbox_SlowClientReceivesResponses(t *testing.T) {
+	partitionName := types.Partition("mailbox-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	var responses []<-chan types.Response
+	for _, key := range []string{"first", "second", "third"} {
+		responses = append(responses, unity.Write(GenerateRequest([]byte(key), []byte("value"), []types.Partition{partitionName})))
+	}
+
+	// Waits until all the writes are delivered before reading.
+	timeout := time.After(5 * time.Second)
+	for _, key := range []string{"first", "second", "third"} {
+		for {
+			if _, err := unity.Read(GenerateRequest([]byte(key), nil, []types.Partition{partitionName})); err == nil {
+				break
+			}
+			select {
+			case <-timeout:
+				t.Fatalf("write %s not delivered", key)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	for i, res := range responses {
+		select {
+		case r, ok := <-res:
+			if !ok || !r.Success {
+				t.Errorf("write %d expected success, found %#v", i, r)
+			}
+		default:
+			t.Errorf("write %d response not available", i)
+		}
+	}
+
+	if evicted := unity.EvictedResponses(); evicted != 0 {
+		t.Errorf("expected no evicted responses, found %d", evicted)
+	}
+}
+
+// Writes to a partition that never answers, so the response only
+// arrives once the observer is removed.
+func stuckWrite(ctx context.Context, t *testing.T, name types.Partition, configure func(*types.Configuration)) (mcast.Unity, types.Response) {
+	conf := mcast.DefaultConfiguration(name)
+	conf.Logger.ToggleDebug(false)
+	configure(conf)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+
+	request := GenerateRequest([]byte("key"), []byte("value"), []types.Partition{name, name + "-missing"})
+	request.Context = ctx
+	select {
+	case res := <-unity.Write(request):
+		return unity, res
+	case <-time.After(5 * time.Second):
+		unity.Shutdown()
+		t.Fatalf("observer not removed")
+		return nil, types.Response{}
+	}
+}
+
+func TestMailbox_CancelledRequestRemovesObserver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	unity, res := stuckWrite(ctx, t, "mailbox-cancelled", func(*types.Configuration) {})
+	defer unity.Shutdown()
+
+	if !errors.Is(res.Failure, context.Canceled) {
+		t.Errorf("expected cancelled response, found %#v", res)
+	}
+	if abandoned := unity.AbandonedObservers(); abandoned != 1 {
+		t.Errorf("expected 1 abandoned observer, found %d", abandoned)
+	}
+}
+
+func TestMailbox_ObserverExpires(t *testing.T) {
+	unity, res := stuckWrite(context.Background(), t, "mailbox-expired", func(conf *types.Configuration) {
+		conf.ObserverTTL = 100 * time.Millisecond
+	})
+	defer unity.Shutdown()
+
+	if !errors.Is(res.Failure, core.ErrObserverExpired) {
+		t.Errorf("expected expired response, found %#v", res)
+	}
+	if abandoned := unity.AbandonedObservers(); abandoned != 1 {
+		t.Errorf("expected 1 abandoned observer, found %d", abandoned)
+	}
+}
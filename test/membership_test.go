@@ -0,0 +1,74 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func membershipUnity(t *testing.T, partition types.Partition, check types.MembershipCheck) mcast.Unity {
+	conf := mcast.DefaultConfiguration(partition)
+	conf.Logger.ToggleDebug(false)
+	conf.Membership = types.Membership{Members: types.StaticMembers{"membership-known"}, Check: check}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	return unity
+}
+
+func awaitResponse(t *testing.T, res <-chan types.Response) types.Response {
+	select {
+	case r := <-res:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatalf("response timeout")
+		return types.Response{}
+	}
+}
+
+func TestMembership_RejectedOnSend(t *testing.T) {
+	partition := types.Partition("membership-send")
+	unity := membershipUnity(t, partition, types.CheckOnSend)
+	defer unity.Shutdown()
+
+	res := awaitResponse(t, unity.Write(GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partition, "membership-unknown"})))
+	if res.Success || !errors.Is(res.Failure, core.ErrUnknownPartition) {
+		t.Errorf("expected unknown partition, found %#v", res)
+	}
+
+	// The own partition is always known.
+	res = awaitResponse(t, unity.Write(GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partition})))
+	if !res.Success {
+		t.Errorf("failed writing to the own partition. %v", res.Failure)
+	}
+}
+
+func TestMembership_RejectedOnReceive(t *testing.T) {
+	partition := types.Partition("membership-receive")
+	unity := membershipUnity(t, partition, types.CheckOnReceive)
+	defer unity.Shutdown()
+
+	res := awaitResponse(t, unity.Write(GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partition, "membership-unknown"})))
+	if res.Success || !errors.Is(res.Failure, core.ErrUnknownPartition) {
+		t.Errorf("expected unknown partition, found %#v", res)
+	}
+
+	client, err := mcast.NewClient(mcast.DefaultClientConfiguration("client-" + helper.GenerateUID()))
+	if err != nil {
+		t.Fatalf("failed creating client. %v", err)
+	}
+	defer client.Close()
+	res = awaitResponse(t, client.Write(GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partition, "membership-unknown"})))
+	if res.Success || !strings.Contains(res.Failure.Error(), core.ErrUnknownPartition.Error()) {
+		t.Errorf("expected unknown partition replied, found %#v", res)
+	}
+	if stats := unity.Stats(); stats.Proposed != 0 {
+		t.Errorf("expected nothing proposed, found %d", stats.Proposed)
+	}
+}
@@ -0,0 +1,157 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Every peer of a partition may send the timestamp, only the
+// greatest value from each partition must be kept.
+func TestMemo_MergeVotesFromSamePartition(t *testing.T) {
+	memo := core.NewMemo()
+	uid := types.UID("merge")
+	memo.Insert(uid, "first", 3)
+	memo.Insert(uid, "first", 7)
+	memo.Insert(uid, "first", 5)
+
+	values := memo.Read(uid)
+	if len(values) != 1 || values[0] != 7 {
+		t.Errorf("expected a single vote with 7, found %v", values)
+	}
+}
+
+// The exchange completes only after a vote from each partition
+// on the destination, votes from other partitions do not count.
+func TestMemo_CollectOnlyDestination(t *testing.T) {
+	memo := core.NewMemo()
+	uid := types.UID("collect")
+	destination := []types.Partition{"first", "second", "third"}
+	memo.Insert(uid, "first", 1)
+	memo.Insert(uid, "outsider", 10)
+	memo.Insert(uid, "second", 2)
+
+	values, complete := memo.Collect(uid, destination)
+	if complete || len(values) != 2 {
+		t.Fatalf("expected incomplete with 2 votes, found %v", values)
+	}
+
+	if missing := memo.Missing(uid, destination); len(missing) != 1 || missing[0] != "third" {
+		t.Errorf("expected third missing, found %v", missing)
+	}
+
+	memo.Insert(uid, "third", 3)
+	values, complete = memo.Collect(uid, destination)
+	if !complete || helper.MaxValue(values) != 3 {
+		t.Errorf("expected complete with max 3, found %v", values)
+	}
+}
+
+// Multiple peers of multiple partitions voting concurrently.
+func TestMemo_ConcurrentVotes(t *testing.T) {
+	memo := core.NewMemo()
+	uid := types.UID("concurrent")
+	destination := []types.Partition{"first", "second", "third"}
+	group := &sync.WaitGroup{}
+	for _, partition := range destination {
+		for peer := uint64(1); peer <= 5; peer++ {
+			group.Add(1)
+			go func(partition types.Partition, timestamp uint64) {
+				defer group.Done()
+				memo.Insert(uid, partition, timestamp)
+			}(partition, peer)
+		}
+	}
+	group.Wait()
+
+	values, complete := memo.Collect(uid, destination)
+	if !complete {
+		t.Fatalf("expected complete, found %v", values)
+	}
+
+	for _, value := range values {
+		if value != 5 {
+			t.Errorf("expected greatest vote 5, found %v", values)
+		}
+	}
+}
+
+// Only the keys not kept and without a recent vote are swept.
+func TestMemo_SweepFinishedKeys(t *testing.T) {
+	memo := core.NewMemo()
+	memo.Insert("finished", "first", 1)
+	memo.Insert("pending", "first", 2)
+	before := time.Now()
+	memo.Insert("recent", "first", 3)
+
+	removed := memo.Sweep(before, func(uid types.UID) bool {
+		return uid == "pending"
+	})
+	if removed != 1 {
+		t.Errorf("expected a single key swept, found %d", removed)
+	}
+
+	if len(memo.Read("finished")) != 0 {
+		t.Errorf("expected finished swept")
+	}
+	if len(memo.Read("pending")) != 1 || len(memo.Read("recent")) != 1 {
+		t.Errorf("expected pending and recent kept")
+	}
+}
+
+func TestMemo_SweepPreviousSet(t *testing.T) {
+	set := core.NewPreviousSet()
+	set.Append(types.Message{Identifier: "dropped"})
+	set.Append(types.Message{Identifier: "delivered"})
+
+	removed := set.Sweep(func(message types.Message) bool {
+		return message.Identifier == "dropped"
+	})
+	if snapshot := set.Snapshot(); removed != 1 || len(snapshot) != 1 || snapshot[0].Identifier != "delivered" {
+		t.Errorf("expected only delivered kept, found %d %#v", removed, snapshot)
+	}
+}
+
+// A partition voting again, as a retransmission, is merged keeping
+// the greatest vote, whatever the order the votes arrive.
+func TestMemo_DuplicateVotesIdempotent(t *testing.T) {
+	votes := [][]uint64{{2, 9, 4}, {9, 4, 2}, {4, 2, 9, 9}}
+	for _, order := range votes {
+		memo := core.NewMemo()
+		uid := types.UID("duplicate")
+		for _, vote := range order {
+			memo.Insert(uid, "first", vote)
+		}
+
+		values, complete := memo.Collect(uid, []types.Partition{"first"})
+		if !complete || len(values) != 1 || values[0] != 9 {
+			t.Errorf("expected a single vote with 9 for %v, found %v", order, values)
+		}
+	}
+}
+
+// Only a vote repeated by the same peer is a repeated vote, every
+// peer of the partition votes once.
+func TestMemo_RepeatedVoteFromSamePeer(t *testing.T) {
+	memo := core.NewMemo()
+	uid := types.UID("repeated")
+	for _, origin := range []string{"first-0", "first-1", "first-2"} {
+		if memo.Vote(uid, "first", origin, 3) {
+			t.Errorf("expected the vote of %s not repeated", origin)
+		}
+	}
+	if !memo.Vote(uid, "first", "first-1", 5) {
+		t.Errorf("expected the vote of first-1 repeated")
+	}
+	if values := memo.Read(uid); len(values) != 1 || values[0] != 5 {
+		t.Errorf("expected a single vote with 5, found %v", values)
+	}
+
+	memo.Remove(uid)
+	if memo.Vote(uid, "first", "first-1", 5) {
+		t.Errorf("expected the votes forgotten once removed")
+	}
+}
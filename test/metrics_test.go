@@ -0,0 +1,147 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Keeps the last value of each instrument by name and peer.
+type recordingMetrics struct {
+	mutex  *sync.Mutex
+	values map[string]float64
+}
+
+type recordingInstrument struct {
+	metrics *recordingMetrics
+	key     string
+}
+
+func (r *recordingMetrics) instrument(name string, labels map[string]string) recordingInstrument {
+	return recordingInstrument{metrics: r, key: name + "/" + labels[types.LabelPeer]}
+}
+
+func (r *recordingMetrics) Counter(name string, labels map[string]string) types.Counter {
+	return r.instrument(name, labels)
+}
+
+func (r *recordingMetrics) Gauge(name string, labels map[string]string) types.Gauge {
+	return r.instrument(name, labels)
+}
+
+func (r *recordingMetrics) Histogram(name string, labels map[string]string) types.Histogram {
+	return r.instrument(name, labels)
+}
+
+func (r *recordingMetrics) value(name string, peer string) float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.values[name+"/"+peer]
+}
+
+func (i recordingInstrument) Add(delta float64) {
+	i.metrics.mutex.Lock()
+	defer i.metrics.mutex.Unlock()
+	i.metrics.values[i.key] += delta
+}
+
+func (i recordingInstrument) Set(value float64) {
+	i.metrics.mutex.Lock()
+	defer i.metrics.mutex.Unlock()
+	i.metrics.values[i.key] = value
+}
+
+func (i recordingInstrument) Observe(value float64) {
+	i.Set(value)
+}
+
+func TestMetrics_ReportedByEveryPeer(t *testing.T) {
+	partitionName := types.Partition("metrics-partition")
+	metrics := &recordingMetrics{mutex: &sync.Mutex{}, values: make(map[string]float64)}
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Metrics = metrics
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case res := <-unity.Write(GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partitionName})):
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+
+	peer := mcast.NewPeerConfiguration(conf, 0, nil).Name
+	if !WaitThisOrTimeout(func() {
+		for metrics.value(types.MetricDelivered, peer) < 3 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}, 5*time.Second) {
+		t.Fatalf("expected 3 delivered, found %v", metrics.value(types.MetricDelivered, peer))
+	}
+	if proposed := metrics.value(types.MetricProposed, peer); proposed != 3 {
+		t.Errorf("expected 3 proposed, found %v", proposed)
+	}
+	if destinations := metrics.value(types.MetricDestinations, peer); destinations != 1 {
+		t.Errorf("expected a single destination, found %v", destinations)
+	}
+	if clock := metrics.value(types.MetricClock, peer); clock == 0 {
+		t.Errorf("expected the clock reported")
+	}
+}
+
+// Every peer of the second partition sends its timestamp once, so
+// without retransmissions no timestamp is counted as duplicated.
+func TestMetrics_NoDuplicateTimestampsWithoutRetransmission(t *testing.T) {
+	partitionOne := types.Partition("metrics-duplicate-one")
+	partitionTwo := types.Partition("metrics-duplicate-two")
+	metrics := &recordingMetrics{mutex: &sync.Mutex{}, values: make(map[string]float64)}
+	conf := mcast.DefaultConfiguration(partitionOne)
+	conf.Logger.ToggleDebug(false)
+	conf.Replication = 3
+	conf.Metrics = metrics
+	unityOne, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	unityTwo := CreateUnity(partitionTwo, t)
+	defer unityOne.Shutdown()
+	defer unityTwo.Shutdown()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case res := <-unityOne.Write(GenerateRandomRequest([]types.Partition{partitionOne, partitionTwo})):
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+
+	if !WaitThisOrTimeout(func() {
+		for unityOne.Stats().Delivered < 3 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}, 5*time.Second) {
+		t.Fatalf("messages not delivered %#v", unityOne.Stats())
+	}
+	for i := 0; i < conf.Replication; i++ {
+		peer := mcast.NewPeerConfiguration(conf, i, nil).Name
+		if duplicates := metrics.value(types.MetricDuplicates, peer); duplicates != 0 {
+			t.Errorf("expected no duplicates on %s, found %v", peer, duplicates)
+		}
+	}
+	if stats := unityOne.Stats(); stats.Duplicates != 0 {
+		t.Errorf("expected no duplicates on the stats, found %#v", stats)
+	}
+}
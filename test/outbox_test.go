@@ -0,0 +1,214 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A transport that records the sent messages and can
+// be configured to fail all sends.
+type recordingTransport struct {
+	mutex      *sync.Mutex
+	fail       bool
+	sent       []types.Message
+	partitions []types.Partition
+	listen     chan types.Message
+}
+
+func newRecordingTransport(fail bool) *recordingTransport {
+	return &recordingTransport{
+		mutex:  &sync.Mutex{},
+		fail:   fail,
+		listen: make(chan types.Message),
+	}
+}
+
+func (r *recordingTransport) Broadcast(message types.Message) error {
+	return r.Unicast(message, "")
+}
+
+func (r *recordingTransport) Unicast(message types.Message, partition types.Partition) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.fail {
+		return errors.New("transport unavailable")
+	}
+	r.sent = append(r.sent, message)
+	r.partitions = append(r.partitions, partition)
+	return nil
+}
+
+func (r *recordingTransport) Sent() ([]types.Message, []types.Partition) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]types.Message{}, r.sent...), append([]types.Partition{}, r.partitions...)
+}
+
+func (r *recordingTransport) Listen() <-chan types.Message {
+	return r.listen
+}
+
+func (r *recordingTransport) Close() {}
+
+func TestOutbox_AcknowledgedMessagesAreRemoved(t *testing.T) {
+	storage := definition.NewInMemoryStorage()
+	transport := newRecordingTransport(false)
+	outbox := core.NewOutboxTransport(transport, storage, "outbox-peer", definition.NewDefaultLogger())
+
+	message := types.Message{Identifier: types.UID(helper.GenerateUID())}
+	if err := outbox.Broadcast(message); err != nil {
+		t.Fatalf("failed broadcasting. %v", err)
+	}
+
+	if err := outbox.Unicast(message, "partition"); err != nil {
+		t.Fatalf("failed unicast. %v", err)
+	}
+
+	if len(transport.sent) != 2 {
+		t.Errorf("expected 2 messages sent, found %d", len(transport.sent))
+	}
+
+	if pending := outbox.Pending(); len(pending) != 0 {
+		t.Errorf("expected empty outbox, found %d", len(pending))
+	}
+}
+
+// A transport that holds every send until released, so
+// a test can crash the peer while sends are in flight.
+type blockingTransport struct {
+	recordingTransport
+	release chan struct{}
+	sending chan types.Message
+}
+
+func newBlockingTransport() *blockingTransport {
+	return &blockingTransport{
+		recordingTransport: *newRecordingTransport(true),
+		release:            make(chan struct{}),
+		sending:            make(chan types.Message),
+	}
+}
+
+func (b *blockingTransport) Broadcast(message types.Message) error {
+	return b.Unicast(message, "")
+}
+
+func (b *blockingTransport) Unicast(message types.Message, partition types.Partition) error {
+	b.sending <- message
+	<-b.release
+	return b.recordingTransport.Unicast(message, partition)
+}
+
+func TestOutbox_ReplayPendingOnRestart(t *testing.T) {
+	storage := definition.NewInMemoryStorage()
+	blocking := newBlockingTransport()
+	defer close(blocking.release)
+	outbox := core.NewOutboxTransport(blocking, storage, "outbox-peer", definition.NewDefaultLogger())
+
+	var messages []types.Message
+	for i := 0; i < 3; i++ {
+		message := types.Message{Identifier: types.UID(helper.GenerateUID())}
+		messages = append(messages, message)
+		go outbox.Unicast(message, "partition")
+		<-blocking.sending
+	}
+
+	pending := outbox.Pending()
+	if len(pending) != len(messages) {
+		t.Fatalf("expected %d pending messages, found %d", len(messages), len(pending))
+	}
+
+	for i, outgoing := range pending {
+		if outgoing.Partition != "partition" || outgoing.Message.Identifier != messages[i].Identifier {
+			t.Errorf("wrong pending message at %d %#v", i, outgoing)
+		}
+	}
+
+	// The peer crashed while the sends were in flight.
+	working := newRecordingTransport(false)
+	restarted := core.NewOutboxTransport(working, storage, "outbox-peer", definition.NewDefaultLogger())
+	sent, _ := working.Sent()
+	if len(sent) != len(messages) {
+		t.Fatalf("expected %d messages replayed, found %d", len(messages), len(sent))
+	}
+
+	for i, message := range sent {
+		if message.Identifier != messages[i].Identifier {
+			t.Errorf("message replayed out of order at %d", i)
+		}
+	}
+
+	if pending := restarted.Pending(); len(pending) != 0 {
+		t.Errorf("expected empty outbox after replay, found %d", len(pending))
+	}
+}
+
+func TestOutbox_FailedMessagesAreNotReplayed(t *testing.T) {
+	storage := definition.NewInMemoryStorage()
+	failing := newRecordingTransport(true)
+	outbox := core.NewOutboxTransport(failing, storage, "outbox-peer", definition.NewDefaultLogger())
+
+	message := types.Message{Identifier: types.UID(helper.GenerateUID())}
+	if err := outbox.Unicast(message, "partition"); err == nil {
+		t.Fatalf("unicast should fail")
+	}
+
+	if pending := outbox.Pending(); len(pending) != 0 {
+		t.Errorf("expected failed message removed, found %d", len(pending))
+	}
+
+	working := newRecordingTransport(false)
+	core.NewOutboxTransport(working, storage, "outbox-peer", definition.NewDefaultLogger())
+	if sent, _ := working.Sent(); len(sent) != 0 {
+		t.Errorf("failed message replayed on restart %#v", sent)
+	}
+}
+
+func TestOutbox_EvictingStorageRejected(t *testing.T) {
+	conf := mcast.DefaultConfiguration("outbox-evicting")
+	conf.Logger.ToggleDebug(false)
+	conf.Storage = definition.NewInMemoryStorageWith(definition.InMemoryStorageOptions{MaxEntries: 1024})
+	if _, err := NewTestingUnity(conf); !errors.Is(err, core.ErrEvictingOutbox) {
+		t.Fatalf("expected evicting storage rejected, found %v", err)
+	}
+
+	conf.Storage = definition.NewInMemoryStorage()
+	conf.Outbox = definition.NewInMemoryStorageWith(definition.InMemoryStorageOptions{TTL: time.Minute})
+	if _, err := NewTestingUnity(conf); !errors.Is(err, core.ErrEvictingOutbox) {
+		t.Fatalf("expected evicting outbox rejected, found %v", err)
+	}
+}
+
+func TestOutbox_DedicatedStorage(t *testing.T) {
+	partition := types.Partition("outbox-dedicated")
+	outbox := definition.NewInMemoryStorage()
+	conf := mcast.DefaultConfiguration(partition)
+	conf.Logger.ToggleDebug(false)
+	conf.Storage = definition.NewInMemoryStorageWith(definition.InMemoryStorageOptions{MaxEntries: 1024})
+	conf.Outbox = outbox
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	select {
+	case res := <-unity.Write(GenerateRandomRequest([]types.Partition{partition})):
+		if !res.Success {
+			t.Fatalf("failed writing. %v", res.Failure)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write timeout")
+	}
+
+	if outbox.Len() == 0 {
+		t.Errorf("expected the outbox persisted on the dedicated storage")
+	}
+}
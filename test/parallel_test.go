@@ -0,0 +1,122 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A state machine recording the commits of each key and
+// how many commits were running at once.
+type concurrentStorage struct {
+	*types.InMemoryStateMachine
+	types.Storage
+
+	mutex    *sync.Mutex
+	running  int
+	greatest int
+	order    map[string][]types.UID
+}
+
+func newConcurrentStorage() *concurrentStorage {
+	storage := definition.NewInMemoryStorage()
+	return &concurrentStorage{
+		InMemoryStateMachine: types.NewStateMachine(storage),
+		Storage:              storage,
+		mutex:                &sync.Mutex{},
+		order:                make(map[string][]types.UID),
+	}
+}
+
+func (c *concurrentStorage) Commit(entry *types.Entry) (interface{}, error) {
+	c.mutex.Lock()
+	c.running++
+	if c.running > c.greatest {
+		c.greatest = c.running
+	}
+	c.order[string(entry.Key)] = append(c.order[string(entry.Key)], entry.Identifier)
+	c.mutex.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+	res, err := c.InMemoryStateMachine.Commit(entry)
+
+	c.mutex.Lock()
+	c.running--
+	c.mutex.Unlock()
+	return res, err
+}
+
+func (c *concurrentStorage) Greatest() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.greatest
+}
+
+func keyedMessages(keys []string, each int) []types.Message {
+	var messages []types.Message
+	for i := 0; i < each; i++ {
+		for _, key := range keys {
+			uid := types.UID(fmt.Sprintf("%s-%d", key, i))
+			messages = append(messages, types.Message{
+				Identifier: uid,
+				Content:    types.DataHolder{Operation: types.Command, Key: []byte(key), Content: []byte(uid)},
+			})
+		}
+	}
+	return messages
+}
+
+func TestDeliver_CommitClassesConcurrently(t *testing.T) {
+	storage := newConcurrentStorage()
+	deliver, err := core.NewParallelDeliver(context.Background(), definition.NewDefaultLogger(), &definition.KeyConflict{}, storage, 4)
+	if err != nil {
+		t.Fatalf("failed creating deliver. %v", err)
+	}
+
+	keys := []string{"a", "b", "c", "d"}
+	messages := keyedMessages(keys, 3)
+	responses := deliver.CommitBatch(messages)
+	for i, res := range responses {
+		if !res.Success || res.Identifier != messages[i].Identifier {
+			t.Errorf("expected %s committed, found %#v", messages[i].Identifier, res)
+		}
+	}
+
+	if storage.Greatest() < 2 {
+		t.Errorf("expected concurrent commits, found %d at once", storage.Greatest())
+	}
+
+	for _, key := range keys {
+		order := storage.order[key]
+		for i, uid := range order {
+			if expected := types.UID(fmt.Sprintf("%s-%d", key, i)); uid != expected {
+				t.Errorf("key %s committed %v out of order", key, order)
+				break
+			}
+		}
+	}
+}
+
+func TestDeliver_CommitConflictingInOrder(t *testing.T) {
+	storage := newConcurrentStorage()
+	deliver, err := core.NewParallelDeliver(context.Background(), definition.NewDefaultLogger(), &definition.AlwaysConflict{}, storage, 4)
+	if err != nil {
+		t.Fatalf("failed creating deliver. %v", err)
+	}
+
+	messages := keyedMessages([]string{"a", "b"}, 3)
+	for i, res := range deliver.CommitBatch(messages) {
+		if !res.Success || res.Identifier != messages[i].Identifier {
+			t.Errorf("expected %s committed, found %#v", messages[i].Identifier, res)
+		}
+	}
+
+	if storage.Greatest() != 1 {
+		t.Errorf("conflicting messages committed concurrently, %d at once", storage.Greatest())
+	}
+}
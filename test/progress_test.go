@@ -0,0 +1,90 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+// Read the progress events until the request is delivered everywhere.
+func collectProgress(t *testing.T, events <-chan types.Progress) []types.Progress {
+	var progress []types.Progress
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			progress = append(progress, event)
+			if event.Stage == types.ProgressDeliveredEverywhere {
+				return progress
+			}
+		case <-timeout:
+			t.Fatalf("not delivered everywhere, found %#v", progress)
+			return progress
+		}
+	}
+}
+
+func TestUnity_ProgressOfRequestWithManyDestinations(t *testing.T) {
+	partitions := []types.Partition{"progress-first", "progress-second", "progress-third"}
+	unity := CreateUnity(partitions[0], t)
+	defer unity.Shutdown()
+	for _, partition := range partitions[1:] {
+		other := CreateUnity(partition, t)
+		defer other.Shutdown()
+	}
+
+	events := make(chan types.Progress, 10)
+	request := GenerateRequest([]byte("key"), []byte("value"), partitions)
+	request.Progress = events
+	select {
+	case res := <-unity.Write(request):
+		if !res.Success {
+			t.Fatalf("failed writing. %v", res.Failure)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write timeout")
+	}
+
+	expected := []types.ProgressStage{
+		types.ProgressProposed,
+		types.ProgressExchanged,
+		types.ProgressDelivered,
+		types.ProgressDeliveredEverywhere,
+	}
+	progress := collectProgress(t, events)
+	if len(progress) != len(expected) {
+		t.Fatalf("expected %d stages, found %#v", len(expected), progress)
+	}
+	for i, event := range progress {
+		if event.Stage != expected[i] {
+			t.Errorf("expected stage %s, found %s", expected[i], event.Stage)
+		}
+		if event.Identifier != progress[0].Identifier {
+			t.Errorf("progress of another request %s", event.Identifier)
+		}
+	}
+	if progress[1].Timestamp < progress[0].Timestamp {
+		t.Errorf("final timestamp %d lower than proposed %d", progress[1].Timestamp, progress[0].Timestamp)
+	}
+}
+
+func TestUnity_ProgressOfRequestWithSingleDestination(t *testing.T) {
+	partition := types.Partition("progress-single")
+	unity := CreateUnity(partition, t)
+	defer unity.Shutdown()
+
+	events := make(chan types.Progress, 10)
+	request := GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partition})
+	request.Progress = events
+	<-unity.Write(request)
+
+	progress := collectProgress(t, events)
+	for _, event := range progress {
+		if event.Stage == types.ProgressExchanged {
+			t.Errorf("single destination exchanged timestamps")
+		}
+	}
+	if len(progress) != 3 || progress[0].Stage != types.ProgressProposed {
+		t.Errorf("unexpected progress %#v", progress)
+	}
+}
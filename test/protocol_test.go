@@ -208,3 +208,45 @@ func TestProtocol_TwoPartitionsSingleParticipant(t *testing.T) {
 		t.Errorf("retrieved response should be %s but was %s", string(value), string(res.Data))
 	}
 }
+
+// While the delivery is paused no value is committed on
+// the state machine, after resuming the buffered messages
+// are committed and the response is sent back.
+func TestProtocol_PauseAndResumeDelivery(t *testing.T) {
+	partitionName := types.Partition("paused-unity")
+	unity := CreateUnity(partitionName, t)
+	defer unity.Shutdown()
+	key := []byte("paused-key")
+	value := []byte("paused-value")
+
+	unity.PauseDelivery()
+	obs := unity.Write(GenerateRequest(key, value, []types.Partition{partitionName}))
+	select {
+	case res := <-obs:
+		t.Fatalf("should not commit while paused %#v", res)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	if res, _ := unity.Read(GenerateRequest(key, nil, []types.Partition{partitionName})); res.Success {
+		t.Fatalf("value should not be readable while paused")
+	}
+
+	unity.ResumeDelivery()
+	select {
+	case res := <-obs:
+		if !res.Success {
+			t.Fatalf("failed writing request %v", res.Failure)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("write timeout after resuming")
+	}
+
+	res, err := unity.Read(GenerateRequest(key, nil, []types.Partition{partitionName}))
+	if err != nil {
+		t.Fatalf("failed reading value. %v", err)
+	}
+
+	if !bytes.Equal(value, res.Data) {
+		t.Errorf("retrieved response should be %s but was %s", string(value), string(res.Data))
+	}
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/rebalance"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A client keeping the values of each partition in memory.
+type partitionedClient struct {
+	mutex  *sync.Mutex
+	values map[types.Partition]map[string][]byte
+}
+
+func newPartitionedClient() *partitionedClient {
+	return &partitionedClient{mutex: &sync.Mutex{}, values: make(map[types.Partition]map[string][]byte)}
+}
+
+func (p *partitionedClient) Write(request types.Request) <-chan types.Response {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, partition := range request.Destination {
+		if p.values[partition] == nil {
+			p.values[partition] = make(map[string][]byte)
+		}
+		p.values[partition][string(request.Key)] = request.Value
+	}
+	res := make(chan types.Response, 1)
+	res <- types.Response{Success: true, Data: request.Value}
+	return res
+}
+
+func (p *partitionedClient) Read(request types.Request) (types.Response, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	value, ok := p.values[request.Destination[0]][string(request.Key)]
+	if !ok {
+		return types.Response{}, errors.New("not found")
+	}
+	return types.Response{Success: true, Data: value}, nil
+}
+
+func (p *partitionedClient) Value(partition types.Partition, key string) []byte {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.values[partition][key]
+}
+
+func firstLetterPolicy(partitions ...types.Partition) rebalance.Policy {
+	return rebalance.PolicyFunc(func(key []byte) types.Partition {
+		return partitions[int(key[0])%len(partitions)]
+	})
+}
+
+func TestRebalancer_MoveKeysToNewPartition(t *testing.T) {
+	client := newPartitionedClient()
+	router := rebalance.NewRouter(client, firstLetterPolicy("first"))
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+	for _, key := range keys {
+		if res := <-router.Write(types.Request{Key: key, Value: key}); !res.Success {
+			t.Fatalf("failed writing %s. %v", key, res.Failure)
+		}
+	}
+
+	policy := firstLetterPolicy("first", "second")
+	report, err := rebalance.NewRebalancer(router, time.Second).Rebalance(policy, keys)
+	if err != nil {
+		t.Fatalf("failed rebalancing. %v", err)
+	}
+
+	if report.Moved != 2 || report.Unchanged != 2 {
+		t.Errorf("expected 2 keys moved, found %#v", report)
+	}
+
+	for _, key := range keys {
+		partition := policy.Partition(key)
+		if router.Partition(key) != partition {
+			t.Errorf("expected %s routed to %s", key, partition)
+		}
+
+		if value := client.Value(partition, string(key)); string(value) != string(key) {
+			t.Errorf("expected %s on %s, found %s", key, partition, value)
+		}
+	}
+
+	if res := <-router.Write(types.Request{Key: []byte("a"), Value: []byte("new")}); !res.Success {
+		t.Fatalf("failed writing after rebalance. %v", res.Failure)
+	}
+
+	if value, err := router.Read(types.Request{Key: []byte("a")}); err != nil || string(value.Data) != "new" {
+		t.Errorf("expected new value read from the new partition, found %s. %v", value.Data, err)
+	}
+}
+
+func TestRebalancer_StopOnFailure(t *testing.T) {
+	client := newPartitionedClient()
+	old := firstLetterPolicy("first")
+	router := rebalance.NewRouter(client, old)
+	policy := firstLetterPolicy("first", "second")
+
+	// The key was never written, so the export fails.
+	if _, err := rebalance.NewRebalancer(router, time.Second).Rebalance(policy, [][]byte{[]byte("a")}); err == nil {
+		t.Fatalf("expected failure exporting missing key")
+	}
+
+	if partition := router.Partition([]byte("a")); partition != "first" {
+		t.Errorf("expected key kept on the old partition, found %s", partition)
+	}
+}
+
+// Records the partitions notified when decommissioning.
+type recordingDecommissioner struct {
+	notified []types.Partition
+}
+
+func (r *recordingDecommissioner) Decommission(notify []types.Partition, timeout time.Duration) error {
+	r.notified = notify
+	return nil
+}
+
+func TestRebalancer_DecommissionAfterMovingKeys(t *testing.T) {
+	client := newPartitionedClient()
+	router := rebalance.NewRouter(client, firstLetterPolicy("first", "second"))
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+	for _, key := range keys {
+		if res := <-router.Write(types.Request{Key: key, Value: key}); !res.Success {
+			t.Fatalf("failed writing %s. %v", key, res.Failure)
+		}
+	}
+
+	unity := &recordingDecommissioner{}
+	rebalancer := rebalance.NewRebalancer(router, time.Second)
+	if _, err := rebalancer.Decommission(unity, "second", firstLetterPolicy("first", "second"), keys, nil); !errors.Is(err, rebalance.ErrStillOwner) {
+		t.Fatalf("expected policy still owning keys, found %v", err)
+	}
+	if unity.notified != nil {
+		t.Fatalf("expected partition not decommissioned")
+	}
+
+	report, err := rebalancer.Decommission(unity, "second", firstLetterPolicy("first"), keys, []types.Partition{"first"})
+	if err != nil {
+		t.Fatalf("failed decommissioning. %v", err)
+	}
+	if report.Moved != 2 || len(unity.notified) != 1 || unity.notified[0] != "first" {
+		t.Errorf("expected 2 keys moved and first notified, found %#v and %v", report, unity.notified)
+	}
+	for _, key := range keys {
+		if router.Partition(key) != "first" {
+			t.Errorf("expected %s routed to first", key)
+		}
+		if value := client.Value("first", string(key)); string(value) != string(key) {
+			t.Errorf("expected %s on first, found %s", key, value)
+		}
+	}
+}
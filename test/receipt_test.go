@@ -0,0 +1,95 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnity_WriteConcernSatisfied(t *testing.T) {
+	partitions := []types.Partition{"receipt-first", "receipt-second"}
+	unity := CreateUnity(partitions[0], t)
+	defer unity.Shutdown()
+	other := CreateUnity(partitions[1], t)
+	defer other.Shutdown()
+
+	request := GenerateRequest([]byte("key"), []byte("value"), partitions)
+	request.Acknowledgments = 2
+	select {
+	case res := <-unity.Write(request):
+		if !res.Success {
+			t.Fatalf("failed writing. %v", res.Failure)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write timeout")
+	}
+}
+
+func TestUnity_WriteConcernNotSatisfied(t *testing.T) {
+	partition := types.Partition("receipt-alone")
+	unity := CreateUnity(partition, t)
+	defer unity.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	request := GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partition, "receipt-absent"})
+	request.Acknowledgments = 2
+	request.Context = ctx
+	select {
+	case res := <-unity.Write(request):
+		if res.Success || !errors.Is(res.Failure, core.ErrWriteConcern) {
+			t.Fatalf("expected write concern failure, found %#v", res)
+		}
+		if !strings.Contains(res.Failure.Error(), "1 of 2") {
+			t.Errorf("expected the own partition acknowledged, found %v", res.Failure)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write timeout")
+	}
+}
+
+func TestUnity_WriteConcernAboveDestination(t *testing.T) {
+	partition := types.Partition("receipt-invalid")
+	unity := CreateUnity(partition, t)
+	defer unity.Shutdown()
+
+	request := GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partition})
+	request.Acknowledgments = 2
+	res := <-unity.Write(request)
+	if !errors.Is(res.Failure, types.ErrInvalidRequest) {
+		t.Errorf("expected invalid request, found %v", res.Failure)
+	}
+}
+
+func TestClient_WriteConcern(t *testing.T) {
+	partition := types.Partition("receipt-client")
+	unity := CreateUnity(partition, t)
+	defer unity.Shutdown()
+
+	conf := mcast.DefaultClientConfiguration("client-" + helper.GenerateUID())
+	conf.Timeout = time.Second
+	client, err := mcast.NewClient(conf)
+	if err != nil {
+		t.Fatalf("failed creating client. %v", err)
+	}
+	defer client.Close()
+
+	write := GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partition})
+	write.Acknowledgments = 1
+	if res := <-client.Write(write); !res.Success {
+		t.Fatalf("failed writing. %v", res.Failure)
+	}
+
+	write = GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partition, "receipt-client-absent"})
+	write.Acknowledgments = 2
+	res := <-client.Write(write)
+	if res.Success || !errors.Is(res.Failure, core.ErrWriteConcern) {
+		t.Errorf("expected write concern failure, found %#v", res)
+	}
+}
@@ -0,0 +1,231 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/replay"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func replayEvent(peer string, kind types.EventKind, state types.MessageState, timestamp uint64, at int) types.Event {
+	return types.Event{
+		Kind:      kind,
+		Peer:      peer,
+		State:     state,
+		Timestamp: timestamp,
+		Time:      time.Unix(0, 0).Add(time.Duration(at) * time.Millisecond),
+	}
+}
+
+func replayDelivered(recorder *types.EventRecorder, uid types.UID) int {
+	delivered := 0
+	for _, event := range recorder.Dump()[uid] {
+		if event.Kind == types.EventDelivered {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+func TestReplay_RecordedUnity(t *testing.T) {
+	partitionName := types.Partition("replay-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Recorder = types.NewEventRecorder(64, 64)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	for _, key := range []string{"first", "second", "third"} {
+		select {
+		case res := <-unity.Write(GenerateRequest([]byte(key), []byte("value"), []types.Partition{partitionName})):
+			if !res.Success {
+				t.Fatalf("failed writing %s. %v", key, res.Failure)
+			}
+			// The replicas deliver independently of each other,
+			// so the next write waits until every replica delivers.
+			if !WaitThisOrTimeout(func() {
+				for replayDelivered(conf.Recorder, res.Identifier) < conf.Replication {
+					time.Sleep(time.Millisecond)
+				}
+			}, 5*time.Second) {
+				t.Fatalf("timeout delivering %s on every replica", key)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout writing %s", key)
+		}
+	}
+
+	data, err := json.Marshal(conf.Recorder.Dump())
+	if err != nil {
+		t.Fatalf("failed encoding events. %v", err)
+	}
+	events, err := replay.Load(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed loading events. %v", err)
+	}
+
+	report := replay.Replay(events, time.Time{})
+	if len(report.Violations) > 0 {
+		t.Errorf("expected no violations, found %v", report.Violations)
+	}
+	if report.Events == 0 || len(report.Delivered) == 0 {
+		t.Errorf("expected events replayed, found %#v", report)
+	}
+}
+
+func TestReplay_DivergentDelivery(t *testing.T) {
+	events := map[types.UID][]types.Event{
+		"first": {
+			replayEvent("peer-0", types.EventDelivered, types.S3, 1, 1),
+			replayEvent("peer-1", types.EventDelivered, types.S3, 1, 2),
+		},
+		"second": {
+			replayEvent("peer-0", types.EventDelivered, types.S3, 2, 2),
+			replayEvent("peer-1", types.EventDelivered, types.S3, 2, 1),
+		},
+	}
+
+	report := replay.Replay(events, time.Time{})
+	if len(report.Violations) != 1 || report.Violations[0].Peer != "peer-1" {
+		t.Errorf("expected divergence on peer-1, found %v", report.Violations)
+	}
+}
+
+func TestReplay_IllegalTransitionUntil(t *testing.T) {
+	events := map[types.UID][]types.Event{
+		"message": {
+			replayEvent("peer-0", types.EventStateChanged, types.S1, 2, 1),
+			replayEvent("peer-0", types.EventStateChanged, types.S2, 1, 2),
+			replayEvent("peer-0", types.EventDelivered, types.S2, 1, 3),
+		},
+	}
+
+	report := replay.Replay(events, time.Time{})
+	if len(report.Violations) != 2 || !strings.Contains(report.Violations[0].Reason, "timestamp decreased") {
+		t.Errorf("expected timestamp and delivery violations, found %v", report.Violations)
+	}
+
+	report = replay.Replay(events, time.Unix(0, 0).Add(time.Millisecond))
+	if len(report.Violations) != 0 || report.Events != 1 {
+		t.Errorf("expected only first event replayed, found %#v", report)
+	}
+}
+
+func TestReplay_RestoreQueue(t *testing.T) {
+	dump := types.QueueDump{
+		Peer:      "replay-queue-0",
+		Partition: "replay-queue",
+		Messages: []types.Message{
+			{Identifier: "first", State: types.S1, Timestamp: 1},
+			{Identifier: "second", State: types.S3, Timestamp: 2},
+		},
+	}
+	data, err := json.Marshal([]types.QueueDump{dump})
+	if err != nil {
+		t.Fatalf("failed encoding queue. %v", err)
+	}
+	dumps, err := replay.LoadQueues(bytes.NewReader(data))
+	if err != nil || len(dumps) != 1 {
+		t.Fatalf("failed loading queue, found %d. %v", len(dumps), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	delivered := make(chan types.UID, len(dump.Messages))
+	queue := replay.RestoreQueue(ctx, dumps[0], definition.AlwaysConflict{}, func(message types.Message) {
+		delivered <- message.Identifier
+	})
+
+	// The head is not ready, so the second message waits.
+	pending := queue.Pending()
+	if len(pending) != 2 || pending[0].Identifier != "first" || pending[1].State != types.S3 {
+		t.Fatalf("expected dumped messages pending, found %#v", pending)
+	}
+	select {
+	case uid := <-delivered:
+		t.Fatalf("expected no delivery while the head is on S1, found %s", uid)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	queue.Enqueue(types.Message{Identifier: "first", State: types.S3, Timestamp: 1})
+	for _, expected := range []types.UID{"first", "second"} {
+		select {
+		case uid := <-delivered:
+			if uid != expected {
+				t.Errorf("expected %s delivered, found %s", expected, uid)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout delivering %s", expected)
+		}
+	}
+}
+
+func TestReplay_QueueHandlerDump(t *testing.T) {
+	partitionName := types.Partition("replay-dump")
+	absent := types.Partition("replay-dump-absent")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Retry = types.RetryPolicy{}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	// The absent partition never answers, so the message is stuck.
+	unity.Write(GenerateRandomRequest([]types.Partition{partitionName, absent}))
+	stuck := func() bool {
+		for _, dump := range unity.Dump() {
+			if len(dump.Messages) != 1 || dump.Messages[0].State != types.S1 {
+				return false
+			}
+		}
+		return true
+	}
+	if !WaitThisOrTimeout(func() {
+		for !stuck() {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}, 5*time.Second) {
+		t.Fatalf("expected message pending on S1")
+	}
+
+	w := httptest.NewRecorder()
+	mcast.QueueHandler(unity).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/queue", nil))
+	dumps, err := replay.LoadQueues(w.Body)
+	if err != nil || len(dumps) != conf.Replication {
+		t.Fatalf("expected queue of every peer, found %d. %v", len(dumps), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, dump := range dumps {
+		queue := replay.RestoreQueue(ctx, dump, definition.AlwaysConflict{}, func(message types.Message) {
+			t.Errorf("expected stuck message not delivered, found %s", message.Identifier)
+		})
+		pending := queue.Pending()
+		if len(pending) != 1 || pending[0].Identifier != dump.Messages[0].Identifier {
+			t.Errorf("expected %s restored, found %#v", dump.Peer, pending)
+		}
+		if len(pending[0].Destination) != 2 || pending[0].Timestamp != dump.Messages[0].Timestamp {
+			t.Errorf("expected message restored as dumped, found %#v", pending[0])
+		}
+	}
+
+	w = httptest.NewRecorder()
+	mcast.QueueHandler(unity).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/queue", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected post rejected, found %d", w.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package test
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"hash/crc32"
+	"testing"
+	"time"
+)
+
+func TestShipper_SubscribeFromPosition(t *testing.T) {
+	shipper := core.NewShipper("peer-0", 2)
+	if entries, head, ok := shipper.Subscribe("replica", 0, ""); !ok || head != 0 || len(entries) != 0 {
+		t.Fatalf("expected empty log, found %d entries until %d", len(entries), head)
+	}
+
+	shipped, replicas := shipper.Append([]types.Message{{Identifier: "a"}, {Identifier: "b"}, {Identifier: "c"}})
+	if len(shipped) != 3 || shipped[2].Header.Index != 3 {
+		t.Fatalf("expected entries positioned until 3, found %#v", shipped)
+	}
+	if len(replicas) != 1 || replicas[0] != "replica" {
+		t.Fatalf("expected replica subscribed, found %v", replicas)
+	}
+
+	if _, _, ok := shipper.Subscribe("replica", 1, "peer-0"); ok {
+		t.Errorf("expected first entry not held anymore")
+	}
+	entries, head, ok := shipper.Subscribe("replica", 2, "peer-0")
+	if !ok || head != 3 || len(entries) != 2 || entries[0].Identifier != "b" {
+		t.Errorf("expected entries from 2 until 3, found %#v until %d", entries, head)
+	}
+
+	shipper.Subscribe("replica", 4, "peer-1")
+	if shipper.Subscribed("replica") {
+		t.Errorf("expected replica removed after subscribing to another peer")
+	}
+}
+
+func TestShipper_ResumeSnapshot(t *testing.T) {
+	shipper := core.NewShipper("peer-0", 2)
+	shipper.Append([]types.Message{{Identifier: "a"}, {Identifier: "b"}, {Identifier: "c"}})
+
+	reads := 0
+	read := func() ([]byte, error) {
+		reads++
+		return []byte(fmt.Sprintf("snapshot-%d", reads)), nil
+	}
+	chunks, entries, err := shipper.Snapshot(types.Chunk{}, 4, read)
+	if err != nil || len(chunks) != 3 || len(entries) != 0 {
+		t.Fatalf("expected snapshot in 3 chunks, found %d chunks and %d entries. %v", len(chunks), len(entries), err)
+	}
+	var data []byte
+	for _, chunk := range chunks {
+		if chunk.Header.Chunk.Offset != uint64(len(data)) || chunk.Header.Chunk.Position != 3 || chunk.Header.Chunk.Total != 10 {
+			t.Fatalf("unexpected chunk %#v", chunk.Header.Chunk)
+		}
+		if crc32.ChecksumIEEE(chunk.Content.Content) != chunk.Header.Chunk.Checksum {
+			t.Fatalf("chunk %d with wrong checksum", chunk.Header.Chunk.Offset)
+		}
+		data = append(data, chunk.Content.Content...)
+	}
+	if string(data) != "snapshot-1" {
+		t.Fatalf("expected snapshot-1, found %s", data)
+	}
+
+	shipper.Append([]types.Message{{Identifier: "d"}})
+	chunks, entries, err = shipper.Snapshot(types.Chunk{Position: 3, Offset: 4, Total: 10}, 4, read)
+	if err != nil || reads != 1 {
+		t.Fatalf("expected the same snapshot resumed, read %d times. %v", reads, err)
+	}
+	if len(chunks) != 2 || chunks[0].Header.Chunk.Offset != 4 || string(chunks[0].Content.Content) != "shot" {
+		t.Errorf("expected snapshot resumed from 4, found %#v", chunks)
+	}
+	if len(entries) != 1 || entries[0].Identifier != "d" {
+		t.Errorf("expected entry after the snapshot, found %#v", entries)
+	}
+
+	shipper.Append([]types.Message{{Identifier: "e"}, {Identifier: "f"}})
+	chunks, entries, err = shipper.Snapshot(types.Chunk{Position: 3, Offset: 4, Total: 10}, 4, read)
+	if err != nil || reads != 2 {
+		t.Fatalf("expected a new snapshot once the log is truncated, read %d times. %v", reads, err)
+	}
+	if len(chunks) != 3 || chunks[0].Header.Chunk.Offset != 0 || chunks[0].Header.Chunk.Position != 6 || len(entries) != 0 {
+		t.Errorf("expected new snapshot from the start, found %#v and %d entries", chunks, len(entries))
+	}
+}
+
+func TestReadReplica_ApplyShippedLog(t *testing.T) {
+	broker := core.NewMemoryBroker()
+	cluster := CreateClusterWith(1, "replica", t, func(conf *types.Configuration) {
+		conf.Broker = broker
+	})
+	defer cluster.Off()
+
+	unity := cluster.Unities[0]
+	write := func(key, value string) {
+		if res := <-unity.Write(GenerateRequest([]byte(key), []byte(value), cluster.Names)); !res.Success {
+			t.Fatalf("failed writing %s. %v", key, res.Failure)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		write(fmt.Sprintf("key-%d", i), "before")
+	}
+
+	conf := mcast.DefaultReplicaConfiguration("replica-"+helper.GenerateUID(), cluster.Names[0])
+	conf.Broker = broker
+	conf.Refresh = 50 * time.Millisecond
+	conf.Logger.ToggleDebug(false)
+	replica, err := mcast.NewReadReplica(conf)
+	if err != nil {
+		t.Fatalf("failed creating replica. %v", err)
+	}
+	defer replica.Close()
+
+	caughtUp := func(applied uint64) bool {
+		return WaitThisOrTimeout(func() {
+			for m := replica.Metrics(); m.Applied < applied || m.Lag > 0; m = replica.Metrics() {
+				time.Sleep(10 * time.Millisecond)
+			}
+		}, 5*time.Second)
+	}
+	if !caughtUp(5) {
+		t.Fatalf("replica not hydrated, found %#v", replica.Metrics())
+	}
+	if metrics := replica.Metrics(); len(metrics.Source) == 0 || metrics.Behind != 0 {
+		t.Errorf("expected replica caught up with a peer, found %#v", metrics)
+	}
+
+	write("key-0", "after")
+	if !caughtUp(6) {
+		t.Fatalf("replica did not receive the new entry, found %#v", replica.Metrics())
+	}
+	for i := 0; i < 5; i++ {
+		expected := "before"
+		if i == 0 {
+			expected = "after"
+		}
+		res, err := replica.Read(GenerateRequest([]byte(fmt.Sprintf("key-%d", i)), nil, nil))
+		if err != nil || string(res.Data) != expected {
+			t.Errorf("expected %s on key-%d, found %s. %v", expected, i, res.Data, err)
+		}
+	}
+}
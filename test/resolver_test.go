@@ -0,0 +1,86 @@
+package test
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolver_Identity(t *testing.T) {
+	address, err := definition.IdentityResolver{}.Resolve("partition")
+	if err != nil || address != "partition" {
+		t.Errorf("expected partition, found %s. %v", address, err)
+	}
+}
+
+func TestResolver_FileReloadOnChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolver")
+	if err != nil {
+		t.Fatalf("failed creating dir. %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "addresses.json")
+	if err := ioutil.WriteFile(path, []byte(`{"partition": "exchange-1"}`), 0600); err != nil {
+		t.Fatalf("failed writing file. %v", err)
+	}
+
+	resolver, err := definition.NewFileResolver(path)
+	if err != nil {
+		t.Fatalf("failed creating resolver. %v", err)
+	}
+
+	address, err := resolver.Resolve("partition")
+	if err != nil || address != "exchange-1" {
+		t.Errorf("expected exchange-1, found %s. %v", address, err)
+	}
+
+	if _, err := resolver.Resolve("unknown"); err == nil {
+		t.Errorf("unknown partition should fail")
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"partition": "exchange-2"}`), 0600); err != nil {
+		t.Fatalf("failed writing file. %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("failed changing time. %v", err)
+	}
+
+	address, err = resolver.Resolve("partition")
+	if err != nil || address != "exchange-2" {
+		t.Errorf("expected exchange-2 after reload, found %s. %v", address, err)
+	}
+}
+
+func TestResolver_Consul(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/mcast-partition" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `[{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 5672}}]`)
+	}))
+	defer server.Close()
+
+	resolver := definition.ConsulResolver{Agent: server.URL, Prefix: "mcast-"}
+	address, err := resolver.Resolve("partition")
+	if err != nil {
+		t.Fatalf("failed resolving. %v", err)
+	}
+
+	if address != types.Address("10.0.0.1:5672") {
+		t.Errorf("expected 10.0.0.1:5672, found %s", address)
+	}
+
+	if _, err := resolver.Resolve("unknown"); err == nil {
+		t.Errorf("unknown partition should fail")
+	}
+}
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errRestricted = errors.New("restricted key")
+
+func TestUnity_SendInterceptorsRouteAndVeto(t *testing.T) {
+	europe := types.Partition("routing-europe")
+	america := types.Partition("routing-america")
+
+	// Keys of european users never leave the european partition.
+	fence := func(message types.Message) types.SendDecision {
+		if !bytes.HasPrefix(message.Content.Key, []byte("eu/")) {
+			return types.SendDecision{}
+		}
+		return types.SendDecision{Destination: []types.Partition{europe}, Reason: "geo-fencing"}
+	}
+	compliance := func(message types.Message) types.SendDecision {
+		if bytes.HasPrefix(message.Content.Key, []byte("secret/")) {
+			return types.SendDecision{Veto: errRestricted, Reason: "compliance"}
+		}
+		return types.SendDecision{}
+	}
+
+	recorder := types.NewEventRecorder(20, 100)
+	conf := mcast.DefaultConfiguration(europe)
+	conf.Logger.ToggleDebug(false)
+	conf.Recorder = recorder
+	conf.Interceptors = []types.SendInterceptor{fence, compliance}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+	other := CreateUnity(america, t)
+	defer other.Shutdown()
+
+	destination := []types.Partition{europe, america}
+	var routed types.UID
+	select {
+	case res := <-unity.Write(GenerateRequest([]byte("eu/user"), []byte("value"), destination)):
+		if !res.Success {
+			t.Fatalf("failed writing. %v", res.Failure)
+		}
+		routed = res.Identifier
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write timeout")
+	}
+
+	var found bool
+	for _, event := range recorder.Events(routed) {
+		if event.Kind == types.EventSent && event.Partition == america {
+			t.Errorf("expected message not sent to %s", america)
+		}
+		if event.Kind == types.EventRouted {
+			found = true
+			if !strings.Contains(event.Detail, "geo-fencing") || !strings.Contains(event.Detail, string(europe)) {
+				t.Errorf("unexpected routing detail %q", event.Detail)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected routing recorded, found %#v", recorder.Events(routed))
+	}
+
+	select {
+	case res := <-unity.Write(GenerateRequest([]byte("secret/user"), []byte("value"), destination)):
+		if !errors.Is(res.Failure, core.ErrSendVetoed) || !strings.Contains(res.Failure.Error(), errRestricted.Error()) {
+			t.Errorf("expected vetoed write, found %#v", res)
+		}
+		events := recorder.Events(res.Identifier)
+		if len(events) != 1 || events[0].Kind != types.EventVetoed || !strings.Contains(events[0].Detail, "compliance") {
+			t.Errorf("expected only the veto recorded, found %#v", events)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write timeout")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if delivered := other.Stats().Delivered; delivered != 0 {
+		t.Errorf("expected nothing delivered on %s, found %d", america, delivered)
+	}
+}
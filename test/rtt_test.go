@@ -0,0 +1,85 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func TestRTTEstimator_InitialTimeout(t *testing.T) {
+	estimator := core.NewRTTEstimatorBounded(100*time.Millisecond, 10*time.Millisecond, time.Second)
+	if timeout := estimator.Timeout("unknown"); timeout != 100*time.Millisecond {
+		t.Errorf("expected initial timeout, found %v", timeout)
+	}
+
+	if timeout := estimator.Greatest(nil); timeout != 100*time.Millisecond {
+		t.Errorf("expected initial timeout for empty destination, found %v", timeout)
+	}
+}
+
+func TestRTTEstimator_ConvergeToObservedValues(t *testing.T) {
+	estimator := core.NewRTTEstimatorBounded(100*time.Millisecond, time.Millisecond, 10*time.Second)
+	lan := types.Partition("lan")
+	wan := types.Partition("wan")
+	for i := 0; i < 50; i++ {
+		estimator.Observe(lan, 2*time.Millisecond)
+		estimator.Observe(wan, 200*time.Millisecond)
+	}
+
+	lanTimeout := estimator.Timeout(lan)
+	if lanTimeout < 2*time.Millisecond || lanTimeout > 5*time.Millisecond {
+		t.Errorf("lan timeout should be close to 2ms, found %v", lanTimeout)
+	}
+
+	wanTimeout := estimator.Timeout(wan)
+	if wanTimeout < 200*time.Millisecond || wanTimeout > 250*time.Millisecond {
+		t.Errorf("wan timeout should be close to 200ms, found %v", wanTimeout)
+	}
+
+	if greatest := estimator.Greatest([]types.Partition{lan, wan}); greatest != wanTimeout {
+		t.Errorf("greatest should be %v, found %v", wanTimeout, greatest)
+	}
+}
+
+func TestRTTEstimator_TimeoutBounds(t *testing.T) {
+	estimator := core.NewRTTEstimatorBounded(100*time.Millisecond, 50*time.Millisecond, time.Second)
+	estimator.Observe("fast", time.Millisecond)
+	estimator.Observe("slow", time.Minute)
+
+	if timeout := estimator.Timeout("fast"); timeout != 50*time.Millisecond {
+		t.Errorf("expected minimum timeout, found %v", timeout)
+	}
+
+	if timeout := estimator.Timeout("slow"); timeout != time.Second {
+		t.Errorf("expected maximum timeout, found %v", timeout)
+	}
+}
+
+func TestRTTEstimator_SampleEchoedExchange(t *testing.T) {
+	estimator := core.NewRTTEstimatorBounded(100*time.Millisecond, time.Millisecond, 10*time.Second)
+	uid := types.UID("echoed")
+	if echo := estimator.Echo(uid, "remote"); echo.Sent != 0 {
+		t.Fatalf("expected no echo before receiving, found %#v", echo)
+	}
+
+	sent := time.Now().Add(-300 * time.Millisecond).UnixNano()
+	estimator.Received(uid, "remote", types.ProtocolHeader{Origin: "remote-1", Sent: sent})
+	estimator.Received(uid, "remote", types.ProtocolHeader{Origin: "remote-2", Sent: time.Now().UnixNano()})
+	echo := estimator.Echo(uid, "remote")
+	if echo.Peer != "remote-1" || echo.Sent != sent {
+		t.Fatalf("expected the first exchange echoed, found %#v", echo)
+	}
+
+	// The echo was held for 250ms, so the round trip is 50ms.
+	echo.Held = int64(250 * time.Millisecond)
+	estimator.Answered("remote", echo)
+	if timeout := estimator.Timeout("remote"); timeout < 50*time.Millisecond || timeout > 200*time.Millisecond {
+		t.Errorf("expected timeout from a 50ms round trip, found %v", timeout)
+	}
+
+	estimator.Forget(uid)
+	if echo := estimator.Echo(uid, "remote"); echo.Sent != 0 {
+		t.Errorf("expected no echo after forgetting, found %#v", echo)
+	}
+}
@@ -0,0 +1,122 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+// Fails after returning the given entries.
+type failingSeed struct {
+	types.SeedSlice
+}
+
+func (f *failingSeed) Next() (types.SeedEntry, bool, error) {
+	if entry, ok, _ := f.SeedSlice.Next(); ok {
+		return entry, true, nil
+	}
+	return types.SeedEntry{}, false, errors.New("dataset failure")
+}
+
+func TestUnity_SeedDataset(t *testing.T) {
+	partitionName := types.Partition("seed-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	var dataset types.SeedSlice
+	for i := 0; i < types.DefaultSeedBatch+10; i++ {
+		dataset = append(dataset, types.SeedEntry{Key: []byte(fmt.Sprintf("key-%d", i)), Value: []byte(fmt.Sprintf("value-%d", i))})
+	}
+	if err := unity.Seed(&dataset); err != nil {
+		t.Fatalf("failed seeding. %v", err)
+	}
+
+	for _, i := range []int{0, types.DefaultSeedBatch + 9} {
+		res, err := unity.Read(GenerateRequest([]byte(fmt.Sprintf("key-%d", i)), nil, []types.Partition{partitionName}))
+		if err != nil || !res.Success || string(res.Data) != fmt.Sprintf("value-%d", i) {
+			t.Errorf("expected seeded value %d, found %#v. %v", i, res, err)
+		}
+	}
+
+	select {
+	case res := <-unity.Write(GenerateRequest([]byte("key-0"), []byte("written"), []types.Partition{partitionName})):
+		if !res.Success {
+			t.Fatalf("failed writing after seed. %v", res.Failure)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write timeout, delivery not resumed")
+	}
+
+	if res, err := unity.Read(GenerateRequest([]byte("key-0"), nil, []types.Partition{partitionName})); err != nil || string(res.Data) != "written" {
+		t.Errorf("expected written value, found %#v. %v", res, err)
+	}
+}
+
+func TestUnity_SeedFailure(t *testing.T) {
+	partitionName := types.Partition("seed-failure")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	dataset := &failingSeed{SeedSlice: types.SeedSlice{{Key: []byte("key"), Value: []byte("value")}}}
+	if err := unity.Seed(dataset); err == nil {
+		t.Fatalf("expected seed failure")
+	}
+
+	if !unity.Ready() {
+		t.Errorf("expected delivery resumed after failure")
+	}
+}
+
+func TestUnity_SeedAfterDeliveredWrites(t *testing.T) {
+	partitionName := types.Partition("seed-ordered")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	markers := make(chan types.UID, conf.Replication)
+	conf.OnDeliver = func(delivered types.Delivered) {
+		if delivered.Message.Header.Flags.Has(types.FlagSeed) {
+			markers <- delivered.Message.Identifier
+		}
+	}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	select {
+	case res := <-unity.Write(GenerateRequest([]byte("key"), []byte("written"), []types.Partition{partitionName})):
+		if !res.Success {
+			t.Fatalf("failed writing before seed. %v", res.Failure)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write timeout")
+	}
+
+	dataset := types.SeedSlice{{Key: []byte("key"), Value: []byte("seeded")}}
+	if err := unity.Seed(&dataset); err != nil {
+		t.Fatalf("failed seeding. %v", err)
+	}
+
+	if res, err := unity.Read(GenerateRequest([]byte("key"), nil, []types.Partition{partitionName})); err != nil || string(res.Data) != "seeded" {
+		t.Errorf("expected seeded value, found %#v. %v", res, err)
+	}
+
+	select {
+	case uid := <-markers:
+		t.Errorf("seed marker %s delivered to the state machine", uid)
+	default:
+	}
+}
@@ -0,0 +1,222 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// Small segments so the tests rotate a few times.
+const testSegmentSize = 256
+
+func segmentDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "segments")
+	if err != nil {
+		t.Fatalf("failed creating dir. %v", err)
+	}
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func openSegmentLog(t *testing.T, dir string) *definition.SegmentLog {
+	log, err := definition.NewSegmentLog(dir, testSegmentSize)
+	if err != nil {
+		t.Fatalf("failed opening log. %v", err)
+	}
+	return log
+}
+
+func segmentRecord(i int) []byte {
+	return []byte(fmt.Sprintf("record-%03d", i))
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.segment"))
+	if err != nil {
+		t.Fatalf("failed listing segments. %v", err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func verifySegmentRecords(t *testing.T, log types.Log, from, to int) {
+	for i := from; i <= to; i++ {
+		data, err := log.Get(uint64(i))
+		if err != nil {
+			t.Fatalf("failed reading %d. %v", i, err)
+		}
+		if !bytes.Equal(data, segmentRecord(i)) {
+			t.Fatalf("record %d is %s", i, string(data))
+		}
+	}
+}
+
+func TestSegmentLog_AppendAcrossSegments(t *testing.T) {
+	dir, cleanup := segmentDir(t)
+	defer cleanup()
+	log := openSegmentLog(t, dir)
+	defer log.Close()
+
+	if log.FirstIndex() != 0 || log.LastIndex() != 0 {
+		t.Fatalf("empty log with %d-%d", log.FirstIndex(), log.LastIndex())
+	}
+
+	for i := 1; i <= 50; i++ {
+		index, err := log.Append(segmentRecord(i))
+		if err != nil {
+			t.Fatalf("failed appending %d. %v", i, err)
+		}
+		if index != uint64(i) {
+			t.Fatalf("appended %d at %d", i, index)
+		}
+	}
+
+	index, err := log.Append(segmentRecord(51), segmentRecord(52))
+	if err != nil || index != 51 {
+		t.Fatalf("batch appended at %d. %v", index, err)
+	}
+
+	if len(segmentFiles(t, dir)) < 2 {
+		t.Fatalf("expected rotated segments")
+	}
+	if log.FirstIndex() != 1 || log.LastIndex() != 52 {
+		t.Fatalf("log with %d-%d", log.FirstIndex(), log.LastIndex())
+	}
+	verifySegmentRecords(t, log, 1, 52)
+
+	if _, err := log.Get(53); !errors.Is(err, types.ErrLogIndex) {
+		t.Fatalf("expected index error, found %v", err)
+	}
+}
+
+func TestSegmentLog_InvalidRecords(t *testing.T) {
+	dir, cleanup := segmentDir(t)
+	defer cleanup()
+	log := openSegmentLog(t, dir)
+	defer log.Close()
+
+	if _, err := log.Append(segmentRecord(1), nil); !errors.Is(err, definition.ErrEmptyRecord) {
+		t.Fatalf("expected empty record error, found %v", err)
+	}
+	if _, err := log.Append(make([]byte, testSegmentSize)); !errors.Is(err, definition.ErrRecordTooLarge) {
+		t.Fatalf("expected too large error, found %v", err)
+	}
+	if log.LastIndex() != 0 {
+		t.Fatalf("invalid batch appended until %d", log.LastIndex())
+	}
+}
+
+func TestSegmentLog_ReopenAndCompact(t *testing.T) {
+	dir, cleanup := segmentDir(t)
+	defer cleanup()
+	log := openSegmentLog(t, dir)
+	for i := 1; i <= 40; i++ {
+		if _, err := log.Append(segmentRecord(i)); err != nil {
+			t.Fatalf("failed appending %d. %v", i, err)
+		}
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("failed closing. %v", err)
+	}
+	if _, err := log.Append(segmentRecord(41)); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected closed error, found %v", err)
+	}
+
+	log = openSegmentLog(t, dir)
+	defer log.Close()
+	if log.FirstIndex() != 1 || log.LastIndex() != 40 {
+		t.Fatalf("reopened with %d-%d", log.FirstIndex(), log.LastIndex())
+	}
+	verifySegmentRecords(t, log, 1, 40)
+
+	before := len(segmentFiles(t, dir))
+	if err := log.Compact(30); err != nil {
+		t.Fatalf("failed compacting. %v", err)
+	}
+	if len(segmentFiles(t, dir)) >= before {
+		t.Fatalf("no segment removed")
+	}
+
+	first := log.FirstIndex()
+	if first <= 1 || first > 30 {
+		t.Fatalf("compacted to %d", first)
+	}
+	if _, err := log.Get(first - 1); !errors.Is(err, types.ErrLogIndex) {
+		t.Fatalf("expected index error, found %v", err)
+	}
+	verifySegmentRecords(t, log, int(first), 40)
+
+	if index, err := log.Append(segmentRecord(41)); err != nil || index != 41 {
+		t.Fatalf("appended at %d after compacting. %v", index, err)
+	}
+}
+
+func TestSegmentLog_DiscardTornTail(t *testing.T) {
+	dir, cleanup := segmentDir(t)
+	defer cleanup()
+	log := openSegmentLog(t, dir)
+	for i := 1; i <= 5; i++ {
+		if _, err := log.Append(segmentRecord(i)); err != nil {
+			t.Fatalf("failed appending %d. %v", i, err)
+		}
+	}
+	log.Close()
+
+	// A header promising more data than was written.
+	files := segmentFiles(t, dir)
+	file, err := os.OpenFile(files[len(files)-1], os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatalf("failed opening segment. %v", err)
+	}
+	if _, err := file.WriteAt([]byte{0, 0, 0, 40, 1, 2, 3, 4, 'x'}, 5*int64(8+len(segmentRecord(1)))); err != nil {
+		t.Fatalf("failed writing. %v", err)
+	}
+	file.Close()
+
+	log = openSegmentLog(t, dir)
+	defer log.Close()
+	if log.LastIndex() != 5 {
+		t.Fatalf("torn tail kept until %d", log.LastIndex())
+	}
+	if index, err := log.Append(segmentRecord(6)); err != nil || index != 6 {
+		t.Fatalf("appended at %d. %v", index, err)
+	}
+	verifySegmentRecords(t, log, 1, 6)
+}
+
+func TestSegmentLog_CorruptedSealedSegment(t *testing.T) {
+	dir, cleanup := segmentDir(t)
+	defer cleanup()
+	log := openSegmentLog(t, dir)
+	for i := 1; i <= 40; i++ {
+		if _, err := log.Append(segmentRecord(i)); err != nil {
+			t.Fatalf("failed appending %d. %v", i, err)
+		}
+	}
+	log.Close()
+
+	files := segmentFiles(t, dir)
+	if len(files) < 2 {
+		t.Fatalf("expected rotated segments")
+	}
+	file, err := os.OpenFile(files[0], os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatalf("failed opening segment. %v", err)
+	}
+	if _, err := file.WriteAt([]byte("garbage"), 8); err != nil {
+		t.Fatalf("failed writing. %v", err)
+	}
+	file.Close()
+
+	if _, err := definition.NewSegmentLog(dir, testSegmentSize); !errors.Is(err, definition.ErrCorruptedLog) {
+		t.Fatalf("expected corrupted log, found %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func newSequencedTransport(inner core.Transport, name string) *core.SequencedTransport {
+	peer := &types.PeerConfiguration{
+		Name:      name,
+		Partition: types.Partition(name + "-partition"),
+	}
+	return core.NewSequencedTransport(inner, peer, definition.NewDefaultLogger())
+}
+
+func TestSequencedTransport_SequencePerPartition(t *testing.T) {
+	inner := newRecordingTransport(false)
+	transport := newSequencedTransport(inner, "sender")
+	defer transport.Close()
+
+	message := types.Message{Destination: []types.Partition{"a", "b"}}
+	if err := transport.Broadcast(message); err != nil {
+		t.Fatalf("failed broadcasting. %v", err)
+	}
+
+	if err := transport.Unicast(message, "a"); err != nil {
+		t.Fatalf("failed unicast. %v", err)
+	}
+
+	sent, partitions := inner.Sent()
+	expected := map[types.Partition][]uint64{"a": {1, 2}, "b": {1}}
+	found := make(map[types.Partition][]uint64)
+	for i, m := range sent {
+		if m.Header.Origin != "sender" {
+			t.Errorf("wrong origin %s", m.Header.Origin)
+		}
+		found[partitions[i]] = append(found[partitions[i]], m.Header.Sequence)
+	}
+
+	for partition, sequences := range expected {
+		if len(found[partition]) != len(sequences) {
+			t.Fatalf("expected %v for %s, found %v", sequences, partition, found[partition])
+		}
+		for i, sequence := range sequences {
+			if found[partition][i] != sequence {
+				t.Errorf("expected %v for %s, found %v", sequences, partition, found[partition])
+			}
+		}
+	}
+}
+
+func TestSequencedTransport_DetectGapAndRequestRetransmission(t *testing.T) {
+	inner := newRecordingTransport(false)
+	transport := newSequencedTransport(inner, "receiver")
+	defer transport.Close()
+
+	for _, sequence := range []uint64{1, 2, 5} {
+		inner.listen <- types.Message{
+			Header: types.ProtocolHeader{Origin: "sender", Sequence: sequence},
+			From:   "sender-partition",
+		}
+		select {
+		case <-transport.Listen():
+		case <-time.After(time.Second):
+			t.Fatalf("message %d not published", sequence)
+		}
+	}
+
+	if transport.Missed() != 2 {
+		t.Errorf("expected 2 missed messages, found %d", transport.Missed())
+	}
+
+	sent, partitions := inner.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 retransmission requests, found %d", len(sent))
+	}
+
+	for i, request := range sent {
+		if request.Header.Type != types.Retransmit || request.Header.Target != "sender" {
+			t.Errorf("wrong retransmission request %#v", request)
+		}
+
+		if request.Header.Sequence != uint64(i+3) {
+			t.Errorf("expected request for %d, found %d", i+3, request.Header.Sequence)
+		}
+
+		if partitions[i] != "sender-partition" {
+			t.Errorf("request sent to wrong partition %s", partitions[i])
+		}
+	}
+}
+
+func TestSequencedTransport_RetransmitFromHistory(t *testing.T) {
+	inner := newRecordingTransport(false)
+	transport := newSequencedTransport(inner, "sender")
+	defer transport.Close()
+
+	message := types.Message{Identifier: "retransmitted"}
+	if err := transport.Unicast(message, "receiver-partition"); err != nil {
+		t.Fatalf("failed unicast. %v", err)
+	}
+
+	inner.listen <- types.Message{
+		Header: types.ProtocolHeader{Type: types.Retransmit, Origin: "receiver", Sequence: 1, Target: "sender"},
+		From:   "receiver-partition",
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		sent, partitions := inner.Sent()
+		if len(sent) == 2 {
+			if sent[1].Identifier != message.Identifier || sent[1].Header.Sequence != 1 || partitions[1] != "receiver-partition" {
+				t.Errorf("wrong retransmission %#v", sent[1])
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("message was not retransmitted")
+}
+
+// Every replica of the partition receives the retransmission requested
+// by one of them, only the replica that missed the message processes
+// it, otherwise the replicas would tick the clock again for it.
+func TestSequencedTransport_RetransmissionProcessedOnlyByMissingReplica(t *testing.T) {
+	partition := types.Partition("replicas-partition")
+	replica := func(name string) (*recordingTransport, *core.SequencedTransport) {
+		inner := newRecordingTransport(false)
+		peer := &types.PeerConfiguration{Name: name, Partition: partition}
+		return inner, core.NewSequencedTransport(inner, peer, definition.NewDefaultLogger())
+	}
+	senderInner := newRecordingTransport(false)
+	sender := newSequencedTransport(senderInner, "sender")
+	defer sender.Close()
+	firstInner, first := replica("replica-0")
+	defer first.Close()
+	secondInner, second := replica("replica-1")
+	defer second.Close()
+
+	for _, uid := range []types.UID{"1", "2", "3", "4"} {
+		if err := sender.Unicast(types.Message{Identifier: uid}, partition); err != nil {
+			t.Fatalf("failed sending %s. %v", uid, err)
+		}
+	}
+	sent, _ := senderInner.Sent()
+
+	deliver := func(inner *recordingTransport, transport *core.SequencedTransport, message types.Message, expected types.UID) {
+		inner.listen <- message
+		if len(expected) == 0 {
+			return
+		}
+		select {
+		case m := <-transport.Listen():
+			if m.Identifier != expected {
+				t.Fatalf("expected %s published, found %s", expected, m.Identifier)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message %s not published", expected)
+		}
+	}
+
+	// The second replica loses the second message.
+	for _, m := range sent[:3] {
+		deliver(firstInner, first, m, m.Identifier)
+	}
+	deliver(secondInner, second, sent[0], sent[0].Identifier)
+	deliver(secondInner, second, sent[2], sent[2].Identifier)
+
+	requests, _ := secondInner.Sent()
+	if len(requests) != 1 || requests[0].Header.Sequence != 2 {
+		t.Fatalf("expected retransmission of 2 requested, found %#v", requests)
+	}
+	senderInner.listen <- requests[0]
+	var retransmitted types.Message
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if sent, _ = senderInner.Sent(); len(sent) == 5 {
+			retransmitted = sent[4]
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if retransmitted.Identifier != "2" || !retransmitted.Header.Flags.Has(types.FlagRetransmitted) {
+		t.Fatalf("expected flagged retransmission of 2, found %#v", retransmitted)
+	}
+
+	// The copy reaches both replicas, the first one already processed
+	// it, as the duplicate delivered by the broker, so the next message
+	// published is the fourth.
+	deliver(firstInner, first, retransmitted, "")
+	deliver(firstInner, first, sent[2], "")
+	deliver(firstInner, first, sent[3], sent[3].Identifier)
+
+	deliver(secondInner, second, retransmitted, retransmitted.Identifier)
+	deliver(secondInner, second, retransmitted, "")
+	deliver(secondInner, second, sent[3], sent[3].Identifier)
+}
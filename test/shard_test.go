@@ -0,0 +1,82 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func TestDeliver_CommitShardsInOrder(t *testing.T) {
+	var storages []types.Storage
+	var shards []*concurrentStorage
+	for i := 0; i < 4; i++ {
+		shard := newConcurrentStorage()
+		shards = append(shards, shard)
+		storages = append(storages, shard)
+	}
+	deliver, err := core.NewShardedDeliver(context.Background(), definition.NewDefaultLogger(), &definition.AlwaysConflict{}, storages, 1)
+	if err != nil {
+		t.Fatalf("failed creating deliver. %v", err)
+	}
+
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	messages := keyedMessages(keys, 3)
+	for i, res := range deliver.CommitBatch(messages) {
+		if !res.Success || res.Identifier != messages[i].Identifier {
+			t.Errorf("expected %s committed, found %#v", messages[i].Identifier, res)
+		}
+	}
+
+	for _, key := range keys {
+		shard := core.ShardOf([]byte(key), len(shards))
+		order := shards[shard].order[key]
+		if len(order) != 3 {
+			t.Errorf("key %s committed %d times on shard %d, expected 3", key, len(order), shard)
+		}
+		for i, uid := range order {
+			if expected := types.UID(fmt.Sprintf("%s-%d", key, i)); uid != expected {
+				t.Errorf("key %s committed %v out of order", key, order)
+				break
+			}
+		}
+	}
+}
+
+func TestUnity_ShardedReadAfterWrite(t *testing.T) {
+	partitionName := types.Partition("sharded-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	for i := 0; i < 3; i++ {
+		conf.Shards = append(conf.Shards, definition.NewInMemoryStorage())
+	}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	for i := 0; i < 6; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		select {
+		case res := <-unity.Write(GenerateRequest(key, key, []types.Partition{partitionName})):
+			if !res.Success {
+				t.Fatalf("failed writing %s. %v", key, res.Failure)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("write %s timeout", key)
+		}
+
+		res, err := unity.Read(GenerateRequest(key, nil, []types.Partition{partitionName}))
+		if err != nil || string(res.Data) != string(key) {
+			t.Errorf("expected %s, found %#v. %v", key, res, err)
+		}
+		if _, err := conf.Shards[core.ShardOf(key, len(conf.Shards))].Get(key); err != nil {
+			t.Errorf("key %s not on its shard. %v", key, err)
+		}
+	}
+}
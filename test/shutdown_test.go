@@ -0,0 +1,89 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A peer failing to stop.
+type stoppingPeer struct {
+	core.PartitionPeer
+	err error
+}
+
+func (s stoppingPeer) Stop() error {
+	return s.err
+}
+
+func TestUnity_ShutdownCompletesFuture(t *testing.T) {
+	unity := CreateUnity("shutdown-future", t)
+	future := unity.Shutdown()
+	if unity.Ready() {
+		t.Errorf("expected peers stopped once the shutdown returns")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := future.Wait(ctx); err != nil {
+		t.Fatalf("failed shutting down. %v", err)
+	}
+	select {
+	case <-future.Done():
+	default:
+		t.Errorf("expected the future done")
+	}
+}
+
+func TestUnity_ShutdownAggregatesPeerFailures(t *testing.T) {
+	unity := &mcast.PeerUnity{
+		Configuration: mcast.DefaultConfiguration("shutdown-failures"),
+		Invoker:       NewInvoker(),
+		Peers: []core.PartitionPeer{
+			stoppingPeer{err: core.ErrStopPanic},
+			stoppingPeer{},
+			stoppingPeer{err: core.ErrUnsentMessages},
+		},
+	}
+
+	err := unity.Shutdown().Error()
+	var shutdown *mcast.ShutdownError
+	if !errors.As(err, &shutdown) || len(shutdown.Errors) != 2 {
+		t.Fatalf("expected the failures of 2 peers, found %v", err)
+	}
+	if !errors.Is(err, core.ErrStopPanic) || !errors.Is(err, core.ErrUnsentMessages) {
+		t.Errorf("expected every failure matched, found %v", err)
+	}
+	if !strings.Contains(err.Error(), core.ErrUnsentMessages.Error()) {
+		t.Errorf("expected failures described, found %q", err.Error())
+	}
+}
+
+func TestUnity_ShutdownWaitDeadline(t *testing.T) {
+	invoker := NewInvoker()
+	release := make(chan struct{})
+	invoker.Spawn(func() {
+		<-release
+	})
+	unity := &mcast.PeerUnity{
+		Configuration: mcast.DefaultConfiguration("shutdown-deadline"),
+		Invoker:       invoker,
+		Peers:         []core.PartitionPeer{stoppingPeer{}},
+	}
+
+	future := unity.Shutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := future.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, found %v", err)
+	}
+
+	close(release)
+	if err := future.Error(); err != nil {
+		t.Errorf("expected shutdown without failures, found %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func signedTransport(t *testing.T, broker types.Broker, name string, key []byte) (core.Transport, *types.ErrorReporter) {
+	reporter := types.NewErrorReporter(10)
+	peer := &types.PeerConfiguration{
+		Name:       name,
+		Partition:  types.Partition(name),
+		Version:    types.LatestProtocolVersion,
+		Broker:     broker,
+		SigningKey: key,
+		Errors:     reporter,
+	}
+	transport, err := core.NewTransport(peer, core.NewRTTEstimator(), definition.NewDefaultLogger())
+	if err != nil {
+		t.Fatalf("failed creating transport. %v", err)
+	}
+	return transport, reporter
+}
+
+func TestFrameSigner_VerifySigned(t *testing.T) {
+	signer := core.NewFrameSigner([]byte("cluster-key"))
+	frame := []byte("frame")
+	signed := signer.Sign(append([]byte(nil), frame...))
+	if len(signed) != len(frame)+core.SignatureSize {
+		t.Fatalf("expected %d bytes, found %d", len(frame)+core.SignatureSize, len(signed))
+	}
+
+	verified, err := signer.Verify(signed)
+	if err != nil || string(verified) != string(frame) {
+		t.Fatalf("expected %q, found %q. %v", frame, verified, err)
+	}
+
+	signed[0] ^= 0xff
+	if _, err := signer.Verify(signed); !errors.Is(err, core.ErrUnauthenticated) {
+		t.Errorf("expected tampered frame rejected, found %v", err)
+	}
+	if _, err := core.NewFrameSigner([]byte("other-key")).Verify(signer.Sign([]byte("frame"))); !errors.Is(err, core.ErrUnauthenticated) {
+		t.Errorf("expected frame of another key rejected, found %v", err)
+	}
+	if _, err := signer.Verify([]byte("short")); !errors.Is(err, core.ErrUnauthenticated) {
+		t.Errorf("expected short frame rejected, found %v", err)
+	}
+}
+
+func TestFrameSigner_EmptyKeyDisabled(t *testing.T) {
+	signer := core.NewFrameSigner(nil)
+	if signer != nil {
+		t.Fatalf("expected no signer for an empty key")
+	}
+	frame := []byte("frame")
+	if signed := signer.Sign(frame); string(signed) != string(frame) {
+		t.Errorf("expected frame unchanged, found %q", signed)
+	}
+	if verified, err := signer.Verify(frame); err != nil || string(verified) != string(frame) {
+		t.Errorf("expected frame unchanged, found %q. %v", verified, err)
+	}
+}
+
+func TestTransport_DropsFramesOfAnotherKey(t *testing.T) {
+	broker := core.NewMemoryBroker()
+	partition := "signed-" + helper.GenerateUID()
+	receiver, reporter := signedTransport(t, broker, partition, []byte("cluster-key"))
+	defer receiver.Close()
+	rogue, _ := signedTransport(t, broker, "rogue-"+helper.GenerateUID(), []byte("other-key"))
+	defer rogue.Close()
+	unsigned, _ := signedTransport(t, broker, "unsigned-"+helper.GenerateUID(), nil)
+	defer unsigned.Close()
+	member, _ := signedTransport(t, broker, "member-"+helper.GenerateUID(), []byte("cluster-key"))
+	defer member.Close()
+
+	destination := types.Partition(partition)
+	for _, sender := range []core.Transport{rogue, unsigned} {
+		message := codecMessage()
+		message.Identifier = types.UID(helper.GenerateUID())
+		message.Destination = []types.Partition{destination}
+		if err := sender.Unicast(message, destination); err != nil {
+			t.Fatalf("failed sending. %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-reporter.Errors():
+			var async *types.AsyncError
+			if !errors.As(err, &async) || async.Kind != types.DroppedMessage || !errors.Is(err, core.ErrUnauthenticated) {
+				t.Errorf("expected unauthenticated message dropped, found %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("unauthenticated message not reported")
+		}
+	}
+
+	message := codecMessage()
+	message.Identifier = types.UID(helper.GenerateUID())
+	message.Destination = []types.Partition{destination}
+	if err := member.Unicast(message, destination); err != nil {
+		t.Fatalf("failed sending. %v", err)
+	}
+	consumeInOrder(t, receiver, []types.UID{message.Identifier})
+}
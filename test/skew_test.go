@@ -0,0 +1,80 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func TestSkewMetrics_ObserveAndMerge(t *testing.T) {
+	var first, second types.SkewMetrics
+	for _, jump := range []uint64{0, 3, 5000} {
+		first.Observe(jump)
+	}
+	second.Observe(10)
+
+	merged := first.Merge(second)
+	if merged.Exchanges != 4 || merged.Leaps != 3 || merged.Max != 5000 || merged.Total != 5013 {
+		t.Errorf("unexpected merged metrics %#v", merged)
+	}
+
+	if merged.Buckets[0] != 1 || merged.Buckets[3] != 1 || merged.Buckets[5] != 1 || merged.Buckets[len(types.SkewBuckets)] != 1 {
+		t.Errorf("unexpected buckets %v", merged.Buckets)
+	}
+}
+
+func TestSkewStatistics_KeyedByDestinationSet(t *testing.T) {
+	statistics := core.NewSkewStatistics()
+	statistics.Observe([]types.Partition{"b", "a"}, 1)
+	statistics.Observe([]types.Partition{"a", "b"}, 2)
+
+	snapshot := statistics.Snapshot()
+	if m, ok := snapshot["a,b"]; !ok || len(snapshot) != 1 || m.Exchanges != 2 {
+		t.Errorf("expected single destination set, found %#v", snapshot)
+	}
+}
+
+func TestUnity_LaggingPartitionClockSkew(t *testing.T) {
+	partitionOne := types.Partition("skew-one")
+	partitionTwo := types.Partition("skew-two")
+	unityOne := CreateUnity(partitionOne, t)
+	unityTwo := CreateUnity(partitionTwo, t)
+	defer unityOne.Shutdown()
+	defer unityTwo.Shutdown()
+
+	// Advance the clock of the first partition only.
+	for i := 0; i < 5; i++ {
+		select {
+		case res := <-unityOne.Write(GenerateRandomRequest([]types.Partition{partitionOne})):
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+
+	both := []types.Partition{partitionOne, partitionTwo}
+	select {
+	case res := <-unityOne.Write(GenerateRandomRequest(both)):
+		if !res.Success {
+			t.Fatalf("failed writing. %v", res.Failure)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write timeout")
+	}
+
+	key := core.DestinationSet(both)
+	if !WaitThisOrTimeout(func() {
+		for unityTwo.ClockSkew()[key].Leaps == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}, 5*time.Second) {
+		t.Fatalf("expected lagging partition to leap, found %#v", unityTwo.ClockSkew())
+	}
+
+	if skew := unityOne.ClockSkew()[key]; skew.Leaps != 0 {
+		t.Errorf("expected leading partition not to leap, found %#v", skew)
+	}
+}
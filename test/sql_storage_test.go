@@ -0,0 +1,403 @@
+package test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"github.com/jabolina/go-mcast/pkg/mcast/types/storagetest"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errFakeSQL = errors.New("fake sql failure")
+
+// A database/sql driver that only understands the statements
+// issued by the SQLStorage, keeping the tables in memory.
+type fakeSQLDriver struct {
+	mutex     *sync.Mutex
+	databases map[string]*fakeSQLDatabase
+}
+
+type fakeSQLRow struct {
+	identifier string
+	key        string
+	timestamp  int64
+	data       []byte
+	extensions []byte
+}
+
+type fakeSQLDatabase struct {
+	mutex      *sync.Mutex
+	migrations int64
+	statements []string
+	values     map[string][]byte
+	log        []fakeSQLRow
+	failOn     string
+}
+
+func (d *fakeSQLDatabase) copy() *fakeSQLDatabase {
+	c := &fakeSQLDatabase{
+		migrations: d.migrations,
+		values:     make(map[string][]byte),
+		log:        append([]fakeSQLRow{}, d.log...),
+	}
+	for k, v := range d.values {
+		c.values[k] = v
+	}
+	return c
+}
+
+func (d *fakeSQLDatabase) execute(query string, args []driver.Value) (int64, [][]driver.Value, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	query = strings.Join(strings.Fields(query), " ")
+	d.statements = append(d.statements, query)
+	if len(d.failOn) > 0 && strings.Contains(query, d.failOn) {
+		return 0, nil, errFakeSQL
+	}
+
+	switch {
+	case strings.HasPrefix(query, "CREATE"), strings.HasPrefix(query, "SET LOCAL"):
+		return 0, nil, nil
+	case strings.HasPrefix(query, "SELECT COALESCE(MAX(version), 0)"):
+		return 0, [][]driver.Value{{d.migrations}}, nil
+	case strings.Contains(query, "_migrations (version) VALUES"):
+		d.migrations = args[0].(int64)
+		return 1, nil, nil
+	case strings.Contains(query, "_values (key, value) VALUES"):
+		d.values[string(args[0].([]byte))] = args[1].([]byte)
+		return 1, nil, nil
+	case strings.HasPrefix(query, "SELECT value FROM"):
+		value, ok := d.values[string(args[0].([]byte))]
+		if !ok {
+			return 0, [][]driver.Value{}, nil
+		}
+		return 0, [][]driver.Value{{value}}, nil
+	case strings.Contains(query, "_log (identifier, key, timestamp, data, extensions)"):
+		for _, row := range d.log {
+			if row.identifier == args[0].(string) {
+				return 0, nil, nil
+			}
+		}
+		row := fakeSQLRow{identifier: args[0].(string), key: string(args[1].([]byte)), timestamp: args[2].(int64)}
+		row.data, _ = args[3].([]byte)
+		row.extensions, _ = args[4].([]byte)
+		d.log = append(d.log, row)
+		return 1, nil, nil
+	case strings.HasPrefix(query, "SELECT identifier, key, timestamp, data, extensions"):
+		rows := [][]driver.Value{}
+		for i := len(d.log) - 1; i >= 0; i-- {
+			row := d.log[i]
+			if key, ok := args[0].([]byte); ok && row.key != string(key) {
+				continue
+			}
+			if row.timestamp < args[1].(int64) || (args[2].(int64) != 0 && row.timestamp > args[2].(int64)) {
+				continue
+			}
+			if limit, ok := args[3].(int64); ok && int64(len(rows)) == limit {
+				break
+			}
+			rows = append(rows, []driver.Value{row.identifier, []byte(row.key), row.timestamp, row.data, row.extensions})
+		}
+		return 0, rows, nil
+	default:
+		return 0, nil, fmt.Errorf("unknown statement %q", query)
+	}
+}
+
+func (f *fakeSQLDriver) Open(name string) (driver.Conn, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	database, ok := f.databases[name]
+	if !ok {
+		database = &fakeSQLDatabase{mutex: &sync.Mutex{}, values: make(map[string][]byte)}
+		f.databases[name] = database
+	}
+	return &fakeSQLConn{database: database}, nil
+}
+
+type fakeSQLConn struct {
+	database *fakeSQLDatabase
+	snapshot *fakeSQLDatabase
+}
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeSQLConn) Close() error {
+	return nil
+}
+
+func (c *fakeSQLConn) Begin() (driver.Tx, error) {
+	c.database.mutex.Lock()
+	defer c.database.mutex.Unlock()
+	c.snapshot = c.database.copy()
+	return c, nil
+}
+
+func (c *fakeSQLConn) Commit() error {
+	c.snapshot = nil
+	return nil
+}
+
+func (c *fakeSQLConn) Rollback() error {
+	c.database.mutex.Lock()
+	defer c.database.mutex.Unlock()
+	c.database.migrations = c.snapshot.migrations
+	c.database.values = c.snapshot.values
+	c.database.log = c.snapshot.log
+	c.snapshot = nil
+	return nil
+}
+
+type fakeSQLStmt struct {
+	conn  *fakeSQLConn
+	query string
+}
+
+func (s *fakeSQLStmt) Close() error {
+	return nil
+}
+
+func (s *fakeSQLStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	affected, _, err := s.conn.database.execute(s.query, args)
+	return driver.RowsAffected(affected), err
+}
+
+func (s *fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	_, rows, err := s.conn.database.execute(s.query, args)
+	return &fakeSQLRows{rows: rows}, err
+}
+
+type fakeSQLRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeSQLRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return []string{"value"}
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeSQLRows) Close() error {
+	return nil
+}
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var fakeSQL = &fakeSQLDriver{mutex: &sync.Mutex{}, databases: make(map[string]*fakeSQLDatabase)}
+
+func init() {
+	sql.Register("fake-postgres", fakeSQL)
+}
+
+func openFakeSQL(t *testing.T) (*sql.DB, *fakeSQLDatabase) {
+	name := helper.GenerateUID()
+	db, err := sql.Open("fake-postgres", name)
+	if err != nil {
+		t.Fatalf("failed opening database. %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed connecting. %v", err)
+	}
+	fakeSQL.mutex.Lock()
+	defer fakeSQL.mutex.Unlock()
+	return db, fakeSQL.databases[name]
+}
+
+func TestSQLStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func() (types.Storage, error) {
+		db, _ := openFakeSQL(t)
+		return definition.NewSQLStorage(db, "mcast")
+	})
+}
+
+func TestSQLStorage_MigrationsAppliedOnce(t *testing.T) {
+	db, database := openFakeSQL(t)
+	defer db.Close()
+	if _, err := definition.NewSQLStorage(db, "mcast"); err != nil {
+		t.Fatalf("failed creating storage. %v", err)
+	}
+
+	applied := database.migrations
+	if applied == 0 {
+		t.Fatalf("expected migrations applied")
+	}
+
+	database.statements = nil
+	if _, err := definition.NewSQLStorage(db, "mcast"); err != nil {
+		t.Fatalf("failed creating storage again. %v", err)
+	}
+
+	if database.migrations != applied || len(database.statements) != 2 {
+		t.Errorf("expected no migration applied, found %v", database.statements)
+	}
+
+	if _, err := definition.NewSQLStorage(db, "mcast; DROP TABLE"); !errors.Is(err, definition.ErrInvalidTableName) {
+		t.Errorf("expected invalid table name, found %v", err)
+	}
+}
+
+func TestSQLStorage_CommitLogAndValueTogether(t *testing.T) {
+	db, database := openFakeSQL(t)
+	defer db.Close()
+	storage, err := definition.NewSQLStorage(db, "mcast")
+	if err != nil {
+		t.Fatalf("failed creating storage. %v", err)
+	}
+
+	entry := &types.Entry{Operation: types.Command, Identifier: "first", Key: []byte("key"), FinalTimestamp: 1, Data: []byte("first")}
+	if _, err := storage.Commit(entry); err != nil {
+		t.Fatalf("failed committing. %v", err)
+	}
+
+	// Committing the same entry again, as done by other peers, is a no-op.
+	if _, err := storage.Commit(entry); err != nil {
+		t.Fatalf("failed committing again. %v", err)
+	}
+
+	database.failOn = "_values"
+	failed := &types.Entry{Operation: types.Command, Identifier: "second", Key: []byte("key"), FinalTimestamp: 2, Data: []byte("second")}
+	if _, err := storage.Commit(failed); !errors.Is(err, errFakeSQL) {
+		t.Fatalf("expected failure, found %v", err)
+	}
+	database.failOn = ""
+
+	history, err := storage.History(types.HistoryFilter{Key: []byte("key")})
+	if err != nil {
+		t.Fatalf("failed reading history. %v", err)
+	}
+
+	if len(history) != 1 || history[0].Identifier != "first" || string(history[0].Data) != "first" {
+		t.Errorf("expected only the first entry, found %#v", history)
+	}
+
+	res, err := storage.Commit(&types.Entry{Operation: types.Query, Key: []byte("key")})
+	if err != nil {
+		t.Fatalf("failed querying. %v", err)
+	}
+
+	if committed := res.(*types.Entry); committed.Identifier != "first" {
+		t.Errorf("expected first entry, found %#v", committed)
+	}
+}
+
+func TestSQLStorage_UsedAsStateMachine(t *testing.T) {
+	db, _ := openFakeSQL(t)
+	defer db.Close()
+	storage, err := definition.NewSQLStorage(db, "mcast")
+	if err != nil {
+		t.Fatalf("failed creating storage. %v", err)
+	}
+
+	partitionName := types.Partition("sql-storage-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Storage = storage
+	conf.Logger.ToggleDebug(false)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	obs := unity.Write(GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partitionName}))
+	select {
+	case res := <-obs:
+		if !res.Success {
+			t.Fatalf("failed writing. %v", res.Failure)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("write timeout")
+	}
+
+	history, err := storage.History(types.HistoryFilter{Key: []byte("key")})
+	if err != nil || len(history) != 1 {
+		t.Errorf("expected a single entry on the log, found %#v. %v", history, err)
+	}
+}
+
+func TestSQLStorage_DurabilityPolicy(t *testing.T) {
+	db, database := openFakeSQL(t)
+	defer db.Close()
+	storage, err := definition.NewSQLStorage(db, "mcast")
+	if err != nil {
+		t.Fatalf("failed creating storage. %v", err)
+	}
+
+	asynchronous := func() bool {
+		database.mutex.Lock()
+		defer database.mutex.Unlock()
+		for _, statement := range database.statements {
+			if statement == "SET LOCAL synchronous_commit TO off" {
+				return true
+			}
+		}
+		return false
+	}
+
+	entry := &types.Entry{Operation: types.Command, Identifier: "first", Key: []byte("key"), Data: []byte("first")}
+	if _, err := storage.Commit(entry); err != nil || asynchronous() {
+		t.Fatalf("expected synchronous commit. %v", err)
+	}
+
+	storage.SetDurability(types.Durability{Policy: types.NoSync})
+	entry = &types.Entry{Operation: types.Command, Identifier: "second", Key: []byte("key"), Data: []byte("second")}
+	if _, err := storage.Commit(entry); err != nil || !asynchronous() {
+		t.Fatalf("expected asynchronous commit. %v", err)
+	}
+}
+
+func TestSQLStorage_CommitBatchAtomically(t *testing.T) {
+	db, database := openFakeSQL(t)
+	defer db.Close()
+	storage, err := definition.NewSQLStorage(db, "mcast")
+	if err != nil {
+		t.Fatalf("failed creating storage. %v", err)
+	}
+
+	entries := []*types.Entry{
+		{Operation: types.Command, Identifier: "first", Key: []byte("first"), FinalTimestamp: 1, Data: []byte("first")},
+		{Operation: types.Command, Identifier: "second", Key: []byte("second"), FinalTimestamp: 2, Data: []byte("second")},
+	}
+	database.failOn = "_values"
+	if _, err := storage.CommitBatch(entries); !errors.Is(err, errFakeSQL) {
+		t.Fatalf("expected failure, found %v", err)
+	}
+	database.failOn = ""
+
+	if history, _ := storage.History(types.HistoryFilter{}); len(history) != 0 {
+		t.Fatalf("expected no entry applied, found %#v", history)
+	}
+
+	responses, err := storage.CommitBatch(entries)
+	if err != nil || len(responses) != len(entries) {
+		t.Fatalf("failed committing batch %#v. %v", responses, err)
+	}
+
+	history, err := storage.History(types.HistoryFilter{})
+	if err != nil || len(history) != 2 || history[0].Identifier != "first" || history[1].Identifier != "second" {
+		t.Errorf("expected both entries in order, found %#v. %v", history, err)
+	}
+}
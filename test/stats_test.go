@@ -0,0 +1,76 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func TestPartitionStats_AverageAndGreatest(t *testing.T) {
+	first := types.PartitionStats{Proposed: 4, Destinations: 6, Delivered: 1}
+	second := types.PartitionStats{Proposed: 2, Destinations: 2, Delivered: 3}
+
+	if avg := first.AverageDestinations(); avg != 1.5 {
+		t.Errorf("expected average 1.5, found %f", avg)
+	}
+
+	if avg := (types.PartitionStats{}).AverageDestinations(); avg != 0 {
+		t.Errorf("expected average 0 without proposals, found %f", avg)
+	}
+
+	greatest := first.Greatest(second)
+	if greatest.Proposed != 4 || greatest.Delivered != 3 || greatest.Destinations != 6 {
+		t.Errorf("unexpected greatest stats %#v", greatest)
+	}
+}
+
+func TestUnity_PartitionStats(t *testing.T) {
+	partitionOne := types.Partition("stats-one")
+	partitionTwo := types.Partition("stats-two")
+	unityOne := CreateUnity(partitionOne, t)
+	unityTwo := CreateUnity(partitionTwo, t)
+	defer unityOne.Shutdown()
+	defer unityTwo.Shutdown()
+
+	requests := []types.Request{
+		GenerateRandomRequest([]types.Partition{partitionOne}),
+		GenerateRandomRequest([]types.Partition{partitionOne}),
+		GenerateRandomRequest([]types.Partition{partitionOne}),
+		GenerateRandomRequest([]types.Partition{partitionOne, partitionTwo}),
+	}
+	for _, request := range requests {
+		select {
+		case res := <-unityOne.Write(request):
+			if !res.Success {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+
+	if !WaitThisOrTimeout(func() {
+		for unityOne.Stats().Delivered < 4 || unityTwo.Stats().Delivered < 1 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}, 5*time.Second) {
+		t.Fatalf("messages not delivered %#v %#v", unityOne.Stats(), unityTwo.Stats())
+	}
+
+	stats := unityOne.Stats()
+	if stats.Proposed != 4 || stats.GenericDelivered > stats.Delivered || stats.Destinations != 5 {
+		t.Errorf("unexpected stats %#v", stats)
+	}
+
+	if avg := stats.AverageDestinations(); avg != 1.25 {
+		t.Errorf("expected average 1.25, found %f", avg)
+	}
+
+	if stats.Conflicts != stats.ClockTicks {
+		t.Errorf("every conflict ticks the clock, found %#v", stats)
+	}
+
+	if other := unityTwo.Stats(); other.Proposed != 1 || other.Delivered != 1 {
+		t.Errorf("unexpected stats for the second partition %#v", other)
+	}
+}
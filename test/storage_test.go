@@ -0,0 +1,267 @@
+package test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"github.com/jabolina/go-mcast/pkg/mcast/types/storagetest"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorage_InMemoryConformance(t *testing.T) {
+	storagetest.Run(t, func() (types.Storage, error) {
+		return definition.NewInMemoryStorage(), nil
+	})
+}
+
+func TestStorage_FailurePropagatedToResponse(t *testing.T) {
+	partitionName := types.Partition("failing-storage-unity")
+	storage := storagetest.NewFailingStorage(definition.NewInMemoryStorage())
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Storage = storage
+	conf.Logger.ToggleDebug(false)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	storage.Fail(true)
+	obs := unity.Write(GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partitionName}))
+	select {
+	case res := <-obs:
+		if res.Success || !errors.Is(res.Failure, storagetest.ErrInjected) {
+			t.Errorf("expected storage failure, found %#v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("write timeout")
+	}
+}
+
+func TestStorage_InMemoryBoundedConformance(t *testing.T) {
+	storagetest.Run(t, func() (types.Storage, error) {
+		return definition.NewInMemoryStorageWith(definition.InMemoryStorageOptions{
+			TTL:        time.Minute,
+			MaxEntries: 1024,
+		}), nil
+	})
+}
+
+func TestStorage_InMemoryCopyValues(t *testing.T) {
+	storage := definition.NewInMemoryStorage()
+	value := []byte("value")
+	if err := storage.Set([]byte("key"), value); err != nil {
+		t.Fatalf("failed setting. %v", err)
+	}
+	value[0] = 'X'
+
+	read, err := storage.Get([]byte("key"))
+	if err != nil || string(read) != "value" {
+		t.Fatalf("expected value, found %q. %v", read, err)
+	}
+	read[0] = 'X'
+
+	if read, _ := storage.Get([]byte("key")); string(read) != "value" {
+		t.Errorf("stored value changed to %q", read)
+	}
+}
+
+func TestStorage_InMemoryExpireEntries(t *testing.T) {
+	storage := definition.NewInMemoryStorageWith(definition.InMemoryStorageOptions{TTL: 50 * time.Millisecond})
+	_ = storage.Set([]byte("short"), []byte("value"))
+	_ = storage.SetWithTTL([]byte("forever"), []byte("value"), 0)
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := storage.Get([]byte("short")); err == nil {
+		t.Errorf("expected short to be expired")
+	}
+
+	if _, err := storage.Get([]byte("forever")); err != nil {
+		t.Errorf("expected forever to be present. %v", err)
+	}
+
+	_ = storage.SetWithTTL([]byte("other"), []byte("value"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	storage.Purge()
+	if storage.Len() != 1 {
+		t.Errorf("expected 1 entry after purge, found %d", storage.Len())
+	}
+}
+
+func TestStorage_InMemoryEvictLeastRecentlyUsed(t *testing.T) {
+	storage := definition.NewInMemoryStorageWith(definition.InMemoryStorageOptions{MaxEntries: 2})
+	_ = storage.Set([]byte("a"), []byte("1"))
+	_ = storage.Set([]byte("b"), []byte("2"))
+	if _, err := storage.Get([]byte("a")); err != nil {
+		t.Fatalf("failed reading a. %v", err)
+	}
+	_ = storage.Set([]byte("c"), []byte("3"))
+
+	if _, err := storage.Get([]byte("b")); err == nil {
+		t.Errorf("expected b to be evicted")
+	}
+
+	for _, key := range []string{"a", "c"} {
+		if _, err := storage.Get([]byte(key)); err != nil {
+			t.Errorf("expected %s to be present. %v", key, err)
+		}
+	}
+
+	bounded := definition.NewInMemoryStorageWith(definition.InMemoryStorageOptions{MaxBytes: 10})
+	_ = bounded.Set([]byte("a"), []byte("1234"))
+	_ = bounded.Set([]byte("b"), []byte("1234"))
+	_ = bounded.Set([]byte("c"), []byte("1234"))
+	if bounded.Len() != 2 {
+		t.Errorf("expected 2 entries within 10 bytes, found %d", bounded.Len())
+	}
+}
+
+// A minimal Redis server that only knows the hash commands
+// used by the RedisStorage.
+type fakeRedis struct {
+	mutex    *sync.Mutex
+	listener net.Listener
+	hashes   map[string]map[string][]byte
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed listening. %v", err)
+	}
+	f := &fakeRedis{
+		mutex:    &sync.Mutex{},
+		listener: listener,
+		hashes:   make(map[string]map[string][]byte),
+	}
+	go f.accept()
+	return f
+}
+
+func (f *fakeRedis) accept() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.serve(conn)
+	}
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		size, err := readFakeRedisLength(reader, '*')
+		if err != nil {
+			return
+		}
+
+		args := make([][]byte, size)
+		for i := range args {
+			length, err := readFakeRedisLength(reader, '$')
+			if err != nil {
+				return
+			}
+			args[i] = make([]byte, length+2)
+			if _, err := io.ReadFull(reader, args[i]); err != nil {
+				return
+			}
+			args[i] = args[i][:length]
+		}
+
+		if _, err := conn.Write(f.execute(args)); err != nil {
+			return
+		}
+	}
+}
+
+func readFakeRedisLength(reader *bufio.Reader, prefix byte) (int, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+
+	if len(line) < 3 || line[0] != prefix {
+		return 0, fmt.Errorf("unexpected line %q", line)
+	}
+	return strconv.Atoi(strings.TrimSpace(line[1:]))
+}
+
+func (f *fakeRedis) execute(args [][]byte) []byte {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	switch string(args[0]) {
+	case "PING":
+		return []byte("+PONG\r\n")
+	case "HSET":
+		hash, ok := f.hashes[string(args[1])]
+		if !ok {
+			hash = make(map[string][]byte)
+			f.hashes[string(args[1])] = hash
+		}
+		hash[string(args[2])] = args[3]
+		return []byte(":1\r\n")
+	case "HGET":
+		value, ok := f.hashes[string(args[1])][string(args[2])]
+		if !ok {
+			return []byte("$-1\r\n")
+		}
+		return append([]byte(fmt.Sprintf("$%d\r\n", len(value))), append(value, '\r', '\n')...)
+	default:
+		return []byte("-ERR unknown command\r\n")
+	}
+}
+
+func TestStorage_RedisConformance(t *testing.T) {
+	server := newFakeRedis(t)
+	defer server.listener.Close()
+	storagetest.Run(t, func() (types.Storage, error) {
+		return definition.NewRedisStorage(definition.RedisStorageOptions{
+			Address: server.listener.Addr().String(),
+			Hash:    "mcast:" + helper.GenerateUID(),
+		})
+	})
+}
+
+func TestStorage_RedisPipelineGetMany(t *testing.T) {
+	server := newFakeRedis(t)
+	defer server.listener.Close()
+	storage, err := definition.NewRedisStorage(definition.RedisStorageOptions{
+		Address: server.listener.Addr().String(),
+		Hash:    "mcast:pipeline",
+	})
+	if err != nil {
+		t.Fatalf("failed creating storage. %v", err)
+	}
+	defer storage.Close()
+
+	_ = storage.Set([]byte("a"), []byte("1"))
+	_ = storage.Set([]byte("c"), []byte("3"))
+	values, err := storage.GetMany([][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	if err != nil {
+		t.Fatalf("failed reading. %v", err)
+	}
+
+	if string(values[0]) != "1" || values[1] != nil || string(values[2]) != "3" {
+		t.Errorf("unexpected values %q", values)
+	}
+
+	if err := storage.Close(); err != nil {
+		t.Fatalf("failed closing. %v", err)
+	}
+
+	if value, err := storage.Get([]byte("a")); err != nil || string(value) != "1" {
+		t.Errorf("expected reconnect and read 1, found %q. %v", value, err)
+	}
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Always conflicts and records the messages evaluated when
+// each message received its group timestamp.
+type recordingConflict struct {
+	mutex     *sync.Mutex
+	evaluated map[string][]types.Message
+	seen      map[string]int
+}
+
+func (r *recordingConflict) Conflict(message types.Message, messages []types.Message) bool {
+	if message.State == types.S0 {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		key := string(message.Content.Key)
+		r.evaluated[key] = append(r.evaluated[key], messages...)
+		r.seen[key]++
+	}
+	return true
+}
+
+// Wait until the message with the given key is evaluated by all peers.
+func (r *recordingConflict) await(t *testing.T, key string, peers int) {
+	timeout := time.After(5 * time.Second)
+	for {
+		r.mutex.Lock()
+		seen := r.seen[key]
+		r.mutex.Unlock()
+		if seen >= peers {
+			return
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("message %s not evaluated", key)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (r *recordingConflict) evaluatedWith(key string, other string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, message := range r.evaluated[key] {
+		if string(message.Content.Key) == other {
+			return true
+		}
+	}
+	return false
+}
+
+// Write a message that stays pending waiting for an absent partition,
+// then two messages evaluated after the pending one left the previous set.
+// The messages are never delivered, since the pending message has the
+// lowest timestamp.
+func evaluatePending(t *testing.T, name types.Partition, strictness types.ConflictStrictness) *recordingConflict {
+	conflict := &recordingConflict{
+		mutex:     &sync.Mutex{},
+		evaluated: make(map[string][]types.Message),
+		seen:      make(map[string]int),
+	}
+	conf := mcast.DefaultConfiguration(name)
+	conf.Logger.ToggleDebug(false)
+	conf.Conflict = conflict
+	conf.Strictness = strictness
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	unity.Write(GenerateRequest([]byte("pending"), []byte("value"), []types.Partition{name, "strictness-absent"}))
+	conflict.await(t, "pending", conf.Replication)
+	for _, key := range []string{"first", "second"} {
+		unity.Write(GenerateRequest([]byte(key), []byte("value"), []types.Partition{name}))
+		conflict.await(t, key, conf.Replication)
+	}
+	return conflict
+}
+
+func TestStrictness_PreviousSetOnly(t *testing.T) {
+	conflict := evaluatePending(t, "strictness-previous", types.ConflictPreviousSet)
+	if conflict.evaluatedWith("second", "pending") {
+		t.Errorf("pending message should not be evaluated")
+	}
+}
+
+func TestStrictness_EvaluatePending(t *testing.T) {
+	conflict := evaluatePending(t, "strictness-pending", types.ConflictPending)
+	if !conflict.evaluatedWith("second", "pending") {
+		t.Errorf("expected pending message evaluated")
+	}
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+// A storage used as state machine that panics when committing.
+type panickingStorage struct {
+	types.Storage
+}
+
+func (p panickingStorage) Commit(*types.Entry) (interface{}, error) {
+	panic("state machine failure")
+}
+
+func (p panickingStorage) Restore() error {
+	return nil
+}
+
+func TestSupervisor_ProtectRecoversPanic(t *testing.T) {
+	before := core.Panics()
+	if !core.Protect("test", func() { panic("failure") }) {
+		t.Errorf("expected panic recovered")
+	}
+
+	if core.Protect("test", func() {}) {
+		t.Errorf("expected no panic")
+	}
+
+	if panics := core.Panics() - before; panics != 1 {
+		t.Errorf("expected 1 panic counted, found %d", panics)
+	}
+}
+
+func TestSupervisor_RestartAfterPanic(t *testing.T) {
+	runs := 0
+	done := make(chan bool)
+	go func() {
+		core.Supervise(context.Background(), "test", func() {
+			runs++
+			if runs < 3 {
+				panic("failure")
+			}
+		})
+		done <- true
+	}()
+
+	select {
+	case <-done:
+		if runs != 3 {
+			t.Errorf("expected 3 runs, found %d", runs)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("loop was not restarted")
+	}
+}
+
+func TestSupervisor_StopWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		core.Supervise(ctx, "test", func() {
+			cancel()
+			panic("failure")
+		})
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("loop restarted after context done")
+	}
+}
+
+func TestUnity_StateMachinePanicFailsRequest(t *testing.T) {
+	partitionName := types.Partition("panic-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Storage = panickingStorage{Storage: definition.NewInMemoryStorage()}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	request := GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partitionName})
+	select {
+	case res := <-unity.Write(request):
+		if res.Success || !errors.Is(res.Failure, core.ErrCommitPanic) {
+			t.Errorf("expected commit panic, found %#v", res)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("request did not fail")
+	}
+
+	failure := awaitFailure(t, unity, types.CommitFailure)
+	if !errors.Is(failure, core.ErrCommitPanic) {
+		t.Errorf("expected commit panic reported, found %v", failure)
+	}
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/jabolina/go-mcast/pkg/mcast"
 	"github.com/jabolina/go-mcast/pkg/mcast/core"
@@ -25,6 +26,12 @@ func (t *TestInvoker) Spawn(f func()) {
 	}()
 }
 
+func (t *TestInvoker) Supervise(ctx context.Context, name string, f func()) {
+	t.Spawn(func() {
+		core.Supervise(ctx, name, f)
+	})
+}
+
 func (t *TestInvoker) Stop() {
 	t.group.Wait()
 }
@@ -54,15 +61,16 @@ func (c *UnityCluster) Off() {
 
 func NewTestingUnity(configuration *types.Configuration) (mcast.Unity, error) {
 	invk := NewInvoker()
+	types.ApplyLogLevels(configuration.Logger, configuration.LogLevels)
+	reporter := types.NewErrorReporter(types.DefaultErrorBuffer)
+	var dispatch types.DeliverCallback
+	if configuration.OnDeliver != nil {
+		dispatch = core.NewDispatcher(configuration.Executor, configuration.OnDeliver).Dispatch
+	}
 	var peers []core.PartitionPeer
 	for i := 0; i < configuration.Replication; i++ {
-		pc := &types.PeerConfiguration{
-			Name:      fmt.Sprintf("%s-%d", configuration.Name, i),
-			Partition: configuration.Name,
-			Version:   configuration.Version,
-			Conflict:  configuration.Conflict,
-			Storage:   configuration.Storage,
-		}
+		pc := mcast.NewPeerConfiguration(configuration, i, reporter)
+		pc.OnDeliver = dispatch
 		peer, err := core.NewPeer(pc, configuration.Logger)
 		if err != nil {
 			return nil, err
@@ -79,6 +87,26 @@ func NewTestingUnity(configuration *types.Configuration) (mcast.Unity, error) {
 	return pu, nil
 }
 
+// Crash the peer with the given index on the unity, the peer
+// stops without any chance to finish the pending messages.
+func CrashPeer(unity mcast.Unity, index int) {
+	unity.(*mcast.PeerUnity).Peers[index].Stop()
+}
+
+// Restart the crashed peer with the given index on the unity. The
+// new peer has the same name and storage, so it recovers the
+// outbox and the delivered messages from the storage. As after
+// any recovery, the partition moves to the next epoch.
+func RestartPeer(unity mcast.Unity, index int) error {
+	pu := unity.(*mcast.PeerUnity)
+	peer, err := core.NewPeer(mcast.NewPeerConfiguration(pu.Configuration, index, nil), pu.Configuration.Logger)
+	if err != nil {
+		return err
+	}
+	pu.Peers[index] = peer
+	return pu.AdvanceEpoch()
+}
+
 func CreateUnity(name types.Partition, t *testing.T) mcast.Unity {
 	conf := mcast.DefaultConfiguration(name)
 	conf.Logger.ToggleDebug(false)
@@ -90,6 +118,12 @@ func CreateUnity(name types.Partition, t *testing.T) mcast.Unity {
 }
 
 func CreateCluster(clusterSize int, prefix string, t *testing.T) *UnityCluster {
+	return CreateClusterWith(clusterSize, prefix, t, nil)
+}
+
+// Creates the cluster changing the configuration of each unity
+// before starting, if the configure function is given.
+func CreateClusterWith(clusterSize int, prefix string, t *testing.T, configure func(*types.Configuration)) *UnityCluster {
 	cluster := &UnityCluster{
 		T:     t,
 		group: &sync.WaitGroup{},
@@ -100,7 +134,16 @@ func CreateCluster(clusterSize int, prefix string, t *testing.T) *UnityCluster {
 	for i := 0; i < clusterSize; i++ {
 		name := types.Partition(fmt.Sprintf("%s-%s", prefix, helper.GenerateUID()))
 		cluster.Names[i] = name
-		unities = append(unities, CreateUnity(name, t))
+		conf := mcast.DefaultConfiguration(name)
+		conf.Logger.ToggleDebug(false)
+		if configure != nil {
+			configure(conf)
+		}
+		unity, err := NewTestingUnity(conf)
+		if err != nil {
+			t.Fatalf("failed creating unity %s. %v", name, err)
+		}
+		unities = append(unities, unity)
 	}
 	cluster.Unities = unities
 	return cluster
@@ -160,7 +203,9 @@ func (c UnityCluster) DoesAllClusterMatch(key []byte) {
 
 func (c *UnityCluster) PoweroffUnity(unity mcast.Unity) {
 	defer c.group.Done()
-	unity.Shutdown()
+	if err := unity.Shutdown().Error(); err != nil {
+		c.T.Errorf("failed shutting down. %v", err)
+	}
 }
 
 func PrintStackTrace(t *testing.T) {
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/timeline"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func timelineEvent(peer string, kind types.EventKind, key string, state types.MessageState, partition types.Partition, at int) types.Event {
+	event := replayEvent(peer, kind, state, uint64(at), at)
+	event.Key = []byte(key)
+	event.Partition = partition
+	return event
+}
+
+func TestTimeline_GroupedByKey(t *testing.T) {
+	events := map[types.UID][]types.Event{
+		"first": {
+			timelineEvent("orders-0", types.EventSent, "apple", types.S0, "orders", 1),
+			timelineEvent("orders-0", types.EventReceived, "apple", types.S0, "orders", 2),
+			timelineEvent("orders-0", types.EventStateChanged, "apple", types.S3, "", 3),
+			timelineEvent("orders-0", types.EventDelivered, "apple", types.S3, "", 5),
+		},
+		"second": {
+			timelineEvent("orders-1", types.EventDelivered, "apple", types.S3, "", 4),
+		},
+		"third": {
+			timelineEvent("orders-0", types.EventDelivered, "banana", types.S3, "", 6),
+		},
+	}
+
+	timelines := timeline.Build(events, "")
+	if len(timelines) != 2 || timelines[0].Key != "apple" || timelines[1].Key != "banana" {
+		t.Fatalf("expected the apple and banana timelines, found %#v", timelines)
+	}
+	apple := timelines[0]
+	if len(apple.Peers) != 2 || apple.Peers[0] != "orders-0" || apple.Peers[1] != "orders-1" {
+		t.Errorf("expected both peers, found %v", apple.Peers)
+	}
+	var order []types.UID
+	for _, step := range apple.Steps {
+		order = append(order, step.Identifier)
+	}
+	expected := []types.UID{"first", "first", "first", "second", "first"}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("expected steps %v, found %v", expected, order)
+		}
+	}
+
+	if only := timeline.Build(events, "banana"); len(only) != 1 || len(only[0].Steps) != 1 {
+		t.Errorf("expected only the banana timeline, found %#v", only)
+	}
+}
+
+func TestTimeline_RenderFormats(t *testing.T) {
+	events := map[types.UID][]types.Event{
+		"uid": {
+			timelineEvent("orders-0", types.EventSent, "apple", types.S0, "orders", 1),
+			timelineEvent("orders-0", types.EventDelivered, "apple", types.S3, "", 2),
+		},
+	}
+	timelines := timeline.Build(events, "")
+
+	mermaid := &bytes.Buffer{}
+	if err := timeline.Render(mermaid, timelines, timeline.Mermaid); err != nil {
+		t.Fatalf("failed rendering mermaid. %v", err)
+	}
+	for _, line := range []string{
+		"sequenceDiagram",
+		"participant p0 as orders-0",
+		"participant g1 as orders",
+		"p0->>g1: uid sent S0@1",
+		"Note over p0: uid delivered S3@2",
+	} {
+		if !strings.Contains(mermaid.String(), line) {
+			t.Errorf("expected %q on\n%s", line, mermaid.String())
+		}
+	}
+
+	dot := &bytes.Buffer{}
+	if err := timeline.Render(dot, timelines, timeline.Graphviz); err != nil {
+		t.Fatalf("failed rendering graphviz. %v", err)
+	}
+	if !strings.Contains(dot.String(), `digraph "key apple"`) || !strings.Contains(dot.String(), "e0 -> e1;") {
+		t.Errorf("expected the graph of the key, found\n%s", dot.String())
+	}
+
+	encoded := &bytes.Buffer{}
+	if err := timeline.Render(encoded, timelines, timeline.JSON); err != nil {
+		t.Fatalf("failed rendering json. %v", err)
+	}
+	var decoded []timeline.Timeline
+	if err := json.Unmarshal(encoded.Bytes(), &decoded); err != nil || len(decoded) != 1 || len(decoded[0].Steps) != 2 {
+		t.Errorf("expected the timeline back, found %#v. %v", decoded, err)
+	}
+
+	if err := timeline.Render(encoded, timelines, "svg"); !errors.Is(err, timeline.ErrUnknownFormat) {
+		t.Errorf("expected unknown format, found %v", err)
+	}
+}
+
+func TestTimeline_RecordedKeys(t *testing.T) {
+	partitionName := types.Partition("timeline-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Recorder = types.NewEventRecorder(64, 64)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	select {
+	case res := <-unity.Write(GenerateRequest([]byte("timeline-key"), []byte("value"), []types.Partition{partitionName})):
+		if !res.Success {
+			t.Fatalf("failed writing. %v", res.Failure)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write timeout")
+	}
+
+	timelines := timeline.Build(conf.Recorder.Dump(), "timeline-key")
+	if len(timelines) != 1 || len(timelines[0].Steps) == 0 {
+		t.Fatalf("expected the events of the key, found %#v", timelines)
+	}
+	for _, step := range timelines[0].Steps {
+		if step.Event.Kind == types.EventDelivered {
+			return
+		}
+	}
+	t.Errorf("expected the delivery on the timeline, found %#v", timelines[0].Steps)
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"reflect"
+	"testing"
+)
+
+func TestTopology_SplitByProximity(t *testing.T) {
+	local := types.Location{Datacenter: "dc-1", Zone: "a"}
+	topology := types.StaticTopology{
+		"same-zone":       {Datacenter: "dc-1", Zone: "a"},
+		"same-datacenter": {Datacenter: "dc-1", Zone: "b"},
+		"remote":          {Datacenter: "dc-2", Zone: "a"},
+	}
+	partitions := []types.Partition{"remote", "same-datacenter", "same-zone", "unknown"}
+
+	near, far := core.SplitByProximity(local, topology, partitions)
+	expectedNear := []types.Partition{"same-zone", "same-datacenter"}
+	if !reflect.DeepEqual(expectedNear, near) {
+		t.Errorf("expected near %v, found %v", expectedNear, near)
+	}
+
+	expectedFar := []types.Partition{"remote", "unknown"}
+	if !reflect.DeepEqual(expectedFar, far) {
+		t.Errorf("expected far %v, found %v", expectedFar, far)
+	}
+}
+
+func TestTopology_ZoneStatistics(t *testing.T) {
+	statistics := core.NewZoneStatistics()
+	statistics.Sent("a")
+	statistics.Sent("a")
+	statistics.Received("b")
+
+	snapshot := statistics.Snapshot()
+	if snapshot["a"].Sent != 2 || snapshot["a"].Received != 0 {
+		t.Errorf("wrong counters for zone a %#v", snapshot["a"])
+	}
+
+	if snapshot["b"].Sent != 0 || snapshot["b"].Received != 1 {
+		t.Errorf("wrong counters for zone b %#v", snapshot["b"])
+	}
+}
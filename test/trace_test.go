@@ -0,0 +1,115 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A buffer safe to read while the peers are tracing.
+type syncBuffer struct {
+	mutex  *sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buffer.Write(p)
+}
+
+func (s *syncBuffer) bytes() []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]byte{}, s.buffer.Bytes()...)
+}
+
+type failingWriter struct {
+	writes int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	f.writes++
+	return 0, errors.New("disk full")
+}
+
+func TestStepTracer_CanonicalLines(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	tracer := types.NewStepTracer(buffer)
+	tracer.Trace("partition", "uid", types.Event{Kind: types.EventReceived, Peer: "partition-0", Type: types.Initial, Partition: "other"})
+	tracer.Trace("partition", "uid", types.Event{Kind: types.EventDelivered, Peer: "partition-1", Type: types.Initial, State: types.S3, Timestamp: 7})
+
+	expected := `{"seq":1,"peer":"partition-0","group":"partition","action":"received","uid":"uid","type":4,"state":0,"ts":0,"partition":"other"}
+{"seq":2,"peer":"partition-1","group":"partition","action":"delivered","uid":"uid","type":4,"state":3,"ts":7,"partition":""}
+`
+	if buffer.String() != expected {
+		t.Errorf("expected canonical trace\n%s\nfound\n%s", expected, buffer.String())
+	}
+}
+
+func TestStepTracer_StopAfterFailure(t *testing.T) {
+	writer := &failingWriter{}
+	tracer := types.NewStepTracer(writer)
+	tracer.Trace("partition", "first", types.Event{Kind: types.EventReceived})
+	tracer.Trace("partition", "second", types.Event{Kind: types.EventReceived})
+
+	if tracer.Err() == nil || writer.writes != 1 {
+		t.Errorf("expected a single failed write, found %d and %v", writer.writes, tracer.Err())
+	}
+}
+
+func TestStepTracer_NilIgnoresSteps(t *testing.T) {
+	var tracer *types.StepTracer
+	tracer.Trace("partition", "uid", types.Event{Kind: types.EventReceived})
+	if err := tracer.Err(); err != nil {
+		t.Errorf("expected no error, found %v", err)
+	}
+}
+
+func TestStepTracer_TracesPeerSteps(t *testing.T) {
+	buffer := &syncBuffer{mutex: &sync.Mutex{}}
+	partition := types.Partition("traced-unity")
+	conf := mcast.DefaultConfiguration(partition)
+	conf.Logger.ToggleDebug(false)
+	conf.Tracer = types.NewStepTracer(buffer)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	res := <-unity.Write(GenerateRandomRequest([]types.Partition{partition}))
+	if !res.Success {
+		t.Fatalf("failed writing. %v", res.Failure)
+	}
+
+	// The response is sent before every replica commits.
+	delivered := 0
+	deadline := time.Now().Add(5 * time.Second)
+	for delivered != conf.Replication && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		delivered = 0
+		scanner := bufio.NewScanner(bytes.NewReader(buffer.bytes()))
+		for sequence := uint64(1); scanner.Scan(); sequence++ {
+			var step types.TraceStep
+			if err := json.Unmarshal(scanner.Bytes(), &step); err != nil {
+				t.Fatalf("invalid step %s. %v", scanner.Text(), err)
+			}
+			if step.Sequence != sequence || step.Group != partition {
+				t.Fatalf("unexpected step %#v at %d", step, sequence)
+			}
+			if step.Action == types.EventDelivered && step.Identifier == res.Identifier {
+				delivered++
+			}
+		}
+	}
+	if delivered != conf.Replication {
+		t.Errorf("expected %d peers delivering, found %d", conf.Replication, delivered)
+	}
+}
@@ -0,0 +1,58 @@
+//go:build tracevalidation
+// +build tracevalidation
+
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Write the trace of concurrent writes across partitions on the
+// file at MCAST_TRACE, to be validated against the specification
+// on the spec folder. Executed with the tracevalidation tag, see
+// the trace-validation target.
+func TestTraceValidation_WriteTrace(t *testing.T) {
+	path := os.Getenv("MCAST_TRACE")
+	if len(path) == 0 {
+		t.Skip("MCAST_TRACE not set")
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed creating trace. %v", err)
+	}
+	defer file.Close()
+
+	tracer := types.NewStepTracer(file)
+	broker := core.NewMemoryBroker()
+	cluster := CreateClusterWith(3, "trace", t, func(conf *types.Configuration) {
+		conf.Broker = broker
+		conf.Tracer = tracer
+	})
+
+	key := []byte("trace")
+	group := &sync.WaitGroup{}
+	for _, letter := range Alphabet {
+		group.Add(1)
+		go func(letter string) {
+			defer group.Done()
+			res := <-cluster.Next().Write(GenerateRequest(key, []byte(letter), cluster.Names))
+			if !res.Success {
+				t.Errorf("failed writing %s. %v", letter, res.Failure)
+			}
+		}(letter)
+	}
+	if !WaitThisOrTimeout(group.Wait, 30*time.Second) {
+		t.Fatalf("writes not finished")
+	}
+
+	time.Sleep(time.Second)
+	cluster.Off()
+	if err := tracer.Err(); err != nil {
+		t.Errorf("failed writing trace. %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+)
+
+func transitionMessage(state types.MessageState, timestamp uint64) types.Message {
+	return types.Message{Identifier: "transition", State: state, Timestamp: timestamp}
+}
+
+func TestTransitionChecker_LegalTransitions(t *testing.T) {
+	paths := [][]types.MessageState{
+		{types.S0, types.S1, types.S2, types.S3},
+		{types.S0, types.S3},
+		{types.S0, types.S1, types.S3},
+		{types.S0, types.S0, types.S1, types.S1, types.S3},
+	}
+	for _, path := range paths {
+		checker := core.NewTransitionChecker()
+		for i, state := range path {
+			if err := checker.Observe(transitionMessage(state, uint64(i))); err != nil {
+				t.Errorf("path %v failed. %v", path, err)
+			}
+		}
+	}
+}
+
+func TestTransitionChecker_IllegalTransitions(t *testing.T) {
+	paths := [][]types.MessageState{
+		{types.S0, types.S2},
+		{types.S1, types.S0},
+		{types.S3, types.S2},
+	}
+	for _, path := range paths {
+		checker := core.NewTransitionChecker()
+		checker.Observe(transitionMessage(path[0], 1))
+		if err := checker.Observe(transitionMessage(path[1], 1)); !errors.Is(err, core.ErrIllegalTransition) {
+			t.Errorf("path %v expected illegal transition, found %v", path, err)
+		}
+	}
+}
+
+func TestTransitionChecker_TimestampDecreased(t *testing.T) {
+	checker := core.NewTransitionChecker()
+	checker.Observe(transitionMessage(types.S1, 5))
+	if err := checker.Observe(transitionMessage(types.S2, 3)); !errors.Is(err, core.ErrTimestampDecreased) {
+		t.Errorf("expected timestamp decreased, found %v", err)
+	}
+}
+
+func TestTransitionChecker_Forget(t *testing.T) {
+	checker := core.NewTransitionChecker()
+	checker.Observe(transitionMessage(types.S3, 5))
+	checker.Forget("transition")
+	if err := checker.Observe(transitionMessage(types.S0, 1)); err != nil {
+		t.Errorf("expected history forgotten, found %v", err)
+	}
+}
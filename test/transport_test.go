@@ -0,0 +1,59 @@
+package test
+
+import (
+	"github.com/jabolina/go-mcast/pkg/mcast/core"
+	"github.com/jabolina/go-mcast/pkg/mcast/core/transporttest"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+)
+
+func reliableTransport(partition types.Partition, name string) (core.Transport, error) {
+	peer := &types.PeerConfiguration{
+		Name:      name,
+		Partition: partition,
+		Version:   types.LatestProtocolVersion,
+	}
+	return core.NewTransport(peer, core.NewRTTEstimator(), definition.NewDefaultLogger())
+}
+
+func TestTransport_ReliableConformance(t *testing.T) {
+	transporttest.Run(t, reliableTransport)
+}
+
+func TestTransport_MemoryBrokerConformance(t *testing.T) {
+	broker := core.NewMemoryBroker()
+	transporttest.Run(t, func(partition types.Partition, name string) (core.Transport, error) {
+		peer := &types.PeerConfiguration{Name: name, Partition: partition, Broker: broker}
+		return core.NewTransport(peer, core.NewRTTEstimator(), definition.NewDefaultLogger())
+	})
+}
+
+func TestTransport_SequencedConformance(t *testing.T) {
+	transporttest.Run(t, func(partition types.Partition, name string) (core.Transport, error) {
+		reliable, err := reliableTransport(partition, name)
+		if err != nil {
+			return nil, err
+		}
+		peer := &types.PeerConfiguration{Name: name, Partition: partition}
+		return core.NewSequencedTransport(reliable, peer, definition.NewDefaultLogger()), nil
+	})
+}
+
+func TestTransport_OutboxConformance(t *testing.T) {
+	transporttest.Run(t, func(partition types.Partition, name string) (core.Transport, error) {
+		reliable, err := reliableTransport(partition, name)
+		if err != nil {
+			return nil, err
+		}
+		return core.NewOutboxTransport(reliable, definition.NewInMemoryStorage(), name, definition.NewDefaultLogger()), nil
+	})
+}
+
+func TestTransport_SignedConformance(t *testing.T) {
+	broker := core.NewMemoryBroker()
+	transporttest.Run(t, func(partition types.Partition, name string) (core.Transport, error) {
+		peer := &types.PeerConfiguration{Name: name, Partition: partition, Broker: broker, SigningKey: []byte("cluster-key")}
+		return core.NewTransport(peer, core.NewRTTEstimator(), definition.NewDefaultLogger())
+	})
+}
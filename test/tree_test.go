@@ -0,0 +1,69 @@
+package test
+
+import (
+	"fmt"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTree_Position(t *testing.T) {
+	tree := types.TreeExchange{Threshold: 2, Fanout: 2}
+	participants := []types.Partition{"f", "c", "a", "e", "b", "d"}
+	cases := []struct {
+		partition types.Partition
+		parent    types.Partition
+		children  []types.Partition
+	}{
+		{"a", "", []types.Partition{"b", "c"}},
+		{"b", "a", []types.Partition{"d", "e"}},
+		{"c", "a", []types.Partition{"f"}},
+		{"e", "b", nil},
+	}
+	for _, c := range cases {
+		parent, children, height := tree.Position(participants, c.partition)
+		if parent != c.parent || !reflect.DeepEqual(children, c.children) || height != 2 {
+			t.Errorf("%s expected %s %v, found %s %v with height %d", c.partition, c.parent, c.children, parent, children, height)
+		}
+	}
+
+	if tree.Applies(1) || !tree.Applies(2) || (types.TreeExchange{}).Applies(10) {
+		t.Errorf("tree applied to the wrong participants")
+	}
+}
+
+func TestTree_ExchangeThroughTree(t *testing.T) {
+	cluster := CreateClusterWith(5, "tree", t, func(conf *types.Configuration) {
+		conf.Tree = types.TreeExchange{Threshold: 3, Fanout: 2}
+	})
+	defer cluster.Off()
+
+	key := []byte("tree")
+	group := &sync.WaitGroup{}
+	for i, unity := range cluster.Unities {
+		group.Add(1)
+		value := []byte(fmt.Sprintf("value-%d", i))
+		go func(write func(types.Request) <-chan types.Response) {
+			defer group.Done()
+			select {
+			case res := <-write(GenerateRequest(key, value, cluster.Names)):
+				if !res.Success {
+					t.Errorf("failed writing. %v", res.Failure)
+				}
+			case <-time.After(5 * time.Second):
+				t.Errorf("write timeout")
+			}
+		}(unity.Write)
+	}
+	group.Wait()
+
+	time.Sleep(100 * time.Millisecond)
+	cluster.DoesAllClusterMatch(key)
+	for _, unity := range cluster.Unities {
+		if timeouts := unity.GatherTimeouts(); timeouts > 0 {
+			t.Errorf("expected the tree to complete, found %d timeouts", timeouts)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/helper"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func TestValidation_DefaultValidators(t *testing.T) {
+	destination := []types.Partition{"first"}
+	cases := []struct {
+		validator types.Validator
+		request   types.Request
+		field     string
+	}{
+		{definition.KeySizeValidator{Max: 4}, types.Request{Destination: destination}, "Key"},
+		{definition.KeySizeValidator{Max: 4}, types.Request{Key: []byte("large-key")}, "Key"},
+		{definition.KeySizeValidator{Max: 4}, types.Request{Key: []byte("key"), Keys: [][]byte{[]byte("large-key")}}, "Keys"},
+		{definition.ValueSizeValidator{Max: 4}, types.Request{Value: []byte("abc"), Extra: []byte("de")}, "Value"},
+		{definition.JSONValueValidator{}, types.Request{Value: []byte("{invalid")}, "Value"},
+		{definition.DestinationValidator{}, types.Request{}, "Destination"},
+		{definition.DestinationValidator{}, types.Request{Destination: []types.Partition{"first", "first"}}, "Destination"},
+		{definition.DestinationValidator{Known: destination}, types.Request{Destination: []types.Partition{"second"}}, "Destination"},
+	}
+	for i, c := range cases {
+		err := c.validator.Validate(c.request)
+		var validation *types.ValidationError
+		if !errors.As(err, &validation) || validation.Field != c.field || !errors.Is(err, types.ErrInvalidRequest) {
+			t.Errorf("case %d expected invalid %s, found %v", i, c.field, err)
+		}
+	}
+
+	valid := types.Request{Key: []byte("key"), Value: []byte(`{"a": 1}`), Destination: destination}
+	validators := []types.Validator{
+		definition.KeySizeValidator{Max: 4},
+		definition.ValueSizeValidator{Max: 16},
+		definition.JSONValueValidator{},
+		definition.DestinationValidator{Known: destination},
+	}
+	if err := types.ValidateRequest(valid, validators); err != nil {
+		t.Errorf("expected valid request, found %v", err)
+	}
+}
+
+func TestValidation_RejectedBeforeReplicating(t *testing.T) {
+	partitionName := types.Partition("validated-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Logger.ToggleDebug(false)
+	conf.Validators = []types.Validator{
+		definition.KeySizeValidator{Max: 8},
+		types.ValidatorFunc(func(request types.Request) error {
+			if string(request.Value) == "forbidden" {
+				return &types.ValidationError{Field: "Value", Reason: "is forbidden"}
+			}
+			return nil
+		}),
+	}
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	for _, request := range []types.Request{
+		GenerateRequest([]byte("a-very-long-key"), []byte("value"), []types.Partition{partitionName}),
+		GenerateRequest([]byte("key"), []byte("forbidden"), []types.Partition{partitionName}),
+	} {
+		res := <-unity.Write(request)
+		if res.Success || !errors.Is(res.Failure, types.ErrInvalidRequest) {
+			t.Errorf("expected invalid request, found %#v", res)
+		}
+
+		if read, _ := unity.Read(request); read.Success {
+			t.Errorf("invalid request was committed")
+		}
+	}
+
+	select {
+	case res := <-unity.Write(GenerateRequest([]byte("key"), []byte("value"), []types.Partition{partitionName})):
+		if !res.Success {
+			t.Errorf("failed writing valid request. %v", res.Failure)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("write timeout")
+	}
+}
+
+func TestValidation_ClientRejectsBeforeSending(t *testing.T) {
+	conf := mcast.DefaultClientConfiguration("client-" + helper.GenerateUID())
+	conf.Validators = []types.Validator{definition.DestinationValidator{}}
+	client, err := mcast.NewClient(conf)
+	if err != nil {
+		t.Fatalf("failed creating client. %v", err)
+	}
+	defer client.Close()
+
+	select {
+	case res := <-client.Write(types.Request{Key: []byte("key")}):
+		if !errors.Is(res.Failure, types.ErrInvalidRequest) {
+			t.Errorf("expected invalid request, found %#v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("validation should fail right away")
+	}
+}
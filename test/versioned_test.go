@@ -0,0 +1,76 @@
+package test
+
+import (
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"testing"
+	"time"
+)
+
+func TestVersioned_RetainLastVersions(t *testing.T) {
+	storage := definition.NewVersionedStorage(definition.NewInMemoryStorage(), 2)
+	for _, entry := range historyEntries() {
+		e := entry
+		if _, err := storage.Commit(&e); err != nil {
+			t.Fatalf("failed committing. %v", err)
+		}
+		if _, err := storage.Commit(&e); err != nil {
+			t.Fatalf("failed committing again. %v", err)
+		}
+	}
+
+	history, err := storage.History(types.HistoryFilter{})
+	if err != nil || historyIdentifiers(history) != "1234" {
+		t.Errorf("expected entries 1234, found %#v. %v", history, err)
+	}
+
+	history, err = storage.History(types.HistoryFilter{Key: []byte("a"), To: 3})
+	if err != nil || historyIdentifiers(history) != "2" {
+		t.Errorf("expected entry 2, found %#v. %v", history, err)
+	}
+}
+
+func TestVersioned_ReadAtTimestamp(t *testing.T) {
+	partitionName := types.Partition("versioned-unity")
+	conf := mcast.DefaultConfiguration(partitionName)
+	conf.Storage = definition.NewVersionedStorage(definition.NewInMemoryStorage(), 2)
+	conf.Logger.ToggleDebug(false)
+	unity, err := NewTestingUnity(conf)
+	if err != nil {
+		t.Fatalf("failed creating unity. %v", err)
+	}
+	defer unity.Shutdown()
+
+	key := []byte("versioned-key")
+	values := []string{"first", "second", "third"}
+	var timestamps []uint64
+	for _, value := range values {
+		select {
+		case res := <-unity.Write(GenerateRequest(key, []byte(value), []types.Partition{partitionName})):
+			if !res.Success || res.Token == nil {
+				t.Fatalf("failed writing. %v", res.Failure)
+			}
+			timestamps = append(timestamps, res.Token.Timestamp)
+		case <-time.After(time.Second):
+			t.Fatalf("write timeout")
+		}
+	}
+
+	if _, err := unity.ReadAt(key, timestamps[0]); !errors.Is(err, mcast.ErrNoVersion) {
+		t.Errorf("expected the first version dropped, found %v", err)
+	}
+
+	for i := 1; i < len(values); i++ {
+		res, err := unity.ReadAt(key, timestamps[i])
+		if err != nil || string(res.Data) != values[i] {
+			t.Errorf("expected %s at %d, found %#v. %v", values[i], timestamps[i], res, err)
+		}
+	}
+
+	res, err := unity.ReadAt(key, 0)
+	if err != nil || string(res.Data) != "third" {
+		t.Errorf("expected the latest value, found %#v. %v", res, err)
+	}
+}
@@ -0,0 +1,325 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/jabolina/go-mcast/pkg/mcast/definition"
+	"github.com/jabolina/go-mcast/pkg/mcast/types"
+	"github.com/jabolina/go-mcast/pkg/mcast/types/storagetest"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Environment variable with the log path used by the helper process.
+const walHelperEnv = "MCAST_WAL_HELPER"
+
+// A storage that kills the process when setting the crash key.
+type crashingStorage struct {
+	types.Storage
+}
+
+func (c crashingStorage) Set(key []byte, value []byte) error {
+	if string(key) == "crash" {
+		os.Exit(3)
+	}
+	return c.Storage.Set(key, value)
+}
+
+func walEntry(id string, key string) *types.Entry {
+	return &types.Entry{Operation: types.Command, Identifier: types.UID(id), Key: []byte(key), Data: []byte(id)}
+}
+
+func walPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatalf("failed creating dir. %v", err)
+	}
+	return filepath.Join(dir, "mcast.wal"), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func openWAL(t *testing.T, path string, storage types.Storage) *definition.WALStorage {
+	wal, err := definition.NewWALStorage(path, storage)
+	if err != nil {
+		t.Fatalf("failed opening log. %v", err)
+	}
+
+	if err := wal.Restore(); err != nil {
+		t.Fatalf("failed restoring. %v", err)
+	}
+	return wal
+}
+
+func committed(t *testing.T, storage types.Storage, key string) *types.Entry {
+	data, err := storage.Get([]byte(key))
+	if err != nil {
+		return nil
+	}
+
+	var entry types.Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("failed decoding %s. %v", key, err)
+	}
+	return &entry
+}
+
+// Not a real test, executed as a separate process that is
+// killed while committing an entry.
+func TestWALStorage_HelperProcess(t *testing.T) {
+	path := os.Getenv(walHelperEnv)
+	if len(path) == 0 {
+		return
+	}
+
+	wal := openWAL(t, path, crashingStorage{Storage: definition.NewInMemoryStorage()})
+	if _, err := wal.Commit(walEntry("first", "key")); err != nil {
+		t.Fatalf("failed committing. %v", err)
+	}
+	wal.Commit(walEntry("second", "crash"))
+	t.Fatalf("process should have crashed")
+}
+
+func TestWALStorage_KilledMidCommit(t *testing.T) {
+	path, clean := walPath(t)
+	defer clean()
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestWALStorage_HelperProcess")
+	cmd.Env = append(os.Environ(), walHelperEnv+"="+path)
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 3 {
+		t.Fatalf("expected process killed mid commit, found %v", err)
+	}
+
+	storage := definition.NewInMemoryStorage()
+	wal := openWAL(t, path, storage)
+	defer wal.Close()
+
+	for _, key := range []string{"key", "crash"} {
+		if committed(t, storage, key) == nil {
+			t.Errorf("expected %s applied after restore", key)
+		}
+	}
+}
+
+func TestWALStorage_AbortedNotApplied(t *testing.T) {
+	path, clean := walPath(t)
+	defer clean()
+
+	failing := storagetest.NewFailingStorage(definition.NewInMemoryStorage())
+	wal := openWAL(t, path, failing)
+	failing.Fail(true)
+	if _, err := wal.Commit(walEntry("aborted", "key")); !errors.Is(err, storagetest.ErrInjected) {
+		t.Fatalf("expected injected failure, found %v", err)
+	}
+	failing.Fail(false)
+
+	if _, err := wal.Commit(walEntry("applied", "other")); err != nil {
+		t.Fatalf("failed committing. %v", err)
+	}
+	wal.Close()
+
+	storage := definition.NewInMemoryStorage()
+	wal = openWAL(t, path, storage)
+	defer wal.Close()
+	if entry := committed(t, storage, "key"); entry != nil {
+		t.Errorf("aborted entry applied %#v", entry)
+	}
+
+	if entry := committed(t, storage, "other"); entry == nil || entry.Identifier != "applied" {
+		t.Errorf("expected applied entry, found %#v", entry)
+	}
+}
+
+func TestWALStorage_DiscardTornRecord(t *testing.T) {
+	path, clean := walPath(t)
+	defer clean()
+
+	wal := openWAL(t, path, definition.NewInMemoryStorage())
+	if _, err := wal.Commit(walEntry("first", "key")); err != nil {
+		t.Fatalf("failed committing. %v", err)
+	}
+	wal.Close()
+
+	// Simulates a crash while the record header was written.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("failed opening log. %v", err)
+	}
+	file.Write([]byte{0x00, 0x00, 0x01})
+	file.Close()
+
+	wal = openWAL(t, path, definition.NewInMemoryStorage())
+	if _, err := wal.Commit(walEntry("second", "key")); err != nil {
+		t.Fatalf("failed committing after torn record. %v", err)
+	}
+	wal.Close()
+
+	storage := definition.NewInMemoryStorage()
+	wal = openWAL(t, path, storage)
+	defer wal.Close()
+	if entry := committed(t, storage, "key"); entry == nil || entry.Identifier != "second" {
+		t.Errorf("expected second entry, found %#v", entry)
+	}
+}
+
+func TestWALStorage_Conformance(t *testing.T) {
+	path, clean := walPath(t)
+	defer clean()
+	storagetest.Run(t, func() (types.Storage, error) {
+		file, err := ioutil.TempFile(filepath.Dir(path), "conformance")
+		if err != nil {
+			return nil, err
+		}
+		file.Close()
+		return definition.NewWALStorage(file.Name(), definition.NewInMemoryStorage())
+	})
+}
+
+func TestWALStorage_DurabilityPolicies(t *testing.T) {
+	policies := []types.Durability{
+		{Policy: types.SyncOnCommit},
+		{Policy: types.NoSync},
+		{Policy: types.SyncBatched, Interval: time.Hour},
+	}
+	for _, durability := range policies {
+		path, clean := walPath(t)
+		wal := openWAL(t, path, definition.NewInMemoryStorage())
+		wal.SetDurability(durability)
+		for _, id := range []string{"first", "second"} {
+			if _, err := wal.Commit(walEntry(id, id)); err != nil {
+				t.Fatalf("policy %d failed committing. %v", durability.Policy, err)
+			}
+		}
+		if err := wal.Close(); err != nil {
+			t.Fatalf("policy %d failed closing. %v", durability.Policy, err)
+		}
+
+		storage := definition.NewInMemoryStorage()
+		wal = openWAL(t, path, storage)
+		for _, id := range []string{"first", "second"} {
+			if committed(t, storage, id) == nil {
+				t.Errorf("policy %d expected %s restored", durability.Policy, id)
+			}
+		}
+		wal.Close()
+		clean()
+	}
+}
+
+// The batched entries are synced on the background while
+// committing, changing the interval and closing the storage.
+func TestWALStorage_BatchedFlushOnBackground(t *testing.T) {
+	path, clean := walPath(t)
+	defer clean()
+	wal := openWAL(t, path, definition.NewInMemoryStorage())
+	wal.SetDurability(types.Durability{Policy: types.SyncBatched, Interval: 5 * time.Millisecond})
+	wal.SetDurability(types.Durability{Policy: types.SyncBatched, Interval: time.Millisecond})
+	for _, id := range []string{"first", "second"} {
+		if _, err := wal.Commit(walEntry(id, id)); err != nil {
+			t.Fatalf("failed committing %s. %v", id, err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := wal.Close(); err != nil {
+		t.Fatalf("failed closing. %v", err)
+	}
+}
+
+// A storage counting the values set.
+type countingStorage struct {
+	types.Storage
+	sets int
+}
+
+func (c *countingStorage) Set(key []byte, value []byte) error {
+	c.sets++
+	return c.Storage.Set(key, value)
+}
+
+func TestWALStorage_IndexedHistoryAndLookup(t *testing.T) {
+	path, clean := walPath(t)
+	defer clean()
+
+	failing := storagetest.NewFailingStorage(definition.NewInMemoryStorage())
+	wal := openWAL(t, path, failing)
+	defer wal.Close()
+	for _, entry := range []*types.Entry{walEntry("first", "key"), walEntry("other", "other"), walEntry("second", "key")} {
+		if _, err := wal.Commit(entry); err != nil {
+			t.Fatalf("failed committing. %v", err)
+		}
+	}
+	failing.Fail(true)
+	if _, err := wal.Commit(walEntry("aborted", "key")); !errors.Is(err, storagetest.ErrInjected) {
+		t.Fatalf("expected injected failure, found %v", err)
+	}
+	failing.Fail(false)
+
+	history, err := wal.History(types.HistoryFilter{Key: []byte("key")})
+	if err != nil {
+		t.Fatalf("failed reading history. %v", err)
+	}
+	if len(history) != 2 || history[0].Identifier != "first" || history[1].Identifier != "second" {
+		t.Errorf("expected the key history without the aborted entry, found %#v", history)
+	}
+
+	if entry, ok, err := wal.Lookup("other"); err != nil || !ok || string(entry.Key) != "other" {
+		t.Errorf("expected entry found by the identifier, found %#v %v %v", entry, ok, err)
+	}
+	if _, ok, err := wal.Lookup("aborted"); err != nil || ok {
+		t.Errorf("expected aborted entry not found, found %v %v", ok, err)
+	}
+}
+
+func TestWALStorage_RestoreFromIndexSnapshot(t *testing.T) {
+	path, clean := walPath(t)
+	defer clean()
+
+	wal := openWAL(t, path, definition.NewInMemoryStorage())
+	for _, id := range []string{"first", "second", "third"} {
+		if _, err := wal.Commit(walEntry(id, "key")); err != nil {
+			t.Fatalf("failed committing. %v", err)
+		}
+	}
+	if err := wal.Snapshot(); err != nil {
+		t.Fatalf("failed writing snapshot. %v", err)
+	}
+
+	// Appended after the snapshot, and the log is never closed.
+	if _, err := wal.Commit(walEntry("fourth", "other")); err != nil {
+		t.Fatalf("failed committing. %v", err)
+	}
+
+	storage := &countingStorage{Storage: definition.NewInMemoryStorage()}
+	restored := openWAL(t, path, storage)
+	if storage.sets != 2 {
+		t.Errorf("expected only the last entry of each key applied, found %d", storage.sets)
+	}
+	if entry := committed(t, storage, "key"); entry == nil || entry.Identifier != "third" {
+		t.Errorf("expected third entry, found %#v", entry)
+	}
+	if entry := committed(t, storage, "other"); entry == nil || entry.Identifier != "fourth" {
+		t.Errorf("expected entry after the snapshot, found %#v", entry)
+	}
+	restored.Close()
+	wal.Close()
+
+	// A snapshot not matching the log is ignored.
+	if err := ioutil.WriteFile(path+".index", []byte(`{"uids":{},"keys":{},"size":3,"last":0}`), 0600); err != nil {
+		t.Fatalf("failed writing snapshot. %v", err)
+	}
+	storage = &countingStorage{Storage: definition.NewInMemoryStorage()}
+	wal = openWAL(t, path, storage)
+	defer wal.Close()
+	if entry := committed(t, storage, "other"); entry == nil || entry.Identifier != "fourth" {
+		t.Errorf("expected log read from the start, found %#v", entry)
+	}
+	if history, err := wal.History(types.HistoryFilter{}); err != nil || len(history) != 4 {
+		t.Errorf("expected the whole history, found %d entries. %v", len(history), err)
+	}
+}